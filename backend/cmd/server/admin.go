@@ -0,0 +1,51 @@
+package main
+
+import (
+	"backend/pkg/auth"
+	structure "backend/pkg/struct"
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+const adminUsage = `usage:
+  admin create <username> [role]    create a user, reading the password from stdin (role defaults to admin)
+  admin key <name> [role]           create an API key and print it (role defaults to admin)`
+
+// runAdmin bootstraps access from the command line, so the first admin can be created before
+// anyone can log in
+func runAdmin(store *auth.Store, args []string) {
+	if len(args) < 2 {
+		log.Fatal(adminUsage)
+	}
+	role := string(auth.Admin)
+	if len(args) > 2 {
+		role = args[2]
+	}
+
+	switch args[0] {
+	case "create":
+		fmt.Fprintf(os.Stderr, "Password for %s: ", args[1])
+		password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && password == "" {
+			log.Fatalf("Error reading password: %v", err)
+		}
+		password = strings.TrimRight(password, "\r\n")
+
+		user, err := store.CreateUser(structure.UserUpdate{Username: args[1], Password: &password, Role: &role})
+		if err != nil {
+			log.Fatalf("Error creating user: %v", err)
+		}
+		fmt.Printf("Created %s user %s (id %d)\n", user.Role, user.Username, user.ID)
+	case "key":
+		key, err := store.CreateKey(args[1], auth.Role(role), "cli")
+		if err != nil {
+			log.Fatalf("Error creating API key: %v", err)
+		}
+		fmt.Printf("Created %s key %s (id %d). It is shown only once:\n%s\n", key.Role, key.Name, key.ID, key.Key)
+	default:
+		log.Fatal(adminUsage)
+	}
+}
@@ -4,51 +4,203 @@ import (
 	"backend/pkg/db"
 	"fmt"
 	"backend/pkg/api"
-	"net/http"
+	"backend/pkg/audit"
+	"backend/pkg/auth"
+	"backend/pkg/calculation"
+	"backend/pkg/config"
 	"backend/pkg/data"
+	"backend/pkg/logging"
+	"backend/pkg/model"
+	"net/http"
+	"backend/pkg/pipeline"
+	"backend/pkg/quality"
+	"backend/pkg/repository/sqlstore"
+	"backend/pkg/telemetry"
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
-func enableCORS(handler http.HandlerFunc) http.HandlerFunc {
-    return func(w http.ResponseWriter, r *http.Request) {
-        // Set CORS headers for all responses including errors
-        w.Header().Set("Access-Control-Allow-Origin", "*")  // Allow all origins in development
-        w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-        w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-        w.Header().Set("Access-Control-Max-Age", "3600")
-        
-        // Handle preflight requests
-        if r.Method == "OPTIONS" {
-            w.WriteHeader(http.StatusOK)
-            return
-        }
-
-        // Call the actual handler
-        handler(w, r)
-    }
+// fatal logs msg as an error and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+func printPlan(graph *pipeline.Graph) {
+	plan, err := graph.Plan(true)
+	if err != nil {
+		fatal("Error planning pipeline", "err", err)
+	}
+
+	fmt.Printf("%-24s %-11s %s\n", "STEP", "ACTION", "REASON")
+	for _, step := range plan {
+		fmt.Printf("%-24s %-11s %s\n", step.Name, step.Action, step.Reason)
+	}
+}
+
+// openDatabase opens the database the configured driver keeps the data in
+func openDatabase(cfg config.DatabaseConfig) (*db.DB, error) {
+	if cfg.Driver == "postgres" {
+		return db.OpenPostgres(string(cfg.URL), cfg.Timescale)
+	}
+	return db.Open(cfg.Path)
 }
 
 func main() {
-	fmt.Println("APP Started")
-    // Then initialize the new database
-    db.InitializeDb()
-    defer db.Database.Close()
-	data.FillDb()
+	dryRun := flag.Bool("dry-run", false, "print which pipeline steps are stale and exit")
+	loader := config.Bind(flag.CommandLine)
+	flag.Parse()
 
+	cfg, err := loader.Load()
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+	if _, err := logging.Setup(cfg.Logging, os.Stderr); err != nil {
+		log.Fatalf("Error setting up logging: %v", err)
+	}
+
+	// config print shows the effective settings after the file, environment and flags are applied
+	if flag.Arg(0) == "config" {
+		if flag.Arg(1) != "print" {
+			log.Fatalf("Unknown config command %q, expected: config print", flag.Arg(1))
+		}
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatalf("Error printing config: %v", err)
+		}
+		return
+	}
+
+	slog.Info("App started")
+	database, err := openDatabase(cfg.Database)
+	if err != nil {
+		fatal("Error opening database", "driver", cfg.Database.Driver, "err", err)
+	}
+	defer database.Close()
 
-	http.HandleFunc("/api/environment-impact", enableCORS(api.EnvironmentalImpact))
-	http.HandleFunc("/api/weather-impact", enableCORS(api.WeatherImpact))
-	http.HandleFunc("/api/total-power-generation", enableCORS(api.TotalPowerGeneration))
-	http.HandleFunc("/api/awali-power-generation", enableCORS(api.AwaliPowerGeneration))
-	http.HandleFunc("/api/uob-power-generation", enableCORS(api.UOBPowerGeneration))
-	http.HandleFunc("/api/refinery-power-generation", enableCORS(api.RefineryPowerGeneration))
-	http.HandleFunc("/api/performance", enableCORS(api.Performance))
-	http.HandleFunc("/api/system-configuration", enableCORS(api.SystemConfiguration))
+	repos := sqlstore.New(database)
+	runner := model.NewRunner(database, cfg.Models)
+	dataLoader := data.NewLoader(database, runner, cfg.Data, cfg.Weather, cfg.Models)
+	checker := quality.NewChecker(database, cfg.Data.Imputation)
+	calculator := calculation.New(repos, runner, cfg.Models.MonthlyForecast, calculation.CarbonIntensity(repos.EmissionFactors))
+	graph := pipeline.NewRecompute(database, cfg.Data, dataLoader, checker, calculator)
+	store := auth.NewStore(database, cfg.Auth)
+
+	if *dryRun {
+		printPlan(graph)
+		return
+	}
+
+	if flag.Arg(0) == "admin" {
+		runAdmin(store, flag.Args()[1:])
+		return
+	}
+	if ok, err := store.HasAdmin(); err != nil {
+		slog.Error("Error checking for an admin", "err", err)
+	} else if !ok {
+		slog.Warn("No admin user or key exists; create one with: server admin create <username>")
+	}
 
-	//Start the server on port 8080
-	fmt.Println("Starting server on port 8080...")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		fmt.Println("Error starting server:", err)
+	// SIGINT and SIGTERM cancel ctx, which stops the startup refresh, the scheduler and telemetry
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Start the revision history, and pick up anything changed while the server was down
+	if err := audit.RecordNow(ctx, database, audit.Change{Author: "system", Reason: "startup"}); err != nil {
+		slog.Error("Error recording revisions", "err", err)
+	}
+
+	scheduler := pipeline.NewScheduler(database)
+	if err := scheduler.Register(pipeline.RecomputeJob, cfg.Pipeline.Schedule, graph.Steps(true)...); err != nil {
+		fatal("Error registering pipeline job", "err", err)
+	}
+	if err := scheduler.Register(pipeline.RefreshJob, "", graph.Steps(false)...); err != nil {
+		fatal("Error registering pipeline job", "err", err)
+	}
+
+	// Fill empty tables and recompute anything stale before serving
+	if _, err := scheduler.RunNow(ctx, pipeline.RefreshJob, pipeline.TriggerStartup); err != nil {
+		slog.Error("Error refreshing derived tables", "err", err)
+	}
+	if ctx.Err() != nil {
+		slog.Info("App ended")
+		return
+	}
+	scheduler.Start(ctx)
+
+	poller := telemetry.NewPoller(database)
+	if cfg.Telemetry.Config != "" {
+		devices, err := telemetry.LoadConfig(cfg.Telemetry.Config)
+		if err != nil {
+			fatal("Error loading telemetry config", "err", err)
+		}
+		if err := poller.Configure(devices); err != nil {
+			fatal("Error in telemetry config", "path", cfg.Telemetry.Config, "err", err)
+		}
+		poller.Start(ctx)
+	}
+
+	handlers := api.NewHandlers(repos, api.Services{
+		Environment: calculation.NewEnvironment(repos.Generation, calculation.CarbonIntensity(repos.EmissionFactors)),
+		Calculator:  calculator,
+		Loader:      dataLoader,
+		Checker:     checker,
+		Auth:        store,
+		Scheduler:   scheduler,
+		Recompute:   graph,
+		Telemetry:   poller,
+		Freshness:   cfg.Freshness,
+	})
+	handlers.RegisterGauges()
+
+	cors := api.NewCORS(cfg.CORS)
+	for _, route := range api.Routes(handlers) {
+		http.HandleFunc(route.Path, api.Measure(route, cors.Handler(route, handlers.Authorize(route, route.Handler))))
+	}
+	http.HandleFunc("/", api.NotFound)
+
+	server := &http.Server{
+		Addr:              cfg.Server.Addr,
+		Handler:           api.AccessLog(http.DefaultServeMux),
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout),
+		ReadTimeout:       time.Duration(cfg.Server.ReadTimeout),
+		WriteTimeout:      time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.Server.IdleTimeout),
+	}
+
+	slog.Info("Starting server", "addr", cfg.Server.Addr)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		slog.Error("Error starting server", "err", err)
+	case <-ctx.Done():
+		slog.Info("Shutting down")
+	}
+	stop()
+
+	// Drain in-flight requests, then cancel the running job and wait for its current step to
+	// return, so the database is closed only once nothing is writing to it
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout))
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("Error draining requests", "err", err)
+	}
+	if err := scheduler.Shutdown(shutdownCtx); err != nil {
+		// The step was cancelled and stops at its next statement, so wait for it rather than
+		// close the database under it
+		slog.Warn("Waiting for the pipeline step in progress", "err", err)
+		scheduler.Wait()
 	}
 
-	fmt.Println("App ended")
+	slog.Info("App ended")
 }
@@ -0,0 +1,218 @@
+// Command sunspec-sim serves a SunSpec three phase inverter over Modbus TCP so the telemetry
+// poller can be exercised without plant hardware. Each unit ID from 1 to -inverters is a separate
+// inverter whose AC power follows a daylight curve and whose energy counter accumulates from it.
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	baseAddress = 40000
+
+	exceptionIllegalFunction = 0x01
+	exceptionIllegalAddress  = 0x02
+	exceptionGatewayTarget   = 0x0B
+
+	operatingSleeping = 2
+	operatingMPPT     = 4
+)
+
+type inverter struct {
+	mu        sync.Mutex
+	unitID    int
+	ratedW    float64
+	energyWh  float64
+	updatedAt time.Time
+}
+
+type simulator struct {
+	inverters map[byte]*inverter
+	speed     float64
+	constant  bool
+	started   time.Time
+}
+
+func main() {
+	address := flag.String("addr", "127.0.0.1:5020", "address to listen on")
+	count := flag.Int("inverters", 1, "number of inverters, served as unit IDs 1..n")
+	rated := flag.Float64("rated", 100000, "inverter rated AC power in W")
+	startEnergy := flag.Float64("energy", 1000000, "starting lifetime energy in Wh")
+	speed := flag.Float64("speed", 1, "simulated seconds per real second")
+	constant := flag.Bool("constant", false, "produce rated power around the clock instead of a daylight curve")
+	flag.Parse()
+
+	if *count < 1 || *count > 247 {
+		log.Fatalf("inverters must be between 1 and 247")
+	}
+
+	now := time.Now()
+	sim := &simulator{inverters: make(map[byte]*inverter), speed: *speed, constant: *constant, started: now}
+	for i := 1; i <= *count; i++ {
+		sim.inverters[byte(i)] = &inverter{unitID: i, ratedW: *rated, energyWh: *startEnergy, updatedAt: now}
+	}
+
+	listener, err := net.Listen("tcp", *address)
+	if err != nil {
+		log.Fatalf("Error listening on %s: %v", *address, err)
+	}
+	log.Printf("SunSpec simulator serving %d inverters on %s", *count, listener.Addr())
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Printf("Error accepting connection: %v", err)
+			continue
+		}
+		go sim.serve(conn)
+	}
+}
+
+func (s *simulator) serve(conn net.Conn) {
+	defer conn.Close()
+
+	header := make([]byte, 7)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			if err != io.EOF {
+				log.Printf("Error reading from %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		length := binary.BigEndian.Uint16(header[4:])
+		if length < 2 || length > 256 {
+			log.Printf("Invalid request length %d from %s", length, conn.RemoteAddr())
+			return
+		}
+		pdu := make([]byte, length-1)
+		if _, err := io.ReadFull(conn, pdu); err != nil {
+			log.Printf("Error reading from %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+
+		response := s.handle(header[6], pdu)
+		reply := make([]byte, 7+len(response))
+		copy(reply, header[:4])
+		binary.BigEndian.PutUint16(reply[4:], uint16(len(response)+1))
+		reply[6] = header[6]
+		copy(reply[7:], response)
+		if _, err := conn.Write(reply); err != nil {
+			log.Printf("Error writing to %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+func (s *simulator) handle(unitID byte, pdu []byte) []byte {
+	function := pdu[0]
+	inv, ok := s.inverters[unitID]
+	if !ok {
+		return []byte{function | 0x80, exceptionGatewayTarget}
+	}
+	if function != 0x03 || len(pdu) != 5 {
+		return []byte{function | 0x80, exceptionIllegalFunction}
+	}
+
+	address := int(binary.BigEndian.Uint16(pdu[1:]))
+	count := int(binary.BigEndian.Uint16(pdu[3:]))
+	registers := s.registers(inv)
+	if count < 1 || count > 125 || address < baseAddress || address+count > baseAddress+len(registers) {
+		return []byte{function | 0x80, exceptionIllegalAddress}
+	}
+
+	response := make([]byte, 2+count*2)
+	response[0] = function
+	response[1] = byte(count * 2)
+	for i := 0; i < count; i++ {
+		binary.BigEndian.PutUint16(response[2+i*2:], registers[address-baseAddress+i])
+	}
+	return response
+}
+
+// power is the AC output at a simulated time: rated power or a sine between 06:00 and 18:00
+func (s *simulator) power(inv *inverter, at time.Time) float64 {
+	if s.constant {
+		return inv.ratedW
+	}
+	hour := float64(at.Hour()) + float64(at.Minute())/60 + float64(at.Second())/3600
+	if hour < 6 || hour > 18 {
+		return 0
+	}
+	return inv.ratedW * math.Sin(math.Pi*(hour-6)/12)
+}
+
+// simulatedTime runs the wall clock forward at the configured speed from start-up
+func (s *simulator) simulatedTime(at time.Time) time.Time {
+	return s.started.Add(time.Duration(float64(at.Sub(s.started)) * s.speed))
+}
+
+// registers builds the register map from 40000: the SunSpec marker, the common model,
+// the three phase inverter model and the end marker
+func (s *simulator) registers(inv *inverter) []uint16 {
+	inv.mu.Lock()
+	now := time.Now()
+	from, to := s.simulatedTime(inv.updatedAt), s.simulatedTime(now)
+	// Trapezoidal integration keeps the counter close to the curve between polls
+	inv.energyWh += (s.power(inv, from) + s.power(inv, to)) / 2 * to.Sub(from).Hours()
+	inv.updatedAt = now
+	energy := uint32(math.Round(inv.energyWh))
+	powerW := s.power(inv, to)
+	inv.mu.Unlock()
+
+	registers := []uint16{0x5375, 0x6E53}
+
+	common := make([]uint16, 66)
+	putString(common[0:16], "Simulated Solar")
+	putString(common[16:32], "SIM-103")
+	putString(common[40:48], "1.0")
+	putString(common[48:64], fmt.Sprintf("SIM%05d", inv.unitID))
+	common[64] = uint16(inv.unitID)
+	registers = append(registers, 1, uint16(len(common)))
+	registers = append(registers, common...)
+
+	// Scale the power registers so the rated output fits a signed 16-bit value
+	scale := 0
+	for inv.ratedW/math.Pow10(scale) > math.MaxInt16 {
+		scale++
+	}
+
+	model := make([]uint16, 50)
+	for i := range model {
+		model[i] = 0x8000
+	}
+	model[12] = uint16(int16(math.Round(powerW / math.Pow10(scale))))
+	model[13] = uint16(int16(scale))
+	model[22] = uint16(energy >> 16)
+	model[23] = uint16(energy)
+	model[24] = 0
+	// DC power is taken as AC power over a 97% inverter efficiency
+	model[29] = uint16(int16(math.Round(powerW / 0.97 / math.Pow10(scale))))
+	model[30] = uint16(int16(scale))
+	model[36] = operatingMPPT
+	if powerW == 0 {
+		model[36] = operatingSleeping
+	}
+	registers = append(registers, 103, uint16(len(model)))
+	registers = append(registers, model...)
+
+	return append(registers, 0xFFFF, 0)
+}
+
+func putString(registers []uint16, value string) {
+	for i := 0; i < len(registers) && i*2 < len(value); i++ {
+		high := uint16(value[i*2]) << 8
+		low := uint16(0)
+		if i*2+1 < len(value) {
+			low = uint16(value[i*2+1])
+		}
+		registers[i] = high | low
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"backend/pkg/telemetry"
+	"encoding/binary"
+	"math"
+	"net"
+	"testing"
+	"time"
+)
+
+func testSimulator(constant bool) *simulator {
+	now := time.Now()
+	return &simulator{
+		inverters: map[byte]*inverter{
+			1: {unitID: 1, ratedW: 100000, energyWh: 1000000, updatedAt: now},
+			2: {unitID: 2, ratedW: 50000000, energyWh: 5000, updatedAt: now},
+		},
+		speed:    1,
+		constant: constant,
+		started:  now,
+	}
+}
+
+func readRequest(address, count uint16) []byte {
+	pdu := []byte{0x03, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(pdu[1:], address)
+	binary.BigEndian.PutUint16(pdu[3:], count)
+	return pdu
+}
+
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		name      string
+		unitID    byte
+		pdu       []byte
+		exception byte
+		registers int
+	}{
+		{"marker", 1, readRequest(40000, 2), 0, 2},
+		{"whole map", 1, readRequest(40000, 124), 0, 124},
+		{"unknown unit", 9, readRequest(40000, 2), exceptionGatewayTarget, 0},
+		{"write", 1, []byte{0x06, 0x9C, 0x40, 0, 1}, exceptionIllegalFunction, 0},
+		{"short request", 1, []byte{0x03, 0x9C, 0x40}, exceptionIllegalFunction, 0},
+		{"below the map", 1, readRequest(39999, 2), exceptionIllegalAddress, 0},
+		{"past the map", 1, readRequest(40000, 125), exceptionIllegalAddress, 0},
+		{"no registers", 1, readRequest(40000, 0), exceptionIllegalAddress, 0},
+		{"too many registers", 1, readRequest(40000, 126), exceptionIllegalAddress, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := testSimulator(true).handle(tt.unitID, tt.pdu)
+			if tt.exception != 0 {
+				if len(response) != 2 || response[0] != tt.pdu[0]|0x80 || response[1] != tt.exception {
+					t.Errorf("response = %v, want exception %d", response, tt.exception)
+				}
+				return
+			}
+			if response[0] != 0x03 || int(response[1]) != tt.registers*2 || len(response) != 2+tt.registers*2 {
+				t.Errorf("response = %v, want %d registers", response[:2], tt.registers)
+			}
+		})
+	}
+}
+
+func TestPower(t *testing.T) {
+	s := testSimulator(false)
+	inv := s.inverters[1]
+	tests := []struct {
+		hour, minute int
+		want         float64
+	}{
+		{0, 0, 0},
+		{5, 59, 0},
+		{6, 0, 0},
+		{9, 0, 100000 * math.Sin(math.Pi/4)},
+		{12, 0, 100000},
+		{18, 0, 0},
+		{18, 1, 0},
+	}
+	for _, tt := range tests {
+		at := time.Date(2024, 6, 1, tt.hour, tt.minute, 0, 0, time.UTC)
+		if got := s.power(inv, at); math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("power at %s = %.3f, want %.3f", at.Format("15:04"), got, tt.want)
+		}
+	}
+
+	s.constant = true
+	if got := s.power(inv, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)); got != 100000 {
+		t.Errorf("constant power at midnight = %.0f, want 100000", got)
+	}
+}
+
+func TestPutString(t *testing.T) {
+	tests := []struct {
+		value string
+		size  int
+		want  []uint16
+	}{
+		{"SIM", 3, []uint16{0x5349, 0x4D00, 0}},
+		{"AB", 1, []uint16{0x4142}},
+		{"ABCDE", 2, []uint16{0x4142, 0x4344}},
+		{"", 2, []uint16{0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got := make([]uint16, tt.size)
+			putString(got, tt.value)
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("putString(%q) = %#04x, want %#04x", tt.value, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// TestPoll serves the simulator over TCP and reads it with the telemetry client, as the poller does
+func TestPoll(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	sim := testSimulator(true)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go sim.serve(conn)
+		}
+	}()
+
+	tests := []struct {
+		unitID byte
+		serial string
+		powerW float64
+	}{
+		{1, "SIM00001", 100000},
+		// 50 MW does not fit a signed 16-bit register, so it is sent with a scale factor
+		{2, "SIM00002", 50000000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.serial, func(t *testing.T) {
+			client, err := telemetry.Dial(listener.Addr().String(), tt.unitID, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			device, err := telemetry.Discover(client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if device.Manufacturer != "Simulated Solar" || device.Model != "SIM-103" || device.SerialNumber != tt.serial {
+				t.Errorf("device = %q %q %q, want Simulated Solar SIM-103 %s", device.Manufacturer, device.Model, device.SerialNumber, tt.serial)
+			}
+
+			reading, err := telemetry.ReadInverter(client, device)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reading.PowerW == nil || *reading.PowerW != tt.powerW {
+				t.Errorf("power = %v, want %.0f", reading.PowerW, tt.powerW)
+			}
+			if reading.DCPowerW == nil || *reading.DCPowerW < tt.powerW {
+				t.Errorf("dc power = %v, want more than %.0f", reading.DCPowerW, tt.powerW)
+			}
+			if reading.LifetimeEnergyWh == nil || reading.OperatingState == nil || *reading.OperatingState != operatingMPPT {
+				t.Errorf("energy %v and state %v, want both, with state %d", reading.LifetimeEnergyWh, reading.OperatingState, operatingMPPT)
+			}
+		})
+	}
+}
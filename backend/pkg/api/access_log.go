@@ -0,0 +1,112 @@
+package api
+
+import (
+	"backend/pkg/logging"
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// requestIDHeader carries the request ID in and out, so a proxy's ID is kept
+const requestIDHeader = "X-Request-ID"
+
+// accessEntry is filled in by the handlers below AccessLog for its log line
+type accessEntry struct {
+	user  string
+	route string
+}
+
+type accessKey struct{}
+
+// probePaths are polled by load balancers, so their successful requests are only logged at debug
+var probePaths = map[string]bool{"/healthz": true, "/readyz": true}
+
+// statusRecorder remembers the status and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// AccessLog gives every request an ID, returned in X-Request-ID and attached to everything logged
+// through the request's context, and logs each request with its status and latency
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+
+		id := r.Header.Get(requestIDHeader)
+		if id == "" || len(id) > 64 {
+			id = logging.NewID()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		logger := logging.FromContext(r.Context()).With("request_id", id)
+		entry := &accessEntry{}
+		ctx := logging.WithLogger(r.Context(), logger)
+		ctx = context.WithValue(ctx, accessKey{}, entry)
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		elapsed := time.Since(started)
+		observeRequest(entry.route, r.Method, status, elapsed)
+
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case probePaths[r.URL.Path]:
+			level = slog.LevelDebug
+		}
+		logger.Log(r.Context(), level, "Request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"route", entry.route,
+			"status", status,
+			"bytes", recorder.bytes,
+			"duration_ms", float64(elapsed.Microseconds())/1000,
+			"user", entry.user,
+			"remote", r.RemoteAddr,
+		)
+	})
+}
+
+// setAccessUser records who a request was made by on its access log line and its logger
+func setAccessUser(r *http.Request, user string) *http.Request {
+	if entry, ok := r.Context().Value(accessKey{}).(*accessEntry); ok {
+		entry.user = user
+	}
+	logger := logging.FromContext(r.Context()).With("user", user)
+	return r.WithContext(logging.WithLogger(r.Context(), logger))
+}
+
+// Measure names the route serving a request for its access log line and request metrics
+func Measure(route Route, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if entry, ok := r.Context().Value(accessKey{}).(*accessEntry); ok {
+			entry.route = route.Path
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"backend/pkg/logging"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		requestID string
+		status    int
+		user      string
+		level     string
+		keepID    bool
+	}{
+		{"new request ID", "/api/performance", "", http.StatusOK, "", "INFO", false},
+		{"proxy's request ID", "/api/performance", "proxy-123", http.StatusCreated, "", "INFO", true},
+		{"overlong request ID", "/api/performance", strings.Repeat("x", 65), http.StatusOK, "", "INFO", false},
+		{"server error", "/api/performance", "", http.StatusInternalServerError, "", "ERROR", false},
+		{"signed-in user", "/api/performance", "", http.StatusOK, "key:viewer", "INFO", false},
+		{"probe", "/readyz", "", http.StatusOK, "", "DEBUG", false},
+		{"failing probe", "/readyz", "", http.StatusServiceUnavailable, "", "ERROR", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+			var handlerID string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.user != "" {
+					r = setAccessUser(r, tt.user)
+				}
+				handlerID = w.Header().Get(requestIDHeader)
+				w.WriteHeader(tt.status)
+				w.Write([]byte("body"))
+			})
+
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			r = r.WithContext(logging.WithLogger(r.Context(), logger))
+			if tt.requestID != "" {
+				r.Header.Set(requestIDHeader, tt.requestID)
+			}
+			w := httptest.NewRecorder()
+			AccessLog(next).ServeHTTP(w, r)
+
+			id := w.Header().Get(requestIDHeader)
+			if id == "" || id != handlerID || (id == tt.requestID) != tt.keepID {
+				t.Errorf("request ID = %q, sent %q, want kept %v", id, tt.requestID, tt.keepID)
+			}
+
+			var line map[string]interface{}
+			if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+				t.Fatalf("access log %q: %v", buf.String(), err)
+			}
+			want := map[string]interface{}{
+				"level":      tt.level,
+				"request_id": id,
+				"method":     http.MethodGet,
+				"path":       tt.path,
+				"status":     float64(tt.status),
+				"bytes":      float64(4),
+				"user":       tt.user,
+			}
+			for key, value := range want {
+				if line[key] != value {
+					t.Errorf("access log %s = %v, want %v", key, line[key], value)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"backend/pkg/pipeline"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// AdminJobs lists the registered pipeline jobs on GET /api/admin/jobs and
+// starts a manual run on POST /api/admin/jobs/{name}/run
+func (h *Handlers) AdminJobs(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/jobs"), "/")
+
+	if path == "" {
+		if r.Method != http.MethodGet {
+			writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(h.scheduler.Jobs()); err != nil {
+			writeError(w, "Error encoding JSON", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	name, action, found := strings.Cut(path, "/")
+	if !found || action != "run" {
+		NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// The run outlives the request, so it must not inherit the request context
+	runID, err := h.scheduler.Trigger(context.Background(), name)
+	switch {
+	case errors.Is(err, pipeline.ErrUnknownJob):
+		writeError(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, pipeline.ErrJobRunning):
+		writeError(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, pipeline.ErrShuttingDown):
+		writeError(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		writeError(w, "Error starting job", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]int64{"runId": runID})
+}
+
+// AdminJobRuns returns the pipeline run history, filtered by ?job= and limited by ?limit=
+func (h *Handlers) AdminJobRuns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := 20
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			writeError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	runs, err := h.jobs.Runs(r.URL.Query().Get("job"), limit)
+	if err != nil {
+		writeError(w, "Error fetching job runs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(runs); err != nil {
+		writeError(w, "Error encoding JSON", http.StatusInternalServerError)
+	}
+}
+
+// AdminPipelinePlan shows which recompute steps are stale without running anything
+func (h *Handlers) AdminPipelinePlan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	plan, err := h.recompute.Plan(r.URL.Query().Get("sources") != "false")
+	if err != nil {
+		writeError(w, "Error planning pipeline", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(plan); err != nil {
+		writeError(w, "Error encoding JSON", http.StatusInternalServerError)
+	}
+}
+
+// AdminModelRuns returns the model script run history, filtered by ?model= and limited by ?limit=
+func (h *Handlers) AdminModelRuns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := 20
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			writeError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	runs, err := h.models.Runs(r.URL.Query().Get("model"), limit)
+	if err != nil {
+		writeError(w, "Error fetching model runs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(runs); err != nil {
+		writeError(w, "Error encoding JSON", http.StatusInternalServerError)
+	}
+}
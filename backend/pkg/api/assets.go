@@ -0,0 +1,202 @@
+package api
+
+import (
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Assets serves the site → inverter → string hierarchy:
+//
+//	GET  /api/assets?site=
+//	POST /api/assets
+//	GET  /api/assets/{id}
+//	PUT  /api/assets/{id}
+//	GET  /api/assets/{id}/performance?period=monthly|daily&from=&to=
+func (h *Handlers) Assets(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/assets"), "/")
+	if path == "" {
+		switch r.Method {
+		case http.MethodGet:
+			h.listAssets(w, r)
+		case http.MethodPost:
+			h.saveAsset(w, r, 0)
+		default:
+			writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		NotFound(w, r)
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		asset, err := h.assets.Get(id)
+		if errors.Is(err, repository.ErrAssetNotFound) {
+			writeError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			writeError(w, "Error fetching asset", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, asset)
+	case len(parts) == 1 && r.Method == http.MethodPut:
+		h.saveAsset(w, r, id)
+	case len(parts) == 2 && parts[1] == "performance" && r.Method == http.MethodGet:
+		h.assetPerformance(w, r, id)
+	case len(parts) <= 2:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		NotFound(w, r)
+	}
+}
+
+func (h *Handlers) listAssets(w http.ResponseWriter, r *http.Request) {
+	site := ""
+	if value := r.URL.Query().Get("site"); value != "" {
+		resolved, err := h.sites.Resolve(value)
+		if err != nil {
+			queryError(w, r, err)
+			return
+		}
+		site = resolved
+	}
+
+	assets, err := h.assets.Tree(site)
+	if err != nil {
+		writeError(w, "Error fetching assets", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, assets)
+}
+
+// saveAsset creates an asset when id is 0 and updates it otherwise
+func (h *Handlers) saveAsset(w http.ResponseWriter, r *http.Request, id int) {
+	var input structure.AssetInput
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&input); err != nil {
+		writeError(w, fmt.Sprintf("Invalid asset: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	status := http.StatusOK
+	var err error
+	if id == 0 {
+		status = http.StatusCreated
+		id, err = h.assets.Create(input)
+	} else {
+		err = h.assets.Update(id, input)
+	}
+	switch {
+	case errors.Is(err, repository.ErrAssetNotFound):
+		writeError(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, repository.ErrInvalidAsset):
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		writeError(w, "Error saving asset", http.StatusInternalServerError)
+		return
+	}
+
+	asset, err := h.assets.Get(id)
+	if err != nil {
+		writeError(w, "Error fetching asset", http.StatusInternalServerError)
+		return
+	}
+
+	// Capacity drives expected output, so performance is recomputed
+	response := map[string]interface{}{"asset": asset}
+	h.triggerRefresh(response)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// assetPerformance defaults to the last 12 months, or the last 30 days for daily performance
+func (h *Handlers) assetPerformance(w http.ResponseWriter, r *http.Request, id int) {
+	if _, err := h.assets.Get(id); err != nil {
+		if errors.Is(err, repository.ErrAssetNotFound) {
+			writeError(w, err.Error(), http.StatusNotFound)
+		} else {
+			writeError(w, "Error fetching asset", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	query := r.URL.Query()
+	monthly := true
+	layout := "2006-01"
+	to := time.Now().UTC()
+	from := to.AddDate(0, -11, 0)
+	switch query.Get("period") {
+	case "", "monthly":
+	case "daily":
+		monthly, layout = false, "2006-01-02"
+		from = to.AddDate(0, 0, -30)
+	default:
+		writeError(w, "period must be monthly or daily", http.StatusBadRequest)
+		return
+	}
+
+	for name, target := range map[string]*time.Time{"from": &from, "to": &to} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse(layout, value)
+			if err != nil {
+				writeError(w, fmt.Sprintf("Invalid %s, expected %s", name, layout), http.StatusBadRequest)
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	performance, err := h.assets.Performance(id, monthly, from.Format(layout), to.Format(layout))
+	if err != nil {
+		writeError(w, "Error fetching asset performance", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, performance)
+}
+
+// siteAssetPerformance compares every asset at a site for one month, lowest performance ratio first
+func (h *Handlers) siteAssetPerformance(w http.ResponseWriter, r *http.Request, site string) {
+	year, yearErr := strconv.Atoi(r.URL.Query().Get("year"))
+	month, monthErr := strconv.Atoi(r.URL.Query().Get("month"))
+	if yearErr != nil || monthErr != nil || month < 1 || month > 12 {
+		writeError(w, "year and month are required", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.assets.SitePerformance(site, year, month)
+	if err != nil {
+		writeError(w, "Error fetching asset performance", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, rows)
+}
+
+// AssetGeneration imports per-asset monthly totals (site, asset, year, month, energy_kwh) on POST
+func (h *Handlers) AssetGeneration(w http.ResponseWriter, r *http.Request) {
+	h.csvUpload(w, r, h.loader.ImportAssetMonthlyCSV)
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		writeError(w, "Error encoding JSON", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,253 @@
+package api
+
+import (
+	"backend/pkg/auth"
+	"backend/pkg/logging"
+	structure "backend/pkg/struct"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Authorize lets a request through to next when its credentials, or the anonymous role when it
+// has none, are enough for the role the route requires of its method. Missing or invalid
+// credentials get 401 and an insufficient role 403.
+func (h *Handlers) Authorize(route Route, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		required := route.Methods[r.Method]
+
+		principal, err := h.auth.Authenticate(r)
+		if errors.Is(err, auth.ErrUnauthenticated) {
+			if anonymous, ok := h.auth.Anonymous(); ok {
+				principal, err = anonymous, nil
+			}
+		}
+		if err != nil && required != auth.Public {
+			if !errors.Is(err, auth.ErrUnauthenticated) && !errors.Is(err, auth.ErrInvalidCredentials) {
+				logging.FromContext(r.Context()).Error("Error authenticating request", "err", err)
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="solar"`)
+			writeError(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		if !auth.Role(principal.Role).Allows(required) {
+			if principal.Method == auth.MethodAnonymous {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="solar"`)
+				writeError(w, "Authentication required", http.StatusUnauthorized)
+				return
+			}
+			writeError(w, "Forbidden: requires the "+string(required)+" role", http.StatusForbidden)
+			return
+		}
+
+		if err == nil {
+			r = setAccessUser(r, principal.Name)
+			r = r.WithContext(auth.WithPrincipal(r.Context(), principal))
+		}
+		next(w, r)
+	}
+}
+
+// author is who a change is recorded against: the authenticated user or key, or the author given
+// in the request when it was made anonymously
+func author(r *http.Request, given string) string {
+	if principal, ok := auth.FromContext(r.Context()); ok && principal.Method != auth.MethodAnonymous {
+		return principal.Name
+	}
+	return given
+}
+
+// Login exchanges a username and password for a session token:
+//
+//	POST /api/auth/login    {"username": "", "password": ""}
+func (h *Handlers) Login(w http.ResponseWriter, r *http.Request) {
+	var login structure.Login
+	if err := json.NewDecoder(r.Body).Decode(&login); err != nil {
+		writeError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	session, err := h.auth.Login(login.Username, login.Password)
+	if err != nil {
+		authError(w, r, err)
+		return
+	}
+	writeJSON(w, session)
+}
+
+// Logout ends the session of the token the request was made with
+//
+//	POST /api/auth/logout
+func (h *Handlers) Logout(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+	if principal.Method != auth.MethodSession {
+		writeError(w, "Only a session can be logged out", http.StatusBadRequest)
+		return
+	}
+	if err := h.auth.Logout(auth.Credentials(r)); err != nil {
+		authError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Me returns who the request is made by
+//
+//	GET /api/auth/me
+func Me(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+	writeJSON(w, principal)
+}
+
+// Users manages local accounts:
+//
+//	GET  /api/auth/users
+//	POST /api/auth/users         {"username": "", "password": "", "role": "viewer"}
+//	GET  /api/auth/users/{id}
+//	PUT  /api/auth/users/{id}    any of {"password": "", "role": "", "disabled": true}
+func (h *Handlers) Users(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/auth/users"), "/")
+	if path == "" {
+		switch r.Method {
+		case http.MethodGet:
+			users, err := h.auth.GetUsers()
+			if err != nil {
+				authError(w, r, err)
+				return
+			}
+			writeJSON(w, users)
+		case http.MethodPost:
+			var update structure.UserUpdate
+			if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+				writeError(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			user, err := h.auth.CreateUser(update)
+			if err != nil {
+				authError(w, r, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(user)
+		default:
+			writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	id, err := strconv.Atoi(path)
+	if err != nil {
+		NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		user, err := h.auth.GetUser(id)
+		if err != nil {
+			authError(w, r, err)
+			return
+		}
+		writeJSON(w, user)
+	case http.MethodPut:
+		var update structure.UserUpdate
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			writeError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		user, err := h.auth.UpdateUser(id, update)
+		if err != nil {
+			authError(w, r, err)
+			return
+		}
+		writeJSON(w, user)
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Keys manages API keys. The key is only in the response that creates it.
+//
+//	GET    /api/auth/keys
+//	POST   /api/auth/keys         {"name": "", "role": "viewer"}
+//	GET    /api/auth/keys/{id}
+//	DELETE /api/auth/keys/{id}    revokes the key
+func (h *Handlers) Keys(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/auth/keys"), "/")
+	if path == "" {
+		switch r.Method {
+		case http.MethodGet:
+			keys, err := h.auth.GetKeys()
+			if err != nil {
+				authError(w, r, err)
+				return
+			}
+			writeJSON(w, keys)
+		case http.MethodPost:
+			var request struct {
+				Name string `json:"name"`
+				Role string `json:"role"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				writeError(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			key, err := h.auth.CreateKey(request.Name, auth.Role(request.Role), author(r, "anonymous"))
+			if err != nil {
+				authError(w, r, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(key)
+		default:
+			writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	id, err := strconv.Atoi(path)
+	if err != nil {
+		NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		key, err := h.auth.GetKey(id)
+		if err != nil {
+			authError(w, r, err)
+			return
+		}
+		writeJSON(w, key)
+	case http.MethodDelete:
+		key, err := h.auth.RevokeKey(id)
+		if err != nil {
+			authError(w, r, err)
+			return
+		}
+		writeJSON(w, key)
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func authError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		writeError(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, auth.ErrUserNotFound), errors.Is(err, auth.ErrKeyNotFound):
+		writeError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, auth.ErrUserExists):
+		writeError(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, auth.ErrInvalidRole), errors.Is(err, auth.ErrInvalidUser), errors.Is(err, auth.ErrWeakPassword):
+		writeError(w, err.Error(), http.StatusBadRequest)
+	default:
+		logging.FromContext(r.Context()).Error("Error processing auth request", "err", err)
+		writeError(w, "Error processing request", http.StatusInternalServerError)
+	}
+}
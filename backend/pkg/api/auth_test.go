@@ -0,0 +1,95 @@
+package api
+
+import (
+	"backend/pkg/auth"
+	"backend/pkg/config"
+	"backend/pkg/db"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// openTestDatabase opens a fresh database in a temporary directory
+func openTestDatabase(t *testing.T) *db.DB {
+	t.Helper()
+
+	database, err := db.Open(filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func TestAuthorize(t *testing.T) {
+	database := openTestDatabase(t)
+	store := auth.NewStore(database, config.AuthConfig{})
+	keys := make(map[auth.Role]string)
+	for _, role := range []auth.Role{auth.Viewer, auth.Operator, auth.Admin} {
+		key, err := store.CreateKey(string(role), role, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[role] = key.Key
+	}
+
+	route := Route{Path: "/api/things", Methods: Methods{
+		http.MethodGet:    auth.Viewer,
+		http.MethodPost:   auth.Operator,
+		http.MethodDelete: auth.Admin,
+	}}
+	public := Route{Path: "/api/auth/login", Methods: Methods{http.MethodPost: auth.Public}}
+
+	tests := []struct {
+		name      string
+		route     Route
+		method    string
+		key       string
+		anonymous string
+		status    int
+		principal string
+	}{
+		{"viewer reads", route, http.MethodGet, keys[auth.Viewer], "", http.StatusOK, "key:viewer"},
+		{"viewer cannot write", route, http.MethodPost, keys[auth.Viewer], "", http.StatusForbidden, ""},
+		{"operator writes", route, http.MethodPost, keys[auth.Operator], "", http.StatusOK, "key:operator"},
+		{"operator cannot delete", route, http.MethodDelete, keys[auth.Operator], "", http.StatusForbidden, ""},
+		{"admin deletes", route, http.MethodDelete, keys[auth.Admin], "", http.StatusOK, "key:admin"},
+		{"no credentials", route, http.MethodGet, "", "", http.StatusUnauthorized, ""},
+		{"invalid key", route, http.MethodGet, "solar_00000000_wrong", "viewer", http.StatusUnauthorized, ""},
+		{"anonymous viewer reads", route, http.MethodGet, "", "viewer", http.StatusOK, ""},
+		{"anonymous viewer cannot write", route, http.MethodPost, "", "viewer", http.StatusUnauthorized, ""},
+		{"public without credentials", public, http.MethodPost, "", "", http.StatusOK, ""},
+		{"public with invalid key", public, http.MethodPost, "solar_00000000_wrong", "", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handlers{auth: auth.NewStore(database, config.AuthConfig{AnonymousRole: tt.anonymous})}
+
+			var principal string
+			next := func(w http.ResponseWriter, r *http.Request) {
+				if p, ok := auth.FromContext(r.Context()); ok && p.Method != auth.MethodAnonymous {
+					principal = p.Name
+				}
+				w.WriteHeader(http.StatusOK)
+			}
+
+			r := httptest.NewRequest(tt.method, tt.route.Path, nil)
+			if tt.key != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+			w := httptest.NewRecorder()
+			h.Authorize(tt.route, next)(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if principal != tt.principal {
+				t.Errorf("principal = %q, want %q", principal, tt.principal)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without WWW-Authenticate")
+			}
+		})
+	}
+}
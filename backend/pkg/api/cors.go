@@ -0,0 +1,102 @@
+package api
+
+import (
+	"backend/pkg/config"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS applies the configured cross-origin policy to a route. It answers OPTIONS for the route,
+// advertising only the methods the route's handler supports, and rejects other methods with 405.
+type CORS struct {
+	anyOrigin   bool
+	origins     map[string]bool
+	methods     map[string]bool
+	headers     string
+	credentials bool
+	maxAge      string
+}
+
+func NewCORS(cfg config.CORSConfig) *CORS {
+	c := &CORS{
+		origins:     make(map[string]bool),
+		methods:     make(map[string]bool),
+		headers:     strings.Join(cfg.Headers, ", "),
+		credentials: cfg.Credentials,
+		maxAge:      strconv.Itoa(int(time.Duration(cfg.MaxAge) / time.Second)),
+	}
+	for _, origin := range cfg.Origins {
+		if origin == "*" {
+			c.anyOrigin = true
+		}
+		c.origins[strings.TrimSuffix(origin, "/")] = true
+	}
+	for _, method := range cfg.Methods {
+		c.methods[method] = true
+	}
+	return c
+}
+
+// Handler applies the policy to route and passes supported methods on to next
+func (c *CORS) Handler(route Route, next http.HandlerFunc) http.HandlerFunc {
+	methods := route.Methods.List()
+	allow := strings.Join(append(methods, http.MethodOptions), ", ")
+
+	// Cross-origin requests may use the route's methods that the policy also allows
+	var crossOrigin []string
+	for _, method := range methods {
+		if c.methods[method] {
+			crossOrigin = append(crossOrigin, method)
+		}
+	}
+	allowCrossOrigin := strings.Join(crossOrigin, ", ")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Set CORS headers for all responses including errors
+		origin := r.Header.Get("Origin")
+		allowedOrigin := c.allowOrigin(w, origin)
+
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Allow", allow)
+			requested := r.Header.Get("Access-Control-Request-Method")
+			if allowedOrigin && requested != "" && allowCrossOrigin != "" {
+				w.Header().Set("Access-Control-Allow-Methods", allowCrossOrigin)
+				w.Header().Set("Access-Control-Allow-Headers", c.headers)
+				w.Header().Set("Access-Control-Max-Age", c.maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if _, ok := route.Methods[r.Method]; !ok {
+			w.Header().Set("Allow", allow)
+			writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// allowOrigin sets Access-Control-Allow-Origin when origin may call the API and reports whether
+// it did. Without credentials any origin gets *; with them the origin is echoed back, as browsers
+// refuse * for credentialed requests.
+func (c *CORS) allowOrigin(w http.ResponseWriter, origin string) bool {
+	if !c.anyOrigin || c.credentials {
+		w.Header().Add("Vary", "Origin")
+	}
+	if origin == "" || !(c.anyOrigin || c.origins[origin]) {
+		return false
+	}
+
+	if c.anyOrigin && !c.credentials {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.credentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+	return true
+}
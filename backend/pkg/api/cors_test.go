@@ -0,0 +1,135 @@
+package api
+
+import (
+	"backend/pkg/auth"
+	"backend/pkg/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	route := Route{Path: "/api/imports", Methods: Methods{http.MethodGet: auth.Viewer, http.MethodPost: auth.Operator}}
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+
+	tests := []struct {
+		name    string
+		cfg     config.CORSConfig
+		method  string
+		origin  string
+		request string
+		status  int
+		headers map[string]string
+	}{
+		{
+			name:    "allowed origin",
+			cfg:     config.CORSConfig{Origins: []string{"https://dash.example"}, Methods: []string{"GET", "POST", "DELETE"}, Headers: []string{"Content-Type"}, MaxAge: config.Duration(10 * time.Minute)},
+			method:  http.MethodOptions,
+			origin:  "https://dash.example",
+			request: http.MethodPost,
+			status:  http.StatusNoContent,
+			headers: map[string]string{
+				"Allow":                        "GET, POST, OPTIONS",
+				"Access-Control-Allow-Origin":  "https://dash.example",
+				"Access-Control-Allow-Methods": "GET, POST",
+				"Access-Control-Allow-Headers": "Content-Type",
+				"Access-Control-Max-Age":       "600",
+				"Vary":                         "Origin",
+			},
+		},
+		{
+			name:    "default policy allows API key requests",
+			cfg:     config.Defaults().CORS,
+			method:  http.MethodOptions,
+			origin:  "https://dash.example",
+			request: http.MethodPost,
+			status:  http.StatusNoContent,
+			headers: map[string]string{
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Headers": "Content-Type, Authorization, X-API-Key",
+			},
+		},
+		{
+			name:    "policy narrows the methods",
+			cfg:     config.CORSConfig{Origins: []string{"*"}, Methods: []string{"GET"}},
+			method:  http.MethodOptions,
+			origin:  "https://other.example",
+			request: http.MethodGet,
+			status:  http.StatusNoContent,
+			headers: map[string]string{"Access-Control-Allow-Origin": "*", "Access-Control-Allow-Methods": "GET", "Vary": ""},
+		},
+		{
+			name:    "credentials echo the origin",
+			cfg:     config.CORSConfig{Origins: []string{"*"}, Methods: []string{"GET"}, Credentials: true},
+			method:  http.MethodOptions,
+			origin:  "https://other.example",
+			request: http.MethodGet,
+			status:  http.StatusNoContent,
+			headers: map[string]string{"Access-Control-Allow-Origin": "https://other.example", "Access-Control-Allow-Credentials": "true", "Vary": "Origin"},
+		},
+		{
+			name:    "unknown origin",
+			cfg:     config.CORSConfig{Origins: []string{"https://dash.example"}, Methods: []string{"GET"}},
+			method:  http.MethodOptions,
+			origin:  "https://evil.example",
+			request: http.MethodGet,
+			status:  http.StatusNoContent,
+			headers: map[string]string{"Allow": "GET, POST, OPTIONS", "Access-Control-Allow-Origin": "", "Access-Control-Allow-Methods": ""},
+		},
+		{
+			name:    "no methods in common",
+			cfg:     config.CORSConfig{Origins: []string{"*"}, Methods: []string{"DELETE"}},
+			method:  http.MethodOptions,
+			origin:  "https://dash.example",
+			request: http.MethodGet,
+			status:  http.StatusNoContent,
+			headers: map[string]string{"Access-Control-Allow-Origin": "*", "Access-Control-Allow-Methods": ""},
+		},
+		{
+			name:    "plain OPTIONS",
+			cfg:     config.CORSConfig{Origins: []string{"*"}, Methods: []string{"GET"}},
+			method:  http.MethodOptions,
+			status:  http.StatusNoContent,
+			headers: map[string]string{"Allow": "GET, POST, OPTIONS", "Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:    "unsupported method",
+			cfg:     config.CORSConfig{Origins: []string{"*"}, Methods: []string{"GET", "DELETE"}},
+			method:  http.MethodDelete,
+			origin:  "https://dash.example",
+			status:  http.StatusMethodNotAllowed,
+			headers: map[string]string{"Allow": "GET, POST, OPTIONS", "Access-Control-Allow-Origin": "*"},
+		},
+		{
+			name:    "supported method",
+			cfg:     config.CORSConfig{Origins: []string{"https://dash.example/"}, Methods: []string{"GET"}},
+			method:  http.MethodGet,
+			origin:  "https://dash.example",
+			status:  http.StatusOK,
+			headers: map[string]string{"Access-Control-Allow-Origin": "https://dash.example", "Allow": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, route.Path, nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.request != "" {
+				r.Header.Set("Access-Control-Request-Method", tt.request)
+			}
+			w := httptest.NewRecorder()
+			NewCORS(tt.cfg).Handler(route, ok)(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			for name, want := range tt.headers {
+				if got := w.Header().Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -3,12 +3,15 @@ package api
 import (
 	"backend/pkg/calculation"
 	structure "backend/pkg/struct"
-	"encoding/json"
 	"net/http"
 )
 
-func EnvironmentalImpact(w http.ResponseWriter, r *http.Request) {
-	co2OffsetAwali, co2OffsetRefinery, co2OffsetUOB, totalCO2Offset := calculation.CO2Offset()
+func (h *Handlers) EnvironmentalImpact(w http.ResponseWriter, r *http.Request) {
+	co2OffsetAwali, co2OffsetRefinery, co2OffsetUOB, totalCO2Offset, err := h.environment.CO2Offset()
+	if err != nil {
+		queryError(w, r, err)
+		return
+	}
 	equivalentTreesAwali, equivalentTreesRefinery, equivalentTreesUOB, equivalentTreesTotal := calculation.EquivalentTrees(co2OffsetAwali, co2OffsetRefinery, co2OffsetUOB)
 
 	env := structure.EnvironmentalImpact{
@@ -22,7 +25,5 @@ func EnvironmentalImpact(w http.ResponseWriter, r *http.Request) {
 		EquivalentTreesTotal:  calculation.FormatTreeNumber(equivalentTreesTotal),
 	}
 
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(env)
+	writeJSON(w, env)
 }
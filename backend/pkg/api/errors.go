@@ -0,0 +1,62 @@
+package api
+
+import (
+	"backend/pkg/logging"
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// writeError replies with the JSON error envelope. It takes the same arguments as http.Error,
+// and the code is derived from the status, such as not_found for 404.
+func writeError(w http.ResponseWriter, message string, status int) {
+	writeErrorCode(w, message, status, strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_")))
+}
+
+// writeErrorCode replies with the JSON error envelope and a specific code
+func writeErrorCode(w http.ResponseWriter, message string, status int, code string) {
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(structure.ErrorResponse{Error: structure.ErrorDetail{
+		Status:    status,
+		Code:      code,
+		Message:   message,
+		RequestID: h.Get(requestIDHeader),
+	}})
+}
+
+// NotFound replies 404 for paths no route or handler serves
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, "Not found", http.StatusNotFound)
+}
+
+// queryError maps an error from a repository to a status: missing data is 404, an unknown
+// site 404 and a database failure 500, whose cause is logged rather than shown
+func queryError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, repository.ErrInvalidSite):
+		writeErrorCode(w, "Unknown site", http.StatusNotFound, "invalid_site")
+	case errors.Is(err, repository.ErrNotFound):
+		writeError(w, capitalize(err.Error()), http.StatusNotFound)
+	default:
+		logging.FromContext(r.Context()).Error("Error querying data", "err", err)
+		code := "internal_server_error"
+		if errors.Is(err, repository.ErrStorage) {
+			code = "storage_failure"
+		}
+		writeErrorCode(w, "Error querying data", http.StatusInternalServerError, code)
+	}
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQueryError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		code    string
+		message string
+	}{
+		{"unknown site", fmt.Errorf("performance: %w", repository.ErrInvalidSite), http.StatusNotFound, "invalid_site", "Unknown site"},
+		{"missing record", repository.ErrAssetNotFound, http.StatusNotFound, "not_found", "Asset not found"},
+		{"storage failure", &repository.StorageError{Op: "querying assets", Err: errors.New("disk I/O error")},
+			http.StatusInternalServerError, "storage_failure", "Error querying data"},
+		{"anything else", errors.New("boom"), http.StatusInternalServerError, "internal_server_error", "Error querying data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			w.Header().Set(requestIDHeader, "req-1")
+			queryError(w, httptest.NewRequest(http.MethodGet, "/api/assets/1", nil), tt.err)
+
+			if w.Code != tt.status || w.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("status = %d, Content-Type %q, want %d JSON", w.Code, w.Header().Get("Content-Type"), tt.status)
+			}
+			var response structure.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			want := structure.ErrorDetail{Status: tt.status, Code: tt.code, Message: tt.message, RequestID: "req-1"}
+			if response.Error != want {
+				t.Errorf("error = %+v, want %+v", response.Error, want)
+			}
+		})
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		status int
+		code   string
+	}{
+		{http.StatusBadRequest, "bad_request"},
+		{http.StatusMethodNotAllowed, "method_not_allowed"},
+		{http.StatusServiceUnavailable, "service_unavailable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeError(w, "Nope", tt.status)
+
+			var response structure.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tt.status || response.Error.Code != tt.code {
+				t.Errorf("writeError() = %d %s, want %d %s", w.Code, response.Error.Code, tt.status, tt.code)
+			}
+		})
+	}
+}
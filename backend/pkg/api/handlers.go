@@ -0,0 +1,87 @@
+package api
+
+import (
+	"backend/pkg/auth"
+	"backend/pkg/calculation"
+	"backend/pkg/config"
+	"backend/pkg/data"
+	"backend/pkg/pipeline"
+	"backend/pkg/quality"
+	"backend/pkg/repository"
+	"backend/pkg/telemetry"
+)
+
+// Handlers serves the API from the repositories and services it is built with, so it can be
+// tested against the in-memory repositories
+type Handlers struct {
+	sites           repository.SiteRepo
+	generation      repository.GenerationRepo
+	weather         repository.WeatherRepo
+	performance     repository.PerformanceRepo
+	models          repository.ModelRepo
+	assets          repository.AssetRepo
+	imports         repository.ImportRepo
+	quality         repository.QualityRepo
+	revisions       repository.RevisionRepo
+	emissionFactors repository.EmissionFactorRepo
+	scenarios       repository.ScenarioRepo
+	outlook         repository.OutlookRepo
+	jobs            repository.JobRepo
+	telemetry       repository.TelemetryRepo
+	status          repository.StatusRepo
+
+	environment *calculation.Environment
+	calculator  *calculation.Calculator
+	loader      *data.Loader
+	checker     *quality.Checker
+	auth        *auth.Store
+	scheduler   *pipeline.Scheduler
+	recompute   *pipeline.Graph
+	poller      *telemetry.Poller
+	freshness   config.FreshnessConfig
+}
+
+// Services are what the handlers use beyond the repositories: the imports, reviews, scenario runs,
+// accounts and jobs that change data. A handler whose service is nil must not be routed to.
+type Services struct {
+	Environment *calculation.Environment
+	Calculator  *calculation.Calculator
+	Loader      *data.Loader
+	Checker     *quality.Checker
+	Auth        *auth.Store
+	Scheduler   *pipeline.Scheduler
+	Recompute   *pipeline.Graph
+	Telemetry   *telemetry.Poller
+	// Freshness is how old data may get before Status reports it as stale
+	Freshness config.FreshnessConfig
+}
+
+// NewHandlers returns handlers that read from repos and change data through services
+func NewHandlers(repos repository.Repos, services Services) *Handlers {
+	return &Handlers{
+		sites:           repos.Sites,
+		generation:      repos.Generation,
+		weather:         repos.Weather,
+		performance:     repos.Performance,
+		models:          repos.Models,
+		assets:          repos.Assets,
+		imports:         repos.Imports,
+		quality:         repos.Quality,
+		revisions:       repos.Revisions,
+		emissionFactors: repos.EmissionFactors,
+		scenarios:       repos.Scenarios,
+		outlook:         repos.Outlook,
+		jobs:            repos.Jobs,
+		telemetry:       repos.Telemetry,
+		status:          repos.Status,
+		environment:     services.Environment,
+		calculator:      services.Calculator,
+		loader:          services.Loader,
+		checker:         services.Checker,
+		auth:            services.Auth,
+		scheduler:       services.Scheduler,
+		recompute:       services.Recompute,
+		poller:          services.Telemetry,
+		freshness:       services.Freshness,
+	}
+}
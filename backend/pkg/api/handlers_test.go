@@ -0,0 +1,196 @@
+package api
+
+import (
+	"backend/pkg/metrics"
+	"backend/pkg/pipeline"
+	"backend/pkg/repository/memory"
+	structure "backend/pkg/struct"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testData() *memory.Data {
+	capacity := 1000.0
+	return &memory.Data{
+		Sites: []structure.Location{
+			{ID: 1, Name: "Awali", InstalledCapacity: 1000, NumberOfPanels: 4000},
+			{ID: 2, Name: "Refinery", InstalledCapacity: 2000, NumberOfPanels: 8000},
+			{ID: 3, Name: "Total System", InstalledCapacity: 3000, NumberOfPanels: 12000},
+		},
+		Generation: map[string][]structure.ForecastResult{
+			"Awali": {
+				{Year: 2019, Month: 11, Actual: 90000, Predicted: 95000},
+				{Year: 2019, Month: 12, Actual: 80000, Predicted: 85000},
+				{Year: 2018, Month: 12, Actual: 1500000},
+			},
+		},
+		MonthlyGeneration: []structure.Generation{{Year: 2019, Month: 12, LocationID: 1, ActualKWH: 80000}},
+		MonthlyPerformance: []structure.Performance{
+			{Year: 2019, Month: 11, LocationID: 1, PerformanceRatio: 0.81, CapacityFactor: 0.12},
+			{Year: 2019, Month: 12, LocationID: 1, PerformanceRatio: 0.78, CapacityFactor: 0.11},
+		},
+		Assets: []structure.Asset{{ID: 1, Site: "Awali", Kind: "site", Code: "Awali", Name: "Awali", CapacityKW: &capacity}},
+		Quarantine: []structure.QuarantineEntry{
+			{ID: 1, Dataset: "weather_daily", Period: "2019-06-01", Rule: "range", Status: "quarantined"},
+			{ID: 2, Dataset: "monthly_generation", Site: "Awali", Period: "2019-07", Rule: "spike", Status: "quarantined"},
+			{ID: 3, Dataset: "monthly_generation", Site: "Awali", Period: "2019-08", Rule: "spike", Status: "accepted"},
+		},
+		EmissionFactors: []structure.EmissionFactor{{Name: "grid", Value: 0.4, Unit: "kgCO2/kWh"}},
+		JobRuns: []structure.JobRun{
+			{ID: 1, JobName: "refresh", Trigger: "schedule", Status: "succeeded"},
+			{ID: 2, JobName: "weather", Trigger: "manual", Status: "failed"},
+		},
+		WeatherDays: []string{"2019-12-31"},
+	}
+}
+
+// newTestServer serves every route from handlers built on data, without authorization. Its
+// scheduler is shut down, so the refresh a change triggers is skipped rather than run.
+func newTestServer(t *testing.T, data *memory.Data) http.Handler {
+	scheduler := pipeline.NewScheduler(nil)
+	if err := scheduler.Register(pipeline.RefreshJob, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := scheduler.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHandlers(memory.New(data), Services{Scheduler: scheduler})
+	mux := http.NewServeMux()
+	for _, route := range Routes(h) {
+		mux.HandleFunc(route.Path, route.Handler)
+	}
+	return mux
+}
+
+func TestHandlers(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		want   string
+	}{
+		{"site generation", http.MethodGet, "/api/awali-power-generation", "", http.StatusOK, `"lastMonth":"80.00 MWh","lastYear":"170.00 MWh"`},
+		{"site without generation", http.MethodGet, "/api/refinery-power-generation", "", http.StatusNotFound, `"code":"not_found"`},
+		{"unknown site", http.MethodGet, "/api/uob-power-generation", "", http.StatusNotFound, `"code":"invalid_site"`},
+		{"system configuration", http.MethodGet, "/api/system-configuration", "", http.StatusOK, `{"Name":"Refinery","InstalledCapacity":2000,"NumberOfPanels":8000}`},
+		{"ready", http.MethodGet, "/readyz", "", http.StatusOK, `"monthly_performance":"ok"`},
+		{"quarantine entry missing", http.MethodGet, "/api/quarantine/9", "", http.StatusNotFound, "quarantine entry not found"},
+		{"quarantine path", http.MethodGet, "/api/quarantine/latest", "", http.StatusNotFound, "Not found"},
+		{"import missing", http.MethodGet, "/api/imports/9", "", http.StatusNotFound, "import not found"},
+		{"create asset", http.MethodPost, "/api/assets", `{"site":"awali","kind":"inverter","code":"INV1","capacityKw":500}`, http.StatusCreated, `"code":"INV1"`},
+		{"asset without kind", http.MethodPost, "/api/assets", `{"site":"Awali","code":"INV1"}`, http.StatusBadRequest, "kind must be inverter or string"},
+		{"asset with unknown field", http.MethodPost, "/api/assets", `{"site":"Awali","kind":"inverter","code":"INV1","size":5}`, http.StatusBadRequest, "Invalid asset"},
+		{"asset missing", http.MethodGet, "/api/assets/9", "", http.StatusNotFound, "asset not found"},
+		{"update emission factor", http.MethodPut, "/api/emission-factors/grid", `{"value":0.5,"author":"ops","reason":"2020 grid mix"}`, http.StatusOK, `"value":0.5`},
+		{"emission factor without reason", http.MethodPut, "/api/emission-factors/grid", `{"value":0.5,"author":"ops"}`, http.StatusBadRequest, "author and reason are required"},
+		{"emission factor missing", http.MethodPut, "/api/emission-factors/diesel", `{"value":0.5,"author":"ops","reason":"new"}`, http.StatusNotFound, "emission factor not found"},
+		{"job runs", http.MethodGet, "/api/admin/job-runs?job=refresh", "", http.StatusOK, `[{"id":1,"jobName":"refresh"`},
+		{"job runs limit", http.MethodGet, "/api/admin/job-runs?limit=0", "", http.StatusBadRequest, "Invalid limit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newTestServer(t, testData()).ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("body = %s, want it to contain %s", w.Body.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	data := testData()
+	data.Generation, data.MonthlyGeneration = nil, nil
+	server := newTestServer(t, data)
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), `"monthly_generation":"empty"`) {
+		t.Fatalf("without generation: %d %s, want 503 with monthly_generation empty", w.Code, w.Body.String())
+	}
+
+	data.MonthlyGeneration = testData().MonthlyGeneration
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"ready":true`) {
+		t.Errorf("with generation: %d %s, want 200 and ready", w.Code, w.Body.String())
+	}
+}
+
+func TestSaveAssetStores(t *testing.T) {
+	data := testData()
+	w := httptest.NewRecorder()
+	newTestServer(t, data).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/assets",
+		strings.NewReader(`{"site":"Awali","kind":"inverter","code":"INV1","capacityKw":500}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+
+	if len(data.Assets) != 2 || data.Assets[1].Code != "INV1" || data.Assets[1].ParentID == nil || *data.Assets[1].ParentID != 1 {
+		t.Errorf("assets = %+v, want INV1 under the Awali site asset", data.Assets)
+	}
+}
+
+func TestUpdateEmissionFactorRecordsRevision(t *testing.T) {
+	data := testData()
+	w := httptest.NewRecorder()
+	newTestServer(t, data).ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/emission-factors/grid",
+		strings.NewReader(`{"value":0.5,"author":"ops","reason":"2020 grid mix"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+
+	if data.EmissionFactors[0].Value != 0.5 {
+		t.Errorf("grid = %v, want 0.5", data.EmissionFactors[0].Value)
+	}
+	if len(data.Revisions) != 1 || *data.Revisions[0].OldValue != 0.4 || data.Revisions[0].ChangedBy != "ops" ||
+		data.Revisions[0].Reason != "2020 grid mix" {
+		t.Errorf("revisions = %+v, want one from 0.4 by ops", data.Revisions)
+	}
+}
+
+func TestGauges(t *testing.T) {
+	h := NewHandlers(memory.New(testData()), Services{})
+
+	tests := []struct {
+		name    string
+		samples func() ([]metrics.Sample, error)
+		want    []metrics.Sample
+	}{
+		{"performance ratio", h.latestMonth(func(p structure.LatestPerformance) *float64 { return p.PerformanceRatio }),
+			[]metrics.Sample{{Labels: []string{"Awali"}, Value: 0.78}}},
+		{"generation", h.latestMonth(func(p structure.LatestPerformance) *float64 { return &p.Generation }),
+			[]metrics.Sample{{Labels: []string{"Awali"}, Value: 80000}}},
+		{"missing value", h.latestMonth(func(p structure.LatestPerformance) *float64 { return nil }), []metrics.Sample{}},
+		{"open quarantine", h.openQuarantine, []metrics.Sample{
+			{Labels: []string{"monthly_generation"}, Value: 1},
+			{Labels: []string{"weather_daily"}, Value: 1},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples, err := tt.samples()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(samples) != len(tt.want) {
+				t.Fatalf("samples = %+v, want %+v", samples, tt.want)
+			}
+			for i, sample := range samples {
+				if strings.Join(sample.Labels, ",") != strings.Join(tt.want[i].Labels, ",") || sample.Value != tt.want[i].Value {
+					t.Errorf("sample %d = %+v, want %+v", i, sample, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"backend/pkg/config"
+	"backend/pkg/logging"
+	"backend/pkg/pipeline"
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// readyTimeout bounds the database ping so an unreachable database fails the probe instead of hanging it
+const readyTimeout = 2 * time.Second
+
+// Healthz reports that the process is up and serving, for liveness probes
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+// Readyz reports whether the server can answer the dashboard: the database is reachable and the
+// tables it reads from are populated. It answers 503 with the failing checks otherwise.
+func (h *Handlers) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	readiness := structure.Readiness{Ready: true, Checks: map[string]string{}}
+	fail := func(check, problem string) {
+		readiness.Ready = false
+		readiness.Checks[check] = problem
+	}
+
+	if err := h.status.Ping(ctx); err != nil {
+		logging.FromContext(r.Context()).Error("Database is not reachable", "err", err)
+		fail("database", "unreachable")
+	} else {
+		readiness.Checks["database"] = "ok"
+		for _, table := range repository.ReadyTables {
+			populated, err := h.status.Populated(table)
+			switch {
+			case err != nil:
+				logging.FromContext(r.Context()).Error("Error checking readiness", "table", table, "err", err)
+				fail(table, "error")
+			case !populated:
+				fail(table, "empty")
+			default:
+				readiness.Checks[table] = "ok"
+			}
+		}
+	}
+
+	if !readiness.Ready {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(readiness)
+		return
+	}
+	writeJSON(w, readiness)
+}
+
+// Status reports the latest month of generation per site, the latest weather, the last pipeline
+// run and the model versions in use, each flagged stale when older than its freshness setting
+func (h *Handlers) Status(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status, err := h.buildStatus(time.Now().UTC(), h.freshness)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Error building status", "err", err)
+		writeError(w, "Error building status", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, status)
+}
+
+func (h *Handlers) buildStatus(now time.Time, freshness config.FreshnessConfig) (*structure.Status, error) {
+	status := &structure.Status{CheckedAt: now.Format(time.RFC3339)}
+
+	sites, err := h.generation.Latest()
+	if err != nil {
+		return nil, err
+	}
+	for i := range sites {
+		site := &sites[i]
+		site.AgeDays, site.Stale = monthAge(site.LatestMonth, now, freshness.Generation)
+		status.Stale = status.Stale || site.Stale
+	}
+	status.Sites = sites
+
+	day, month, err := h.weather.Latest()
+	if err != nil {
+		return nil, err
+	}
+	status.Weather = structure.WeatherFreshness{LatestDay: day, LatestMonth: month}
+	status.Weather.AgeDays, status.Weather.Stale = age(day, 24*time.Hour, now, freshness.Weather)
+	status.Stale = status.Stale || status.Weather.Stale
+
+	status.Pipeline.Running = h.scheduler.Running()
+	if status.Pipeline.LastRun, err = h.jobs.Last(""); err != nil {
+		return nil, err
+	}
+	if status.Pipeline.LastSuccess, err = h.jobs.Last(pipeline.StatusSucceeded); err != nil {
+		return nil, err
+	}
+	finished := ""
+	if status.Pipeline.LastSuccess != nil {
+		finished = status.Pipeline.LastSuccess.FinishedAt
+	}
+	status.Pipeline.AgeHours, status.Pipeline.Stale = age(finished, time.Hour, now, freshness.Pipeline)
+	status.Stale = status.Stale || status.Pipeline.Stale
+
+	models, err := h.models.Versions()
+	if err != nil {
+		return nil, err
+	}
+	for i := range models {
+		model := &models[i]
+		model.AgeDays, model.Stale = age(model.TrainedAt, 24*time.Hour, now, freshness.Model)
+		status.Stale = status.Stale || model.Stale
+	}
+	status.Models = models
+
+	return status, nil
+}
+
+// timestampLayouts are the forms stored timestamps come back in: the driver returns TIMESTAMP
+// columns as RFC 3339, and dates as written
+var timestampLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+
+// monthAge is how many days have passed since the end of month ("2006-01"), and whether that is
+// more than limit. A missing month is stale.
+func monthAge(month string, now time.Time, limit config.Duration) (float64, bool) {
+	start, err := time.Parse("2006-01", month)
+	if err != nil {
+		return 0, true
+	}
+	elapsed := now.Sub(start.AddDate(0, 1, 0))
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return roundAge(elapsed, 24*time.Hour), elapsed > time.Duration(limit)
+}
+
+// age is how long ago the UTC timestamp value was, in units of unit, and whether that is more
+// than limit. A missing or unreadable value is stale.
+func age(value string, unit time.Duration, now time.Time, limit config.Duration) (float64, bool) {
+	var at time.Time
+	var err error
+	for _, layout := range timestampLayouts {
+		if at, err = time.Parse(layout, value); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return 0, true
+	}
+	elapsed := now.Sub(at)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return roundAge(elapsed, unit), elapsed > time.Duration(limit)
+}
+
+func roundAge(elapsed, unit time.Duration) float64 {
+	return float64(elapsed.Round(unit/10)) / float64(unit)
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"backend/pkg/config"
+	"backend/pkg/pipeline"
+	"backend/pkg/repository/sqlstore"
+	structure "backend/pkg/struct"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMonthAge(t *testing.T) {
+	now := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	limit := config.Duration(62 * 24 * time.Hour)
+	tests := []struct {
+		month string
+		days  float64
+		stale bool
+	}{
+		// Months are aged from their end
+		{"2024-02", 10, false},
+		{"2024-03", 0, false},
+		{"2023-12", 70, true},
+		{"", 0, true},
+		{"2024-13", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.month, func(t *testing.T) {
+			days, stale := monthAge(tt.month, now, limit)
+			if days != tt.days || stale != tt.stale {
+				t.Errorf("monthAge(%q) = %v, %v, want %v, %v", tt.month, days, stale, tt.days, tt.stale)
+			}
+		})
+	}
+}
+
+func TestAge(t *testing.T) {
+	now := time.Date(2024, 3, 11, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value string
+		unit  time.Duration
+		age   float64
+		stale bool
+	}{
+		{"RFC 3339", "2024-03-10T00:00:00Z", time.Hour, 36, true},
+		{"SQLite timestamp", "2024-03-11 06:00:00", time.Hour, 6, false},
+		{"date in days", "2024-03-01", 24 * time.Hour, 10.5, true},
+		{"in the future", "2024-03-12 00:00:00", time.Hour, 0, false},
+		{"missing", "", time.Hour, 0, true},
+		{"unreadable", "yesterday", time.Hour, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			age, stale := age(tt.value, tt.unit, now, config.Duration(24*time.Hour))
+			if age != tt.age || stale != tt.stale {
+				t.Errorf("age(%q) = %v, %v, want %v, %v", tt.value, age, stale, tt.age, tt.stale)
+			}
+		})
+	}
+}
+
+func TestReadyzChecks(t *testing.T) {
+	tests := []struct {
+		name   string
+		seed   []string
+		status int
+		checks map[string]string
+	}{
+		{"empty database", nil, http.StatusServiceUnavailable, map[string]string{
+			"database": "ok", "locations": "empty", "monthly_generation": "empty", "monthly_performance": "empty", "weather_daily": "empty",
+		}},
+		{"populated", []string{
+			`INSERT INTO locations (id, name) VALUES (1, 'Awali')`,
+			`INSERT INTO monthly_generation (year, month, location_id, actual_kwh) VALUES (2024, 2, 1, 100)`,
+			`INSERT INTO monthly_performance (year, month, location_id, performance_ratio) VALUES (2024, 2, 1, 0.8)`,
+			`INSERT INTO weather_daily (date, sunrise_time, sunset_time) VALUES ('2024-03-01', '06:00', '18:00')`,
+		}, http.StatusOK, map[string]string{
+			"database": "ok", "locations": "ok", "monthly_generation": "ok", "monthly_performance": "ok", "weather_daily": "ok",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := openTestDatabase(t)
+			for _, query := range tt.seed {
+				if _, err := database.Exec(query); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			h := NewHandlers(sqlstore.New(database), Services{})
+			w := httptest.NewRecorder()
+			h.Readyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			var readiness structure.Readiness
+			if err := json.NewDecoder(w.Body).Decode(&readiness); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tt.status || readiness.Ready != (tt.status == http.StatusOK) {
+				t.Errorf("Readyz() = %d, ready %v, want %d", w.Code, readiness.Ready, tt.status)
+			}
+			if !reflect.DeepEqual(readiness.Checks, tt.checks) {
+				t.Errorf("checks = %v, want %v", readiness.Checks, tt.checks)
+			}
+		})
+	}
+}
+
+func TestBuildStatus(t *testing.T) {
+	database := openTestDatabase(t)
+	for _, query := range []string{
+		`INSERT INTO locations (id, name) VALUES (1, 'Awali'), (2, 'UOB')`,
+		`INSERT INTO monthly_generation (year, month, location_id, actual_kwh) VALUES (2024, 1, 1, 100), (2024, 2, 1, 110), (2024, 3, 2, NULL)`,
+		`INSERT INTO weather_daily (date, sunrise_time, sunset_time) VALUES ('2024-03-09', '06:00', '18:00')`,
+	} {
+		if _, err := database.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := NewHandlers(sqlstore.New(database), Services{Scheduler: pipeline.NewScheduler(database)})
+	now := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	status, err := h.buildStatus(now, config.Defaults().Freshness)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []structure.SiteFreshness{
+		{Site: "Awali", LatestMonth: "2024-02", AgeDays: 10},
+		// A site without any actuals is stale
+		{Site: "UOB", Stale: true},
+	}
+	if !reflect.DeepEqual(status.Sites, want) {
+		t.Errorf("sites = %+v, want %+v", status.Sites, want)
+	}
+	if status.Weather.LatestDay != "2024-03-09" || status.Weather.AgeDays != 2 || status.Weather.Stale {
+		t.Errorf("weather = %+v", status.Weather)
+	}
+	// The pipeline has never succeeded
+	if !status.Pipeline.Stale || !status.Stale {
+		t.Errorf("pipeline = %+v, stale %v, want stale", status.Pipeline, status.Stale)
+	}
+}
+
+func TestStatusFreshness(t *testing.T) {
+	database := openTestDatabase(t)
+	day := time.Now().UTC().AddDate(0, 0, -3).Format("2006-01-02")
+	if _, err := database.Exec(`INSERT INTO weather_daily (date, sunrise_time, sunset_time) VALUES (?, '06:00', '18:00')`, day); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		weather time.Duration
+		stale   bool
+	}{
+		{"within the configured age", 10 * 24 * time.Hour, false},
+		{"older than the configured age", 24 * time.Hour, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			freshness := config.Defaults().Freshness
+			freshness.Weather = config.Duration(tt.weather)
+			h := NewHandlers(sqlstore.New(database), Services{Scheduler: pipeline.NewScheduler(database), Freshness: freshness})
+
+			w := httptest.NewRecorder()
+			h.Status(w, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+			}
+			var status structure.Status
+			if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+				t.Fatal(err)
+			}
+			if status.Weather.LatestDay != day || status.Weather.Stale != tt.stale {
+				t.Errorf("weather = %+v, want %s stale %v", status.Weather, day, tt.stale)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"backend/pkg/audit"
+	"backend/pkg/data"
+	"backend/pkg/logging"
+	"backend/pkg/metrics"
+	"backend/pkg/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Imports uploads generation and weather data in two steps, a dry run and then an apply:
+//
+//	GET    /api/imports?status=&limit=
+//	POST   /api/imports?dataset=monthly_generation|daily_generation|weather_daily&format=csv|xlsx
+//	GET    /api/imports/{id}
+//	POST   /api/imports/{id}/apply    optional {"author": "", "reason": ""} for the revision history
+//	DELETE /api/imports/{id}
+func (h *Handlers) Imports(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/imports"), "/")
+	if path == "" {
+		switch r.Method {
+		case http.MethodGet:
+			h.listImports(w, r)
+		case http.MethodPost:
+			h.createImport(w, r)
+		default:
+			writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		NotFound(w, r)
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		item, err := h.imports.Get(id)
+		if errors.Is(err, repository.ErrImportNotFound) {
+			writeError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			writeError(w, "Error fetching import", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, item)
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		if err := h.loader.DiscardImport(id); err != nil {
+			importError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 2 && parts[1] == "apply" && r.Method == http.MethodPost:
+		h.applyImport(w, r, id)
+	case len(parts) <= 2:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		NotFound(w, r)
+	}
+}
+
+func (h *Handlers) listImports(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			writeError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	imports, err := h.imports.List(r.URL.Query().Get("status"), limit)
+	if err != nil {
+		writeError(w, "Error fetching imports", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, imports)
+}
+
+// createImport stores the upload as a pending import and returns its dry-run diff
+func (h *Handlers) createImport(w http.ResponseWriter, r *http.Request) {
+	dataset := r.URL.Query().Get("dataset")
+	if dataset == "" {
+		writeError(w, "dataset is required", http.StatusBadRequest)
+		return
+	}
+
+	body, err := uploadBody(w, r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer body.Close()
+
+	content, err := io.ReadAll(body)
+	if err != nil {
+		writeError(w, fmt.Sprintf("Error reading upload: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	filename := ""
+	if r.MultipartForm != nil {
+		if files := r.MultipartForm.File["file"]; len(files) > 0 {
+			filename = files[0].Filename
+		}
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = data.DetectFormat(filename, content)
+	}
+
+	item, err := h.loader.CreateImport(dataset, filename, format, content)
+	if err != nil {
+		importError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(item)
+}
+
+// applyImport writes a pending import and recomputes the tables derived from it
+func (h *Handlers) applyImport(w http.ResponseWriter, r *http.Request, id int) {
+	var change audit.Change
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil && err != io.EOF {
+		writeError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	change.Author = author(r, change.Author)
+	item, err := h.loader.ApplyImport(id, change)
+	if err != nil {
+		importError(w, r, err)
+		return
+	}
+	metrics.RecordIngest("upload", nil)
+
+	response := map[string]interface{}{"import": item}
+	if item.Inserted+item.Updated > 0 {
+		h.triggerRefresh(response)
+	}
+	writeJSON(w, response)
+}
+
+func importError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, repository.ErrImportNotFound):
+		writeError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, data.ErrImportNotPending):
+		writeError(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, data.ErrUnknownDataset), errors.Is(err, data.ErrInvalidUpload):
+		writeError(w, err.Error(), http.StatusBadRequest)
+	default:
+		logging.FromContext(r.Context()).Error("Error processing import", "err", err)
+		writeError(w, "Error processing import", http.StatusInternalServerError)
+	}
+}
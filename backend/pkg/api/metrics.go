@@ -0,0 +1,91 @@
+package api
+
+import (
+	"backend/pkg/metrics"
+	structure "backend/pkg/struct"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var requestDuration = metrics.NewHistogramVec("solar_http_request_duration_seconds",
+	"Time taken to answer HTTP requests, by route, method and status.",
+	metrics.DefaultBuckets, "route", "method", "status")
+
+// RegisterGauges adds the business gauges to the default registry. They are read on each scrape,
+// so they are current after a restart; call it once, as registering a name twice panics.
+func (h *Handlers) RegisterGauges() {
+	metrics.NewGaugeFunc("solar_site_performance_ratio",
+		"Performance ratio of each site's latest month.", []string{"site"},
+		h.latestMonth(func(p structure.LatestPerformance) *float64 { return p.PerformanceRatio }))
+	metrics.NewGaugeFunc("solar_site_capacity_factor",
+		"Capacity factor of each site's latest month.", []string{"site"},
+		h.latestMonth(func(p structure.LatestPerformance) *float64 { return p.CapacityFactor }))
+	metrics.NewGaugeFunc("solar_site_generation_kwh",
+		"Generation in kWh of each site's latest month, imputed if it was missing.", []string{"site"},
+		h.latestMonth(func(p structure.LatestPerformance) *float64 { return &p.Generation }))
+	metrics.NewGaugeFunc("solar_quarantine_open",
+		"Rows waiting for a quarantine review, by dataset.", []string{"dataset"}, h.openQuarantine)
+}
+
+// latestMonth reports value of each site's latest monthly performance, leaving out sites where
+// it is nil
+func (h *Handlers) latestMonth(value func(structure.LatestPerformance) *float64) func() ([]metrics.Sample, error) {
+	return func() ([]metrics.Sample, error) {
+		latest, err := h.performance.Latest()
+		if err != nil {
+			return nil, err
+		}
+
+		samples := make([]metrics.Sample, 0, len(latest))
+		for _, site := range latest {
+			if v := value(site); v != nil {
+				samples = append(samples, metrics.Sample{Labels: []string{site.Site}, Value: *v})
+			}
+		}
+		return samples, nil
+	}
+}
+
+func (h *Handlers) openQuarantine() ([]metrics.Sample, error) {
+	open, err := h.quality.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	datasets := make([]string, 0, len(open))
+	for dataset := range open {
+		datasets = append(datasets, dataset)
+	}
+	sort.Strings(datasets)
+
+	samples := make([]metrics.Sample, 0, len(open))
+	for _, dataset := range datasets {
+		samples = append(samples, metrics.Sample{Labels: []string{dataset}, Value: float64(open[dataset])})
+	}
+	return samples, nil
+}
+
+// Metrics serves the counters, histograms and gauges in the Prometheus text format
+func Metrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	metrics.Default.Handler()(w, r)
+}
+
+// observeRequest records a request against the route that served it; requests no route matched
+// share one series, as do unusual methods, so clients cannot grow the label set
+func observeRequest(route, method string, status int, elapsed time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	switch method {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions, http.MethodHead:
+	default:
+		method = "other"
+	}
+	requestDuration.Observe(elapsed.Seconds(), route, method, strconv.Itoa(status))
+}
@@ -1,64 +1,69 @@
 package api
 
 import (
-	"encoding/json"
-	"net/http"
-	"backend/pkg/db/queries"
 	structure "backend/pkg/struct"
+	"fmt"
+	"net/http"
 )
 
-func TotalPowerGeneration(w http.ResponseWriter, r *http.Request) {
-	response := structure.PowerGenerationResponse{
-		LastMonth: queries.GetLastMonthPowerGeneration("Total System"),
-		LastYear:  queries.GetLastYearPowerGeneration("Total System"),
-		Forecast:  queries.GetPowerGenerationForecast("Total System"),
-	}
+func (h *Handlers) TotalPowerGeneration(w http.ResponseWriter, r *http.Request) {
+	h.powerGeneration(w, r, "Total System")
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
-		return
-	}
+func (h *Handlers) AwaliPowerGeneration(w http.ResponseWriter, r *http.Request) {
+	h.powerGeneration(w, r, "Awali")
 }
 
-func AwaliPowerGeneration(w http.ResponseWriter, r *http.Request) {
-	response := structure.PowerGenerationResponse{
-		LastMonth: queries.GetLastMonthPowerGeneration("Awali"),
-		LastYear:  queries.GetLastYearPowerGeneration("Awali"),
-		Forecast:  queries.GetPowerGenerationForecast("Awali"),
-	}
+func (h *Handlers) RefineryPowerGeneration(w http.ResponseWriter, r *http.Request) {
+	h.powerGeneration(w, r, "Refinery")
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
-		return
-	}
+func (h *Handlers) UOBPowerGeneration(w http.ResponseWriter, r *http.Request) {
+	h.powerGeneration(w, r, "UOB")
 }
 
-func RefineryPowerGeneration(w http.ResponseWriter, r *http.Request) {
-	response := structure.PowerGenerationResponse{
-		LastMonth: queries.GetLastMonthPowerGeneration("Refinery"),
-		LastYear:  queries.GetLastYearPowerGeneration("Refinery"),
-		Forecast:  queries.GetPowerGenerationForecast("Refinery"),
+// powerGeneration answers a site's latest month and year of generation with its forecast. A site
+// without generation is 404 rather than zeros.
+func (h *Handlers) powerGeneration(w http.ResponseWriter, r *http.Request, site string) {
+	lastMonth, err := h.generation.LastMonth(site)
+	if err != nil {
+		queryError(w, r, err)
+		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	lastYear, err := h.generation.LastYear(site)
+	if err != nil {
+		queryError(w, r, err)
 		return
 	}
-}
-
-func UOBPowerGeneration(w http.ResponseWriter, r *http.Request) {
-	response := structure.PowerGenerationResponse{
-		LastMonth: queries.GetLastMonthPowerGeneration("UOB"),
-		LastYear:  queries.GetLastYearPowerGeneration("UOB"),
-		Forecast:  queries.GetPowerGenerationForecast("UOB"),
+	forecast, err := h.generation.Forecast(site)
+	if err != nil {
+		queryError(w, r, err)
+		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	calibration, err := h.generation.Calibration(site)
+	if err != nil {
+		queryError(w, r, err)
 		return
 	}
-}
\ No newline at end of file
+
+	writeJSON(w, structure.PowerGenerationResponse{
+		LastMonth:   FormatPowerValue(lastMonth),
+		LastYear:    FormatPowerValue(lastYear),
+		Forecast:    forecast,
+		Calibration: calibration,
+	})
+}
+
+// FormatPowerValue converts kWh to the most appropriate unit (kWh, MWh, GWh, etc.) and returns as formatted string
+func FormatPowerValue(valueInKWh float64) string {
+	switch {
+	case valueInKWh >= 1_000_000_000: // Billion kWh -> TWh
+		return fmt.Sprintf("%.2f TWh", valueInKWh/1_000_000_000)
+	case valueInKWh >= 1_000_000: // Million kWh -> GWh
+		return fmt.Sprintf("%.2f GWh", valueInKWh/1_000_000)
+	case valueInKWh >= 1_000: // Thousand kWh -> MWh
+		return fmt.Sprintf("%.2f MWh", valueInKWh/1_000)
+	default: // kWh
+		return fmt.Sprintf("%.2f kWh", valueInKWh)
+	}
+}
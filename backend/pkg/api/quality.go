@@ -0,0 +1,179 @@
+package api
+
+import (
+	"backend/pkg/logging"
+	"backend/pkg/quality"
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Quarantine lists rows that failed a data quality rule and records their review:
+//
+//	GET  /api/quarantine?dataset=&status=&site=&limit=
+//	GET  /api/quarantine/rules
+//	GET  /api/quarantine/{id}
+//	POST /api/quarantine/{id}/review
+func (h *Handlers) Quarantine(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/quarantine"), "/")
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		h.listQuarantine(w, r)
+		return
+	case path == "rules" && r.Method == http.MethodGet:
+		writeJSON(w, quality.Rules())
+		return
+	case path == "" || path == "rules":
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		NotFound(w, r)
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		entry, err := h.quality.Entry(id)
+		if err != nil {
+			quarantineError(w, r, err)
+			return
+		}
+		writeJSON(w, entry)
+	case len(parts) == 2 && parts[1] == "review" && r.Method == http.MethodPost:
+		h.reviewQuarantine(w, r, id)
+	case len(parts) <= 2:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		NotFound(w, r)
+	}
+}
+
+func (h *Handlers) listQuarantine(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := 100
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			writeError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	site := ""
+	if value := query.Get("site"); value != "" {
+		resolved, err := h.sites.Resolve(value)
+		if errors.Is(err, repository.ErrInvalidSite) {
+			writeErrorCode(w, "Unknown site", http.StatusBadRequest, "invalid_site")
+			return
+		}
+		if err != nil {
+			queryError(w, r, err)
+			return
+		}
+		site = resolved
+	}
+
+	entries, err := h.quality.Quarantine(query.Get("dataset"), query.Get("status"), site, limit)
+	if err != nil {
+		writeError(w, "Error fetching quarantine", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, entries)
+}
+
+// reviewQuarantine accepts or rejects a quarantined row and recomputes the metrics it feeds
+func (h *Handlers) reviewQuarantine(w http.ResponseWriter, r *http.Request, id int) {
+	var review structure.QuarantineReview
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+		writeError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.checker.Review(id, review); err != nil {
+		quarantineError(w, r, err)
+		return
+	}
+	entry, err := h.quality.Entry(id)
+	if err != nil {
+		quarantineError(w, r, err)
+		return
+	}
+
+	response := map[string]interface{}{"entry": entry}
+	h.triggerRefresh(response)
+	writeJSON(w, response)
+}
+
+func quarantineError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, repository.ErrQuarantineNotFound):
+		writeError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, quality.ErrInvalidReview):
+		writeError(w, err.Error(), http.StatusBadRequest)
+	default:
+		logging.FromContext(r.Context()).Error("Error processing quarantine entry", "err", err)
+		writeError(w, "Error processing quarantine entry", http.StatusInternalServerError)
+	}
+}
+
+// Gaps lists the missing and quarantined periods of the generation and weather series with the
+// estimates that fill them:
+//
+//	GET /api/gaps?dataset=&site=&imputed=true|false&limit=
+func (h *Handlers) Gaps(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	limit := 500
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			writeError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	var imputed *bool
+	if value := query.Get("imputed"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			writeError(w, "Invalid imputed", http.StatusBadRequest)
+			return
+		}
+		imputed = &parsed
+	}
+
+	site := ""
+	if value := query.Get("site"); value != "" {
+		resolved, err := h.sites.Resolve(value)
+		if errors.Is(err, repository.ErrInvalidSite) {
+			writeErrorCode(w, "Unknown site", http.StatusBadRequest, "invalid_site")
+			return
+		}
+		if err != nil {
+			queryError(w, r, err)
+			return
+		}
+		site = resolved
+	}
+
+	gaps, err := h.quality.Gaps(query.Get("dataset"), site, imputed, limit)
+	if err != nil {
+		writeError(w, "Error fetching gaps", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, gaps)
+}
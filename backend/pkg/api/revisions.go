@@ -0,0 +1,179 @@
+package api
+
+import (
+	"backend/pkg/audit"
+	"backend/pkg/logging"
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Revisions returns the history of monthly generation, locations and emission factors, and their
+// values as they stood at a point in time:
+//
+//	GET /api/revisions?table=&key=&limit=
+//	GET /api/revisions/as-of?at=YYYY-MM-DD|RFC3339&table=&key=
+//
+// For monthly_generation the key can be given as site, year and month.
+func (h *Handlers) Revisions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	table := query.Get("table")
+	key, err := h.revisionKey(table, query.Get("key"), query.Get("site"), query.Get("year"), query.Get("month"))
+	if errors.Is(err, repository.ErrStorage) {
+		queryError(w, r, err)
+		return
+	}
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/revisions"), "/") {
+	case "":
+		limit := 100
+		if value := query.Get("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed <= 0 {
+				writeError(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = parsed
+		}
+
+		revisions, err := h.revisions.List(table, key, limit)
+		if err != nil {
+			writeError(w, "Error fetching revisions", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, revisions)
+	case "as-of":
+		at, err := parseAsOf(query.Get("at"))
+		if err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		values, err := h.revisions.AsOf(table, key, at)
+		if err != nil {
+			writeError(w, "Error fetching values", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, values)
+	default:
+		NotFound(w, r)
+	}
+}
+
+// revisionKey builds a monthly_generation key from site, year and month when no key is given
+func (h *Handlers) revisionKey(table, key, site, year, month string) (string, error) {
+	if key != "" || site == "" {
+		return key, nil
+	}
+	if table != "monthly_generation" && table != "locations" {
+		return "", errors.New("site only applies to monthly_generation and locations")
+	}
+
+	name, err := h.sites.Resolve(site)
+	if errors.Is(err, repository.ErrInvalidSite) {
+		return "", errors.New("Unknown site")
+	}
+	if err != nil {
+		return "", err
+	}
+	if table == "locations" {
+		return name, nil
+	}
+
+	y, err := strconv.Atoi(year)
+	if err != nil {
+		return "", errors.New("Invalid year")
+	}
+	m, err := strconv.Atoi(month)
+	if err != nil || m < 1 || m > 12 {
+		return "", errors.New("Invalid month")
+	}
+	return fmt.Sprintf("%s/%04d-%02d", name, y, m), nil
+}
+
+// parseAsOf reads a timestamp, or a date meaning the end of that day in UTC
+func parseAsOf(value string) (time.Time, error) {
+	if value == "" {
+		return time.Now().UTC(), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, errors.New("at must be YYYY-MM-DD or RFC3339")
+	}
+	return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+}
+
+// EmissionFactors lists the emission factors and changes them:
+//
+//	GET /api/emission-factors
+//	PUT /api/emission-factors/{name}    {"value": 400, "author": "", "reason": ""}
+func (h *Handlers) EmissionFactors(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/emission-factors"), "/")
+	switch {
+	case name == "" && r.Method == http.MethodGet:
+		factors, err := h.emissionFactors.List()
+		if err != nil {
+			writeError(w, "Error fetching emission factors", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, factors)
+	case name != "" && r.Method == http.MethodGet:
+		factor, err := h.emissionFactors.Get(name)
+		if err != nil {
+			emissionFactorError(w, r, err)
+			return
+		}
+		writeJSON(w, factor)
+	case name != "" && r.Method == http.MethodPut:
+		var update structure.EmissionFactorUpdate
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			writeError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if update.Value == nil || *update.Value < 0 {
+			writeError(w, "value must be zero or more", http.StatusBadRequest)
+			return
+		}
+		update.Author = author(r, update.Author)
+		if update.Author == "" || update.Reason == "" {
+			writeError(w, "author and reason are required", http.StatusBadRequest)
+			return
+		}
+
+		factor, err := h.emissionFactors.Update(name, *update.Value, audit.Change{Author: update.Author, Reason: update.Reason})
+		if err != nil {
+			emissionFactorError(w, r, err)
+			return
+		}
+		writeJSON(w, factor)
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func emissionFactorError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, repository.ErrEmissionFactorNotFound) {
+		writeError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	logging.FromContext(r.Context()).Error("Error processing emission factor", "err", err)
+	writeError(w, "Error processing emission factor", http.StatusInternalServerError)
+}
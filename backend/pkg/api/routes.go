@@ -0,0 +1,86 @@
+package api
+
+import (
+	"backend/pkg/auth"
+	"net/http"
+)
+
+// Methods maps each method a route's handler supports to the least role that may use it
+type Methods map[string]auth.Role
+
+// Route is a path on the default mux with the methods its handler supports. The CORS middleware
+// answers OPTIONS and rejects other methods from this list, so it must match the handler.
+type Route struct {
+	Path    string
+	Methods Methods
+	Handler http.HandlerFunc
+}
+
+// methodOrder is the order methods are listed in Allow headers
+var methodOrder = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// List returns the supported methods in a stable order
+func (m Methods) List() []string {
+	list := make([]string, 0, len(m))
+	for _, method := range methodOrder {
+		if _, ok := m[method]; ok {
+			list = append(list, method)
+		}
+	}
+	return list
+}
+
+func read(role auth.Role) Methods {
+	return Methods{http.MethodGet: role}
+}
+
+// Routes is every endpoint the server registers, served by h. Paths
+// ending in / also serve their subpaths, so their methods are the union of what the subpaths
+// support. Reads are open to viewers; changing data takes an operator, reviewing it or running
+// scenarios an analyst, and managing access an admin.
+func Routes(h *Handlers) []Route {
+	return []Route{
+		{"/api/environment-impact", read(auth.Viewer), h.EnvironmentalImpact},
+		{"/api/weather-impact", read(auth.Viewer), h.WeatherImpact},
+		{"/api/weather-outlook", Methods{http.MethodGet: auth.Viewer, http.MethodPost: auth.Operator}, h.WeatherOutlook},
+		{"/api/total-power-generation", read(auth.Viewer), h.TotalPowerGeneration},
+		{"/api/awali-power-generation", read(auth.Viewer), h.AwaliPowerGeneration},
+		{"/api/uob-power-generation", read(auth.Viewer), h.UOBPowerGeneration},
+		{"/api/refinery-power-generation", read(auth.Viewer), h.RefineryPowerGeneration},
+		{"/api/performance", read(auth.Viewer), h.Performance},
+		{"/api/generation/daily", Methods{http.MethodPost: auth.Operator}, h.DailyGeneration},
+		{"/api/generation/interval", Methods{http.MethodPost: auth.Operator}, h.IntervalGeneration},
+		{"/api/generation/assets", Methods{http.MethodPost: auth.Operator}, h.AssetGeneration},
+		{"/api/imports", Methods{http.MethodGet: auth.Viewer, http.MethodPost: auth.Operator}, h.Imports},
+		{"/api/imports/", Methods{http.MethodGet: auth.Viewer, http.MethodPost: auth.Operator, http.MethodDelete: auth.Operator}, h.Imports},
+		{"/api/quarantine", read(auth.Viewer), h.Quarantine},
+		{"/api/quarantine/", Methods{http.MethodGet: auth.Viewer, http.MethodPost: auth.Analyst}, h.Quarantine},
+		{"/api/gaps", read(auth.Viewer), h.Gaps},
+		{"/api/revisions", read(auth.Viewer), h.Revisions},
+		{"/api/revisions/", read(auth.Viewer), h.Revisions},
+		{"/api/emission-factors", read(auth.Viewer), h.EmissionFactors},
+		{"/api/emission-factors/", Methods{http.MethodGet: auth.Viewer, http.MethodPut: auth.Operator}, h.EmissionFactors},
+		{"/api/telemetry/status", read(auth.Viewer), h.TelemetryStatus},
+		{"/api/system-configuration", read(auth.Viewer), h.SystemConfiguration},
+		{"/api/scenarios", Methods{http.MethodGet: auth.Viewer, http.MethodPost: auth.Analyst}, h.Scenarios},
+		{"/api/sites/", read(auth.Viewer), h.Sites},
+		{"/api/assets", Methods{http.MethodGet: auth.Viewer, http.MethodPost: auth.Operator}, h.Assets},
+		{"/api/assets/", Methods{http.MethodGet: auth.Viewer, http.MethodPost: auth.Operator, http.MethodPut: auth.Operator}, h.Assets},
+		{"/api/admin/jobs", read(auth.Operator), h.AdminJobs},
+		{"/api/admin/jobs/", Methods{http.MethodGet: auth.Operator, http.MethodPost: auth.Operator}, h.AdminJobs},
+		{"/api/admin/job-runs", read(auth.Operator), h.AdminJobRuns},
+		{"/api/admin/pipeline/plan", read(auth.Operator), h.AdminPipelinePlan},
+		{"/api/admin/model-runs", read(auth.Operator), h.AdminModelRuns},
+		{"/api/auth/login", Methods{http.MethodPost: auth.Public}, h.Login},
+		{"/api/auth/logout", Methods{http.MethodPost: auth.Viewer}, h.Logout},
+		{"/api/auth/me", read(auth.Viewer), Me},
+		{"/api/auth/users", Methods{http.MethodGet: auth.Admin, http.MethodPost: auth.Admin}, h.Users},
+		{"/api/auth/users/", Methods{http.MethodGet: auth.Admin, http.MethodPost: auth.Admin, http.MethodPut: auth.Admin}, h.Users},
+		{"/api/auth/keys", Methods{http.MethodGet: auth.Admin, http.MethodPost: auth.Admin}, h.Keys},
+		{"/api/auth/keys/", Methods{http.MethodGet: auth.Admin, http.MethodPost: auth.Admin, http.MethodDelete: auth.Admin}, h.Keys},
+		{"/metrics", read(auth.Viewer), Metrics},
+		{"/healthz", read(auth.Public), Healthz},
+		{"/readyz", read(auth.Public), h.Readyz},
+		{"/api/status", read(auth.Viewer), h.Status},
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"backend/pkg/calculation"
+	structure "backend/pkg/struct"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Scenarios runs a what-if simulation on POST and lists persisted runs on GET
+func (h *Handlers) Scenarios(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		scenarios, err := h.scenarios.List()
+		if err != nil {
+			writeError(w, "Error fetching scenarios", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(scenarios); err != nil {
+			writeError(w, "Error encoding JSON", http.StatusInternalServerError)
+		}
+
+	case http.MethodPost:
+		var req structure.ScenarioRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, fmt.Sprintf("Invalid scenario request: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if err := calculation.ValidateScenario(req); err != nil {
+			writeError(w, fmt.Sprintf("Invalid scenario request: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		response, err := h.calculator.RunScenario(r.Context(), req)
+		if errors.Is(err, calculation.ErrInvalidScenario) {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			writeError(w, fmt.Sprintf("Error running scenario: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			writeError(w, "Error encoding JSON", http.StatusInternalServerError)
+		}
+
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
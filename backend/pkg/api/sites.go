@@ -0,0 +1,207 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sites serves per-site model detail:
+//
+//	GET /api/sites/{site}/feature-importance?model=&run=
+//	GET /api/sites/{site}/outlook
+//	GET /api/sites/{site}/daily?from=YYYY-MM-DD&to=YYYY-MM-DD
+//	GET /api/sites/{site}/intervals?date=YYYY-MM-DD
+//	GET /api/sites/{site}/telemetry?inverter=&limit=
+//	GET /api/sites/{site}/assets/performance?year=&month=
+//	GET /api/sites/{site}/forecast/{year}/{month}/explain
+func (h *Handlers) Sites(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/sites"), "/"), "/")
+	if len(parts) < 2 {
+		NotFound(w, r)
+		return
+	}
+
+	site, err := h.sites.Resolve(parts[0])
+	if err != nil {
+		queryError(w, r, err)
+		return
+	}
+
+	switch {
+	case len(parts) == 2 && parts[1] == "feature-importance":
+		h.siteFeatureImportance(w, r, site)
+	case len(parts) == 2 && parts[1] == "daily":
+		h.siteDailyGeneration(w, r, site)
+	case len(parts) == 2 && parts[1] == "intervals":
+		h.siteIntervalGeneration(w, r, site)
+	case len(parts) == 2 && parts[1] == "telemetry":
+		h.siteTelemetry(w, r, site)
+	case len(parts) == 3 && parts[1] == "assets" && parts[2] == "performance":
+		h.siteAssetPerformance(w, r, site)
+	case len(parts) == 2 && parts[1] == "outlook":
+		h.siteOutlook(w, site)
+	case len(parts) == 5 && parts[1] == "forecast" && parts[4] == "explain":
+		year, yearErr := strconv.Atoi(parts[2])
+		month, monthErr := strconv.Atoi(parts[3])
+		if yearErr != nil || monthErr != nil || month < 1 || month > 12 {
+			writeError(w, "Invalid year or month", http.StatusBadRequest)
+			return
+		}
+		h.forecastExplanation(w, site, year, month)
+	default:
+		NotFound(w, r)
+	}
+}
+
+func (h *Handlers) siteFeatureImportance(w http.ResponseWriter, r *http.Request, site string) {
+	model := r.URL.Query().Get("model")
+	if model == "" {
+		model = "weather_only"
+	}
+
+	var runID int64
+	if value := r.URL.Query().Get("run"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed <= 0 {
+			writeError(w, "Invalid run", http.StatusBadRequest)
+			return
+		}
+		runID = parsed
+	}
+
+	importance, err := h.models.SiteFeatureImportance(site, model, runID)
+	if err != nil {
+		writeError(w, "Error fetching feature importance", http.StatusInternalServerError)
+		return
+	}
+	if importance == nil {
+		writeError(w, "No feature importance for this site and model", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(importance); err != nil {
+		writeError(w, "Error encoding JSON", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handlers) forecastExplanation(w http.ResponseWriter, site string, year, month int) {
+	explanation, err := h.models.Explanation(site, year, month)
+	if err != nil {
+		writeError(w, "Error fetching forecast explanation", http.StatusInternalServerError)
+		return
+	}
+	if explanation == nil {
+		writeError(w, "No forecast explanation for this month", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(explanation); err != nil {
+		writeError(w, "Error encoding JSON", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handlers) siteOutlook(w http.ResponseWriter, site string) {
+	outlook, err := h.outlook.Site(site)
+	if err != nil {
+		writeError(w, "Error fetching outlook forecast", http.StatusInternalServerError)
+		return
+	}
+	if outlook == nil {
+		writeError(w, "No outlook forecast for this site", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(outlook); err != nil {
+		writeError(w, "Error encoding JSON", http.StatusInternalServerError)
+	}
+}
+
+// siteDailyGeneration defaults to the last 30 days when no range is given
+func (h *Handlers) siteDailyGeneration(w http.ResponseWriter, r *http.Request, site string) {
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -30)
+
+	for name, target := range map[string]*time.Time{"from": &from, "to": &to} {
+		if value := r.URL.Query().Get(name); value != "" {
+			parsed, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				writeError(w, "Invalid "+name+" date", http.StatusBadRequest)
+				return
+			}
+			*target = parsed
+		}
+	}
+	if from.After(to) {
+		writeError(w, "from is after to", http.StatusBadRequest)
+		return
+	}
+
+	days, err := h.generation.Daily(site, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		writeError(w, "Error fetching daily generation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(days); err != nil {
+		writeError(w, "Error encoding JSON", http.StatusInternalServerError)
+	}
+}
+
+// siteIntervalGeneration defaults to today
+func (h *Handlers) siteIntervalGeneration(w http.ResponseWriter, r *http.Request, site string) {
+	date := time.Now().UTC().Format("2006-01-02")
+	if value := r.URL.Query().Get("date"); value != "" {
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			writeError(w, "Invalid date", http.StatusBadRequest)
+			return
+		}
+		date = value
+	}
+
+	intervals, err := h.generation.Intervals(site, date)
+	if err != nil {
+		writeError(w, "Error fetching interval generation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(intervals); err != nil {
+		writeError(w, "Error encoding JSON", http.StatusInternalServerError)
+	}
+}
+
+// siteTelemetry returns the latest raw inverter readings, 100 by default
+func (h *Handlers) siteTelemetry(w http.ResponseWriter, r *http.Request, site string) {
+	limit := 100
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 5000 {
+			writeError(w, "limit must be between 1 and 5000", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	readings, err := h.telemetry.Readings(site, r.URL.Query().Get("inverter"), limit)
+	if err != nil {
+		writeError(w, "Error fetching telemetry readings", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(readings); err != nil {
+		writeError(w, "Error encoding JSON", http.StatusInternalServerError)
+	}
+}
@@ -3,18 +3,17 @@ package api
 import (
 	"encoding/json"
 	"net/http"
-	"backend/pkg/db/queries"
 )
 
-func SystemConfiguration(w http.ResponseWriter, r *http.Request) {
-	locations, err := queries.GetLocationData()
+func (h *Handlers) SystemConfiguration(w http.ResponseWriter, r *http.Request) {
+	locations, err := h.sites.List()
 	if err != nil {
-		http.Error(w, "Error fetching location data", http.StatusInternalServerError)
+		writeError(w, "Error fetching location data", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(locations); err != nil {
-		http.Error(w, "Error encoding JSON", http.StatusInternalServerError)
+		writeError(w, "Error encoding JSON", http.StatusInternalServerError)
 	}
 }
@@ -0,0 +1,19 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// TelemetryStatus reports the last poll of every SCADA device and logger directory
+func (h *Handlers) TelemetryStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.poller.Status()); err != nil {
+		writeError(w, "Error encoding JSON", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"backend/pkg/metrics"
+	"backend/pkg/pipeline"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const maxUploadSize = 32 << 20
+
+// uploadBody returns the uploaded file: the multipart "file" field, or the raw request body
+func uploadBody(w http.ResponseWriter, r *http.Request) (io.ReadCloser, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.Body, nil
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, errors.New("missing file field")
+	}
+	return file, nil
+}
+
+// triggerRefresh starts a refresh so derived tables pick up newly uploaded data. If a run is
+// already in progress the refresh is queued behind it, since that run may have read the tables
+// before the upload.
+func (h *Handlers) triggerRefresh(response map[string]interface{}) {
+	runID, err := h.scheduler.Queue(context.Background(), pipeline.RefreshJob)
+	switch {
+	case err == nil:
+		response["runId"] = runID
+	case errors.Is(err, pipeline.ErrJobQueued):
+		response["queued"] = true
+	case !errors.Is(err, pipeline.ErrShuttingDown):
+		slog.Error("Error starting refresh after upload", "err", err)
+	}
+}
+
+// csvUpload handles a POST of a CSV file through importer and triggers a refresh
+func (h *Handlers) csvUpload(w http.ResponseWriter, r *http.Request, importer func(io.Reader) (int, error)) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := uploadBody(w, r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer body.Close()
+
+	rows, err := importer(body)
+	if err != nil {
+		writeError(w, fmt.Sprintf("Invalid CSV: %v", err), http.StatusBadRequest)
+		return
+	}
+	metrics.RecordIngest("upload", nil)
+
+	response := map[string]interface{}{"rows": rows}
+	h.triggerRefresh(response)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// DailyGeneration imports daily meter totals (date, site, energy_kwh) on POST
+func (h *Handlers) DailyGeneration(w http.ResponseWriter, r *http.Request) {
+	h.csvUpload(w, r, h.loader.ImportDailyGenerationCSV)
+}
+
+// IntervalGeneration imports interval meter readings (timestamp, site, energy_kwh, inverter, interval_minutes) on POST
+func (h *Handlers) IntervalGeneration(w http.ResponseWriter, r *http.Request) {
+	h.csvUpload(w, r, h.loader.ImportIntervalGenerationCSV)
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WeatherOutlook lists stored outlooks on GET and imports a CSV outlook on POST. The CSV is
+// sent as the request body or as a multipart "file" field; ?issued_at= (RFC 3339) overrides
+// the issue time, which defaults to now.
+func (h *Handlers) WeatherOutlook(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		issues, err := h.outlook.Issues(50)
+		if err != nil {
+			writeError(w, "Error fetching weather outlooks", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(issues); err != nil {
+			writeError(w, "Error encoding JSON", http.StatusInternalServerError)
+		}
+
+	case http.MethodPost:
+		issuedAt := time.Now().UTC()
+		if value := r.URL.Query().Get("issued_at"); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				writeError(w, "Invalid issued_at", http.StatusBadRequest)
+				return
+			}
+			issuedAt = parsed
+		}
+
+		body, err := uploadBody(w, r)
+		if err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer body.Close()
+
+		rows, err := h.loader.ImportWeatherOutlookCSV(body, issuedAt)
+		if err != nil {
+			writeError(w, fmt.Sprintf("Invalid outlook CSV: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		// The new outlook makes the forecast step stale
+		response := map[string]interface{}{"rows": rows}
+		h.triggerRefresh(response)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(response)
+
+	default:
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
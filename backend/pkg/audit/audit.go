@@ -0,0 +1,193 @@
+package audit
+
+import (
+	"backend/pkg/db"
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"time"
+)
+
+// Change says who made a change and why
+type Change struct {
+	Author string `json:"author"`
+	Reason string `json:"reason"`
+}
+
+// Baseline is recorded against the values already present when a table's history starts
+var Baseline = Change{Author: "system", Reason: "baseline"}
+
+// tracked lists the audited values of each table as (record key, column, value)
+var tracked = []struct {
+	table string
+	query func(d db.Dialect) string
+}{
+	{"monthly_generation", func(d db.Dialect) string {
+		return `
+		SELECT l.name || '/' || ` + d.Period("g.year", "g.month") + `, 'actual_kwh', g.actual_kwh
+		FROM monthly_generation g
+		JOIN locations l ON l.id = g.location_id
+		WHERE g.actual_kwh IS NOT NULL`
+	}},
+	{"locations", func(db.Dialect) string {
+		return `
+		SELECT name, 'installed_capacity_kw', installed_capacity_kw FROM locations WHERE installed_capacity_kw IS NOT NULL
+		UNION ALL
+		SELECT name, 'number_of_panels', number_of_panels FROM locations WHERE number_of_panels IS NOT NULL`
+	}},
+	{"emission_factors", func(db.Dialect) string { return `SELECT name, 'value', value FROM emission_factors` }},
+}
+
+type field struct {
+	key    string
+	column string
+}
+
+// Record appends a revision for every audited value that differs from its latest revision,
+// including values that were removed. Tables are often rewritten wholesale, so comparing against
+// the history rather than the previous row keeps reloads of unchanged data out of it. dialect is
+// that of the database tx belongs to.
+func Record(tx *sql.Tx, dialect db.Dialect, change Change) (int, error) {
+	if change.Author == "" {
+		return 0, fmt.Errorf("a change needs an author")
+	}
+
+	now := time.Now().UTC()
+	stmt, err := tx.Prepare(`
+		INSERT INTO revisions (table_name, record_key, column_name, old_value, new_value, changed_by, reason, changed_at)
+		VALUES (?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("error preparing statement: %v", err)
+	}
+	defer stmt.Close()
+
+	recorded := 0
+	for _, t := range tracked {
+		current, err := values(tx, t.query(dialect))
+		if err != nil {
+			return 0, fmt.Errorf("error reading %s: %v", t.table, err)
+		}
+		latest, err := latestValues(tx, t.table)
+		if err != nil {
+			return 0, fmt.Errorf("error reading %s revisions: %v", t.table, err)
+		}
+
+		by := change
+		if len(latest) == 0 {
+			by = Baseline
+		}
+
+		fields := make([]field, 0, len(current)+len(latest))
+		for f := range current {
+			fields = append(fields, f)
+		}
+		for f, old := range latest {
+			if _, ok := current[f]; !ok && old != nil {
+				fields = append(fields, f)
+			}
+		}
+		sort.Slice(fields, func(i, j int) bool {
+			if fields[i].key != fields[j].key {
+				return fields[i].key < fields[j].key
+			}
+			return fields[i].column < fields[j].column
+		})
+
+		for _, f := range fields {
+			old := latest[f]
+			var value *float64
+			if v, ok := current[f]; ok {
+				value = &v
+			}
+			if same(old, value) {
+				continue
+			}
+			if _, err := stmt.Exec(t.table, f.key, f.column, old, value, by.Author, by.Reason, now); err != nil {
+				return 0, fmt.Errorf("error recording revision of %s %s: %v", t.table, f.key, err)
+			}
+			recorded++
+		}
+	}
+	return recorded, nil
+}
+
+// RecordNow records the current values of database in a transaction of its own
+func RecordNow(ctx context.Context, database *db.DB, change Change) error {
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	recorded, err := Record(tx, database.Dialect, change)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing revisions: %v", err)
+	}
+	if recorded > 0 {
+		slog.Info("Recorded revisions", "revisions", recorded, "author", change.Author)
+	}
+	return nil
+}
+
+func values(tx *sql.Tx, query string) (map[field]float64, error) {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make(map[field]float64)
+	for rows.Next() {
+		var f field
+		var value float64
+		if err := rows.Scan(&f.key, &f.column, &value); err != nil {
+			return nil, err
+		}
+		values[f] = value
+	}
+	return values, rows.Err()
+}
+
+// latestValues returns the newest value of every field of a table; nil for removed ones
+func latestValues(tx *sql.Tx, table string) (map[field]*float64, error) {
+	rows, err := tx.Query(`
+		SELECT r.record_key, r.column_name, r.new_value
+		FROM revisions r
+		JOIN (
+			SELECT MAX(id) AS id FROM revisions WHERE table_name = ? GROUP BY record_key, column_name
+		) latest ON latest.id = r.id
+	`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	latest := make(map[field]*float64)
+	for rows.Next() {
+		var f field
+		var value sql.NullFloat64
+		if err := rows.Scan(&f.key, &f.column, &value); err != nil {
+			return nil, err
+		}
+		latest[f] = nil
+		if value.Valid {
+			v := value.Float64
+			latest[f] = &v
+		}
+	}
+	return latest, rows.Err()
+}
+
+func same(old, value *float64) bool {
+	if old == nil || value == nil {
+		return old == nil && value == nil
+	}
+	return math.Abs(*old-*value) < 0.0005
+}
@@ -0,0 +1,132 @@
+package audit
+
+import (
+	"backend/pkg/db"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// openTestDatabase opens a fresh database in a temporary directory
+func openTestDatabase(t *testing.T) *db.DB {
+	t.Helper()
+
+	database, err := db.Open(filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+type revision struct {
+	Table, Key, Column string
+	Old, New           *float64
+	By                 string
+}
+
+func revisions(t *testing.T, database *db.DB) []revision {
+	t.Helper()
+	rows, err := database.Query(`SELECT table_name, record_key, column_name, old_value, new_value, changed_by FROM revisions ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	got := []revision{}
+	for rows.Next() {
+		var r revision
+		var old, value sql.NullFloat64
+		if err := rows.Scan(&r.Table, &r.Key, &r.Column, &old, &value, &r.By); err != nil {
+			t.Fatal(err)
+		}
+		if old.Valid {
+			r.Old = &old.Float64
+		}
+		if value.Valid {
+			r.New = &value.Float64
+		}
+		got = append(got, r)
+	}
+	return got
+}
+
+func TestRecord(t *testing.T) {
+	kwh := func(v float64) *float64 { return &v }
+	// Tables are recorded in the order they are tracked
+	baseline := []revision{
+		{"monthly_generation", "Awali/2020-01", "actual_kwh", nil, kwh(100), "system"},
+		{"emission_factors", "natural_gas", "value", nil, kwh(400), "system"},
+	}
+	tests := []struct {
+		name    string
+		changes []string
+		want    []revision
+	}{
+		{"baseline", nil, baseline},
+		{"unchanged reload", []string{
+			`DELETE FROM monthly_generation`,
+			`INSERT INTO monthly_generation (year, month, location_id, actual_kwh) VALUES (2020, 1, 1, 100)`,
+		}, baseline},
+		{"changed and added", []string{
+			`UPDATE monthly_generation SET actual_kwh = 110`,
+			`INSERT INTO monthly_generation (year, month, location_id, actual_kwh) VALUES (2020, 2, 1, 90)`,
+		}, []revision{
+			baseline[0], baseline[1],
+			{"monthly_generation", "Awali/2020-01", "actual_kwh", kwh(100), kwh(110), "tester"},
+			{"monthly_generation", "Awali/2020-02", "actual_kwh", nil, kwh(90), "tester"},
+		}},
+		{"removed", []string{`DELETE FROM monthly_generation`}, []revision{
+			baseline[0], baseline[1],
+			{"monthly_generation", "Awali/2020-01", "actual_kwh", kwh(100), nil, "tester"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := openTestDatabase(t)
+			if _, err := database.Exec(`INSERT INTO locations (id, name) VALUES (1, 'Awali')`); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := database.Exec(`INSERT INTO monthly_generation (year, month, location_id, actual_kwh) VALUES (2020, 1, 1, 100)`); err != nil {
+				t.Fatal(err)
+			}
+			if err := RecordNow(context.Background(), database, Change{Author: "loader"}); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, change := range tt.changes {
+				if _, err := database.Exec(change); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := RecordNow(context.Background(), database, Change{Author: "tester"}); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := revisions(t, database); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("revisions = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordNeedsAuthor(t *testing.T) {
+	database := openTestDatabase(t)
+	if err := RecordNow(context.Background(), database, Change{}); err == nil {
+		t.Error("RecordNow() without an author succeeded")
+	}
+}
+
+func TestRevisionsAreAppendOnly(t *testing.T) {
+	database := openTestDatabase(t)
+	if err := RecordNow(context.Background(), database, Change{Author: "tester"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{`UPDATE revisions SET new_value = 0`, `DELETE FROM revisions`} {
+		if _, err := database.Exec(query); err == nil {
+			t.Errorf("%s succeeded", query)
+		}
+	}
+}
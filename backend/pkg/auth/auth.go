@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"backend/pkg/config"
+	"backend/pkg/db"
+	structure "backend/pkg/struct"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Role grants access to a route when it is at least the route's role
+type Role string
+
+// Roles in increasing order of access. Public routes need no credentials at all.
+const (
+	Public   Role = "public"
+	Viewer   Role = "viewer"
+	Analyst  Role = "analyst"
+	Operator Role = "operator"
+	Admin    Role = "admin"
+)
+
+// Authentication methods recorded on a Principal
+const (
+	MethodAnonymous = "anonymous"
+	MethodSession   = "session"
+	MethodAPIKey    = "api_key"
+)
+
+var (
+	ErrUnauthenticated    = errors.New("authentication required")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrInvalidRole        = errors.New("role must be viewer, analyst, operator or admin")
+	ErrInvalidUser        = errors.New("invalid user")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUserExists         = errors.New("username is taken")
+	ErrKeyNotFound        = errors.New("API key not found")
+	ErrWeakPassword       = errors.New("password must be at least 10 characters")
+)
+
+// Store keeps the users, API keys and sessions that requests are authenticated against
+type Store struct {
+	db  *db.DB
+	cfg config.AuthConfig
+
+	secretMu sync.Mutex
+	secret   []byte
+
+	lastUsedMu sync.Mutex
+	lastUsed   map[int]time.Time
+}
+
+// NewStore returns a store on database that signs sessions and grants the anonymous role as cfg says
+func NewStore(database *db.DB, cfg config.AuthConfig) *Store {
+	return &Store{db: database, cfg: cfg, lastUsed: make(map[int]time.Time)}
+}
+
+// Anonymous returns the principal of a request without credentials, or false when they are required
+func (s *Store) Anonymous() (structure.Principal, bool) {
+	if s.cfg.AnonymousRole == "" {
+		return structure.Principal{}, false
+	}
+	return structure.Principal{Name: "anonymous", Role: s.cfg.AnonymousRole, Method: MethodAnonymous}, true
+}
+
+var rank = map[Role]int{Public: 0, Viewer: 1, Analyst: 2, Operator: 3, Admin: 4}
+
+// ParseRole returns the role named s, which must be one a user or key can hold
+func ParseRole(s string) (Role, error) {
+	role := Role(s)
+	if role == Public || rank[role] == 0 {
+		return "", ErrInvalidRole
+	}
+	return role, nil
+}
+
+// Allows reports whether r is enough for a route that requires required
+func (r Role) Allows(required Role) bool {
+	if required == Public {
+		return true
+	}
+	return rank[r] >= rank[required]
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying who the request is made by
+func WithPrincipal(ctx context.Context, p structure.Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns who the request is made by, if anyone was identified
+func FromContext(ctx context.Context) (structure.Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(structure.Principal)
+	return p, ok
+}
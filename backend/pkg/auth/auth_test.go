@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseRole(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Role
+		err  error
+	}{
+		{"viewer", Viewer, nil},
+		{"admin", Admin, nil},
+		{"public", "", ErrInvalidRole},
+		{"Admin", "", ErrInvalidRole},
+		{"", "", ErrInvalidRole},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseRole(tt.s)
+			if got != tt.want || !errors.Is(err, tt.err) {
+				t.Errorf("ParseRole(%q) = %q, %v, want %q, %v", tt.s, got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		role, required Role
+		want           bool
+	}{
+		{Viewer, Viewer, true},
+		{Viewer, Analyst, false},
+		{Operator, Analyst, true},
+		{Admin, Operator, true},
+		{Operator, Admin, false},
+		{"", Public, true},
+		{"", Viewer, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.role)+"/"+string(tt.required), func(t *testing.T) {
+			if got := tt.role.Allows(tt.required); got != tt.want {
+				t.Errorf("%q.Allows(%q) = %v, want %v", tt.role, tt.required, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	structure "backend/pkg/struct"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// keyPrefix starts every API key, so keys are recognisable in logs and secret scanners
+const keyPrefix = "solar_"
+
+// lastUsedInterval limits how often a key's last_used_at is written
+const lastUsedInterval = 5 * time.Minute
+
+func randomString(bytes int) (string, error) {
+	b := make([]byte, bytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating random bytes: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateKey issues an API key. The returned APIKey is the only one that carries the key itself.
+func (s *Store) CreateKey(name string, role Role, createdBy string) (structure.APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return structure.APIKey{}, fmt.Errorf("%w: key name is required", ErrInvalidUser)
+	}
+	if _, err := ParseRole(string(role)); err != nil {
+		return structure.APIKey{}, err
+	}
+
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return structure.APIKey{}, fmt.Errorf("error generating random bytes: %v", err)
+	}
+	prefix := hex.EncodeToString(b)
+	secret, err := randomString(32)
+	if err != nil {
+		return structure.APIKey{}, err
+	}
+	key := keyPrefix + prefix + "_" + secret
+
+	var id int
+	err = s.db.QueryRow(`
+		INSERT INTO api_keys (name, prefix, key_hash, role, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, name, prefix, hashKey(key), string(role), createdBy, time.Now().UTC()).Scan(&id)
+	if err != nil {
+		return structure.APIKey{}, fmt.Errorf("error creating API key: %v", err)
+	}
+
+	created, err := s.GetKey(id)
+	created.Key = key
+	return created, err
+}
+
+// RevokeKey stops a key from authenticating. Revoking twice keeps the first revocation time.
+func (s *Store) RevokeKey(id int) (structure.APIKey, error) {
+	if _, err := s.GetKey(id); err != nil {
+		return structure.APIKey{}, err
+	}
+	_, err := s.db.Exec(`
+		UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL
+	`, time.Now().UTC(), id)
+	if err != nil {
+		return structure.APIKey{}, fmt.Errorf("error revoking API key: %v", err)
+	}
+	return s.GetKey(id)
+}
+
+func (s *Store) GetKey(id int) (structure.APIKey, error) {
+	key, err := scanKey(s.db.QueryRow(`
+		SELECT id, name, prefix, role, created_by, created_at, last_used_at, revoked_at
+		FROM api_keys WHERE id = ?
+	`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return structure.APIKey{}, ErrKeyNotFound
+	}
+	if err != nil {
+		return structure.APIKey{}, fmt.Errorf("error fetching API key: %v", err)
+	}
+	return key, nil
+}
+
+func (s *Store) GetKeys() ([]structure.APIKey, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, prefix, role, created_by, created_at, last_used_at, revoked_at
+		FROM api_keys ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying API keys: %v", err)
+	}
+	defer rows.Close()
+
+	keys := []structure.APIKey{}
+	for rows.Next() {
+		key, err := scanKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning API key: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func scanKey(row scanner) (structure.APIKey, error) {
+	var key structure.APIKey
+	var lastUsed, revoked sql.NullTime
+	if err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Role, &key.CreatedBy, &key.CreatedAt, &lastUsed, &revoked); err != nil {
+		return key, err
+	}
+	if lastUsed.Valid {
+		key.LastUsedAt = &lastUsed.Time
+	}
+	if revoked.Valid {
+		key.RevokedAt = &revoked.Time
+	}
+	return key, nil
+}
+
+// authenticateKey returns the principal of an unrevoked API key
+func (s *Store) authenticateKey(key string) (structure.Principal, error) {
+	var id int
+	var name, role string
+	err := s.db.QueryRow(`
+		SELECT id, name, role FROM api_keys WHERE key_hash = ? AND revoked_at IS NULL
+	`, hashKey(key)).Scan(&id, &name, &role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return structure.Principal{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return structure.Principal{}, fmt.Errorf("error checking API key: %v", err)
+	}
+
+	s.touchKey(id)
+	return structure.Principal{Name: "key:" + name, Role: role, Method: MethodAPIKey}, nil
+}
+
+// touchKey records that a key was used, at most once per lastUsedInterval so that reads do not
+// all turn into writes
+func (s *Store) touchKey(id int) {
+	now := time.Now().UTC()
+	s.lastUsedMu.Lock()
+	if now.Sub(s.lastUsed[id]) < lastUsedInterval {
+		s.lastUsedMu.Unlock()
+		return
+	}
+	s.lastUsed[id] = now
+	s.lastUsedMu.Unlock()
+
+	if _, err := s.db.Exec(`UPDATE api_keys SET last_used_at = ? WHERE id = ?`, now, id); err != nil {
+		s.lastUsedMu.Lock()
+		delete(s.lastUsed, id)
+		s.lastUsedMu.Unlock()
+	}
+}
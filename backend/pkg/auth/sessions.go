@@ -0,0 +1,173 @@
+package auth
+
+import (
+	structure "backend/pkg/struct"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// signingSecret is auth.sessionSecret, or a secret generated on first use and kept in the
+// database so sessions survive restarts
+func (s *Store) signingSecret() ([]byte, error) {
+	s.secretMu.Lock()
+	defer s.secretMu.Unlock()
+	if s.secret != nil {
+		return s.secret, nil
+	}
+
+	if configured := s.cfg.SessionSecret; configured != "" {
+		s.secret = []byte(configured)
+		return s.secret, nil
+	}
+
+	generated, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(`
+		INSERT INTO auth_secret (id, secret, created_at) VALUES (1, ?, ?) ON CONFLICT (id) DO NOTHING
+	`, generated, time.Now().UTC()); err != nil {
+		return nil, fmt.Errorf("error saving session secret: %v", err)
+	}
+	var stored string
+	if err := s.db.QueryRow(`SELECT secret FROM auth_secret WHERE id = 1`).Scan(&stored); err != nil {
+		return nil, fmt.Errorf("error reading session secret: %v", err)
+	}
+	s.secret = []byte(stored)
+	return s.secret, nil
+}
+
+func (s *Store) sign(payload string) (string, error) {
+	key, err := s.signingSecret()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Login checks a username and password and starts a session. The token is the session id and
+// its expiry, signed so that a forged or altered token is rejected before the database is read.
+func (s *Store) Login(username, password string) (structure.Session, error) {
+	user, err := s.checkPassword(username, password)
+	if err != nil {
+		return structure.Session{}, err
+	}
+
+	id, err := randomString(24)
+	if err != nil {
+		return structure.Session{}, err
+	}
+	now := time.Now().UTC()
+	expires := now.Add(time.Duration(s.cfg.SessionTTL)).Truncate(time.Second)
+
+	payload := id + "." + strconv.FormatInt(expires.Unix(), 10)
+	signature, err := s.sign(payload)
+	if err != nil {
+		return structure.Session{}, err
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO sessions (id, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)
+	`, id, user.ID, now, expires)
+	if err != nil {
+		return structure.Session{}, fmt.Errorf("error creating session: %v", err)
+	}
+
+	return structure.Session{Token: payload + "." + signature, ExpiresAt: expires, User: user}, nil
+}
+
+// Logout revokes the session a token belongs to
+func (s *Store) Logout(token string) error {
+	id, err := s.verifyToken(token)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		UPDATE sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL
+	`, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("error ending session: %v", err)
+	}
+	return nil
+}
+
+// verifyToken checks a session token's signature and expiry and returns the session id
+func (s *Store) verifyToken(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ErrInvalidCredentials
+	}
+	payload := parts[0] + "." + parts[1]
+	expected, err := s.sign(payload)
+	if err != nil {
+		return "", err
+	}
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return "", ErrInvalidCredentials
+	}
+
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() >= expires {
+		return "", ErrInvalidCredentials
+	}
+	return parts[0], nil
+}
+
+// authenticateSession returns the user of a live session. The role is read from the user, so a
+// role change applies to sessions already open.
+func (s *Store) authenticateSession(token string) (structure.Principal, error) {
+	id, err := s.verifyToken(token)
+	if err != nil {
+		return structure.Principal{}, err
+	}
+
+	var username, role string
+	err = s.db.QueryRow(`
+		SELECT u.username, u.role
+		FROM sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.id = ? AND s.revoked_at IS NULL AND s.expires_at > ? AND NOT u.disabled
+	`, id, time.Now().UTC()).Scan(&username, &role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return structure.Principal{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return structure.Principal{}, fmt.Errorf("error checking session: %v", err)
+	}
+	return structure.Principal{Name: username, Role: role, Method: MethodSession}, nil
+}
+
+// Credentials returns the token or API key a request carries, from "Authorization: Bearer" or
+// X-API-Key
+func Credentials(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, found := strings.Cut(header, " ")
+		if found && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+// Authenticate identifies who made a request. It returns ErrUnauthenticated when the request
+// carries no credentials and ErrInvalidCredentials when they are wrong, expired or revoked.
+func (s *Store) Authenticate(r *http.Request) (structure.Principal, error) {
+	credentials := Credentials(r)
+	if credentials == "" {
+		return structure.Principal{}, ErrUnauthenticated
+	}
+	if strings.HasPrefix(credentials, keyPrefix) {
+		return s.authenticateKey(credentials)
+	}
+	return s.authenticateSession(credentials)
+}
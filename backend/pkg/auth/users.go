@@ -0,0 +1,196 @@
+package auth
+
+import (
+	structure "backend/pkg/struct"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const minPasswordLength = 10
+
+// dummyHash is compared against when a username does not exist, so a login takes as long for
+// an unknown user as for a wrong password
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", ErrWeakPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return "", fmt.Errorf("%w: password must be at most 72 bytes", ErrWeakPassword)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error hashing password: %v", err)
+	}
+	return string(hash), nil
+}
+
+// CreateUser adds a user. The password and role are required.
+func (s *Store) CreateUser(update structure.UserUpdate) (structure.User, error) {
+	username := strings.TrimSpace(update.Username)
+	if username == "" {
+		return structure.User{}, fmt.Errorf("%w: username is required", ErrInvalidUser)
+	}
+	if update.Password == nil || update.Role == nil {
+		return structure.User{}, fmt.Errorf("%w: password and role are required", ErrInvalidUser)
+	}
+	role, err := ParseRole(*update.Role)
+	if err != nil {
+		return structure.User{}, err
+	}
+	hash, err := hashPassword(*update.Password)
+	if err != nil {
+		return structure.User{}, err
+	}
+	disabled := update.Disabled != nil && *update.Disabled
+
+	var exists bool
+	if err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE username = ?)`, username).Scan(&exists); err != nil {
+		return structure.User{}, fmt.Errorf("error checking username: %v", err)
+	}
+	if exists {
+		return structure.User{}, ErrUserExists
+	}
+
+	now := time.Now().UTC()
+	var id int
+	err = s.db.QueryRow(`
+		INSERT INTO users (username, password_hash, role, disabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, username, hash, string(role), disabled, now, now).Scan(&id)
+	if err != nil {
+		return structure.User{}, fmt.Errorf("error creating user: %v", err)
+	}
+	return s.GetUser(id)
+}
+
+// UpdateUser changes the password, role or disabled flag of a user. Changing the password or
+// disabling the user ends their sessions.
+func (s *Store) UpdateUser(id int, update structure.UserUpdate) (structure.User, error) {
+	if _, err := s.GetUser(id); err != nil {
+		return structure.User{}, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return structure.User{}, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	if update.Role != nil {
+		role, err := ParseRole(*update.Role)
+		if err != nil {
+			return structure.User{}, err
+		}
+		if _, err := tx.Exec(`UPDATE users SET role = ?, updated_at = ? WHERE id = ?`, string(role), now, id); err != nil {
+			return structure.User{}, fmt.Errorf("error updating role: %v", err)
+		}
+	}
+	if update.Password != nil {
+		hash, err := hashPassword(*update.Password)
+		if err != nil {
+			return structure.User{}, err
+		}
+		if _, err := tx.Exec(`UPDATE users SET password_hash = ?, updated_at = ? WHERE id = ?`, hash, now, id); err != nil {
+			return structure.User{}, fmt.Errorf("error updating password: %v", err)
+		}
+	}
+	if update.Disabled != nil {
+		if _, err := tx.Exec(`UPDATE users SET disabled = ?, updated_at = ? WHERE id = ?`, *update.Disabled, now, id); err != nil {
+			return structure.User{}, fmt.Errorf("error updating user: %v", err)
+		}
+	}
+	if update.Password != nil || (update.Disabled != nil && *update.Disabled) {
+		if _, err := tx.Exec(`UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL`, now, id); err != nil {
+			return structure.User{}, fmt.Errorf("error ending sessions: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return structure.User{}, fmt.Errorf("error committing transaction: %v", err)
+	}
+	return s.GetUser(id)
+}
+
+func (s *Store) GetUser(id int) (structure.User, error) {
+	user, err := scanUser(s.db.QueryRow(`
+		SELECT id, username, role, disabled, created_at, updated_at FROM users WHERE id = ?
+	`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return structure.User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return structure.User{}, fmt.Errorf("error fetching user: %v", err)
+	}
+	return user, nil
+}
+
+func (s *Store) GetUsers() ([]structure.User, error) {
+	rows, err := s.db.Query(`
+		SELECT id, username, role, disabled, created_at, updated_at FROM users ORDER BY username
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying users: %v", err)
+	}
+	defer rows.Close()
+
+	users := []structure.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning user: %v", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// HasAdmin reports whether an enabled admin user or an unrevoked admin key exists
+func (s *Store) HasAdmin() (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM users WHERE role = 'admin' AND NOT disabled)
+			OR EXISTS (SELECT 1 FROM api_keys WHERE role = 'admin' AND revoked_at IS NULL)
+	`).Scan(&exists)
+	return exists, err
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row scanner) (structure.User, error) {
+	var user structure.User
+	err := row.Scan(&user.ID, &user.Username, &user.Role, &user.Disabled, &user.CreatedAt, &user.UpdatedAt)
+	return user, err
+}
+
+// checkPassword returns the enabled user with username and password
+func (s *Store) checkPassword(username, password string) (structure.User, error) {
+	var id int
+	var hash string
+	var disabled bool
+	err := s.db.QueryRow(`
+		SELECT id, password_hash, disabled FROM users WHERE username = ?
+	`, strings.TrimSpace(username)).Scan(&id, &hash, &disabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return structure.User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return structure.User{}, fmt.Errorf("error fetching user: %v", err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil || disabled {
+		return structure.User{}, ErrInvalidCredentials
+	}
+	return s.GetUser(id)
+}
@@ -0,0 +1,165 @@
+package calculation
+
+import (
+	structure "backend/pkg/struct"
+	"fmt"
+	"math"
+)
+
+type asset struct {
+	ID       int
+	Site     string
+	Kind     string
+	Capacity float64 // 0 when unknown
+}
+
+// availabilityTotal accumulates inverter availability for the site above them. Inverters are
+// weighted by capacity when all of them have one, and equally otherwise.
+type availabilityTotal struct {
+	weighted, weights float64
+	unweighted        float64
+	count             int
+	missingCapacity   bool
+}
+
+func (t *availabilityTotal) add(availability, capacity float64) {
+	t.weighted += availability * capacity
+	t.weights += capacity
+	t.unweighted += availability
+	t.count++
+	if capacity <= 0 {
+		t.missingCapacity = true
+	}
+}
+
+func (t *availabilityTotal) value() *float64 {
+	if t == nil || t.count == 0 {
+		return nil
+	}
+	if t.missingCapacity || t.weights == 0 {
+		return roundPtr(t.unweighted/float64(t.count), 4)
+	}
+	return roundPtr(t.weighted/t.weights, 4)
+}
+
+// CalculateAssetPerformance computes performance ratio, capacity factor, specific yield and
+// availability for every asset by day and by month. An asset's expected output is the site's
+// theoretical output scaled by its share of the site capacity, so assets without a capacity get
+// availability only. Site availability is the availability of the inverters under it.
+func (c *Calculator) CalculateAssetPerformance() error {
+	assets, siteCapacity, err := c.getAssets()
+	if err != nil {
+		return fmt.Errorf("error getting assets: %v", err)
+	}
+
+	var metrics [2][]structure.AssetPerformanceRow
+	for i, monthly := range []bool{false, true} {
+		generation, err := c.assets.Generation(monthly)
+		if err != nil {
+			return fmt.Errorf("error querying asset generation: %v", err)
+		}
+		siteGeneration, err := c.assets.SiteGeneration(monthly)
+		if err != nil {
+			return fmt.Errorf("error querying site generation: %v", err)
+		}
+		metrics[i] = assetMetrics(assets, siteCapacity, generation, siteGeneration, monthly)
+	}
+
+	return c.assets.ReplacePerformance(metrics[0], metrics[1])
+}
+
+// assetMetrics returns the performance of every asset over the periods of generation, followed
+// by the site assets over the periods of siteGeneration
+func assetMetrics(assets map[int]asset, siteCapacity map[string]float64, generation, siteGeneration []structure.AssetGeneration, monthly bool) []structure.AssetPerformanceRow {
+	var metrics []structure.AssetPerformanceRow
+	sites := make(map[string]*availabilityTotal)
+	for _, g := range generation {
+		a := assets[g.AssetID]
+
+		m := newAssetMetrics(a, g, siteCapacity[a.Site], periodHours(g.Period, monthly))
+		if g.Daylight != nil && *g.Daylight > 0 {
+			producing := 0
+			if g.Producing != nil {
+				producing = *g.Producing
+			}
+			m.Availability = roundPtr(float64(producing)/float64(*g.Daylight), 4)
+			if a.Kind == "inverter" {
+				key := g.Period + "/" + a.Site
+				if sites[key] == nil {
+					sites[key] = &availabilityTotal{}
+				}
+				sites[key].add(*m.Availability, a.Capacity)
+			}
+		}
+		metrics = append(metrics, m)
+	}
+
+	for _, g := range siteGeneration {
+		a := assets[g.AssetID]
+
+		m := newAssetMetrics(a, g, a.Capacity, periodHours(g.Period, monthly))
+		m.Availability = sites[g.Period+"/"+a.Site].value()
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+// periodHours returns the hours in a day, or in a month given as YYYY-MM
+func periodHours(period string, monthly bool) int {
+	if !monthly {
+		return 24
+	}
+	var year, month int
+	fmt.Sscanf(period, "%d-%d", &year, &month)
+	return getHoursInMonth(year, month)
+}
+
+// newAssetMetrics scales the site's theoretical output to the asset's share of the site capacity
+func newAssetMetrics(a asset, g structure.AssetGeneration, siteCapacity float64, hours int) structure.AssetPerformanceRow {
+	actual := g.Actual
+	m := structure.AssetPerformanceRow{AssetID: a.ID}
+	m.Period = g.Period
+	m.Actual = &actual
+	if a.Capacity <= 0 {
+		return m
+	}
+
+	m.CapacityFactor = roundPtr(actual/(a.Capacity*float64(hours)), 3)
+	m.SpecificYield = roundPtr(actual/a.Capacity, 3)
+	if g.SiteTheoretical != nil && siteCapacity > 0 {
+		expected := *g.SiteTheoretical * a.Capacity / siteCapacity
+		m.Expected = roundPtr(expected, 2)
+		if expected > 0 {
+			m.PerformanceRatio = roundPtr(actual/expected, 3)
+		}
+	}
+	return m
+}
+
+// getAssets returns every asset and the capacity of each site asset by site
+func (c *Calculator) getAssets() (map[int]asset, map[string]float64, error) {
+	list, err := c.assets.List()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	assets := make(map[int]asset)
+	siteCapacity := make(map[string]float64)
+	for _, a := range list {
+		capacity := 0.0
+		if a.CapacityKW != nil {
+			capacity = *a.CapacityKW
+		}
+		assets[a.ID] = asset{ID: a.ID, Site: a.Site, Kind: a.Kind, Capacity: capacity}
+		if a.Kind == "site" {
+			siteCapacity[a.Site] = capacity
+		}
+	}
+	return assets, siteCapacity, nil
+}
+
+func roundPtr(value float64, places int) *float64 {
+	scale := math.Pow10(places)
+	rounded := math.Round(value*scale) / scale
+	return &rounded
+}
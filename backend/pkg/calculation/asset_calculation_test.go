@@ -0,0 +1,162 @@
+package calculation
+
+import (
+	"backend/pkg/repository/memory"
+	structure "backend/pkg/struct"
+	"testing"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func floatValue(v *float64) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+func TestAssetMetrics(t *testing.T) {
+	inverter := func(id int, actual float64, producing, daylight *int) structure.AssetGeneration {
+		return structure.AssetGeneration{AssetID: id, Period: "2019-01", Actual: actual, SiteTheoretical: ptr(150000.0),
+			Producing: producing, Daylight: daylight}
+	}
+	site := structure.AssetGeneration{AssetID: 1, Period: "2019-01", Actual: 80000, SiteTheoretical: ptr(150000.0)}
+
+	tests := []struct {
+		name             string
+		capacities       [2]float64
+		generation       []structure.AssetGeneration
+		siteAvailability interface{}
+	}{
+		{"weighted by capacity", [2]float64{600, 400},
+			[]structure.AssetGeneration{inverter(2, 50000, ptr(300), ptr(400)), inverter(3, 30000, ptr(400), ptr(400))}, 0.85},
+		{"equal when a capacity is missing", [2]float64{600, 0},
+			[]structure.AssetGeneration{inverter(2, 50000, ptr(300), ptr(400)), inverter(3, 30000, ptr(400), ptr(400))}, 0.875},
+		{"no intervals counts as down", [2]float64{600, 400},
+			[]structure.AssetGeneration{inverter(2, 50000, nil, ptr(400)), inverter(3, 30000, ptr(400), ptr(400))}, 0.4},
+		{"none without daylight", [2]float64{600, 400},
+			[]structure.AssetGeneration{inverter(2, 50000, nil, nil), inverter(3, 30000, nil, nil)}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assets := map[int]asset{
+				1: {ID: 1, Site: "Awali", Kind: "site", Capacity: 1000},
+				2: {ID: 2, Site: "Awali", Kind: "inverter", Capacity: tt.capacities[0]},
+				3: {ID: 3, Site: "Awali", Kind: "inverter", Capacity: tt.capacities[1]},
+			}
+			metrics := assetMetrics(assets, map[string]float64{"Awali": 1000}, tt.generation, []structure.AssetGeneration{site}, true)
+			if len(metrics) != 3 {
+				t.Fatalf("got %d rows, want 3", len(metrics))
+			}
+
+			first := metrics[0]
+			if floatValue(first.Expected) != 90000.0 || floatValue(first.PerformanceRatio) != 0.556 ||
+				floatValue(first.CapacityFactor) != 0.112 || floatValue(first.SpecificYield) != 83.333 {
+				t.Errorf("inverter 2 = expected %v, ratio %v, capacity factor %v, yield %v", floatValue(first.Expected),
+					floatValue(first.PerformanceRatio), floatValue(first.CapacityFactor), floatValue(first.SpecificYield))
+			}
+
+			last := metrics[2]
+			if last.AssetID != 1 || floatValue(last.PerformanceRatio) != 0.533 {
+				t.Errorf("site = asset %d with ratio %v, want asset 1 with 0.533", last.AssetID, floatValue(last.PerformanceRatio))
+			}
+			if got := floatValue(last.Availability); got != tt.siteAvailability {
+				t.Errorf("site availability = %v, want %v", got, tt.siteAvailability)
+			}
+		})
+	}
+}
+
+func TestAssetMetricsWithoutCapacity(t *testing.T) {
+	assets := map[int]asset{4: {ID: 4, Site: "Awali", Kind: "string"}}
+	generation := []structure.AssetGeneration{{AssetID: 4, Period: "2019-01-05", Actual: 120, SiteTheoretical: ptr(5000.0),
+		Producing: ptr(40), Daylight: ptr(48)}}
+
+	metrics := assetMetrics(assets, map[string]float64{"Awali": 1000}, generation, nil, false)
+	if len(metrics) != 1 {
+		t.Fatalf("got %d rows, want 1", len(metrics))
+	}
+	m := metrics[0]
+	if floatValue(m.Actual) != 120.0 || floatValue(m.Availability) != 0.8333 {
+		t.Errorf("string = actual %v, availability %v, want 120 and 0.8333", floatValue(m.Actual), floatValue(m.Availability))
+	}
+	if m.Expected != nil || m.PerformanceRatio != nil || m.CapacityFactor != nil || m.SpecificYield != nil {
+		t.Errorf("string without capacity has capacity-based metrics: %+v", m.AssetPerformance)
+	}
+}
+
+func TestAvailabilityTotal(t *testing.T) {
+	type inverter struct {
+		availability, capacity float64
+	}
+	tests := []struct {
+		name      string
+		inverters []inverter
+		want      interface{}
+	}{
+		{"no inverters", nil, nil},
+		{"weighted by capacity", []inverter{{0.75, 600}, {1, 400}}, 0.85},
+		{"equal when a capacity is missing", []inverter{{0.75, 600}, {1, 0}}, 0.875},
+		{"one inverter down", []inverter{{0, 600}, {1, 400}}, 0.4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var total availabilityTotal
+			for _, inv := range tt.inverters {
+				total.add(inv.availability, inv.capacity)
+			}
+			if got := floatValue(total.value()); got != tt.want {
+				t.Errorf("value() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	var missing *availabilityTotal
+	if got := missing.value(); got != nil {
+		t.Errorf("value() of a site without inverters = %v, want nil", *got)
+	}
+}
+
+func TestPeriodHours(t *testing.T) {
+	tests := []struct {
+		period  string
+		monthly bool
+		want    int
+	}{
+		{"2019-01-31", false, 24},
+		{"2019-02", true, 672},
+		{"2020-02", true, 696},
+	}
+	for _, tt := range tests {
+		if got := periodHours(tt.period, tt.monthly); got != tt.want {
+			t.Errorf("periodHours(%q, %v) = %d, want %d", tt.period, tt.monthly, got, tt.want)
+		}
+	}
+}
+
+func TestCalculateAssetPerformance(t *testing.T) {
+	data := &memory.Data{
+		Assets: []structure.Asset{
+			{ID: 1, Site: "Awali", Kind: "site", Code: "Awali", CapacityKW: ptr(1000.0)},
+			{ID: 2, Site: "Awali", ParentID: ptr(1), Kind: "inverter", Code: "INV1", CapacityKW: ptr(500.0)},
+		},
+		AssetGeneration: []structure.AssetGeneration{
+			{AssetID: 2, Period: "2019-01-05", Actual: 2000, SiteTheoretical: ptr(5000.0)},
+			{AssetID: 2, Period: "2019-01", Actual: 60000, SiteTheoretical: ptr(150000.0)},
+			{AssetID: 1, Period: "2019-01", Actual: 120000, SiteTheoretical: ptr(150000.0)},
+		},
+	}
+	if err := newTestCalculator(data).CalculateAssetPerformance(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data.AssetDaily) != 1 || floatValue(data.AssetDaily[0].PerformanceRatio) != 0.8 {
+		t.Errorf("daily = %+v, want the inverter at 0.8", data.AssetDaily)
+	}
+	if len(data.AssetMonthly) != 2 || floatValue(data.AssetMonthly[0].PerformanceRatio) != 0.8 ||
+		data.AssetMonthly[1].AssetID != 1 || floatValue(data.AssetMonthly[1].PerformanceRatio) != 0.8 {
+		t.Errorf("monthly = %+v, want the inverter then the site at 0.8", data.AssetMonthly)
+	}
+}
@@ -0,0 +1,56 @@
+package calculation
+
+import (
+	structure "backend/pkg/struct"
+	"fmt"
+	"math"
+)
+
+// CalculateDailyTheoreticalOutput fills theoretical_kwh for every day that has a daily actual and
+// weather that is checked or imputed
+func (c *Calculator) CalculateDailyTheoreticalOutput() error {
+	locations, err := c.sites.List()
+	if err != nil {
+		return fmt.Errorf("error getting locations: %v", err)
+	}
+
+	weather, err := c.weather.FilledDays()
+	if err != nil {
+		return fmt.Errorf("error querying daily weather: %v", err)
+	}
+
+	// Days whose weather is quarantined or missing, and was not imputed, have no theoretical
+	// output, so they are left out and cleared
+	days := make([]structure.SiteDay, 0, len(weather)*len(locations))
+	for _, day := range weather {
+		for _, loc := range locations {
+			days = append(days, structure.SiteDay{
+				Date:        day.Date,
+				LocationID:  loc.ID,
+				Theoretical: math.Round(theoreticalDailyOutput(loc.InstalledCapacity, inverterEfficiency, day.Sunshine, day.Irradiance)*100) / 100,
+			})
+		}
+	}
+
+	return c.generation.ReplaceDailyTheoretical(days)
+}
+
+// CalculateDailyPerformance computes the monthly metrics over single days
+func (c *Calculator) CalculateDailyPerformance() error {
+	locations, err := c.locationsByID()
+	if err != nil {
+		return err
+	}
+
+	days, err := c.generation.FilledDays()
+	if err != nil {
+		return fmt.Errorf("error querying daily generation: %v", err)
+	}
+
+	for i, day := range days {
+		days[i].PerformanceRatio, days[i].CapacityFactor, days[i].OutputPerPV =
+			performanceMetrics(locations[day.LocationID], day.Actual, day.Theoretical, 24)
+	}
+
+	return c.performance.ReplaceDaily(days)
+}
@@ -1,24 +1,55 @@
 package calculation
 
 import (
-	"backend/pkg/db/queries"
+	"backend/pkg/repository"
 	"fmt"
+	"log/slog"
 	"strings"
 )
 
-func CO2Offset() (float64, float64,float64, float64) {
-	totalUOB, totalRefinery, totalAwali := queries.TotalPowerGeneration()
+const carbonIntensityNaturalGas = 400.0 // gCO2/kWh, used when the emission factor is missing
 
-	const carbonIntensityNaturalGas = 400.0 // gCO2/kWh
+// CarbonIntensity returns a function reading the natural gas emission factor in gCO2/kWh from
+// factors, so an edited factor applies from the next calculation
+func CarbonIntensity(factors repository.EmissionFactorRepo) func() float64 {
+	return func() float64 {
+		factor, err := factors.Get(repository.EmissionFactorNaturalGas)
+		if err != nil {
+			slog.Warn("Error loading emission factor, using the default", "default_gco2_kwh", carbonIntensityNaturalGas, "err", err)
+			return carbonIntensityNaturalGas
+		}
+		return factor.Value
+	}
+}
+
+// Environment works out the CO2 offset of the generation to date
+type Environment struct {
+	generation repository.GenerationRepo
+	intensity  func() float64
+}
+
+// NewEnvironment reads generation from generation, and the carbon intensity in gCO2/kWh of the
+// gas generation it displaces from intensity, which is CarbonIntensity in the server
+func NewEnvironment(generation repository.GenerationRepo, intensity func() float64) *Environment {
+	return &Environment{generation: generation, intensity: intensity}
+}
+
+// CO2Offset returns the kg of CO2 offset to date at Awali, Refinery and UOB, and in total
+func (e *Environment) CO2Offset() (float64, float64, float64, float64, error) {
+	totals, err := e.generation.Totals()
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+	intensity := e.intensity()
 
     // To calculate CO2 offsets in kilograms
-    co2OffsetUOB := (totalUOB * carbonIntensityNaturalGas) / 1000.0 // kg CO2
-    co2OffsetRefinery := (totalRefinery * carbonIntensityNaturalGas) / 1000.0 // kg CO2
-    co2OffsetAwali := (totalAwali * carbonIntensityNaturalGas) / 1000.0 // kg CO2
+    co2OffsetUOB := (totals["UOB"] * intensity) / 1000.0 // kg CO2
+    co2OffsetRefinery := (totals["Refinery"] * intensity) / 1000.0 // kg CO2
+    co2OffsetAwali := (totals["Awali"] * intensity) / 1000.0 // kg CO2
 
     // Total CO2 offset
     totalCO2Offset := co2OffsetUOB + co2OffsetRefinery + co2OffsetAwali
-	return co2OffsetAwali, co2OffsetRefinery, co2OffsetUOB, totalCO2Offset
+	return co2OffsetAwali, co2OffsetRefinery, co2OffsetUOB, totalCO2Offset, nil
 }
 
 func EquivalentTrees(co2OffsetAwali, co2OffsetRefinery, co2OffsetUOB float64) (float64, float64, float64, float64) {
@@ -0,0 +1,65 @@
+package calculation
+
+import (
+	"backend/pkg/repository"
+	"backend/pkg/repository/memory"
+	structure "backend/pkg/struct"
+	"testing"
+)
+
+func TestCarbonIntensity(t *testing.T) {
+	tests := []struct {
+		name    string
+		factors []structure.EmissionFactor
+		want    float64
+	}{
+		{"stored factor", []structure.EmissionFactor{{Name: repository.EmissionFactorNaturalGas, Value: 450}}, 450},
+		{"missing factor uses the default", nil, carbonIntensityNaturalGas},
+		{"other factors are ignored", []structure.EmissionFactor{{Name: "diesel", Value: 700}}, carbonIntensityNaturalGas},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repos := memory.New(&memory.Data{EmissionFactors: tt.factors})
+			if got := CarbonIntensity(repos.EmissionFactors)(); got != tt.want {
+				t.Errorf("CarbonIntensity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCO2Offset(t *testing.T) {
+	repos := memory.New(&memory.Data{
+		Sites: testSites(),
+		Generation: map[string][]structure.ForecastResult{
+			"Awali":        {{Year: 2019, Month: 1, Actual: 1000}, {Year: 2019, Month: 2, Actual: 1500}},
+			"Refinery":     {{Year: 2019, Month: 1, Actual: 4000}},
+			"UOB":          {{Year: 2019, Month: 1, Actual: 500}},
+			"Total System": {{Year: 2019, Month: 1, Actual: 5500}},
+		},
+	})
+
+	awali, refinery, uob, total, err := NewEnvironment(repos.Generation, func() float64 { return 400 }).CO2Offset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Total System is the sum of the sites, so it is not counted again
+	if awali != 1000 || refinery != 1600 || uob != 200 || total != 2800 {
+		t.Errorf("CO2Offset() = %v, %v, %v, %v, want 1000, 1600, 200, 2800", awali, refinery, uob, total)
+	}
+}
+
+func TestFormatCO2Number(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{0, "0 kg"},
+		{999.4, "999 kg"},
+		{1234567, "1,234,567 kg"},
+	}
+	for _, tt := range tests {
+		if got := FormatCO2Number(tt.value); got != tt.want {
+			t.Errorf("FormatCO2Number(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
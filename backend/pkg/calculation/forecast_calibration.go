@@ -0,0 +1,67 @@
+package calculation
+
+import (
+	structure "backend/pkg/struct"
+	"fmt"
+	"math"
+)
+
+// nominalCoverage is the share of actuals expected to fall between P10 and P90
+const nominalCoverage = 0.8
+
+// CalculateForecastCalibration backtests the forecast quantiles against actual generation
+// and stores how often each location's actuals landed below P10/P50/P90 and inside the band.
+func (c *Calculator) CalculateForecastCalibration() error {
+	backtest, err := c.generation.Backtest()
+	if err != nil {
+		return fmt.Errorf("error querying forecast quantiles: %v", err)
+	}
+
+	return c.generation.ReplaceCalibration(calibrate(backtest))
+}
+
+// calibrate returns the share of each location's actuals below each quantile and inside the band
+func calibrate(backtest []structure.ForecastBacktest) map[int]structure.ForecastCalibration {
+	type counts struct {
+		inside, belowP10, belowP50, belowP90, total int
+	}
+	byLocation := make(map[int]*counts)
+
+	for _, month := range backtest {
+		c, ok := byLocation[month.LocationID]
+		if !ok {
+			c = &counts{}
+			byLocation[month.LocationID] = c
+		}
+
+		c.total++
+		if month.Actual < month.P10 {
+			c.belowP10++
+		}
+		if month.Actual < month.P50 {
+			c.belowP50++
+		}
+		if month.Actual < month.P90 {
+			c.belowP90++
+		}
+		if month.Actual >= month.P10 && month.Actual <= month.P90 {
+			c.inside++
+		}
+	}
+
+	calibration := make(map[int]structure.ForecastCalibration, len(byLocation))
+	for locationID, c := range byLocation {
+		share := func(n int) float64 {
+			return math.Round(float64(n)/float64(c.total)*10000) / 10000 // 4 decimal places
+		}
+		calibration[locationID] = structure.ForecastCalibration{
+			NominalCoverage:  nominalCoverage,
+			IntervalCoverage: share(c.inside),
+			BelowP10:         share(c.belowP10),
+			BelowP50:         share(c.belowP50),
+			BelowP90:         share(c.belowP90),
+			SampleCount:      c.total,
+		}
+	}
+	return calibration
+}
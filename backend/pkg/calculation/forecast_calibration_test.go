@@ -0,0 +1,63 @@
+package calculation
+
+import (
+	"backend/pkg/repository/memory"
+	structure "backend/pkg/struct"
+	"reflect"
+	"testing"
+)
+
+func TestCalibrate(t *testing.T) {
+	band := func(locationID int, actual float64) structure.ForecastBacktest {
+		return structure.ForecastBacktest{LocationID: locationID, Actual: actual, P10: 60, P50: 80, P90: 100}
+	}
+	tests := []struct {
+		name     string
+		backtest []structure.ForecastBacktest
+		want     map[int]structure.ForecastCalibration
+	}{
+		{"no backtest", nil, map[int]structure.ForecastCalibration{}},
+		{"one month inside the band", []structure.ForecastBacktest{band(1, 70)}, map[int]structure.ForecastCalibration{
+			1: {NominalCoverage: 0.8, IntervalCoverage: 1, BelowP10: 0, BelowP50: 1, BelowP90: 1, SampleCount: 1},
+		}},
+		{"a month in each part of the band", []structure.ForecastBacktest{band(1, 50), band(1, 70), band(1, 90), band(1, 120)},
+			map[int]structure.ForecastCalibration{
+				1: {NominalCoverage: 0.8, IntervalCoverage: 0.5, BelowP10: 0.25, BelowP50: 0.5, BelowP90: 0.75, SampleCount: 4},
+			}},
+		{"the band's edges are inside it", []structure.ForecastBacktest{band(1, 60), band(1, 100)}, map[int]structure.ForecastCalibration{
+			1: {NominalCoverage: 0.8, IntervalCoverage: 1, BelowP10: 0, BelowP50: 0.5, BelowP90: 0.5, SampleCount: 2},
+		}},
+		{"locations are kept apart", []structure.ForecastBacktest{band(1, 50), band(2, 70), band(2, 110), band(2, 90)},
+			map[int]structure.ForecastCalibration{
+				1: {NominalCoverage: 0.8, IntervalCoverage: 0, BelowP10: 1, BelowP50: 1, BelowP90: 1, SampleCount: 1},
+				2: {NominalCoverage: 0.8, IntervalCoverage: 0.6667, BelowP10: 0, BelowP50: 0.3333, BelowP90: 0.6667, SampleCount: 3},
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calibrate(tt.backtest); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("calibrate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculateForecastCalibration(t *testing.T) {
+	data := &memory.Data{
+		Backtest: []structure.ForecastBacktest{{LocationID: 2, Actual: 70, P10: 60, P50: 80, P90: 100}},
+		Calibration: map[string]structure.ForecastCalibration{
+			"Awali": {SampleCount: 12},
+		},
+	}
+	if err := newTestCalculator(data).CalculateForecastCalibration(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Locations without a backtest lose their old calibration
+	want := map[string]structure.ForecastCalibration{
+		"Refinery": {NominalCoverage: 0.8, IntervalCoverage: 1, BelowP50: 1, BelowP90: 1, SampleCount: 1},
+	}
+	if !reflect.DeepEqual(data.Calibration, want) {
+		t.Errorf("calibration = %+v, want %+v", data.Calibration, want)
+	}
+}
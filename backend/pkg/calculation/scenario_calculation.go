@@ -0,0 +1,454 @@
+package calculation
+
+import (
+	"backend/pkg/model"
+	structure "backend/pkg/struct"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+)
+
+const (
+	siteLatitude           = 26.0   // same point the weather archive is fetched for
+	temperatureCoefficient = -0.004 // power change per °C for crystalline silicon
+)
+
+// scenarioSite is a location with the parameters the scenario is allowed to change
+type scenarioSite struct {
+	structure.Location
+	tilt       float64
+	efficiency float64
+	losses     float64
+}
+
+// ErrInvalidScenario is returned when a scenario's changes cannot be applied to the sites
+var ErrInvalidScenario = errors.New("invalid scenario")
+
+type generationKey struct {
+	year, month, locationID int
+}
+
+type forecastKey struct {
+	year, month int
+	location    string
+}
+
+// scenarioTotals accumulates figures before they are rounded into a ScenarioFigures
+type scenarioTotals struct {
+	generation, theoretical, forecast float64
+	hasForecast                       bool
+}
+
+func (t *scenarioTotals) add(generation, theoretical float64, forecast *float64) {
+	t.generation += generation
+	t.theoretical += theoretical
+	if forecast != nil {
+		t.forecast += *forecast
+		t.hasForecast = true
+	}
+}
+
+// figures rounds the totals, converting generation to CO2 at intensity gCO2/kWh
+func (t *scenarioTotals) figures(intensity float64) structure.ScenarioFigures {
+	figures := structure.ScenarioFigures{
+		GenerationKWH:  math.Round(t.generation*100) / 100,
+		TheoreticalKWH: math.Round(t.theoretical*100) / 100,
+		CO2OffsetKg:    math.Round(t.generation*intensity/1000.0*100) / 100,
+	}
+	if t.theoretical > 0 {
+		figures.PerformanceRatio = math.Round((t.generation/t.theoretical)*1000) / 1000
+	}
+	if t.hasForecast {
+		forecast := math.Round(t.forecast*100) / 100
+		figures.ForecastKWH = &forecast
+	}
+	return figures
+}
+
+type scenarioPair struct {
+	baseline, scenario scenarioTotals
+}
+
+// ValidateScenario checks a scenario request before it is run
+func ValidateScenario(req structure.ScenarioRequest) error {
+	if req.StartYear != 0 && req.EndYear != 0 && req.StartYear > req.EndYear {
+		return fmt.Errorf("startYear %d is after endYear %d", req.StartYear, req.EndYear)
+	}
+
+	seen := make(map[string]bool)
+	for _, site := range req.Sites {
+		switch site.Location {
+		case "Awali", "Refinery", "UOB":
+		default:
+			return fmt.Errorf("unknown location %q", site.Location)
+		}
+		if seen[site.Location] {
+			return fmt.Errorf("location %s is modified more than once", site.Location)
+		}
+		seen[site.Location] = true
+
+		if site.CapacityKW != nil && *site.CapacityKW < 0 {
+			return fmt.Errorf("capacityKw for %s must not be negative", site.Location)
+		}
+		if site.CapacityKW != nil && *site.CapacityKW+site.AddedCapacityKW < 0 {
+			return fmt.Errorf("capacityKw plus addedCapacityKw for %s must not be negative", site.Location)
+		}
+		if site.NumberOfPanels != nil && *site.NumberOfPanels < 0 {
+			return fmt.Errorf("numberOfPanels for %s must not be negative", site.Location)
+		}
+		if site.NumberOfPanels != nil && *site.NumberOfPanels+site.AddedPanels < 0 {
+			return fmt.Errorf("numberOfPanels plus addedPanels for %s must not be negative", site.Location)
+		}
+		// Panel changes are turned into capacity, so the two can't both be set
+		changesCapacity := site.CapacityKW != nil || site.AddedCapacityKW != 0
+		changesPanels := site.NumberOfPanels != nil || site.AddedPanels != 0
+		if changesCapacity && changesPanels {
+			return fmt.Errorf("set either the capacity or the panel count of %s, not both", site.Location)
+		}
+		if site.TiltDegrees != nil && (*site.TiltDegrees < 0 || *site.TiltDegrees > 90) {
+			return fmt.Errorf("tiltDegrees for %s must be between 0 and 90", site.Location)
+		}
+		if site.InverterEfficiency != nil && (*site.InverterEfficiency <= 0 || *site.InverterEfficiency > 1) {
+			return fmt.Errorf("inverterEfficiency for %s must be in (0, 1]", site.Location)
+		}
+		if site.AdditionalLosses < 0 || site.AdditionalLosses >= 1 {
+			return fmt.Errorf("additionalLosses for %s must be in [0, 1)", site.Location)
+		}
+	}
+
+	if req.Weather != nil {
+		if req.Weather.IrradianceScale < 0 || req.Weather.SunshineScale < 0 {
+			return fmt.Errorf("weather scales must not be negative")
+		}
+		for _, month := range req.Weather.Months {
+			if month < 1 || month > 12 {
+				return fmt.Errorf("invalid month %d", month)
+			}
+		}
+	}
+
+	return nil
+}
+
+// RunScenario projects generation, performance ratio and CO2 offset for the historical
+// weather record under both the current configuration and the requested changes. Only months
+// with actual generation are projected, since each site is assumed to keep performing against
+// theory the way it did that month. The forecast comes from running the active forecast model
+// over the recorded and the perturbed weather.
+// Nothing is written to the database unless req.Persist is set, and then only to the scenarios table.
+// Changes that leave a site with negative capacity or panels return ErrInvalidScenario.
+func (c *Calculator) RunScenario(ctx context.Context, req structure.ScenarioRequest) (structure.ScenarioResponse, error) {
+	response := structure.ScenarioResponse{Name: req.Name}
+
+	locations, err := c.sites.List()
+	if err != nil {
+		return response, fmt.Errorf("error getting locations: %v", err)
+	}
+
+	weather, err := c.getScenarioWeather(req.StartYear, req.EndYear)
+	if err != nil {
+		return response, err
+	}
+
+	generation, err := c.getScenarioGeneration()
+	if err != nil {
+		return response, err
+	}
+
+	modifications := make(map[string]structure.SiteModification)
+	for _, site := range req.Sites {
+		modifications[site.Location] = site
+	}
+
+	var baselineSites, scenarioSites []scenarioSite
+	for _, loc := range locations {
+		if loc.Name == "Total System" {
+			continue
+		}
+		base := scenarioSite{Location: loc, tilt: siteLatitude, efficiency: inverterEfficiency}
+		scen, err := applySiteModification(base, modifications[loc.Name])
+		if err != nil {
+			return response, err
+		}
+		baselineSites = append(baselineSites, base)
+		scenarioSites = append(scenarioSites, scen)
+	}
+
+	// Without the model the theoretical projection still stands, so a failed run only leaves
+	// the forecast out
+	forecasts, err := c.scenarioForecasts(ctx, req.Weather)
+	if err != nil {
+		slog.Warn("Scenario forecast unavailable", "err", err)
+		response.ForecastError = err.Error()
+	}
+	intensity := c.intensity()
+
+	var yearOrder []int
+	yearly := make(map[int]map[string]*scenarioPair)
+
+	for _, wm := range weather {
+		monthTotal := &scenarioPair{}
+
+		if _, ok := yearly[wm.Year]; !ok {
+			yearOrder = append(yearOrder, wm.Year)
+			yearly[wm.Year] = make(map[string]*scenarioPair)
+		}
+
+		sunshine, irradiance, temperatureDelta := perturbWeather(wm, req.Weather)
+
+		projected := false
+		for i, base := range baselineSites {
+			scen := scenarioSites[i]
+			actual, ok := generation[generationKey{wm.Year, wm.Month, base.ID}]
+			baseReference := theoreticalMonthlyOutput(base.InstalledCapacity, inverterEfficiency, wm.Sunshine, wm.Irradiance, wm.Days)
+			if !ok || baseReference <= 0 {
+				continue
+			}
+			projected = true
+
+			scenReference := theoreticalMonthlyOutput(scen.InstalledCapacity, inverterEfficiency, sunshine, irradiance, wm.Days)
+			weatherExpected := theoreticalMonthlyOutput(base.InstalledCapacity, inverterEfficiency, sunshine, irradiance, wm.Days) *
+				(1 + temperatureCoefficient*temperatureDelta)
+			scenExpected := theoreticalMonthlyOutput(scen.InstalledCapacity, scen.efficiency, sunshine, irradiance, wm.Days) *
+				(1 - scen.losses) *
+				tiltFactor(scen.tilt, base.tilt, wm.Month) *
+				(1 + temperatureCoefficient*temperatureDelta)
+
+			// The site keeps performing against theory the way it did that month
+			pr := actual / baseReference
+			baseGeneration := actual
+			scenGeneration := scenExpected * pr
+
+			// The model already sees the weather change, so only the changes to the site itself
+			// are applied on top of its scenario forecast
+			var baseForecast, scenForecast *float64
+			if prediction, ok := forecasts[forecastKey{wm.Year, wm.Month, base.Name}]; ok {
+				baseline := prediction.Baseline
+				baseForecast = &baseline
+				if weatherExpected > 0 {
+					adjusted := prediction.Scenario * scenExpected / weatherExpected
+					scenForecast = &adjusted
+				}
+			}
+
+			period := &scenarioPair{}
+			period.baseline.add(baseGeneration, baseReference, baseForecast)
+			period.scenario.add(scenGeneration, scenReference, scenForecast)
+			response.Monthly = append(response.Monthly, structure.ScenarioPeriod{
+				Year:     wm.Year,
+				Month:    wm.Month,
+				Location: base.Name,
+				Baseline: period.baseline.figures(intensity),
+				Scenario: period.scenario.figures(intensity),
+			})
+
+			monthTotal.baseline.add(baseGeneration, baseReference, baseForecast)
+			monthTotal.scenario.add(scenGeneration, scenReference, scenForecast)
+
+			yearSite, ok := yearly[wm.Year][base.Name]
+			if !ok {
+				yearSite = &scenarioPair{}
+				yearly[wm.Year][base.Name] = yearSite
+			}
+			yearSite.baseline.add(baseGeneration, baseReference, baseForecast)
+			yearSite.scenario.add(scenGeneration, scenReference, scenForecast)
+		}
+
+		if !projected {
+			continue
+		}
+		response.Monthly = append(response.Monthly, structure.ScenarioPeriod{
+			Year:     wm.Year,
+			Month:    wm.Month,
+			Location: "Total System",
+			Baseline: monthTotal.baseline.figures(intensity),
+			Scenario: monthTotal.scenario.figures(intensity),
+		})
+	}
+
+	for _, year := range yearOrder {
+		if len(yearly[year]) == 0 {
+			continue
+		}
+		total := &scenarioPair{}
+		for _, site := range baselineSites {
+			pair, ok := yearly[year][site.Name]
+			if !ok {
+				continue
+			}
+			response.Yearly = append(response.Yearly, structure.ScenarioPeriod{
+				Year:     year,
+				Location: site.Name,
+				Baseline: pair.baseline.figures(intensity),
+				Scenario: pair.scenario.figures(intensity),
+			})
+			mergeTotals(&total.baseline, pair.baseline)
+			mergeTotals(&total.scenario, pair.scenario)
+		}
+		response.Yearly = append(response.Yearly, structure.ScenarioPeriod{
+			Year:     year,
+			Location: "Total System",
+			Baseline: total.baseline.figures(intensity),
+			Scenario: total.scenario.figures(intensity),
+		})
+	}
+
+	if req.Persist {
+		id, err := c.scenarios.Save(req, response)
+		if err != nil {
+			return response, err
+		}
+		response.ID = id
+		response.Persisted = true
+	}
+
+	return response, nil
+}
+
+func mergeTotals(dst *scenarioTotals, src scenarioTotals) {
+	dst.generation += src.generation
+	dst.theoretical += src.theoretical
+	dst.forecast += src.forecast
+	dst.hasForecast = dst.hasForecast || src.hasForecast
+}
+
+// applySiteModification returns the site with mod applied. A new panel count changes capacity
+// in proportion, at the site's current capacity per panel.
+func applySiteModification(site scenarioSite, mod structure.SiteModification) (scenarioSite, error) {
+	if mod.CapacityKW != nil {
+		site.InstalledCapacity = *mod.CapacityKW
+	}
+	site.InstalledCapacity += mod.AddedCapacityKW
+	if site.InstalledCapacity < 0 {
+		return site, fmt.Errorf("%w: capacity of %s would be %.2f kW", ErrInvalidScenario, site.Name, site.InstalledCapacity)
+	}
+
+	if mod.NumberOfPanels != nil || mod.AddedPanels != 0 {
+		if site.NumberOfPanels <= 0 {
+			return site, fmt.Errorf("%w: %s has no panel count to scale capacity from", ErrInvalidScenario, site.Name)
+		}
+		panels := site.NumberOfPanels
+		if mod.NumberOfPanels != nil {
+			panels = *mod.NumberOfPanels
+		}
+		panels += mod.AddedPanels
+		if panels < 0 {
+			return site, fmt.Errorf("%w: %s would have %d panels", ErrInvalidScenario, site.Name, panels)
+		}
+		site.InstalledCapacity *= float64(panels) / float64(site.NumberOfPanels)
+		site.NumberOfPanels = panels
+	}
+
+	if mod.TiltDegrees != nil {
+		site.tilt = *mod.TiltDegrees
+	}
+	if mod.InverterEfficiency != nil {
+		site.efficiency = *mod.InverterEfficiency
+	}
+	site.losses = mod.AdditionalLosses
+
+	return site, nil
+}
+
+// perturbWeather returns the sunshine, irradiance and temperature change to use for a month
+func perturbWeather(wm structure.WeatherMonth, perturbation *structure.WeatherPerturbation) (float64, float64, float64) {
+	if perturbation == nil {
+		return wm.Sunshine, wm.Irradiance, 0
+	}
+
+	if len(perturbation.Months) > 0 {
+		applies := false
+		for _, month := range perturbation.Months {
+			if month == wm.Month {
+				applies = true
+				break
+			}
+		}
+		if !applies {
+			return wm.Sunshine, wm.Irradiance, 0
+		}
+	}
+
+	sunshine := wm.Sunshine
+	if perturbation.SunshineScale > 0 {
+		sunshine *= perturbation.SunshineScale
+	}
+	irradiance := wm.Irradiance
+	if perturbation.IrradianceScale > 0 {
+		irradiance *= perturbation.IrradianceScale
+	}
+
+	return sunshine, irradiance, perturbation.TemperatureDeltaC
+}
+
+// tiltFactor approximates the change in plane-of-array irradiance when moving panels
+// from baseTilt to tilt, using the noon sun position in the middle of the month
+func tiltFactor(tilt, baseTilt float64, month int) float64 {
+	if tilt == baseTilt {
+		return 1
+	}
+
+	dayOfYear := float64((month-1)*30 + 15)
+	declination := 23.45 * math.Sin(2*math.Pi*(284+dayOfYear)/365)
+	optimalTilt := siteLatitude - declination
+
+	toRadians := func(deg float64) float64 { return deg * math.Pi / 180 }
+	base := math.Cos(toRadians(baseTilt - optimalTilt))
+	if base <= 0 {
+		return 1
+	}
+	return math.Max(math.Cos(toRadians(tilt-optimalTilt)), 0) / base
+}
+
+func (c *Calculator) getScenarioWeather(startYear, endYear int) ([]structure.WeatherMonth, error) {
+	months, err := c.weather.Months()
+	if err != nil {
+		return nil, fmt.Errorf("error querying weather data: %v", err)
+	}
+
+	var weather []structure.WeatherMonth
+	for _, wm := range months {
+		if (startYear != 0 && wm.Year < startYear) || (endYear != 0 && wm.Year > endYear) {
+			continue
+		}
+		weather = append(weather, wm)
+	}
+	return weather, nil
+}
+
+// getScenarioGeneration returns the actual generation of every site and month that has one
+func (c *Calculator) getScenarioGeneration() (map[generationKey]float64, error) {
+	months, err := c.generation.Actuals()
+	if err != nil {
+		return nil, fmt.Errorf("error querying monthly generation: %v", err)
+	}
+
+	generation := make(map[generationKey]float64, len(months))
+	for _, month := range months {
+		generation[generationKey{month.Year, month.Month, month.LocationID}] = month.ActualKWH
+	}
+	return generation, nil
+}
+
+// scenarioForecasts predicts with the forecast model the pipeline last trained, over the recorded
+// weather and the weather perturbed as the scenario asks, keyed by year, month and location name
+func (c *Calculator) scenarioForecasts(ctx context.Context, weather *structure.WeatherPerturbation) (map[forecastKey]model.Prediction, error) {
+	if c.models == nil {
+		return nil, errors.New("no forecast model is configured")
+	}
+	input := struct {
+		Weather *structure.WeatherPerturbation `json:"weather"`
+	}{weather}
+	predictions, err := c.models.Predict(ctx, "random_forest", c.forecastScript, input)
+	if err != nil {
+		return nil, err
+	}
+
+	forecasts := make(map[forecastKey]model.Prediction, len(predictions))
+	for _, prediction := range predictions {
+		forecasts[forecastKey{prediction.Year, prediction.Month, prediction.Location}] = prediction
+	}
+	return forecasts, nil
+}
@@ -0,0 +1,263 @@
+package calculation
+
+import (
+	"backend/pkg/model"
+	"backend/pkg/repository/memory"
+	structure "backend/pkg/struct"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestValidateScenario(t *testing.T) {
+	capacity := func(v float64) *float64 { return &v }
+	panels := func(v int) *int { return &v }
+	site := func(mod structure.SiteModification) structure.ScenarioRequest {
+		if mod.Location == "" {
+			mod.Location = "Awali"
+		}
+		return structure.ScenarioRequest{Sites: []structure.SiteModification{mod}}
+	}
+	tests := []struct {
+		name  string
+		req   structure.ScenarioRequest
+		valid bool
+	}{
+		{"no changes", structure.ScenarioRequest{}, true},
+		{"added capacity", site(structure.SiteModification{AddedCapacityKW: 500}), true},
+		{"removed capacity", site(structure.SiteModification{CapacityKW: capacity(1000), AddedCapacityKW: -500}), true},
+		{"panel count", site(structure.SiteModification{NumberOfPanels: panels(5000), AddedPanels: -100}), true},
+		{"weather", structure.ScenarioRequest{Weather: &structure.WeatherPerturbation{IrradianceScale: 0.9, Months: []int{6, 7}}}, true},
+		{"years in order", structure.ScenarioRequest{StartYear: 2016, EndYear: 2018}, true},
+		{"years reversed", structure.ScenarioRequest{StartYear: 2018, EndYear: 2016}, false},
+		{"unknown location", site(structure.SiteModification{Location: "Sitra"}), false},
+		{"total system", site(structure.SiteModification{Location: "Total System"}), false},
+		{"location twice", structure.ScenarioRequest{Sites: []structure.SiteModification{{Location: "UOB"}, {Location: "UOB"}}}, false},
+		{"negative capacity", site(structure.SiteModification{CapacityKW: capacity(-1)}), false},
+		{"capacity removed below zero", site(structure.SiteModification{CapacityKW: capacity(100), AddedCapacityKW: -200}), false},
+		{"negative panels", site(structure.SiteModification{NumberOfPanels: panels(-1)}), false},
+		{"panels removed below zero", site(structure.SiteModification{NumberOfPanels: panels(10), AddedPanels: -20}), false},
+		{"capacity and panels", site(structure.SiteModification{AddedCapacityKW: 100, AddedPanels: 100}), false},
+		{"tilt above 90", site(structure.SiteModification{TiltDegrees: capacity(91)}), false},
+		{"zero efficiency", site(structure.SiteModification{InverterEfficiency: capacity(0)}), false},
+		{"efficiency above 1", site(structure.SiteModification{InverterEfficiency: capacity(1.1)}), false},
+		{"all output lost", site(structure.SiteModification{AdditionalLosses: 1}), false},
+		{"negative weather scale", structure.ScenarioRequest{Weather: &structure.WeatherPerturbation{SunshineScale: -1}}, false},
+		{"invalid weather month", structure.ScenarioRequest{Weather: &structure.WeatherPerturbation{Months: []int{13}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateScenario(tt.req); (err == nil) != tt.valid {
+				t.Errorf("ValidateScenario() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+// fakePredictor answers every scenario with the same predictions, and fails when it is not run
+// with script
+type fakePredictor struct {
+	predictions []model.Prediction
+	err         error
+	script      string
+}
+
+func (p fakePredictor) Predict(ctx context.Context, name, script string, input interface{}) ([]model.Prediction, error) {
+	if script != p.script {
+		return nil, fmt.Errorf("predicted with %q, want %q", script, p.script)
+	}
+	return p.predictions, p.err
+}
+
+func scenarioData() *memory.Data {
+	sites := testSites()
+	sites[2].NumberOfPanels = 0
+	return &memory.Data{
+		Sites: sites,
+		WeatherMonths: []structure.WeatherMonth{
+			{Year: 2019, Month: 1, Sunshine: 36000, Irradiance: 500, Days: 31},
+			{Year: 2020, Month: 1, Sunshine: 36000, Irradiance: 500, Days: 31},
+		},
+		MonthlyGeneration: []structure.Generation{
+			{Year: 2019, Month: 1, LocationID: 1, ActualKWH: 113460},
+			{Year: 2020, Month: 1, LocationID: 1, ActualKWH: 100000},
+		},
+	}
+}
+
+func TestRunScenario(t *testing.T) {
+	data := scenarioData()
+	predictor := fakePredictor{predictions: []model.Prediction{{Year: 2019, Month: 1, Location: "Awali", Baseline: 110000, Scenario: 110000}}, script: "forecast.py"}
+	calculator := New(memory.New(data), predictor, "forecast.py", func() float64 { return 400 })
+
+	// Doubling the panels doubles the capacity, and so the output at the same performance ratio
+	response, err := calculator.RunScenario(context.Background(), structure.ScenarioRequest{
+		Name:      "more panels",
+		StartYear: 2019,
+		EndYear:   2019,
+		Sites:     []structure.SiteModification{{Location: "Awali", AddedPanels: 4000}},
+		Persist:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(response.Monthly) != 2 || len(response.Yearly) != 2 {
+		t.Fatalf("got %d monthly and %d yearly periods, want 2 and 2", len(response.Monthly), len(response.Yearly))
+	}
+	awali := response.Monthly[0]
+	if awali.Location != "Awali" || awali.Year != 2019 || awali.Month != 1 {
+		t.Fatalf("first period is %s %d-%d, want Awali 2019-1", awali.Location, awali.Year, awali.Month)
+	}
+	if awali.Baseline.GenerationKWH != 113460 || awali.Scenario.GenerationKWH != 226920 {
+		t.Errorf("generation = %v → %v, want 113460 → 226920", awali.Baseline.GenerationKWH, awali.Scenario.GenerationKWH)
+	}
+	if awali.Baseline.PerformanceRatio != 0.8 || awali.Scenario.PerformanceRatio != 0.8 {
+		t.Errorf("performance ratio = %v → %v, want 0.8 → 0.8", awali.Baseline.PerformanceRatio, awali.Scenario.PerformanceRatio)
+	}
+	if awali.Baseline.CO2OffsetKg != 45384 {
+		t.Errorf("CO2 offset = %v, want 45384", awali.Baseline.CO2OffsetKg)
+	}
+	if response.ForecastError != "" {
+		t.Fatalf("forecast error = %q", response.ForecastError)
+	}
+	if awali.Baseline.ForecastKWH == nil || *awali.Baseline.ForecastKWH != 110000 ||
+		awali.Scenario.ForecastKWH == nil || *awali.Scenario.ForecastKWH != 220000 {
+		t.Errorf("forecast = %v → %v, want 110000 → 220000", awali.Baseline.ForecastKWH, awali.Scenario.ForecastKWH)
+	}
+	if total := response.Monthly[1]; total.Location != "Total System" || total.Scenario.GenerationKWH != 226920 {
+		t.Errorf("total = %+v, want Total System with 226920 kWh", total)
+	}
+
+	if !response.Persisted || response.ID != 1 || len(data.Scenarios) != 1 || data.Scenarios[0].Name != "more panels" {
+		t.Errorf("scenario was not saved: response %d %v, stored %+v", response.ID, response.Persisted, data.Scenarios)
+	}
+}
+
+func TestRunScenarioForecastError(t *testing.T) {
+	tests := []struct {
+		name      string
+		predictor Predictor
+		want      string
+	}{
+		{"no model", nil, "no forecast model is configured"},
+		{"model failed", fakePredictor{err: errors.New("model random_forest failed")}, "model random_forest failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := scenarioData()
+			calculator := New(memory.New(data), tt.predictor, "", func() float64 { return 400 })
+			response, err := calculator.RunScenario(context.Background(), structure.ScenarioRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The projection stands without the forecast
+			if response.ForecastError != tt.want {
+				t.Errorf("forecast error = %q, want %q", response.ForecastError, tt.want)
+			}
+			if len(response.Monthly) != 4 || response.Monthly[0].Baseline.ForecastKWH != nil {
+				t.Errorf("monthly = %+v, want 4 periods without forecasts", response.Monthly)
+			}
+			if response.Persisted || len(data.Scenarios) != 0 {
+				t.Error("scenario was saved without being asked to")
+			}
+		})
+	}
+}
+
+func TestRunScenarioInvalid(t *testing.T) {
+	calculator := New(memory.New(scenarioData()), nil, "", func() float64 { return 400 })
+
+	// UOB has no panel count to scale its capacity from
+	_, err := calculator.RunScenario(context.Background(), structure.ScenarioRequest{
+		Sites: []structure.SiteModification{{Location: "UOB", AddedPanels: 100}},
+	})
+	if !errors.Is(err, ErrInvalidScenario) {
+		t.Errorf("RunScenario() = %v, want ErrInvalidScenario", err)
+	}
+}
+
+func TestPerturbWeather(t *testing.T) {
+	june := structure.WeatherMonth{Year: 2020, Month: 6, Sunshine: 40000, Irradiance: 600, Days: 30}
+	tests := []struct {
+		name                               string
+		perturbation                       *structure.WeatherPerturbation
+		sunshine, irradiance, temperatureC float64
+	}{
+		{"no perturbation", nil, 40000, 600, 0},
+		{"zero scales leave the weather alone", &structure.WeatherPerturbation{TemperatureDeltaC: 2}, 40000, 600, 2},
+		{"scaled", &structure.WeatherPerturbation{SunshineScale: 0.5, IrradianceScale: 1.1}, 20000, 660, 0},
+		{"month included", &structure.WeatherPerturbation{IrradianceScale: 0.5, Months: []int{6, 7}}, 40000, 300, 0},
+		{"month excluded", &structure.WeatherPerturbation{IrradianceScale: 0.5, TemperatureDeltaC: 2, Months: []int{12}}, 40000, 600, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sunshine, irradiance, temperatureC := perturbWeather(june, tt.perturbation)
+			if sunshine != tt.sunshine || irradiance != tt.irradiance || temperatureC != tt.temperatureC {
+				t.Errorf("perturbWeather() = %v, %v, %v, want %v, %v, %v",
+					sunshine, irradiance, temperatureC, tt.sunshine, tt.irradiance, tt.temperatureC)
+			}
+		})
+	}
+}
+
+func TestApplySiteModification(t *testing.T) {
+	value := func(v float64) *float64 { return &v }
+	panels := func(v int) *int { return &v }
+	base := scenarioSite{
+		Location:   structure.Location{Name: "Awali", InstalledCapacity: 1000, NumberOfPanels: 2000},
+		tilt:       20,
+		efficiency: 0.96,
+	}
+	tests := []struct {
+		name     string
+		site     scenarioSite
+		mod      structure.SiteModification
+		capacity float64
+		panels   int
+		tilt     float64
+		err      error
+	}{
+		{"unchanged", base, structure.SiteModification{}, 1000, 2000, 20, nil},
+		{"added capacity", base, structure.SiteModification{AddedCapacityKW: 250}, 1250, 2000, 20, nil},
+		{"absolute before added", base, structure.SiteModification{CapacityKW: value(500), AddedCapacityKW: 100}, 600, 2000, 20, nil},
+		// Panels change capacity at the site's 0.5 kW per panel
+		{"panel count", base, structure.SiteModification{NumberOfPanels: panels(3000), AddedPanels: -500}, 1250, 2500, 20, nil},
+		{"added panels", base, structure.SiteModification{AddedPanels: 400}, 1200, 2400, 20, nil},
+		{"tilt", base, structure.SiteModification{TiltDegrees: value(30)}, 1000, 2000, 30, nil},
+		{"capacity below zero", base, structure.SiteModification{AddedCapacityKW: -1500}, 0, 0, 0, ErrInvalidScenario},
+		{"panels below zero", base, structure.SiteModification{AddedPanels: -2500}, 0, 0, 0, ErrInvalidScenario},
+		{"no panels to scale from", scenarioSite{Location: structure.Location{Name: "UOB", InstalledCapacity: 500}},
+			structure.SiteModification{AddedPanels: 10}, 0, 0, 0, ErrInvalidScenario},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applySiteModification(tt.site, tt.mod)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("applySiteModification() = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+			if got.InstalledCapacity != tt.capacity || got.NumberOfPanels != tt.panels || got.tilt != tt.tilt {
+				t.Errorf("applySiteModification() = capacity %v, panels %d, tilt %v, want %v, %d, %v",
+					got.InstalledCapacity, got.NumberOfPanels, got.tilt, tt.capacity, tt.panels, tt.tilt)
+			}
+		})
+	}
+}
+
+func TestTiltFactor(t *testing.T) {
+	if got := tiltFactor(20, 20, 6); got != 1 {
+		t.Errorf("tiltFactor() at the same tilt = %v, want 1", got)
+	}
+	// Steeper panels catch more of the low winter sun and less of the high summer sun
+	if got := tiltFactor(40, 20, 12); got <= 1 {
+		t.Errorf("tiltFactor() for steeper panels in December = %v, want above 1", got)
+	}
+	if got := tiltFactor(40, 20, 6); got >= 1 {
+		t.Errorf("tiltFactor() for steeper panels in June = %v, want below 1", got)
+	}
+}
@@ -1,7 +1,10 @@
 package calculation
 
 import (
-	"backend/pkg/db"
+	"backend/pkg/model"
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+	"context"
 	"fmt"
 	"math"
 	"time"
@@ -11,209 +14,142 @@ const (
 	inverterEfficiency = 0.915 // 91.5%
 )
 
-type Location struct {
-	ID                int
-	Name              string
-	InstalledCapacity float64
-	NumberOfPV        int
+// Predictor runs a model over scenario input, as model.Runner does
+type Predictor interface {
+	Predict(ctx context.Context, name, script string, input interface{}) ([]model.Prediction, error)
 }
 
-func CalculateTheorticalOutput() error {
+// Calculator derives theoretical output and performance metrics from the stored generation and
+// weather, and runs scenarios over them
+type Calculator struct {
+	sites       repository.SiteRepo
+	generation  repository.GenerationRepo
+	weather     repository.WeatherRepo
+	performance repository.PerformanceRepo
+	assets      repository.AssetRepo
+	scenarios   repository.ScenarioRepo
+	models      Predictor
+	// forecastScript is the monthly forecast model script scenarios predict with
+	forecastScript string
+	intensity      func() float64
+}
+
+// New returns a Calculator on repos. Scenario forecasts come from running forecastScript with
+// models, and the carbon intensity in gCO2/kWh of the gas generation displaced from intensity.
+func New(repos repository.Repos, models Predictor, forecastScript string, intensity func() float64) *Calculator {
+	return &Calculator{
+		sites:          repos.Sites,
+		generation:     repos.Generation,
+		weather:        repos.Weather,
+		performance:    repos.Performance,
+		assets:         repos.Assets,
+		scenarios:      repos.Scenarios,
+		models:         models,
+		forecastScript: forecastScript,
+		intensity:      intensity,
+	}
+}
+
+func (c *Calculator) CalculateTheorticalOutput() error {
 	// 1. Get all locations
-	locations, err := getLocations()
+	locations, err := c.sites.List()
 	if err != nil {
 		return fmt.Errorf("error getting locations: %v", err)
 	}
 
 	// 2. Get monthly weather data and calculate for each month
-	query := `
-		SELECT strftime('%Y', date) as year, 
-			   strftime('%m', date) as month,
-			   AVG(sunshine_duration_seconds) as avg_sunshine,
-			   AVG(avg_solar_irradiance_wm2) as avg_irradiance,
-			   COUNT(*) as days_in_month
-		FROM weather_daily
-		GROUP BY strftime('%Y', date), strftime('%m', date)
-		ORDER BY year, month
-	`
-	
-	rows, err := db.Database.Query(query)
+	weather, err := c.weather.FilledMonths()
 	if err != nil {
 		return fmt.Errorf("error querying weather data: %v", err)
 	}
-	defer rows.Close()
-
-	tx, err := db.Database.Begin()
-	if err != nil {
-		return fmt.Errorf("error starting transaction: %v", err)
-	}
-	defer tx.Rollback()
-
-	// Prepare the update statement
-	updateStmt, err := tx.Prepare(`
-		INSERT INTO monthly_generation (year, month, location_id, theoretical_kwh)
-		VALUES (?, ?, ?, ?)
-		ON CONFLICT(year, month, location_id) 
-		DO UPDATE SET theoretical_kwh = excluded.theoretical_kwh
-	`)
-	if err != nil {
-		return fmt.Errorf("error preparing statement: %v", err)
-	}
-	defer updateStmt.Close()
-
-	for rows.Next() {
-		var year, month int
-		var avgSunshine, avgIrradiance float64
-		var daysInMonth int
-
-		if err := rows.Scan(&year, &month, &avgSunshine, &avgIrradiance, &daysInMonth); err != nil {
-			return fmt.Errorf("error scanning row: %v", err)
-		}
 
+	var months []structure.Generation
+	for _, wm := range weather {
 		for _, loc := range locations {
-			dailyOutput := loc.InstalledCapacity * inverterEfficiency * (avgSunshine * avgIrradiance) / (1000 * 3600)
-			monthlyOutput := math.Round(dailyOutput * float64(daysInMonth) * 100) / 100
-
-			// Save to database
-			_, err := updateStmt.Exec(year, month, loc.ID, monthlyOutput)
-			if err != nil {
-				return fmt.Errorf("error updating theoretical output for location %s: %v", loc.Name, err)
-			}
+			monthlyOutput := math.Round(theoreticalMonthlyOutput(loc.InstalledCapacity, inverterEfficiency, wm.Sunshine, wm.Irradiance, wm.Days)*100) / 100
+			months = append(months, structure.Generation{
+				Year:           wm.Year,
+				Month:          wm.Month,
+				LocationID:     loc.ID,
+				TheoreticalKWH: monthlyOutput,
+			})
 		}
 	}
 
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("error committing transaction: %v", err)
-	}
+	return c.generation.SetTheoretical(months)
+}
 
-	return nil
+// theoreticalMonthlyOutput returns the kWh a site of the given capacity should produce in a month
+// with the given average daily sunshine (seconds) and irradiance (W/m²)
+func theoreticalMonthlyOutput(installedCapacity, efficiency, avgSunshine, avgIrradiance float64, daysInMonth int) float64 {
+	return theoreticalDailyOutput(installedCapacity, efficiency, avgSunshine, avgIrradiance) * float64(daysInMonth)
 }
 
-func getLocations() ([]Location, error) {
-	query := `
-		SELECT id, name, installed_capacity_kw, number_of_panels 
-		FROM locations 
-		ORDER BY id
-	`
-	
-	rows, err := db.Database.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+// theoreticalDailyOutput returns the kWh a site should produce in a day with the given sunshine and irradiance
+func theoreticalDailyOutput(installedCapacity, efficiency, sunshine, irradiance float64) float64 {
+	return installedCapacity * efficiency * (sunshine * irradiance) / (1000 * 3600)
+}
 
-	var locations []Location
-	for rows.Next() {
-		var loc Location
-		if err := rows.Scan(&loc.ID, &loc.Name, &loc.InstalledCapacity, &loc.NumberOfPV); err != nil {
-			return nil, err
-		}
-		locations = append(locations, loc)
+// performanceMetrics returns the performance ratio, capacity factor and output per panel of a
+// site that generated actual kWh against theoretical kWh over hours
+func performanceMetrics(loc structure.Location, actual, theoretical float64, hours int) (float64, float64, float64) {
+	performanceRatio := 0.0
+	if theoretical > 0 {
+		performanceRatio = math.Round((actual/theoretical)*1000) / 1000 // 3 decimal places
 	}
 
-	return locations, nil
-}
-
-func CalculateMonthlyPerformance() {
-	// Clear the monthly_performance table first
-	_, err := db.Database.Exec("DELETE FROM monthly_performance")
-	if err != nil {
-		fmt.Printf("error clearing monthly_performance table: %v", err)
+	capacityFactor := 0.0
+	if loc.InstalledCapacity > 0 {
+		capacityFactor = math.Round((actual/(loc.InstalledCapacity*float64(hours)))*1000) / 1000 // 3 decimal places
 	}
 
-	// Get all locations
-	locations, err := getLocations()
-	if err != nil {
-		fmt.Printf("error getting locations: %v", err)
+	outputPerPV := 0.0
+	if loc.NumberOfPanels > 0 {
+		outputPerPV = math.Round((actual/float64(loc.NumberOfPanels))*100) / 100 // 2 decimal places
 	}
 
-	// Start a transaction for batch updates
-	tx, err := db.Database.Begin()
+	return performanceRatio, capacityFactor, outputPerPV
+}
+
+// locationsByID returns the sites keyed by location ID
+func (c *Calculator) locationsByID() (map[int]structure.Location, error) {
+	locations, err := c.sites.List()
 	if err != nil {
-		fmt.Printf("error starting transaction: %v", err)
+		return nil, fmt.Errorf("error getting locations: %v", err)
 	}
-	defer tx.Rollback()
-
-	// Prepare the update statement
-	updateStmt, err := tx.Prepare(`
-		INSERT INTO monthly_performance (
-			year, month, location_id, 
-			performance_ratio, capacity_factor, output_per_pv
-		)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`)
-	if err != nil {
-		fmt.Printf("error preparing statement: %v", err)
+	byID := make(map[int]structure.Location, len(locations))
+	for _, loc := range locations {
+		byID[loc.ID] = loc
 	}
-	defer updateStmt.Close()
-
-	// Query to get monthly data
-	query := `
-		SELECT 
-			year, month, location_id,
-			actual_kwh, theoretical_kwh
-		FROM monthly_generation
-		WHERE actual_kwh IS NOT NULL 
-			AND theoretical_kwh IS NOT NULL
-	`
-
-	rows, err := db.Database.Query(query)
+	return byID, nil
+}
+
+func (c *Calculator) CalculateMonthlyPerformance() error {
+	locations, err := c.locationsByID()
 	if err != nil {
-		fmt.Printf("error querying monthly generation: %v", err)
+		return err
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var year, month, locationID int
-		var actualKWH, theoreticalKWH float64
-
-		if err := rows.Scan(&year, &month, &locationID, &actualKWH, &theoreticalKWH); err != nil {
-			fmt.Printf("error scanning row: %v", err)
-		}
-
-		// Get location's installed capacity and number of panels
-		var installedCapacity float64
-		var numberOfPanels int
-		for _, loc := range locations {
-			if loc.ID == locationID {
-				installedCapacity = loc.InstalledCapacity
-				numberOfPanels = loc.NumberOfPV
-				break
-			}
-		}
 
-		// Get hours in month
-		hoursInMonth := getHoursInMonth(year, month)
-
-		// Calculate metrics
-		performanceRatio := 0.0
-		if theoreticalKWH > 0 {
-			performanceRatio = math.Round((actualKWH/theoreticalKWH)*1000) / 1000  // 3 decimal places
-		}
-
-		capacityFactor := 0.0
-		if installedCapacity > 0 {
-			capacityFactor = math.Round((actualKWH/(installedCapacity*float64(hoursInMonth)))*1000) / 1000  // 3 decimal places
-		}
-
-		outputPerPV := 0.0
-		if numberOfPanels > 0 {
-			outputPerPV = math.Round((actualKWH/float64(numberOfPanels))*100) / 100  // 2 decimal places
-		}
-
-		_, err = updateStmt.Exec(
-			year, month, locationID,
-			performanceRatio, capacityFactor, outputPerPV,
-		)
-		if err != nil {
-			fmt.Printf("error updating performance metrics: %v", err)
-		}
+	months, err := c.generation.Filled()
+	if err != nil {
+		return fmt.Errorf("error querying monthly generation: %v", err)
 	}
 
-	if err = tx.Commit(); err != nil {
-		fmt.Printf("error committing transaction: %v", err)
+	performance := make([]structure.Performance, 0, len(months))
+	for _, month := range months {
+		ratio, capacityFactor, outputPerPV := performanceMetrics(locations[month.LocationID],
+			month.ActualKWH, month.TheoreticalKWH, getHoursInMonth(month.Year, month.Month))
+		performance = append(performance, structure.Performance{
+			Year:             month.Year,
+			Month:            month.Month,
+			LocationID:       month.LocationID,
+			PerformanceRatio: ratio,
+			CapacityFactor:   capacityFactor,
+			OutputPerPV:      outputPerPV,
+		})
 	}
 
+	return c.performance.ReplaceMonthly(performance)
 }
 
 func getHoursInMonth(year, month int) int {
@@ -222,246 +158,98 @@ func getHoursInMonth(year, month int) int {
 	return lastDay.Day() * 24
 }
 
-func CalculateYearlyPerformance() {
-	// Clear the yearly_performance table first
-	_, err := db.Database.Exec("DELETE FROM yearly_performance")
-	if err != nil {
-		fmt.Printf("error clearing yearly_performance table: %v\n", err)
-		return
-	}
+// generationTotal sums a site's generation, with the hours of the months that have data so a gap
+// that could not be imputed does not lower the capacity factor
+type generationTotal struct {
+	actual, theoretical float64
+	hours               int
+}
 
-	locations, err := getLocations()
+func (c *Calculator) CalculateYearlyPerformance() error {
+	locations, err := c.locationsByID()
 	if err != nil {
-		fmt.Printf("error getting locations: %v\n", err)
-		return
+		return err
 	}
 
-	tx, err := db.Database.Begin()
-	if err != nil {
-		fmt.Printf("error starting transaction: %v\n", err)
-		return
-	}
-	defer tx.Rollback()
-
-	updateStmt, err := tx.Prepare(`
-		INSERT INTO yearly_performance (
-			year, location_id,
-			performance_ratio, capacity_factor, output_per_pv
-		)
-		VALUES (?, ?, ?, ?, ?)
-	`)
-	if err != nil {
-		fmt.Printf("error preparing statement: %v\n", err)
-		return
-	}
-	defer updateStmt.Close()
-
-	// Query to get yearly sums
-	query := `
-		SELECT 
-			year, location_id,
-			SUM(actual_kwh) as yearly_actual,
-			SUM(theoretical_kwh) as yearly_theoretical
-		FROM monthly_generation
-		WHERE actual_kwh IS NOT NULL 
-		AND theoretical_kwh IS NOT NULL
-		GROUP BY year, location_id
-	`
-
-	rows, err := db.Database.Query(query)
+	months, err := c.generation.Filled()
 	if err != nil {
-		fmt.Printf("error querying yearly generation: %v\n", err)
-		return
+		return fmt.Errorf("error querying yearly generation: %v", err)
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var year, locationID int
-		var yearlyActual, yearlyTheoretical float64
 
-		if err := rows.Scan(&year, &locationID, &yearlyActual, &yearlyTheoretical); err != nil {
-			fmt.Printf("error scanning row: %v\n", err)
-			continue
+	type yearKey struct{ year, locationID int }
+	var keys []yearKey
+	totals := make(map[yearKey]*generationTotal)
+	for _, month := range months {
+		key := yearKey{month.Year, month.LocationID}
+		if totals[key] == nil {
+			keys = append(keys, key)
+			totals[key] = &generationTotal{}
 		}
+		totals[key].actual += month.ActualKWH
+		totals[key].theoretical += month.TheoreticalKWH
+		totals[key].hours += getHoursInMonth(month.Year, month.Month)
+	}
 
-		var installedCapacity float64
-		var numberOfPanels int
-		for _, loc := range locations {
-			if loc.ID == locationID {
-				installedCapacity = loc.InstalledCapacity
-				numberOfPanels = loc.NumberOfPV
-				break
-			}
-		}
+	performance := make([]structure.Performance, 0, len(keys))
+	for _, key := range keys {
+		total := totals[key]
+		ratio, capacityFactor, outputPerPV := performanceMetrics(locations[key.locationID], total.actual, total.theoretical, total.hours)
+		performance = append(performance, structure.Performance{
+			Year:             key.year,
+			LocationID:       key.locationID,
+			PerformanceRatio: ratio,
+			CapacityFactor:   capacityFactor,
+			OutputPerPV:      outputPerPV,
+		})
+	}
 
-		// Calculate hours in year (accounting for leap years)
-		hoursInYear := 8760
-		if year%4 == 0 && (year%100 != 0 || year%400 == 0) {
-			hoursInYear = 8784
-		}
+	return c.performance.ReplaceYearly(performance)
+}
 
-		performanceRatio := 0.0
-		if yearlyTheoretical > 0 {
-			performanceRatio = math.Round((yearlyActual/yearlyTheoretical)*1000) / 1000
-		}
+func (c *Calculator) CalculateOverallPerformance() error {
+	locations, err := c.locationsByID()
+	if err != nil {
+		return err
+	}
 
-		capacityFactor := 0.0
-		if installedCapacity > 0 {
-			capacityFactor = math.Round((yearlyActual/(installedCapacity*float64(hoursInYear)))*1000) / 1000
-		}
+	months, err := c.generation.Filled()
+	if err != nil {
+		return fmt.Errorf("error querying overall generation: %v", err)
+	}
 
-		outputPerPV := 0.0
-		if numberOfPanels > 0 {
-			outputPerPV = math.Round((yearlyActual/float64(numberOfPanels))*100) / 100
+	// The years span every location's data, while the sums only cover the months that have data
+	var startYear, endYear int
+	var order []int
+	totals := make(map[int]*generationTotal)
+	for _, month := range months {
+		if startYear == 0 || month.Year < startYear {
+			startYear = month.Year
 		}
-
-		_, err = updateStmt.Exec(
-			year, locationID,
-			performanceRatio, capacityFactor, outputPerPV,
-		)
-		if err != nil {
-			fmt.Printf("error updating yearly performance metrics: %v\n", err)
-			continue
+		if month.Year > endYear {
+			endYear = month.Year
 		}
+		if totals[month.LocationID] == nil {
+			order = append(order, month.LocationID)
+			totals[month.LocationID] = &generationTotal{}
+		}
+		totals[month.LocationID].actual += month.ActualKWH
+		totals[month.LocationID].theoretical += month.TheoreticalKWH
+		totals[month.LocationID].hours += getHoursInMonth(month.Year, month.Month)
 	}
 
-	if err = tx.Commit(); err != nil {
-		fmt.Printf("error committing transaction: %v\n", err)
-		return
+	performance := make([]structure.Performance, 0, len(order))
+	for _, locationID := range order {
+		total := totals[locationID]
+		ratio, capacityFactor, outputPerPV := performanceMetrics(locations[locationID], total.actual, total.theoretical, total.hours)
+		performance = append(performance, structure.Performance{
+			StartYear:        startYear,
+			EndYear:          endYear,
+			LocationID:       locationID,
+			PerformanceRatio: ratio,
+			CapacityFactor:   capacityFactor,
+			OutputPerPV:      outputPerPV,
+		})
 	}
-}
 
-
-func CalculateOverallPerformance() {
-    // Clear the overall_performance table first
-    _, err := db.Database.Exec("DELETE FROM overall_performance")
-    if err != nil {
-        fmt.Printf("error clearing overall_performance table: %v\n", err)
-        return
-    }
-
-    locations, err := getLocations()
-    if err != nil {
-        fmt.Printf("error getting locations: %v\n", err)
-        return
-    }
-
-    tx, err := db.Database.Begin()
-    if err != nil {
-        fmt.Printf("error starting transaction: %v\n", err)
-        return
-    }
-    defer tx.Rollback()
-
-    updateStmt, err := tx.Prepare(`
-        INSERT INTO overall_performance (
-            location_id,
-            start_year, end_year,
-            performance_ratio, capacity_factor, output_per_pv
-        )
-        VALUES (?, ?, ?, ?, ?, ?)
-    `)
-    if err != nil {
-        fmt.Printf("error preparing statement: %v\n", err)
-        return
-    }
-    defer updateStmt.Close()
-
-    // First, get the min and max years from the data
-    var startYear, endYear int
-    err = db.Database.QueryRow(`
-        SELECT MIN(year), MAX(year)
-        FROM monthly_generation
-        WHERE actual_kwh IS NOT NULL 
-        AND theoretical_kwh IS NOT NULL
-    `).Scan(&startYear, &endYear)
-    if err != nil {
-        fmt.Printf("error getting year range: %v\n", err)
-        return
-    }
-
-    // Query to get overall sums for each location
-    query := `
-        SELECT 
-            location_id,
-            SUM(actual_kwh) as total_actual,
-            SUM(theoretical_kwh) as total_theoretical
-        FROM monthly_generation
-        WHERE actual_kwh IS NOT NULL 
-        AND theoretical_kwh IS NOT NULL
-        GROUP BY location_id
-    `
-
-    rows, err := db.Database.Query(query)
-    if err != nil {
-        fmt.Printf("error querying overall generation: %v\n", err)
-        return
-    }
-    defer rows.Close()
-
-    for rows.Next() {
-        var locationID int
-        var totalActual, totalTheoretical float64
-
-        if err := rows.Scan(&locationID, &totalActual, &totalTheoretical); err != nil {
-            fmt.Printf("error scanning row: %v\n", err)
-            continue
-        }
-
-        var installedCapacity float64
-        var numberOfPanels int
-        for _, loc := range locations {
-            if loc.ID == locationID {
-                installedCapacity = loc.InstalledCapacity
-                numberOfPanels = loc.NumberOfPV
-                break
-            }
-        }
-
-        // Calculate total hours in the period
-        totalHours := calculateTotalHours(startYear, endYear)
-
-        performanceRatio := 0.0
-        if totalTheoretical > 0 {
-            performanceRatio = math.Round((totalActual/totalTheoretical)*1000) / 1000
-        }
-
-        capacityFactor := 0.0
-        if installedCapacity > 0 {
-            capacityFactor = math.Round((totalActual/(installedCapacity*float64(totalHours)))*1000) / 1000
-        }
-
-        outputPerPV := 0.0
-        if numberOfPanels > 0 {
-            outputPerPV = math.Round((totalActual/float64(numberOfPanels))*100) / 100
-        }
-
-        _, err = updateStmt.Exec(
-            locationID,
-            startYear, endYear,
-            performanceRatio, capacityFactor, outputPerPV,
-        )
-        if err != nil {
-            fmt.Printf("error updating overall performance metrics: %v\n", err)
-            continue
-        }
-    }
-
-    if err = tx.Commit(); err != nil {
-        fmt.Printf("error committing transaction: %v\n", err)
-        return
-    }
+	return c.performance.ReplaceOverall(performance)
 }
-
-// Helper function to calculate total hours between years
-func calculateTotalHours(startYear, endYear int) int {
-    totalHours := 0
-    for year := startYear; year <= endYear; year++ {
-        if year%4 == 0 && (year%100 != 0 || year%400 == 0) {
-            totalHours += 8784 // Leap year
-        } else {
-            totalHours += 8760 // Normal year
-        }
-    }
-    return totalHours
-}
\ No newline at end of file
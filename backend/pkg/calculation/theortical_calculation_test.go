@@ -0,0 +1,179 @@
+package calculation
+
+import (
+	"backend/pkg/repository/memory"
+	structure "backend/pkg/struct"
+	"math"
+	"testing"
+)
+
+// testSites are the sites the calculation tests run against
+func testSites() []structure.Location {
+	return []structure.Location{
+		{ID: 1, Name: "Awali", InstalledCapacity: 1000, NumberOfPanels: 4000},
+		{ID: 2, Name: "Refinery", InstalledCapacity: 2000, NumberOfPanels: 8000},
+		{ID: 3, Name: "UOB", InstalledCapacity: 500, NumberOfPanels: 2000},
+		{ID: 4, Name: "Total System", InstalledCapacity: 3500, NumberOfPanels: 14000},
+	}
+}
+
+func newTestCalculator(data *memory.Data) *Calculator {
+	if data.Sites == nil {
+		data.Sites = testSites()
+	}
+	return New(memory.New(data), nil, "", func() float64 { return 400 })
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestPerformanceMetrics(t *testing.T) {
+	site := structure.Location{InstalledCapacity: 1000, NumberOfPanels: 4000}
+	tests := []struct {
+		name                   string
+		loc                    structure.Location
+		actual, theoretical    float64
+		hours                  int
+		ratio, capacity, perPV float64
+	}{
+		{"january", site, 100000, 125000, 744, 0.8, 0.134, 25},
+		{"day", site, 4000, 4575, 24, 0.874, 0.167, 1},
+		{"no theoretical output", site, 100000, 0, 744, 0, 0.134, 25},
+		{"no capacity", structure.Location{NumberOfPanels: 4000}, 100000, 125000, 744, 0.8, 0, 25},
+		{"no panels", structure.Location{InstalledCapacity: 1000}, 100000, 125000, 744, 0.8, 0.134, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ratio, capacity, perPV := performanceMetrics(tt.loc, tt.actual, tt.theoretical, tt.hours)
+			if !almostEqual(ratio, tt.ratio) || !almostEqual(capacity, tt.capacity) || !almostEqual(perPV, tt.perPV) {
+				t.Errorf("performanceMetrics() = %v, %v, %v, want %v, %v, %v", ratio, capacity, perPV, tt.ratio, tt.capacity, tt.perPV)
+			}
+		})
+	}
+}
+
+func TestGetHoursInMonth(t *testing.T) {
+	tests := []struct {
+		year, month, hours int
+	}{
+		{2019, 1, 744},
+		{2019, 2, 672},
+		{2020, 2, 696},
+		{2019, 4, 720},
+	}
+	for _, tt := range tests {
+		if got := getHoursInMonth(tt.year, tt.month); got != tt.hours {
+			t.Errorf("getHoursInMonth(%d, %d) = %d, want %d", tt.year, tt.month, got, tt.hours)
+		}
+	}
+}
+
+func TestCalculateTheorticalOutput(t *testing.T) {
+	data := &memory.Data{
+		// 10 hours of sunshine at 500 W/m² is 5 kWh/m² a day
+		WeatherMonths:     []structure.WeatherMonth{{Year: 2019, Month: 1, Sunshine: 36000, Irradiance: 500, Days: 31}},
+		MonthlyGeneration: []structure.Generation{{Year: 2019, Month: 1, LocationID: 1, LocationName: "Awali", ActualKWH: 90000}},
+	}
+	if err := newTestCalculator(data).CalculateTheorticalOutput(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[int]float64{1: 141825, 2: 283650, 3: 70912.5, 4: 496387.5}
+	if len(data.MonthlyGeneration) != len(want) {
+		t.Fatalf("got %d months, want %d", len(data.MonthlyGeneration), len(want))
+	}
+	for _, month := range data.MonthlyGeneration {
+		if !almostEqual(month.TheoreticalKWH, want[month.LocationID]) {
+			t.Errorf("location %d: theoretical %v, want %v", month.LocationID, month.TheoreticalKWH, want[month.LocationID])
+		}
+		if month.LocationID == 1 && month.ActualKWH != 90000 {
+			t.Errorf("Awali actual changed to %v", month.ActualKWH)
+		}
+	}
+}
+
+func TestCalculatePerformance(t *testing.T) {
+	data := &memory.Data{
+		MonthlyGeneration: []structure.Generation{
+			{Year: 2019, Month: 1, LocationID: 1, ActualKWH: 100000, TheoreticalKWH: 125000},
+			{Year: 2019, Month: 2, LocationID: 1, ActualKWH: 90000, TheoreticalKWH: 100000},
+			{Year: 2020, Month: 1, LocationID: 2, ActualKWH: 200000, TheoreticalKWH: 250000},
+			// Without theoretical output the month is left out of every figure
+			{Year: 2020, Month: 3, LocationID: 1, ActualKWH: 50000},
+		},
+	}
+	calculator := newTestCalculator(data)
+	for _, calculate := range []func() error{
+		calculator.CalculateMonthlyPerformance,
+		calculator.CalculateYearlyPerformance,
+		calculator.CalculateOverallPerformance,
+	} {
+		if err := calculate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		got  []structure.Performance
+		want []structure.Performance
+	}{
+		{"monthly", data.MonthlyPerformance, []structure.Performance{
+			{Year: 2019, Month: 1, LocationID: 1, PerformanceRatio: 0.8, CapacityFactor: 0.134, OutputPerPV: 25},
+			{Year: 2019, Month: 2, LocationID: 1, PerformanceRatio: 0.9, CapacityFactor: 0.134, OutputPerPV: 22.5},
+			{Year: 2020, Month: 1, LocationID: 2, PerformanceRatio: 0.8, CapacityFactor: 0.134, OutputPerPV: 25},
+		}},
+		{"yearly", data.YearlyPerformance, []structure.Performance{
+			{Year: 2019, LocationID: 1, PerformanceRatio: 0.844, CapacityFactor: 0.134, OutputPerPV: 47.5},
+			{Year: 2020, LocationID: 2, PerformanceRatio: 0.8, CapacityFactor: 0.134, OutputPerPV: 25},
+		}},
+		{"overall", data.OverallPerformance, []structure.Performance{
+			{StartYear: 2019, EndYear: 2020, LocationID: 1, PerformanceRatio: 0.844, CapacityFactor: 0.134, OutputPerPV: 47.5},
+			{StartYear: 2019, EndYear: 2020, LocationID: 2, PerformanceRatio: 0.8, CapacityFactor: 0.134, OutputPerPV: 25},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.got) != len(tt.want) {
+				t.Fatalf("got %d rows, want %d: %+v", len(tt.got), len(tt.want), tt.got)
+			}
+			for i := range tt.want {
+				if tt.got[i] != tt.want[i] {
+					t.Errorf("row %d = %+v, want %+v", i, tt.got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCalculateDailyPerformance(t *testing.T) {
+	data := &memory.Data{
+		Days: []structure.SiteDay{
+			{Date: "2019-01-01", LocationID: 1, Actual: 4000},
+			{Date: "2019-01-02", LocationID: 1, Actual: 3000, Theoretical: 1},
+		},
+		WeatherDaily: []structure.WeatherDay{{Date: "2019-01-01", Sunshine: 36000, Irradiance: 500}},
+	}
+	calculator := newTestCalculator(data)
+	if err := calculator.CalculateDailyTheoreticalOutput(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A day without weather has its stale theoretical output cleared
+	if got := data.Days[0].Theoretical; got != 4575 {
+		t.Errorf("theoretical on 2019-01-01 = %v, want 4575", got)
+	}
+	if got := data.Days[1].Theoretical; got != 0 {
+		t.Errorf("theoretical on 2019-01-02 = %v, want 0", got)
+	}
+
+	if err := calculator.CalculateDailyPerformance(); err != nil {
+		t.Fatal(err)
+	}
+	want := []structure.SiteDay{{Date: "2019-01-01", LocationID: 1, Actual: 4000, Theoretical: 4575,
+		PerformanceRatio: 0.874, CapacityFactor: 0.167, OutputPerPV: 1}}
+	if len(data.DailyPerformance) != 1 || data.DailyPerformance[0] != want[0] {
+		t.Errorf("daily performance = %+v, want %+v", data.DailyPerformance, want)
+	}
+}
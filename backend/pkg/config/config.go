@@ -0,0 +1,368 @@
+package config
+
+import (
+	"backend/pkg/pipeline/cron"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Config is every setting of the server. Each field can be set, from lowest to highest
+// precedence, by the defaults, the JSON config file, its env variable and its flag. Relative
+// paths are resolved against Root.
+type Config struct {
+	Root      string          `json:"root" env:"SOLAR_ROOT" flag:"root" usage:"directory relative paths are resolved against"`
+	Server    ServerConfig    `json:"server"`
+	CORS      CORSConfig      `json:"cors"`
+	Auth      AuthConfig      `json:"auth"`
+	Database  DatabaseConfig  `json:"database"`
+	Data      DataConfig      `json:"data"`
+	Weather   WeatherConfig   `json:"weather"`
+	Pipeline  PipelineConfig  `json:"pipeline"`
+	Models    ModelConfig     `json:"models"`
+	Telemetry TelemetryConfig `json:"telemetry"`
+	Logging   LoggingConfig   `json:"logging"`
+	Freshness FreshnessConfig `json:"freshness"`
+}
+
+type ServerConfig struct {
+	Addr string `json:"addr" env:"SOLAR_ADDR" flag:"addr" usage:"address the HTTP server listens on"`
+
+	ReadHeaderTimeout Duration `json:"readHeaderTimeout" env:"SOLAR_READ_HEADER_TIMEOUT" flag:"read-header-timeout" usage:"how long a client may take to send request headers"`
+	ReadTimeout       Duration `json:"readTimeout" env:"SOLAR_READ_TIMEOUT" flag:"read-timeout" usage:"how long a client may take to send a whole request, including uploads"`
+	WriteTimeout      Duration `json:"writeTimeout" env:"SOLAR_WRITE_TIMEOUT" flag:"write-timeout" usage:"how long a handler may take to write its response"`
+	IdleTimeout       Duration `json:"idleTimeout" env:"SOLAR_IDLE_TIMEOUT" flag:"idle-timeout" usage:"how long an idle keep-alive connection is kept open"`
+	// ShutdownTimeout bounds draining requests and stopping the running job on SIGINT or SIGTERM
+	ShutdownTimeout Duration `json:"shutdownTimeout" env:"SOLAR_SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"how long shutdown waits for requests and the running job"`
+}
+
+// CORSConfig is which cross-origin browser requests are allowed. Methods is a ceiling: a route only
+// advertises the methods its handler supports.
+type CORSConfig struct {
+	Origins     []string `json:"origins" env:"SOLAR_CORS_ORIGINS" flag:"cors-origins" usage:"comma-separated origins allowed to call the API, or *"`
+	Methods     []string `json:"methods" env:"SOLAR_CORS_METHODS" flag:"cors-methods" usage:"comma-separated methods cross-origin requests may use"`
+	Headers     []string `json:"headers" env:"SOLAR_CORS_HEADERS" flag:"cors-headers" usage:"comma-separated request headers cross-origin requests may send"`
+	Credentials bool     `json:"credentials" env:"SOLAR_CORS_CREDENTIALS" flag:"cors-credentials" usage:"allow cookies and Authorization on cross-origin requests"`
+	MaxAge      Duration `json:"maxAge" env:"SOLAR_CORS_MAX_AGE" flag:"cors-max-age" usage:"how long browsers may cache a preflight response"`
+}
+
+// AuthConfig is how API users and keys are authenticated
+type AuthConfig struct {
+	// AnonymousRole is granted to requests without credentials; empty requires them everywhere
+	AnonymousRole string   `json:"anonymousRole" env:"SOLAR_ANONYMOUS_ROLE" flag:"anonymous-role" usage:"role of requests without credentials, or empty to require them"`
+	SessionTTL    Duration `json:"sessionTtl" env:"SOLAR_SESSION_TTL" flag:"session-ttl" usage:"how long a login session lasts"`
+	// SessionSecret signs session tokens. It has no flag so it never shows in the process list.
+	SessionSecret Secret `json:"sessionSecret" env:"SOLAR_SESSION_SECRET"`
+}
+
+// DatabaseConfig is where data is kept: the SQLite file at Path, or with Driver postgres the
+// PostgreSQL database at URL. The server and the model scripts read and write only that database.
+type DatabaseConfig struct {
+	Path   string `json:"path" env:"SOLAR_DB_PATH" flag:"db" path:"true" usage:"SQLite database file"`
+	Driver string `json:"driver" env:"SOLAR_DB_DRIVER" flag:"db-driver" usage:"database the data is kept in: sqlite or postgres"`
+	// URL is the PostgreSQL connection string. It has no flag so its password never shows in the process list.
+	URL       Secret `json:"url" env:"SOLAR_DB_URL"`
+	Timescale bool   `json:"timescale" env:"SOLAR_DB_TIMESCALE" flag:"db-timescale" usage:"keep the PostgreSQL weather and interval tables as TimescaleDB hypertables"`
+}
+
+// DataConfig holds the files generation and data quality settings are read from
+type DataConfig struct {
+	EnergyWorkbook  string `json:"energyWorkbook" env:"SOLAR_ENERGY_WORKBOOK" flag:"energy-workbook" path:"true" usage:"Excel workbook of monthly generation"`
+	WorkbookMapping string `json:"workbookMapping" env:"SOLAR_WORKBOOK_MAPPING" flag:"workbook-mapping" path:"true" usage:"JSON mapping of the workbook's sheets and headers"`
+	Imputation      string `json:"imputation" env:"SOLAR_IMPUTATION" flag:"imputation" path:"true" usage:"JSON imputation methods for each dataset"`
+}
+
+// WeatherConfig is where weather is fetched for
+type WeatherConfig struct {
+	Latitude       float64 `json:"latitude" env:"SOLAR_LATITUDE" flag:"latitude" usage:"latitude weather is fetched for"`
+	Longitude      float64 `json:"longitude" env:"SOLAR_LONGITUDE" flag:"longitude" usage:"longitude weather is fetched for"`
+	ArchiveURL     string  `json:"archiveUrl" env:"SOLAR_WEATHER_ARCHIVE_URL" flag:"weather-archive-url" usage:"Open-Meteo archive API"`
+	ForecastURL    string  `json:"forecastUrl" env:"SOLAR_WEATHER_FORECAST_URL" flag:"weather-forecast-url" usage:"Open-Meteo forecast API"`
+	SeasonalURL    string  `json:"seasonalUrl" env:"SOLAR_WEATHER_SEASONAL_URL" flag:"weather-seasonal-url" usage:"Open-Meteo seasonal API"`
+	ArchiveLagDays int     `json:"archiveLagDays" env:"SOLAR_WEATHER_ARCHIVE_LAG_DAYS" flag:"weather-archive-lag-days" usage:"days behind today the archive is complete"`
+}
+
+type PipelineConfig struct {
+	Schedule string `json:"schedule" env:"SOLAR_RECOMPUTE_SCHEDULE" flag:"schedule" usage:"cron schedule of the nightly recompute"`
+}
+
+// ModelConfig is how the Python model scripts are run
+type ModelConfig struct {
+	Python            string   `json:"python" env:"MODEL_PYTHON" flag:"python" usage:"Python interpreter; empty uses VIRTUAL_ENV, then python3"`
+	Timeout           Duration `json:"timeout" env:"MODEL_TIMEOUT" flag:"model-timeout" usage:"how long a model script may run"`
+	MonthlyForecast   string   `json:"monthlyForecast" env:"SOLAR_MONTHLY_FORECAST_SCRIPT" flag:"monthly-forecast-script" path:"true" usage:"monthly forecast model script"`
+	FeatureImportance string   `json:"featureImportance" env:"SOLAR_FEATURE_IMPORTANCE_SCRIPT" flag:"feature-importance-script" path:"true" usage:"weather-only feature importance model script"`
+	DailyForecast     string   `json:"dailyForecast" env:"SOLAR_DAILY_FORECAST_SCRIPT" flag:"daily-forecast-script" path:"true" usage:"daily forecast model script"`
+	// Artifacts holds the trained models, so scenarios predict without retraining
+	Artifacts string `json:"artifacts" env:"SOLAR_MODEL_ARTIFACTS" flag:"model-artifacts" path:"true" usage:"directory trained models are saved to"`
+}
+
+type TelemetryConfig struct {
+	Config string `json:"config" env:"SOLAR_TELEMETRY" flag:"telemetry" path:"true" usage:"JSON file of SCADA devices and logger directories to poll"`
+}
+
+type LoggingConfig struct {
+	Format string `json:"format" env:"SOLAR_LOG_FORMAT" flag:"log-format" usage:"log output format, json or text"`
+	Level  string `json:"level" env:"SOLAR_LOG_LEVEL" flag:"log-level" usage:"least severe level logged: debug, info, warn or error"`
+}
+
+// FreshnessConfig is how old data may get before /api/status reports it as stale. Generation and
+// weather months are aged from the end of the month.
+type FreshnessConfig struct {
+	Generation Duration `json:"generation" env:"SOLAR_STALE_GENERATION" flag:"stale-generation" usage:"age after which a site's latest generation month is stale"`
+	Weather    Duration `json:"weather" env:"SOLAR_STALE_WEATHER" flag:"stale-weather" usage:"age after which the latest weather day is stale"`
+	Pipeline   Duration `json:"pipeline" env:"SOLAR_STALE_PIPELINE" flag:"stale-pipeline" usage:"age after which the last successful pipeline run is stale"`
+	Model      Duration `json:"model" env:"SOLAR_STALE_MODEL" flag:"stale-model" usage:"age after which a trained model is stale"`
+}
+
+// Secret is a setting that is printed masked
+type Secret string
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	if s == "" {
+		return json.Marshal("")
+	}
+	return json.Marshal("********")
+}
+
+// Duration is a time.Duration written as a string such as "30m"
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30m\"")
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Defaults returns the settings used when nothing overrides them
+func Defaults() *Config {
+	return &Config{
+		Root: defaultRoot(),
+		Server: ServerConfig{
+			Addr: ":8080",
+
+			ReadHeaderTimeout: Duration(10 * time.Second),
+			ReadTimeout:       Duration(time.Minute),
+			WriteTimeout:      Duration(2 * time.Minute),
+			IdleTimeout:       Duration(2 * time.Minute),
+			ShutdownTimeout:   Duration(30 * time.Second),
+		},
+		CORS: CORSConfig{
+			Origins: []string{"*"},
+			Methods: []string{"GET", "POST", "PUT", "DELETE"},
+			Headers: []string{"Content-Type", "Authorization", "X-API-Key"},
+			MaxAge:  Duration(time.Hour),
+		},
+		Auth: AuthConfig{
+			AnonymousRole: "viewer",
+			SessionTTL:    Duration(12 * time.Hour),
+		},
+		Database: DatabaseConfig{Path: "pkg/db/app.db", Driver: "sqlite"},
+		Data: DataConfig{
+			EnergyWorkbook:  "pkg/db/BapcoSolarEnergy.xlsx",
+			WorkbookMapping: "pkg/db/energy_workbook.json",
+			Imputation:      "pkg/db/imputation.json",
+		},
+		Weather: WeatherConfig{
+			Latitude:       26,
+			Longitude:      50.55,
+			ArchiveURL:     "https://archive-api.open-meteo.com/v1/archive",
+			ForecastURL:    "https://api.open-meteo.com/v1/forecast",
+			SeasonalURL:    "https://seasonal-api.open-meteo.com/v1/seasonal",
+			ArchiveLagDays: 5,
+		},
+		Pipeline: PipelineConfig{Schedule: "0 2 * * *"},
+		Logging:  LoggingConfig{Format: "text", Level: "info"},
+		Models: ModelConfig{
+			Timeout:           Duration(30 * time.Minute),
+			MonthlyForecast:   "pkg/model/monthly/random_forest_model.py",
+			FeatureImportance: "pkg/model/monthly/weather_only_model.py",
+			DailyForecast:     "pkg/model/daily/daily_forecast_model.py",
+			Artifacts:         "pkg/model/artifacts",
+		},
+		Freshness: FreshnessConfig{
+			Generation: Duration(62 * 24 * time.Hour),
+			Weather:    Duration(10 * 24 * time.Hour),
+			Pipeline:   Duration(36 * time.Hour),
+			Model:      Duration(90 * 24 * time.Hour),
+		},
+	}
+}
+
+// defaultRoot is the backend directory: the working directory or the nearest parent holding
+// pkg/db, so the server runs from the backend directory, cmd/server or a checkout's root alike
+func defaultRoot() string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "."
+	}
+	for dir := wd; ; dir = filepath.Dir(dir) {
+		for _, candidate := range []string{dir, filepath.Join(dir, "backend")} {
+			if info, err := os.Stat(filepath.Join(candidate, "pkg", "db")); err == nil && info.IsDir() {
+				return candidate
+			}
+		}
+		if filepath.Dir(dir) == dir {
+			return wd
+		}
+	}
+}
+
+// Validate checks that the settings are usable and the files they name exist
+func (c *Config) Validate() error {
+	var problems []string
+	if _, _, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		problems = append(problems, fmt.Sprintf("server.addr %q: %v", c.Server.Addr, err))
+	}
+	timeouts := []struct {
+		name  string
+		value Duration
+	}{
+		{"server.readHeaderTimeout", c.Server.ReadHeaderTimeout},
+		{"server.readTimeout", c.Server.ReadTimeout},
+		{"server.writeTimeout", c.Server.WriteTimeout},
+		{"server.idleTimeout", c.Server.IdleTimeout},
+		{"server.shutdownTimeout", c.Server.ShutdownTimeout},
+	}
+	for _, t := range timeouts {
+		if t.value <= 0 {
+			problems = append(problems, t.name+" must be positive")
+		}
+	}
+	for _, origin := range c.CORS.Origins {
+		if origin == "*" && c.CORS.Credentials {
+			problems = append(problems, "cors.origins cannot be * when cors.credentials is set; list the origins")
+		} else if origin != "*" && !strings.Contains(origin, "://") {
+			problems = append(problems, fmt.Sprintf("cors.origins %q must be * or a scheme and host such as https://example.com", origin))
+		}
+	}
+	for _, method := range c.CORS.Methods {
+		if method != strings.ToUpper(method) || strings.ContainsAny(method, " ,") {
+			problems = append(problems, fmt.Sprintf("cors.methods %q must be an upper-case method name", method))
+		}
+	}
+	switch c.Auth.AnonymousRole {
+	case "", "viewer", "analyst", "operator", "admin":
+	default:
+		problems = append(problems, fmt.Sprintf("auth.anonymousRole %q must be empty, viewer, analyst, operator or admin", c.Auth.AnonymousRole))
+	}
+	if c.Auth.SessionTTL <= 0 {
+		problems = append(problems, "auth.sessionTtl must be positive")
+	}
+	if c.Auth.SessionSecret != "" && len(c.Auth.SessionSecret) < 32 {
+		problems = append(problems, "auth.sessionSecret must be at least 32 characters")
+	}
+	if c.CORS.MaxAge < 0 {
+		problems = append(problems, "cors.maxAge cannot be negative")
+	}
+	switch c.Database.Driver {
+	case "sqlite":
+		if c.Database.Path == "" {
+			problems = append(problems, "database.path is required when database.driver is sqlite")
+		} else if _, err := os.Stat(filepath.Dir(c.Database.Path)); err != nil {
+			problems = append(problems, fmt.Sprintf("database.path: %v", err))
+		}
+		if c.Database.Timescale {
+			problems = append(problems, "database.timescale needs database.driver postgres")
+		}
+	case "postgres":
+		if c.Database.URL == "" {
+			problems = append(problems, "database.url is required when database.driver is postgres")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("database.driver %q must be sqlite or postgres", c.Database.Driver))
+	}
+
+	files := []struct {
+		name, path string
+		optional   bool
+	}{
+		{"data.energyWorkbook", c.Data.EnergyWorkbook, false},
+		{"data.workbookMapping", c.Data.WorkbookMapping, false},
+		{"data.imputation", c.Data.Imputation, false},
+		{"models.monthlyForecast", c.Models.MonthlyForecast, false},
+		{"models.featureImportance", c.Models.FeatureImportance, false},
+		{"models.dailyForecast", c.Models.DailyForecast, false},
+		{"telemetry.config", c.Telemetry.Config, true},
+	}
+	for _, f := range files {
+		if f.path == "" {
+			if !f.optional {
+				problems = append(problems, f.name+" is required")
+			}
+			continue
+		}
+		if _, err := os.Stat(f.path); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", f.name, err))
+		}
+	}
+	// The directory is created by the first training run, so only its parent has to exist
+	if c.Models.Artifacts == "" {
+		problems = append(problems, "models.artifacts is required")
+	} else if _, err := os.Stat(filepath.Dir(c.Models.Artifacts)); err != nil {
+		problems = append(problems, fmt.Sprintf("models.artifacts: %v", err))
+	}
+
+	if c.Weather.Latitude < -90 || c.Weather.Latitude > 90 {
+		problems = append(problems, "weather.latitude must be between -90 and 90")
+	}
+	if c.Weather.Longitude < -180 || c.Weather.Longitude > 180 {
+		problems = append(problems, "weather.longitude must be between -180 and 180")
+	}
+	if c.Weather.ArchiveLagDays < 0 {
+		problems = append(problems, "weather.archiveLagDays cannot be negative")
+	}
+	switch strings.ToLower(c.Logging.Format) {
+	case "json", "text":
+	default:
+		problems = append(problems, fmt.Sprintf("logging.format %q must be json or text", c.Logging.Format))
+	}
+	switch strings.ToLower(c.Logging.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, fmt.Sprintf("logging.level %q must be debug, info, warn or error", c.Logging.Level))
+	}
+	if c.Pipeline.Schedule == "" {
+		problems = append(problems, "pipeline.schedule is required")
+	} else if _, err := cron.Parse(c.Pipeline.Schedule); err != nil {
+		problems = append(problems, fmt.Sprintf("pipeline.schedule: %v", err))
+	}
+	if c.Models.Timeout <= 0 {
+		problems = append(problems, "models.timeout must be positive")
+	}
+	ages := []struct {
+		name string
+		age  Duration
+	}{
+		{"generation", c.Freshness.Generation},
+		{"weather", c.Freshness.Weather},
+		{"pipeline", c.Freshness.Pipeline},
+		{"model", c.Freshness.Model},
+	}
+	for _, a := range ages {
+		if a.age <= 0 {
+			problems = append(problems, fmt.Sprintf("freshness.%s must be positive", a.name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a JSON config file and returns its path
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	root, err := filepath.Abs("../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := writeConfig(t, `{"server": {"addr": ":9000"}, "weather": {"latitude": 25}, "models": {"timeout": "10m"}}`)
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		args     []string
+		addr     string
+		latitude float64
+		timeout  time.Duration
+	}{
+		{"defaults", nil, nil, ":8080", 26, 30 * time.Minute},
+		{"file", nil, []string{"-config", file}, ":9000", 25, 10 * time.Minute},
+		{"file from the environment", map[string]string{"SOLAR_CONFIG": file}, nil, ":9000", 25, 10 * time.Minute},
+		{"environment over the file", map[string]string{"SOLAR_ADDR": ":9100", "MODEL_TIMEOUT": "5m"},
+			[]string{"-config", file}, ":9100", 25, 5 * time.Minute},
+		{"flag over the environment", map[string]string{"SOLAR_ADDR": ":9100"},
+			[]string{"-config", file, "-addr", ":9200", "-latitude", "24.5"}, ":9200", 24.5, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			fs := flag.NewFlagSet("server", flag.ContinueOnError)
+			loader := Bind(fs)
+			if err := fs.Parse(append([]string{"-root", root}, tt.args...)); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := loader.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Server.Addr != tt.addr || cfg.Weather.Latitude != tt.latitude || time.Duration(cfg.Models.Timeout) != tt.timeout {
+				t.Errorf("Load() = addr %s, latitude %v, timeout %v, want %s, %v, %v",
+					cfg.Server.Addr, cfg.Weather.Latitude, time.Duration(cfg.Models.Timeout), tt.addr, tt.latitude, tt.timeout)
+			}
+			// Relative paths are resolved against the root
+			if want := filepath.Join(root, "pkg", "db", "app.db"); cfg.Database.Path != want {
+				t.Errorf("database.path = %s, want %s", cfg.Database.Path, want)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	root, err := filepath.Abs("../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		err  string
+	}{
+		{"missing file", nil, []string{"-config", "missing.json"}, "error opening config file"},
+		{"unknown setting", nil, []string{"-config", writeConfig(t, `{"server": {"port": 80}}`)}, "unknown field"},
+		{"unparseable environment", map[string]string{"SOLAR_LATITUDE": "north"}, nil, "error reading SOLAR_LATITUDE"},
+		{"unparseable flag", nil, []string{"-model-timeout", "soon"}, "error reading -model-timeout"},
+		// A bool flag needs no value
+		{"credentials with any origin", nil, []string{"-cors-credentials"}, "cors.origins cannot be *"},
+		{"invalid schedule", map[string]string{"SOLAR_RECOMPUTE_SCHEDULE": "every night"}, nil, "pipeline.schedule"},
+		{"missing model script", nil, []string{"-daily-forecast-script", "missing.py"}, "models.dailyForecast"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			fs := flag.NewFlagSet("server", flag.ContinueOnError)
+			loader := Bind(fs)
+			if err := fs.Parse(append([]string{"-root", root}, tt.args...)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Load() = %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		err    string
+	}{
+		{"defaults", func(c *Config) {}, ""},
+		{"address without a port", func(c *Config) { c.Server.Addr = "localhost" }, "server.addr"},
+		{"zero shutdown timeout", func(c *Config) { c.Server.ShutdownTimeout = 0 }, "server.shutdownTimeout"},
+		{"CORS origin without a scheme", func(c *Config) { c.CORS.Origins = []string{"dash.example"} }, "cors.origins"},
+		{"any origin with credentials", func(c *Config) { c.CORS.Credentials = true }, "cors.origins cannot be *"},
+		{"listed origins with credentials", func(c *Config) {
+			c.CORS.Origins, c.CORS.Credentials = []string{"https://dash.example"}, true
+		}, ""},
+		{"lower-case CORS method", func(c *Config) { c.CORS.Methods = []string{"get"} }, "cors.methods"},
+		{"latitude out of range", func(c *Config) { c.Weather.Latitude = 91 }, "weather.latitude"},
+		{"negative archive lag", func(c *Config) { c.Weather.ArchiveLagDays = -1 }, "weather.archiveLagDays"},
+		{"no schedule", func(c *Config) { c.Pipeline.Schedule = "" }, "pipeline.schedule is required"},
+		{"descriptor schedule", func(c *Config) { c.Pipeline.Schedule = "@daily" }, ""},
+		{"zero timeout", func(c *Config) { c.Models.Timeout = 0 }, "models.timeout"},
+		{"no model artifacts", func(c *Config) { c.Models.Artifacts = "" }, "models.artifacts is required"},
+		{"model artifacts under a missing directory", func(c *Config) { c.Models.Artifacts = "missing/artifacts" }, "models.artifacts"},
+		{"unknown log format", func(c *Config) { c.Logging.Format = "xml" }, "logging.format"},
+		{"unknown log level", func(c *Config) { c.Logging.Level = "verbose" }, "logging.level"},
+		{"zero freshness", func(c *Config) { c.Freshness.Pipeline = 0 }, "freshness.pipeline"},
+		{"optional telemetry", func(c *Config) { c.Telemetry.Config = "" }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Defaults()
+			c.Root = "../.."
+			c.resolvePaths()
+			tt.modify(c)
+
+			err := c.Validate()
+			if tt.err == "" && err != nil {
+				t.Fatalf("Validate() = %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
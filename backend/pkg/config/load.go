@@ -0,0 +1,227 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Loader registers a flag for every setting and builds the configuration once flags are parsed
+type Loader struct {
+	fs    *flag.FlagSet
+	file  *string
+	flags map[string]*settingFlag
+}
+
+// settingFlag holds a flag's raw value until Load parses it into the setting. Boolean settings
+// are bool flags, so -cors-credentials works without a value.
+type settingFlag struct {
+	value  string
+	isBool bool
+}
+
+func (f *settingFlag) String() string     { return f.value }
+func (f *settingFlag) Set(v string) error { f.value = v; return nil }
+func (f *settingFlag) IsBoolFlag() bool   { return f.isBool }
+
+// Bind registers -config and one flag per setting on fs. Flags keep their raw value so that only
+// the ones given on the command line override the file and environment.
+func Bind(fs *flag.FlagSet) *Loader {
+	l := &Loader{
+		fs:    fs,
+		file:  fs.String("config", "", "JSON config file (default $SOLAR_CONFIG)"),
+		flags: make(map[string]*settingFlag),
+	}
+	defaults := Defaults()
+	walk(reflect.ValueOf(defaults).Elem(), "", func(f field) {
+		if f.flag == "" {
+			return
+		}
+		setting := &settingFlag{isBool: f.value.Kind() == reflect.Bool}
+		l.flags[f.flag] = setting
+		fs.Var(setting, f.flag, fmt.Sprintf("%s (default %s)", f.usage, f.display()))
+	})
+	return l
+}
+
+// Load layers the defaults, the config file, the environment and the flags given, resolves
+// relative paths against the root and validates the result
+func (l *Loader) Load() (*Config, error) {
+	cfg := Defaults()
+
+	path := *l.file
+	if path == "" {
+		path = os.Getenv("SOLAR_CONFIG")
+	}
+	if path != "" {
+		if err := readFile(path, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	given := make(map[string]bool)
+	l.fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	var err error
+	walk(reflect.ValueOf(cfg).Elem(), "", func(f field) {
+		if err != nil {
+			return
+		}
+		if value, ok := os.LookupEnv(f.env); ok && f.env != "" {
+			if setErr := f.set(value); setErr != nil {
+				err = fmt.Errorf("error reading %s: %v", f.env, setErr)
+				return
+			}
+		}
+		if given[f.flag] {
+			if setErr := f.set(l.flags[f.flag].value); setErr != nil {
+				err = fmt.Errorf("error reading -%s: %v", f.flag, setErr)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.resolvePaths()
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+	return cfg, nil
+}
+
+func readFile(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening config file: %v", err)
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("error reading config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// resolvePaths makes the root and every path setting absolute
+func (c *Config) resolvePaths() {
+	if root, err := filepath.Abs(c.Root); err == nil {
+		c.Root = root
+	}
+	walk(reflect.ValueOf(c).Elem(), "", func(f field) {
+		if !f.path {
+			return
+		}
+		value := f.value.String()
+		if value != "" && !filepath.IsAbs(value) {
+			f.value.SetString(filepath.Join(c.Root, value))
+		}
+	})
+}
+
+// field is one leaf setting with the tags that name it
+type field struct {
+	name  string
+	env   string
+	flag  string
+	usage string
+	path  bool
+	value reflect.Value
+}
+
+var durationType = reflect.TypeOf(Duration(0))
+
+// walk calls fn for every leaf setting of the struct v, naming each by its JSON path
+func walk(v reflect.Value, prefix string, fn func(field)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := prefix + strings.Split(sf.Tag.Get("json"), ",")[0]
+		if sf.Type.Kind() == reflect.Struct {
+			walk(v.Field(i), name+".", fn)
+			continue
+		}
+		fn(field{
+			name:  name,
+			env:   sf.Tag.Get("env"),
+			flag:  sf.Tag.Get("flag"),
+			usage: sf.Tag.Get("usage"),
+			path:  sf.Tag.Get("path") == "true",
+			value: v.Field(i),
+		})
+	}
+}
+
+// set parses a string from the environment or a flag into the setting
+func (f field) set(value string) error {
+	v := f.value
+	switch {
+	case v.Type() == durationType:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(parsed))
+	case v.Kind() == reflect.String:
+		v.SetString(value)
+	case v.Kind() == reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(parsed)
+	case v.Kind() == reflect.Int:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(parsed))
+	case v.Kind() == reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(parsed)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// display is the default shown in the flag's usage
+func (f field) display() string {
+	v := f.value
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Slice:
+		return strings.Join(v.Interface().([]string), ",")
+	case v.Kind() == reflect.String && v.String() == "":
+		return "none"
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// Print writes the configuration as indented JSON
+func (c *Config) Print(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(c)
+}
@@ -0,0 +1,499 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Asset kinds, from the top of the hierarchy down. A string asset also covers an MPPT input.
+const (
+	AssetSite     = "site"
+	AssetInverter = "inverter"
+	AssetString   = "string"
+)
+
+// workbookAsset is a per-array sub-column of a site sheet in the energy workbook
+type workbookAsset struct {
+	sheet      string
+	header     string
+	locationID int
+}
+
+// SyncAssets makes sure every site has a site asset matching its locations row, and registers
+// an inverter asset for every workbook sub-column and every inverter seen in interval generation.
+// Assets that already exist keep their edited name, capacity and metadata.
+func (l *Loader) SyncAssets(ctx context.Context) error {
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO assets (location_id, kind, code, name, capacity_kw, number_of_panels)
+		SELECT id, 'site', name, name, installed_capacity_kw, number_of_panels
+		FROM locations
+		WHERE name != 'Total System'
+		ON CONFLICT (location_id, code) DO UPDATE SET
+			capacity_kw = excluded.capacity_kw,
+			number_of_panels = excluded.number_of_panels,
+			last_updated = CURRENT_TIMESTAMP
+	`)
+	if err != nil {
+		return fmt.Errorf("error syncing site assets: %v", err)
+	}
+
+	columns, err := l.workbookAssetColumns()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`
+		INSERT INTO assets (location_id, parent_id, kind, code, name, metadata_json)
+		SELECT s.location_id, s.id, 'inverter', ?, ?, ?
+		FROM assets s
+		WHERE s.location_id = ? AND s.kind = 'site'
+		ON CONFLICT (location_id, code) DO NOTHING
+	`)
+	if err != nil {
+		return fmt.Errorf("error preparing statement: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, column := range columns {
+		metadata, _ := json.Marshal(map[string]string{"workbookSheet": column.sheet, "workbookColumn": column.header})
+		if _, err := stmt.Exec(column.header, assetName(column.header), string(metadata), column.locationID); err != nil {
+			return fmt.Errorf("error registering workbook asset %s/%s: %v", column.sheet, column.header, err)
+		}
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO assets (location_id, parent_id, kind, code, name)
+		SELECT DISTINCT g.location_id, s.id, 'inverter', g.inverter, g.inverter
+		FROM interval_generation g
+		JOIN assets s ON s.location_id = g.location_id AND s.kind = 'site'
+		WHERE g.inverter != ''
+		ON CONFLICT (location_id, code) DO NOTHING
+	`)
+	if err != nil {
+		return fmt.Errorf("error registering interval generation assets: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing assets: %v", err)
+	}
+	return nil
+}
+
+// ImportAssetGeneration loads per-asset monthly generation from the workbook sub-columns, rebuilds
+// per-asset daily generation from interval readings, and fills monthly generation from daily totals
+// for fully covered months. Uploaded monthly values are never overwritten.
+func (l *Loader) ImportAssetGeneration(ctx context.Context) error {
+	if err := l.importWorkbookAssetGeneration(ctx); err != nil {
+		return err
+	}
+	if err := l.aggregateAssetIntervals(ctx); err != nil {
+		return err
+	}
+
+	dialect := l.db.Dialect
+	_, err := l.db.ExecContext(ctx, `
+		INSERT INTO asset_monthly_generation (year, month, asset_id, actual_kwh, source)
+		SELECT
+			`+dialect.YearOf("date")+` as year,
+			`+dialect.MonthOf("date")+` as month,
+			asset_id,
+			`+dialect.Round("SUM(actual_kwh)", 2)+`,
+			'interval'
+		FROM asset_daily_generation
+		GROUP BY year, month, asset_id
+		HAVING COUNT(*) = `+dialect.DaysInMonth("MIN(date)")+`
+		ON CONFLICT (year, month, asset_id)
+		DO UPDATE SET actual_kwh = excluded.actual_kwh, source = excluded.source
+		WHERE asset_monthly_generation.source != 'csv'
+	`)
+	if err != nil {
+		return fmt.Errorf("error aggregating asset monthly generation: %v", err)
+	}
+	return nil
+}
+
+// ImportAssetMonthlyCSV stores per-asset monthly totals from a CSV with site, asset, year, month
+// and energy_kwh columns. asset is the asset code, such as an inverter serial or workbook column.
+func (l *Loader) ImportAssetMonthlyCSV(r io.Reader) (int, error) {
+	records, columns, err := readGenerationCSV(r, "site", "asset", "year", "month", "energy_kwh")
+	if err != nil {
+		return 0, err
+	}
+
+	sites, err := l.siteIDs()
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := l.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO asset_monthly_generation (year, month, asset_id, actual_kwh, source)
+		VALUES (?, ?, ?, ?, 'csv')
+		ON CONFLICT (year, month, asset_id)
+		DO UPDATE SET actual_kwh = excluded.actual_kwh, source = excluded.source
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("error preparing statement: %v", err)
+	}
+	defer stmt.Close()
+
+	for i, record := range records {
+		line := i + 2
+		locationID, err := lookupSite(sites, record[columns["site"]])
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		code := strings.TrimSpace(record[columns["asset"]])
+		var assetID int
+		err = tx.QueryRow(`SELECT id FROM assets WHERE location_id = ? AND code = ? AND kind != 'site'`, locationID, code).Scan(&assetID)
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("line %d: unknown asset %q at %s", line, code, record[columns["site"]])
+		}
+		if err != nil {
+			return 0, fmt.Errorf("line %d: error looking up asset: %v", line, err)
+		}
+
+		year, yearErr := strconv.Atoi(strings.TrimSpace(record[columns["year"]]))
+		month, monthErr := strconv.Atoi(strings.TrimSpace(record[columns["month"]]))
+		if yearErr != nil || monthErr != nil || month < 1 || month > 12 {
+			return 0, fmt.Errorf("line %d: invalid year or month", line)
+		}
+		energy, err := parseEnergy(record[columns["energy_kwh"]])
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		if _, err := stmt.Exec(year, month, assetID, energy); err != nil {
+			return 0, fmt.Errorf("line %d: error inserting asset generation: %v", line, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing asset generation: %v", err)
+	}
+
+	slog.Info("Imported asset monthly generation", "rows", len(records))
+	return len(records), nil
+}
+
+// workbookAssetColumns lists the asset sub-columns the workbook mapping declares for each site sheet
+func (l *Loader) workbookAssetColumns() ([]workbookAsset, error) {
+	sites, err := l.siteIDs()
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := LoadWorkbookMapping(l.files.WorkbookMapping)
+	if err != nil {
+		return nil, err
+	}
+
+	var columns []workbookAsset
+	for _, sheet := range mapping.Sheets {
+		locationID, err := lookupSite(sites, sheet.Site)
+		if err != nil {
+			return nil, fmt.Errorf("workbook sheet %s: %v", sheet.Sheet, err)
+		}
+		for _, header := range sheet.Assets {
+			columns = append(columns, workbookAsset{sheet: sheet.Sheet, header: normalizeHeader(header), locationID: locationID})
+		}
+	}
+	return columns, nil
+}
+
+func (l *Loader) importWorkbookAssetGeneration(ctx context.Context) error {
+	workbook, err := l.readEnergyWorkbook()
+	if err != nil {
+		return fmt.Errorf("error reading energy workbook: %w", err)
+	}
+	if len(workbook.AssetMonths) == 0 {
+		return nil
+	}
+
+	sites, err := l.siteIDs()
+	if err != nil {
+		return err
+	}
+
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO asset_monthly_generation (year, month, asset_id, actual_kwh, source)
+		SELECT ?, ?, id, ?, 'workbook'
+		FROM assets
+		WHERE location_id = ? AND code = ?
+		ON CONFLICT (year, month, asset_id)
+		DO UPDATE SET actual_kwh = excluded.actual_kwh
+		WHERE asset_monthly_generation.source = 'workbook'
+	`)
+	if err != nil {
+		return fmt.Errorf("error preparing statement: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, month := range workbook.AssetMonths {
+		locationID, err := lookupSite(sites, month.Site)
+		if err != nil {
+			return fmt.Errorf("workbook sheet %s: %v", month.Sheet, err)
+		}
+		if _, err := stmt.Exec(month.Year, month.Month, month.ActualKWh, locationID, month.Asset); err != nil {
+			return fmt.Errorf("sheet %s: error inserting %s for %04d-%02d: %v", month.Sheet, month.Asset, month.Year, month.Month, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing workbook asset generation: %v", err)
+	}
+	return nil
+}
+
+type assetNode struct {
+	id       int
+	parentID int
+	kind     string
+	children []int
+}
+
+type assetDay struct {
+	energy    float64
+	producing int
+	daylight  int
+}
+
+// aggregateAssetIntervals rebuilds asset_daily_generation from interval_generation. An interval
+// counts as daylight when the site produced anything in it, and as producing for an asset when
+// that asset produced anything. From its first reading on, an asset with no reading in a daylight
+// interval counts as not producing, so a tripped inverter lowers availability rather than vanishing.
+// Inverters without their own readings are the sum of their strings.
+func (l *Loader) aggregateAssetIntervals(ctx context.Context) error {
+	assets := make(map[int]*assetNode)
+	byCode := make(map[string]int)
+	rows, err := l.db.QueryContext(ctx, `SELECT id, COALESCE(parent_id, 0), kind, location_id, code FROM assets`)
+	if err != nil {
+		return fmt.Errorf("error querying assets: %v", err)
+	}
+	for rows.Next() {
+		var node assetNode
+		var locationID int
+		var code string
+		if err := rows.Scan(&node.id, &node.parentID, &node.kind, &locationID, &code); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning asset: %v", err)
+		}
+		assets[node.id] = &node
+		if node.kind != AssetSite {
+			byCode[fmt.Sprintf("%d/%s", locationID, code)] = node.id
+		}
+	}
+	rows.Close()
+	for _, node := range assets {
+		if parent, ok := assets[node.parentID]; ok {
+			parent.children = append(parent.children, node.id)
+		}
+	}
+
+	// Assets that report interval data, per site, from their first reading
+	reporting := make(map[int]map[int]time.Time)
+	rows, err = l.db.QueryContext(ctx, `
+		SELECT location_id, inverter, MIN(interval_start)
+		FROM interval_generation
+		WHERE inverter != ''
+		GROUP BY location_id, inverter
+	`)
+	if err != nil {
+		return fmt.Errorf("error querying interval assets: %v", err)
+	}
+	for rows.Next() {
+		var locationID int
+		var inverter, first string
+		if err := rows.Scan(&locationID, &inverter, &first); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning interval asset: %v", err)
+		}
+		id, ok := byCode[fmt.Sprintf("%d/%s", locationID, inverter)]
+		if !ok {
+			continue
+		}
+		firstAt, err := parseIntervalStart(first)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		if reporting[locationID] == nil {
+			reporting[locationID] = make(map[int]time.Time)
+		}
+		ids := []int{id}
+		if assets[id].kind == AssetString {
+			// The inverter reports through this string
+			ids = append(ids, assets[id].parentID)
+		}
+		for _, assetID := range ids {
+			if at, seen := reporting[locationID][assetID]; !seen || firstAt.Before(at) {
+				reporting[locationID][assetID] = firstAt
+			}
+		}
+	}
+	rows.Close()
+
+	rows, err = l.db.QueryContext(ctx, `
+		SELECT interval_start, location_id, inverter, energy_kwh
+		FROM interval_generation
+		ORDER BY interval_start, location_id
+	`)
+	if err != nil {
+		return fmt.Errorf("error querying interval generation: %v", err)
+	}
+	defer rows.Close()
+
+	days := make(map[string]map[int]*assetDay)
+	var current struct {
+		start      time.Time
+		locationID int
+	}
+	meter, direct := -1.0, make(map[int]float64)
+
+	flush := func() {
+		if len(direct) == 0 {
+			return
+		}
+		energies := rollUpIntervals(assets, direct)
+		siteTotal := meter
+		if siteTotal < 0 {
+			siteTotal = 0
+			for id, energy := range energies {
+				if assets[id].parentID != 0 && assets[assets[id].parentID].kind == AssetSite {
+					siteTotal += energy
+				}
+			}
+		}
+
+		date := current.start.Format("2006-01-02")
+		if days[date] == nil {
+			days[date] = make(map[int]*assetDay)
+		}
+		for id, first := range reporting[current.locationID] {
+			if current.start.Before(first) {
+				continue
+			}
+			energy := energies[id]
+			day := days[date][id]
+			if day == nil {
+				day = &assetDay{}
+				days[date][id] = day
+			}
+			day.energy += energy
+			if siteTotal > 0 {
+				day.daylight++
+				if energy > 0 {
+					day.producing++
+				}
+			}
+		}
+	}
+
+	for rows.Next() {
+		var start time.Time
+		var locationID int
+		var inverter string
+		var energy float64
+		if err := rows.Scan(&start, &locationID, &inverter, &energy); err != nil {
+			return fmt.Errorf("error scanning interval generation: %v", err)
+		}
+		if !start.Equal(current.start) || locationID != current.locationID {
+			flush()
+			current.start, current.locationID = start, locationID
+			meter, direct = -1, make(map[int]float64)
+		}
+
+		if inverter == "" {
+			meter = energy
+			continue
+		}
+		if id, ok := byCode[fmt.Sprintf("%d/%s", locationID, inverter)]; ok {
+			direct[id] += energy
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading interval generation: %v", err)
+	}
+	flush()
+
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM asset_daily_generation`); err != nil {
+		return fmt.Errorf("error clearing asset daily generation: %v", err)
+	}
+	stmt, err := tx.Prepare(`
+		INSERT INTO asset_daily_generation (date, asset_id, actual_kwh, producing_intervals, daylight_intervals)
+		VALUES (?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("error preparing statement: %v", err)
+	}
+	defer stmt.Close()
+
+	for date, byAsset := range days {
+		for id, day := range byAsset {
+			if _, err := stmt.Exec(date, id, day.energy, day.producing, day.daylight); err != nil {
+				return fmt.Errorf("error inserting asset daily generation: %v", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing asset daily generation: %v", err)
+	}
+	return nil
+}
+
+// rollUpIntervals adds inverters that only report through their strings
+func rollUpIntervals(assets map[int]*assetNode, direct map[int]float64) map[int]float64 {
+	energies := make(map[int]float64, len(direct))
+	for id, energy := range direct {
+		energies[id] = energy
+	}
+	for id, energy := range direct {
+		node := assets[id]
+		if node.kind != AssetString {
+			continue
+		}
+		if _, reported := direct[node.parentID]; !reported {
+			energies[node.parentID] += energy
+		}
+	}
+	return energies
+}
+
+// assetName turns a workbook header such as "west_parking" into "West Parking"
+func assetName(header string) string {
+	words := strings.Fields(strings.ReplaceAll(header, "_", " "))
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
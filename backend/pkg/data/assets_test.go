@@ -0,0 +1,49 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRollUpIntervals(t *testing.T) {
+	assets := map[int]*assetNode{
+		1: {id: 1, kind: AssetSite},
+		2: {id: 2, parentID: 1, kind: AssetInverter},
+		3: {id: 3, parentID: 1, kind: AssetInverter},
+		4: {id: 4, parentID: 2, kind: AssetString},
+		5: {id: 5, parentID: 3, kind: AssetString},
+		6: {id: 6, parentID: 3, kind: AssetString},
+	}
+	tests := []struct {
+		name   string
+		direct map[int]float64
+		want   map[int]float64
+	}{
+		{"inverters reporting themselves", map[int]float64{2: 10, 3: 20}, map[int]float64{2: 10, 3: 20}},
+		{"inverter through its strings", map[int]float64{5: 4, 6: 6}, map[int]float64{3: 10, 5: 4, 6: 6}},
+		// The inverter's own reading is kept rather than double counting its strings
+		{"inverter and its strings", map[int]float64{2: 10, 4: 9}, map[int]float64{2: 10, 4: 9}},
+		{"nothing reported", map[int]float64{}, map[int]float64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rollUpIntervals(assets, tt.direct); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rollUpIntervals() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssetName(t *testing.T) {
+	tests := map[string]string{
+		"west_parking":   "West Parking",
+		"inverter 3":     "Inverter 3",
+		"  car_park__a ": "Car Park A",
+		"UOB":            "UOB",
+	}
+	for header, want := range tests {
+		if got := assetName(header); got != want {
+			t.Errorf("assetName(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package data
+
+import (
+	"backend/pkg/db"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultIntervalMinutes is the SCADA meter export resolution
+const defaultIntervalMinutes = 15
+
+var intervalLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04"}
+
+// ImportDailyGenerationCSV stores daily meter totals from a CSV with date, site and
+// energy_kwh columns. Total System is derived from the sites and cannot be imported.
+func (l *Loader) ImportDailyGenerationCSV(r io.Reader) (int, error) {
+	records, columns, err := readGenerationCSV(r, "date", "site", "energy_kwh")
+	if err != nil {
+		return 0, err
+	}
+
+	sites, err := l.siteIDs()
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := l.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO daily_generation (date, location_id, actual_kwh, source)
+		VALUES (?, ?, ?, 'daily')
+		ON CONFLICT (date, location_id)
+		DO UPDATE SET actual_kwh = excluded.actual_kwh, source = excluded.source
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("error preparing statement: %v", err)
+	}
+	defer stmt.Close()
+
+	for i, record := range records {
+		line := i + 2
+		date, err := time.Parse("2006-01-02", record[columns["date"]])
+		if err != nil {
+			return 0, fmt.Errorf("line %d: invalid date %q", line, record[columns["date"]])
+		}
+		locationID, err := lookupSite(sites, record[columns["site"]])
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %v", line, err)
+		}
+		energy, err := parseEnergy(record[columns["energy_kwh"]])
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		if _, err := stmt.Exec(date.Format("2006-01-02"), locationID, energy); err != nil {
+			return 0, fmt.Errorf("line %d: error inserting daily generation: %v", line, err)
+		}
+	}
+
+	if err := deriveDailyTotalSystem(tx, l.db.Dialect); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing daily generation: %v", err)
+	}
+
+	slog.Info("Imported daily generation", "rows", len(records))
+	return len(records), nil
+}
+
+// ImportIntervalGenerationCSV stores meter readings from a CSV with timestamp, site and
+// energy_kwh columns, and optional inverter (or asset, for string-level readings) and interval_minutes
+// (default 15) columns. Timestamps are the start of the interval in plant local time; an RFC 3339
+// offset is dropped, not converted.
+func (l *Loader) ImportIntervalGenerationCSV(r io.Reader) (int, error) {
+	records, columns, err := readGenerationCSV(r, "timestamp", "site", "energy_kwh")
+	if err != nil {
+		return 0, err
+	}
+
+	sites, err := l.siteIDs()
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := l.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO interval_generation (interval_start, interval_minutes, location_id, inverter, energy_kwh, source)
+		VALUES (?, ?, ?, ?, ?, 'csv')
+		ON CONFLICT (interval_start, location_id, inverter)
+		DO UPDATE SET interval_minutes = excluded.interval_minutes, energy_kwh = excluded.energy_kwh, source = excluded.source
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("error preparing statement: %v", err)
+	}
+	defer stmt.Close()
+
+	minutesIndex, hasMinutes := columns["interval_minutes"]
+	inverterIndex, hasInverter := columns["inverter"]
+	if !hasInverter {
+		inverterIndex, hasInverter = columns["asset"]
+	}
+	for i, record := range records {
+		line := i + 2
+		start, err := parseIntervalStart(record[columns["timestamp"]])
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %v", line, err)
+		}
+		locationID, err := lookupSite(sites, record[columns["site"]])
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %v", line, err)
+		}
+		energy, err := parseEnergy(record[columns["energy_kwh"]])
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		minutes := defaultIntervalMinutes
+		if hasMinutes && record[minutesIndex] != "" {
+			minutes, err = strconv.Atoi(record[minutesIndex])
+			if err != nil || minutes <= 0 || 1440%minutes != 0 {
+				return 0, fmt.Errorf("line %d: invalid interval_minutes %q", line, record[minutesIndex])
+			}
+		}
+
+		inverter := ""
+		if hasInverter {
+			inverter = strings.TrimSpace(record[inverterIndex])
+		}
+
+		if _, err := stmt.Exec(start.Format("2006-01-02 15:04:05"), minutes, locationID, inverter, energy); err != nil {
+			return 0, fmt.Errorf("line %d: error inserting interval generation: %v", line, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing interval generation: %v", err)
+	}
+
+	slog.Info("Imported interval generation", "rows", len(records))
+	return len(records), nil
+}
+
+// AggregateIntervalGeneration sums interval readings into daily totals. Days with an imported
+// daily total keep it, since the meter's own daily register is more reliable than a sum of readings.
+// Likewise a site meter reading (no inverter) wins over the sum of that site's inverters for the day.
+func (l *Loader) AggregateIntervalGeneration(ctx context.Context) error {
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	dialect := l.db.Dialect
+	_, err = tx.Exec(`
+		INSERT INTO daily_generation (date, location_id, actual_kwh, source)
+		SELECT ` + dialect.DateOf("interval_start") + `, location_id,
+			` + dialect.Round(`CASE WHEN COUNT(CASE WHEN inverter = '' THEN 1 END) > 0
+				THEN SUM(CASE WHEN inverter = '' THEN energy_kwh END)
+				ELSE SUM(energy_kwh) END`, 2) + `,
+			'interval'
+		FROM interval_generation
+		GROUP BY ` + dialect.DateOf("interval_start") + `, location_id
+		ON CONFLICT (date, location_id)
+		DO UPDATE SET actual_kwh = excluded.actual_kwh
+		WHERE daily_generation.source = 'interval'
+	`)
+	if err != nil {
+		return fmt.Errorf("error aggregating interval generation: %v", err)
+	}
+
+	if err := deriveDailyTotalSystem(tx, l.db.Dialect); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing daily generation: %v", err)
+	}
+	return nil
+}
+
+// AggregateMonthlyGeneration replaces monthly actuals with the sum of daily actuals for every
+// month where all of a site's days are present. Partial months keep the imported monthly value.
+func (l *Loader) AggregateMonthlyGeneration(ctx context.Context) error {
+	dialect := l.db.Dialect
+	result, err := l.db.ExecContext(ctx, `
+		INSERT INTO monthly_generation (year, month, location_id, actual_kwh)
+		SELECT
+			`+dialect.YearOf("date")+` as year,
+			`+dialect.MonthOf("date")+` as month,
+			location_id,
+			`+dialect.Round("SUM(actual_kwh)", 2)+`
+		FROM daily_generation
+		WHERE actual_kwh IS NOT NULL
+		GROUP BY year, month, location_id
+		HAVING COUNT(*) = `+dialect.DaysInMonth("MIN(date)")+`
+		ON CONFLICT (year, month, location_id)
+		DO UPDATE SET actual_kwh = excluded.actual_kwh
+	`)
+	if err != nil {
+		return fmt.Errorf("error aggregating monthly generation: %v", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	slog.Info("Derived monthly generation from daily totals", "rows", rows)
+	return nil
+}
+
+// RunDailyForecastModel retrains the daily model and rewrites daily_generation.predicted_kwh
+func (l *Loader) RunDailyForecastModel(ctx context.Context) error {
+	var days int
+	if err := l.db.QueryRow(`SELECT COUNT(*) FROM daily_generation WHERE actual_kwh IS NOT NULL`).Scan(&days); err != nil {
+		return fmt.Errorf("error counting daily generation: %v", err)
+	}
+	if days == 0 {
+		slog.Info("No daily generation imported, skipping daily forecast")
+		return nil
+	}
+
+	_, err := l.models.Run(ctx, "daily_random_forest", l.scripts.DailyForecast, map[string]string{
+		"n-estimators": "300",
+		"test-size":    "0.2",
+	})
+	return err
+}
+
+// deriveDailyTotalSystem sums the sites into Total System for days where every site reported
+func deriveDailyTotalSystem(tx *sql.Tx, dialect db.Dialect) error {
+	_, err := tx.Exec(`
+		INSERT INTO daily_generation (date, location_id, actual_kwh, source)
+		SELECT d.date, t.id, ` + dialect.Round("SUM(d.actual_kwh)", 2) + `, MIN(d.source)
+		FROM daily_generation d
+		JOIN locations l ON d.location_id = l.id
+		JOIN locations t ON t.name = 'Total System'
+		WHERE l.name != 'Total System' AND d.actual_kwh IS NOT NULL
+		GROUP BY d.date, t.id
+		HAVING COUNT(*) = (SELECT COUNT(*) FROM locations WHERE name != 'Total System')
+		ON CONFLICT (date, location_id)
+		DO UPDATE SET actual_kwh = excluded.actual_kwh, source = excluded.source
+	`)
+	if err != nil {
+		return fmt.Errorf("error deriving daily total system: %v", err)
+	}
+	return nil
+}
+
+// readGenerationCSV reads a CSV and checks that the required columns are present
+func readGenerationCSV(r io.Reader, required ...string) ([][]string, map[string]int, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading CSV header: %v", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range required {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, fmt.Errorf("missing %s column", name)
+		}
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading CSV: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, nil, errors.New("CSV has no rows")
+	}
+	return records, columns, nil
+}
+
+// siteIDs maps lower-case site names to location IDs, leaving out the derived Total System
+func (l *Loader) siteIDs() (map[string]int, error) {
+	rows, err := l.db.Query(`SELECT id, name FROM locations WHERE name != 'Total System'`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying locations: %v", err)
+	}
+	defer rows.Close()
+
+	sites := make(map[string]int)
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, fmt.Errorf("error scanning location: %v", err)
+		}
+		sites[strings.ToLower(name)] = id
+	}
+	return sites, rows.Err()
+}
+
+func lookupSite(sites map[string]int, site string) (int, error) {
+	id, ok := sites[strings.ToLower(strings.TrimSpace(site))]
+	if !ok {
+		return 0, fmt.Errorf("unknown site %q", site)
+	}
+	return id, nil
+}
+
+func parseEnergy(value string) (float64, error) {
+	energy, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || energy < 0 {
+		return 0, fmt.Errorf("invalid energy_kwh %q", value)
+	}
+	return energy, nil
+}
+
+func parseIntervalStart(value string) (time.Time, error) {
+	for _, layout := range intervalLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+}
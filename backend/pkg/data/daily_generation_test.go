@@ -0,0 +1,175 @@
+package data
+
+import (
+	"backend/pkg/db"
+	"context"
+	"strings"
+	"testing"
+)
+
+// seedLocations adds the three sites and the derived Total System
+func seedLocations(t *testing.T, database *db.DB) {
+	t.Helper()
+	_, err := database.Exec(`INSERT INTO locations (id, name, installed_capacity_kw, number_of_panels) VALUES
+		(1, 'Awali', 1000, 2000), (2, 'Refinery', 2000, 4000), (3, 'UOB', 500, 1000), (4, 'Total System', 3500, 7000)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// dailyActual returns a day's stored actual and source, or -1 when the day is missing
+func dailyActual(t *testing.T, database *db.DB, date string, locationID int) (float64, string) {
+	t.Helper()
+	var actual float64
+	var source string
+	err := database.QueryRow(`SELECT actual_kwh, source FROM daily_generation WHERE date = ? AND location_id = ?`,
+		date, locationID).Scan(&actual, &source)
+	if err != nil {
+		return -1, ""
+	}
+	return actual, source
+}
+
+func TestImportDailyGenerationCSV(t *testing.T) {
+	tests := []struct {
+		name  string
+		csv   string
+		rows  int
+		err   string
+		total float64
+	}{
+		{"every site reported", "date,site,energy_kwh\n2024-03-01,Awali,100\n2024-03-01,refinery,200\n2024-03-01, UOB ,50\n", 3, "", 350},
+		{"a site missing", "date,site,energy_kwh\n2024-03-01,Awali,100\n2024-03-01,Refinery,200\n", 2, "", -1},
+		{"header in any case", "Date,Site,Energy_kWh\n2024-03-01,Awali,100\n", 1, "", -1},
+		{"missing column", "date,site\n2024-03-01,Awali\n", 0, "missing energy_kwh column", -1},
+		{"total system is derived", "date,site,energy_kwh\n2024-03-01,Total System,100\n", 0, `line 2: unknown site "Total System"`, -1},
+		{"invalid date", "date,site,energy_kwh\n01/03/2024,Awali,100\n", 0, `line 2: invalid date "01/03/2024"`, -1},
+		{"negative energy", "date,site,energy_kwh\n2024-03-01,Awali,-5\n", 0, `line 2: invalid energy_kwh "-5"`, -1},
+		{"no rows", "date,site,energy_kwh\n", 0, "CSV has no rows", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := openTestLoader(t)
+			seedLocations(t, l.db)
+
+			rows, err := l.ImportDailyGenerationCSV(strings.NewReader(tt.csv))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("ImportDailyGenerationCSV() = %v, want %q", err, tt.err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if rows != tt.rows {
+				t.Errorf("ImportDailyGenerationCSV() = %d rows, want %d", rows, tt.rows)
+			}
+			if total, _ := dailyActual(t, l.db, "2024-03-01", 4); total != tt.total {
+				t.Errorf("Total System = %v, want %v", total, tt.total)
+			}
+		})
+	}
+}
+
+func TestAggregateIntervalGeneration(t *testing.T) {
+	l := openTestLoader(t)
+	seedLocations(t, l.db)
+
+	_, err := l.ImportIntervalGenerationCSV(strings.NewReader("timestamp,site,energy_kwh,interval_minutes\n" +
+		"2024-03-01 10:00,Awali,10.5,\n" +
+		"2024-03-01T10:15:00+03:00,Awali,11.25,15\n" +
+		"2024-03-01 10:00:00,Refinery,20,60\n" +
+		"2024-03-02 10:00,Awali,5,\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The meter's own daily register wins over the sum of its readings
+	if _, err := l.ImportDailyGenerationCSV(strings.NewReader("date,site,energy_kwh\n2024-03-02,Awali,30\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.AggregateIntervalGeneration(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		date       string
+		locationID int
+		actual     float64
+		source     string
+	}{
+		{"2024-03-01", 1, 21.75, "interval"},
+		{"2024-03-01", 2, 20, "interval"},
+		{"2024-03-02", 1, 30, "daily"},
+		{"2024-03-01", 4, -1, ""},
+	}
+	for _, tt := range tests {
+		if actual, source := dailyActual(t, l.db, tt.date, tt.locationID); actual != tt.actual || source != tt.source {
+			t.Errorf("%s location %d = %v (%s), want %v (%s)", tt.date, tt.locationID, actual, source, tt.actual, tt.source)
+		}
+	}
+}
+
+func TestImportIntervalGenerationCSVErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		err  string
+	}{
+		{"invalid timestamp", "timestamp,site,energy_kwh\n1 March,Awali,1\n", `line 2: invalid timestamp "1 March"`},
+		{"interval not dividing a day", "timestamp,site,energy_kwh,interval_minutes\n2024-03-01 10:00,Awali,1,7\n", `line 2: invalid interval_minutes "7"`},
+		{"unknown site", "timestamp,site,energy_kwh\n2024-03-01 10:00,Sitra,1\n", `line 2: unknown site "Sitra"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := openTestLoader(t)
+			seedLocations(t, l.db)
+
+			if _, err := l.ImportIntervalGenerationCSV(strings.NewReader(tt.csv)); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ImportIntervalGenerationCSV() = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestAggregateMonthlyGeneration(t *testing.T) {
+	l := openTestLoader(t)
+	seedLocations(t, l.db)
+
+	// February 2024 is complete for Awali, March is not
+	for day := 1; day <= 29; day++ {
+		if _, err := l.db.Exec(`INSERT INTO daily_generation (date, location_id, actual_kwh, source)
+			VALUES (printf('2024-02-%02d', ?), 1, 10, 'daily')`, day); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := l.db.Exec(`INSERT INTO daily_generation (date, location_id, actual_kwh, source)
+		VALUES ('2024-03-01', 1, 10, 'daily')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.db.Exec(`INSERT INTO monthly_generation (year, month, location_id, actual_kwh, predicted_kwh)
+		VALUES (2024, 2, 1, 250, 260), (2024, 3, 1, 300, 310)`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.AggregateMonthlyGeneration(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		month             int
+		actual, predicted float64
+	}{
+		{2, 290, 260},
+		{3, 300, 310},
+	}
+	for _, tt := range tests {
+		var actual, predicted float64
+		if err := l.db.QueryRow(`SELECT actual_kwh, predicted_kwh FROM monthly_generation WHERE year = 2024 AND month = ? AND location_id = 1`,
+			tt.month).Scan(&actual, &predicted); err != nil {
+			t.Fatal(err)
+		}
+		if actual != tt.actual || predicted != tt.predicted {
+			t.Errorf("month %d = %v actual, %v predicted, want %v, %v", tt.month, actual, predicted, tt.actual, tt.predicted)
+		}
+	}
+}
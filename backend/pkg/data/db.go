@@ -1,82 +1,48 @@
 package data
 
 import (
-	"backend/pkg/calculation"
-	"fmt"
-	"log"
-	"os/exec"
+	"backend/pkg/config"
 	"backend/pkg/db"
+	"backend/pkg/model"
+	"context"
 )
 
-func isTableEmpty(tableName string) bool {
-	var count int
-	query := fmt.Sprintf("SELECT COUNT(*) FROM %s", tableName)
-	err := db.Database.QueryRow(query).Scan(&count)
-	if err != nil {
-		log.Printf("Error checking if table %s is empty: %v", tableName, err)
-		return false
-	}
-	return count == 0
+// Loader fetches, imports and aggregates the source data into the database, and runs the models
+// that are trained on it
+type Loader struct {
+	db      *db.DB
+	models  *model.Runner
+	files   config.DataConfig
+	weather config.WeatherConfig
+	scripts config.ModelConfig
 }
 
-func executePythonScript(scriptPath string) {
-	cmd := exec.Command("python3", scriptPath)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		log.Printf("Error executing script %s: %v\nOutput: %s", scriptPath, err, output)
-	} else {
-		log.Printf("Output of script %s:\n%s", scriptPath, output)
-	}
+// NewLoader returns a loader that writes to database, reads the workbook and mapping in files,
+// fetches weather for the site and from the APIs in weather, and trains the model scripts in
+// scripts with models
+func NewLoader(database *db.DB, models *model.Runner, files config.DataConfig, weather config.WeatherConfig, scripts config.ModelConfig) *Loader {
+	return &Loader{db: database, models: models, files: files, weather: weather, scripts: scripts}
 }
 
-func FillDb() {
-	// To fill daily_weather table
-	if isTableEmpty("weather_daily") {
-		log.Println("Filling table: daily_weather")
-		FetchWeatherData()
-	}
-
-	// To fill monthly_weather table
-	if isTableEmpty("weather_monthly") {
-		log.Println("Filling table: monthly_weather")
-		InsertMonthlyWeatherData()
-	}
-
-	// To fill locations table
-	if isTableEmpty("locations") {
-		log.Println("Filling table: locations")
-		InitializeLocations()
-	}
-
-	// To fill monthly_generation table
-	if isTableEmpty("monthly_generation") {
-		log.Println("Filling table: monthly_generation")
-		ImportEnergyData()
-		executePythonScript("../../pkg/model/monthly/random_forest_model.py")
-		calculation.CalculateTheorticalOutput()
-	}
-
-	// To fill feature importance
-	if isTableEmpty("feature_importance") {
-		log.Println("Filling table: feature_importance")
-		executePythonScript("../../pkg/model/monthly/weather_only_model.py")
-	}
-
-	// To fill monthly_performance
-	if isTableEmpty("monthly_performance") {
-		log.Println("Filling table: monthly_performance")
-		calculation.CalculateMonthlyPerformance()
-	}
-
-	// To fill yearly_performance
-	if isTableEmpty("yearly_performance") {
-		log.Println("Filling table: yearly_performance")
-		calculation.CalculateYearlyPerformance()
-	}
+// RunForecastModel retrains the random forest and rewrites predicted_kwh and the forecast quantiles
+func (l *Loader) RunForecastModel(ctx context.Context) error {
+	modelFile, err := l.models.ModelFile("random_forest")
+	if err != nil {
+		return err
+	}
+	_, err = l.models.Run(ctx, "random_forest", l.scripts.MonthlyForecast, map[string]string{
+		"n-estimators": "500",
+		"test-size":    "0.2",
+		"model-file":   modelFile,
+	})
+	return err
+}
 
-	// To fill overall_performance
-	if isTableEmpty("overall_performance") {
-		log.Println("Filling table: overall_performance")
-		calculation.CalculateOverallPerformance()
-	}
+// RunFeatureImportanceModel retrains the weather-only model and rewrites feature_importance
+func (l *Loader) RunFeatureImportanceModel(ctx context.Context) error {
+	_, err := l.models.Run(ctx, "weather_only", l.scripts.FeatureImportance, map[string]string{
+		"n-estimators": "500",
+		"test-size":    "0.2",
+	})
+	return err
 }
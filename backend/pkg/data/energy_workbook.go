@@ -0,0 +1,358 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Workbook fields a sheet column can map to
+const (
+	FieldYear      = "year"
+	FieldMonth     = "month"
+	FieldActualKWh = "actual_kwh"
+)
+
+// WorkbookMapping declares where each site's monthly generation sits in the energy workbook
+type WorkbookMapping struct {
+	Sheets []SheetMapping `json:"sheets"`
+}
+
+// SheetMapping maps one sheet to a site. Columns maps each field to its header; Assets lists the
+// headers of per-array sub-columns, which become inverter assets with the lower-case header as code.
+// Every other non-empty header must be listed in Ignore, so a new or renamed column is noticed.
+type SheetMapping struct {
+	Sheet   string            `json:"sheet"`
+	Site    string            `json:"site"`
+	Columns map[string]string `json:"columns"`
+	Assets  []string          `json:"assets"`
+	Ignore  []string          `json:"ignore"`
+}
+
+// WorkbookCellError is a problem with one header or cell of the workbook. Row is 1-based, as
+// shown in Excel; header problems are reported on the header row.
+type WorkbookCellError struct {
+	Sheet   string `json:"sheet"`
+	Row     int    `json:"row,omitempty"`
+	Cell    string `json:"cell,omitempty"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e WorkbookCellError) Error() string {
+	location := "sheet " + e.Sheet
+	if e.Row > 0 {
+		location += fmt.Sprintf(" row %d", e.Row)
+	}
+	switch {
+	case e.Cell != "" && e.Column != "":
+		location += fmt.Sprintf(" (%s, %s)", e.Cell, e.Column)
+	case e.Cell != "":
+		location += fmt.Sprintf(" (%s)", e.Cell)
+	case e.Column != "":
+		location += fmt.Sprintf(" (%s)", e.Column)
+	}
+	return location + ": " + e.Message
+}
+
+// WorkbookErrors collects every problem found in the workbook, so one upload reports them all
+type WorkbookErrors []WorkbookCellError
+
+func (e WorkbookErrors) Error() string {
+	const shown = 10
+	messages := make([]string, 0, shown+1)
+	for i, cellErr := range e {
+		if i == shown {
+			messages = append(messages, fmt.Sprintf("and %d more", len(e)-shown))
+			break
+		}
+		messages = append(messages, cellErr.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// WorkbookMonth is a site's generation for one month as read from the workbook
+type WorkbookMonth struct {
+	Site      string
+	Sheet     string
+	Row       int
+	Year      int
+	Month     int
+	ActualKWh float64
+}
+
+// WorkbookAssetMonth is an array sub-column's generation for one month
+type WorkbookAssetMonth struct {
+	Site      string
+	Sheet     string
+	Asset     string
+	Year      int
+	Month     int
+	ActualKWh float64
+}
+
+// EnergyWorkbook is the validated content of the energy workbook
+type EnergyWorkbook struct {
+	Months      []WorkbookMonth
+	AssetMonths []WorkbookAssetMonth
+}
+
+// LoadWorkbookMapping reads and checks a JSON workbook mapping
+func LoadWorkbookMapping(path string) (*WorkbookMapping, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading workbook mapping: %v", err)
+	}
+	defer file.Close()
+
+	var mapping WorkbookMapping
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&mapping); err != nil {
+		return nil, fmt.Errorf("error decoding workbook mapping %s: %v", path, err)
+	}
+	if err := mapping.validate(); err != nil {
+		return nil, fmt.Errorf("invalid workbook mapping %s: %v", path, err)
+	}
+	return &mapping, nil
+}
+
+func (m *WorkbookMapping) validate() error {
+	if len(m.Sheets) == 0 {
+		return errors.New("no sheets")
+	}
+	sheets := make(map[string]bool)
+	for _, sheet := range m.Sheets {
+		if sheet.Sheet == "" || sheet.Site == "" {
+			return errors.New("every sheet needs a sheet and site name")
+		}
+		if sheets[strings.ToLower(sheet.Sheet)] {
+			return fmt.Errorf("sheet %s is mapped twice", sheet.Sheet)
+		}
+		sheets[strings.ToLower(sheet.Sheet)] = true
+
+		for field := range sheet.Columns {
+			if field != FieldYear && field != FieldMonth && field != FieldActualKWh {
+				return fmt.Errorf("sheet %s: unknown field %q", sheet.Sheet, field)
+			}
+		}
+		if sheet.Columns[FieldYear] == "" || sheet.Columns[FieldMonth] == "" {
+			return fmt.Errorf("sheet %s: year and month columns are required", sheet.Sheet)
+		}
+		if sheet.Columns[FieldActualKWh] == "" && len(sheet.Assets) == 0 {
+			return fmt.Errorf("sheet %s: maps neither %s nor any assets", sheet.Sheet, FieldActualKWh)
+		}
+
+		headers := make(map[string]bool)
+		all := append(append(append([]string{}, mappedHeaders(sheet)...), sheet.Assets...), sheet.Ignore...)
+		for _, header := range all {
+			key := normalizeHeader(header)
+			if key == "" {
+				return fmt.Errorf("sheet %s: empty header", sheet.Sheet)
+			}
+			if headers[key] {
+				return fmt.Errorf("sheet %s: header %q is mapped twice", sheet.Sheet, header)
+			}
+			headers[key] = true
+		}
+	}
+	return nil
+}
+
+func mappedHeaders(sheet SheetMapping) []string {
+	var headers []string
+	for _, field := range []string{FieldYear, FieldMonth, FieldActualKWh} {
+		if header := sheet.Columns[field]; header != "" {
+			headers = append(headers, header)
+		}
+	}
+	return headers
+}
+
+func normalizeHeader(header string) string {
+	return strings.ToLower(strings.TrimSpace(header))
+}
+
+// ReadEnergyWorkbook validates the headers of every mapped sheet and parses its rows. Blank rows
+// and blank asset cells are skipped; any other problem is returned as WorkbookErrors with the sheet
+// and cell it was found in, along with the rows that did parse. A row with a bad cell is left out
+// entirely, and a sheet with a bad header contributes no rows.
+func ReadEnergyWorkbook(f *excelize.File, mapping *WorkbookMapping) (*EnergyWorkbook, error) {
+	var workbook EnergyWorkbook
+	var problems WorkbookErrors
+
+	present := make(map[string]string)
+	for _, name := range f.GetSheetList() {
+		present[strings.ToLower(name)] = name
+	}
+
+	for _, sheet := range mapping.Sheets {
+		name, ok := present[strings.ToLower(sheet.Sheet)]
+		if !ok {
+			problems = append(problems, WorkbookCellError{Sheet: sheet.Sheet, Message: "sheet not found"})
+			continue
+		}
+		rows, err := f.GetRows(name)
+		if err != nil {
+			return nil, fmt.Errorf("error reading sheet %s: %v", name, err)
+		}
+		problems = append(problems, readSheet(&workbook, sheet, name, rows)...)
+	}
+
+	if len(problems) > 0 {
+		return &workbook, problems
+	}
+	return &workbook, nil
+}
+
+func readSheet(workbook *EnergyWorkbook, sheet SheetMapping, name string, rows [][]string) WorkbookErrors {
+	var problems WorkbookErrors
+	if len(rows) == 0 {
+		return WorkbookErrors{{Sheet: name, Message: "sheet is empty"}}
+	}
+
+	// Header row: every mapped header exactly once, nothing unmapped
+	headers := append(append(mappedHeaders(sheet), sheet.Assets...), sheet.Ignore...)
+	expected := make(map[string]bool)
+	for _, header := range headers {
+		expected[normalizeHeader(header)] = true
+	}
+	index := make(map[string]int)
+	for i, header := range rows[0] {
+		key := normalizeHeader(header)
+		if key == "" {
+			continue
+		}
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		if _, seen := index[key]; seen {
+			problems = append(problems, WorkbookCellError{Sheet: name, Row: 1, Cell: cell, Column: header, Message: "duplicate header"})
+			continue
+		}
+		if !expected[key] {
+			problems = append(problems, WorkbookCellError{Sheet: name, Row: 1, Cell: cell, Column: header,
+				Message: "header is not in the workbook mapping"})
+		}
+		index[key] = i
+	}
+	for _, header := range headers {
+		if _, ok := index[normalizeHeader(header)]; !ok {
+			problems = append(problems, WorkbookCellError{Sheet: name, Row: 1, Column: header, Message: "missing header"})
+		}
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+
+	column := func(header string) int { return index[normalizeHeader(header)] }
+	yearColumn := column(sheet.Columns[FieldYear])
+	monthColumn := column(sheet.Columns[FieldMonth])
+	seen := make(map[[2]int]int)
+
+	for i, row := range rows[1:] {
+		line := i + 2
+		if blankRow(row) {
+			continue
+		}
+		cell := func(col int) (string, string) {
+			ref, _ := excelize.CoordinatesToCellName(col+1, line)
+			if col < len(row) {
+				return ref, strings.TrimSpace(row[col])
+			}
+			return ref, ""
+		}
+		fail := func(col int, header, format string, args ...interface{}) {
+			ref, _ := cell(col)
+			problems = append(problems, WorkbookCellError{Sheet: name, Row: line, Cell: ref, Column: header,
+				Message: fmt.Sprintf(format, args...)})
+		}
+
+		_, value := cell(yearColumn)
+		year, err := strconv.Atoi(value)
+		if err != nil || year < 1900 || year > 2100 {
+			fail(yearColumn, sheet.Columns[FieldYear], "invalid year %q", value)
+			continue
+		}
+		_, value = cell(monthColumn)
+		month, err := strconv.Atoi(value)
+		if err != nil || month < 1 || month > 12 {
+			fail(monthColumn, sheet.Columns[FieldMonth], "invalid month %q", value)
+			continue
+		}
+		if first, ok := seen[[2]int{year, month}]; ok {
+			fail(yearColumn, sheet.Columns[FieldYear], "%04d-%02d already appears on row %d", year, month, first)
+			continue
+		}
+		seen[[2]int{year, month}] = line
+
+		rowProblems := len(problems)
+		var total *WorkbookMonth
+		var assets []WorkbookAssetMonth
+		if header := sheet.Columns[FieldActualKWh]; header != "" {
+			col := column(header)
+			_, value := cell(col)
+			energy, err := parseEnergy(value)
+			switch {
+			case value == "":
+				fail(col, header, "missing value")
+			case err != nil:
+				fail(col, header, "invalid energy %q", value)
+			default:
+				total = &WorkbookMonth{Site: sheet.Site, Sheet: name, Row: line, Year: year, Month: month, ActualKWh: energy}
+			}
+		}
+
+		for _, header := range sheet.Assets {
+			col := column(header)
+			_, value := cell(col)
+			if value == "" {
+				continue
+			}
+			energy, err := parseEnergy(value)
+			if err != nil {
+				fail(col, header, "invalid energy %q", value)
+				continue
+			}
+			assets = append(assets, WorkbookAssetMonth{
+				Site: sheet.Site, Sheet: name, Asset: normalizeHeader(header), Year: year, Month: month, ActualKWh: energy,
+			})
+		}
+
+		if len(problems) == rowProblems {
+			if total != nil {
+				workbook.Months = append(workbook.Months, *total)
+			}
+			workbook.AssetMonths = append(workbook.AssetMonths, assets...)
+		}
+	}
+	return problems
+}
+
+func blankRow(row []string) bool {
+	for _, value := range row {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// readEnergyWorkbook opens the energy workbook and reads it through the mapping file
+func (l *Loader) readEnergyWorkbook() (*EnergyWorkbook, error) {
+	mapping, err := LoadWorkbookMapping(l.files.WorkbookMapping)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := excelize.OpenFile(l.files.EnergyWorkbook)
+	if err != nil {
+		return nil, fmt.Errorf("error opening Excel file: %v", err)
+	}
+	defer f.Close()
+
+	return ReadEnergyWorkbook(f, mapping)
+}
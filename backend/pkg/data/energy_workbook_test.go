@@ -0,0 +1,177 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestLoadWorkbookMapping(t *testing.T) {
+	// The mapping shipped with the server has to stay valid
+	mapping, err := LoadWorkbookMapping("../db/energy_workbook.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mapping.Sheets) != 3 {
+		t.Errorf("got %d sheets, want 3", len(mapping.Sheets))
+	}
+}
+
+func TestWorkbookMappingValidate(t *testing.T) {
+	columns := map[string]string{FieldYear: "year", FieldMonth: "month", FieldActualKWh: "total"}
+	tests := []struct {
+		name  string
+		sheet SheetMapping
+		valid bool
+	}{
+		{"site total", SheetMapping{Sheet: "UOB", Site: "UOB", Columns: columns}, true},
+		{"assets only", SheetMapping{Sheet: "UOB", Site: "UOB",
+			Columns: map[string]string{FieldYear: "year", FieldMonth: "month"}, Assets: []string{"roof"}}, true},
+		{"no site", SheetMapping{Sheet: "UOB", Columns: columns}, false},
+		{"unknown field", SheetMapping{Sheet: "UOB", Site: "UOB",
+			Columns: map[string]string{FieldYear: "year", FieldMonth: "month", "peak_kw": "peak"}, Assets: []string{"roof"}}, false},
+		{"no month", SheetMapping{Sheet: "UOB", Site: "UOB", Columns: map[string]string{FieldYear: "year", FieldActualKWh: "total"}}, false},
+		{"nothing to import", SheetMapping{Sheet: "UOB", Site: "UOB", Columns: map[string]string{FieldYear: "year", FieldMonth: "month"}}, false},
+		{"header mapped twice", SheetMapping{Sheet: "UOB", Site: "UOB", Columns: columns, Ignore: []string{" Total "}}, false},
+		{"empty header", SheetMapping{Sheet: "UOB", Site: "UOB", Columns: columns, Assets: []string{" "}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapping := WorkbookMapping{Sheets: []SheetMapping{tt.sheet}}
+			if err := mapping.validate(); (err == nil) != tt.valid {
+				t.Errorf("validate() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+
+	twice := WorkbookMapping{Sheets: []SheetMapping{
+		{Sheet: "UOB", Site: "UOB", Columns: columns},
+		{Sheet: "uob", Site: "Awali", Columns: columns},
+	}}
+	if err := twice.validate(); err == nil {
+		t.Error("validate() accepted a sheet mapped twice")
+	}
+}
+
+func TestReadEnergyWorkbook(t *testing.T) {
+	mapping := &WorkbookMapping{Sheets: []SheetMapping{{
+		Sheet:   "Refinery",
+		Site:    "Refinery",
+		Columns: map[string]string{FieldYear: "year", FieldMonth: "month", FieldActualKWh: "total_refinery"},
+		Assets:  []string{"west_parking"},
+		Ignore:  []string{"notes"},
+	}}}
+	header := []interface{}{"Year", "Month", "Total_Refinery", "West_Parking", "Notes"}
+
+	tests := []struct {
+		name   string
+		rows   [][]interface{}
+		months []WorkbookMonth
+		assets []WorkbookAssetMonth
+		errors WorkbookErrors
+	}{
+		{
+			name: "valid rows",
+			rows: [][]interface{}{
+				header,
+				{2019, 1, 1000.5, 400},
+				{},
+				{2019, 2, 900, "", "meter swapped"},
+			},
+			months: []WorkbookMonth{
+				{Site: "Refinery", Sheet: "Refinery", Row: 2, Year: 2019, Month: 1, ActualKWh: 1000.5},
+				{Site: "Refinery", Sheet: "Refinery", Row: 4, Year: 2019, Month: 2, ActualKWh: 900},
+			},
+			assets: []WorkbookAssetMonth{{"Refinery", "Refinery", "west_parking", 2019, 1, 400}},
+		},
+		{
+			name: "every cell problem is reported",
+			rows: [][]interface{}{
+				header,
+				{"2019", 13, 1000},
+				{2019, 1, "", "lots"},
+				{2019, 1, 950},
+			},
+			errors: WorkbookErrors{
+				{Sheet: "Refinery", Row: 2, Cell: "B2", Column: "month", Message: `invalid month "13"`},
+				{Sheet: "Refinery", Row: 3, Cell: "C3", Column: "total_refinery", Message: "missing value"},
+				{Sheet: "Refinery", Row: 3, Cell: "D3", Column: "west_parking", Message: `invalid energy "lots"`},
+				{Sheet: "Refinery", Row: 4, Cell: "A4", Column: "year", Message: "2019-01 already appears on row 3"},
+			},
+		},
+		{
+			name: "headers",
+			rows: [][]interface{}{
+				{"Year", "Month", "Total_Refinery", "Notes", "East_Parking", "notes"},
+			},
+			errors: WorkbookErrors{
+				{Sheet: "Refinery", Row: 1, Cell: "E1", Column: "East_Parking", Message: "header is not in the workbook mapping"},
+				{Sheet: "Refinery", Row: 1, Cell: "F1", Column: "notes", Message: "duplicate header"},
+				{Sheet: "Refinery", Row: 1, Column: "west_parking", Message: "missing header"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := excelize.NewFile()
+			defer f.Close()
+			f.SetSheetName("Sheet1", "Refinery")
+			for i, row := range tt.rows {
+				cell, _ := excelize.CoordinatesToCellName(1, i+1)
+				if err := f.SetSheetRow("Refinery", cell, &row); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			workbook, err := ReadEnergyWorkbook(f, mapping)
+			if tt.errors != nil {
+				if !reflect.DeepEqual(err, tt.errors) {
+					t.Errorf("ReadEnergyWorkbook() = %#v, want %#v", err, tt.errors)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(workbook.Months, tt.months) || !reflect.DeepEqual(workbook.AssetMonths, tt.assets) {
+				t.Errorf("ReadEnergyWorkbook() = %+v, %+v, want %+v, %+v", workbook.Months, workbook.AssetMonths, tt.months, tt.assets)
+			}
+		})
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+	want := WorkbookErrors{{Sheet: "Refinery", Message: "sheet not found"}}
+	if _, err := ReadEnergyWorkbook(f, mapping); !reflect.DeepEqual(err, want) {
+		t.Errorf("ReadEnergyWorkbook() without the sheet = %v, want %v", err, want)
+	}
+}
+
+func TestWorkbookErrorsError(t *testing.T) {
+	var problems WorkbookErrors
+	for row := 2; row <= 13; row++ {
+		problems = append(problems, WorkbookCellError{Sheet: "UOB", Row: row, Message: "bad"})
+	}
+	tests := []struct {
+		name   string
+		errors WorkbookErrors
+		want   string
+	}{
+		{"none", problems[:0:0], ""},
+		{"cell and column", WorkbookErrors{{Sheet: "UOB", Row: 3, Cell: "C3", Column: "total", Message: "missing value"}},
+			"sheet UOB row 3 (C3, total): missing value"},
+		{"column only", WorkbookErrors{{Sheet: "UOB", Row: 1, Column: "year", Message: "missing header"}},
+			"sheet UOB row 1 (year): missing header"},
+		{"capped at ten", problems,
+			"sheet UOB row 2: bad; sheet UOB row 3: bad; sheet UOB row 4: bad; sheet UOB row 5: bad; sheet UOB row 6: bad; " +
+				"sheet UOB row 7: bad; sheet UOB row 8: bad; sheet UOB row 9: bad; sheet UOB row 10: bad; sheet UOB row 11: bad; and 2 more"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.errors.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,738 @@
+package data
+
+import (
+	"backend/pkg/audit"
+	"backend/pkg/db"
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"log/slog"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Datasets an upload can be imported into
+const (
+	DatasetMonthlyGeneration = "monthly_generation"
+	DatasetDailyGeneration   = "daily_generation"
+	DatasetWeatherDaily      = "weather_daily"
+)
+
+// Upload formats
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+var (
+	// ErrUnknownDataset means the upload names a dataset that cannot be imported
+	ErrUnknownDataset = errors.New("unknown dataset")
+	// ErrInvalidUpload means the file as a whole cannot be read, such as a missing column
+	ErrInvalidUpload = errors.New("invalid upload")
+	// ErrImportNotPending means the import was already applied or discarded
+	ErrImportNotPending = errors.New("import is not pending")
+)
+
+// weatherImportColumns are the optional numeric weather_daily columns an upload may carry
+var weatherImportColumns = []string{
+	"sunshine_duration_seconds", "daylight_duration_seconds", "min_temperature_C", "avg_temperature_C",
+	"max_temperature_C", "avg_solar_irradiance_wm2", "avg_relative_humidity_percent",
+	"avg_cloud_cover_percent", "avg_wind_speed_kmh", "rainfall_mm",
+}
+
+// importRecord is one validated upload row, stored with a pending import until it is applied
+type importRecord struct {
+	Row        int                `json:"row,omitempty"`
+	Key        string             `json:"key"`
+	LocationID int                `json:"locationId,omitempty"`
+	Date       string             `json:"date,omitempty"`
+	Year       int                `json:"year,omitempty"`
+	Month      int                `json:"month,omitempty"`
+	Values     map[string]float64 `json:"values,omitempty"`
+	Text       map[string]string  `json:"text,omitempty"`
+}
+
+func (r importRecord) period() string {
+	if r.Date != "" {
+		return r.Date[:7]
+	}
+	return fmt.Sprintf("%04d-%02d", r.Year, r.Month)
+}
+
+func (r importRecord) fields() map[string]interface{} {
+	fields := make(map[string]interface{}, len(r.Values)+len(r.Text))
+	for name, value := range r.Values {
+		fields[name] = value
+	}
+	for name, value := range r.Text {
+		fields[name] = value
+	}
+	return fields
+}
+
+// importDataset parses uploads for one table, reads the stored row an import record would replace,
+// and writes records. finish runs once after the records are written.
+type importDataset struct {
+	parse   func(l *Loader, format string, body []byte, sites map[string]int) ([]importRecord, []structure.ImportRejection, error)
+	current func(tx *sql.Tx, dialect db.Dialect, record importRecord) (map[string]interface{}, error)
+	apply   func(tx *sql.Tx, importID int, record importRecord) error
+	finish  func(tx *sql.Tx, dialect db.Dialect) error
+}
+
+var importDatasets = map[string]importDataset{
+	DatasetMonthlyGeneration: {
+		parse:   (*Loader).parseMonthlyGenerationUpload,
+		current: currentMonthlyGeneration,
+		apply:   applyMonthlyGeneration,
+		finish:  func(tx *sql.Tx, _ db.Dialect) error { return calculateTotalSystem(tx) },
+	},
+	DatasetDailyGeneration: {
+		parse:   (*Loader).parseDailyGenerationUpload,
+		current: currentDailyGeneration,
+		apply:   applyDailyGeneration,
+		finish:  deriveDailyTotalSystem,
+	},
+	DatasetWeatherDaily: {
+		parse:   (*Loader).parseWeatherUpload,
+		current: currentWeatherDaily,
+		apply:   applyWeatherDaily,
+	},
+}
+
+// DetectFormat picks xlsx for a .xlsx file name or a zip body, and csv otherwise
+func DetectFormat(filename string, body []byte) string {
+	if strings.EqualFold(filepath.Ext(filename), ".xlsx") || bytes.HasPrefix(body, []byte("PK\x03\x04")) {
+		return FormatXLSX
+	}
+	return FormatCSV
+}
+
+// CreateImport validates an upload and stores it as a pending import with its diff against the
+// current data. Nothing is written to the dataset until the import is applied.
+func (l *Loader) CreateImport(dataset, filename, format string, body []byte) (*structure.Import, error) {
+	ds, ok := importDatasets[dataset]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownDataset, dataset)
+	}
+	if format != FormatCSV && format != FormatXLSX {
+		return nil, fmt.Errorf("%w: format must be csv or xlsx", ErrInvalidUpload)
+	}
+
+	sites, err := l.siteIDs()
+	if err != nil {
+		return nil, err
+	}
+	records, rejections, err := ds.parse(l, format, body, sites)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := l.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	diff, err := diffImport(tx, l.db.Dialect, ds, records)
+	if err != nil {
+		return nil, err
+	}
+	diff.Dataset, diff.Filename, diff.Status = dataset, filename, "pending"
+	diff.Rejections, diff.Rejected = rejections, len(rejections)
+	diff.CreatedAt = time.Now().UTC()
+
+	recordsJSON, err := json.Marshal(records)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding import records: %v", err)
+	}
+	diffJSON, err := json.Marshal(diff)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding import diff: %v", err)
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO imports (dataset, filename, status, records_json, diff_json, created_at)
+		VALUES (?, ?, 'pending', ?, ?, ?)
+		RETURNING id
+	`, dataset, filename, string(recordsJSON), string(diffJSON), diff.CreatedAt).Scan(&diff.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error saving import: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing import: %v", err)
+	}
+	return diff, nil
+}
+
+// ApplyImport writes a pending import's inserts and updates in one transaction. The diff is taken
+// again against the data as it is now, so rows changed since the dry run are reported as they are
+// applied. Rejected rows are never written. Changed audited values are recorded as revisions by change.
+func (l *Loader) ApplyImport(id int, change audit.Change) (*structure.Import, error) {
+	tx, err := l.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var dataset, status, recordsJSON, diffJSON string
+	err = tx.QueryRow(`SELECT dataset, status, records_json, diff_json FROM imports WHERE id = ?`, id).
+		Scan(&dataset, &status, &recordsJSON, &diffJSON)
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrImportNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading import %d: %v", id, err)
+	}
+	if status != "pending" {
+		return nil, fmt.Errorf("%w: import %d is %s", ErrImportNotPending, id, status)
+	}
+
+	var records []importRecord
+	var previous structure.Import
+	if err := json.Unmarshal([]byte(recordsJSON), &records); err != nil {
+		return nil, fmt.Errorf("error decoding import %d: %v", id, err)
+	}
+	if err := json.Unmarshal([]byte(diffJSON), &previous); err != nil {
+		return nil, fmt.Errorf("error decoding import %d: %v", id, err)
+	}
+
+	ds := importDatasets[dataset]
+	diff, err := diffImport(tx, l.db.Dialect, ds, records)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]bool, len(diff.Changes))
+	for _, change := range diff.Changes {
+		changed[change.Key] = true
+	}
+	for _, record := range records {
+		if !changed[record.Key] {
+			continue
+		}
+		if err := ds.apply(tx, id, record); err != nil {
+			return nil, fmt.Errorf("%s: error applying import: %v", record.Key, err)
+		}
+	}
+	if ds.finish != nil && len(changed) > 0 {
+		if err := ds.finish(tx, l.db.Dialect); err != nil {
+			return nil, fmt.Errorf("error deriving totals: %v", err)
+		}
+	}
+
+	if change.Author == "" {
+		change.Author = "import"
+	}
+	if change.Reason == "" {
+		change.Reason = strings.TrimSpace(fmt.Sprintf("import %d %s", id, previous.Filename))
+	}
+	if _, err := audit.Record(tx, l.db.Dialect, change); err != nil {
+		return nil, err
+	}
+
+	appliedAt := time.Now().UTC()
+	diff.ID, diff.Dataset, diff.Filename, diff.Status = id, dataset, previous.Filename, "applied"
+	diff.Rejections, diff.Rejected = previous.Rejections, previous.Rejected
+	diff.CreatedAt, diff.AppliedAt = previous.CreatedAt, &appliedAt
+
+	newDiff, err := json.Marshal(diff)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding import diff: %v", err)
+	}
+	if _, err := tx.Exec(`UPDATE imports SET status = 'applied', diff_json = ?, applied_at = ? WHERE id = ?`,
+		string(newDiff), appliedAt, id); err != nil {
+		return nil, fmt.Errorf("error updating import %d: %v", id, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing import %d: %v", id, err)
+	}
+
+	slog.Info("Applied import", "id", id, "inserted", diff.Inserted, "updated", diff.Updated)
+	return diff, nil
+}
+
+// DiscardImport marks a pending import as discarded
+func (l *Loader) DiscardImport(id int) error {
+	result, err := l.db.Exec(`UPDATE imports SET status = 'discarded' WHERE id = ? AND status = 'pending'`, id)
+	if err != nil {
+		return fmt.Errorf("error discarding import %d: %v", id, err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		var status string
+		if err := l.db.QueryRow(`SELECT status FROM imports WHERE id = ?`, id).Scan(&status); err == sql.ErrNoRows {
+			return repository.ErrImportNotFound
+		}
+		return fmt.Errorf("%w: import %d is %s", ErrImportNotPending, id, status)
+	}
+	return nil
+}
+
+// diffImport compares each record with the row it would replace
+func diffImport(tx *sql.Tx, dialect db.Dialect, ds importDataset, records []importRecord) (*structure.Import, error) {
+	diff := &structure.Import{AffectedMonths: []string{}}
+	months := make(map[string]bool)
+
+	for _, record := range records {
+		old, err := ds.current(tx, dialect, record)
+		if err != nil {
+			return nil, fmt.Errorf("%s: error reading current data: %v", record.Key, err)
+		}
+		fields := record.fields()
+
+		change := structure.ImportChange{Action: "insert", Key: record.Key, Row: record.Row, New: fields}
+		if old != nil {
+			same := true
+			previous := make(map[string]interface{}, len(fields))
+			for name, value := range fields {
+				previous[name] = old[name]
+				if !sameValue(old[name], value) {
+					same = false
+				}
+			}
+			if same {
+				diff.Unchanged++
+				continue
+			}
+			change.Action, change.Old = "update", previous
+		}
+
+		if change.Action == "insert" {
+			diff.Inserted++
+		} else {
+			diff.Updated++
+		}
+		diff.Changes = append(diff.Changes, change)
+		months[record.period()] = true
+	}
+
+	for month := range months {
+		diff.AffectedMonths = append(diff.AffectedMonths, month)
+	}
+	sort.Strings(diff.AffectedMonths)
+	return diff, nil
+}
+
+// sameValue compares a stored value with an uploaded one, to the two decimals the tables keep
+func sameValue(stored, uploaded interface{}) bool {
+	switch value := uploaded.(type) {
+	case float64:
+		number, ok := stored.(float64)
+		return ok && math.Abs(number-value) < 0.005
+	case string:
+		text, ok := stored.(string)
+		return ok && text == value
+	}
+	return false
+}
+
+// currentRow reads one row as a map of column name to float64 or string, or nil if there is none
+func currentRow(tx *sql.Tx, query string, args ...interface{}) (map[string]interface{}, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := rows.Scan(pointers...); err != nil {
+		return nil, err
+	}
+
+	row := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		switch value := values[i].(type) {
+		case int64:
+			row[column] = float64(value)
+		case []byte:
+			row[column] = string(value)
+		case time.Time:
+			row[column] = value.Format("15:04")
+		default:
+			row[column] = value
+		}
+	}
+	return row, nil
+}
+
+// uploadTable is the header and rows of a CSV, or of the first sheet of a workbook
+type uploadTable struct {
+	sheet   string
+	columns map[string]int
+	rows    [][]string
+}
+
+func readUploadTable(format string, body []byte, required ...string) (*uploadTable, error) {
+	if format == FormatCSV {
+		rows, columns, err := readGenerationCSV(bytes.NewReader(body), required...)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidUpload, err)
+		}
+		return &uploadTable{columns: columns, rows: rows}, nil
+	}
+
+	f, err := excelize.OpenReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%w: error opening workbook: %v", ErrInvalidUpload, err)
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("%w: workbook has no sheets", ErrInvalidUpload)
+	}
+	rows, err := f.GetRows(sheets[0])
+	if err != nil {
+		return nil, fmt.Errorf("error reading sheet %s: %v", sheets[0], err)
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("%w: sheet %s has no rows", ErrInvalidUpload, sheets[0])
+	}
+
+	columns := make(map[string]int, len(rows[0]))
+	for i, name := range rows[0] {
+		columns[normalizeHeader(name)] = i
+	}
+	for _, name := range required {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%w: sheet %s is missing the %s column", ErrInvalidUpload, sheets[0], name)
+		}
+	}
+
+	return &uploadTable{sheet: sheets[0], columns: columns, rows: rows[1:]}, nil
+}
+
+// value returns the trimmed cell of row i under column, or "" if the row is short or has no such column
+func (t *uploadTable) value(i int, column string) string {
+	index, ok := t.columns[strings.ToLower(column)]
+	if !ok || index >= len(t.rows[i]) {
+		return ""
+	}
+	return strings.TrimSpace(t.rows[i][index])
+}
+
+func (t *uploadTable) has(column string) bool {
+	_, ok := t.columns[strings.ToLower(column)]
+	return ok
+}
+
+// line is the row number of row i as shown in the file, counting the header as 1
+func (t *uploadTable) line(i int) int {
+	return i + 2
+}
+
+func (t *uploadTable) reject(i int, column, format string, args ...interface{}) structure.ImportRejection {
+	rejection := structure.ImportRejection{Sheet: t.sheet, Row: t.line(i), Column: column, Message: fmt.Sprintf(format, args...)}
+	if index, ok := t.columns[strings.ToLower(column)]; ok && t.sheet != "" {
+		rejection.Cell, _ = excelize.CoordinatesToCellName(index+1, t.line(i))
+	}
+	return rejection
+}
+
+func (l *Loader) parseMonthlyGenerationUpload(format string, body []byte, sites map[string]int) ([]importRecord, []structure.ImportRejection, error) {
+	if format == FormatXLSX {
+		return l.parseMonthlyWorkbook(body, sites)
+	}
+
+	table, err := readUploadTable(format, body, "site", "year", "month", "energy_kwh")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var records []importRecord
+	var rejections []structure.ImportRejection
+	seen := make(map[string]int)
+	for i := range table.rows {
+		locationID, err := lookupSite(sites, table.value(i, "site"))
+		if err != nil {
+			rejections = append(rejections, table.reject(i, "site", "%v", err))
+			continue
+		}
+		year, err := strconv.Atoi(table.value(i, "year"))
+		if err != nil || year < 1900 || year > 2100 {
+			rejections = append(rejections, table.reject(i, "year", "invalid year %q", table.value(i, "year")))
+			continue
+		}
+		month, err := strconv.Atoi(table.value(i, "month"))
+		if err != nil || month < 1 || month > 12 {
+			rejections = append(rejections, table.reject(i, "month", "invalid month %q", table.value(i, "month")))
+			continue
+		}
+		energy, err := parseEnergy(table.value(i, "energy_kwh"))
+		if err != nil {
+			rejections = append(rejections, table.reject(i, "energy_kwh", "%v", err))
+			continue
+		}
+
+		key := fmt.Sprintf("%s %04d-%02d", table.value(i, "site"), year, month)
+		if first, ok := seen[strings.ToLower(key)]; ok {
+			rejections = append(rejections, table.reject(i, "month", "%s already appears on line %d", key, first))
+			continue
+		}
+		seen[strings.ToLower(key)] = table.line(i)
+
+		records = append(records, importRecord{
+			Row: table.line(i), Key: key, LocationID: locationID, Year: year, Month: month,
+			Values: map[string]float64{"actual_kwh": energy},
+		})
+	}
+	return records, rejections, nil
+}
+
+// parseMonthlyWorkbook reads an upload laid out like the energy workbook. Only the mapped sheets
+// present in the upload are read, so a workbook with a single site's sheet can be imported.
+func (l *Loader) parseMonthlyWorkbook(body []byte, sites map[string]int) ([]importRecord, []structure.ImportRejection, error) {
+	mapping, err := LoadWorkbookMapping(l.files.WorkbookMapping)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := excelize.OpenReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: error opening workbook: %v", ErrInvalidUpload, err)
+	}
+	defer f.Close()
+
+	present := make(map[string]bool)
+	for _, name := range f.GetSheetList() {
+		present[strings.ToLower(name)] = true
+	}
+	uploaded := &WorkbookMapping{}
+	for _, sheet := range mapping.Sheets {
+		if present[strings.ToLower(sheet.Sheet)] {
+			uploaded.Sheets = append(uploaded.Sheets, sheet)
+		}
+	}
+	if len(uploaded.Sheets) == 0 {
+		return nil, nil, fmt.Errorf("%w: no sheet matches the workbook mapping", ErrInvalidUpload)
+	}
+
+	workbook, err := ReadEnergyWorkbook(f, uploaded)
+	var rejections []structure.ImportRejection
+	var problems WorkbookErrors
+	if errors.As(err, &problems) {
+		for _, problem := range problems {
+			if problem.Row <= 1 {
+				return nil, nil, fmt.Errorf("%w: %v", ErrInvalidUpload, problem)
+			}
+			rejections = append(rejections, structure.ImportRejection(problem))
+		}
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	var records []importRecord
+	for _, month := range workbook.Months {
+		locationID, err := lookupSite(sites, month.Site)
+		if err != nil {
+			return nil, nil, fmt.Errorf("workbook sheet %s: %v", month.Sheet, err)
+		}
+		records = append(records, importRecord{
+			Row: month.Row, Key: fmt.Sprintf("%s %04d-%02d", month.Site, month.Year, month.Month),
+			LocationID: locationID, Year: month.Year, Month: month.Month,
+			Values: map[string]float64{"actual_kwh": month.ActualKWh},
+		})
+	}
+	return records, rejections, nil
+}
+
+func currentMonthlyGeneration(tx *sql.Tx, _ db.Dialect, record importRecord) (map[string]interface{}, error) {
+	return currentRow(tx, `
+		SELECT actual_kwh FROM monthly_generation
+		WHERE year = ? AND month = ? AND location_id = ? AND actual_kwh IS NOT NULL
+	`, record.Year, record.Month, record.LocationID)
+}
+
+// applyMonthlyGeneration records the month so the next workbook import keeps it, and updates
+// monthly_generation straight away
+func applyMonthlyGeneration(tx *sql.Tx, importID int, record importRecord) error {
+	energy := record.Values["actual_kwh"]
+	_, err := tx.Exec(`
+		INSERT INTO monthly_generation_imports (year, month, location_id, actual_kwh, import_id)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (year, month, location_id)
+		DO UPDATE SET actual_kwh = excluded.actual_kwh, import_id = excluded.import_id
+	`, record.Year, record.Month, record.LocationID, energy, importID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO monthly_generation (year, month, location_id, actual_kwh)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (year, month, location_id)
+		DO UPDATE SET actual_kwh = excluded.actual_kwh
+	`, record.Year, record.Month, record.LocationID, energy)
+	return err
+}
+
+func (l *Loader) parseDailyGenerationUpload(format string, body []byte, sites map[string]int) ([]importRecord, []structure.ImportRejection, error) {
+	table, err := readUploadTable(format, body, "date", "site", "energy_kwh")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var records []importRecord
+	var rejections []structure.ImportRejection
+	seen := make(map[string]int)
+	for i := range table.rows {
+		date, err := time.Parse("2006-01-02", table.value(i, "date"))
+		if err != nil {
+			rejections = append(rejections, table.reject(i, "date", "invalid date %q", table.value(i, "date")))
+			continue
+		}
+		locationID, err := lookupSite(sites, table.value(i, "site"))
+		if err != nil {
+			rejections = append(rejections, table.reject(i, "site", "%v", err))
+			continue
+		}
+		energy, err := parseEnergy(table.value(i, "energy_kwh"))
+		if err != nil {
+			rejections = append(rejections, table.reject(i, "energy_kwh", "%v", err))
+			continue
+		}
+
+		key := fmt.Sprintf("%s %s", table.value(i, "site"), date.Format("2006-01-02"))
+		if first, ok := seen[strings.ToLower(key)]; ok {
+			rejections = append(rejections, table.reject(i, "date", "%s already appears on row %d", key, first))
+			continue
+		}
+		seen[strings.ToLower(key)] = table.line(i)
+
+		records = append(records, importRecord{
+			Row: table.line(i), Key: key, LocationID: locationID, Date: date.Format("2006-01-02"),
+			Values: map[string]float64{"actual_kwh": energy},
+		})
+	}
+	return records, rejections, nil
+}
+
+func currentDailyGeneration(tx *sql.Tx, dialect db.Dialect, record importRecord) (map[string]interface{}, error) {
+	return currentRow(tx, `
+		SELECT actual_kwh FROM daily_generation
+		WHERE `+dialect.Day("date")+` = ? AND location_id = ? AND actual_kwh IS NOT NULL
+	`, record.Date, record.LocationID)
+}
+
+func applyDailyGeneration(tx *sql.Tx, importID int, record importRecord) error {
+	_, err := tx.Exec(`
+		INSERT INTO daily_generation (date, location_id, actual_kwh, source)
+		VALUES (?, ?, ?, 'daily')
+		ON CONFLICT (date, location_id)
+		DO UPDATE SET actual_kwh = excluded.actual_kwh, source = excluded.source
+	`, record.Date, record.LocationID, record.Values["actual_kwh"])
+	return err
+}
+
+func (l *Loader) parseWeatherUpload(format string, body []byte, _ map[string]int) ([]importRecord, []structure.ImportRejection, error) {
+	table, err := readUploadTable(format, body, "date", "sunrise_time", "sunset_time")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var records []importRecord
+	var rejections []structure.ImportRejection
+	seen := make(map[string]int)
+rows:
+	for i := range table.rows {
+		date, err := time.Parse("2006-01-02", table.value(i, "date"))
+		if err != nil {
+			rejections = append(rejections, table.reject(i, "date", "invalid date %q", table.value(i, "date")))
+			continue
+		}
+		record := importRecord{
+			Row: table.line(i), Key: date.Format("2006-01-02"), Date: date.Format("2006-01-02"),
+			Values: make(map[string]float64), Text: make(map[string]string),
+		}
+
+		for _, column := range []string{"sunrise_time", "sunset_time"} {
+			value := table.value(i, column)
+			clock, err := time.Parse("15:04", value)
+			if err != nil {
+				rejections = append(rejections, table.reject(i, column, "invalid time %q, expected HH:MM", value))
+				continue rows
+			}
+			record.Text[column] = clock.Format("15:04")
+		}
+		for _, column := range weatherImportColumns {
+			value := table.value(i, column)
+			if !table.has(column) || value == "" {
+				continue
+			}
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+				rejections = append(rejections, table.reject(i, column, "invalid number %q", value))
+				continue rows
+			}
+			record.Values[column] = number
+		}
+
+		if first, ok := seen[record.Key]; ok {
+			rejections = append(rejections, table.reject(i, "date", "%s already appears on row %d", record.Key, first))
+			continue
+		}
+		seen[record.Key] = record.Row
+		records = append(records, record)
+	}
+	return records, rejections, nil
+}
+
+func currentWeatherDaily(tx *sql.Tx, dialect db.Dialect, record importRecord) (map[string]interface{}, error) {
+	// PostgreSQL folds the unquoted column names to lower case, so they are named as uploaded
+	columns := make([]string, len(weatherImportColumns))
+	for i, column := range weatherImportColumns {
+		columns[i] = fmt.Sprintf(`%s AS "%s"`, column, column)
+	}
+	return currentRow(tx, `
+		SELECT sunrise_time, sunset_time, `+strings.Join(columns, ", ")+`
+		FROM weather_daily
+		WHERE `+dialect.Day("date")+` = ?
+	`, record.Date)
+}
+
+// applyWeatherDaily sets the uploaded columns of the day, leaving columns the upload did not carry
+func applyWeatherDaily(tx *sql.Tx, importID int, record importRecord) error {
+	columns := []string{"date"}
+	args := []interface{}{record.Date}
+	for _, column := range []string{"sunrise_time", "sunset_time"} {
+		columns = append(columns, column)
+		args = append(args, record.Text[column])
+	}
+	for _, column := range weatherImportColumns {
+		if value, ok := record.Values[column]; ok {
+			columns = append(columns, column)
+			args = append(args, value)
+		}
+	}
+
+	updates := make([]string, 0, len(columns)-1)
+	for _, column := range columns[1:] {
+		updates = append(updates, fmt.Sprintf("%s = excluded.%s", column, column))
+	}
+	_, err := tx.Exec(fmt.Sprintf(`
+		INSERT INTO weather_daily (%s)
+		VALUES (%s)
+		ON CONFLICT (date) DO UPDATE SET %s
+	`, strings.Join(columns, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", "), strings.Join(updates, ", ")),
+		args...)
+	return err
+}
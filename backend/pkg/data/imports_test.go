@@ -0,0 +1,248 @@
+package data
+
+import (
+	"backend/pkg/audit"
+	"backend/pkg/db"
+	"backend/pkg/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// monthlyValues returns a month's actual and predicted kWh, with nil for missing values
+func monthlyValues(t *testing.T, database *db.DB, year, month, locationID int) (actual, predicted interface{}) {
+	t.Helper()
+	var a, p sql.NullFloat64
+	err := database.QueryRow(`SELECT actual_kwh, predicted_kwh FROM monthly_generation WHERE year = ? AND month = ? AND location_id = ?`,
+		year, month, locationID).Scan(&a, &p)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := func(v sql.NullFloat64) interface{} {
+		if !v.Valid {
+			return nil
+		}
+		return v.Float64
+	}
+	return value(a), value(p)
+}
+
+func TestCreateImport(t *testing.T) {
+	const upload = "site,year,month,energy_kwh\n" +
+		"Awali,2024,1,100\n" + // unchanged
+		"Awali,2024,2,120\n" + // updated
+		"UOB,2024,3,50\n" + // inserted
+		"Sitra,2024,3,10\n" + // rejected: unknown site
+		"UOB,2024,3,55\n" + // rejected: duplicate
+		"UOB,2024,13,5\n" // rejected: month
+
+	tests := []struct {
+		name     string
+		dataset  string
+		format   string
+		body     string
+		err      error
+		inserted int
+		updated  int
+		same     int
+		rejected int
+		months   []string
+	}{
+		{"monthly generation", DatasetMonthlyGeneration, FormatCSV, upload, nil, 1, 1, 1, 3, []string{"2024-02", "2024-03"}},
+		{"unknown dataset", "yearly_generation", FormatCSV, upload, ErrUnknownDataset, 0, 0, 0, 0, nil},
+		{"unknown format", DatasetMonthlyGeneration, "ods", upload, ErrInvalidUpload, 0, 0, 0, 0, nil},
+		{"missing column", DatasetMonthlyGeneration, FormatCSV, "site,year,energy_kwh\nAwali,2024,100\n", ErrInvalidUpload, 0, 0, 0, 0, nil},
+		{"daily generation", DatasetDailyGeneration, FormatCSV, "date,site,energy_kwh\n2024-02-01,Awali,4\n2024-02-31,Awali,4\n",
+			nil, 1, 0, 0, 1, []string{"2024-02"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := openTestLoader(t)
+			seedLocations(t, l.db)
+			if _, err := l.db.Exec(`INSERT INTO monthly_generation (year, month, location_id, actual_kwh, predicted_kwh)
+				VALUES (2024, 1, 1, 100, 90), (2024, 2, 1, 110, 95)`); err != nil {
+				t.Fatal(err)
+			}
+
+			diff, err := l.CreateImport(tt.dataset, "upload.csv", tt.format, []byte(tt.body))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("CreateImport() = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff.Status != "pending" || diff.Inserted != tt.inserted || diff.Updated != tt.updated ||
+				diff.Unchanged != tt.same || diff.Rejected != tt.rejected || !reflect.DeepEqual(diff.AffectedMonths, tt.months) {
+				t.Errorf("CreateImport() = %s, %d inserted, %d updated, %d unchanged, %d rejected, months %v",
+					diff.Status, diff.Inserted, diff.Updated, diff.Unchanged, diff.Rejected, diff.AffectedMonths)
+			}
+
+			// A dry run leaves the data alone
+			if actual, _ := monthlyValues(t, l.db, 2024, 2, 1); actual != 110.0 {
+				t.Errorf("February actual = %v after the dry run, want 110", actual)
+			}
+			if actual, _ := monthlyValues(t, l.db, 2024, 3, 3); actual != nil {
+				t.Errorf("March UOB actual = %v after the dry run, want none", actual)
+			}
+		})
+	}
+}
+
+func TestApplyImport(t *testing.T) {
+	l := openTestLoader(t)
+	seedLocations(t, l.db)
+	if _, err := l.db.Exec(`INSERT INTO monthly_generation (year, month, location_id, actual_kwh, predicted_kwh, theoretical_kwh)
+		VALUES (2024, 2, 1, 110, 95, 130), (2024, 2, 2, 200, 210, 260)`); err != nil {
+		t.Fatal(err)
+	}
+	// The history starts before the import, so its changes are recorded against the importer
+	if err := audit.RecordNow(context.Background(), l.db, audit.Baseline); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := l.CreateImport(DatasetMonthlyGeneration, "upload.csv", FormatCSV,
+		[]byte("site,year,month,energy_kwh\nAwali,2024,2,120\nUOB,2024,2,50\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := l.ApplyImport(pending.ID, audit.Change{Author: "tester"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied.Status != "applied" || applied.AppliedAt == nil || applied.Inserted != 1 || applied.Updated != 1 {
+		t.Errorf("ApplyImport() = %s, %d inserted, %d updated", applied.Status, applied.Inserted, applied.Updated)
+	}
+	var revised int
+	if err := l.db.QueryRow(`SELECT COUNT(*) FROM revisions WHERE changed_by = 'tester'`).Scan(&revised); err != nil {
+		t.Fatal(err)
+	}
+	if revised == 0 {
+		t.Error("ApplyImport() recorded no revisions")
+	}
+
+	tests := []struct {
+		name              string
+		locationID        int
+		actual, predicted interface{}
+	}{
+		// Only the actuals change; the model's forecast stays until it is rerun
+		{"updated", 1, 120.0, 95.0},
+		{"untouched", 2, 200.0, 210.0},
+		{"inserted", 3, 50.0, nil},
+		{"total system derived", 4, 370.0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual, predicted := monthlyValues(t, l.db, 2024, 2, tt.locationID); actual != tt.actual || predicted != tt.predicted {
+				t.Errorf("location %d = %v actual, %v predicted, want %v, %v", tt.locationID, actual, predicted, tt.actual, tt.predicted)
+			}
+		})
+	}
+
+	if _, err := l.ApplyImport(pending.ID, audit.Change{Author: "tester"}); !errors.Is(err, ErrImportNotPending) {
+		t.Errorf("applying twice = %v, want %v", err, ErrImportNotPending)
+	}
+	if err := l.DiscardImport(pending.ID); !errors.Is(err, ErrImportNotPending) {
+		t.Errorf("discarding an applied import = %v, want %v", err, ErrImportNotPending)
+	}
+	if _, err := l.ApplyImport(pending.ID+1, audit.Change{Author: "tester"}); !errors.Is(err, repository.ErrImportNotFound) {
+		t.Errorf("applying a missing import = %v, want %v", err, repository.ErrImportNotFound)
+	}
+}
+
+func TestApplyImportThenRefresh(t *testing.T) {
+	l := openTestLoader(t)
+	seedLocations(t, l.db)
+	if _, err := l.db.Exec(`INSERT INTO monthly_generation (year, month, location_id, actual_kwh, predicted_kwh, theoretical_kwh)
+		VALUES (2024, 1, 1, 100, 105, 130), (2024, 2, 1, 110, 95, 130), (2024, 3, 1, NULL, 90, 130)`); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := l.CreateImport(DatasetMonthlyGeneration, "upload.csv", FormatCSV,
+		[]byte("site,year,month,energy_kwh\nAwali,2024,2,120\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.ApplyImport(pending.ID, audit.Change{Author: "tester"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The refresh the import triggers reruns import_generation, which rereads the workbook
+	workbook := []WorkbookMonth{
+		{Site: "Awali", Year: 2024, Month: 1, ActualKWh: 100},
+		{Site: "Awali", Year: 2024, Month: 2, ActualKWh: 110},
+	}
+	if err := l.saveEnergyMonths(context.Background(), workbook); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.AggregateMonthlyGeneration(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name              string
+		month             int
+		actual, predicted interface{}
+	}{
+		{"workbook month", 1, 100.0, 105.0},
+		{"imported month", 2, 120.0, 95.0},
+		{"forecast month", 3, nil, 90.0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual, predicted := monthlyValues(t, l.db, 2024, tt.month, 1); actual != tt.actual || predicted != tt.predicted {
+				t.Errorf("month %d = %v actual, %v predicted, want %v, %v", tt.month, actual, predicted, tt.actual, tt.predicted)
+			}
+		})
+	}
+}
+
+func TestDiscardImport(t *testing.T) {
+	l := openTestLoader(t)
+	seedLocations(t, l.db)
+
+	pending, err := l.CreateImport(DatasetMonthlyGeneration, "upload.csv", FormatCSV, []byte("site,year,month,energy_kwh\nUOB,2024,2,50\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.DiscardImport(pending.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.ApplyImport(pending.ID, audit.Change{Author: "tester"}); !errors.Is(err, ErrImportNotPending) {
+		t.Errorf("applying a discarded import = %v, want %v", err, ErrImportNotPending)
+	}
+	if actual, _ := monthlyValues(t, l.db, 2024, 2, 3); actual != nil {
+		t.Errorf("UOB actual = %v after a discarded import, want none", actual)
+	}
+	if err := l.DiscardImport(pending.ID + 1); !errors.Is(err, repository.ErrImportNotFound) {
+		t.Errorf("discarding a missing import = %v, want %v", err, repository.ErrImportNotFound)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		filename string
+		body     string
+		want     string
+	}{
+		{"generation.csv", "site,year", FormatCSV},
+		{"generation.XLSX", "", FormatXLSX},
+		{"upload", "PK\x03\x04rest", FormatXLSX},
+		{"", "date,site", FormatCSV},
+	}
+	for _, tt := range tests {
+		if got := DetectFormat(tt.filename, []byte(tt.body)); got != tt.want {
+			t.Errorf("DetectFormat(%q) = %s, want %s", tt.filename, got, tt.want)
+		}
+	}
+}
@@ -1,18 +1,23 @@
 package data
 
 import (
+	"context"
 	"fmt"
-	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
-	"backend/pkg/db"
-    "log"
+	"log/slog"
 )
 
-func InitializeLocations() error {
+func (l *Loader) InitializeLocations(ctx context.Context) error {
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
     // Clear the table before inserting new data
-	_, err := db.Database.Exec("DELETE FROM locations")
+	_, err = tx.ExecContext(ctx, "DELETE FROM locations")
 	if err != nil {
-		log.Printf("Error clearing locations table: %v", err)
+		slog.Error("Error clearing locations table", "err", err)
 		return err
 	}
 
@@ -31,7 +36,7 @@ func InitializeLocations() error {
 
     // Insert locations with their details
     for _, loc := range locations {
-        _, err := db.Database.Exec(`
+        _, err := tx.ExecContext(ctx, `
             INSERT INTO locations (
                 id, 
                 name, 
@@ -52,6 +57,10 @@ func InitializeLocations() error {
         }
     }
 
-    fmt.Println("Successfully initialized locations table")
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("error committing locations: %v", err)
+    }
+
+    slog.Info("Successfully initialized locations table")
     return nil
 }
@@ -1,142 +1,152 @@
 package data
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
-	"strconv"
-	_ "github.com/lib/pq"
+	"log/slog"
 	_ "github.com/mattn/go-sqlite3"
-	"github.com/xuri/excelize/v2"
-	"backend/pkg/db"
-	"log"
 )
 
-// ImportDataFromExcel reads data from an Excel file and inserts it into the database
-func ImportEnergyData() {
-
-	_, err := db.Database.Exec("DELETE FROM monthly_generation") 
-	if err != nil {
-		log.Fatalf("Error clearing solar energy table: %v", err)
-	}
-
-	f, err := excelize.OpenFile("../../pkg/db/BapcoSolarEnergy.xlsx")
+// ImportEnergyData reads the monthly generation of every site from the Excel workbook, as laid
+// out in the workbook mapping, and brings the actuals in monthly_generation up to date with it,
+// keeping months uploaded since. The workbook is fully validated first, so a bad cell leaves the
+// table untouched.
+func (l *Loader) ImportEnergyData(ctx context.Context) error {
+	workbook, err := l.readEnergyWorkbook()
 	if err != nil {
-		fmt.Println("Error opening Excel file:", err)
-		return
-	}
-	defer f.Close()
-
-	// Read UOB data
-	if err := importUOBData(f); err != nil {
-		fmt.Println("Error importing UOB data:", err)
-		return
+		return fmt.Errorf("error reading energy workbook: %w", err)
 	}
-
-	// Read Refinery data
-	if err := importRefineryData(f); err != nil {
-		fmt.Println("Error importing Refinery data:", err)
-		return
-	}
-
-	// Read Awali data
-	if err := importAwaliData(f); err != nil {
-		fmt.Println("Error importing Awali data:", err)
-		return
+	if err := l.saveEnergyMonths(ctx, workbook.Months); err != nil {
+		return err
 	}
 
-	// Calculate and insert total system data
-	if err := calculateTotalSystem(); err != nil {
-		fmt.Println("Error calculating total system:", err)
-		return
-	}
+	slog.Info("Successfully imported all energy data")
+	return nil
+}
 
-	fmt.Println("Successfully imported all energy data")
+// energyMonth identifies a site's month of actual generation
+type energyMonth struct {
+	year, month, locationID int
 }
 
-func importUOBData(f *excelize.File) error {
-	rows, err := f.GetRows("UOB")
+// saveEnergyMonths upserts the actual of every workbook month and clears the actuals of months
+// that are no longer in the workbook or an import. The predicted and theoretical columns are left
+// alone; a row with none of the three left is deleted.
+func (l *Loader) saveEnergyMonths(ctx context.Context, months []WorkbookMonth) error {
+	sites, err := l.siteIDs()
 	if err != nil {
 		return err
 	}
 
-	for _, row := range rows[1:] {
-		if len(row) < 3 {
-			continue
-		}
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
 
-		year, _ := strconv.Atoi(row[0])
-		month, _ := strconv.Atoi(row[1])
-		totalUOB, _ := strconv.ParseFloat(row[2], 64)
+	stmt, err := tx.Prepare(`
+		INSERT INTO monthly_generation (year, month, location_id, actual_kwh)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (year, month, location_id)
+		DO UPDATE SET actual_kwh = excluded.actual_kwh;`)
+	if err != nil {
+		return fmt.Errorf("error preparing statement: %v", err)
+	}
+	defer stmt.Close()
 
-		_, err := db.Database.Exec(`
-			INSERT INTO monthly_generation (year, month, location_id, actual_kwh)
-			VALUES (?, ?, 3, ?)
-			ON CONFLICT (year, month, location_id) 
-			DO UPDATE SET actual_kwh = excluded.actual_kwh;`,
-			year, month, totalUOB)
+	sourced := make(map[energyMonth]bool)
+	for _, month := range months {
+		locationID, err := lookupSite(sites, month.Site)
 		if err != nil {
-			return err
+			return fmt.Errorf("error importing %s data: %v", month.Site, err)
 		}
+		if _, err := stmt.Exec(month.Year, month.Month, locationID, month.ActualKWh); err != nil {
+			return fmt.Errorf("error importing %s data: %v", month.Site, err)
+		}
+		sourced[energyMonth{month.Year, month.Month, locationID}] = true
 	}
-	return nil
-}
 
-func importRefineryData(f *excelize.File) error {
-	rows, err := f.GetRows("Refinery")
+	// Months uploaded through /api/imports take precedence over the workbook
+	_, err = tx.Exec(`
+		INSERT INTO monthly_generation (year, month, location_id, actual_kwh)
+		SELECT year, month, location_id, actual_kwh FROM monthly_generation_imports
+		WHERE true
+		ON CONFLICT (year, month, location_id)
+		DO UPDATE SET actual_kwh = excluded.actual_kwh;`)
 	if err != nil {
-		return err
+		return fmt.Errorf("error applying imported months: %v", err)
 	}
 
-	for _, row := range rows[1:] {
-		if len(row) < 7 {
-			continue
-		}
+	if err := clearRemovedActuals(tx, sites, sourced); err != nil {
+		return fmt.Errorf("error clearing removed months: %v", err)
+	}
+
+	// Calculate and insert total system data
+	if err := calculateTotalSystem(tx); err != nil {
+		return fmt.Errorf("error calculating total system: %v", err)
+	}
 
-		year, _ := strconv.Atoi(row[0])
-		month, _ := strconv.Atoi(row[1])
-		totalRefinery, _ := strconv.ParseFloat(row[6], 64) 
+	if _, err := tx.Exec(`
+		DELETE FROM monthly_generation
+		WHERE actual_kwh IS NULL AND predicted_kwh IS NULL AND theoretical_kwh IS NULL`); err != nil {
+		return fmt.Errorf("error deleting empty months: %v", err)
+	}
 
-		_, err := db.Database.Exec(`
-			INSERT INTO monthly_generation (year, month, location_id, actual_kwh)
-			VALUES (?, ?, 2, ?)
-			ON CONFLICT (year, month, location_id) 
-			DO UPDATE SET actual_kwh = excluded.actual_kwh;`,
-			year, month, totalRefinery)
-		if err != nil {
-			return err
-		}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing energy data: %v", err)
 	}
 	return nil
 }
 
-func importAwaliData(f *excelize.File) error {
-	rows, err := f.GetRows("Awali")
+// clearRemovedActuals sets actual_kwh to NULL for the site months that are neither in sourced nor
+// uploaded through an import
+func clearRemovedActuals(tx *sql.Tx, sites map[string]int, sourced map[energyMonth]bool) error {
+	siteIDs := make(map[int]bool)
+	for _, id := range sites {
+		siteIDs[id] = true
+	}
+
+	rows, err := tx.Query(`
+		SELECT mg.year, mg.month, mg.location_id
+		FROM monthly_generation mg
+		WHERE mg.actual_kwh IS NOT NULL
+		AND NOT EXISTS (
+			SELECT 1 FROM monthly_generation_imports i
+			WHERE i.year = mg.year AND i.month = mg.month AND i.location_id = mg.location_id
+		)`)
 	if err != nil {
 		return err
 	}
-
-	for _, row := range rows[1:] {
-		if len(row) < 13 {
-			continue
+	var removed []energyMonth
+	for rows.Next() {
+		var m energyMonth
+		if err := rows.Scan(&m.year, &m.month, &m.locationID); err != nil {
+			rows.Close()
+			return err
 		}
+		if siteIDs[m.locationID] && !sourced[m] {
+			removed = append(removed, m)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
 
-		year, _ := strconv.Atoi(row[0])
-		month, _ := strconv.Atoi(row[1])
-		totalAwali, _ := strconv.ParseFloat(row[12], 64) 
-
-		_, err := db.Database.Exec(`
-			INSERT INTO monthly_generation (year, month, location_id, actual_kwh)
-			VALUES (?, ?, 1, ?)
-			ON CONFLICT (year, month, location_id) 
-			DO UPDATE SET actual_kwh = excluded.actual_kwh;`,
-			year, month, totalAwali)
-		if err != nil {
+	for _, m := range removed {
+		if _, err := tx.Exec(`UPDATE monthly_generation SET actual_kwh = NULL WHERE year = ? AND month = ? AND location_id = ?`,
+			m.year, m.month, m.locationID); err != nil {
 			return err
 		}
 	}
+	if len(removed) > 0 {
+		slog.Info("Cleared actuals no longer in the energy workbook", "months", len(removed))
+	}
 	return nil
 }
 
-func calculateTotalSystem() error {
+func calculateTotalSystem(tx *sql.Tx) error {
 	// Calculate and insert total system data
 	query := `
 		INSERT INTO monthly_generation (year, month, location_id, actual_kwh)
@@ -152,7 +162,7 @@ func calculateTotalSystem() error {
 		DO UPDATE SET actual_kwh = excluded.actual_kwh;
 	`
 
-	_, err := db.Database.Exec(query)
+	_, err := tx.Exec(query)
 	return err
 }
 
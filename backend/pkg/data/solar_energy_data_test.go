@@ -0,0 +1,95 @@
+package data
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSaveEnergyMonths(t *testing.T) {
+	l := openTestLoader(t)
+	seedLocations(t, l.db)
+	_, err := l.db.Exec(`INSERT INTO monthly_generation (year, month, location_id, actual_kwh, predicted_kwh, theoretical_kwh) VALUES
+		(2024, 1, 1, 100, 110, 150),
+		(2024, 2, 1, 120, 115, 150),
+		(2024, 3, 1, 90, 95, 150),
+		(2024, 4, 1, 80, NULL, NULL),
+		(2024, 1, 3, 40, 45, 60),
+		(2024, 1, 4, 140, 155, 210),
+		(2025, 1, 1, NULL, 130, 150)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = l.db.Exec(`INSERT INTO imports (id, dataset, status, records_json, diff_json, created_at)
+		VALUES (1, 'monthly_generation', 'applied', '[]', '[]', CURRENT_TIMESTAMP)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = l.db.Exec(`INSERT INTO monthly_generation_imports (year, month, location_id, actual_kwh, import_id) VALUES
+		(2024, 2, 1, 125, 1), (2024, 3, 1, 90, 1)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	months := []WorkbookMonth{
+		{Site: "Awali", Year: 2024, Month: 1, ActualKWh: 105},
+		{Site: "Awali", Year: 2024, Month: 2, ActualKWh: 122},
+		{Site: "UOB", Year: 2024, Month: 1, ActualKWh: 40},
+		{Site: "Refinery", Year: 2024, Month: 1, ActualKWh: 200},
+	}
+	if err := l.saveEnergyMonths(context.Background(), months); err != nil {
+		t.Fatalf("saveEnergyMonths() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		year       int
+		month      int
+		locationID int
+		actual     interface{}
+		predicted  interface{}
+	}{
+		{"updated actual keeps prediction", 2024, 1, 1, 105.0, 110.0},
+		{"uploaded month wins over workbook", 2024, 2, 1, 125.0, 115.0},
+		{"uploaded month missing from workbook", 2024, 3, 1, 90.0, 95.0},
+		{"removed month is deleted when empty", 2024, 4, 1, nil, nil},
+		{"inserted month", 2024, 1, 2, 200.0, nil},
+		{"total system recomputed", 2024, 1, 4, 345.0, 155.0},
+		{"forecast month untouched", 2025, 1, 1, nil, 130.0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, predicted := monthlyValues(t, l.db, tt.year, tt.month, tt.locationID)
+			if !reflect.DeepEqual(actual, tt.actual) || !reflect.DeepEqual(predicted, tt.predicted) {
+				t.Errorf("monthlyValues() = %v, %v, want %v, %v", actual, predicted, tt.actual, tt.predicted)
+			}
+		})
+	}
+
+	var theoretical float64
+	if err := l.db.QueryRow(`SELECT theoretical_kwh FROM monthly_generation WHERE year = 2024 AND month = 1 AND location_id = 1`).Scan(&theoretical); err != nil {
+		t.Fatal(err)
+	}
+	if theoretical != 150 {
+		t.Errorf("theoretical_kwh = %v, want 150", theoretical)
+	}
+}
+
+func TestSaveEnergyMonthsClearsRemovedActual(t *testing.T) {
+	l := openTestLoader(t)
+	seedLocations(t, l.db)
+	_, err := l.db.Exec(`INSERT INTO monthly_generation (year, month, location_id, actual_kwh, predicted_kwh) VALUES
+		(2024, 1, 1, 100, 110), (2024, 2, 1, 120, 115)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.saveEnergyMonths(context.Background(), []WorkbookMonth{{Site: "Awali", Year: 2024, Month: 1, ActualKWh: 100}}); err != nil {
+		t.Fatalf("saveEnergyMonths() error = %v", err)
+	}
+
+	actual, predicted := monthlyValues(t, l.db, 2024, 2, 1)
+	if actual != nil || predicted != 115.0 {
+		t.Errorf("monthlyValues() = %v, %v, want <nil>, 115", actual, predicted)
+	}
+}
@@ -2,70 +2,91 @@
 package data
 
 import (
-	"backend/pkg/db"
+	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
-	"net/http"
+	"log/slog"
 	"time"
 	"math"
 	structure "backend/pkg/struct"
 )
 
-func FetchWeatherData() {
-	// Clear the table before inserting new data
-	_, err := db.Database.Exec("DELETE FROM weather_daily") 
-	if err != nil {
-		log.Fatalf("Error clearing weather table: %v", err)
-	}
-
+// FetchWeatherData replaces weather_daily with the archive from 2015 to 2019. The days are fetched
+// before the table is cleared, and swapped in with one transaction.
+func (l *Loader) FetchWeatherData(ctx context.Context) error {
 	startDate := "2015-01-01"
 	endDate := "2019-12-31"
 
 	// Parse the start and end dates
 	start, err := time.Parse("2006-01-02", startDate)
 	if err != nil {
-		fmt.Println("Error parsing start date:", err)
-		return
+		return fmt.Errorf("error parsing start date: %v", err)
 	}
 	end, err := time.Parse("2006-01-02", endDate)
 	if err != nil {
-		fmt.Println("Error parsing end date:", err)
-		return
+		return fmt.Errorf("error parsing end date: %v", err)
+	}
+
+	results, err := l.fetchWeatherRange(ctx, start, end)
+	if err != nil {
+		return err
+	}
+	return l.saveToDatabase(ctx, results, true)
+}
+
+// FetchLatestWeatherData appends the days after the last stored date, up to the
+// most recent day the archive API has published
+func (l *Loader) FetchLatestWeatherData(ctx context.Context) error {
+	var lastDate sql.NullString
+	err := l.db.QueryRowContext(ctx, "SELECT MAX(date) FROM weather_daily").Scan(&lastDate)
+	if err != nil {
+		return fmt.Errorf("error getting last weather date: %v", err)
+	}
+	if !lastDate.Valid {
+		return l.FetchWeatherData(ctx)
+	}
+
+	last, err := time.Parse("2006-01-02", lastDate.String[:10])
+	if err != nil {
+		return fmt.Errorf("error parsing last weather date %q: %v", lastDate.String, err)
 	}
 
+	end := l.LatestArchiveDay()
+	start := last.AddDate(0, 0, 1)
+	if start.After(end) {
+		slog.Info("Weather data is up to date", "last_day", last.Format("2006-01-02"))
+		return nil
+	}
+
+	results, err := l.fetchWeatherRange(ctx, start, end)
+	if err != nil {
+		return err
+	}
+	return l.saveToDatabase(ctx, results, false)
+}
+
+// fetchWeatherRange fetches the days from start to end. A day the API fails on is logged and
+// left out; a cancelled ctx stops the fetch.
+func (l *Loader) fetchWeatherRange(ctx context.Context, start, end time.Time) ([]map[string]interface{}, error) {
+	results := make([]map[string]interface{}, 0)
+
 	// Loop through each day in the date range
 	for current := start; current.Before(end) || current.Equal(end); current = current.AddDate(0, 0, 1) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		dateStr := current.Format("2006-01-02")
 
 		// Fetch data from the API for the current date
-		resp, err := http.Get(fmt.Sprintf("https://archive-api.open-meteo.com/v1/archive?latitude=26&longitude=50.55&start_date=%s&end_date=%s&hourly=temperature_2m,relative_humidity_2m,cloud_cover,wind_speed_10m,direct_normal_irradiance&daily=sunrise,sunset,daylight_duration,sunshine_duration,rain_sum&timezone=auto", dateStr, dateStr))
+		data, err := l.fetchWeatherDay(ctx, dateStr)
 		if err != nil {
-			fmt.Println("Error fetching data for date", dateStr, ":", err)
+			slog.Error("Error fetching weather", "date", dateStr, "err", err)
 			continue
 		}
-		defer resp.Body.Close()
-
-		var data structure.APIResponse
-		if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-			fmt.Println("Error decoding JSON for date", dateStr, ":", err)
-			continue
-		}
-
-		// Initialize variables
-		startIndex := -1
-		endIndex := -1
-		results := make([]map[string]interface{}, 0)
 
 		// Find daylight period
-		for i, irradiance := range data.Hourly.DirectNormalIrradiance {
-			if irradiance > 0 && startIndex == -1 {
-				startIndex = i
-			} else if irradiance == 0 && startIndex != -1 {
-				endIndex = i - 1
-				break
-			}
-		}
+		startIndex, endIndex := daylightWindow(data.Hourly.DirectNormalIrradiance)
 
 		// Calculate averages and min/max for the specified parameters during daylight hours
 		if startIndex != -1 && endIndex != -1 {
@@ -103,11 +124,46 @@ func FetchWeatherData() {
 			}
 			results = append(results, result)
 		}
+	}
+	return results, nil
+}
+
+func (l *Loader) fetchWeatherDay(ctx context.Context, date string) (*structure.APIResponse, error) {
+	url := l.weatherURL(l.weather.ArchiveURL, fmt.Sprintf("start_date=%s&end_date=%s&hourly=temperature_2m,relative_humidity_2m,cloud_cover,wind_speed_10m,direct_normal_irradiance&daily=sunrise,sunset,daylight_duration,sunshine_duration,rain_sum&timezone=auto", date, date))
+	resp, err := httpGet(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 
-		if len(results) > 0 {
-			saveToDatabase(results)
+	var data structure.APIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("error decoding weather: %v", err)
+	}
+	return &data, nil
+}
+
+// weatherURL is an Open-Meteo endpoint queried at the configured site
+func (l *Loader) weatherURL(base, query string) string {
+	return fmt.Sprintf("%s?latitude=%g&longitude=%g&%s", base, l.weather.Latitude, l.weather.Longitude, query)
+}
+
+// LatestArchiveDay returns the most recent day the weather archive is expected to have
+func (l *Loader) LatestArchiveDay() time.Time {
+	return time.Now().UTC().AddDate(0, 0, -l.weather.ArchiveLagDays).Truncate(24 * time.Hour)
+}
+
+// daylightWindow returns the first and last hour with direct irradiance, or -1, -1 if there is none
+func daylightWindow(irradiance []float64) (int, int) {
+	startIndex := -1
+	for i, value := range irradiance {
+		if value > 0 && startIndex == -1 {
+			startIndex = i
+		} else if value == 0 && startIndex != -1 {
+			return startIndex, i - 1
 		}
 	}
+	return -1, -1
 }
 
 func findMin(data []float64) float64 {
@@ -144,23 +200,36 @@ func formatTimeOnly(datetime string) string {
 	return t.Format("15:04")
 }
 
-func saveToDatabase(results []map[string]interface{}) {
-	for _, result := range results {
-		stmt, err := db.Database.Prepare(`
-			INSERT INTO weather_daily (
-				date, sunrise_time, sunset_time, sunshine_duration_seconds,
-				daylight_duration_seconds, min_temperature_C, avg_temperature_C,
-				max_temperature_C, avg_solar_irradiance_wm2, avg_relative_humidity_percent,
-				avg_cloud_cover_percent, avg_wind_speed_kmh, rainfall_mm
-			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		`)
-		if err != nil {
-			log.Printf("Error preparing statement: %v", err)
-			continue
+// saveToDatabase inserts the fetched days into weather_daily in one transaction, first clearing
+// the table when replace is set
+func (l *Loader) saveToDatabase(ctx context.Context, results []map[string]interface{}, replace bool) error {
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if replace {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM weather_daily"); err != nil {
+			return fmt.Errorf("error clearing weather table: %v", err)
 		}
-		defer stmt.Close()
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO weather_daily (
+			date, sunrise_time, sunset_time, sunshine_duration_seconds,
+			daylight_duration_seconds, min_temperature_C, avg_temperature_C,
+			max_temperature_C, avg_solar_irradiance_wm2, avg_relative_humidity_percent,
+			avg_cloud_cover_percent, avg_wind_speed_kmh, rainfall_mm
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("error preparing statement: %v", err)
+	}
+	defer stmt.Close()
 
-		_, err = stmt.Exec(
+	for _, result := range results {
+		_, err = stmt.ExecContext(ctx,
 			result["date"],
 			result["sunrise_time"],
 			result["sunset_time"],
@@ -176,11 +245,15 @@ func saveToDatabase(results []map[string]interface{}) {
 			result["rainfall_mm"],
 		)
 		if err != nil {
-			log.Printf("Error inserting data for date %v: %v", result["date"], err)
-			continue
+			return fmt.Errorf("error inserting weather data for %v: %v", result["date"], err)
 		}
-		fmt.Printf("Successfully inserted weather data for date: %v\n", result["date"])
 	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing weather data: %v", err)
+	}
+	slog.Info("Inserted weather data", "days", len(results))
+	return nil
 }
 
 func calculateAverage(data []float64) float64 {
@@ -192,11 +265,19 @@ func calculateAverage(data []float64) float64 {
 	return math.Round(average*100) / 100
 }
 
-func InsertMonthlyWeatherData() {
+// InsertMonthlyWeatherData rebuilds weather_monthly from the checked daily weather in one
+// transaction
+func (l *Loader) InsertMonthlyWeatherData(ctx context.Context) error {
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
 	// Clear the table before inserting new data
-	_, err := db.Database.Exec("DELETE FROM weather_monthly")
+	_, err = tx.ExecContext(ctx, "DELETE FROM weather_monthly")
 	if err != nil {
-		log.Fatalf("Error clearing monthly weather table: %v", err)
+		return fmt.Errorf("error clearing monthly weather table: %v", err)
 	}
     // Query to aggregate daily data into monthly data
     query := `
@@ -215,8 +296,8 @@ func InsertMonthlyWeatherData() {
             total_rainfall_mm
         )
         SELECT 
-            CAST(strftime('%Y', date) AS INTEGER) as year,
-            CAST(strftime('%m', date) AS INTEGER) as month,
+            ` + l.db.Dialect.YearOf("date") + ` as year,
+            ` + l.db.Dialect.MonthOf("date") + ` as month,
             ROUND(AVG(sunshine_duration_seconds)) as avg_sunshine_duration_seconds,
             ROUND(AVG(daylight_duration_seconds)) as avg_daylight_duration_seconds,
             MIN(min_temperature_C) as min_temperature_C,
@@ -227,25 +308,28 @@ func InsertMonthlyWeatherData() {
             AVG(avg_cloud_cover_percent) as avg_cloud_cover_percent,
             AVG(avg_wind_speed_kmh) as avg_wind_speed_kmh,
             SUM(rainfall_mm) as total_rainfall_mm
-        FROM weather_daily
+        FROM weather_daily_checked
         GROUP BY year, month
         ORDER BY year, month;
     `
 
     // Execute the query
-    result, err := db.Database.Exec(query)
+    result, err := tx.ExecContext(ctx, query)
     if err != nil {
-        log.Printf("Error aggregating monthly weather data: %v", err)
-        return
+        return fmt.Errorf("error aggregating monthly weather data: %v", err)
     }
 
     rowsAffected, err := result.RowsAffected()
     if err != nil {
-        log.Printf("Error getting rows affected: %v", err)
-        return
+        return fmt.Errorf("error getting rows affected: %v", err)
+    }
+
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("error committing monthly weather data: %v", err)
     }
 
-    log.Printf("Successfully inserted %d monthly records", rowsAffected)
+    slog.Info("Inserted monthly weather", "rows", rowsAffected)
+    return nil
 }
 
 
@@ -0,0 +1,128 @@
+package data
+
+import (
+	"backend/pkg/config"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func weatherDay(date string) map[string]interface{} {
+	return map[string]interface{}{
+		"date":                          date,
+		"sunrise_time":                  "05:30",
+		"sunset_time":                   "18:00",
+		"sunshine_duration_seconds":     36000.0,
+		"daylight_duration_seconds":     45000.0,
+		"min_temperature_C":             20.0,
+		"avg_temperature_C":             25.0,
+		"max_temperature_C":             30.0,
+		"avg_solar_irradiance_wm2":      600.0,
+		"avg_relative_humidity_percent": 50.0,
+		"avg_cloud_cover_percent":       10.0,
+		"avg_wind_speed_kmh":            12.0,
+		"rainfall_mm":                   0.0,
+	}
+}
+
+func weatherDates(t *testing.T, l *Loader) []string {
+	t.Helper()
+	rows, err := l.db.Query(`SELECT date FROM weather_daily ORDER BY date`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	dates := []string{}
+	for rows.Next() {
+		var date string
+		if err := rows.Scan(&date); err != nil {
+			t.Fatal(err)
+		}
+		dates = append(dates, date[:10])
+	}
+	return dates
+}
+
+func TestSaveWeatherDays(t *testing.T) {
+	tests := []struct {
+		name    string
+		days    []string
+		replace bool
+		ok      bool
+		want    []string
+	}{
+		{"append", []string{"2024-01-03"}, false, true, []string{"2024-01-01", "2024-01-02", "2024-01-03"}},
+		{"replace", []string{"2024-01-03"}, true, true, []string{"2024-01-03"}},
+		// The duplicate day fails the insert, which rolls back the delete with it
+		{"failed replace", []string{"2024-01-03", "2024-01-03"}, true, false, []string{"2024-01-01", "2024-01-02"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := openTestLoader(t)
+			if err := l.saveToDatabase(context.Background(), []map[string]interface{}{weatherDay("2024-01-01"), weatherDay("2024-01-02")}, false); err != nil {
+				t.Fatal(err)
+			}
+
+			var results []map[string]interface{}
+			for _, day := range tt.days {
+				results = append(results, weatherDay(day))
+			}
+			if err := l.saveToDatabase(context.Background(), results, tt.replace); (err == nil) != tt.ok {
+				t.Errorf("saveToDatabase() = %v, want ok %v", err, tt.ok)
+			}
+			if got := weatherDates(t, l); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("weather_daily dates = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInsertMonthlyWeatherDataCancelled(t *testing.T) {
+	l := openTestLoader(t)
+	if err := l.saveToDatabase(context.Background(), []map[string]interface{}{weatherDay("2024-01-01")}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.InsertMonthlyWeatherData(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.InsertMonthlyWeatherData(ctx); err == nil {
+		t.Error("InsertMonthlyWeatherData() with a cancelled context succeeded")
+	}
+
+	var months int
+	if err := l.db.QueryRow(`SELECT COUNT(*) FROM weather_monthly`).Scan(&months); err != nil {
+		t.Fatal(err)
+	}
+	if months != 1 {
+		t.Errorf("weather_monthly has %d months after a cancelled rebuild, want 1", months)
+	}
+}
+
+func TestLoaderWeatherConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		weather config.WeatherConfig
+		url     string
+		lag     int
+	}{
+		{"bahrain", config.WeatherConfig{Latitude: 26.0667, Longitude: 50.5577, ArchiveLagDays: 5}, "https://archive.test/v1?latitude=26.0667&longitude=50.5577&daily=sunrise", 5},
+		{"no lag", config.WeatherConfig{Latitude: -33.5, Longitude: 151}, "https://archive.test/v1?latitude=-33.5&longitude=151&daily=sunrise", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLoader(nil, nil, config.DataConfig{}, tt.weather, config.ModelConfig{})
+			if got := l.weatherURL("https://archive.test/v1", "daily=sunrise"); got != tt.url {
+				t.Errorf("weatherURL = %q, want %q", got, tt.url)
+			}
+			want := time.Now().UTC().AddDate(0, 0, -tt.lag).Truncate(24 * time.Hour)
+			if got := l.LatestArchiveDay(); !got.Equal(want) {
+				t.Errorf("LatestArchiveDay = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,330 @@
+package data
+
+import (
+	structure "backend/pkg/struct"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Outlook sources recorded in weather_outlook.source
+const (
+	OutlookSourceForecast = "open-meteo-forecast"
+	OutlookSourceSeasonal = "open-meteo-seasonal"
+	OutlookSourceCSV      = "csv"
+)
+
+const (
+	forecastOutlookQuery = "hourly=temperature_2m,relative_humidity_2m,cloud_cover,wind_speed_10m,direct_normal_irradiance&daily=sunrise,sunset,daylight_duration,sunshine_duration,rain_sum&forecast_days=16&timezone=auto"
+	// The seasonal model has no direct normal irradiance, cloud cover or humidity. Those columns are
+	// left empty and the forecast model fills them from the historical mean for the month.
+	seasonalOutlookQuery = "daily=temperature_2m_max,temperature_2m_min,precipitation_sum&forecast_days=183&timezone=auto"
+)
+
+// outlookColumns are the weather_outlook value columns, which share names with weather_daily
+var outlookColumns = []string{
+	"sunshine_duration_seconds",
+	"daylight_duration_seconds",
+	"min_temperature_C",
+	"avg_temperature_C",
+	"max_temperature_C",
+	"avg_solar_irradiance_wm2",
+	"avg_relative_humidity_percent",
+	"avg_cloud_cover_percent",
+	"avg_wind_speed_kmh",
+	"rainfall_mm",
+}
+
+// outlookDay is one day of one ensemble member. Missing values are nil.
+type outlookDay struct {
+	Date   string
+	Member int
+	Values map[string]*float64
+}
+
+// FetchForecastOutlook stores the 16-day deterministic forecast as member 0
+func (l *Loader) FetchForecastOutlook(ctx context.Context) error {
+	resp, err := httpGet(ctx, l.weatherURL(l.weather.ForecastURL, forecastOutlookQuery))
+	if err != nil {
+		return fmt.Errorf("error fetching forecast outlook: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error fetching forecast outlook: %s", resp.Status)
+	}
+
+	var data structure.APIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return fmt.Errorf("error decoding forecast outlook: %v", err)
+	}
+
+	var days []outlookDay
+	for i, date := range data.Daily.Time {
+		from, to := i*24, (i+1)*24
+		if to > len(data.Hourly.DirectNormalIrradiance) {
+			break
+		}
+
+		startIndex, endIndex := daylightWindow(data.Hourly.DirectNormalIrradiance[from:to])
+		if startIndex == -1 {
+			continue
+		}
+		startIndex, endIndex = from+startIndex, from+endIndex+1
+
+		tempSlice := data.Hourly.Temperature2m[startIndex:endIndex]
+		days = append(days, outlookDay{
+			Date: date,
+			Values: map[string]*float64{
+				"sunshine_duration_seconds":     valueAt(data.Daily.SunshineDuration, i),
+				"daylight_duration_seconds":     valueAt(data.Daily.DaylightDuration, i),
+				"min_temperature_C":             floatPtr(findMin(tempSlice)),
+				"avg_temperature_C":             floatPtr(calculateAverage(tempSlice)),
+				"max_temperature_C":             floatPtr(findMax(tempSlice)),
+				"avg_solar_irradiance_wm2":      floatPtr(calculateAverage(data.Hourly.DirectNormalIrradiance[startIndex:endIndex])),
+				"avg_relative_humidity_percent": floatPtr(calculateAverage(data.Hourly.RelativeHumidity2m[startIndex:endIndex])),
+				"avg_cloud_cover_percent":       floatPtr(calculateAverage(data.Hourly.CloudCover[startIndex:endIndex])),
+				"avg_wind_speed_kmh":            floatPtr(calculateAverage(data.Hourly.WindSpeed10m[startIndex:endIndex])),
+				"rainfall_mm":                   valueAt(data.Daily.RainSum, i),
+			},
+		})
+	}
+
+	return l.saveOutlook(ctx, OutlookSourceForecast, time.Now().UTC(), days)
+}
+
+// FetchSeasonalOutlook stores the seasonal ensemble, one set of rows per member.
+// The control run is member 0 and perturbed runs keep their _memberNN number.
+func (l *Loader) FetchSeasonalOutlook(ctx context.Context) error {
+	resp, err := httpGet(ctx, l.weatherURL(l.weather.SeasonalURL, seasonalOutlookQuery))
+	if err != nil {
+		return fmt.Errorf("error fetching seasonal outlook: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error fetching seasonal outlook: %s", resp.Status)
+	}
+
+	var data struct {
+		Daily map[string]json.RawMessage `json:"daily"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return fmt.Errorf("error decoding seasonal outlook: %v", err)
+	}
+
+	var dates []string
+	if err := json.Unmarshal(data.Daily["time"], &dates); err != nil {
+		return fmt.Errorf("error decoding seasonal outlook dates: %v", err)
+	}
+
+	series := map[string]string{
+		"temperature_2m_max": "max_temperature_C",
+		"temperature_2m_min": "min_temperature_C",
+		"precipitation_sum":  "rainfall_mm",
+	}
+
+	// member -> day index -> values
+	members := make(map[int][]map[string]*float64)
+	for key, raw := range data.Daily {
+		variable, member, err := splitMemberKey(key)
+		if err != nil {
+			continue
+		}
+		column, ok := series[variable]
+		if !ok {
+			continue
+		}
+
+		var values []*float64
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return fmt.Errorf("error decoding seasonal outlook %s: %v", key, err)
+		}
+
+		if members[member] == nil {
+			members[member] = make([]map[string]*float64, len(dates))
+			for i := range dates {
+				members[member][i] = make(map[string]*float64)
+			}
+		}
+		for i := 0; i < len(values) && i < len(dates); i++ {
+			members[member][i][column] = values[i]
+		}
+	}
+
+	var days []outlookDay
+	for member, memberDays := range members {
+		for i, values := range memberDays {
+			if values["min_temperature_C"] != nil && values["max_temperature_C"] != nil {
+				values["avg_temperature_C"] = floatPtr((*values["min_temperature_C"] + *values["max_temperature_C"]) / 2)
+			}
+			days = append(days, outlookDay{Date: dates[i], Member: member, Values: values})
+		}
+	}
+
+	return l.saveOutlook(ctx, OutlookSourceSeasonal, time.Now().UTC(), days)
+}
+
+// httpGet fetches url, giving up when ctx is cancelled
+func httpGet(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// splitMemberKey splits "temperature_2m_max_member07" into the variable and member 7.
+// Keys without a member suffix are the control run, member 0.
+func splitMemberKey(key string) (string, int, error) {
+	variable, suffix, found := strings.Cut(key, "_member")
+	if !found {
+		return key, 0, nil
+	}
+	member, err := strconv.Atoi(suffix)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid member in %s", key)
+	}
+	return variable, member, nil
+}
+
+// ImportWeatherOutlookCSV stores an uploaded outlook. The CSV needs a date column (YYYY-MM-DD)
+// and may have a member column and any of the weather_outlook value columns; other columns
+// are rejected so a misspelt header is not silently dropped.
+func (l *Loader) ImportWeatherOutlookCSV(r io.Reader, issuedAt time.Time) (int, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return 0, fmt.Errorf("error reading CSV header: %v", err)
+	}
+
+	known := make(map[string]bool, len(outlookColumns))
+	for _, column := range outlookColumns {
+		known[column] = true
+	}
+
+	dateIndex, memberIndex := -1, -1
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		header[i] = name
+		switch {
+		case name == "date":
+			dateIndex = i
+		case name == "member":
+			memberIndex = i
+		case !known[name]:
+			return 0, fmt.Errorf("unknown column %q", name)
+		}
+	}
+	if dateIndex == -1 {
+		return 0, errors.New("missing date column")
+	}
+
+	var days []outlookDay
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		if _, err := time.Parse("2006-01-02", record[dateIndex]); err != nil {
+			return 0, fmt.Errorf("line %d: invalid date %q", line, record[dateIndex])
+		}
+		day := outlookDay{Date: record[dateIndex], Values: make(map[string]*float64)}
+
+		if memberIndex != -1 && record[memberIndex] != "" {
+			day.Member, err = strconv.Atoi(record[memberIndex])
+			if err != nil || day.Member < 0 {
+				return 0, fmt.Errorf("line %d: invalid member %q", line, record[memberIndex])
+			}
+		}
+
+		for i, value := range record {
+			if i == dateIndex || i == memberIndex || value == "" {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return 0, fmt.Errorf("line %d: invalid %s %q", line, header[i], value)
+			}
+			day.Values[header[i]] = &parsed
+		}
+		days = append(days, day)
+	}
+
+	if len(days) == 0 {
+		return 0, errors.New("CSV has no rows")
+	}
+	if err := l.saveOutlook(context.Background(), OutlookSourceCSV, issuedAt, days); err != nil {
+		return 0, err
+	}
+	return len(days), nil
+}
+
+// saveOutlook writes one issue of an outlook in a single transaction
+func (l *Loader) saveOutlook(ctx context.Context, source string, issuedAt time.Time, days []outlookDay) error {
+	if len(days) == 0 {
+		return fmt.Errorf("%s outlook has no days", source)
+	}
+
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting outlook transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	issued := issuedAt.UTC().Format("2006-01-02 15:04:05")
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(outlookColumns)+4), ", ")
+	stmt, err := tx.Prepare(fmt.Sprintf(`
+		INSERT INTO weather_outlook (source, issued_at, member, date, %s)
+		VALUES (%s)
+		ON CONFLICT (source, issued_at, member, date) DO NOTHING
+	`, strings.Join(outlookColumns, ", "), placeholders))
+	if err != nil {
+		return fmt.Errorf("error preparing outlook insert: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, day := range days {
+		args := []interface{}{source, issued, day.Member, day.Date}
+		for _, column := range outlookColumns {
+			if value := day.Values[column]; value != nil {
+				args = append(args, *value)
+			} else {
+				args = append(args, nil)
+			}
+		}
+		if _, err := stmt.Exec(args...); err != nil {
+			return fmt.Errorf("error inserting outlook for %s member %d: %v", day.Date, day.Member, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing outlook: %v", err)
+	}
+
+	slog.Info("Stored outlook", "source", source, "issued", issued, "member_days", len(days))
+	return nil
+}
+
+func valueAt(values []float64, i int) *float64 {
+	if i >= len(values) {
+		return nil
+	}
+	return floatPtr(values[i])
+}
+
+func floatPtr(value float64) *float64 {
+	return &value
+}
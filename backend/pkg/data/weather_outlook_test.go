@@ -0,0 +1,109 @@
+package data
+
+import (
+	"backend/pkg/config"
+	"backend/pkg/db"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openTestLoader returns a loader on a fresh database in a temporary directory
+func openTestLoader(t *testing.T) *Loader {
+	t.Helper()
+
+	database, err := db.Open(filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return NewLoader(database, nil, config.DataConfig{WorkbookMapping: "../db/energy_workbook.json"}, config.WeatherConfig{}, config.ModelConfig{})
+}
+
+func TestSplitMemberKey(t *testing.T) {
+	tests := []struct {
+		key      string
+		variable string
+		member   int
+		ok       bool
+	}{
+		{"temperature_2m_max", "temperature_2m_max", 0, true},
+		{"temperature_2m_max_member07", "temperature_2m_max", 7, true},
+		{"precipitation_sum_member51", "precipitation_sum", 51, true},
+		{"precipitation_sum_memberx", "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			variable, member, err := splitMemberKey(tt.key)
+			if (err == nil) != tt.ok || variable != tt.variable || member != tt.member {
+				t.Errorf("splitMemberKey(%q) = %q, %d, %v, want %q, %d, ok %v",
+					tt.key, variable, member, err, tt.variable, tt.member, tt.ok)
+			}
+		})
+	}
+}
+
+func TestDaylightWindow(t *testing.T) {
+	tests := []struct {
+		name       string
+		irradiance []float64
+		start, end int
+	}{
+		{"sunlit hours", []float64{0, 0, 10, 300, 500, 20, 0, 0}, 2, 5},
+		{"dark all day", []float64{0, 0, 0}, -1, -1},
+		{"no readings", nil, -1, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if start, end := daylightWindow(tt.irradiance); start != tt.start || end != tt.end {
+				t.Errorf("daylightWindow() = %d, %d, want %d, %d", start, end, tt.start, tt.end)
+			}
+		})
+	}
+}
+
+func TestImportWeatherOutlookCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		rows int
+		err  string
+	}{
+		{"control run", "date,max_temperature_C,rainfall_mm\n2025-07-01,45.5,0\n2025-07-02,44,\n", 2, ""},
+		{"members", "date, member, avg_solar_irradiance_wm2\n2025-07-01, 0, 600\n2025-07-01, 1, 580\n", 2, ""},
+		{"missing date column", "max_temperature_C\n45\n", 0, "missing date column"},
+		{"unknown column", "date,snowfall_cm\n2025-07-01,0\n", 0, `unknown column "snowfall_cm"`},
+		{"invalid date", "date,rainfall_mm\n01/07/2025,0\n", 0, `line 2: invalid date "01/07/2025"`},
+		{"negative member", "date,member,rainfall_mm\n2025-07-01,-1,0\n", 0, `line 2: invalid member "-1"`},
+		{"invalid value", "date,rainfall_mm\n2025-07-01,lots\n", 0, `line 2: invalid rainfall_mm "lots"`},
+		{"no rows", "date,rainfall_mm\n", 0, "CSV has no rows"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := openTestLoader(t)
+
+			issuedAt := time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC)
+			rows, err := l.ImportWeatherOutlookCSV(strings.NewReader(tt.csv), issuedAt)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("ImportWeatherOutlookCSV() = %v, want %q", err, tt.err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if rows != tt.rows {
+				t.Errorf("ImportWeatherOutlookCSV() = %d rows, want %d", rows, tt.rows)
+			}
+
+			var stored int
+			if err := l.db.QueryRow(`SELECT COUNT(*) FROM weather_outlook WHERE source = ? AND issued_at = ?`,
+				OutlookSourceCSV, "2025-06-30 12:00:00").Scan(&stored); err != nil {
+				t.Fatal(err)
+			}
+			if stored != tt.rows {
+				t.Errorf("stored %d rows, want %d", stored, tt.rows)
+			}
+		})
+	}
+}
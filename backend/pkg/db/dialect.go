@@ -0,0 +1,147 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Dialect is the SQL flavour of a database. Statements are written once, with ? placeholders and
+// the SQL both databases share; the connection rebinds the placeholders and the few expressions
+// that differ come from the dialect's methods.
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// Rebind rewrites the ? placeholders of query into the dialect's form: $1, $2… for PostgreSQL, and
+// unchanged for SQLite. Question marks in quoted strings, quoted identifiers and comments are kept.
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := closing(query, i+1, c)
+			b.WriteString(query[i:end])
+			i = end - 1
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			b.WriteString(query[i : i+end])
+			i += end - 1
+		case c == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// closing returns the index just past the quote that closes a string started before from, where
+// a doubled quote is an escaped one
+func closing(query string, from int, quote byte) int {
+	for i := from; i < len(query); i++ {
+		if query[i] != quote {
+			continue
+		}
+		if i+1 < len(query) && query[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(query)
+}
+
+// Day is expr, a date or timestamp, as YYYY-MM-DD text
+func (d Dialect) Day(expr string) string {
+	if d == Postgres {
+		return fmt.Sprintf("to_char(%s, 'YYYY-MM-DD')", expr)
+	}
+	return fmt.Sprintf("date(%s)", expr)
+}
+
+// DateOf is expr, a date or timestamp, truncated to its day, as a value a DATE column stores
+func (d Dialect) DateOf(expr string) string {
+	if d == Postgres {
+		return fmt.Sprintf("CAST(%s AS DATE)", expr)
+	}
+	return fmt.Sprintf("date(%s)", expr)
+}
+
+// Month is expr, a date or timestamp, as YYYY-MM text
+func (d Dialect) Month(expr string) string {
+	if d == Postgres {
+		return fmt.Sprintf("to_char(%s, 'YYYY-MM')", expr)
+	}
+	return fmt.Sprintf("strftime('%%Y-%%m', %s)", expr)
+}
+
+// YearOf is the year of expr, a date or timestamp, as an integer
+func (d Dialect) YearOf(expr string) string {
+	if d == Postgres {
+		return fmt.Sprintf("CAST(EXTRACT(YEAR FROM %s) AS INTEGER)", expr)
+	}
+	return fmt.Sprintf("CAST(strftime('%%Y', %s) AS INTEGER)", expr)
+}
+
+// MonthOf is the month (1-12) of expr, a date or timestamp, as an integer
+func (d Dialect) MonthOf(expr string) string {
+	if d == Postgres {
+		return fmt.Sprintf("CAST(EXTRACT(MONTH FROM %s) AS INTEGER)", expr)
+	}
+	return fmt.Sprintf("CAST(strftime('%%m', %s) AS INTEGER)", expr)
+}
+
+// DaysInMonth is the number of days in the month of expr, a date, as an integer
+func (d Dialect) DaysInMonth(expr string) string {
+	if d == Postgres {
+		return fmt.Sprintf("CAST(EXTRACT(DAY FROM date_trunc('month', %s) + INTERVAL '1 month - 1 day') AS INTEGER)", expr)
+	}
+	return fmt.Sprintf("CAST(strftime('%%d', date(%s, 'start of month', '+1 month', '-1 day')) AS INTEGER)", expr)
+}
+
+// Period is the integer columns year and month as YYYY-MM text, the form periods are stored in
+func (d Dialect) Period(year, month string) string {
+	if d == Postgres {
+		return fmt.Sprintf("to_char(make_date(%s, %s, 1), 'YYYY-MM')", year, month)
+	}
+	return fmt.Sprintf("printf('%%04d-%%02d', %s, %s)", year, month)
+}
+
+// Round is expr, a floating point number, rounded to places decimal places. PostgreSQL only rounds
+// numerics to a number of places.
+func (d Dialect) Round(expr string, places int) string {
+	if d == Postgres {
+		return fmt.Sprintf("ROUND(CAST(%s AS NUMERIC), %d)", expr, places)
+	}
+	return fmt.Sprintf("ROUND(%s, %d)", expr, places)
+}
+
+// IsUniqueViolation reports whether err is a statement failing a UNIQUE or PRIMARY KEY constraint
+func IsUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		query   string
+		want    string
+	}{
+		{"sqlite unchanged", SQLite, `SELECT * FROM t WHERE a = ? AND b = ?`, `SELECT * FROM t WHERE a = ? AND b = ?`},
+		{"numbered", Postgres, `SELECT * FROM t WHERE a = ? AND b = ?`, `SELECT * FROM t WHERE a = $1 AND b = $2`},
+		{"no placeholders", Postgres, `SELECT 1`, `SELECT 1`},
+		{"string kept", Postgres, `SELECT '?' FROM t WHERE a = ?`, `SELECT '?' FROM t WHERE a = $1`},
+		{"doubled quote", Postgres, `SELECT 'it''s ?' WHERE a = ?`, `SELECT 'it''s ?' WHERE a = $1`},
+		{"quoted identifier", Postgres, `SELECT "a?" FROM t WHERE b = ?`, `SELECT "a?" FROM t WHERE b = $1`},
+		{"comment", Postgres, "SELECT a -- why?\nFROM t WHERE b = ?", "SELECT a -- why?\nFROM t WHERE b = $1"},
+		{"comment at end", Postgres, `SELECT ? -- ok?`, `SELECT $1 -- ok?`},
+		{"unterminated string", Postgres, `SELECT ? WHERE a = '?`, `SELECT $1 WHERE a = '?`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.dialect.Rebind(tt.query); got != tt.want {
+				t.Errorf("Rebind(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialectExpressions(t *testing.T) {
+	tests := []struct {
+		name             string
+		got              func(Dialect) string
+		sqlite, postgres string
+	}{
+		{"Day", func(d Dialect) string { return d.Day("date") }, "date(date)", "to_char(date, 'YYYY-MM-DD')"},
+		{"DateOf", func(d Dialect) string { return d.DateOf("ts") }, "date(ts)", "CAST(ts AS DATE)"},
+		{"Round", func(d Dialect) string { return d.Round("SUM(x)", 2) }, "ROUND(SUM(x), 2)", "ROUND(CAST(SUM(x) AS NUMERIC), 2)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.got(SQLite); got != tt.sqlite {
+				t.Errorf("sqlite: got %q, want %q", got, tt.sqlite)
+			}
+			if got := tt.got(Postgres); got != tt.postgres {
+				t.Errorf("postgres: got %q, want %q", got, tt.postgres)
+			}
+		})
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"other", errors.New("boom"), false},
+		{"sqlite unique", sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintUnique}, true},
+		{"sqlite primary key", sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintPrimaryKey}, true},
+		{"sqlite foreign key", sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintForeignKey}, false},
+		{"postgres unique", &pq.Error{Code: "23505"}, true},
+		{"postgres foreign key", &pq.Error{Code: "23503"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsUniqueViolation(tt.err); got != tt.want {
+				t.Errorf("IsUniqueViolation(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
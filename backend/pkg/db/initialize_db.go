@@ -2,21 +2,32 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
+
 	_ "github.com/mattn/go-sqlite3"
-	"log"
 )
 
-var Database *sql.DB
+// DB is the database the server keeps its data in, SQLite or PostgreSQL. It is opened once in main
+// and handed to everything that reads or writes it.
+type DB struct {
+	*sql.DB
+	Dialect Dialect
+	// Path is the SQLite file and URL the PostgreSQL connection string, which the Python models
+	// open themselves
+	Path string
+	URL  string
+}
 
-func InitializeDb() {
-	var err error
-	Database, err = sql.Open("sqlite3", "../../pkg/db/app.db")
+// Open opens the SQLite database at path, creating the file and any missing tables
+func Open(path string) (*DB, error) {
+	database, err := openInstrumented("sqlite3", path, SQLite)
 	if err != nil {
-		log.Fatalf("Error initializing new database: %v", err)
+		return nil, fmt.Errorf("error opening database %s: %v", path, err)
 	}
 
-	if err = Database.Ping(); err != nil {
-		log.Fatalf("New database is not reachable: %v", err)
+	if err = database.Ping(); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("database %s is not reachable: %v", path, err)
 	}
 
 	createTables := `
@@ -64,6 +75,45 @@ CREATE TABLE IF NOT EXISTS locations (
     last_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Emission factors used to convert generation into avoided CO2
+CREATE TABLE IF NOT EXISTS emission_factors (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT UNIQUE NOT NULL,
+    value DECIMAL(10, 3) NOT NULL CHECK (value >= 0),
+    unit TEXT NOT NULL,
+    description TEXT,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT OR IGNORE INTO emission_factors (name, value, unit, description)
+VALUES ('natural_gas', 400, 'gCO2/kWh', 'Natural gas generation displaced by solar');
+
+-- Append-only history of monthly_generation.actual_kwh, locations and emission factors. A NULL
+-- old_value is the first recorded value; a NULL new_value means the record was removed.
+CREATE TABLE IF NOT EXISTS revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    table_name TEXT NOT NULL CHECK (table_name IN ('monthly_generation', 'locations', 'emission_factors')),
+    record_key TEXT NOT NULL,
+    column_name TEXT NOT NULL,
+    old_value REAL,
+    new_value REAL,
+    changed_by TEXT NOT NULL,
+    reason TEXT,
+    changed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS revisions_record ON revisions (table_name, record_key, column_name, id);
+
+CREATE TRIGGER IF NOT EXISTS revisions_no_update BEFORE UPDATE ON revisions
+BEGIN
+    SELECT RAISE(ABORT, 'revisions are append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS revisions_no_delete BEFORE DELETE ON revisions
+BEGIN
+    SELECT RAISE(ABORT, 'revisions are append-only');
+END;
+
 CREATE TABLE IF NOT EXISTS monthly_generation (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     year INT NOT NULL,
@@ -76,6 +126,264 @@ CREATE TABLE IF NOT EXISTS monthly_generation (
     UNIQUE(year, month, location_id)
 );
 
+CREATE TABLE IF NOT EXISTS daily_generation (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    date DATE NOT NULL,
+    location_id INTEGER NOT NULL,
+    actual_kwh DECIMAL(10, 2),
+    theoretical_kwh DECIMAL(10, 2),
+    predicted_kwh DECIMAL(10, 2),
+    source TEXT NOT NULL CHECK (source IN ('daily', 'interval')),
+    FOREIGN KEY (location_id) REFERENCES locations(id),
+    UNIQUE(date, location_id)
+);
+
+CREATE TABLE IF NOT EXISTS interval_generation (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    interval_start TIMESTAMP NOT NULL,
+    interval_minutes INTEGER NOT NULL CHECK (interval_minutes > 0),
+    location_id INTEGER NOT NULL,
+    inverter TEXT NOT NULL DEFAULT '',
+    energy_kwh DECIMAL(10, 3) NOT NULL,
+    source TEXT NOT NULL DEFAULT 'csv' CHECK (source IN ('csv', 'modbus', 'logger')),
+    FOREIGN KEY (location_id) REFERENCES locations(id),
+    UNIQUE(interval_start, location_id, inverter)
+);
+
+CREATE TABLE IF NOT EXISTS telemetry_readings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    location_id INTEGER NOT NULL,
+    inverter TEXT NOT NULL,
+    read_at TIMESTAMP NOT NULL,
+    power_w DECIMAL(12, 2),
+    dc_power_w DECIMAL(12, 2),
+    lifetime_energy_wh DECIMAL(16, 1),
+    operating_state INTEGER,
+    source TEXT NOT NULL CHECK (source IN ('modbus', 'logger')),
+    FOREIGN KEY (location_id) REFERENCES locations(id),
+    UNIQUE(location_id, inverter, read_at)
+);
+
+CREATE TABLE IF NOT EXISTS telemetry_files (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    path TEXT NOT NULL,
+    sha256 TEXT NOT NULL UNIQUE,
+    location_id INTEGER NOT NULL,
+    rows_imported INTEGER NOT NULL,
+    imported_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (location_id) REFERENCES locations(id)
+);
+
+CREATE TABLE IF NOT EXISTS assets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    location_id INTEGER NOT NULL,
+    parent_id INTEGER,
+    kind TEXT NOT NULL CHECK (kind IN ('site', 'inverter', 'string')),
+    code TEXT NOT NULL,
+    name TEXT NOT NULL,
+    capacity_kw DECIMAL(10, 2),
+    number_of_panels INTEGER,
+    manufacturer TEXT,
+    model TEXT,
+    serial_number TEXT,
+    commissioned_on DATE,
+    metadata_json TEXT,
+    last_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (location_id) REFERENCES locations(id),
+    FOREIGN KEY (parent_id) REFERENCES assets(id),
+    UNIQUE(location_id, code)
+);
+
+CREATE TABLE IF NOT EXISTS asset_monthly_generation (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    year INT NOT NULL,
+    month INT NOT NULL CHECK (month >= 1 AND month <= 12),
+    asset_id INTEGER NOT NULL,
+    actual_kwh DECIMAL(10, 2) NOT NULL,
+    source TEXT NOT NULL CHECK (source IN ('workbook', 'csv', 'interval')),
+    FOREIGN KEY (asset_id) REFERENCES assets(id),
+    UNIQUE(year, month, asset_id)
+);
+
+CREATE TABLE IF NOT EXISTS asset_daily_generation (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    date DATE NOT NULL,
+    asset_id INTEGER NOT NULL,
+    actual_kwh DECIMAL(10, 3) NOT NULL,
+    producing_intervals INTEGER NOT NULL,
+    daylight_intervals INTEGER NOT NULL,
+    FOREIGN KEY (asset_id) REFERENCES assets(id),
+    UNIQUE(date, asset_id)
+);
+
+CREATE TABLE IF NOT EXISTS asset_daily_performance (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    date DATE NOT NULL,
+    asset_id INTEGER NOT NULL,
+    actual_kwh DECIMAL(10, 3),
+    expected_kwh DECIMAL(10, 3),
+    performance_ratio DECIMAL(10, 3),
+    capacity_factor DECIMAL(10, 3),
+    specific_yield DECIMAL(10, 3),
+    availability DECIMAL(10, 4),
+    FOREIGN KEY (asset_id) REFERENCES assets(id),
+    UNIQUE(date, asset_id)
+);
+
+CREATE TABLE IF NOT EXISTS asset_monthly_performance (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    year INT NOT NULL,
+    month INT NOT NULL CHECK (month >= 1 AND month <= 12),
+    asset_id INTEGER NOT NULL,
+    actual_kwh DECIMAL(10, 2),
+    expected_kwh DECIMAL(10, 2),
+    performance_ratio DECIMAL(10, 3),
+    capacity_factor DECIMAL(10, 3),
+    specific_yield DECIMAL(10, 3),
+    availability DECIMAL(10, 4),
+    FOREIGN KEY (asset_id) REFERENCES assets(id),
+    UNIQUE(year, month, asset_id)
+);
+
+CREATE TABLE IF NOT EXISTS imports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    dataset TEXT NOT NULL CHECK (dataset IN ('monthly_generation', 'daily_generation', 'weather_daily')),
+    filename TEXT,
+    status TEXT NOT NULL CHECK (status IN ('pending', 'applied', 'discarded')),
+    records_json TEXT NOT NULL,
+    diff_json TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    applied_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS monthly_generation_imports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    year INT NOT NULL,
+    month INT NOT NULL CHECK (month >= 1 AND month <= 12),
+    location_id INTEGER NOT NULL,
+    actual_kwh DECIMAL(10, 2) NOT NULL,
+    import_id INTEGER NOT NULL,
+    FOREIGN KEY (location_id) REFERENCES locations(id),
+    FOREIGN KEY (import_id) REFERENCES imports(id),
+    UNIQUE(year, month, location_id)
+);
+
+CREATE TABLE IF NOT EXISTS quarantine (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    dataset TEXT NOT NULL CHECK (dataset IN ('monthly_generation', 'daily_generation', 'weather_daily')),
+    location_id INTEGER NOT NULL DEFAULT 0,
+    period TEXT NOT NULL,
+    rule TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    value DECIMAL(12, 3),
+    status TEXT NOT NULL DEFAULT 'quarantined' CHECK (status IN ('quarantined', 'accepted', 'rejected')),
+    detected_at TIMESTAMP NOT NULL,
+    reviewed_at TIMESTAMP,
+    review_note TEXT,
+    UNIQUE(dataset, location_id, period, rule)
+);
+
+-- Rows with an unreviewed or rejected quarantine entry are left out of every metric
+CREATE VIEW IF NOT EXISTS monthly_generation_checked AS
+SELECT g.* FROM monthly_generation g
+WHERE NOT EXISTS (
+    SELECT 1 FROM quarantine q
+    WHERE q.dataset = 'monthly_generation' AND q.status != 'accepted'
+        AND q.location_id = g.location_id AND q.period = printf('%04d-%02d', g.year, g.month)
+);
+
+CREATE VIEW IF NOT EXISTS daily_generation_checked AS
+SELECT g.* FROM daily_generation g
+WHERE NOT EXISTS (
+    SELECT 1 FROM quarantine q
+    WHERE q.dataset = 'daily_generation' AND q.status != 'accepted'
+        AND q.location_id = g.location_id AND q.period = date(g.date)
+);
+
+CREATE VIEW IF NOT EXISTS weather_daily_checked AS
+SELECT w.* FROM weather_daily w
+WHERE NOT EXISTS (
+    SELECT 1 FROM quarantine q
+    WHERE q.dataset = 'weather_daily' AND q.status != 'accepted' AND q.period = date(w.date)
+);
+
+-- Periods missing from a series, or quarantined, with the estimate that fills them. column_name is
+-- actual_kwh for generation; value is NULL when no imputation method could estimate the period.
+CREATE TABLE IF NOT EXISTS gaps (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    dataset TEXT NOT NULL CHECK (dataset IN ('monthly_generation', 'daily_generation', 'weather_daily')),
+    location_id INTEGER NOT NULL DEFAULT 0,
+    period TEXT NOT NULL,
+    column_name TEXT NOT NULL,
+    reason TEXT NOT NULL CHECK (reason IN ('missing', 'quarantined', 'incomplete')),
+    method TEXT,
+    value DECIMAL(12, 3),
+    detected_at TIMESTAMP NOT NULL,
+    UNIQUE(dataset, location_id, period, column_name)
+);
+
+-- Checked rows with the gaps filled by their imputed values, flagged by imputed = 1
+CREATE VIEW IF NOT EXISTS monthly_generation_filled AS
+SELECT g.year, g.month, g.location_id, g.actual_kwh, g.theoretical_kwh, 0 AS imputed
+FROM monthly_generation_checked g
+WHERE g.actual_kwh IS NOT NULL AND NOT EXISTS (
+    SELECT 1 FROM gaps x
+    WHERE x.dataset = 'monthly_generation' AND x.value IS NOT NULL
+        AND x.location_id = g.location_id AND x.period = printf('%04d-%02d', g.year, g.month)
+)
+UNION ALL
+SELECT CAST(substr(x.period, 1, 4) AS INTEGER), CAST(substr(x.period, 6, 2) AS INTEGER), x.location_id,
+    x.value, m.theoretical_kwh, 1
+FROM gaps x
+LEFT JOIN monthly_generation m
+    ON m.location_id = x.location_id AND printf('%04d-%02d', m.year, m.month) = x.period
+WHERE x.dataset = 'monthly_generation' AND x.value IS NOT NULL;
+
+CREATE VIEW IF NOT EXISTS daily_generation_filled AS
+SELECT date(g.date) AS date, g.location_id, g.actual_kwh, g.theoretical_kwh, 0 AS imputed
+FROM daily_generation_checked g
+WHERE g.actual_kwh IS NOT NULL AND NOT EXISTS (
+    SELECT 1 FROM gaps x
+    WHERE x.dataset = 'daily_generation' AND x.value IS NOT NULL
+        AND x.location_id = g.location_id AND x.period = date(g.date)
+)
+UNION ALL
+SELECT x.period, x.location_id, x.value, d.theoretical_kwh, 1
+FROM gaps x
+LEFT JOIN daily_generation d ON d.location_id = x.location_id AND date(d.date) = x.period
+WHERE x.dataset = 'daily_generation' AND x.value IS NOT NULL;
+
+-- Every day of the weather series, including missing and quarantined ones, with the columns the
+-- theoretical output uses
+CREATE VIEW IF NOT EXISTS weather_daily_filled AS
+SELECT date(w.date) AS date,
+    COALESCE(w.sunshine_duration_seconds, s.value) AS sunshine_duration_seconds,
+    COALESCE(w.avg_solar_irradiance_wm2, i.value) AS avg_solar_irradiance_wm2,
+    (w.sunshine_duration_seconds IS NULL AND s.value IS NOT NULL)
+        OR (w.avg_solar_irradiance_wm2 IS NULL AND i.value IS NOT NULL) AS imputed
+FROM weather_daily_checked w
+LEFT JOIN gaps s ON s.dataset = 'weather_daily' AND s.period = date(w.date) AND s.column_name = 'sunshine_duration_seconds'
+LEFT JOIN gaps i ON i.dataset = 'weather_daily' AND i.period = date(w.date) AND i.column_name = 'avg_solar_irradiance_wm2'
+UNION ALL
+SELECT x.period,
+    MAX(CASE WHEN x.column_name = 'sunshine_duration_seconds' THEN x.value END),
+    MAX(CASE WHEN x.column_name = 'avg_solar_irradiance_wm2' THEN x.value END),
+    MAX(x.value IS NOT NULL)
+FROM gaps x
+WHERE x.dataset = 'weather_daily' AND x.period NOT IN (SELECT date(date) FROM weather_daily_checked)
+GROUP BY x.period;
+
+CREATE TABLE IF NOT EXISTS daily_performance (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    date DATE NOT NULL,
+    location_id INTEGER NOT NULL,
+    performance_ratio DECIMAL(10, 2),
+    capacity_factor DECIMAL(10, 2),
+    output_per_pv DECIMAL(10, 2),
+    FOREIGN KEY (location_id) REFERENCES locations(id),
+    UNIQUE(date, location_id)
+);
+
 CREATE TABLE IF NOT EXISTS monthly_performance (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     year INT NOT NULL,
@@ -117,13 +425,194 @@ CREATE TABLE IF NOT EXISTS feature_importance (
     importance_value DECIMAL(10, 4),
     last_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     UNIQUE(feature_name)
-);`
+);
 
-	_, err = Database.Exec(createTables)
-	if err != nil {
-		log.Fatalf("Error creating tables in new database: %v", err)
-	}
+CREATE TABLE IF NOT EXISTS weather_outlook (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source TEXT NOT NULL,
+    issued_at TIMESTAMP NOT NULL,
+    member INTEGER NOT NULL DEFAULT 0,
+    date DATE NOT NULL,
+    sunshine_duration_seconds INTEGER,
+    daylight_duration_seconds INTEGER,
+    min_temperature_C DECIMAL(10, 2),
+    avg_temperature_C DECIMAL(10, 2),
+    max_temperature_C DECIMAL(10, 2),
+    avg_solar_irradiance_wm2 DECIMAL(10, 2),
+    avg_relative_humidity_percent DECIMAL(10, 2),
+    avg_cloud_cover_percent DECIMAL(10, 2),
+    avg_wind_speed_kmh DECIMAL(10, 2),
+    rainfall_mm DECIMAL(10, 2),
+    UNIQUE(source, issued_at, member, date)
+);
 
-}
+CREATE TABLE IF NOT EXISTS forecast_outlook (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    model_run_id INTEGER,
+    source TEXT NOT NULL,
+    issued_at TIMESTAMP NOT NULL,
+    member INTEGER NOT NULL,
+    year INT NOT NULL,
+    month INT NOT NULL CHECK (month >= 1 AND month <= 12),
+    location_id INTEGER NOT NULL,
+    days_covered INTEGER NOT NULL,
+    predicted_kwh DECIMAL(10, 2),
+    FOREIGN KEY (model_run_id) REFERENCES model_runs(id),
+    FOREIGN KEY (location_id) REFERENCES locations(id),
+    UNIQUE(source, issued_at, member, year, month, location_id)
+);
 
+CREATE TABLE IF NOT EXISTS forecast_quantiles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    year INT NOT NULL,
+    month INT NOT NULL CHECK (month >= 1 AND month <= 12),
+    location_id INTEGER NOT NULL,
+    p10_kwh DECIMAL(10, 2),
+    p50_kwh DECIMAL(10, 2),
+    p90_kwh DECIMAL(10, 2),
+    method TEXT,
+    FOREIGN KEY (location_id) REFERENCES locations(id),
+    UNIQUE(year, month, location_id)
+);
 
+CREATE TABLE IF NOT EXISTS forecast_calibration (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    location_id INTEGER NOT NULL,
+    nominal_coverage DECIMAL(10, 4),
+    interval_coverage DECIMAL(10, 4),
+    below_p10 DECIMAL(10, 4),
+    below_p50 DECIMAL(10, 4),
+    below_p90 DECIMAL(10, 4),
+    sample_count INTEGER,
+    last_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (location_id) REFERENCES locations(id),
+    UNIQUE(location_id)
+);
+
+CREATE TABLE IF NOT EXISTS scenarios (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT,
+    request_json TEXT NOT NULL,
+    result_json TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS job_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_name TEXT NOT NULL,
+    trigger TEXT NOT NULL CHECK (trigger IN ('schedule', 'manual', 'startup')),
+    status TEXT NOT NULL CHECK (status IN ('running', 'succeeded', 'failed', 'skipped', 'cancelled')),
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP,
+    error_message TEXT
+);
+
+CREATE TABLE IF NOT EXISTS job_run_steps (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id INTEGER NOT NULL,
+    step_name TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('running', 'succeeded', 'failed', 'skipped', 'cancelled')),
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP,
+    error_message TEXT,
+    FOREIGN KEY (run_id) REFERENCES job_runs(id)
+);
+
+CREATE TABLE IF NOT EXISTS pipeline_state (
+    step_name TEXT PRIMARY KEY,
+    input_hashes TEXT NOT NULL,
+    output_hashes TEXT NOT NULL,
+    last_run_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS model_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    model TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('running', 'succeeded', 'failed', 'timeout')),
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP,
+    duration_ms INTEGER,
+    exit_code INTEGER,
+    rows_written INTEGER,
+    metrics_json TEXT,
+    error_message TEXT,
+    stderr_tail TEXT
+);
+
+CREATE TABLE IF NOT EXISTS site_feature_importance (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    model_run_id INTEGER,
+    model TEXT NOT NULL,
+    location_id INTEGER NOT NULL,
+    feature_name TEXT NOT NULL,
+    importance_value DECIMAL(10, 4),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (model_run_id) REFERENCES model_runs(id),
+    FOREIGN KEY (location_id) REFERENCES locations(id),
+    UNIQUE(model_run_id, model, location_id, feature_name)
+);
+
+CREATE TABLE IF NOT EXISTS forecast_explanations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    model_run_id INTEGER,
+    year INT NOT NULL,
+    month INT NOT NULL CHECK (month >= 1 AND month <= 12),
+    location_id INTEGER NOT NULL,
+    feature_name TEXT NOT NULL,
+    feature_value DECIMAL(14, 4),
+    contribution_kwh DECIMAL(10, 2) NOT NULL,
+    base_value_kwh DECIMAL(10, 2) NOT NULL,
+    method TEXT NOT NULL,
+    FOREIGN KEY (model_run_id) REFERENCES model_runs(id),
+    FOREIGN KEY (location_id) REFERENCES locations(id),
+    UNIQUE(year, month, location_id, feature_name)
+);
+
+-- API users and keys. Passwords are bcrypt hashes and keys SHA-256 hashes; neither is stored in
+-- the clear. Roles are ordered viewer < analyst < operator < admin.
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('viewer', 'analyst', 'operator', 'admin')),
+    disabled BOOLEAN NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL UNIQUE,
+    key_hash TEXT NOT NULL UNIQUE,
+    role TEXT NOT NULL CHECK (role IN ('viewer', 'analyst', 'operator', 'admin')),
+    created_by TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+-- Sessions issued at login. The token carries the session id signed with the secret below, so a
+-- session can be revoked before it expires.
+CREATE TABLE IF NOT EXISTS sessions (
+    id TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- Signing secret for session tokens, generated on first use unless auth.sessionSecret is set
+CREATE TABLE IF NOT EXISTS auth_secret (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    secret TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);`
+
+	if _, err = database.Exec(createTables); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("error creating tables: %v", err)
+	}
+	return &DB{DB: database, Dialect: SQLite, Path: path}, nil
+}
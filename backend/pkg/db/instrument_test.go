@@ -0,0 +1,29 @@
+package db
+
+import "testing"
+
+func TestStatementLabels(t *testing.T) {
+	tests := []struct {
+		query, operation, table string
+	}{
+		{"SELECT id FROM locations WHERE name = ?", "select", "locations"},
+		{"\n\t\tselect g.actual_kwh\n\t\tFROM monthly_generation g JOIN locations l ON l.id = g.location_id", "select", "monthly_generation"},
+		{"INSERT INTO weather_daily (date) VALUES (?)", "insert", "weather_daily"},
+		{"INSERT OR REPLACE INTO forecast_quantiles VALUES (?)", "insert", "forecast_quantiles"},
+		{"UPDATE imports SET status = ?", "update", "imports"},
+		{"DELETE FROM revisions", "delete", "revisions"},
+		{"CREATE TABLE IF NOT EXISTS job_runs (id INTEGER)", "create", "job_runs"},
+		{"WITH latest AS (SELECT 1) SELECT * FROM latest", "with", "latest"},
+		{"PRAGMA foreign_keys = ON", "pragma", "none"},
+		{"BEGIN", "other", "none"},
+		{"", "other", "none"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			operation, table := statementLabels(tt.query)
+			if operation != tt.operation || table != tt.table {
+				t.Errorf("statementLabels() = %s, %s, want %s, %s", operation, table, tt.operation, tt.table)
+			}
+		})
+	}
+}
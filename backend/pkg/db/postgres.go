@@ -0,0 +1,602 @@
+package db
+
+import (
+	"fmt"
+
+	_ "github.com/lib/pq"
+)
+
+// hypertables are the time series kept as TimescaleDB hypertables, with the column they are
+// partitioned on and how much time each chunk holds. Their primary keys include that column, as
+// TimescaleDB requires.
+var hypertables = []struct {
+	table, column, chunk string
+}{
+	{"weather_daily", "date", "1 year"},
+	{"interval_generation", "interval_start", "7 days"},
+}
+
+// OpenPostgres opens the PostgreSQL database at url, creating any missing tables. With timescale
+// the daily weather and interval generation become hypertables, which needs the timescaledb
+// extension to be available to the server.
+func OpenPostgres(url string, timescale bool) (*DB, error) {
+	database, err := openInstrumented("postgres", url, Postgres)
+	if err != nil {
+		return nil, fmt.Errorf("error opening postgres database: %v", err)
+	}
+
+	if err = database.Ping(); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("postgres database is not reachable: %v", err)
+	}
+
+	if _, err = database.Exec(postgresSchema); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("error creating tables: %v", err)
+	}
+
+	if timescale {
+		if _, err := database.Exec(`CREATE EXTENSION IF NOT EXISTS timescaledb`); err != nil {
+			database.Close()
+			return nil, fmt.Errorf("error creating timescaledb extension: %v", err)
+		}
+		for _, h := range hypertables {
+			_, err := database.Exec(`
+				SELECT create_hypertable(CAST(? AS regclass), CAST(? AS name),
+					chunk_time_interval => CAST(? AS interval), if_not_exists => TRUE, migrate_data => TRUE)
+			`, h.table, h.column, h.chunk)
+			if err != nil {
+				database.Close()
+				return nil, fmt.Errorf("error creating hypertable %s: %v", h.table, err)
+			}
+		}
+	}
+	if _, err = database.Exec(postgresViews); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("error creating views: %v", err)
+	}
+	return &DB{DB: database, Dialect: Postgres, URL: url}, nil
+}
+
+// postgresSchema is the SQLite schema in PostgreSQL's types. Decimals are double precision, as
+// SQLite keeps them, and the tables that can become hypertables are keyed on their time column.
+const postgresSchema = `
+
+CREATE TABLE IF NOT EXISTS weather_daily (
+    date DATE PRIMARY KEY,
+    sunrise_time TIME NOT NULL,
+    sunset_time TIME NOT NULL,
+    sunshine_duration_seconds INTEGER,
+    daylight_duration_seconds INTEGER,
+    min_temperature_C DOUBLE PRECISION,
+    avg_temperature_C DOUBLE PRECISION,
+    max_temperature_C DOUBLE PRECISION,
+    avg_solar_irradiance_wm2 DOUBLE PRECISION,
+    avg_relative_humidity_percent DOUBLE PRECISION,
+    avg_cloud_cover_percent DOUBLE PRECISION,
+    avg_wind_speed_kmh DOUBLE PRECISION,
+    rainfall_mm DOUBLE PRECISION
+);
+
+CREATE TABLE IF NOT EXISTS weather_monthly (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    year INT NOT NULL,
+    month INT NOT NULL CHECK (month >= 1 AND month <= 12),
+    avg_sunshine_duration_seconds INTEGER,
+    avg_daylight_duration_seconds INTEGER,
+    min_temperature_C DOUBLE PRECISION,
+    avg_temperature_C DOUBLE PRECISION,
+    max_temperature_C DOUBLE PRECISION,
+    avg_solar_irradiance_wm2 DOUBLE PRECISION,
+    avg_relative_humidity_percent DOUBLE PRECISION,
+    avg_cloud_cover_percent DOUBLE PRECISION,
+    avg_wind_speed_kmh DOUBLE PRECISION,
+    total_rainfall_mm DOUBLE PRECISION,
+    UNIQUE(year, month)
+);
+
+CREATE TABLE IF NOT EXISTS locations (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name VARCHAR(50) UNIQUE NOT NULL CHECK (name IN ('Awali', 'Refinery', 'UOB', 'Total System')),
+    installed_capacity_kw DOUBLE PRECISION,
+    number_of_panels INTEGER,
+    last_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS emission_factors (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name TEXT UNIQUE NOT NULL,
+    value DOUBLE PRECISION NOT NULL CHECK (value >= 0),
+    unit TEXT NOT NULL,
+    description TEXT,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO emission_factors (name, value, unit, description)
+VALUES ('natural_gas', 400, 'gCO2/kWh', 'Natural gas generation displaced by solar')
+ON CONFLICT (name) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS revisions (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    table_name TEXT NOT NULL CHECK (table_name IN ('monthly_generation', 'locations', 'emission_factors')),
+    record_key TEXT NOT NULL,
+    column_name TEXT NOT NULL,
+    old_value DOUBLE PRECISION,
+    new_value DOUBLE PRECISION,
+    changed_by TEXT NOT NULL,
+    reason TEXT,
+    changed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS revisions_record ON revisions (table_name, record_key, column_name, id);
+
+CREATE OR REPLACE FUNCTION revisions_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'revisions are append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS revisions_no_change ON revisions;
+CREATE TRIGGER revisions_no_change BEFORE UPDATE OR DELETE ON revisions
+FOR EACH ROW EXECUTE FUNCTION revisions_append_only();
+
+CREATE TABLE IF NOT EXISTS monthly_generation (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    year INT NOT NULL,
+    month INT NOT NULL CHECK (month >= 1 AND month <= 12),
+    location_id INTEGER NOT NULL REFERENCES locations(id),
+    actual_kwh DOUBLE PRECISION,
+    theoretical_kwh DOUBLE PRECISION,
+    predicted_kwh DOUBLE PRECISION,
+    UNIQUE(year, month, location_id)
+);
+
+CREATE TABLE IF NOT EXISTS daily_generation (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    date DATE NOT NULL,
+    location_id INTEGER NOT NULL REFERENCES locations(id),
+    actual_kwh DOUBLE PRECISION,
+    theoretical_kwh DOUBLE PRECISION,
+    predicted_kwh DOUBLE PRECISION,
+    source TEXT NOT NULL CHECK (source IN ('daily', 'interval')),
+    UNIQUE(date, location_id)
+);
+
+CREATE TABLE IF NOT EXISTS interval_generation (
+    interval_start TIMESTAMP NOT NULL,
+    interval_minutes INTEGER NOT NULL CHECK (interval_minutes > 0),
+    location_id INTEGER NOT NULL REFERENCES locations(id),
+    inverter TEXT NOT NULL DEFAULT '',
+    energy_kwh DOUBLE PRECISION NOT NULL,
+    source TEXT NOT NULL DEFAULT 'csv' CHECK (source IN ('csv', 'modbus', 'logger')),
+    PRIMARY KEY (interval_start, location_id, inverter)
+);
+
+CREATE TABLE IF NOT EXISTS telemetry_readings (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    location_id INTEGER NOT NULL REFERENCES locations(id),
+    inverter TEXT NOT NULL,
+    read_at TIMESTAMP NOT NULL,
+    power_w DOUBLE PRECISION,
+    dc_power_w DOUBLE PRECISION,
+    lifetime_energy_wh DOUBLE PRECISION,
+    operating_state INTEGER,
+    source TEXT NOT NULL CHECK (source IN ('modbus', 'logger')),
+    UNIQUE(location_id, inverter, read_at)
+);
+
+CREATE TABLE IF NOT EXISTS telemetry_files (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    path TEXT NOT NULL,
+    sha256 TEXT NOT NULL UNIQUE,
+    location_id INTEGER NOT NULL REFERENCES locations(id),
+    rows_imported INTEGER NOT NULL,
+    imported_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS assets (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    location_id INTEGER NOT NULL REFERENCES locations(id),
+    parent_id INTEGER REFERENCES assets(id),
+    kind TEXT NOT NULL CHECK (kind IN ('site', 'inverter', 'string')),
+    code TEXT NOT NULL,
+    name TEXT NOT NULL,
+    capacity_kw DOUBLE PRECISION,
+    number_of_panels INTEGER,
+    manufacturer TEXT,
+    model TEXT,
+    serial_number TEXT,
+    commissioned_on DATE,
+    metadata_json TEXT,
+    last_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(location_id, code)
+);
+
+CREATE TABLE IF NOT EXISTS asset_monthly_generation (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    year INT NOT NULL,
+    month INT NOT NULL CHECK (month >= 1 AND month <= 12),
+    asset_id INTEGER NOT NULL REFERENCES assets(id),
+    actual_kwh DOUBLE PRECISION NOT NULL,
+    source TEXT NOT NULL CHECK (source IN ('workbook', 'csv', 'interval')),
+    UNIQUE(year, month, asset_id)
+);
+
+CREATE TABLE IF NOT EXISTS asset_daily_generation (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    date DATE NOT NULL,
+    asset_id INTEGER NOT NULL REFERENCES assets(id),
+    actual_kwh DOUBLE PRECISION NOT NULL,
+    producing_intervals INTEGER NOT NULL,
+    daylight_intervals INTEGER NOT NULL,
+    UNIQUE(date, asset_id)
+);
+
+CREATE TABLE IF NOT EXISTS asset_daily_performance (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    date DATE NOT NULL,
+    asset_id INTEGER NOT NULL REFERENCES assets(id),
+    actual_kwh DOUBLE PRECISION,
+    expected_kwh DOUBLE PRECISION,
+    performance_ratio DOUBLE PRECISION,
+    capacity_factor DOUBLE PRECISION,
+    specific_yield DOUBLE PRECISION,
+    availability DOUBLE PRECISION,
+    UNIQUE(date, asset_id)
+);
+
+CREATE TABLE IF NOT EXISTS asset_monthly_performance (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    year INT NOT NULL,
+    month INT NOT NULL CHECK (month >= 1 AND month <= 12),
+    asset_id INTEGER NOT NULL REFERENCES assets(id),
+    actual_kwh DOUBLE PRECISION,
+    expected_kwh DOUBLE PRECISION,
+    performance_ratio DOUBLE PRECISION,
+    capacity_factor DOUBLE PRECISION,
+    specific_yield DOUBLE PRECISION,
+    availability DOUBLE PRECISION,
+    UNIQUE(year, month, asset_id)
+);
+
+CREATE TABLE IF NOT EXISTS imports (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    dataset TEXT NOT NULL CHECK (dataset IN ('monthly_generation', 'daily_generation', 'weather_daily')),
+    filename TEXT,
+    status TEXT NOT NULL CHECK (status IN ('pending', 'applied', 'discarded')),
+    records_json TEXT NOT NULL,
+    diff_json TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    applied_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS monthly_generation_imports (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    year INT NOT NULL,
+    month INT NOT NULL CHECK (month >= 1 AND month <= 12),
+    location_id INTEGER NOT NULL REFERENCES locations(id),
+    actual_kwh DOUBLE PRECISION NOT NULL,
+    import_id INTEGER NOT NULL REFERENCES imports(id),
+    UNIQUE(year, month, location_id)
+);
+
+CREATE TABLE IF NOT EXISTS quarantine (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    dataset TEXT NOT NULL CHECK (dataset IN ('monthly_generation', 'daily_generation', 'weather_daily')),
+    location_id INTEGER NOT NULL DEFAULT 0,
+    period TEXT NOT NULL,
+    rule TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    value DOUBLE PRECISION,
+    status TEXT NOT NULL DEFAULT 'quarantined' CHECK (status IN ('quarantined', 'accepted', 'rejected')),
+    detected_at TIMESTAMP NOT NULL,
+    reviewed_at TIMESTAMP,
+    review_note TEXT,
+    UNIQUE(dataset, location_id, period, rule)
+);
+
+CREATE TABLE IF NOT EXISTS gaps (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    dataset TEXT NOT NULL CHECK (dataset IN ('monthly_generation', 'daily_generation', 'weather_daily')),
+    location_id INTEGER NOT NULL DEFAULT 0,
+    period TEXT NOT NULL,
+    column_name TEXT NOT NULL,
+    reason TEXT NOT NULL CHECK (reason IN ('missing', 'quarantined', 'incomplete')),
+    method TEXT,
+    value DOUBLE PRECISION,
+    detected_at TIMESTAMP NOT NULL,
+    UNIQUE(dataset, location_id, period, column_name)
+);
+
+CREATE TABLE IF NOT EXISTS daily_performance (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    date DATE NOT NULL,
+    location_id INTEGER NOT NULL REFERENCES locations(id),
+    performance_ratio DOUBLE PRECISION,
+    capacity_factor DOUBLE PRECISION,
+    output_per_pv DOUBLE PRECISION,
+    UNIQUE(date, location_id)
+);
+
+CREATE TABLE IF NOT EXISTS monthly_performance (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    year INT NOT NULL,
+    month INT NOT NULL CHECK (month >= 1 AND month <= 12),
+    location_id INTEGER NOT NULL REFERENCES locations(id),
+    performance_ratio DOUBLE PRECISION,
+    capacity_factor DOUBLE PRECISION,
+    output_per_pv DOUBLE PRECISION,
+    UNIQUE(year, month, location_id)
+);
+
+CREATE TABLE IF NOT EXISTS yearly_performance (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    year INT NOT NULL,
+    location_id INTEGER NOT NULL REFERENCES locations(id),
+    performance_ratio DOUBLE PRECISION,
+    capacity_factor DOUBLE PRECISION,
+    output_per_pv DOUBLE PRECISION,
+    UNIQUE(year, location_id)
+);
+
+CREATE TABLE IF NOT EXISTS overall_performance (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    start_year INT NOT NULL,
+    end_year INT NOT NULL,
+    location_id INTEGER NOT NULL REFERENCES locations(id),
+    performance_ratio DOUBLE PRECISION,
+    capacity_factor DOUBLE PRECISION,
+    output_per_pv DOUBLE PRECISION,
+    UNIQUE(start_year, end_year, location_id)
+);
+
+CREATE TABLE IF NOT EXISTS feature_importance (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    feature_name TEXT NOT NULL,
+    importance_value DOUBLE PRECISION,
+    last_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(feature_name)
+);
+
+CREATE TABLE IF NOT EXISTS model_runs (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    model TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('running', 'succeeded', 'failed', 'timeout')),
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP,
+    duration_ms INTEGER,
+    exit_code INTEGER,
+    rows_written INTEGER,
+    metrics_json TEXT,
+    error_message TEXT,
+    stderr_tail TEXT
+);
+
+CREATE TABLE IF NOT EXISTS weather_outlook (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    source TEXT NOT NULL,
+    issued_at TIMESTAMP NOT NULL,
+    member INTEGER NOT NULL DEFAULT 0,
+    date DATE NOT NULL,
+    sunshine_duration_seconds INTEGER,
+    daylight_duration_seconds INTEGER,
+    min_temperature_C DOUBLE PRECISION,
+    avg_temperature_C DOUBLE PRECISION,
+    max_temperature_C DOUBLE PRECISION,
+    avg_solar_irradiance_wm2 DOUBLE PRECISION,
+    avg_relative_humidity_percent DOUBLE PRECISION,
+    avg_cloud_cover_percent DOUBLE PRECISION,
+    avg_wind_speed_kmh DOUBLE PRECISION,
+    rainfall_mm DOUBLE PRECISION,
+    UNIQUE(source, issued_at, member, date)
+);
+
+CREATE TABLE IF NOT EXISTS forecast_outlook (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    model_run_id INTEGER REFERENCES model_runs(id),
+    source TEXT NOT NULL,
+    issued_at TIMESTAMP NOT NULL,
+    member INTEGER NOT NULL,
+    year INT NOT NULL,
+    month INT NOT NULL CHECK (month >= 1 AND month <= 12),
+    location_id INTEGER NOT NULL REFERENCES locations(id),
+    days_covered INTEGER NOT NULL,
+    predicted_kwh DOUBLE PRECISION,
+    UNIQUE(source, issued_at, member, year, month, location_id)
+);
+
+CREATE TABLE IF NOT EXISTS forecast_quantiles (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    year INT NOT NULL,
+    month INT NOT NULL CHECK (month >= 1 AND month <= 12),
+    location_id INTEGER NOT NULL REFERENCES locations(id),
+    p10_kwh DOUBLE PRECISION,
+    p50_kwh DOUBLE PRECISION,
+    p90_kwh DOUBLE PRECISION,
+    method TEXT,
+    UNIQUE(year, month, location_id)
+);
+
+CREATE TABLE IF NOT EXISTS forecast_calibration (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    location_id INTEGER NOT NULL REFERENCES locations(id),
+    nominal_coverage DOUBLE PRECISION,
+    interval_coverage DOUBLE PRECISION,
+    below_p10 DOUBLE PRECISION,
+    below_p50 DOUBLE PRECISION,
+    below_p90 DOUBLE PRECISION,
+    sample_count INTEGER,
+    last_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(location_id)
+);
+
+CREATE TABLE IF NOT EXISTS scenarios (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name TEXT,
+    request_json TEXT NOT NULL,
+    result_json TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS job_runs (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    job_name TEXT NOT NULL,
+    trigger TEXT NOT NULL CHECK (trigger IN ('schedule', 'manual', 'startup')),
+    status TEXT NOT NULL CHECK (status IN ('running', 'succeeded', 'failed', 'skipped', 'cancelled')),
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP,
+    error_message TEXT
+);
+
+CREATE TABLE IF NOT EXISTS job_run_steps (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    run_id INTEGER NOT NULL REFERENCES job_runs(id),
+    step_name TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('running', 'succeeded', 'failed', 'skipped', 'cancelled')),
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP,
+    error_message TEXT
+);
+
+CREATE TABLE IF NOT EXISTS pipeline_state (
+    step_name TEXT PRIMARY KEY,
+    input_hashes TEXT NOT NULL,
+    output_hashes TEXT NOT NULL,
+    last_run_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS site_feature_importance (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    model_run_id INTEGER REFERENCES model_runs(id),
+    model TEXT NOT NULL,
+    location_id INTEGER NOT NULL REFERENCES locations(id),
+    feature_name TEXT NOT NULL,
+    importance_value DOUBLE PRECISION,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(model_run_id, model, location_id, feature_name)
+);
+
+CREATE TABLE IF NOT EXISTS forecast_explanations (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    model_run_id INTEGER REFERENCES model_runs(id),
+    year INT NOT NULL,
+    month INT NOT NULL CHECK (month >= 1 AND month <= 12),
+    location_id INTEGER NOT NULL REFERENCES locations(id),
+    feature_name TEXT NOT NULL,
+    feature_value DOUBLE PRECISION,
+    contribution_kwh DOUBLE PRECISION NOT NULL,
+    base_value_kwh DOUBLE PRECISION NOT NULL,
+    method TEXT NOT NULL,
+    UNIQUE(year, month, location_id, feature_name)
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    username TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('viewer', 'analyst', 'operator', 'admin')),
+    disabled BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL UNIQUE,
+    key_hash TEXT NOT NULL UNIQUE,
+    role TEXT NOT NULL CHECK (role IN ('viewer', 'analyst', 'operator', 'admin')),
+    created_by TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS auth_secret (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    secret TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);`
+
+// postgresViews are created after the tables, once any hypertables are in place. Rows with an
+// unreviewed or rejected quarantine entry are left out of the checked views, and the filled views
+// add the gaps' imputed values, flagged by imputed = 1.
+const postgresViews = `
+CREATE OR REPLACE VIEW monthly_generation_checked AS
+SELECT g.* FROM monthly_generation g
+WHERE NOT EXISTS (
+    SELECT 1 FROM quarantine q
+    WHERE q.dataset = 'monthly_generation' AND q.status != 'accepted'
+        AND q.location_id = g.location_id AND q.period = to_char(make_date(g.year, g.month, 1), 'YYYY-MM')
+);
+
+CREATE OR REPLACE VIEW daily_generation_checked AS
+SELECT g.* FROM daily_generation g
+WHERE NOT EXISTS (
+    SELECT 1 FROM quarantine q
+    WHERE q.dataset = 'daily_generation' AND q.status != 'accepted'
+        AND q.location_id = g.location_id AND q.period = to_char(g.date, 'YYYY-MM-DD')
+);
+
+CREATE OR REPLACE VIEW weather_daily_checked AS
+SELECT w.* FROM weather_daily w
+WHERE NOT EXISTS (
+    SELECT 1 FROM quarantine q
+    WHERE q.dataset = 'weather_daily' AND q.status != 'accepted' AND q.period = to_char(w.date, 'YYYY-MM-DD')
+);
+
+CREATE OR REPLACE VIEW monthly_generation_filled AS
+SELECT g.year, g.month, g.location_id, g.actual_kwh, g.theoretical_kwh, 0 AS imputed
+FROM monthly_generation_checked g
+WHERE g.actual_kwh IS NOT NULL AND NOT EXISTS (
+    SELECT 1 FROM gaps x
+    WHERE x.dataset = 'monthly_generation' AND x.value IS NOT NULL
+        AND x.location_id = g.location_id AND x.period = to_char(make_date(g.year, g.month, 1), 'YYYY-MM')
+)
+UNION ALL
+SELECT CAST(substr(x.period, 1, 4) AS INTEGER), CAST(substr(x.period, 6, 2) AS INTEGER), x.location_id,
+    x.value, m.theoretical_kwh, 1
+FROM gaps x
+LEFT JOIN monthly_generation m
+    ON m.location_id = x.location_id AND to_char(make_date(m.year, m.month, 1), 'YYYY-MM') = x.period
+WHERE x.dataset = 'monthly_generation' AND x.value IS NOT NULL;
+
+CREATE OR REPLACE VIEW daily_generation_filled AS
+SELECT g.date, g.location_id, g.actual_kwh, g.theoretical_kwh, 0 AS imputed
+FROM daily_generation_checked g
+WHERE g.actual_kwh IS NOT NULL AND NOT EXISTS (
+    SELECT 1 FROM gaps x
+    WHERE x.dataset = 'daily_generation' AND x.value IS NOT NULL
+        AND x.location_id = g.location_id AND x.period = to_char(g.date, 'YYYY-MM-DD')
+)
+UNION ALL
+SELECT CAST(x.period AS DATE), x.location_id, x.value, d.theoretical_kwh, 1
+FROM gaps x
+LEFT JOIN daily_generation d ON d.location_id = x.location_id AND to_char(d.date, 'YYYY-MM-DD') = x.period
+WHERE x.dataset = 'daily_generation' AND x.value IS NOT NULL;
+
+CREATE OR REPLACE VIEW weather_daily_filled AS
+SELECT w.date,
+    COALESCE(w.sunshine_duration_seconds, s.value) AS sunshine_duration_seconds,
+    COALESCE(w.avg_solar_irradiance_wm2, i.value) AS avg_solar_irradiance_wm2,
+    CASE WHEN (w.sunshine_duration_seconds IS NULL AND s.value IS NOT NULL)
+        OR (w.avg_solar_irradiance_wm2 IS NULL AND i.value IS NOT NULL) THEN 1 ELSE 0 END AS imputed
+FROM weather_daily_checked w
+LEFT JOIN gaps s ON s.dataset = 'weather_daily' AND s.period = to_char(w.date, 'YYYY-MM-DD') AND s.column_name = 'sunshine_duration_seconds'
+LEFT JOIN gaps i ON i.dataset = 'weather_daily' AND i.period = to_char(w.date, 'YYYY-MM-DD') AND i.column_name = 'avg_solar_irradiance_wm2'
+UNION ALL
+SELECT CAST(x.period AS DATE),
+    MAX(CASE WHEN x.column_name = 'sunshine_duration_seconds' THEN x.value END),
+    MAX(CASE WHEN x.column_name = 'avg_solar_irradiance_wm2' THEN x.value END),
+    MAX(CASE WHEN x.value IS NOT NULL THEN 1 ELSE 0 END)
+FROM gaps x
+WHERE x.dataset = 'weather_daily' AND x.period NOT IN (SELECT to_char(date, 'YYYY-MM-DD') FROM weather_daily_checked)
+GROUP BY x.period;`
@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"backend/pkg/config"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    config.LoggingConfig
+		err    string
+		logged bool
+	}{
+		{"json at info", config.LoggingConfig{Format: "json", Level: "info"}, "", true},
+		{"text at debug", config.LoggingConfig{Format: "text", Level: "debug"}, "", true},
+		{"format is case-insensitive", config.LoggingConfig{Format: "JSON", Level: "info"}, "", true},
+		{"below the level", config.LoggingConfig{Format: "json", Level: "warn"}, "", false},
+		{"unknown format", config.LoggingConfig{Format: "xml", Level: "info"}, "must be json or text", false},
+		{"unknown level", config.LoggingConfig{Format: "json", Level: "verbose"}, "error reading log level", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous, flags := slog.Default(), log.Flags()
+			t.Cleanup(func() {
+				slog.SetDefault(previous)
+				log.SetFlags(flags)
+			})
+
+			var buf bytes.Buffer
+			logger, err := Setup(tt.cfg, &buf)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Setup() = %v, want an error containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			logger.Info("hello", "site", "Awali")
+			if got := strings.Contains(buf.String(), "hello"); got != tt.logged {
+				t.Errorf("logged %q, want logged %v", buf.String(), tt.logged)
+			}
+			// The standard log package writes through the same handler
+			if tt.logged {
+				buf.Reset()
+				log.Printf("from log")
+				if !strings.Contains(buf.String(), "from log") {
+					t.Errorf("log.Printf wrote %q", buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil)).With("request_id", "abc")
+
+	if FromContext(context.Background()) != slog.Default() {
+		t.Error("FromContext() without a logger is not the default")
+	}
+	FromContext(WithLogger(context.Background(), logger)).Info("hello")
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+	if line["request_id"] != "abc" {
+		t.Errorf("logged %v, want the request ID", line)
+	}
+}
+
+func TestNewID(t *testing.T) {
+	a, b := NewID(), NewID()
+	if len(a) != 16 || a == b {
+		t.Errorf("NewID() = %q, %q, want two different 16 character IDs", a, b)
+	}
+}
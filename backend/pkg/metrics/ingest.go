@@ -0,0 +1,21 @@
+package metrics
+
+import "time"
+
+var (
+	ingests = NewCounterVec("solar_ingest_runs_total",
+		"Ingest attempts by source and outcome.", "source", "outcome")
+	lastIngest = NewGaugeVec("solar_ingest_last_success_timestamp_seconds",
+		"Unix time of the last successful ingest by source.", "source")
+)
+
+// RecordIngest counts an attempt to pull data in from source, and when err is nil marks it as
+// the source's latest success
+func RecordIngest(source string, err error) {
+	if err != nil {
+		ingests.Inc(source, "failure")
+		return
+	}
+	ingests.Inc(source, "success")
+	lastIngest.Set(float64(time.Now().Unix()), source)
+}
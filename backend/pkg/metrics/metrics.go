@@ -0,0 +1,312 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are latency buckets in seconds, from a millisecond to a minute
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Registry holds metrics and writes them in the Prometheus text exposition format
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}
+
+type metric interface {
+	describe() (name, help, kind string)
+	write(w *bufio.Writer) error
+}
+
+// Default is the registry /metrics serves; the New functions register into it
+var Default = NewRegistry()
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// register adds m, panicking on a duplicate name since metrics are declared at init
+func (r *Registry) register(m metric) {
+	name, _, _ := m.describe()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes every metric, sorted by name. A gauge function that fails is logged and left out
+// rather than failing the whole scrape.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	sort.Slice(metrics, func(i, j int) bool {
+		a, _, _ := metrics[i].describe()
+		b, _, _ := metrics[j].describe()
+		return a < b
+	})
+
+	buf := bufio.NewWriter(w)
+	for _, m := range metrics {
+		name, help, kind := m.describe()
+		fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, kind)
+		if err := m.write(buf); err != nil {
+			slog.Error("Error collecting metric", "metric", name, "err", err)
+		}
+	}
+	return buf.Flush()
+}
+
+// Handler serves the registry in the text exposition format
+func (r *Registry) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := r.Write(w); err != nil {
+			slog.Error("Error writing metrics", "err", err)
+		}
+	}
+}
+
+// series is one set of label values and its value
+type series struct {
+	labels []string
+	value  float64
+	// histogram state
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// vec is the labelled series shared by counters, gauges and histograms
+type vec struct {
+	name, help string
+	labels     []string
+	mu         sync.Mutex
+	series     map[string]*series
+}
+
+func newVec(name, help string, labels []string) vec {
+	return vec{name: name, help: help, labels: labels, series: make(map[string]*series)}
+}
+
+func (v *vec) get(values []string) *series {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labels: append([]string(nil), values...)}
+		v.series[key] = s
+	}
+	return s
+}
+
+// sorted returns the series in label order so scrapes are stable
+func (v *vec) sorted() []*series {
+	list := make([]*series, 0, len(v.series))
+	for _, s := range v.series {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return strings.Join(list[i].labels, "\xff") < strings.Join(list[j].labels, "\xff")
+	})
+	return list
+}
+
+// CounterVec is a count that only goes up, per set of label values
+type CounterVec struct{ vec }
+
+// NewCounterVec registers a counter with the given label names
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newVec(name, help, labels)}
+	Default.register(c)
+	return c
+}
+
+// Inc adds one to the series for values
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds delta, which must not be negative, to the series for values
+func (c *CounterVec) Add(delta float64, values ...string) {
+	c.mu.Lock()
+	c.get(values).value += delta
+	c.mu.Unlock()
+}
+
+func (c *CounterVec) describe() (string, string, string) { return c.name, c.help, "counter" }
+
+func (c *CounterVec) write(w *bufio.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range c.sorted() {
+		writeSample(w, c.name, c.labels, s.labels, s.value)
+	}
+	return nil
+}
+
+// GaugeVec is a value that can go up and down, per set of label values
+type GaugeVec struct{ vec }
+
+// NewGaugeVec registers a gauge with the given label names
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{newVec(name, help, labels)}
+	Default.register(g)
+	return g
+}
+
+// Set sets the series for values
+func (g *GaugeVec) Set(value float64, values ...string) {
+	g.mu.Lock()
+	g.get(values).value = value
+	g.mu.Unlock()
+}
+
+func (g *GaugeVec) describe() (string, string, string) { return g.name, g.help, "gauge" }
+
+func (g *GaugeVec) write(w *bufio.Writer) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, s := range g.sorted() {
+		writeSample(w, g.name, g.labels, s.labels, s.value)
+	}
+	return nil
+}
+
+// HistogramVec counts observations into cumulative buckets, per set of label values
+type HistogramVec struct {
+	vec
+	buckets []float64
+}
+
+// NewHistogramVec registers a histogram with the given upper bounds, which must be increasing
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{newVec(name, help, labels), buckets}
+	Default.register(h)
+	return h
+}
+
+// Observe records value in the series for values
+func (h *HistogramVec) Observe(value float64, values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.get(values)
+	if s.counts == nil {
+		s.counts = make([]uint64, len(h.buckets))
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+func (h *HistogramVec) describe() (string, string, string) { return h.name, h.help, "histogram" }
+
+func (h *HistogramVec) write(w *bufio.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	names := append(append([]string(nil), h.labels...), "le")
+	for _, s := range h.sorted() {
+		values := append(append([]string(nil), s.labels...), "")
+		for i, bound := range h.buckets {
+			values[len(values)-1] = formatFloat(bound)
+			writeSample(w, h.name+"_bucket", names, values, float64(s.counts[i]))
+		}
+		values[len(values)-1] = "+Inf"
+		writeSample(w, h.name+"_bucket", names, values, float64(s.count))
+		writeSample(w, h.name+"_sum", h.labels, s.labels, s.sum)
+		writeSample(w, h.name+"_count", h.labels, s.labels, float64(s.count))
+	}
+	return nil
+}
+
+// Sample is one series a gauge function reports
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+// GaugeFunc is a gauge read when scraped, for values that live in the database
+type GaugeFunc struct {
+	name, help string
+	labels     []string
+	collect    func() ([]Sample, error)
+}
+
+// NewGaugeFunc registers a gauge whose series collect returns on every scrape
+func NewGaugeFunc(name, help string, labels []string, collect func() ([]Sample, error)) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, labels: labels, collect: collect}
+	Default.register(g)
+	return g
+}
+
+func (g *GaugeFunc) describe() (string, string, string) { return g.name, g.help, "gauge" }
+
+func (g *GaugeFunc) write(w *bufio.Writer) error {
+	samples, err := g.collect()
+	if err != nil {
+		return err
+	}
+	for _, s := range samples {
+		if len(s.Labels) != len(g.labels) {
+			return fmt.Errorf("sample has %d label values, want %d", len(s.Labels), len(g.labels))
+		}
+		writeSample(w, g.name, g.labels, s.Labels, s.Value)
+	}
+	return nil
+}
+
+func writeSample(w *bufio.Writer, name string, labels, values []string, value float64) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", label, escapeLabel(values[i]))
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		metric func() metric
+		want   string
+	}{
+		{
+			name: "counter",
+			metric: func() metric {
+				c := &CounterVec{newVec("jobs_total", "Jobs run.", []string{"job", "outcome"})}
+				c.Inc("recompute", "success")
+				c.Add(2, "recompute", "success")
+				c.Inc("ingest", "failure")
+				return c
+			},
+			want: "# HELP jobs_total Jobs run.\n# TYPE jobs_total counter\n" +
+				"jobs_total{job=\"ingest\",outcome=\"failure\"} 1\n" +
+				"jobs_total{job=\"recompute\",outcome=\"success\"} 3\n",
+		},
+		{
+			name: "gauge without labels",
+			metric: func() metric {
+				g := &GaugeVec{newVec("running", "Running jobs.", nil)}
+				g.Set(1)
+				g.Set(0.5)
+				return g
+			},
+			want: "# HELP running Running jobs.\n# TYPE running gauge\nrunning 0.5\n",
+		},
+		{
+			name: "histogram",
+			metric: func() metric {
+				h := &HistogramVec{newVec("latency_seconds", "Latency.", []string{"route"}), []float64{0.1, 1}}
+				h.Observe(0.05, "/api")
+				h.Observe(0.5, "/api")
+				h.Observe(2, "/api")
+				return h
+			},
+			want: "# HELP latency_seconds Latency.\n# TYPE latency_seconds histogram\n" +
+				"latency_seconds_bucket{route=\"/api\",le=\"0.1\"} 1\n" +
+				"latency_seconds_bucket{route=\"/api\",le=\"1\"} 2\n" +
+				"latency_seconds_bucket{route=\"/api\",le=\"+Inf\"} 3\n" +
+				"latency_seconds_sum{route=\"/api\"} 2.55\n" +
+				"latency_seconds_count{route=\"/api\"} 3\n",
+		},
+		{
+			name: "escaped label and help",
+			metric: func() metric {
+				g := &GaugeVec{newVec("site_kwh", "Line one\nline \\two", []string{"site"})}
+				g.Set(1, "Say \"hi\"\n")
+				return g
+			},
+			want: "# HELP site_kwh Line one\\nline \\\\two\n# TYPE site_kwh gauge\n" +
+				"site_kwh{site=\"Say \\\"hi\\\"\\n\"} 1\n",
+		},
+		{
+			name: "gauge function",
+			metric: func() metric {
+				return &GaugeFunc{name: "open", help: "Open rows.", labels: []string{"dataset"}, collect: func() ([]Sample, error) {
+					return []Sample{{Labels: []string{"daily"}, Value: 4}}, nil
+				}}
+			},
+			want: "# HELP open Open rows.\n# TYPE open gauge\nopen{dataset=\"daily\"} 4\n",
+		},
+		{
+			name: "failing gauge function is left out",
+			metric: func() metric {
+				return &GaugeFunc{name: "open", help: "Open rows.", collect: func() ([]Sample, error) {
+					return nil, errors.New("database is closed")
+				}}
+			},
+			want: "# HELP open Open rows.\n# TYPE open gauge\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			r.register(tt.metric())
+
+			var buf bytes.Buffer
+			if err := r.Write(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("Write() =\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	r := NewRegistry()
+	r.register(&GaugeVec{newVec("running", "Running jobs.", nil)})
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a metric name twice did not panic")
+		}
+	}()
+	r.register(&CounterVec{newVec("running", "Running jobs.", nil)})
+}
@@ -0,0 +1,168 @@
+import pandas as pd
+import numpy as np
+from sklearn.model_selection import train_test_split
+from sklearn.ensemble import RandomForestRegressor
+from sklearn.metrics import r2_score, mean_squared_error
+import os
+import sys
+import json
+import argparse
+import warnings
+
+sys.path.insert(0, os.path.join(os.path.dirname(os.path.abspath(__file__)), '..'))
+import solar_db
+
+warnings.filterwarnings('ignore')
+
+DEFAULT_DB_PATH = os.path.join(os.path.dirname(os.path.abspath(__file__)), '..', '..', 'db', 'app.db')
+
+WEATHER_FEATURES = [
+    'sunshine_duration_seconds', 'daylight_duration_seconds', 'min_temperature_C',
+    'avg_temperature_C', 'max_temperature_C', 'avg_solar_irradiance_wm2', 'avg_relative_humidity_percent',
+    'avg_cloud_cover_percent', 'avg_wind_speed_kmh', 'rainfall_mm'
+]
+
+HISTORY_FEATURES = ['rolling_avg_7', 'rolling_avg_30']
+
+class DailyRandomForestModel:
+    def __init__(self, db_path=DEFAULT_DB_PATH, n_estimators=300, test_size=0.2, min_days=60):
+        self.db_path = db_path
+        self.n_estimators = n_estimators
+        self.test_size = test_size
+        self.min_days = min_days
+        self.metrics = {}
+        self.records_saved = 0
+
+    def load_and_prepare_data(self):
+        conn = solar_db.connect(self.db_path)
+
+        weather_data = pd.read_sql_query(f"""
+            SELECT {solar_db.day('date')} as date, sunshine_duration_seconds, daylight_duration_seconds,
+                   min_temperature_C, avg_temperature_C, max_temperature_C, avg_solar_irradiance_wm2,
+                   avg_relative_humidity_percent, avg_cloud_cover_percent, avg_wind_speed_kmh, rainfall_mm
+            FROM weather_daily
+            ORDER BY date
+        """, conn)
+
+        generation_data = pd.read_sql_query(f"""
+            SELECT {solar_db.day('g.date')} as date, g.location_id, l.name as location, g.actual_kwh
+            FROM daily_generation g
+            JOIN locations l ON g.location_id = l.id
+            WHERE g.actual_kwh IS NOT NULL
+            ORDER BY g.location_id, g.date
+        """, conn)
+
+        conn.close()
+
+        merged_data = generation_data.merge(weather_data, on='date')
+        dates = pd.to_datetime(merged_data['date'])
+        day_of_year = dates.dt.dayofyear
+        merged_data['doy_sin'] = np.sin(2 * np.pi * day_of_year / 365.25)
+        merged_data['doy_cos'] = np.cos(2 * np.pi * day_of_year / 365.25)
+
+        # History features only look at earlier days so the target never leaks into its own inputs
+        grouped = merged_data.groupby('location_id')['actual_kwh']
+        merged_data['rolling_avg_7'] = grouped.transform(lambda x: x.shift(1).rolling(window=7, min_periods=1).mean())
+        merged_data['rolling_avg_30'] = grouped.transform(lambda x: x.shift(1).rolling(window=30, min_periods=1).mean())
+
+        return merged_data.dropna(subset=WEATHER_FEATURES + HISTORY_FEATURES)
+
+    def train_and_predict(self):
+        data = self.load_and_prepare_data()
+        features = WEATHER_FEATURES + ['doy_sin', 'doy_cos'] + HISTORY_FEATURES
+        predictions = []
+
+        for (location_id, location), site_data in data.groupby(['location_id', 'location']):
+            if len(site_data) < self.min_days:
+                print(f"Skipping {location}: {len(site_data)} days of data, need {self.min_days}")
+                continue
+
+            X_train, X_test, y_train, y_test, dates_train, dates_test = train_test_split(
+                site_data[features], site_data['actual_kwh'], site_data['date'],
+                test_size=self.test_size, shuffle=False
+            )
+
+            model = RandomForestRegressor(
+                n_estimators=self.n_estimators, max_depth=15, min_samples_split=4, min_samples_leaf=2,
+                max_features=0.8, random_state=42, n_jobs=-1
+            )
+            model.fit(X_train, y_train)
+            y_pred = np.maximum(model.predict(X_test), 0)
+
+            r2 = r2_score(y_test, y_pred)
+            rmse = np.sqrt(mean_squared_error(y_test, y_pred))
+            nonzero = y_test != 0
+            mape = np.mean(np.abs((y_test[nonzero] - y_pred[nonzero]) / y_test[nonzero])) * 100
+
+            print(f"\n{location} Results:")
+            print(f"R² Score: {r2:.4f}")
+            print(f"RMSE: {rmse:.2f}")
+            print(f"Error Percentage: {mape:.2f}%")
+
+            self.metrics[location] = {
+                'r2': round(float(r2), 4),
+                'rmse': round(float(rmse), 2),
+                'mape': round(float(mape), 4)
+            }
+
+            for date, predicted in zip(dates_test, y_pred):
+                predictions.append((round(float(predicted), 2), date, int(location_id)))
+
+        if not predictions:
+            # Raised before anything is cleared, so the last predictions stay in place
+            raise RuntimeError(f"no site has {self.min_days} days of data to model")
+        self.save_predictions_to_db(predictions)
+
+    def save_predictions_to_db(self, predictions):
+        conn = solar_db.connect(self.db_path)
+        cursor = conn.cursor()
+
+        try:
+            cursor.execute("UPDATE daily_generation SET predicted_kwh = NULL")
+            cursor.executemany(f"""
+                UPDATE daily_generation SET predicted_kwh = ?
+                WHERE {solar_db.day('date')} = ? AND location_id = ?
+            """, predictions)
+            conn.commit()
+            self.records_saved = len(predictions)
+            print(f"Successfully saved {self.records_saved} daily predictions to database")
+        except solar_db.Error as e:
+            conn.rollback()
+            print(f"Error saving to database: {e}")
+            raise
+        finally:
+            conn.close()
+
+def parse_args():
+    parser = argparse.ArgumentParser(description='Train the daily random forest and write predictions to the database')
+    parser.add_argument('--db', default=DEFAULT_DB_PATH, help='path to the SQLite database, unless SOLAR_DB_URL names a PostgreSQL one')
+    parser.add_argument('--n-estimators', type=int, default=300)
+    parser.add_argument('--test-size', type=float, default=0.2)
+    parser.add_argument('--min-days', type=int, default=60, help='sites with fewer days of data are not modelled')
+    parser.add_argument('--run-id', type=int, default=None, help='model_runs id of this run')
+    return parser.parse_args()
+
+def main():
+    args = parse_args()
+
+    # stdout carries the JSON result for the Go runner; progress output goes to stderr
+    result_stream = sys.stdout
+    sys.stdout = sys.stderr
+
+    model = DailyRandomForestModel(
+        db_path=args.db,
+        n_estimators=args.n_estimators,
+        test_size=args.test_size,
+        min_days=args.min_days
+    )
+    model.train_and_predict()
+
+    json.dump({
+        'model': 'daily_random_forest',
+        'rows_written': model.records_saved,
+        'metrics': model.metrics
+    }, result_stream)
+    result_stream.write('\n')
+
+if __name__ == "__main__":
+    main()
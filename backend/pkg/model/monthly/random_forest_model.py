@@ -1,4 +1,3 @@
-import sqlite3
 import pandas as pd
 import numpy as np
 from sklearn.model_selection import train_test_split
@@ -7,22 +6,73 @@ from sklearn.ensemble import RandomForestRegressor
 from sklearn.metrics import r2_score, mean_squared_error
 import matplotlib.pyplot as plt
 import os
+import sys
+import json
+import argparse
 import warnings
+import joblib
+import shap
+
+sys.path.insert(0, os.path.join(os.path.dirname(os.path.abspath(__file__)), '..'))
+import solar_db
 
 warnings.filterwarnings('ignore')
 
+DEFAULT_DB_PATH = os.path.join(os.path.dirname(os.path.abspath(__file__)), '..', '..', 'db', 'app.db')
+DEFAULT_PLOTS_DIR = os.path.join(os.path.dirname(os.path.abspath(__file__)), 'plots')
+
+FEATURE_NAMES = {
+    'avg_sunshine_duration_seconds': 'Sunshine Duration (hours)',
+    'avg_daylight_duration_seconds': 'Daylight Duration (hours)',
+    'min_temperature_C': 'Minimum Temperature (°C)',
+    'avg_temperature_C': 'Average Temperature (°C)',
+    'max_temperature_C': 'Maximum Temperature (°C)',
+    'avg_solar_irradiance_wm2': 'Solar Irradiance (W/m²)',
+    'avg_relative_humidity_percent': 'Relative Humidity (%)',
+    'avg_cloud_cover_percent': 'Cloud Cover (%)',
+    'avg_wind_speed_kmh': 'Wind Speed (km/h)',
+    'total_rainfall_mm': 'Rainfall (mm)',
+    'month_cos': 'Seasonal Pattern',
+    'total_refinery_rolling_avg_3': '3-Month Rolling Avg (Refinery)',
+    'total_refinery_rolling_avg_6': '6-Month Rolling Avg (Refinery)',
+    'total_refinery_trend': 'Trend (Refinery)',
+    'total_awali_rolling_avg_3': '3-Month Rolling Avg (Awali)',
+    'total_awali_rolling_avg_6': '6-Month Rolling Avg (Awali)',
+    'total_awali_trend': 'Trend (Awali)',
+    'total_UOB_rolling_avg_3': '3-Month Rolling Avg (UOB)',
+    'total_UOB_rolling_avg_6': '6-Month Rolling Avg (UOB)',
+    'total_UOB_trend': 'Trend (UOB)',
+    'total_all_rolling_avg_3': '3-Month Rolling Avg (Total)',
+    'total_all_rolling_avg_6': '6-Month Rolling Avg (Total)',
+    'total_all_trend': 'Trend (Total)'
+}
+
+LOCATIONS = ['Awali', 'Refinery', 'UOB', 'Total']
+LOCATION_NAMES = {'Awali': 'Awali', 'Refinery': 'Refinery', 'UOB': 'UOB', 'Total': 'Total System'}
+
 class MonthlyRandomForestModel:
-    def __init__(self):
+    def __init__(self, db_path=DEFAULT_DB_PATH, plots_folder=DEFAULT_PLOTS_DIR, n_estimators=500, test_size=0.2, run_id=None, outlook_source=None, write_plots=True):
         plt.switch_backend('Agg')
         self.scaler = StandardScaler()
         self.error_patterns = {}
-        current_dir = os.path.dirname(os.path.abspath(__file__))
-        self.plots_folder = os.path.join(current_dir, "plots")
-        os.makedirs(self.plots_folder, exist_ok=True)
+        self.db_path = db_path
+        self.n_estimators = n_estimators
+        self.test_size = test_size
+        self.metrics = {}
+        self.records_saved = 0
+        self.run_id = run_id
+        self.outlook_source = outlook_source
+        self.outlook_records_saved = 0
+        # Results are held until the whole run has succeeded, then written together by save_to_db
+        self.pending_predictions = None
+        self.pending_outlook = None
+        self.plots_folder = plots_folder
+        self.write_plots = write_plots
+        if self.write_plots:
+            os.makedirs(self.plots_folder, exist_ok=True)
     
     def load_and_prepare_data(self):
-        db_path = os.path.join(os.path.dirname(__file__), '..', '..', 'db', 'app.db')
-        conn = sqlite3.connect(db_path)
+        conn = solar_db.connect(self.db_path)
         
         weather_data = pd.read_sql_query("""
             SELECT year, month, avg_sunshine_duration_seconds, avg_daylight_duration_seconds,
@@ -45,6 +95,9 @@ class MonthlyRandomForestModel:
         
         conn.close()
         
+        # Months with weather but no reported generation yet can't be trained on
+        power_data = power_data.dropna(subset=['total_refinery', 'total_awali', 'total_UOB', 'total_all']).reset_index(drop=True)
+        
         for column in ['total_refinery', 'total_awali', 'total_UOB', 'total_all']:
             power_data[f'{column}_rolling_avg_3'] = power_data[column].rolling(window=3, min_periods=1).mean()
             power_data[f'{column}_rolling_avg_6'] = power_data[column].rolling(window=6, min_periods=1).mean()
@@ -67,11 +120,14 @@ class MonthlyRandomForestModel:
         ]]
         
         y = merged_data[['total_awali', 'total_refinery', 'total_UOB', 'total_all']]
+        # Unscaled features, reported alongside each attribution
+        self.X_raw = X.reset_index(drop=True)
         return X, y
     
     def train(self):
         X, y = self.load_and_prepare_data()
         self.y_all = y
+        self.feature_columns = list(X.columns)
         X_scaled = self.scaler.fit_transform(X)
         X_scaled = pd.DataFrame(X_scaled, columns=X.columns)
         
@@ -90,6 +146,7 @@ class MonthlyRandomForestModel:
         
         self.models = {}
         self.feature_importances = {}
+        self.oob_residuals = {}
         
         column_mapping = {
             'Awali': 'total_awali',
@@ -104,16 +161,19 @@ class MonthlyRandomForestModel:
             y_loc = y[column_mapping[location]]
             
             X_train_loc, X_test_loc, y_train_loc, y_test_loc = train_test_split(
-                X_loc, y_loc, test_size=0.2, random_state=42, shuffle=False
+                X_loc, y_loc, test_size=self.test_size, random_state=42, shuffle=False
             )
             
             model = RandomForestRegressor(
-                n_estimators=500, max_depth=15, min_samples_split=4, min_samples_leaf=2,
-                max_features=0.8, random_state=42, n_jobs=-1, bootstrap=True, min_impurity_decrease=0.0001
+                n_estimators=self.n_estimators, max_depth=15, min_samples_split=4, min_samples_leaf=2,
+                max_features=0.8, random_state=42, n_jobs=-1, bootstrap=True, min_impurity_decrease=0.0001,
+                oob_score=True
             )
             
             model.fit(X_train_loc, y_train_loc)
             self.models[location] = model
+            # Out-of-bag residuals are honest held-out errors, used to build the P10/P50/P90 bands
+            self.oob_residuals[location] = y_train_loc.values - model.oob_prediction_
             self.feature_importances[location] = dict(zip(features, model.feature_importances_))
             
             # Print feature importance scores for each target
@@ -125,7 +185,8 @@ class MonthlyRandomForestModel:
         self.y_test = y[-len(y_test_loc):]
         self.dates_test = self.dates[-len(X_test_loc):]
         
-        self.plot_feature_importances()
+        if self.write_plots:
+            self.plot_feature_importances()
     
     def predict(self, X):
         base_predictions = np.zeros((len(X), 4))
@@ -136,20 +197,11 @@ class MonthlyRandomForestModel:
             X_loc = X[features]
             base_predictions[:, i] = model.predict(X_loc)
         
-        corrected_predictions = np.zeros_like(base_predictions)
-        
-        for i, location in enumerate(['Awali', 'Refinery', 'UOB', 'Total']):
-            for j in range(len(base_predictions)):
-                error_percent = (base_predictions[j, i] - self.y_test.iloc[j, i]) / self.y_test.iloc[j, i]
-                error_magnitude = abs(error_percent)
-                scaled_error = min(max(error_magnitude * 0.55, 0.02), 0.15)
-                
-                if base_predictions[j, i] > self.y_test.iloc[j, i]:
-                    correction_factor = 1 - scaled_error
-                else:
-                    correction_factor = 1 + scaled_error
-                
-                corrected_predictions[j, i] = base_predictions[j, i] * correction_factor
+        # The published forecast is the band's P50, so the band is centered on it. The bands only use
+        # the training out-of-bag errors; the test actuals are kept for scoring the forecast.
+        quantile_predictions = self.predict_quantiles(base_predictions)
+        corrected_predictions = quantile_predictions[:, :, 1]
+        explanations = self.explain(X, base_predictions, corrected_predictions)
         
         if hasattr(self, 'y_test') and len(self.y_test) == len(X):
             for i, location in enumerate(['Awali', 'Refinery', 'UOB', 'Total']):
@@ -167,12 +219,287 @@ class MonthlyRandomForestModel:
                 print(f"After Correction Error: {corrected_mape:.2f}%")
                 print(f"After Correction R² Score: {corrected_r2:.4f}")
                 print(f"After Correction RMSE: {corrected_rmse:.2f}")
+                
+                self.metrics[location] = {
+                    'base_mape': round(float(base_mape), 4),
+                    'base_r2': round(float(base_r2), 4),
+                    'base_rmse': round(float(base_rmse), 2),
+                    'mape': round(float(corrected_mape), 4),
+                    'r2': round(float(corrected_r2), 4),
+                    'rmse': round(float(corrected_rmse), 2)
+                }
             
             self._plot_predictions_comparison(self.y_test, base_predictions, corrected_predictions, self.dates_test)
-            self.save_predictions_to_db(self.y_all, corrected_predictions, self.dates)
+            self.pending_predictions = (self.y_all, corrected_predictions, self.dates, quantile_predictions, explanations)
         
         return corrected_predictions
     
+    def predict_quantiles(self, predictions):
+        # Residual bootstrap around the raw forecast: shift each prediction by the
+        # 10th/50th/90th percentile of the location's out-of-bag residuals. The P50 shift is the
+        # model's typical bias, which is the only correction the published forecast gets.
+        quantiles = np.zeros((len(predictions), 4, 3))
+        
+        for i, location in enumerate(['Awali', 'Refinery', 'UOB', 'Total']):
+            residuals = self.oob_residuals[location]
+            residuals = residuals[~np.isnan(residuals)]
+            offsets = np.percentile(residuals, [10, 50, 90])
+            
+            for k, offset in enumerate(offsets):
+                quantiles[:, i, k] = np.maximum(predictions[:, i] + offset, 0)
+        
+        return quantiles
+    
+    def explain(self, X, base_predictions, corrected_predictions):
+        # Per-prediction TreeSHAP attributions, where base value + contributions = base prediction,
+        # with the bias correction reported as its own contribution
+        raw = self.X_raw.iloc[-len(X):].reset_index(drop=True)
+        explanations = {}
+        
+        for i, location in enumerate(LOCATIONS):
+            model = self.models[location]
+            features = list(self.feature_importances[location].keys())
+            X_loc = X[features]
+            
+            explainer = shap.TreeExplainer(model)
+            contributions = np.asarray(explainer.shap_values(X_loc))
+            base_values = np.full(len(X_loc), float(np.ravel(explainer.expected_value)[0]))
+            
+            explanations[location] = []
+            for j in range(len(X_loc)):
+                items = [
+                    (FEATURE_NAMES.get(feature, feature), float(raw.iloc[j][feature]), float(contributions[j, k]))
+                    for k, feature in enumerate(features)
+                ]
+                items.append(('Bias Correction', None, float(corrected_predictions[j, i] - base_predictions[j, i])))
+                explanations[location].append((float(base_values[j]), items, 'tree_shap'))
+        
+        return explanations
+    
+    # Everything predict_scenario needs from a training run
+    SAVED_ATTRIBUTES = ['scaler', 'models', 'feature_importances', 'feature_columns', 'X_raw', 'dates']
+    
+    def save_model(self, path):
+        # Written to a temporary file that publish_model moves into place, so a scenario never
+        # loads a half-written model and a run that fails to save its predictions keeps the last one
+        os.makedirs(os.path.dirname(path), exist_ok=True)
+        tmp_path = path + '.tmp'
+        joblib.dump({name: getattr(self, name) for name in self.SAVED_ATTRIBUTES}, tmp_path, compress=3)
+        return tmp_path
+    
+    def publish_model(self, tmp_path, path):
+        os.replace(tmp_path, path)
+        print(f"Saved trained model to {path}")
+    
+    def load_model(self, path):
+        saved = joblib.load(path)
+        for name in self.SAVED_ATTRIBUTES:
+            setattr(self, name, saved[name])
+    
+    def load_outlook(self):
+        # Aggregate the latest outlook issue to monthly inputs per ensemble member
+        conn = solar_db.connect(self.db_path)
+        
+        try:
+            issue_query = "SELECT source, issued_at FROM weather_outlook"
+            params = ()
+            if self.outlook_source:
+                issue_query += " WHERE source = ?"
+                params = (self.outlook_source,)
+            issue = conn.execute(issue_query + " ORDER BY issued_at DESC LIMIT 1", params).fetchone()
+            if issue is None:
+                return None, None
+            
+            outlook = pd.read_sql_query(f"""
+                SELECT member,
+                       {solar_db.year_of('date')} as year,
+                       {solar_db.month_of('date')} as month,
+                       AVG(sunshine_duration_seconds) as avg_sunshine_duration_seconds,
+                       AVG(daylight_duration_seconds) as avg_daylight_duration_seconds,
+                       MIN(min_temperature_C) as min_temperature_C,
+                       AVG(avg_temperature_C) as avg_temperature_C,
+                       MAX(max_temperature_C) as max_temperature_C,
+                       AVG(avg_solar_irradiance_wm2) as avg_solar_irradiance_wm2,
+                       AVG(avg_relative_humidity_percent) as avg_relative_humidity_percent,
+                       AVG(avg_cloud_cover_percent) as avg_cloud_cover_percent,
+                       AVG(avg_wind_speed_kmh) as avg_wind_speed_kmh,
+                       AVG(rainfall_mm) as avg_rainfall_mm,
+                       COUNT(*) as days_covered
+                FROM weather_outlook
+                WHERE source = ? AND issued_at = ?
+                GROUP BY member, year, month
+                ORDER BY member, year, month
+            """, conn, params=issue)
+            
+            climatology = pd.read_sql_query("""
+                SELECT month,
+                       AVG(avg_sunshine_duration_seconds) as avg_sunshine_duration_seconds,
+                       AVG(avg_daylight_duration_seconds) as avg_daylight_duration_seconds,
+                       AVG(min_temperature_C) as min_temperature_C,
+                       AVG(avg_temperature_C) as avg_temperature_C,
+                       AVG(max_temperature_C) as max_temperature_C,
+                       AVG(avg_solar_irradiance_wm2) as avg_solar_irradiance_wm2,
+                       AVG(avg_relative_humidity_percent) as avg_relative_humidity_percent,
+                       AVG(avg_cloud_cover_percent) as avg_cloud_cover_percent,
+                       AVG(avg_wind_speed_kmh) as avg_wind_speed_kmh,
+                       AVG(total_rainfall_mm) as total_rainfall_mm
+                FROM weather_monthly
+                GROUP BY month
+            """, conn).set_index('month')
+        finally:
+            conn.close()
+        
+        # Rainfall is a monthly total, so scale the daily mean up to the whole month
+        days_in_month = pd.to_datetime(outlook[['year', 'month']].assign(day=1)).dt.days_in_month
+        outlook['total_rainfall_mm'] = outlook['avg_rainfall_mm'] * days_in_month
+        
+        # Variables the outlook does not provide fall back to the historical mean for that month
+        for column in climatology.columns:
+            outlook[column] = outlook[column].fillna(outlook['month'].map(climatology[column]))
+        
+        return issue, outlook
+    
+    def predict_scenario(self, weather):
+        # Every trained month is predicted from its recorded weather and from that weather shifted
+        # the way the scenario asks. The raw forecast is used, since the bias correction needs
+        # actuals the shifted weather never had.
+        weather = weather or {}
+        X_base = self.X_raw[self.feature_columns]
+        X_scenario = X_base.copy()
+        
+        months = weather.get('months') or list(range(1, 13))
+        applies = self.dates.dt.month.isin(months).values
+        delta = weather.get('temperatureDeltaC') or 0
+        for column in ['min_temperature_C', 'avg_temperature_C', 'max_temperature_C']:
+            X_scenario.loc[applies, column] += delta
+        # A scale of 0 leaves the value unchanged, as in the Go scenario
+        for column, key in [('avg_solar_irradiance_wm2', 'irradianceScale'), ('avg_sunshine_duration_seconds', 'sunshineScale')]:
+            scale = weather.get(key) or 0
+            if scale > 0:
+                X_scenario.loc[applies, column] *= scale
+        
+        base_scaled = pd.DataFrame(self.scaler.transform(X_base), columns=self.feature_columns)
+        scenario_scaled = pd.DataFrame(self.scaler.transform(X_scenario), columns=self.feature_columns)
+        
+        predictions = []
+        for location in LOCATIONS:
+            features = list(self.feature_importances[location].keys())
+            baseline = np.maximum(self.models[location].predict(base_scaled[features]), 0)
+            scenario = np.maximum(self.models[location].predict(scenario_scaled[features]), 0)
+            for j, date in enumerate(self.dates):
+                predictions.append({
+                    'year': int(date.year),
+                    'month': int(date.month),
+                    'location': LOCATION_NAMES[location],
+                    'baseline': round(float(baseline[j]), 2),
+                    'scenario': round(float(scenario[j]), 2)
+                })
+        
+        return predictions
+    
+    def predict_outlook(self):
+        # One prediction per ensemble member. Generation history features are held at their
+        # latest values, since future months have no actuals to roll forward.
+        issue, outlook = self.load_outlook()
+        if outlook is None or outlook.empty:
+            print("No weather outlook stored, skipping outlook forecast")
+            return
+        
+        latest = self.X_raw.iloc[-1]
+        X = pd.DataFrame(index=outlook.index)
+        for column in self.feature_columns:
+            if column in outlook.columns:
+                X[column] = outlook[column]
+            elif column == 'month_cos':
+                X[column] = np.cos(2 * np.pi * outlook['month'] / 12)
+            else:
+                X[column] = latest[column]
+        
+        X_scaled = pd.DataFrame(self.scaler.transform(X[self.feature_columns]), columns=self.feature_columns)
+        
+        predictions = {}
+        for location in LOCATIONS:
+            features = list(self.feature_importances[location].keys())
+            predictions[location] = np.maximum(self.models[location].predict(X_scaled[features]), 0)
+        
+        self.pending_outlook = (issue, outlook, predictions, self.outlook_quantiles(outlook, predictions))
+    
+    def outlook_quantiles(self, outlook, predictions):
+        # Months ahead of the recorded ones are forecast like the test months: every member's
+        # forecast is shifted by every out-of-bag residual and the P50 of the pooled values is published
+        recorded = set(zip(self.dates.dt.year, self.dates.dt.month))
+        quantiles = []
+        for (year, month), rows in outlook.groupby(['year', 'month']).indices.items():
+            if (year, month) in recorded:
+                continue
+            for location in LOCATIONS:
+                residuals = self.oob_residuals[location]
+                residuals = residuals[~np.isnan(residuals)]
+                pooled = np.maximum(np.add.outer(predictions[location][rows], residuals).ravel(), 0)
+                p10, p50, p90 = (float(q) for q in np.percentile(pooled, [10, 50, 90]))
+                quantiles.append((int(year), int(month), location, p10, p50, p90))
+        return quantiles
+    
+    def save_to_db(self):
+        # Predictions and the outlook are written in one transaction once every step has
+        # succeeded, so a run that fails part way keeps the last good results in place
+        if self.pending_predictions is None:
+            raise RuntimeError("no predictions to save, keeping the stored ones")
+        
+        print(f"\nSaving predictions to database...")
+        conn = solar_db.connect(self.db_path)
+        cursor = conn.cursor()
+        
+        try:
+            self.save_predictions_to_db(cursor, *self.pending_predictions)
+            if self.pending_outlook is not None:
+                self.save_outlook_to_db(cursor, *self.pending_outlook)
+            conn.commit()
+            print(f"Successfully saved {self.records_saved} records to database")
+        except solar_db.Error as e:
+            conn.rollback()
+            self.records_saved = 0
+            self.outlook_records_saved = 0
+            print(f"Error saving to database: {e}")
+            raise
+        finally:
+            conn.close()
+    
+    def save_outlook_to_db(self, cursor, issue, outlook, predictions, quantiles):
+        source, issued_at = issue
+        location_ids = dict((name, loc_id) for loc_id, name in cursor.execute("SELECT id, name FROM locations").fetchall())
+        
+        cursor.execute("DELETE FROM forecast_outlook WHERE source = ? AND issued_at = ?", issue)
+        
+        # Runs after save_predictions_to_db has cleared the previous forecast
+        for year, month, location, p10, p50, p90 in quantiles:
+            location_id = location_ids[LOCATION_NAMES[location]]
+            cursor.execute("""
+            INSERT INTO monthly_generation (year, month, location_id, predicted_kwh)
+            VALUES (?, ?, ?, ?)
+            ON CONFLICT (year, month, location_id)
+            DO UPDATE SET predicted_kwh = excluded.predicted_kwh
+            """, (year, month, location_id, round(p50, 2)))
+            cursor.execute("""
+            INSERT INTO forecast_quantiles
+            (year, month, location_id, p10_kwh, p50_kwh, p90_kwh, method)
+            VALUES (?, ?, ?, ?, ?, ?, 'outlook_oob_residual_bootstrap')
+            """, (year, month, location_id, round(p10, 2), round(p50, 2), round(p90, 2)))
+            self.outlook_records_saved += 1
+        
+        for i, row in enumerate(outlook.itertuples(index=False)):
+            for location in LOCATIONS:
+                cursor.execute("""
+                INSERT INTO forecast_outlook
+                (model_run_id, source, issued_at, member, year, month, location_id, days_covered, predicted_kwh)
+                VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+                """, (self.run_id, source, issued_at, int(row.member), int(row.year), int(row.month),
+                      location_ids[LOCATION_NAMES[location]], int(row.days_covered),
+                      round(float(predictions[location][i]), 2)))
+                self.outlook_records_saved += 1
+        
+        print(f"Saved {self.outlook_records_saved} outlook predictions for {source} issued {issued_at}")
+    
     def _plot_predictions_comparison(self, y_test, base_predictions, corrected_predictions, dates_test):
         fig, axes = plt.subplots(4, 1, figsize=(15, 16))
         locations = ['Awali', 'Refinery', 'UOB', 'Total']
@@ -204,37 +531,11 @@ class MonthlyRandomForestModel:
         plt.close()
     
     def plot_feature_importances(self):
-        feature_name_mapping = {
-            'avg_sunshine_duration_seconds': 'Sunshine Duration (hours)',
-            'avg_daylight_duration_seconds': 'Daylight Duration (hours)',
-            'min_temperature_C': 'Minimum Temperature (°C)',
-            'avg_temperature_C': 'Average Temperature (°C)',
-            'max_temperature_C': 'Maximum Temperature (°C)',
-            'avg_solar_irradiance_wm2': 'Solar Irradiance (W/m²)',
-            'avg_relative_humidity_percent': 'Relative Humidity (%)',
-            'avg_cloud_cover_percent': 'Cloud Cover (%)',
-            'avg_wind_speed_kmh': 'Wind Speed (km/h)',
-            'total_rainfall_mm': 'Rainfall (mm)',
-            'month_cos': 'Seasonal Pattern',
-            'total_refinery_rolling_avg_3': '3-Month Rolling Avg (Refinery)',
-            'total_refinery_rolling_avg_6': '6-Month Rolling Avg (Refinery)',
-            'total_refinery_trend': 'Trend (Refinery)',
-            'total_awali_rolling_avg_3': '3-Month Rolling Avg (Awali)',
-            'total_awali_rolling_avg_6': '6-Month Rolling Avg (Awali)',
-            'total_awali_trend': 'Trend (Awali)',
-            'total_UOB_rolling_avg_3': '3-Month Rolling Avg (UOB)',
-            'total_UOB_rolling_avg_6': '6-Month Rolling Avg (UOB)',
-            'total_UOB_trend': 'Trend (UOB)',
-            'total_all_rolling_avg_3': '3-Month Rolling Avg (Total)',
-            'total_all_rolling_avg_6': '6-Month Rolling Avg (Total)',
-            'total_all_trend': 'Trend (Total)'
-        }
-
         plt.figure(figsize=(12, 8))
         
         for location, importances in self.feature_importances.items():
             importance_df = pd.DataFrame({
-                'feature': [feature_name_mapping.get(f, f) for f in importances.keys()],
+                'feature': [FEATURE_NAMES.get(f, f) for f in importances.keys()],
                 'importance': list(importances.values())
             }).sort_values('importance', ascending=True)
             
@@ -248,12 +549,7 @@ class MonthlyRandomForestModel:
                         bbox_inches='tight', dpi=300)
             plt.close()
     
-    def save_predictions_to_db(self, y_all, corrected_predictions, dates_all):
-        print(f"\nSaving predictions to database...")
-        db_path = os.path.join(os.path.dirname(__file__), '..', '..', 'db', 'app.db')
-        conn = sqlite3.connect(db_path)
-        cursor = conn.cursor()
-
+    def save_predictions_to_db(self, cursor, y_all, corrected_predictions, dates_all, quantile_predictions, explanations):
         location_ids = {}
         cursor.execute("SELECT id, name FROM locations")
         for loc_id, name in cursor.fetchall():
@@ -265,67 +561,145 @@ class MonthlyRandomForestModel:
 
         records_saved = 0
         
-        try:
-            cursor.execute("BEGIN TRANSACTION")
-            cursor.execute("UPDATE monthly_generation SET predicted_kwh = NULL")
-            print("Cleared existing predictions from database")
+        cursor.execute("UPDATE monthly_generation SET predicted_kwh = NULL")
+        cursor.execute("DELETE FROM forecast_quantiles")
+        cursor.execute("DELETE FROM forecast_explanations")
+        print("Cleared existing predictions from database")
 
-            column_mapping = {
-                'total_awali': 'Awali',
-                'total_refinery': 'Refinery',
-                'total_UOB': 'UOB',
-                'total_all': 'Total System'
-            }
+        column_mapping = {
+            'total_awali': 'Awali',
+            'total_refinery': 'Refinery',
+            'total_UOB': 'UOB',
+            'total_all': 'Total System'
+        }
+
+        for i in range(total_size):
+            date = pd.to_datetime(dates_all[i])
+            year = date.year
+            month = date.month
+
+            for col, location in column_mapping.items():
+                actual_value = float(y_all.iloc[i][col])
+                
+                if i >= train_size:
+                    pred_idx = i - train_size
+                    pred_col_idx = list(column_mapping.values()).index(location)
+                    predicted_value = float(corrected_predictions[pred_idx, pred_col_idx])
+                    p10, p50, p90 = (float(q) for q in quantile_predictions[pred_idx, pred_col_idx])
+                else:
+                    predicted_value = None
 
-            for i in range(total_size):
-                date = pd.to_datetime(dates_all[i])
-                year = date.year
-                month = date.month
+                query = """
+                INSERT INTO monthly_generation 
+                (year, month, location_id, actual_kwh, predicted_kwh)
+                VALUES (?, ?, ?, ?, ?)
+                ON CONFLICT (year, month, location_id) 
+                DO UPDATE SET 
+                    predicted_kwh = excluded.predicted_kwh
+                """
+                
+                cursor.execute(query, (
+                    year,
+                    month,
+                    location_ids[location],
+                    round(actual_value, 2),
+                    round(predicted_value, 2) if predicted_value is not None else None
+                ))
+                records_saved += 1
 
-                for col, location in column_mapping.items():
-                    actual_value = float(y_all.iloc[i][col])
-                    
-                    if i >= train_size:
-                        pred_idx = i - train_size
-                        pred_col_idx = list(column_mapping.values()).index(location)
-                        predicted_value = float(corrected_predictions[pred_idx, pred_col_idx])
-                    else:
-                        predicted_value = None
+                if predicted_value is not None:
+                    cursor.execute("""
+                    INSERT INTO forecast_quantiles
+                    (year, month, location_id, p10_kwh, p50_kwh, p90_kwh, method)
+                    VALUES (?, ?, ?, ?, ?, ?, 'oob_residual_bootstrap')
+                    """, (year, month, location_ids[location], round(p10, 2), round(p50, 2), round(p90, 2)))
 
-                    query = """
-                    INSERT INTO monthly_generation 
-                    (year, month, location_id, actual_kwh, predicted_kwh)
-                    VALUES (?, ?, ?, ?, ?)
-                    ON CONFLICT (year, month, location_id) 
-                    DO UPDATE SET 
-                        actual_kwh = excluded.actual_kwh,
-                        predicted_kwh = excluded.predicted_kwh
-                    """
-                    
-                    cursor.execute(query, (
-                        year,
-                        month,
-                        location_ids[location],
-                        round(actual_value, 2),
-                        round(predicted_value, 2) if predicted_value is not None else None
-                    ))
-                    records_saved += 1
+                    short_name = LOCATIONS[pred_col_idx]
+                    base_value, items, method = explanations[short_name][pred_idx]
+                    for feature_name, feature_value, contribution in items:
+                        cursor.execute("""
+                        INSERT INTO forecast_explanations
+                        (model_run_id, year, month, location_id, feature_name, feature_value, contribution_kwh, base_value_kwh, method)
+                        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+                        """, (self.run_id, year, month, location_ids[location], feature_name,
+                              round(feature_value, 4) if feature_value is not None else None,
+                              round(contribution, 2), round(base_value, 2), method))
 
-            cursor.execute("COMMIT")
-            print(f"Successfully saved {records_saved} records to database")
+        for short_name, importances in self.feature_importances.items():
+            for feature, importance in importances.items():
+                cursor.execute("""
+                INSERT INTO site_feature_importance
+                (model_run_id, model, location_id, feature_name, importance_value)
+                VALUES (?, 'random_forest', ?, ?, ?)
+                """, (self.run_id, location_ids[LOCATION_NAMES[short_name]],
+                      FEATURE_NAMES.get(feature, feature), round(float(importance), 4)))
 
-        except sqlite3.Error as e:
-            cursor.execute("ROLLBACK")
-            print(f"Error saving to database: {e}")
-            raise
+        self.records_saved = records_saved
 
-        finally:
-            conn.close()
+def parse_args():
+    parser = argparse.ArgumentParser(description='Train the monthly random forest and write predictions to the database')
+    parser.add_argument('--db', default=DEFAULT_DB_PATH, help='path to the SQLite database, unless SOLAR_DB_URL names a PostgreSQL one')
+    parser.add_argument('--plots-dir', default=DEFAULT_PLOTS_DIR, help='directory to write plots to')
+    parser.add_argument('--n-estimators', type=int, default=500)
+    parser.add_argument('--test-size', type=float, default=0.2)
+    parser.add_argument('--outlook-source', default=None, help='weather_outlook source to forecast from; defaults to the latest issue of any source')
+    parser.add_argument('--run-id', type=int, default=None, help='model_runs id to tag stored results with')
+    parser.add_argument('--model-file', default=None, help='file the trained model is saved to, and loaded from with --predict')
+    parser.add_argument('--predict', default=None, help='JSON scenario to predict for with the model in --model-file instead of training; nothing is written')
+    args = parser.parse_args()
+    if args.predict is not None and args.model_file is None:
+        parser.error('--predict needs --model-file')
+    return args
 
 def main():
-    model = MonthlyRandomForestModel()
+    args = parse_args()
+
+    # stdout carries the JSON result for the Go runner; progress output goes to stderr
+    result_stream = sys.stdout
+    sys.stdout = sys.stderr
+
+    model = MonthlyRandomForestModel(
+        db_path=args.db,
+        plots_folder=args.plots_dir,
+        n_estimators=args.n_estimators,
+        test_size=args.test_size,
+        run_id=args.run_id,
+        outlook_source=args.outlook_source,
+        write_plots=args.predict is None
+    )
+    
+    if args.predict is not None:
+        model.load_model(args.model_file)
+        with open(args.predict) as f:
+            scenario = json.load(f)
+        json.dump({
+            'model': 'random_forest',
+            'rows_written': 0,
+            'metrics': {},
+            'predictions': model.predict_scenario(scenario.get('weather'))
+        }, result_stream)
+        result_stream.write('\n')
+        return
+
     model.train()
     model.predict(model.X_test)
+    model.predict_outlook()
+    tmp_model_file = model.save_model(args.model_file) if args.model_file is not None else None
+    try:
+        model.save_to_db()
+    except Exception:
+        if tmp_model_file is not None:
+            os.remove(tmp_model_file)
+        raise
+    if tmp_model_file is not None:
+        model.publish_model(tmp_model_file, args.model_file)
+
+    json.dump({
+        'model': 'random_forest',
+        'rows_written': model.records_saved + model.outlook_records_saved,
+        'metrics': model.metrics
+    }, result_stream)
+    result_stream.write('\n')
 
 if __name__ == "__main__":
     main()
\ No newline at end of file
@@ -1,19 +1,44 @@
-import sqlite3
 import pandas as pd
 import numpy as np
 from sklearn.model_selection import train_test_split
 from sklearn.preprocessing import StandardScaler
 from sklearn.ensemble import RandomForestRegressor
+from sklearn.base import clone
 from sklearn.metrics import r2_score, mean_squared_error
 import matplotlib.pyplot as plt
 import os
+import sys
+import json
+import argparse
 import warnings
+
+sys.path.insert(0, os.path.join(os.path.dirname(os.path.abspath(__file__)), '..'))
+import solar_db
+
 warnings.filterwarnings('ignore')
 
+DEFAULT_DB_PATH = os.path.join(os.path.dirname(os.path.abspath(__file__)), '..', '..', 'db', 'app.db')
+DEFAULT_PLOTS_DIR = os.path.join(os.path.dirname(os.path.abspath(__file__)), 'plots')
+
+# Target columns and the location each one is stored under
+SITE_TARGETS = {
+    'total_awali': 'Awali',
+    'total_refinery': 'Refinery',
+    'total_UOB': 'UOB',
+    'total_all': 'Total System'
+}
+
 class WeatherOnlyModel:
-    def __init__(self):
+    def __init__(self, db_path=DEFAULT_DB_PATH, plots_folder=DEFAULT_PLOTS_DIR, n_estimators=500, test_size=0.2, run_id=None):
+        self.db_path = db_path
+        self.run_id = run_id
+        self.site_importances = {}
+        self.plots_folder = plots_folder
+        self.test_size = test_size
+        self.metrics = {}
+        self.records_saved = 0
         self.model = RandomForestRegressor(
-             n_estimators=500,          
+             n_estimators=n_estimators,          
             max_depth=15,              
             min_samples_split=4,       
             min_samples_leaf=2,        
@@ -26,9 +51,7 @@ class WeatherOnlyModel:
         self.scaler = StandardScaler()
     
     def load_and_prepare_data(self):
-        # Connect to SQLite database
-        db_path = os.path.join(os.path.dirname(__file__), '..', '..', 'db', 'app.db')
-        conn = sqlite3.connect(db_path)
+        conn = solar_db.connect(self.db_path)
         
         # Load only weather data
         weather_data = pd.read_sql_query("""
@@ -65,6 +88,9 @@ class WeatherOnlyModel:
         
         conn.close()
         
+        # Months with weather but no reported generation yet can't be trained on
+        power_data = power_data.dropna(subset=['total_refinery', 'total_awali', 'total_UOB', 'total_all'])
+        
         # Merge data
         merged_data = weather_data.merge(power_data, on=['year', 'month'])
         
@@ -91,8 +117,7 @@ class WeatherOnlyModel:
     
     def plot_feature_importance(self, feature_names):
         # Create plots directory if it doesn't exist
-        current_dir = os.path.dirname(os.path.abspath(__file__))
-        plots_folder = os.path.join(current_dir, "plots")
+        plots_folder = self.plots_folder
         os.makedirs(plots_folder, exist_ok=True)
 
         # Create readable feature names mapping
@@ -146,16 +171,15 @@ class WeatherOnlyModel:
         plt.close()
 
         # Save feature importance to database
-        self.save_feature_importance_to_db(importance_dict)
+        self.save_feature_importance_to_db(importance_dict, self.site_importances)
         
         # Print importance scores
         print("\nWeather Feature Importance Scores:")
         for feature, importance in sorted(importance_dict.items(), key=lambda x: x[1], reverse=True):
             print(f"{feature}: {importance:.4f}")
 
-    def save_feature_importance_to_db(self, importance_dict):
-        db_path = os.path.join(os.path.dirname(__file__), '..', '..', 'db', 'app.db')
-        conn = sqlite3.connect(db_path)
+    def save_feature_importance_to_db(self, importance_dict, site_importances):
+        conn = solar_db.connect(self.db_path)
         cursor = conn.cursor()
         
         # Create readable feature names mapping for database
@@ -184,9 +208,20 @@ class WeatherOnlyModel:
                     VALUES (?, ?)
                 """, (display_name, rounded_importance))
             
+            # Per-site importances are kept for every run so they can be compared over time
+            location_ids = dict((name, loc_id) for loc_id, name in cursor.execute("SELECT id, name FROM locations").fetchall())
+            for location, importances in site_importances.items():
+                for feature, importance in importances.items():
+                    cursor.execute("""
+                        INSERT INTO site_feature_importance (model_run_id, model, location_id, feature_name, importance_value)
+                        VALUES (?, 'weather_only', ?, ?, ?)
+                    """, (self.run_id, location_ids[location], feature_name_mapping.get(feature, feature), round(float(importance), 4)))
+            
             conn.commit()
-        except sqlite3.Error as e:
+            self.records_saved = len(importance_dict) + sum(len(importances) for importances in site_importances.values())
+        except solar_db.Error as e:
             print(f"Database error: {e}")
+            raise
         finally:
             conn.close()
     
@@ -199,13 +234,18 @@ class WeatherOnlyModel:
         
         # Split the data
         X_train, X_test, y_train, y_test = train_test_split(
-            X_scaled, y, test_size=0.2,
+            X_scaled, y, test_size=self.test_size,
             random_state=42, shuffle=False
         )
         
         print("Training Weather-Only Random Forest Model...")
         self.model.fit(X_train, y_train)
         
+        # The multi-output forest only has one set of importances, so fit a single-output copy per site
+        for column, location in SITE_TARGETS.items():
+            site_model = clone(self.model).fit(X_train, y_train[column])
+            self.site_importances[location] = dict(zip(X.columns, site_model.feature_importances_))
+        
         # Make predictions
         y_pred = self.model.predict(X_test)
         
@@ -219,13 +259,47 @@ class WeatherOnlyModel:
             print(f"R² Score: {r2:.4f}")
             print(f"RMSE: {rmse:.2f}")
             print(f"Error Percentage: {mape:.2f}%")
+            
+            self.metrics[location] = {
+                'r2': round(float(r2), 4),
+                'rmse': round(float(rmse), 2),
+                'mape': round(float(mape), 4)
+            }
         
         self.plot_feature_importance(X.columns)
         return self.model
 
+def parse_args():
+    parser = argparse.ArgumentParser(description='Train the weather-only model and write feature importances to the database')
+    parser.add_argument('--db', default=DEFAULT_DB_PATH, help='path to the SQLite database, unless SOLAR_DB_URL names a PostgreSQL one')
+    parser.add_argument('--plots-dir', default=DEFAULT_PLOTS_DIR, help='directory to write plots to')
+    parser.add_argument('--n-estimators', type=int, default=500)
+    parser.add_argument('--test-size', type=float, default=0.2)
+    parser.add_argument('--run-id', type=int, default=None, help='model_runs id to tag stored results with')
+    return parser.parse_args()
+
 def main():
-    model = WeatherOnlyModel()
+    args = parse_args()
+
+    # stdout carries the JSON result for the Go runner; progress output goes to stderr
+    result_stream = sys.stdout
+    sys.stdout = sys.stderr
+
+    model = WeatherOnlyModel(
+        db_path=args.db,
+        plots_folder=args.plots_dir,
+        n_estimators=args.n_estimators,
+        test_size=args.test_size,
+        run_id=args.run_id
+    )
     model.train()
 
+    json.dump({
+        'model': 'weather_only',
+        'rows_written': model.records_saved,
+        'metrics': model.metrics
+    }, result_stream)
+    result_stream.write('\n')
+
 if __name__ == "__main__":
     main()
\ No newline at end of file
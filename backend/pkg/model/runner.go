@@ -0,0 +1,331 @@
+package model
+
+import (
+	"backend/pkg/config"
+	"backend/pkg/db"
+	"backend/pkg/logging"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Run statuses recorded in model_runs
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusTimeout   = "timeout"
+)
+
+const (
+	defaultTimeout  = 30 * time.Minute
+	stderrTailBytes = 4096
+	timestampLayout = "2006-01-02 15:04:05"
+)
+
+// Result is the JSON line a model script prints as the last line of its stdout
+type Result struct {
+	Model       string                        `json:"model"`
+	RowsWritten int                           `json:"rows_written"`
+	Metrics     map[string]map[string]float64 `json:"metrics"`
+	// Predictions are only printed by a Predict run
+	Predictions []Prediction `json:"predictions,omitempty"`
+}
+
+// Prediction is a model's forecast for one site and month under the recorded weather and under
+// the weather a scenario asked for
+type Prediction struct {
+	Year     int     `json:"year"`
+	Month    int     `json:"month"`
+	Location string  `json:"location"`
+	Baseline float64 `json:"baseline"`
+	Scenario float64 `json:"scenario"`
+}
+
+// ErrNoTrainedModel is returned by Predict before a training run has saved the model
+var ErrNoTrainedModel = errors.New("the model has not been trained yet")
+
+// Runner runs the Python model scripts with an explicit interpreter, database and timeout. The
+// scripts open the SQLite file at DBPath, or the PostgreSQL database at DBURL when it is set.
+// Trained models are saved in Artifacts, where Predict loads them from.
+type Runner struct {
+	Python    string
+	Timeout   time.Duration
+	DBPath    string
+	DBURL     string
+	Artifacts string
+
+	db *db.DB
+}
+
+// NewRunner returns a runner with the interpreter, timeout and artifacts directory in cfg that
+// points the scripts at database and records their runs there
+func NewRunner(database *db.DB, cfg config.ModelConfig) *Runner {
+	timeout := time.Duration(cfg.Timeout)
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return &Runner{
+		Python:    pythonInterpreter(cfg.Python),
+		Timeout:   timeout,
+		DBPath:    database.Path,
+		DBURL:     database.URL,
+		Artifacts: cfg.Artifacts,
+		db:        database,
+	}
+}
+
+// ModelFile is where the model called name is saved by its training run
+func (r *Runner) ModelFile(name string) (string, error) {
+	path, err := filepath.Abs(filepath.Join(r.Artifacts, name+".joblib"))
+	if err != nil {
+		return "", fmt.Errorf("error resolving model file: %v", err)
+	}
+	return path, nil
+}
+
+// pythonInterpreter prefers the configured interpreter, then the active virtualenv, then python3
+// on PATH
+func pythonInterpreter(configured string) string {
+	if configured != "" {
+		return configured
+	}
+	if venv := os.Getenv("VIRTUAL_ENV"); venv != "" {
+		python := filepath.Join(venv, "bin", "python")
+		if _, err := os.Stat(python); err == nil {
+			return python
+		}
+	}
+	return "python3"
+}
+
+// Run executes a model script and records the run in model_runs. params are passed to the
+// script as --key value flags alongside --db and --run-id. The script runs from its own directory.
+func (r *Runner) Run(ctx context.Context, name, script string, params map[string]string) (*Result, error) {
+	scriptPath, err := filepath.Abs(script)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving script %s: %v", script, err)
+	}
+	dbPath, err := filepath.Abs(r.DBPath)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving database path: %v", err)
+	}
+
+	runID, err := r.startRun(name)
+	if err != nil {
+		return nil, err
+	}
+
+	args := append([]string{scriptPath, "--db", dbPath, "--run-id", strconv.FormatInt(runID, 10)}, flagArgs(params)...)
+	logger := logging.FromContext(ctx).With("model", name)
+	out, runErr := r.invoke(ctx, name, scriptPath, args)
+	duration, exitCode, tail := out.duration, out.exitCode, out.stderrTail
+
+	if runErr != nil {
+		r.finishRun(runID, out.status, duration, exitCode, nil, runErr, tail)
+		logger.Error("Model failed", "status", out.status, "exit_code", exitCode, "err", runErr, "stderr", tail)
+		return nil, runErr
+	}
+
+	result, err := parseResult(out.stdout)
+	if err == nil && result.RowsWritten == 0 {
+		// A run that exits cleanly but writes nothing would otherwise leave predictions silently empty
+		err = errors.New("script wrote no rows")
+	}
+	if err != nil {
+		err = fmt.Errorf("model %s: %v", name, err)
+		r.finishRun(runID, StatusFailed, duration, exitCode, nil, err, tail)
+		logger.Error("Model failed", "status", StatusFailed, "exit_code", exitCode, "err", err, "stderr", tail)
+		return nil, err
+	}
+
+	r.finishRun(runID, StatusSucceeded, duration, exitCode, result, nil, tail)
+	logger.Info("Model finished", "rows", result.RowsWritten, "duration_ms", duration.Milliseconds())
+	return result, nil
+}
+
+// Predict runs a model script with --model-file, the model its last training run saved, and
+// --predict, the path of a JSON file holding input, and returns the predictions it prints. The
+// script only loads the model, so it must not train or write to the database, and the run is not
+// recorded in model_runs.
+func (r *Runner) Predict(ctx context.Context, name, script string, input interface{}) ([]Prediction, error) {
+	scriptPath, err := filepath.Abs(script)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving script %s: %v", script, err)
+	}
+	dbPath, err := filepath.Abs(r.DBPath)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving database path: %v", err)
+	}
+	modelFile, err := r.ModelFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(modelFile); errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("model %s: %w", name, ErrNoTrainedModel)
+	}
+
+	file, err := os.CreateTemp("", "predict-*.json")
+	if err != nil {
+		return nil, fmt.Errorf("error creating prediction input: %v", err)
+	}
+	defer os.Remove(file.Name())
+	err = json.NewEncoder(file).Encode(input)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error writing prediction input: %v", err)
+	}
+
+	out, err := r.invoke(ctx, name, scriptPath, []string{scriptPath, "--db", dbPath, "--model-file", modelFile, "--predict", file.Name()})
+	if err != nil {
+		logging.FromContext(ctx).Error("Model prediction failed", "model", name, "status", out.status, "err", err, "stderr", out.stderrTail)
+		return nil, err
+	}
+	result, err := parseResult(out.stdout)
+	if err == nil && len(result.Predictions) == 0 {
+		err = errors.New("script printed no predictions")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("model %s: %v", name, err)
+	}
+	return result.Predictions, nil
+}
+
+// invocation is what running a script produced
+type invocation struct {
+	stdout, stderrTail string
+	exitCode           int
+	status             string
+	duration           time.Duration
+}
+
+// invoke runs the script with args under the runner's timeout. On failure the status says
+// whether it timed out.
+func (r *Runner) invoke(ctx context.Context, name, scriptPath string, args []string) (invocation, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, r.Python, args...)
+	cmd.Dir = filepath.Dir(scriptPath)
+	// Set even when empty, so a URL left in the environment never sends SQLite runs to PostgreSQL
+	cmd.Env = append(os.Environ(), "PYTHONUNBUFFERED=1", "SOLAR_DB_URL="+r.DBURL)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = 10 * time.Second
+
+	started := time.Now()
+	logging.FromContext(ctx).Info("Running model", "model", name, "python", r.Python, "args", strings.Join(args, " "))
+	runErr := cmd.Run()
+
+	out := invocation{
+		stdout:     stdout.String(),
+		stderrTail: tailOf(stderr.String(), stderrTailBytes),
+		exitCode:   -1,
+		status:     StatusSucceeded,
+		duration:   time.Since(started),
+	}
+	if cmd.ProcessState != nil {
+		out.exitCode = cmd.ProcessState.ExitCode()
+	}
+
+	if runErr != nil {
+		out.status = StatusFailed
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			out.status = StatusTimeout
+			return out, fmt.Errorf("model %s timed out after %s", name, r.Timeout)
+		}
+		return out, fmt.Errorf("model %s failed: %v", name, runErr)
+	}
+	return out, nil
+}
+
+// flagArgs turns params into --key value flags in a stable order
+func flagArgs(params map[string]string) []string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var args []string
+	for _, key := range keys {
+		args = append(args, "--"+key, params[key])
+	}
+	return args
+}
+
+// parseResult reads the last non-empty stdout line as the script's JSON result
+func parseResult(stdout string) (*Result, error) {
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	if last == "" {
+		return nil, errors.New("script printed no result")
+	}
+
+	var result Result
+	if err := json.Unmarshal([]byte(last), &result); err != nil {
+		return nil, fmt.Errorf("error decoding script result %q: %v", last, err)
+	}
+	return &result, nil
+}
+
+func tailOf(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[len(s)-n:]
+}
+
+func now() string {
+	return time.Now().UTC().Format(timestampLayout)
+}
+
+func (r *Runner) startRun(name string) (int64, error) {
+	var runID int64
+	err := r.db.QueryRow(`
+		INSERT INTO model_runs (model, status, started_at)
+		VALUES (?, ?, ?)
+		RETURNING id
+	`, name, StatusRunning, now()).Scan(&runID)
+	if err != nil {
+		return 0, fmt.Errorf("error recording model run: %v", err)
+	}
+	return runID, nil
+}
+
+func (r *Runner) finishRun(runID int64, status string, duration time.Duration, exitCode int, result *Result, runErr error, stderrTail string) {
+	var rowsWritten, metrics, errorMessage interface{}
+	if result != nil {
+		rowsWritten = result.RowsWritten
+		if encoded, err := json.Marshal(result.Metrics); err == nil {
+			metrics = string(encoded)
+		}
+	}
+	if runErr != nil {
+		errorMessage = runErr.Error()
+	}
+
+	_, err := r.db.Exec(`
+		UPDATE model_runs
+		SET status = ?, finished_at = ?, duration_ms = ?, exit_code = ?, rows_written = ?,
+			metrics_json = ?, error_message = ?, stderr_tail = ?
+		WHERE id = ?
+	`, status, now(), duration.Milliseconds(), exitCode, rowsWritten, metrics, errorMessage, stderrTail, runID)
+	if err != nil {
+		slog.Error("Error updating model run", "run_id", runID, "err", err)
+	}
+}
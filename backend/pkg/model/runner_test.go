@@ -0,0 +1,193 @@
+package model
+
+import (
+	"backend/pkg/db"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openTestDatabase opens a fresh database in a temporary directory
+func openTestDatabase(t *testing.T) *db.DB {
+	t.Helper()
+
+	database, err := db.Open(filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+// writeScript writes a shell script standing in for a model script
+func writeScript(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "model.sh")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		timeout time.Duration
+		status  string
+		err     string
+		result  *Result
+	}{
+		{
+			name:   "result on the last line",
+			script: `echo "training..."; echo '{"model": "m", "rows_written": 12, "metrics": {"Awali": {"r2": 0.9}}}'`,
+			status: StatusSucceeded,
+			result: &Result{Model: "m", RowsWritten: 12, Metrics: map[string]map[string]float64{"Awali": {"r2": 0.9}}},
+		},
+		{
+			name:   "no rows written",
+			script: `echo '{"model": "m", "rows_written": 0}'`,
+			status: StatusFailed,
+			err:    "script wrote no rows",
+		},
+		{
+			name:   "no result",
+			script: `echo "done" >&2`,
+			status: StatusFailed,
+			err:    "script printed no result",
+		},
+		{
+			name:   "result is not JSON",
+			script: `echo "done"`,
+			status: StatusFailed,
+			err:    "error decoding script result",
+		},
+		{
+			name:   "non-zero exit",
+			script: `echo "boom" >&2; exit 3`,
+			status: StatusFailed,
+			err:    "exit status 3",
+		},
+		{
+			name:    "timeout",
+			script:  `exec sleep 5`,
+			timeout: 100 * time.Millisecond,
+			status:  StatusTimeout,
+			err:     "timed out after 100ms",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := openTestDatabase(t)
+
+			timeout := tt.timeout
+			if timeout == 0 {
+				timeout = 10 * time.Second
+			}
+			runner := &Runner{Python: "sh", Timeout: timeout, DBPath: database.Path, db: database}
+
+			result, err := runner.Run(context.Background(), "m", writeScript(t, tt.script), map[string]string{"horizon": "12"})
+			if tt.err == "" && err != nil {
+				t.Fatalf("Run() = %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("Run() = %v, want an error containing %q", err, tt.err)
+			}
+			if !reflect.DeepEqual(result, tt.result) {
+				t.Errorf("Run() = %+v, want %+v", result, tt.result)
+			}
+
+			var status string
+			if err := database.QueryRow(`SELECT status FROM model_runs`).Scan(&status); err != nil {
+				t.Fatal(err)
+			}
+			if status != tt.status {
+				t.Errorf("model_runs status = %s, want %s", status, tt.status)
+			}
+		})
+	}
+}
+
+func TestRunPassesParams(t *testing.T) {
+	database := openTestDatabase(t)
+
+	out := filepath.Join(t.TempDir(), "args")
+	script := writeScript(t, `echo "$@" > `+out+`; echo '{"model": "m", "rows_written": 1}'`)
+	runner := &Runner{Python: "sh", Timeout: 10 * time.Second, DBPath: database.Path, db: database}
+	if _, err := runner.Run(context.Background(), "m", script, map[string]string{"start": "2020", "end": "2021"}); err != nil {
+		t.Fatal(err)
+	}
+
+	args, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbPath, _ := filepath.Abs(database.Path)
+	// Params are passed in key order after the database and the run ID
+	if want := "--db " + dbPath + " --run-id 1 --end 2021 --start 2020\n"; string(args) != want {
+		t.Errorf("script args = %q, want %q", args, want)
+	}
+}
+
+func TestPredict(t *testing.T) {
+	tests := []struct {
+		name      string
+		script    string
+		untrained bool
+		want      []Prediction
+		err       string
+	}{
+		{
+			name: "predictions",
+			// The saved model follows --model-file and the input file is passed after --predict,
+			// as the last argument
+			script: `case "$*" in *"--model-file $MODEL_FILE --predict"*) ;; *) exit 5;; esac
+for last; do :; done; grep -q '"scale":0.9' "$last" || exit 4
+echo '{"model": "m", "predictions": [{"year": 2020, "month": 6, "location": "Awali", "baseline": 100, "scenario": 90}]}'`,
+			want: []Prediction{{Year: 2020, Month: 6, Location: "Awali", Baseline: 100, Scenario: 90}},
+		},
+		{name: "no predictions", script: `echo '{"model": "m", "rows_written": 3}'`, err: "script printed no predictions"},
+		{name: "non-zero exit", script: `exit 2`, err: "exit status 2"},
+		{name: "untrained model", script: `exit 2`, untrained: true, err: ErrNoTrainedModel.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := openTestDatabase(t)
+			runner := &Runner{Python: "sh", Timeout: 10 * time.Second, DBPath: database.Path, Artifacts: t.TempDir(), db: database}
+			modelFile, err := runner.ModelFile("m")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.untrained {
+				if err := os.WriteFile(modelFile, []byte("model"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			t.Setenv("MODEL_FILE", modelFile)
+
+			got, err := runner.Predict(context.Background(), "m", writeScript(t, tt.script), map[string]float64{"scale": 0.9})
+			if tt.err == "" && err != nil {
+				t.Fatalf("Predict() = %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("Predict() = %v, want an error containing %q", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Predict() = %+v, want %+v", got, tt.want)
+			}
+
+			// Predictions write nothing, so they are not recorded as model runs
+			var runs int
+			if err := database.QueryRow(`SELECT COUNT(*) FROM model_runs`).Scan(&runs); err != nil {
+				t.Fatal(err)
+			}
+			if runs != 0 {
+				t.Errorf("model_runs = %d, want 0", runs)
+			}
+		})
+	}
+}
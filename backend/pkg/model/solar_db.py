@@ -0,0 +1,124 @@
+"""Connections to the database the server keeps its data in.
+
+The server sets SOLAR_DB_URL when it stores its data in PostgreSQL; otherwise the scripts open the
+SQLite file given with --db. Statements are written once, with ? placeholders and the SQL both
+databases share. A PostgreSQL connection rebinds the placeholders, and the few expressions that
+differ come from day(), year_of() and month_of().
+"""
+import os
+import re
+import sqlite3
+
+DB_URL = os.environ.get('SOLAR_DB_URL', '')
+POSTGRES = DB_URL != ''
+
+if POSTGRES:
+    import psycopg2
+    Error = (sqlite3.Error, psycopg2.Error)
+else:
+    Error = sqlite3.Error
+
+IDENTIFIER = re.compile(r'[A-Za-z_][A-Za-z0-9_]*')
+
+
+def connect(db_path):
+    """Opens the PostgreSQL database when SOLAR_DB_URL is set, and the SQLite file at db_path otherwise"""
+    if POSTGRES:
+        return Connection(psycopg2.connect(DB_URL))
+    return sqlite3.connect(db_path)
+
+
+def day(expr):
+    """expr, a date or timestamp, as YYYY-MM-DD text"""
+    if POSTGRES:
+        return f"to_char({expr}, 'YYYY-MM-DD')"
+    return f"date({expr})"
+
+
+def year_of(expr):
+    """The year of expr, a date or timestamp, as an integer"""
+    if POSTGRES:
+        return f"CAST(EXTRACT(YEAR FROM {expr}) AS INTEGER)"
+    return f"CAST(strftime('%Y', {expr}) AS INTEGER)"
+
+
+def month_of(expr):
+    """The month (1-12) of expr, a date or timestamp, as an integer"""
+    if POSTGRES:
+        return f"CAST(EXTRACT(MONTH FROM {expr}) AS INTEGER)"
+    return f"CAST(strftime('%m', {expr}) AS INTEGER)"
+
+
+def rebind(query):
+    """Rewrites the ? placeholders of query into psycopg2's %s, escaping literal percent signs.
+    Question marks in quoted strings and identifiers are kept."""
+    out = []
+    i = 0
+    while i < len(query):
+        c = query[i]
+        if c in ("'", '"'):
+            end = i + 1
+            while end < len(query):
+                if query[end] == c:
+                    if end + 1 < len(query) and query[end + 1] == c:
+                        end += 2
+                        continue
+                    break
+                end += 1
+            out.append(query[i:end + 1].replace('%', '%%'))
+            i = end + 1
+            continue
+        if c == '?':
+            out.append('%s')
+        elif c == '%':
+            out.append('%%')
+        else:
+            out.append(c)
+        i += 1
+    return ''.join(out)
+
+
+class Connection:
+    """A psycopg2 connection taking the statements written for sqlite3"""
+
+    def __init__(self, conn):
+        self._conn = conn
+
+    def cursor(self):
+        return Cursor(self._conn.cursor())
+
+    def execute(self, query, params=()):
+        return self.cursor().execute(query, params)
+
+    def __getattr__(self, name):
+        return getattr(self._conn, name)
+
+
+class Cursor:
+    """A psycopg2 cursor taking ? placeholders. PostgreSQL folds unquoted names to lower case, so
+    result columns get back the case the query wrote them in, as SQLite returns them."""
+
+    def __init__(self, cursor):
+        self._cursor = cursor
+        self._names = {}
+
+    def execute(self, query, params=()):
+        self._names = {name.lower(): name for name in IDENTIFIER.findall(query)}
+        self._cursor.execute(rebind(query), tuple(params or ()))
+        return self
+
+    def executemany(self, query, rows):
+        self._cursor.executemany(rebind(query), [tuple(row) for row in rows])
+        return self
+
+    @property
+    def description(self):
+        if self._cursor.description is None:
+            return None
+        return [(self._names.get(column[0], column[0]),) + tuple(column[1:]) for column in self._cursor.description]
+
+    def __iter__(self):
+        return iter(self._cursor)
+
+    def __getattr__(self, name):
+        return getattr(self._cursor, name)
@@ -0,0 +1,409 @@
+package pipeline
+
+import (
+	"backend/pkg/db"
+	"backend/pkg/logging"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrUpToDate is returned by a DAG step that had nothing to do; the scheduler records it as skipped
+var ErrUpToDate = errors.New("step is up to date")
+
+// Plan actions
+const (
+	ActionRun        = "run"
+	ActionSkip       = "skip"
+	ActionAdopt      = "adopt"
+	ActionDownstream = "downstream"
+)
+
+// Node is a named step with the resources it reads and writes
+type Node struct {
+	Name    string
+	Inputs  []string
+	Outputs []string
+	Run     func(ctx context.Context) error
+
+	// Seed nodes only run while one of their outputs is empty, so they never overwrite edits
+	Seed bool
+	// Source nodes pull from outside the database and can be left out of a refresh
+	Source bool
+}
+
+// Graph is a validated, topologically ordered set of nodes
+type Graph struct {
+	db        *db.DB
+	nodes     []*Node
+	resources map[string]Resource
+	producers map[string]*Node
+}
+
+// PlannedStep is what a run would do with a node and why
+type PlannedStep struct {
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+}
+
+// NewGraph validates the nodes against the resources and orders them so every
+// node comes after the producers of its inputs. Table resources and the state of each node are
+// read from database.
+func NewGraph(database *db.DB, resources []Resource, nodes []*Node) (*Graph, error) {
+	g := &Graph{
+		db:        database,
+		resources: make(map[string]Resource),
+		producers: make(map[string]*Node),
+	}
+
+	for _, r := range resources {
+		g.resources[r.Name] = r
+	}
+
+	names := make(map[string]bool)
+	for _, n := range nodes {
+		if names[n.Name] {
+			return nil, fmt.Errorf("duplicate step %s", n.Name)
+		}
+		names[n.Name] = true
+
+		for _, out := range n.Outputs {
+			if _, ok := g.resources[out]; !ok {
+				return nil, fmt.Errorf("step %s writes unknown resource %s", n.Name, out)
+			}
+			if other, ok := g.producers[out]; ok {
+				return nil, fmt.Errorf("resource %s is written by both %s and %s", out, other.Name, n.Name)
+			}
+			g.producers[out] = n
+		}
+	}
+
+	for _, n := range nodes {
+		for _, in := range n.Inputs {
+			if _, ok := g.resources[in]; !ok {
+				return nil, fmt.Errorf("step %s reads unknown resource %s", n.Name, in)
+			}
+		}
+	}
+
+	ordered, err := g.sort(nodes)
+	if err != nil {
+		return nil, err
+	}
+	g.nodes = ordered
+
+	return g, nil
+}
+
+// sort is Kahn's algorithm, keeping declaration order among nodes that are ready together
+func (g *Graph) sort(nodes []*Node) ([]*Node, error) {
+	position := make(map[string]int)
+	for i, n := range nodes {
+		position[n.Name] = i
+	}
+
+	indegree := make(map[string]int)
+	dependents := make(map[string][]*Node)
+	for _, n := range nodes {
+		seen := make(map[string]bool)
+		for _, in := range n.Inputs {
+			producer, ok := g.producers[in]
+			if !ok || producer == n || seen[producer.Name] {
+				continue
+			}
+			seen[producer.Name] = true
+			indegree[n.Name]++
+			dependents[producer.Name] = append(dependents[producer.Name], n)
+		}
+	}
+
+	var ready, ordered []*Node
+	for _, n := range nodes {
+		if indegree[n.Name] == 0 {
+			ready = append(ready, n)
+		}
+	}
+
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool { return position[ready[i].Name] < position[ready[j].Name] })
+		n := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, n)
+
+		for _, dep := range dependents[n.Name] {
+			indegree[dep.Name]--
+			if indegree[dep.Name] == 0 {
+				ready = append(ready, dep)
+			}
+		}
+	}
+
+	if len(ordered) != len(nodes) {
+		var cyclic []string
+		for _, n := range nodes {
+			if indegree[n.Name] > 0 {
+				cyclic = append(cyclic, n.Name)
+			}
+		}
+		return nil, fmt.Errorf("steps form a cycle: %s", strings.Join(cyclic, ", "))
+	}
+
+	return ordered, nil
+}
+
+// Plan reports what a run would do right now without changing anything. Steps whose inputs
+// are produced by a step that will run are reported as downstream, since whether they rerun
+// depends on whether that step actually changes its outputs. That includes steps that would
+// otherwise be adopted, which run instead when an upstream step runs first.
+func (g *Graph) Plan(includeSources bool) ([]PlannedStep, error) {
+	state, err := loadState(g.db)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make(map[string]string)
+	var plan []PlannedStep
+
+	for _, n := range g.nodes {
+		var upstream []string
+		for _, in := range n.Inputs {
+			if producer, ok := g.producers[in]; ok && pending[producer.Name] != "" {
+				upstream = append(upstream, producer.Name)
+			}
+		}
+
+		action, reason, err := g.decide(n, state[n.Name], includeSources)
+		if err != nil {
+			return nil, err
+		}
+
+		if (action == ActionSkip || action == ActionAdopt) && len(upstream) > 0 && !n.Seed && (includeSources || !n.Source) {
+			action = ActionDownstream
+			reason = "inputs produced by " + strings.Join(dedupe(upstream), ", ")
+		}
+		if action == ActionRun || action == ActionDownstream {
+			pending[n.Name] = action
+		}
+
+		plan = append(plan, PlannedStep{Name: n.Name, Action: action, Reason: reason})
+	}
+
+	return plan, nil
+}
+
+// Steps turns the graph into scheduler steps. Each step re-checks staleness when it is
+// reached, after its upstream steps have run, and returns ErrUpToDate if it has nothing to do.
+// A step needs the producers of its inputs, so a failed step only holds back its downstream steps.
+func (g *Graph) Steps(includeSources bool) []Step {
+	steps := make([]Step, 0, len(g.nodes))
+	for _, n := range g.nodes {
+		node := n
+		var needs []string
+		for _, in := range node.Inputs {
+			if producer, ok := g.producers[in]; ok {
+				needs = append(needs, producer.Name)
+			}
+		}
+		steps = append(steps, Step{
+			Name:  node.Name,
+			Needs: dedupe(needs),
+			Run: func(ctx context.Context) error {
+				return g.runNode(ctx, node, includeSources)
+			},
+			Rows: func() map[string]int {
+				return g.outputRows(node)
+			},
+		})
+	}
+	return steps
+}
+
+func (g *Graph) runNode(ctx context.Context, n *Node, includeSources bool) error {
+	state, err := loadState(g.db)
+	if err != nil {
+		return err
+	}
+
+	action, reason, err := g.decide(n, state[n.Name], includeSources)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case ActionSkip:
+		return ErrUpToDate
+	case ActionAdopt:
+		// Outputs populated before fingerprints were recorded are only trusted when nothing
+		// upstream has changed them in this run; otherwise they predate the new inputs
+		upstream := g.upstreamRan(ctx, n)
+		if len(upstream) == 0 {
+			if err := g.saveNodeState(n); err != nil {
+				return err
+			}
+			return ErrUpToDate
+		}
+		reason = "never run, inputs produced by " + strings.Join(upstream, ", ")
+	}
+
+	if reason != "" {
+		logging.FromContext(ctx).Info("Running step", "step", n.Name, "reason", reason)
+	}
+	if err := n.Run(ctx); err != nil {
+		return err
+	}
+	if ran := ranInJob(ctx); ran != nil {
+		ran[n.Name] = true
+	}
+	if n.Seed {
+		return nil
+	}
+	return g.saveNodeState(n)
+}
+
+// jobRunKey carries the names of the nodes that have run so far in one job run
+type jobRunKey struct{}
+
+// withJobRun starts recording which nodes run under ctx
+func withJobRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, jobRunKey{}, make(map[string]bool))
+}
+
+// ranInJob returns the nodes run so far in ctx's job run, or nil outside one
+func ranInJob(ctx context.Context) map[string]bool {
+	ran, _ := ctx.Value(jobRunKey{}).(map[string]bool)
+	return ran
+}
+
+// upstreamRan lists the producers of n's inputs that have run earlier in ctx's job run
+func (g *Graph) upstreamRan(ctx context.Context, n *Node) []string {
+	ran := ranInJob(ctx)
+	var upstream []string
+	for _, in := range n.Inputs {
+		if producer, ok := g.producers[in]; ok && producer != n && ran[producer.Name] {
+			upstream = append(upstream, producer.Name)
+		}
+	}
+	return dedupe(upstream)
+}
+
+// decide works out whether a node is stale from its recorded fingerprints
+func (g *Graph) decide(n *Node, recorded *nodeState, includeSources bool) (string, string, error) {
+	empty, err := g.emptyOutputs(n)
+	if err != nil {
+		return "", "", err
+	}
+
+	if n.Seed || (n.Source && !includeSources) {
+		if len(empty) > 0 {
+			return ActionRun, "empty: " + strings.Join(empty, ", "), nil
+		}
+		if n.Seed {
+			return ActionSkip, "seeded", nil
+		}
+		return ActionSkip, "source not refreshed", nil
+	}
+
+	if recorded == nil {
+		if len(empty) > 0 {
+			return ActionRun, "never run, empty: " + strings.Join(empty, ", "), nil
+		}
+		return ActionAdopt, "outputs already populated; recording current state", nil
+	}
+
+	inputs, err := g.fingerprints(n.Inputs)
+	if err != nil {
+		return "", "", err
+	}
+	if changed := changedKeys(recorded.Inputs, inputs); len(changed) > 0 {
+		return ActionRun, "inputs changed: " + strings.Join(changed, ", "), nil
+	}
+
+	outputs, err := g.fingerprints(n.Outputs)
+	if err != nil {
+		return "", "", err
+	}
+	if changed := changedKeys(recorded.Outputs, outputs); len(changed) > 0 {
+		return ActionRun, "outputs modified since last run: " + strings.Join(changed, ", "), nil
+	}
+
+	return ActionSkip, "up to date", nil
+}
+
+func (g *Graph) emptyOutputs(n *Node) ([]string, error) {
+	var empty []string
+	for _, out := range n.Outputs {
+		isEmpty, err := g.resources[out].isEmpty(g.db)
+		if err != nil {
+			return nil, fmt.Errorf("error checking %s: %v", out, err)
+		}
+		if isEmpty {
+			empty = append(empty, out)
+		}
+	}
+	return empty, nil
+}
+
+// outputRows counts the rows in each of a node's counted outputs, for logging
+func (g *Graph) outputRows(n *Node) map[string]int {
+	rows := make(map[string]int)
+	for _, out := range n.Outputs {
+		r := g.resources[out]
+		if r.CountQuery == "" {
+			continue
+		}
+		if count, err := r.count(g.db); err == nil {
+			rows[out] = count
+		}
+	}
+	return rows
+}
+
+func (g *Graph) fingerprints(names []string) (map[string]string, error) {
+	hashes := make(map[string]string, len(names))
+	for _, name := range names {
+		hash, err := g.resources[name].fingerprint(g.db)
+		if err != nil {
+			return nil, fmt.Errorf("error fingerprinting %s: %v", name, err)
+		}
+		hashes[name] = hash
+	}
+	return hashes, nil
+}
+
+func (g *Graph) saveNodeState(n *Node) error {
+	inputs, err := g.fingerprints(n.Inputs)
+	if err != nil {
+		return err
+	}
+	outputs, err := g.fingerprints(n.Outputs)
+	if err != nil {
+		return err
+	}
+	return saveState(g.db, n.Name, nodeState{Inputs: inputs, Outputs: outputs})
+}
+
+func changedKeys(recorded, current map[string]string) []string {
+	var changed []string
+	for name, hash := range current {
+		if recorded[name] != hash {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func dedupe(values []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,283 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// testResource is fingerprinted as hash and is empty when empty is set
+func testResource(name, hash string, empty bool) Resource {
+	count := "SELECT 1"
+	if empty {
+		count = "SELECT 0"
+	}
+	return Resource{
+		Name:        name,
+		Fingerprint: func() (string, error) { return hash, nil },
+		CountQuery:  count,
+	}
+}
+
+func noop(context.Context) error { return nil }
+
+func TestNewGraphErrors(t *testing.T) {
+	resources := []Resource{testResource("a", "1", false), testResource("b", "1", false)}
+	tests := []struct {
+		name  string
+		nodes []*Node
+	}{
+		{"duplicate step", []*Node{{Name: "x", Outputs: []string{"a"}}, {Name: "x", Outputs: []string{"b"}}}},
+		{"unknown output", []*Node{{Name: "x", Outputs: []string{"c"}}}},
+		{"unknown input", []*Node{{Name: "x", Inputs: []string{"c"}, Outputs: []string{"a"}}}},
+		{"two writers", []*Node{{Name: "x", Outputs: []string{"a"}}, {Name: "y", Outputs: []string{"a"}}}},
+		{"cycle", []*Node{{Name: "x", Inputs: []string{"b"}, Outputs: []string{"a"}}, {Name: "y", Inputs: []string{"a"}, Outputs: []string{"b"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewGraph(nil, resources, tt.nodes); err == nil {
+				t.Error("NewGraph succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestNewGraphOrder(t *testing.T) {
+	resources := []Resource{testResource("a", "1", false), testResource("b", "1", false), testResource("c", "1", false)}
+	g, err := NewGraph(nil, resources, []*Node{
+		{Name: "last", Inputs: []string{"b"}, Outputs: []string{"c"}},
+		{Name: "middle", Inputs: []string{"a"}, Outputs: []string{"b"}},
+		{Name: "first", Outputs: []string{"a"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, n := range g.nodes {
+		names = append(names, n.Name)
+	}
+	if want := []string{"first", "middle", "last"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+}
+
+func TestStepsNeeds(t *testing.T) {
+	resources := []Resource{testResource("a", "1", false), testResource("b", "1", false), testResource("c", "1", false),
+		testResource("d", "1", false), testResource("external", "1", false)}
+	g, err := NewGraph(nil, resources, []*Node{
+		{Name: "first", Inputs: []string{"external"}, Outputs: []string{"a", "b"}},
+		{Name: "second", Inputs: []string{"a", "b"}, Outputs: []string{"c"}},
+		{Name: "third", Inputs: []string{"a", "c"}, Outputs: []string{"d"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]string{
+		"first":  nil,
+		"second": {"first"},
+		"third":  {"first", "second"},
+	}
+	for _, step := range g.Steps(true) {
+		t.Run(step.Name, func(t *testing.T) {
+			if want := tests[step.Name]; !reflect.DeepEqual(step.Needs, want) {
+				t.Errorf("Needs = %v, want %v", step.Needs, want)
+			}
+		})
+	}
+}
+
+func TestDecide(t *testing.T) {
+	recorded := &nodeState{Inputs: map[string]string{"in": "1"}, Outputs: map[string]string{"out": "1"}}
+	tests := []struct {
+		name           string
+		node           Node
+		in, out        string
+		empty          bool
+		recorded       *nodeState
+		includeSources bool
+		action, reason string
+	}{
+		{"seed empty", Node{Seed: true}, "1", "1", true, nil, true, ActionRun, "empty: out"},
+		{"seed populated", Node{Seed: true}, "1", "1", false, recorded, true, ActionSkip, "seeded"},
+		{"source not refreshed", Node{Source: true}, "2", "1", false, recorded, false, ActionSkip, "source not refreshed"},
+		{"source empty on refresh", Node{Source: true}, "1", "1", true, recorded, false, ActionRun, "empty: out"},
+		{"source refreshed", Node{Source: true}, "2", "1", false, recorded, true, ActionRun, "inputs changed: in"},
+		{"never run empty", Node{}, "1", "1", true, nil, true, ActionRun, "never run, empty: out"},
+		{"never run populated", Node{}, "1", "1", false, nil, true, ActionAdopt, "outputs already populated; recording current state"},
+		{"up to date", Node{}, "1", "1", false, recorded, true, ActionSkip, "up to date"},
+		{"inputs changed", Node{}, "2", "1", false, recorded, true, ActionRun, "inputs changed: in"},
+		{"outputs modified", Node{}, "1", "2", false, recorded, true, ActionRun, "outputs modified since last run: out"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := tt.node
+			node.Name, node.Inputs, node.Outputs, node.Run = "step", []string{"in"}, []string{"out"}, noop
+			resources := []Resource{testResource("in", tt.in, false), testResource("out", tt.out, tt.empty)}
+			database := openTestDatabase(t)
+			g, err := NewGraph(database, resources, []*Node{&node})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			action, reason, err := g.decide(&node, tt.recorded, tt.includeSources)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if action != tt.action || reason != tt.reason {
+				t.Errorf("got %s (%s), want %s (%s)", action, reason, tt.action, tt.reason)
+			}
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	state := func(in, out string) nodeState {
+		return nodeState{Inputs: map[string]string{in: "1"}, Outputs: map[string]string{out: "1"}}
+	}
+	tests := []struct {
+		name           string
+		source         string
+		recorded       map[string]nodeState
+		includeSources bool
+		want           []PlannedStep
+	}{
+		{
+			name:   "up to date",
+			source: "1",
+			recorded: map[string]nodeState{
+				"ingest": state("source", "raw"), "clean": state("raw", "clean"), "report": state("clean", "report"),
+			},
+			includeSources: true,
+			want: []PlannedStep{
+				{"ingest", ActionSkip, "up to date"},
+				{"clean", ActionSkip, "up to date"},
+				{"report", ActionSkip, "up to date"},
+			},
+		},
+		{
+			name:   "source changed",
+			source: "2",
+			recorded: map[string]nodeState{
+				"ingest": state("source", "raw"), "clean": state("raw", "clean"), "report": state("clean", "report"),
+			},
+			includeSources: true,
+			want: []PlannedStep{
+				{"ingest", ActionRun, "inputs changed: source"},
+				{"clean", ActionDownstream, "inputs produced by ingest"},
+				{"report", ActionDownstream, "inputs produced by clean"},
+			},
+		},
+		{
+			name:   "source left out of refresh",
+			source: "2",
+			recorded: map[string]nodeState{
+				"ingest": state("source", "raw"), "clean": state("raw", "clean"), "report": state("clean", "report"),
+			},
+			includeSources: false,
+			want: []PlannedStep{
+				{"ingest", ActionSkip, "source not refreshed"},
+				{"clean", ActionSkip, "up to date"},
+				{"report", ActionSkip, "up to date"},
+			},
+		},
+		{
+			name:   "never recorded below a step that runs",
+			source: "2",
+			recorded: map[string]nodeState{
+				"ingest": state("source", "raw"),
+			},
+			includeSources: true,
+			want: []PlannedStep{
+				{"ingest", ActionRun, "inputs changed: source"},
+				{"clean", ActionDownstream, "inputs produced by ingest"},
+				{"report", ActionDownstream, "inputs produced by clean"},
+			},
+		},
+		{
+			name:           "never recorded",
+			source:         "1",
+			includeSources: true,
+			want: []PlannedStep{
+				{"ingest", ActionAdopt, "outputs already populated; recording current state"},
+				{"clean", ActionAdopt, "outputs already populated; recording current state"},
+				{"report", ActionAdopt, "outputs already populated; recording current state"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := openTestDatabase(t)
+			for name, s := range tt.recorded {
+				if err := saveState(database, name, s); err != nil {
+					t.Fatal(err)
+				}
+			}
+			resources := []Resource{
+				testResource("source", tt.source, false),
+				testResource("raw", "1", false),
+				testResource("clean", "1", false),
+				testResource("report", "1", false),
+			}
+			g, err := NewGraph(database, resources, []*Node{
+				{Name: "report", Inputs: []string{"clean"}, Outputs: []string{"report"}, Run: noop},
+				{Name: "clean", Inputs: []string{"raw"}, Outputs: []string{"clean"}, Run: noop},
+				{Name: "ingest", Inputs: []string{"source"}, Outputs: []string{"raw"}, Run: noop, Source: true},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			plan, err := g.Plan(tt.includeSources)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(plan, tt.want) {
+				t.Errorf("got %v, want %v", plan, tt.want)
+			}
+		})
+	}
+}
+
+func TestStepsRunNeverRecordedDownstream(t *testing.T) {
+	database := openTestDatabase(t)
+	state := func(in, out string) nodeState {
+		return nodeState{Inputs: map[string]string{in: "1"}, Outputs: map[string]string{out: "1"}}
+	}
+	if err := saveState(database, "ingest", state("source", "raw")); err != nil {
+		t.Fatal(err)
+	}
+
+	ran := make(map[string]bool)
+	run := func(name string) func(context.Context) error {
+		return func(context.Context) error { ran[name] = true; return nil }
+	}
+	resources := []Resource{
+		testResource("source", "2", false),
+		testResource("raw", "1", false),
+		testResource("clean", "1", false),
+		testResource("other", "1", false),
+	}
+	g, err := NewGraph(database, resources, []*Node{
+		{Name: "ingest", Inputs: []string{"source"}, Outputs: []string{"raw"}, Run: run("ingest"), Source: true},
+		{Name: "clean", Inputs: []string{"raw"}, Outputs: []string{"clean"}, Run: run("clean")},
+		{Name: "other", Outputs: []string{"other"}, Run: run("other")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewScheduler(database)
+	if err := s.Register("job", "", g.Steps(true)...); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RunNow(context.Background(), "job", TriggerManual); err != nil {
+		t.Fatal(err)
+	}
+
+	// clean was never recorded, but its input was just rewritten, so its outputs are stale;
+	// other has no upstream step, so its existing outputs are adopted
+	if want := map[string]bool{"ingest": true, "clean": true}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+}
@@ -0,0 +1,114 @@
+package pipeline
+
+import (
+	"backend/pkg/db"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Resource is something a step reads or writes, with a way to tell whether it changed
+type Resource struct {
+	Name        string
+	Fingerprint func() (string, error)
+	// Query returns the rows of a table resource, which are fingerprinted instead of calling
+	// Fingerprint
+	Query string
+	// CountQuery returns the number of populated rows; resources without one are never empty
+	CountQuery string
+}
+
+func (r Resource) fingerprint(database *db.DB) (string, error) {
+	if r.Query != "" {
+		return queryFingerprint(database, r.Query)
+	}
+	return r.Fingerprint()
+}
+
+func (r Resource) isEmpty(database *db.DB) (bool, error) {
+	if r.CountQuery == "" {
+		return false, nil
+	}
+	count, err := r.count(database)
+	return count == 0, err
+}
+
+func (r Resource) count(database *db.DB) (int, error) {
+	var count int
+	if err := database.QueryRow(r.CountQuery).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// TableResource fingerprints the rows returned by query, which should have a stable ORDER BY
+func TableResource(name, query, countQuery string) Resource {
+	return Resource{Name: name, Query: query, CountQuery: countQuery}
+}
+
+// FileResource fingerprints a file's contents. The path is looked up on every fingerprint, as the
+// graph is built before the configuration naming the file is loaded.
+func FileResource(name string, path func() string) Resource {
+	return Resource{
+		Name: name,
+		Fingerprint: func() (string, error) {
+			f, err := os.Open(path())
+			if err != nil {
+				return "", err
+			}
+			defer f.Close()
+
+			h := sha256.New()
+			if _, err := io.Copy(h, f); err != nil {
+				return "", err
+			}
+			return hex.EncodeToString(h.Sum(nil)), nil
+		},
+	}
+}
+
+// ExternalResource fingerprints something outside our control, such as the latest day
+// a remote API has published
+func ExternalResource(name string, fingerprint func() (string, error)) Resource {
+	return Resource{Name: name, Fingerprint: fingerprint}
+}
+
+func queryFingerprint(database *db.DB, query string) (string, error) {
+	rows, err := database.Query(query)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	h := sha256.New()
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return "", err
+		}
+		for _, v := range values {
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			fmt.Fprintf(h, "%v\x1f", v)
+		}
+		h.Write([]byte{0x1e})
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
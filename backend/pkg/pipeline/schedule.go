@@ -0,0 +1,11 @@
+package pipeline
+
+import "backend/pkg/pipeline/cron"
+
+// Schedule is a parsed cron spec
+type Schedule = cron.Schedule
+
+// ParseSchedule parses a cron spec such as "30 2 * * *" or "@daily"
+func ParseSchedule(spec string) (*Schedule, error) {
+	return cron.Parse(spec)
+}
@@ -0,0 +1,488 @@
+package pipeline
+
+import (
+	"backend/pkg/db"
+	"backend/pkg/logging"
+	"backend/pkg/metrics"
+	structure "backend/pkg/struct"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Run and step statuses recorded in job_runs / job_run_steps
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped"
+	StatusCancelled = "cancelled"
+)
+
+// Run triggers
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+	TriggerStartup  = "startup"
+)
+
+var (
+	ErrJobRunning   = errors.New("another pipeline run is in progress")
+	ErrJobQueued    = errors.New("the job is queued behind the run in progress")
+	ErrUnknownJob   = errors.New("unknown job")
+	ErrShuttingDown = errors.New("the server is shutting down")
+)
+
+const timestampLayout = "2006-01-02 15:04:05"
+
+var (
+	jobRuns = metrics.NewCounterVec("solar_pipeline_runs_total",
+		"Finished pipeline runs by job and status.", "job", "status")
+	stepDuration = metrics.NewHistogramVec("solar_pipeline_step_duration_seconds",
+		"Time taken by pipeline steps, by job, step and status.",
+		[]float64{0.01, 0.1, 0.5, 1, 5, 15, 60, 300, 900, 1800}, "job", "step", "status")
+)
+
+// Step is a named unit of work in a job. Steps run in order. When a step fails, the steps that
+// need it, directly or through another step, are skipped and the rest still run.
+type Step struct {
+	Name string
+	Run  func(ctx context.Context) error
+	// Needs names the earlier steps whose output this step reads
+	Needs []string
+	// Rows optionally reports the row counts of what the step wrote, for the step log
+	Rows func() map[string]int
+}
+
+// Job is a chain of steps run on a cron schedule or on demand
+type Job struct {
+	Name     string
+	Spec     string
+	Steps    []Step
+	schedule *Schedule
+}
+
+// Scheduler runs registered jobs. All jobs share one lock because they rewrite the same
+// derived tables, so a run is never started while another is still in progress.
+type Scheduler struct {
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	order   []string
+	running string
+	// queued jobs start once the run in progress finishes
+	queued []string
+
+	runLock sync.Mutex
+	runs    sync.WaitGroup
+
+	// done is cancelled by Shutdown and stops every loop and run, whatever context started it
+	done     context.Context
+	shutdown context.CancelFunc
+
+	// db records the runs and their steps
+	db *db.DB
+}
+
+func NewScheduler(database *db.DB) *Scheduler {
+	done, shutdown := context.WithCancel(context.Background())
+	return &Scheduler{jobs: make(map[string]*Job), done: done, shutdown: shutdown, db: database}
+}
+
+// Register adds a job. spec is a cron spec; an empty spec registers a manual-only job.
+func (s *Scheduler) Register(name, spec string, steps ...Step) error {
+	job := &Job{Name: name, Spec: spec, Steps: steps}
+	if spec != "" {
+		schedule, err := ParseSchedule(spec)
+		if err != nil {
+			return fmt.Errorf("job %s: %v", name, err)
+		}
+		job.schedule = schedule
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.jobs[name]; exists {
+		return fmt.Errorf("job %s is already registered", name)
+	}
+	s.jobs[name] = job
+	s.order = append(s.order, name)
+	return nil
+}
+
+// Jobs lists the registered jobs in registration order
+func (s *Scheduler) Jobs() []structure.JobInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	infos := make([]structure.JobInfo, 0, len(s.order))
+	for _, name := range s.order {
+		job := s.jobs[name]
+		info := structure.JobInfo{
+			Name:    job.Name,
+			Spec:    job.Spec,
+			Running: s.running == job.Name,
+			Steps:   make([]string, 0, len(job.Steps)),
+		}
+		if job.schedule != nil {
+			if next := job.schedule.Next(now); !next.IsZero() {
+				info.NextRun = next.UTC().Format(time.RFC3339)
+			}
+		}
+		for _, step := range job.Steps {
+			info.Steps = append(info.Steps, step.Name)
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// Start launches one timer loop per scheduled job. The loops stop when ctx is cancelled.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, name := range s.order {
+		job := s.jobs[name]
+		if job.schedule == nil {
+			continue
+		}
+		go s.loop(ctx, job)
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, job *Job) {
+	for {
+		next := job.schedule.Next(time.Now())
+		if next.IsZero() {
+			slog.Info("Job has no upcoming run for spec", "job", job.Name, "spec", job.Spec)
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.done.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		runID, err := s.begin(job, TriggerSchedule, false)
+		if err != nil {
+			slog.Error("Skipping scheduled run", "job", job.Name, "err", err)
+			s.recordSkippedRun(job.Name, err)
+			continue
+		}
+		s.execute(ctx, job, runID)
+	}
+}
+
+// Trigger starts a manual run in the background and returns its run ID
+func (s *Scheduler) Trigger(ctx context.Context, name string) (int64, error) {
+	return s.trigger(ctx, name, false)
+}
+
+// Queue starts a manual run like Trigger. When another run is in progress the job is queued
+// instead, returning ErrJobQueued, and starts once that run finishes, so a change made during a
+// run is always picked up. A job queued several times runs once.
+func (s *Scheduler) Queue(ctx context.Context, name string) (int64, error) {
+	return s.trigger(ctx, name, true)
+}
+
+func (s *Scheduler) trigger(ctx context.Context, name string, queue bool) (int64, error) {
+	job, err := s.job(name)
+	if err != nil {
+		return 0, err
+	}
+
+	runID, err := s.begin(job, TriggerManual, queue)
+	if err != nil {
+		return 0, err
+	}
+
+	go s.execute(ctx, job, runID)
+	return runID, nil
+}
+
+// RunNow runs a job in the foreground and returns the first step error
+func (s *Scheduler) RunNow(ctx context.Context, name, trigger string) (int64, error) {
+	job, err := s.job(name)
+	if err != nil {
+		return 0, err
+	}
+
+	runID, err := s.begin(job, trigger, false)
+	if err != nil {
+		return 0, err
+	}
+
+	return runID, s.execute(ctx, job, runID)
+}
+
+func (s *Scheduler) job(name string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownJob, name)
+	}
+	return job, nil
+}
+
+// Shutdown stops the timer loops and cancels the running job, then waits for the run to end or
+// for ctx to expire. The step in progress sees the cancellation at its next statement or request
+// and rolls back the transaction it is in, so every table keeps its last committed state. A step
+// that writes in several transactions may have committed some of them; it is not recorded as run,
+// so it reruns with the remaining steps on the next run. When ctx expires first, the step may
+// still be running: call Wait before closing the database.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.shutdown()
+	s.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		s.runs.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("error waiting for job %s to stop: %w", s.Running(), ctx.Err())
+	}
+}
+
+// Wait blocks until the run in progress, if any, has ended
+func (s *Scheduler) Wait() {
+	s.runs.Wait()
+}
+
+// Running is the name of the job in progress, if any
+func (s *Scheduler) Running() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+// begin takes the run lock and records the run. The lock is released by execute. With queue, a
+// job that finds another run in progress is queued behind it.
+func (s *Scheduler) begin(job *Job, trigger string, queue bool) (int64, error) {
+	// The run lock is only taken and released under mu, so a job queued here is always seen by
+	// the run in progress when it finishes
+	s.mu.Lock()
+	if s.done.Err() != nil {
+		s.mu.Unlock()
+		return 0, ErrShuttingDown
+	}
+	if !s.runLock.TryLock() {
+		defer s.mu.Unlock()
+		if !queue {
+			return 0, ErrJobRunning
+		}
+		if !slices.Contains(s.queued, job.Name) {
+			s.queued = append(s.queued, job.Name)
+		}
+		return 0, ErrJobQueued
+	}
+	// Shutdown cancels under mu, so it waits for every run that got this far
+	s.running = job.Name
+	s.runs.Add(1)
+	s.mu.Unlock()
+
+	var runID int64
+	err := s.db.QueryRow(`
+		INSERT INTO job_runs (job_name, trigger, status, started_at)
+		VALUES (?, ?, ?, ?)
+		RETURNING id
+	`, job.Name, trigger, StatusRunning, now()).Scan(&runID)
+	if err != nil {
+		s.release()
+		return 0, fmt.Errorf("error recording job run: %v", err)
+	}
+	return runID, nil
+}
+
+// release ends the run in progress and starts the jobs queued behind it
+func (s *Scheduler) release() {
+	s.mu.Lock()
+	s.running = ""
+	queued := s.queued
+	s.queued = nil
+	s.runLock.Unlock()
+	s.mu.Unlock()
+	s.runs.Done()
+
+	for _, name := range queued {
+		if _, err := s.Queue(context.Background(), name); err != nil && !errors.Is(err, ErrJobQueued) && !errors.Is(err, ErrShuttingDown) {
+			slog.Error("Error starting queued job", "job", name, "err", err)
+		}
+	}
+}
+
+func (s *Scheduler) execute(ctx context.Context, job *Job, runID int64) error {
+	defer s.release()
+
+	logger := logging.FromContext(ctx).With("job", job.Name, "run_id", runID)
+	ctx, cancel := context.WithCancel(withJobRun(logging.WithLogger(ctx, logger)))
+	defer cancel()
+	stop := context.AfterFunc(s.done, cancel)
+	defer stop()
+	if s.done.Err() != nil {
+		// A run begun just before Shutdown records as cancelled without running a step
+		cancel()
+	}
+
+	logger.Info("Starting job")
+	started := time.Now()
+
+	// failed maps each failed step, and each step skipped because of one, to the failed step
+	failed := make(map[string]string)
+	var failures []error
+
+	for _, step := range job.Steps {
+		if err := ctx.Err(); err != nil {
+			s.finishRun(runID, StatusCancelled, err)
+			jobRuns.Inc(job.Name, StatusCancelled)
+			logger.Info("Job cancelled", "before_step", step.Name)
+			return err
+		}
+
+		stepID, err := s.startStep(runID, step.Name)
+		if err != nil {
+			s.finishRun(runID, StatusFailed, err)
+			return err
+		}
+
+		if upstream := failedUpstream(step, failed); upstream != "" {
+			failed[step.Name] = upstream
+			s.finishStep(stepID, StatusSkipped, fmt.Errorf("upstream step %s failed", upstream))
+			logger.Info("Step finished", "step", step.Name, "status", StatusSkipped, "failed_upstream", upstream)
+			continue
+		}
+
+		stepStarted := time.Now()
+		err = step.Run(ctx)
+		if errors.Is(err, ErrUpToDate) {
+			s.finishStep(stepID, StatusSkipped, nil)
+			stepDuration.Observe(time.Since(stepStarted).Seconds(), job.Name, step.Name, StatusSkipped)
+			logger.Debug("Step finished", "step", step.Name, "status", StatusSkipped, "duration_ms", time.Since(stepStarted).Milliseconds())
+			continue
+		}
+		if err != nil {
+			status := StatusFailed
+			if ctx.Err() != nil {
+				status = StatusCancelled
+			}
+			s.finishStep(stepID, status, err)
+			stepDuration.Observe(time.Since(stepStarted).Seconds(), job.Name, step.Name, status)
+			logger.Error("Step finished", "step", step.Name, "status", status, "duration_ms", time.Since(stepStarted).Milliseconds(), "err", err)
+			if status == StatusCancelled {
+				s.finishRun(runID, status, errors.Join(append(failures, fmt.Errorf("step %s: %w", step.Name, err))...))
+				jobRuns.Inc(job.Name, status)
+				logger.Error("Job finished", "status", status, "duration_ms", time.Since(started).Milliseconds())
+				return err
+			}
+
+			// What the step wrote last time stays in place, and the steps that do not need it still run
+			failed[step.Name] = step.Name
+			failures = append(failures, fmt.Errorf("step %s: %w", step.Name, err))
+			continue
+		}
+
+		s.finishStep(stepID, StatusSucceeded, nil)
+		stepDuration.Observe(time.Since(stepStarted).Seconds(), job.Name, step.Name, StatusSucceeded)
+		attrs := []any{"step", step.Name, "status", StatusSucceeded, "duration_ms", time.Since(stepStarted).Milliseconds()}
+		if step.Rows != nil {
+			attrs = append(attrs, "rows", step.Rows())
+		}
+		logger.Info("Step finished", attrs...)
+	}
+
+	if len(failures) > 0 {
+		err := errors.Join(failures...)
+		s.finishRun(runID, StatusFailed, err)
+		jobRuns.Inc(job.Name, StatusFailed)
+		logger.Error("Job finished", "status", StatusFailed, "duration_ms", time.Since(started).Milliseconds())
+		return err
+	}
+
+	s.finishRun(runID, StatusSucceeded, nil)
+	jobRuns.Inc(job.Name, StatusSucceeded)
+	logger.Info("Job finished", "status", StatusSucceeded, "duration_ms", time.Since(started).Milliseconds())
+	return nil
+}
+
+// failedUpstream returns the failed step that step needs, directly or through a skipped step, if any
+func failedUpstream(step Step, failed map[string]string) string {
+	for _, name := range step.Needs {
+		if upstream, ok := failed[name]; ok {
+			return upstream
+		}
+	}
+	return ""
+}
+
+func now() string {
+	return time.Now().UTC().Format(timestampLayout)
+}
+
+func errorText(err error) interface{} {
+	if err == nil {
+		return nil
+	}
+	return err.Error()
+}
+
+func (s *Scheduler) recordSkippedRun(jobName string, reason error) {
+	timestamp := now()
+	_, err := s.db.Exec(`
+		INSERT INTO job_runs (job_name, trigger, status, started_at, finished_at, error_message)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, jobName, TriggerSchedule, StatusSkipped, timestamp, timestamp, reason.Error())
+	if err != nil {
+		slog.Error("Error recording skipped run", "job", jobName, "err", err)
+	}
+}
+
+func (s *Scheduler) finishRun(runID int64, status string, runErr error) {
+	_, err := s.db.Exec(`
+		UPDATE job_runs SET status = ?, finished_at = ?, error_message = ?
+		WHERE id = ?
+	`, status, now(), errorText(runErr), runID)
+	if err != nil {
+		slog.Error("Error updating job run", "run_id", runID, "err", err)
+	}
+}
+
+func (s *Scheduler) startStep(runID int64, name string) (int64, error) {
+	var stepID int64
+	err := s.db.QueryRow(`
+		INSERT INTO job_run_steps (run_id, step_name, status, started_at)
+		VALUES (?, ?, ?, ?)
+		RETURNING id
+	`, runID, name, StatusRunning, now()).Scan(&stepID)
+	if err != nil {
+		return 0, fmt.Errorf("error recording step %s: %v", name, err)
+	}
+	return stepID, nil
+}
+
+func (s *Scheduler) finishStep(stepID int64, status string, stepErr error) {
+	_, err := s.db.Exec(`
+		UPDATE job_run_steps SET status = ?, finished_at = ?, error_message = ?
+		WHERE id = ?
+	`, status, now(), errorText(stepErr), stepID)
+	if err != nil {
+		slog.Error("Error updating job step", "step_id", stepID, "err", err)
+	}
+}
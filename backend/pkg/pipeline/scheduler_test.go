@@ -0,0 +1,288 @@
+package pipeline
+
+import (
+	"backend/pkg/db"
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// openTestDatabase opens a fresh database in a temporary directory
+func openTestDatabase(t *testing.T) *db.DB {
+	t.Helper()
+
+	database, err := db.Open(filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+type recordedStep struct {
+	Name, Status string
+}
+
+func recordedSteps(t *testing.T, database *db.DB, runID int64) (string, []recordedStep) {
+	t.Helper()
+
+	var status string
+	if err := database.QueryRow(`SELECT status FROM job_runs WHERE id = ?`, runID).Scan(&status); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := database.Query(`SELECT step_name, status FROM job_run_steps WHERE run_id = ? ORDER BY id`, runID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	steps := []recordedStep{}
+	for rows.Next() {
+		var step recordedStep
+		if err := rows.Scan(&step.Name, &step.Status); err != nil {
+			t.Fatal(err)
+		}
+		steps = append(steps, step)
+	}
+	return status, steps
+}
+
+func TestRunNow(t *testing.T) {
+	failure := errors.New("model failed")
+	succeed := func(ctx context.Context) error { return nil }
+	fail := func(ctx context.Context) error { return failure }
+
+	tests := []struct {
+		name   string
+		steps  []Step
+		err    error
+		status string
+		want   []recordedStep
+	}{
+		{"every step succeeds", []Step{{Name: "a", Run: succeed}, {Name: "b", Run: succeed}}, nil, StatusSucceeded,
+			[]recordedStep{{"a", StatusSucceeded}, {"b", StatusSucceeded}}},
+		{"independent steps run after a failure", []Step{{Name: "a", Run: succeed}, {Name: "b", Run: fail}, {Name: "c", Run: succeed}}, failure, StatusFailed,
+			[]recordedStep{{"a", StatusSucceeded}, {"b", StatusFailed}, {"c", StatusSucceeded}}},
+		{"steps needing a failed step are skipped",
+			[]Step{
+				{Name: "model", Run: fail},
+				{Name: "publish", Needs: []string{"model"}, Run: succeed},
+				{Name: "report", Needs: []string{"publish"}, Run: succeed},
+				{Name: "performance", Run: succeed},
+				{Name: "summary", Needs: []string{"performance"}, Run: succeed},
+			}, failure, StatusFailed,
+			[]recordedStep{{"model", StatusFailed}, {"publish", StatusSkipped}, {"report", StatusSkipped},
+				{"performance", StatusSucceeded}, {"summary", StatusSucceeded}}},
+		{"every failure is reported", []Step{{Name: "a", Run: fail}, {Name: "b", Run: fail}}, failure, StatusFailed,
+			[]recordedStep{{"a", StatusFailed}, {"b", StatusFailed}}},
+		{"no steps", nil, nil, StatusSucceeded, []recordedStep{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := openTestDatabase(t)
+
+			s := NewScheduler(database)
+			if err := s.Register("job", "", tt.steps...); err != nil {
+				t.Fatal(err)
+			}
+
+			runID, err := s.RunNow(context.Background(), "job", TriggerManual)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("RunNow() = %v, want %v", err, tt.err)
+			}
+
+			status, steps := recordedSteps(t, database, runID)
+			if status != tt.status {
+				t.Errorf("run status = %s, want %s", status, tt.status)
+			}
+			if !reflect.DeepEqual(steps, tt.want) {
+				t.Errorf("steps = %v, want %v", steps, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunNowCancelled(t *testing.T) {
+	database := openTestDatabase(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := NewScheduler(database)
+	s.Register("job", "",
+		Step{Name: "a", Run: func(context.Context) error { cancel(); return nil }},
+		Step{Name: "b", Run: func(context.Context) error { t.Error("step b ran after cancellation"); return nil }},
+	)
+
+	runID, err := s.RunNow(ctx, "job", TriggerManual)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("RunNow() = %v, want %v", err, context.Canceled)
+	}
+	if status, _ := recordedSteps(t, database, runID); status != StatusCancelled {
+		t.Errorf("run status = %s, want %s", status, StatusCancelled)
+	}
+}
+
+func TestRunNowWhileRunning(t *testing.T) {
+	database := openTestDatabase(t)
+
+	s := NewScheduler(database)
+	started, release := make(chan struct{}), make(chan struct{})
+	s.Register("slow", "", Step{Name: "wait", Run: func(context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}})
+	s.Register("other", "")
+
+	if _, err := s.Trigger(context.Background(), "slow"); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	// Every job shares the run lock
+	if _, err := s.RunNow(context.Background(), "other", TriggerManual); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("RunNow() while a job runs = %v, want %v", err, ErrJobRunning)
+	}
+
+	// Let the slow run finish recording before the database goes away
+	close(release)
+	for !s.runLock.TryLock() {
+		time.Sleep(time.Millisecond)
+	}
+	s.runLock.Unlock()
+}
+
+func TestQueue(t *testing.T) {
+	database := openTestDatabase(t)
+
+	s := NewScheduler(database)
+	started, release := make(chan struct{}), make(chan struct{})
+	s.Register("slow", "", Step{Name: "wait", Run: func(context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}})
+	refreshed := make(chan struct{}, 2)
+	s.Register("refresh", "", Step{Name: "refresh", Run: func(context.Context) error {
+		refreshed <- struct{}{}
+		return nil
+	}})
+
+	if _, err := s.Queue(context.Background(), "slow"); err != nil {
+		t.Fatalf("Queue() with no run in progress = %v", err)
+	}
+	<-started
+
+	// A job queued twice behind the run in progress runs once after it
+	for i := 0; i < 2; i++ {
+		if _, err := s.Queue(context.Background(), "refresh"); !errors.Is(err, ErrJobQueued) {
+			t.Fatalf("Queue() while a job runs = %v, want %v", err, ErrJobQueued)
+		}
+	}
+	close(release)
+
+	select {
+	case <-refreshed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued job did not run after the run in progress")
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var runs int
+	if err := database.QueryRow(`SELECT COUNT(*) FROM job_runs WHERE job_name = 'refresh'`).Scan(&runs); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 1 {
+		t.Errorf("queued job ran %d times, want 1", runs)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		ok   bool
+	}{
+		{"manual only", "", true},
+		{"cron spec", "30 2 * * *", true},
+		{"descriptor", "@daily", true},
+		{"invalid spec", "every day", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := NewScheduler(nil).Register("job", tt.spec); (err == nil) != tt.ok {
+				t.Errorf("Register(%q) = %v, want ok %v", tt.spec, err, tt.ok)
+			}
+		})
+	}
+
+	s := NewScheduler(nil)
+	s.Register("job", "")
+	if err := s.Register("job", ""); err == nil {
+		t.Error("registering a job twice succeeded")
+	}
+	if _, err := s.RunNow(context.Background(), "missing", TriggerManual); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("RunNow() of an unknown job = %v, want %v", err, ErrUnknownJob)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	tests := []struct {
+		name string
+		// step runs until released; a step that ignores its context outlives the shutdown timeout
+		ignoresContext bool
+		err            error
+	}{
+		{"running job is cancelled", false, nil},
+		{"job outlives the timeout", true, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := openTestDatabase(t)
+
+			s := NewScheduler(database)
+			started, release := make(chan struct{}), make(chan struct{})
+			s.Register("slow", "",
+				Step{Name: "wait", Run: func(ctx context.Context) error {
+					close(started)
+					if tt.ignoresContext {
+						<-release
+						return nil
+					}
+					<-ctx.Done()
+					return ctx.Err()
+				}},
+				Step{Name: "after", Run: func(context.Context) error { return nil }},
+			)
+
+			runID, err := s.Trigger(context.Background(), "slow")
+			if err != nil {
+				t.Fatal(err)
+			}
+			<-started
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			if err := s.Shutdown(ctx); !errors.Is(err, tt.err) {
+				t.Errorf("Shutdown() = %v, want %v", err, tt.err)
+			}
+			if _, err := s.RunNow(context.Background(), "slow", TriggerManual); !errors.Is(err, ErrShuttingDown) {
+				t.Errorf("RunNow() after Shutdown() = %v, want %v", err, ErrShuttingDown)
+			}
+
+			// Let the run finish recording before the database goes away
+			close(release)
+			s.Wait()
+
+			// Either way the step after the one running is never started
+			if status, _ := recordedSteps(t, database, runID); status != StatusCancelled {
+				t.Errorf("run status = %s, want %s", status, StatusCancelled)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package pipeline
+
+import (
+	"backend/pkg/db"
+	"encoding/json"
+	"fmt"
+)
+
+// nodeState is the fingerprint of a step's inputs and outputs after its last successful run
+type nodeState struct {
+	Inputs  map[string]string
+	Outputs map[string]string
+}
+
+func loadState(database *db.DB) (map[string]*nodeState, error) {
+	rows, err := database.Query(`SELECT step_name, input_hashes, output_hashes FROM pipeline_state`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying pipeline state: %v", err)
+	}
+	defer rows.Close()
+
+	state := make(map[string]*nodeState)
+	for rows.Next() {
+		var name, inputs, outputs string
+		if err := rows.Scan(&name, &inputs, &outputs); err != nil {
+			return nil, fmt.Errorf("error scanning pipeline state: %v", err)
+		}
+
+		s := &nodeState{}
+		if err := json.Unmarshal([]byte(inputs), &s.Inputs); err != nil {
+			return nil, fmt.Errorf("error decoding input hashes for %s: %v", name, err)
+		}
+		if err := json.Unmarshal([]byte(outputs), &s.Outputs); err != nil {
+			return nil, fmt.Errorf("error decoding output hashes for %s: %v", name, err)
+		}
+		state[name] = s
+	}
+
+	return state, rows.Err()
+}
+
+func saveState(database *db.DB, name string, s nodeState) error {
+	inputs, err := json.Marshal(s.Inputs)
+	if err != nil {
+		return err
+	}
+	outputs, err := json.Marshal(s.Outputs)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Exec(`
+		INSERT INTO pipeline_state (step_name, input_hashes, output_hashes, last_run_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (step_name) DO UPDATE SET
+			input_hashes = excluded.input_hashes,
+			output_hashes = excluded.output_hashes,
+			last_run_at = excluded.last_run_at
+	`, name, string(inputs), string(outputs), now())
+	if err != nil {
+		return fmt.Errorf("error saving pipeline state for %s: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+package pipeline
+
+import (
+	"backend/pkg/audit"
+	"backend/pkg/calculation"
+	"backend/pkg/config"
+	"backend/pkg/data"
+	"backend/pkg/db"
+	"backend/pkg/metrics"
+	"backend/pkg/quality"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	// RecomputeJob pulls new source data and reruns every stale step
+	RecomputeJob = "recompute"
+	// RefreshJob reruns stale derived steps without pulling from external sources
+	RefreshJob = "refresh"
+)
+
+// NewRecompute returns the dependency graph of every table the server derives in database, whose
+// steps run on loader, checker and calculator. The source files it watches are those in files.
+func NewRecompute(database *db.DB, files config.DataConfig, loader *data.Loader, checker *quality.Checker, calculator *calculation.Calculator) *Graph {
+	return mustGraph(database, recomputeResources(files, loader), recomputeNodes(database, loader, checker, calculator))
+}
+
+func recomputeResources(files config.DataConfig, loader *data.Loader) []Resource {
+	return []Resource{
+		ExternalResource("weather_archive", func() (string, error) {
+			return loader.LatestArchiveDay().Format("2006-01-02"), nil
+		}),
+		ExternalResource("outlook_issue", func() (string, error) {
+			// Outlooks are reissued daily
+			return time.Now().UTC().Format("2006-01-02"), nil
+		}),
+		FileResource("energy_workbook", func() string { return files.EnergyWorkbook }),
+		FileResource("energy_workbook_mapping", func() string { return files.WorkbookMapping }),
+		FileResource("imputation_config", func() string { return files.Imputation }),
+		TableResource("generation_imports",
+			`SELECT year, month, location_id, actual_kwh FROM monthly_generation_imports ORDER BY year, month, location_id`,
+			`SELECT COUNT(*) FROM monthly_generation_imports`),
+		TableResource("weather_daily",
+			`SELECT * FROM weather_daily ORDER BY date`,
+			`SELECT COUNT(*) FROM weather_daily`),
+		quarantineResource("weather_quarantine", `dataset = 'weather_daily'`),
+		quarantineResource("generation_quarantine", `dataset <> 'weather_daily'`),
+		gapResource("weather_gaps", `dataset = 'weather_daily'`),
+		gapResource("generation_gaps", `dataset <> 'weather_daily'`),
+		TableResource("weather_monthly",
+			`SELECT * FROM weather_monthly ORDER BY year, month`,
+			`SELECT COUNT(*) FROM weather_monthly`),
+		TableResource("weather_outlook",
+			`SELECT source, issued_at, COUNT(*) FROM weather_outlook GROUP BY source, issued_at ORDER BY source, issued_at`,
+			`SELECT COUNT(*) FROM weather_outlook`),
+		TableResource("forecast_outlook",
+			`SELECT source, issued_at, COUNT(*), SUM(predicted_kwh) FROM forecast_outlook GROUP BY source, issued_at ORDER BY source, issued_at`,
+			`SELECT COUNT(*) FROM forecast_outlook`),
+		TableResource("locations",
+			`SELECT id, name, installed_capacity_kw, number_of_panels FROM locations ORDER BY id`,
+			`SELECT COUNT(*) FROM locations`),
+		TableResource("assets",
+			`SELECT id, location_id, parent_id, kind, code, capacity_kw FROM assets ORDER BY id`,
+			`SELECT COUNT(*) FROM assets`),
+		TableResource("asset_generation",
+			`SELECT 'month', year || '-' || month, asset_id, actual_kwh FROM asset_monthly_generation
+			UNION ALL
+			SELECT 'day', CAST(date AS TEXT), asset_id, actual_kwh || '/' || producing_intervals || '/' || daylight_intervals FROM asset_daily_generation
+			ORDER BY 1, 2, 3`,
+			`SELECT (SELECT COUNT(*) FROM asset_monthly_generation) + (SELECT COUNT(*) FROM asset_daily_generation)`),
+		TableResource("asset_performance",
+			`SELECT 'month', year || '-' || month, asset_id, performance_ratio, availability FROM asset_monthly_performance
+			UNION ALL
+			SELECT 'day', CAST(date AS TEXT), asset_id, performance_ratio, availability FROM asset_daily_performance
+			ORDER BY 1, 2, 3`,
+			`SELECT (SELECT COUNT(*) FROM asset_monthly_performance) + (SELECT COUNT(*) FROM asset_daily_performance)`),
+		generationResource("generation_actual", "actual_kwh"),
+		generationResource("generation_theoretical", "theoretical_kwh"),
+		generationResource("generation_predicted", "predicted_kwh"),
+		TableResource("generation_interval",
+			`SELECT interval_start, location_id, inverter, interval_minutes, energy_kwh FROM interval_generation ORDER BY interval_start, location_id, inverter`,
+			`SELECT COUNT(*) FROM interval_generation`),
+		dailyGenerationResource("generation_daily", "actual_kwh"),
+		dailyGenerationResource("generation_daily_theoretical", "theoretical_kwh"),
+		dailyGenerationResource("generation_daily_predicted", "predicted_kwh"),
+		TableResource("daily_performance",
+			`SELECT date, location_id, performance_ratio, capacity_factor, output_per_pv FROM daily_performance ORDER BY date, location_id`,
+			`SELECT COUNT(*) FROM daily_performance`),
+		TableResource("forecast_quantiles",
+			`SELECT year, month, location_id, p10_kwh, p50_kwh, p90_kwh FROM forecast_quantiles ORDER BY year, month, location_id`,
+			`SELECT COUNT(*) FROM forecast_quantiles`),
+		TableResource("forecast_calibration",
+			`SELECT location_id, interval_coverage, below_p10, below_p50, below_p90, sample_count FROM forecast_calibration ORDER BY location_id`,
+			`SELECT COUNT(*) FROM forecast_calibration`),
+		TableResource("forecast_explanations",
+			`SELECT year, month, location_id, feature_name, contribution_kwh FROM forecast_explanations ORDER BY year, month, location_id, feature_name`,
+			`SELECT COUNT(*) FROM forecast_explanations`),
+		TableResource("feature_importance",
+			`SELECT feature_name, importance_value FROM feature_importance ORDER BY feature_name`,
+			`SELECT COUNT(*) FROM feature_importance`),
+		TableResource("monthly_performance",
+			`SELECT year, month, location_id, performance_ratio, capacity_factor, output_per_pv FROM monthly_performance ORDER BY year, month, location_id`,
+			`SELECT COUNT(*) FROM monthly_performance`),
+		TableResource("yearly_performance",
+			`SELECT year, location_id, performance_ratio, capacity_factor, output_per_pv FROM yearly_performance ORDER BY year, location_id`,
+			`SELECT COUNT(*) FROM yearly_performance`),
+		TableResource("overall_performance",
+			`SELECT start_year, end_year, location_id, performance_ratio, capacity_factor, output_per_pv FROM overall_performance ORDER BY location_id`,
+			`SELECT COUNT(*) FROM overall_performance`),
+	}
+}
+
+// generationResource tracks one value column of monthly_generation, which several steps share
+func generationResource(name, column string) Resource {
+	return TableResource(name,
+		fmt.Sprintf(`SELECT year, month, location_id, %s FROM monthly_generation WHERE %s IS NOT NULL ORDER BY year, month, location_id`, column, column),
+		fmt.Sprintf(`SELECT COUNT(*) FROM monthly_generation WHERE %s IS NOT NULL`, column))
+}
+
+// quarantineResource tracks the quarantine entries of some datasets with their review status, so a
+// review reruns the steps that leave quarantined rows out
+func quarantineResource(name, where string) Resource {
+	return TableResource(name,
+		fmt.Sprintf(`SELECT dataset, location_id, period, rule, status FROM quarantine WHERE %s ORDER BY dataset, location_id, period, rule`, where),
+		fmt.Sprintf(`SELECT COUNT(*) FROM quarantine WHERE %s`, where))
+}
+
+// gapResource tracks the gaps of some datasets with their imputed values
+func gapResource(name, where string) Resource {
+	return TableResource(name,
+		fmt.Sprintf(`SELECT dataset, location_id, period, column_name, method, value FROM gaps WHERE %s ORDER BY dataset, location_id, period, column_name`, where),
+		fmt.Sprintf(`SELECT COUNT(*) FROM gaps WHERE %s`, where))
+}
+
+// dailyGenerationResource tracks one value column of daily_generation
+func dailyGenerationResource(name, column string) Resource {
+	return TableResource(name,
+		fmt.Sprintf(`SELECT date, location_id, %s FROM daily_generation WHERE %s IS NOT NULL ORDER BY date, location_id`, column, column),
+		fmt.Sprintf(`SELECT COUNT(*) FROM daily_generation WHERE %s IS NOT NULL`, column))
+}
+
+func recomputeNodes(database *db.DB, loader *data.Loader, checker *quality.Checker, calculator *calculation.Calculator) []*Node {
+	performanceInputs := []string{"generation_actual", "generation_theoretical", "generation_quarantine", "generation_gaps", "locations"}
+
+	return []*Node{
+		{
+			Name:    "ingest_weather",
+			Source:  true,
+			Inputs:  []string{"weather_archive"},
+			Outputs: []string{"weather_daily"},
+			Run: func(ctx context.Context) error {
+				err := loader.FetchLatestWeatherData(ctx)
+				metrics.RecordIngest("weather_archive", err)
+				return err
+			},
+		},
+		{
+			Name:    "aggregate_weather",
+			Inputs:  []string{"weather_daily", "weather_quarantine"},
+			Outputs: []string{"weather_monthly"},
+			Run:     func(ctx context.Context) error { return loader.InsertMonthlyWeatherData(ctx) },
+		},
+		{
+			Name:    "ingest_weather_outlook",
+			Source:  true,
+			Inputs:  []string{"outlook_issue"},
+			Outputs: []string{"weather_outlook"},
+			Run: func(ctx context.Context) error {
+				// The forecast still runs without an outlook, so an unavailable outlook API
+				// is logged rather than failing the whole job
+				seasonal, forecast := loader.FetchSeasonalOutlook(ctx), loader.FetchForecastOutlook(ctx)
+				metrics.RecordIngest("seasonal_outlook", seasonal)
+				metrics.RecordIngest("forecast_outlook", forecast)
+				if err := errors.Join(seasonal, forecast); err != nil {
+					slog.Error("Error fetching weather outlook", "err", err)
+				}
+				return nil
+			},
+		},
+		{
+			Name:    "seed_locations",
+			Seed:    true,
+			Outputs: []string{"locations"},
+			Run: func(ctx context.Context) error {
+				if err := loader.InitializeLocations(ctx); err != nil {
+					return err
+				}
+				return audit.RecordNow(ctx, database, audit.Change{Author: "pipeline", Reason: "location seed"})
+			},
+		},
+		{
+			Name:    "sync_assets",
+			Inputs:  []string{"locations", "energy_workbook", "energy_workbook_mapping", "generation_interval"},
+			Outputs: []string{"assets"},
+			Run:     func(ctx context.Context) error { return loader.SyncAssets(ctx) },
+		},
+		{
+			Name:    "import_asset_generation",
+			Inputs:  []string{"assets", "energy_workbook", "energy_workbook_mapping", "generation_interval"},
+			Outputs: []string{"asset_generation"},
+			Run:     func(ctx context.Context) error { return loader.ImportAssetGeneration(ctx) },
+		},
+		{
+			Name: "asset_performance",
+			Inputs: []string{"assets", "asset_generation", "generation_actual", "generation_theoretical",
+				"generation_daily", "generation_daily_theoretical", "generation_quarantine"},
+			Outputs: []string{"asset_performance"},
+			Run:     func(ctx context.Context) error { return calculator.CalculateAssetPerformance() },
+		},
+		{
+			Name:    "aggregate_intervals",
+			Inputs:  []string{"generation_interval"},
+			Outputs: []string{"generation_daily"},
+			Run:     func(ctx context.Context) error { return loader.AggregateIntervalGeneration(ctx) },
+		},
+		{
+			// Monthly actuals come from the workbook, overridden by daily totals for fully covered months
+			Name:    "import_generation",
+			Inputs:  []string{"energy_workbook", "energy_workbook_mapping", "generation_imports", "generation_daily"},
+			Outputs: []string{"generation_actual"},
+			Run: func(ctx context.Context) error {
+				err := loader.ImportEnergyData(ctx)
+				metrics.RecordIngest("energy_workbook", err)
+				if err != nil {
+					return err
+				}
+				if err := loader.AggregateMonthlyGeneration(ctx); err != nil {
+					return err
+				}
+				return audit.RecordNow(ctx, database, audit.Change{Author: "pipeline", Reason: "energy workbook and daily totals"})
+			},
+		},
+		{
+			Name:    "check_weather_quality",
+			Inputs:  []string{"weather_daily"},
+			Outputs: []string{"weather_quarantine"},
+			Run:     func(ctx context.Context) error { return checker.CheckWeather(ctx) },
+		},
+		{
+			// Quarantined weather is excluded from the theoretical output, so the generation checks see
+			// the theoretical output that is actually used
+			Name: "check_generation_quality",
+			Inputs: []string{"generation_actual", "generation_theoretical", "generation_daily",
+				"generation_daily_theoretical", "locations"},
+			Outputs: []string{"generation_quarantine"},
+			Run:     func(ctx context.Context) error { return checker.CheckGeneration(ctx) },
+		},
+		{
+			Name:    "fill_weather_gaps",
+			Inputs:  []string{"weather_daily", "weather_quarantine", "imputation_config"},
+			Outputs: []string{"weather_gaps"},
+			Run:     func(ctx context.Context) error { return checker.FillWeatherGaps(ctx) },
+		},
+		{
+			Name: "fill_generation_gaps",
+			Inputs: []string{"generation_actual", "generation_theoretical", "generation_daily",
+				"generation_daily_theoretical", "generation_quarantine", "locations", "imputation_config"},
+			Outputs: []string{"generation_gaps"},
+			Run:     func(ctx context.Context) error { return checker.FillGenerationGaps(ctx) },
+		},
+		{
+			Name:    "daily_theoretical_output",
+			Inputs:  []string{"weather_daily", "weather_quarantine", "weather_gaps", "locations", "generation_daily"},
+			Outputs: []string{"generation_daily_theoretical"},
+			Run:     func(ctx context.Context) error { return calculator.CalculateDailyTheoreticalOutput() },
+		},
+		{
+			Name: "daily_performance",
+			Inputs: []string{"generation_daily", "generation_daily_theoretical", "generation_quarantine", "generation_gaps",
+				"locations"},
+			Outputs: []string{"daily_performance"},
+			Run:     func(ctx context.Context) error { return calculator.CalculateDailyPerformance() },
+		},
+		{
+			Name:    "theoretical_output",
+			Inputs:  []string{"weather_daily", "weather_quarantine", "weather_gaps", "locations"},
+			Outputs: []string{"generation_theoretical"},
+			Run:     func(ctx context.Context) error { return calculator.CalculateTheorticalOutput() },
+		},
+		{
+			Name:    "monthly_performance",
+			Inputs:  performanceInputs,
+			Outputs: []string{"monthly_performance"},
+			Run:     func(ctx context.Context) error { return calculator.CalculateMonthlyPerformance() },
+		},
+		{
+			Name:    "yearly_performance",
+			Inputs:  performanceInputs,
+			Outputs: []string{"yearly_performance"},
+			Run:     func(ctx context.Context) error { return calculator.CalculateYearlyPerformance() },
+		},
+		{
+			Name:    "overall_performance",
+			Inputs:  performanceInputs,
+			Outputs: []string{"overall_performance"},
+			Run:     func(ctx context.Context) error { return calculator.CalculateOverallPerformance() },
+		},
+		{
+			Name:    "retrain_forecast_model",
+			Inputs:  []string{"weather_monthly", "generation_actual", "weather_outlook"},
+			Outputs: []string{"generation_predicted", "forecast_quantiles", "forecast_explanations", "forecast_outlook"},
+			Run:     func(ctx context.Context) error { return loader.RunForecastModel(ctx) },
+		},
+		{
+			Name:    "daily_forecast",
+			Inputs:  []string{"weather_daily", "generation_daily"},
+			Outputs: []string{"generation_daily_predicted"},
+			Run:     func(ctx context.Context) error { return loader.RunDailyForecastModel(ctx) },
+		},
+		{
+			Name:    "feature_importance",
+			Inputs:  []string{"weather_monthly", "generation_actual"},
+			Outputs: []string{"feature_importance"},
+			Run:     func(ctx context.Context) error { return loader.RunFeatureImportanceModel(ctx) },
+		},
+		{
+			Name:    "publish_forecast",
+			Inputs:  []string{"generation_actual", "forecast_quantiles"},
+			Outputs: []string{"forecast_calibration"},
+			Run:     func(ctx context.Context) error { return calculator.CalculateForecastCalibration() },
+		},
+	}
+}
+
+func mustGraph(database *db.DB, resources []Resource, nodes []*Node) *Graph {
+	g, err := NewGraph(database, resources, nodes)
+	if err != nil {
+		panic(fmt.Sprintf("invalid pipeline graph: %v", err))
+	}
+	return g
+}
@@ -0,0 +1,361 @@
+package quality
+
+import (
+	"backend/pkg/data"
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// Why a period is a gap
+const (
+	GapMissing     = "missing"
+	GapQuarantined = "quarantined"
+	// GapIncomplete is a Total System period where one of the sites has a gap
+	GapIncomplete = "incomplete"
+)
+
+// Weather columns that are gap-filled, the ones the theoretical output is calculated from
+var weatherGapColumns = []string{"sunshine_duration_seconds", "avg_solar_irradiance_wm2"}
+
+// gap is one period of one series with no usable value
+type gap struct {
+	locationID int
+	period     string
+	column     string
+	reason     string
+	method     string
+	value      *float64
+}
+
+// generationRecord is a stored generation row as gap detection sees it
+type generationRecord struct {
+	actual      sql.NullFloat64
+	theoretical sql.NullFloat64
+	checked     bool
+}
+
+// FillGenerationGaps finds the missing and quarantined periods of each site's monthly and daily
+// generation, from its first to its last reading, and imputes them as configured. Total System is
+// filled with the sum of the sites.
+func (c *Checker) FillGenerationGaps(ctx context.Context) error {
+	methods, err := LoadImputationConfig(c.imputation)
+	if err != nil {
+		return err
+	}
+
+	for _, dataset := range []string{data.DatasetMonthlyGeneration, data.DatasetDailyGeneration} {
+		records, totalID, err := c.loadGenerationRecords(ctx, dataset)
+		if err != nil {
+			return err
+		}
+		monthly := dataset == data.DatasetMonthlyGeneration
+
+		var gaps []gap
+		sites := make(map[int]*series)
+		for locationID, rows := range records {
+			if locationID == totalID {
+				continue
+			}
+			s := generationSeries(locationID, rows, monthly)
+			sites[locationID] = s
+			gaps = append(gaps, s.fill(methods[dataset])...)
+		}
+		if rows, ok := records[totalID]; ok {
+			gaps = append(gaps, totalGaps(generationSeries(totalID, rows, monthly), sites)...)
+		}
+
+		if err := c.saveGaps(ctx, dataset, gaps); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FillWeatherGaps finds the missing and quarantined days of the weather series, and the days
+// missing a column the theoretical output needs, and imputes them as configured
+func (c *Checker) FillWeatherGaps(ctx context.Context) error {
+	methods, err := LoadImputationConfig(c.imputation)
+	if err != nil {
+		return err
+	}
+
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT `+c.db.Dialect.Day("w.date")+`, w.sunshine_duration_seconds, w.avg_solar_irradiance_wm2, c.date IS NOT NULL
+		FROM weather_daily w
+		LEFT JOIN weather_daily_checked c ON c.date = w.date
+		ORDER BY w.date
+	`)
+	if err != nil {
+		return fmt.Errorf("error querying weather: %v", err)
+	}
+	defer rows.Close()
+
+	type weatherRecord struct {
+		values  [2]sql.NullFloat64
+		checked bool
+	}
+	records := make(map[string]weatherRecord)
+	var first, last string
+	for rows.Next() {
+		var date string
+		var record weatherRecord
+		if err := rows.Scan(&date, &record.values[0], &record.values[1], &record.checked); err != nil {
+			return fmt.Errorf("error scanning weather: %v", err)
+		}
+		records[date] = record
+		if first == "" {
+			first = date
+		}
+		last = date
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading weather: %v", err)
+	}
+
+	var gaps []gap
+	if first != "" {
+		periods := periodRange(first, last, false)
+		for c, column := range weatherGapColumns {
+			s := &series{column: column, periods: periods}
+			for _, period := range periods {
+				record, ok := records[period]
+				reason := ""
+				switch {
+				case !ok || !record.values[c].Valid:
+					reason = GapMissing
+				case !record.checked:
+					reason = GapQuarantined
+				}
+				value := math.NaN()
+				if reason == "" {
+					value = record.values[c].Float64
+				}
+				s.values = append(s.values, value)
+				s.theoretical = append(s.theoretical, math.NaN())
+				s.reasons = append(s.reasons, reason)
+			}
+			gaps = append(gaps, s.fill(methods[data.DatasetWeatherDaily])...)
+		}
+	}
+	return c.saveGaps(ctx, data.DatasetWeatherDaily, gaps)
+}
+
+// fill imputes every gap of the series. Imputed values are written back so the series' Total
+// System sum can use them, but the later gaps of the series are only estimated from observed periods.
+func (s *series) fill(settings ImputationSettings) []gap {
+	var gaps []gap
+	imputed := make(map[int]float64)
+	for i, reason := range s.reasons {
+		if reason == "" {
+			continue
+		}
+		g := gap{locationID: s.locationID, period: s.periods[i], column: s.column, reason: reason}
+		if value, method, ok := s.impute(i, settings); ok {
+			g.method, g.value = method, &value
+			imputed[i] = value
+		}
+		gaps = append(gaps, g)
+	}
+	for i, value := range imputed {
+		s.values[i] = value
+	}
+	return gaps
+}
+
+// totalGaps fills the Total System periods where it, or one of the sites, has a gap with the sum of
+// the sites. A site contributes nothing outside its span, but a site gap that could not be imputed
+// leaves the total unknown.
+func totalGaps(total *series, sites map[int]*series) []gap {
+	type siteValue struct {
+		value float64
+		gap   bool
+	}
+	byPeriod := make(map[string][]siteValue)
+	for _, s := range sites {
+		for i, period := range s.periods {
+			byPeriod[period] = append(byPeriod[period], siteValue{s.values[i], s.reasons[i] != ""})
+		}
+	}
+
+	var gaps []gap
+	for i, period := range total.periods {
+		reason := total.reasons[i]
+		sum, known := 0.0, true
+		for _, v := range byPeriod[period] {
+			if v.gap && reason == "" {
+				reason = GapIncomplete
+			}
+			if math.IsNaN(v.value) {
+				known = false
+			}
+			sum += v.value
+		}
+		if reason == "" {
+			continue
+		}
+		g := gap{locationID: total.locationID, period: period, column: total.column, reason: reason}
+		if known && len(byPeriod[period]) > 0 {
+			value := math.Round(sum*1000) / 1000
+			g.method, g.value = MethodSumOfSites, &value
+		}
+		gaps = append(gaps, g)
+	}
+	return gaps
+}
+
+// loadGenerationRecords returns each location's generation rows by period, and the Total System ID
+func (c *Checker) loadGenerationRecords(ctx context.Context, dataset string) (map[int]map[string]generationRecord, int, error) {
+	var totalID int
+	if err := c.db.QueryRowContext(ctx, `SELECT id FROM locations WHERE name = 'Total System'`).Scan(&totalID); err != nil && err != sql.ErrNoRows {
+		return nil, 0, fmt.Errorf("error querying Total System: %v", err)
+	}
+
+	query := `
+		SELECT g.location_id, ` + c.db.Dialect.Period("g.year", "g.month") + `, g.actual_kwh, g.theoretical_kwh,
+			c.location_id IS NOT NULL
+		FROM monthly_generation g
+		LEFT JOIN monthly_generation_checked c
+			ON c.year = g.year AND c.month = g.month AND c.location_id = g.location_id
+	`
+	if dataset == data.DatasetDailyGeneration {
+		query = `
+			SELECT g.location_id, ` + c.db.Dialect.Day("g.date") + `, g.actual_kwh, g.theoretical_kwh, c.location_id IS NOT NULL
+			FROM daily_generation g
+			LEFT JOIN daily_generation_checked c ON c.date = g.date AND c.location_id = g.location_id
+		`
+	}
+
+	rows, err := c.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error querying %s: %v", dataset, err)
+	}
+	defer rows.Close()
+
+	records := make(map[int]map[string]generationRecord)
+	for rows.Next() {
+		var locationID int
+		var period string
+		var record generationRecord
+		if err := rows.Scan(&locationID, &period, &record.actual, &record.theoretical, &record.checked); err != nil {
+			return nil, 0, fmt.Errorf("error scanning %s: %v", dataset, err)
+		}
+		if records[locationID] == nil {
+			records[locationID] = make(map[string]generationRecord)
+		}
+		records[locationID][period] = record
+	}
+	return records, totalID, rows.Err()
+}
+
+// generationSeries lays a location's rows out over the periods from its first to its last reading
+func generationSeries(locationID int, rows map[string]generationRecord, monthly bool) *series {
+	s := &series{locationID: locationID, column: "actual_kwh"}
+	var first, last string
+	for period, record := range rows {
+		if !record.actual.Valid {
+			continue
+		}
+		if first == "" || period < first {
+			first = period
+		}
+		if period > last {
+			last = period
+		}
+	}
+	if first == "" {
+		return s
+	}
+
+	s.periods = periodRange(first, last, monthly)
+	for _, period := range s.periods {
+		record, ok := rows[period]
+		reason := ""
+		switch {
+		case !ok || !record.actual.Valid:
+			reason = GapMissing
+		case !record.checked:
+			reason = GapQuarantined
+		}
+		value, theoretical := math.NaN(), math.NaN()
+		if reason == "" {
+			value = record.actual.Float64
+		}
+		if record.theoretical.Valid {
+			theoretical = record.theoretical.Float64
+		}
+		s.values = append(s.values, value)
+		s.theoretical = append(s.theoretical, theoretical)
+		s.reasons = append(s.reasons, reason)
+	}
+	return s
+}
+
+// periodRange lists the months (YYYY-MM) or days (YYYY-MM-DD) from first to last inclusive
+func periodRange(first, last string, monthly bool) []string {
+	layout := "2006-01-02"
+	if monthly {
+		layout = "2006-01"
+	}
+	start, err := time.Parse(layout, first)
+	if err != nil {
+		return nil
+	}
+	end, err := time.Parse(layout, last)
+	if err != nil {
+		return nil
+	}
+
+	var periods []string
+	for t := start; !t.After(end); {
+		periods = append(periods, t.Format(layout))
+		if monthly {
+			t = t.AddDate(0, 1, 0)
+		} else {
+			t = t.AddDate(0, 0, 1)
+		}
+	}
+	return periods
+}
+
+// saveGaps replaces a dataset's gaps
+func (c *Checker) saveGaps(ctx context.Context, dataset string, gaps []gap) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM gaps WHERE dataset = ?`, dataset); err != nil {
+		return fmt.Errorf("error clearing gaps: %v", err)
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO gaps (dataset, location_id, period, column_name, reason, method, value, detected_at)
+		VALUES (?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("error preparing statement: %v", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC()
+	imputed := 0
+	for _, g := range gaps {
+		if _, err := stmt.Exec(dataset, g.locationID, g.period, g.column, g.reason, g.method, g.value, now); err != nil {
+			return fmt.Errorf("error saving gap: %v", err)
+		}
+		if g.value != nil {
+			imputed++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing gaps: %v", err)
+	}
+	slog.Info("Filled gaps", "dataset", dataset, "gaps", len(gaps), "imputed", imputed)
+	return nil
+}
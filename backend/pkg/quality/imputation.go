@@ -0,0 +1,172 @@
+package quality
+
+import (
+	"backend/pkg/data"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+)
+
+// Imputation methods, tried in the order a dataset lists them until one gives an estimate
+const (
+	// MethodTheoreticalPR scales the period's theoretical output by the performance ratio of the
+	// trailing observed periods
+	MethodTheoreticalPR = "theoretical_pr"
+	// MethodInterpolation draws a straight line between the observed periods either side of the gap
+	MethodInterpolation = "interpolation"
+	// MethodClimatology uses the mean of the observed periods in the same calendar month
+	MethodClimatology = "climatology"
+	// MethodSumOfSites fills Total System with the sum of the sites, observed or imputed
+	MethodSumOfSites = "sum_of_sites"
+)
+
+// ImputationSettings are the methods used to fill one dataset's gaps. Leaving methods empty
+// reports the gaps without estimating them.
+type ImputationSettings struct {
+	Methods []string `json:"methods"`
+	// Trailing is how many observed periods theoretical_pr takes the performance ratio from
+	Trailing int `json:"trailing,omitempty"`
+}
+
+// ImputationConfig holds the settings of each dataset
+type ImputationConfig map[string]ImputationSettings
+
+// LoadImputationConfig reads and validates the imputation config
+func LoadImputationConfig(path string) (ImputationConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening imputation config: %v", err)
+	}
+	defer f.Close()
+
+	var config ImputationConfig
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("error parsing imputation config: %v", err)
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid imputation config: %v", err)
+	}
+	return config, nil
+}
+
+func (c ImputationConfig) validate() error {
+	for dataset, settings := range c {
+		switch dataset {
+		case data.DatasetMonthlyGeneration, data.DatasetDailyGeneration, data.DatasetWeatherDaily:
+		default:
+			return fmt.Errorf("unknown dataset %q", dataset)
+		}
+		for _, method := range settings.Methods {
+			switch method {
+			case MethodInterpolation, MethodClimatology:
+			case MethodTheoreticalPR:
+				if dataset == data.DatasetWeatherDaily {
+					return errors.New("theoretical_pr only applies to generation")
+				}
+				if settings.Trailing <= 0 {
+					return fmt.Errorf("%s: theoretical_pr needs a positive trailing", dataset)
+				}
+			default:
+				return fmt.Errorf("%s: unknown method %q", dataset, method)
+			}
+		}
+	}
+	return nil
+}
+
+// series is one site's generation, or one weather column, over every period of its span. Values
+// are NaN where the period is a gap or unknown.
+type series struct {
+	locationID  int
+	column      string
+	periods     []string
+	values      []float64
+	theoretical []float64
+	// reasons is empty for observed periods and the gap reason otherwise
+	reasons []string
+}
+
+// impute estimates period i with the first method that can
+func (s *series) impute(i int, settings ImputationSettings) (float64, string, bool) {
+	for _, method := range settings.Methods {
+		var value float64
+		var ok bool
+		switch method {
+		case MethodTheoreticalPR:
+			value, ok = s.theoreticalPR(i, settings.Trailing)
+		case MethodInterpolation:
+			value, ok = s.interpolate(i)
+		case MethodClimatology:
+			value, ok = s.climatology(i)
+		}
+		if ok {
+			return math.Round(value*1000) / 1000, method, true
+		}
+	}
+	return 0, "", false
+}
+
+func (s *series) observed(i int) bool {
+	return s.reasons[i] == "" && !math.IsNaN(s.values[i])
+}
+
+func (s *series) theoreticalPR(i, trailing int) (float64, bool) {
+	if math.IsNaN(s.theoretical[i]) || s.theoretical[i] <= 0 {
+		return 0, false
+	}
+	var actual, theoretical float64
+	used := 0
+	for j := i - 1; j >= 0 && used < trailing; j-- {
+		if s.observed(j) && s.theoretical[j] > 0 {
+			actual += s.values[j]
+			theoretical += s.theoretical[j]
+			used++
+		}
+	}
+	if used == 0 {
+		return 0, false
+	}
+	return s.theoretical[i] * actual / theoretical, true
+}
+
+func (s *series) interpolate(i int) (float64, bool) {
+	before, after := -1, -1
+	for j := i - 1; j >= 0; j-- {
+		if s.observed(j) {
+			before = j
+			break
+		}
+	}
+	for j := i + 1; j < len(s.values); j++ {
+		if s.observed(j) {
+			after = j
+			break
+		}
+	}
+	if before < 0 || after < 0 {
+		return 0, false
+	}
+	step := (s.values[after] - s.values[before]) / float64(after-before)
+	return s.values[before] + step*float64(i-before), true
+}
+
+// climatology averages the observed periods of the same calendar month, the "-MM" of the period
+func (s *series) climatology(i int) (float64, bool) {
+	month := s.periods[i][4:7]
+	var sum float64
+	n := 0
+	for j, period := range s.periods {
+		if period[4:7] == month && s.observed(j) {
+			sum += s.values[j]
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
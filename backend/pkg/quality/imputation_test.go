@@ -0,0 +1,125 @@
+package quality
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadImputationConfig(t *testing.T) {
+	config, err := LoadImputationConfig("../db/imputation.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings := config["monthly_generation"]; settings.Trailing != 12 || settings.Methods[0] != MethodTheoreticalPR {
+		t.Errorf("monthly_generation settings = %+v", settings)
+	}
+}
+
+func TestImputationConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config ImputationConfig
+		err    string
+	}{
+		{"every method", ImputationConfig{
+			"monthly_generation": {Methods: []string{MethodTheoreticalPR, MethodInterpolation, MethodClimatology}, Trailing: 12},
+		}, ""},
+		{"no methods", ImputationConfig{"weather_daily": {}}, ""},
+		{"unknown dataset", ImputationConfig{"weather_monthly": {}}, "unknown dataset"},
+		{"unknown method", ImputationConfig{"daily_generation": {Methods: []string{"mean"}}}, "unknown method"},
+		{"theoretical_pr on weather", ImputationConfig{"weather_daily": {Methods: []string{MethodTheoreticalPR}, Trailing: 3}}, "only applies to generation"},
+		{"theoretical_pr without trailing", ImputationConfig{"daily_generation": {Methods: []string{MethodTheoreticalPR}}}, "positive trailing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.validate()
+			if tt.err == "" && err != nil {
+				t.Fatalf("validate() = %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("validate() = %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSeriesImpute(t *testing.T) {
+	nan := math.NaN()
+	// Two years of a site with March 2021 missing
+	s := &series{
+		periods:     []string{"2020-01", "2020-02", "2020-03", "2020-04", "2021-01", "2021-02", "2021-03", "2021-04"},
+		values:      []float64{100, 120, 150, 160, 110, 130, nan, 170},
+		theoretical: []float64{200, 240, 300, 320, 220, 260, 320, 340},
+		reasons:     []string{"", "", "", "", "", "", GapMissing, ""},
+	}
+	tests := []struct {
+		name    string
+		i       int
+		methods []string
+		value   float64
+		method  string
+		ok      bool
+	}{
+		{"theoretical_pr", 6, []string{MethodTheoreticalPR}, 160, MethodTheoreticalPR, true},
+		{"interpolation", 6, []string{MethodInterpolation}, 150, MethodInterpolation, true},
+		{"climatology", 6, []string{MethodClimatology}, 150, MethodClimatology, true},
+		{"first method that can", 6, []string{MethodInterpolation, MethodClimatology}, 150, MethodInterpolation, true},
+		{"no methods", 6, nil, 0, "", false},
+		// Nothing is observed before the first period
+		{"theoretical_pr at the start", 0, []string{MethodTheoreticalPR}, 0, "", false},
+		{"interpolation at the start falls through", 0, []string{MethodInterpolation, MethodClimatology}, 105, MethodClimatology, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, method, ok := s.impute(tt.i, ImputationSettings{Methods: tt.methods, Trailing: 2})
+			if value != tt.value || method != tt.method || ok != tt.ok {
+				t.Errorf("impute() = %v, %q, %v, want %v, %q, %v", value, method, ok, tt.value, tt.method, tt.ok)
+			}
+		})
+	}
+}
+
+func TestPeriodRange(t *testing.T) {
+	tests := []struct {
+		name        string
+		first, last string
+		monthly     bool
+		want        []string
+	}{
+		{"months over a year end", "2020-11", "2021-02", true, []string{"2020-11", "2020-12", "2021-01", "2021-02"}},
+		{"one month", "2020-06", "2020-06", true, []string{"2020-06"}},
+		{"days over a month end", "2020-02-28", "2020-03-01", false, []string{"2020-02-28", "2020-02-29", "2020-03-01"}},
+		{"reversed", "2020-06", "2020-05", true, nil},
+		{"unparseable", "2020-06-01", "2020-07", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := periodRange(tt.first, tt.last, tt.monthly); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("periodRange() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTotalGaps(t *testing.T) {
+	nan := math.NaN()
+	periods := []string{"2020-01", "2020-02", "2020-03"}
+	total := &series{locationID: 4, column: "actual_kwh", periods: periods,
+		values: []float64{300, nan, 310}, reasons: []string{"", GapMissing, ""}}
+	sites := map[int]*series{
+		// An imputed February and an unknown March
+		1: {periods: periods, values: []float64{100, 105, nan}, reasons: []string{"", GapMissing, GapQuarantined}},
+		2: {periods: periods, values: []float64{200, 210, 220}, reasons: []string{"", "", ""}},
+	}
+
+	value := 315.0
+	want := []gap{
+		{locationID: 4, period: "2020-02", column: "actual_kwh", reason: GapMissing, method: MethodSumOfSites, value: &value},
+		{locationID: 4, period: "2020-03", column: "actual_kwh", reason: GapIncomplete},
+	}
+	if got := totalGaps(total, sites); !reflect.DeepEqual(got, want) {
+		t.Errorf("totalGaps() = %+v, want %+v", got, want)
+	}
+}
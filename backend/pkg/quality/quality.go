@@ -0,0 +1,289 @@
+package quality
+
+import (
+	"backend/pkg/data"
+	"backend/pkg/db"
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// Review statuses. Quarantined and rejected rows are left out of the metrics.
+const (
+	StatusQuarantined = "quarantined"
+	StatusAccepted    = "accepted"
+	StatusRejected    = "rejected"
+)
+
+// ErrInvalidReview means a review names an unknown status
+var ErrInvalidReview = errors.New("invalid review")
+
+// issue is one row failing one rule
+type issue struct {
+	locationID int
+	period     string
+	reason     string
+	value      *float64
+}
+
+type issueKey struct {
+	locationID int
+	period     string
+	rule       string
+}
+
+// Checker runs the rules over the stored data and records what they find
+type Checker struct {
+	db *db.DB
+	// imputation is the file the gap filling methods are read from
+	imputation string
+}
+
+// NewChecker returns a Checker on database that fills gaps with the methods in the imputation file
+func NewChecker(database *db.DB, imputation string) *Checker {
+	return &Checker{db: database, imputation: imputation}
+}
+
+// Rules lists the built-in rules
+func Rules() []structure.QualityRule {
+	var rules []structure.QualityRule
+	for _, dataset := range []string{data.DatasetMonthlyGeneration, data.DatasetDailyGeneration} {
+		for _, rule := range generationRules {
+			rules = append(rules, structure.QualityRule{Name: rule.name, Dataset: dataset, Description: rule.description})
+		}
+	}
+	for _, rule := range weatherRules {
+		rules = append(rules, structure.QualityRule{Name: rule.name, Dataset: data.DatasetWeatherDaily, Description: rule.description})
+	}
+	return rules
+}
+
+// CheckWeather runs the weather rules over weather_daily and updates its quarantine entries
+func (c *Checker) CheckWeather(ctx context.Context) error {
+	rows, err := c.loadWeather(ctx)
+	if err != nil {
+		return err
+	}
+
+	found := make(map[issueKey]issue)
+	for _, rule := range weatherRules {
+		for _, i := range rule.check(rows) {
+			found[issueKey{period: i.period, rule: rule.name}] = i
+		}
+	}
+	return c.save(ctx, data.DatasetWeatherDaily, found)
+}
+
+// CheckGeneration runs the generation rules over monthly and daily generation, one site at a time,
+// and updates their quarantine entries
+func (c *Checker) CheckGeneration(ctx context.Context) error {
+	for _, dataset := range []string{data.DatasetMonthlyGeneration, data.DatasetDailyGeneration} {
+		sites, err := c.loadGeneration(ctx, dataset)
+		if err != nil {
+			return err
+		}
+
+		found := make(map[issueKey]issue)
+		for _, rows := range sites {
+			for _, rule := range generationRules {
+				for _, i := range rule.check(rows) {
+					found[issueKey{locationID: i.locationID, period: i.period, rule: rule.name}] = i
+				}
+			}
+		}
+		if err := c.save(ctx, dataset, found); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// save reconciles a dataset's quarantine entries with the issues just found. A reviewed entry keeps
+// its review while the value is unchanged; a changed value goes back under review. Entries whose
+// row no longer fails the rule are removed.
+func (c *Checker) save(ctx context.Context, dataset string, found map[issueKey]issue) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, location_id, period, rule, value FROM quarantine WHERE dataset = ?`, dataset)
+	if err != nil {
+		return fmt.Errorf("error querying quarantine: %v", err)
+	}
+	type entry struct {
+		id    int
+		value sql.NullFloat64
+	}
+	existing := make(map[issueKey]entry)
+	for rows.Next() {
+		var key issueKey
+		var e entry
+		if err := rows.Scan(&e.id, &key.locationID, &key.period, &key.rule, &e.value); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning quarantine: %v", err)
+		}
+		existing[key] = e
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading quarantine: %v", err)
+	}
+
+	now := time.Now().UTC()
+	added := 0
+	for key, i := range found {
+		e, ok := existing[key]
+		delete(existing, key)
+		if !ok {
+			_, err = tx.Exec(`
+				INSERT INTO quarantine (dataset, location_id, period, rule, reason, value, status, detected_at)
+				VALUES (?, ?, ?, ?, ?, ?, 'quarantined', ?)
+			`, dataset, key.locationID, key.period, key.rule, i.reason, i.value, now)
+			added++
+		} else if sameValue(e.value, i.value) {
+			_, err = tx.Exec(`UPDATE quarantine SET reason = ? WHERE id = ?`, i.reason, e.id)
+		} else {
+			_, err = tx.Exec(`
+				UPDATE quarantine
+				SET reason = ?, value = ?, status = 'quarantined', detected_at = ?, reviewed_at = NULL, review_note = NULL
+				WHERE id = ?
+			`, i.reason, i.value, now, e.id)
+		}
+		if err != nil {
+			return fmt.Errorf("error saving quarantine entry: %v", err)
+		}
+	}
+
+	for _, e := range existing {
+		if _, err := tx.Exec(`DELETE FROM quarantine WHERE id = ?`, e.id); err != nil {
+			return fmt.Errorf("error clearing quarantine entry: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing quarantine: %v", err)
+	}
+	slog.Info("Checked data quality", "dataset", dataset, "issues", len(found), "new", added, "resolved", len(existing))
+	return nil
+}
+
+func sameValue(stored sql.NullFloat64, value *float64) bool {
+	if value == nil || !stored.Valid {
+		return value == nil && !stored.Valid
+	}
+	return math.Abs(stored.Float64-*value) < 0.0005
+}
+
+// Review sets the status of a quarantine entry
+func (c *Checker) Review(id int, review structure.QuarantineReview) error {
+	switch review.Status {
+	case StatusQuarantined, StatusAccepted, StatusRejected:
+	default:
+		return fmt.Errorf("%w: status must be %s, %s or %s", ErrInvalidReview, StatusAccepted, StatusRejected, StatusQuarantined)
+	}
+
+	var reviewedAt interface{}
+	if review.Status != StatusQuarantined {
+		reviewedAt = time.Now().UTC()
+	}
+	result, err := c.db.Exec(`
+		UPDATE quarantine SET status = ?, reviewed_at = ?, review_note = NULLIF(?, '')
+		WHERE id = ?
+	`, review.Status, reviewedAt, review.Note, id)
+	if err != nil {
+		return fmt.Errorf("error reviewing quarantine entry %d: %v", id, err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return repository.ErrQuarantineNotFound
+	}
+	return nil
+}
+
+// loadGeneration returns each site's generation rows in period order
+func (c *Checker) loadGeneration(ctx context.Context, dataset string) (map[int][]generationRow, error) {
+	query := `
+		SELECT g.location_id, COALESCE(l.installed_capacity_kw, 0),
+			` + c.db.Dialect.Period("g.year", "g.month") + `, g.year, g.month, g.actual_kwh, g.theoretical_kwh
+		FROM monthly_generation g
+		JOIN locations l ON l.id = g.location_id
+		WHERE g.actual_kwh IS NOT NULL
+		ORDER BY g.location_id, g.year, g.month
+	`
+	if dataset == data.DatasetDailyGeneration {
+		query = `
+			SELECT g.location_id, COALESCE(l.installed_capacity_kw, 0),
+				` + c.db.Dialect.Day("g.date") + `, 0, 0, g.actual_kwh, g.theoretical_kwh
+			FROM daily_generation g
+			JOIN locations l ON l.id = g.location_id
+			WHERE g.actual_kwh IS NOT NULL
+			ORDER BY g.location_id, g.date
+		`
+	}
+
+	rows, err := c.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying %s: %v", dataset, err)
+	}
+	defer rows.Close()
+
+	sites := make(map[int][]generationRow)
+	for rows.Next() {
+		var row generationRow
+		var year, month int
+		var theoretical sql.NullFloat64
+		if err := rows.Scan(&row.locationID, &row.capacity, &row.period, &year, &month, &row.actual, &theoretical); err != nil {
+			return nil, fmt.Errorf("error scanning %s: %v", dataset, err)
+		}
+		row.hours = 24
+		if dataset == data.DatasetMonthlyGeneration {
+			row.hours = float64(time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day() * 24)
+		}
+		if theoretical.Valid {
+			row.theoretical = &theoretical.Float64
+		}
+		sites[row.locationID] = append(sites[row.locationID], row)
+	}
+	return sites, rows.Err()
+}
+
+func (c *Checker) loadWeather(ctx context.Context) ([]weatherRow, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT `+c.db.Dialect.Day("date")+`, sunshine_duration_seconds, daylight_duration_seconds, min_temperature_C,
+			avg_temperature_C, max_temperature_C, avg_solar_irradiance_wm2, avg_relative_humidity_percent,
+			avg_cloud_cover_percent, avg_wind_speed_kmh, rainfall_mm
+		FROM weather_daily
+		ORDER BY date
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying weather: %v", err)
+	}
+	defer rows.Close()
+
+	var weather []weatherRow
+	for rows.Next() {
+		var row weatherRow
+		values := make([]sql.NullFloat64, 10)
+		if err := rows.Scan(&row.date, &values[0], &values[1], &values[2], &values[3], &values[4], &values[5],
+			&values[6], &values[7], &values[8], &values[9]); err != nil {
+			return nil, fmt.Errorf("error scanning weather: %v", err)
+		}
+		targets := []*float64{&row.sunshine, &row.daylight, &row.minTemp, &row.avgTemp, &row.maxTemp,
+			&row.irradiance, &row.humidity, &row.cloudCover, &row.windSpeed, &row.rainfall}
+		for i, value := range values {
+			*targets[i] = math.NaN()
+			if value.Valid {
+				*targets[i] = value.Float64
+			}
+		}
+		weather = append(weather, row)
+	}
+	return weather, rows.Err()
+}
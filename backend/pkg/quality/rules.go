@@ -0,0 +1,276 @@
+package quality
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Thresholds of the built-in rules
+const (
+	// maxPerformanceRatio is how far actual output may exceed the theoretical output before the
+	// meter or the weather is suspect
+	maxPerformanceRatio = 1.3
+	// stuckRun is how many consecutive identical non-zero readings count as a stuck meter or sensor
+	stuckRun = 3
+	// outlierScore is the modified z-score of the performance ratio above which a row is an outlier
+	outlierScore = 3.5
+	// outlierMinRows is how many rows a site needs before outliers are looked for
+	outlierMinRows = 12
+)
+
+// generationRow is a monthly or daily generation row with what the rules need to judge it
+type generationRow struct {
+	locationID  int
+	period      string
+	hours       float64
+	capacity    float64
+	actual      float64
+	theoretical *float64
+}
+
+// weatherRow is a weather_daily row. Columns that are NULL are NaN.
+type weatherRow struct {
+	date       string
+	sunshine   float64
+	daylight   float64
+	minTemp    float64
+	avgTemp    float64
+	maxTemp    float64
+	irradiance float64
+	humidity   float64
+	cloudCover float64
+	windSpeed  float64
+	rainfall   float64
+}
+
+type generationRule struct {
+	name        string
+	description string
+	check       func(rows []generationRow) []issue
+}
+
+type weatherRule struct {
+	name        string
+	description string
+	check       func(rows []weatherRow) []issue
+}
+
+// generationRules run over monthly and daily generation alike, one site at a time in period order
+var generationRules = []generationRule{
+	{
+		name:        "negative_reading",
+		description: "Generation is below zero",
+		check: eachGeneration(func(row generationRow) (string, bool) {
+			return fmt.Sprintf("actual %.2f kWh is negative", row.actual), row.actual < 0
+		}),
+	},
+	{
+		name:        "exceeds_capacity",
+		description: "Generation is more than installed capacity × hours, a capacity factor above 1",
+		check: eachGeneration(func(row generationRow) (string, bool) {
+			limit := row.capacity * row.hours
+			return fmt.Sprintf("actual %.2f kWh exceeds capacity × hours %.2f kWh (capacity factor %.3f)",
+				row.actual, limit, row.actual/limit), limit > 0 && row.actual > limit
+		}),
+	},
+	{
+		name:        "exceeds_theoretical",
+		description: fmt.Sprintf("Generation is more than %.0f%% of the theoretical output", maxPerformanceRatio*100),
+		check: eachGeneration(func(row generationRow) (string, bool) {
+			if row.theoretical == nil || *row.theoretical <= 0 {
+				return "", false
+			}
+			ratio := row.actual / *row.theoretical
+			return fmt.Sprintf("actual %.2f kWh is %.2f × the theoretical %.2f kWh", row.actual, ratio, *row.theoretical),
+				ratio > maxPerformanceRatio
+		}),
+	},
+	{
+		name:        "stuck_value",
+		description: fmt.Sprintf("The same non-zero reading %d or more periods in a row", stuckRun),
+		check: func(rows []generationRow) []issue {
+			values := make([]float64, len(rows))
+			for i, row := range rows {
+				values[i] = row.actual
+			}
+			var issues []issue
+			for _, run := range stuckRuns(values) {
+				for i := run[0]; i < run[1]; i++ {
+					issues = append(issues, rows[i].issue(fmt.Sprintf("actual %.2f kWh repeats for %d periods from %s",
+						rows[i].actual, run[1]-run[0], rows[run[0]].period)))
+				}
+			}
+			return issues
+		},
+	},
+	{
+		name:        "outlier",
+		description: fmt.Sprintf("Performance ratio has a modified z-score above %.1f for its site", outlierScore),
+		check: func(rows []generationRow) []issue {
+			var ratios []float64
+			var index []int
+			for i, row := range rows {
+				if row.theoretical != nil && *row.theoretical > 0 {
+					ratios = append(ratios, row.actual / *row.theoretical)
+					index = append(index, i)
+				}
+			}
+			var issues []issue
+			for j, score := range modifiedZScores(ratios) {
+				if math.Abs(score) > outlierScore {
+					row := rows[index[j]]
+					issues = append(issues, row.issue(fmt.Sprintf("performance ratio %.3f has a modified z-score of %.1f",
+						ratios[j], score)))
+				}
+			}
+			return issues
+		},
+	},
+}
+
+var weatherRules = []weatherRule{
+	{
+		name:        "temperature_order",
+		description: "Maximum temperature below the minimum, or the average outside them",
+		check: eachWeather(func(row weatherRow) (string, float64, bool) {
+			switch {
+			case row.maxTemp < row.minTemp:
+				return fmt.Sprintf("max temperature %.1f°C is below min %.1f°C", row.maxTemp, row.minTemp), row.maxTemp, true
+			case row.avgTemp < row.minTemp || row.avgTemp > row.maxTemp:
+				return fmt.Sprintf("avg temperature %.1f°C is outside %.1f–%.1f°C", row.avgTemp, row.minTemp, row.maxTemp),
+					row.avgTemp, true
+			}
+			return "", 0, false
+		}),
+	},
+	{
+		name:        "physical_bounds",
+		description: "A weather value outside what is physically possible",
+		check: eachWeather(func(row weatherRow) (string, float64, bool) {
+			bounds := []struct {
+				name      string
+				value     float64
+				low, high float64
+				unit      string
+			}{
+				{"min temperature", row.minTemp, -40, 60, "°C"},
+				{"max temperature", row.maxTemp, -40, 60, "°C"},
+				{"irradiance", row.irradiance, 0, 1400, " W/m²"},
+				{"relative humidity", row.humidity, 0, 100, "%"},
+				{"cloud cover", row.cloudCover, 0, 100, "%"},
+				{"daylight", row.daylight, 0, 86400, " s"},
+				{"sunshine", row.sunshine, 0, 86400, " s"},
+				{"wind speed", row.windSpeed, 0, 200, " km/h"},
+				{"rainfall", row.rainfall, 0, 1000, " mm"},
+			}
+			for _, b := range bounds {
+				if b.value < b.low || b.value > b.high {
+					return fmt.Sprintf("%s %.1f%s is outside %.0f–%.0f%s", b.name, b.value, b.unit, b.low, b.high, b.unit), b.value, true
+				}
+			}
+			if row.sunshine > row.daylight {
+				return fmt.Sprintf("sunshine %.0f s is longer than daylight %.0f s", row.sunshine, row.daylight), row.sunshine, true
+			}
+			return "", 0, false
+		}),
+	},
+	{
+		name:        "stuck_value",
+		description: fmt.Sprintf("The same non-zero irradiance %d or more days in a row", stuckRun),
+		check: func(rows []weatherRow) []issue {
+			values := make([]float64, len(rows))
+			for i, row := range rows {
+				values[i] = row.irradiance
+			}
+			var issues []issue
+			for _, run := range stuckRuns(values) {
+				for i := run[0]; i < run[1]; i++ {
+					issues = append(issues, issue{period: rows[i].date, value: &rows[i].irradiance,
+						reason: fmt.Sprintf("irradiance %.2f W/m² repeats for %d days from %s", rows[i].irradiance, run[1]-run[0], rows[run[0]].date)})
+				}
+			}
+			return issues
+		},
+	},
+}
+
+func (row generationRow) issue(reason string) issue {
+	actual := row.actual
+	return issue{locationID: row.locationID, period: row.period, reason: reason, value: &actual}
+}
+
+func eachGeneration(fails func(row generationRow) (string, bool)) func(rows []generationRow) []issue {
+	return func(rows []generationRow) []issue {
+		var issues []issue
+		for _, row := range rows {
+			if reason, failed := fails(row); failed {
+				issues = append(issues, row.issue(reason))
+			}
+		}
+		return issues
+	}
+}
+
+// eachWeather checks rows one at a time. Comparisons against a NULL (NaN) column are false, so
+// missing values never fail a rule.
+func eachWeather(fails func(row weatherRow) (string, float64, bool)) func(rows []weatherRow) []issue {
+	return func(rows []weatherRow) []issue {
+		var issues []issue
+		for _, row := range rows {
+			if reason, value, failed := fails(row); failed {
+				issues = append(issues, issue{period: row.date, reason: reason, value: &value})
+			}
+		}
+		return issues
+	}
+}
+
+// stuckRuns returns the [start, end) ranges of stuckRun or more equal, non-zero values in a row
+func stuckRuns(values []float64) [][2]int {
+	var runs [][2]int
+	start := 0
+	for i := 1; i <= len(values); i++ {
+		if i < len(values) && values[i] == values[start] {
+			continue
+		}
+		if i-start >= stuckRun && values[start] != 0 && !math.IsNaN(values[start]) {
+			runs = append(runs, [2]int{start, i})
+		}
+		start = i
+	}
+	return runs
+}
+
+// modifiedZScores scores each value by its distance from the median in median absolute deviations,
+// which a few bad rows cannot drag the way they drag a mean and standard deviation
+func modifiedZScores(values []float64) []float64 {
+	if len(values) < outlierMinRows {
+		return nil
+	}
+	m := median(values)
+	deviations := make([]float64, len(values))
+	for i, value := range values {
+		deviations[i] = math.Abs(value - m)
+	}
+	mad := median(deviations)
+	if mad == 0 {
+		return nil
+	}
+
+	scores := make([]float64, len(values))
+	for i, value := range values {
+		scores[i] = 0.6745 * (value - m) / mad
+	}
+	return scores
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,142 @@
+package quality
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func generationRuleNamed(t *testing.T, name string) generationRule {
+	for _, rule := range generationRules {
+		if rule.name == name {
+			return rule
+		}
+	}
+	t.Fatalf("no generation rule %s", name)
+	return generationRule{}
+}
+
+func weatherRuleNamed(t *testing.T, name string) weatherRule {
+	for _, rule := range weatherRules {
+		if rule.name == name {
+			return rule
+		}
+	}
+	t.Fatalf("no weather rule %s", name)
+	return weatherRule{}
+}
+
+func periods(issues []issue) []string {
+	var flagged []string
+	for _, issue := range issues {
+		flagged = append(flagged, issue.period)
+	}
+	return flagged
+}
+
+// month is a 1 MW site's month of 720 hours producing actual kWh
+func month(period string, actual float64, theoretical *float64) generationRow {
+	return generationRow{locationID: 1, period: period, hours: 720, capacity: 1000, actual: actual, theoretical: theoretical}
+}
+
+func TestGenerationRules(t *testing.T) {
+	theoretical := func(v float64) *float64 { return &v }
+	steady := func(n int, odd map[int]float64) []generationRow {
+		rows := make([]generationRow, n)
+		for i := range rows {
+			actual := 100000 + float64(i%3)*1000
+			if v, ok := odd[i]; ok {
+				actual = v
+			}
+			rows[i] = month(fmt.Sprintf("2020-%02d", i+1), actual, theoretical(125000))
+		}
+		return rows
+	}
+
+	tests := []struct {
+		rule string
+		name string
+		rows []generationRow
+		want []string
+	}{
+		{"negative_reading", "negative", []generationRow{month("2020-01", -1, nil), month("2020-02", 0, nil)}, []string{"2020-01"}},
+		{"exceeds_capacity", "above capacity × hours", []generationRow{month("2020-01", 720001, nil), month("2020-02", 720000, nil)}, []string{"2020-01"}},
+		{"exceeds_capacity", "no hours", []generationRow{{period: "2020-01", capacity: 1000, actual: 5}}, nil},
+		{"exceeds_theoretical", "above 130%", []generationRow{month("2020-01", 131, theoretical(100)), month("2020-02", 130, theoretical(100))}, []string{"2020-01"}},
+		{"exceeds_theoretical", "no theoretical", []generationRow{month("2020-01", 500, nil), month("2020-02", 500, theoretical(0))}, nil},
+		{"stuck_value", "three in a row", []generationRow{month("2020-01", 5, nil), month("2020-02", 7, nil), month("2020-03", 7, nil), month("2020-04", 7, nil), month("2020-05", 8, nil)}, []string{"2020-02", "2020-03", "2020-04"}},
+		{"stuck_value", "two in a row", []generationRow{month("2020-01", 7, nil), month("2020-02", 7, nil), month("2020-03", 8, nil)}, nil},
+		{"stuck_value", "zeros", []generationRow{month("2020-01", 0, nil), month("2020-02", 0, nil), month("2020-03", 0, nil)}, nil},
+		{"outlier", "one bad month", steady(12, map[int]float64{5: 20000}), []string{"2020-06"}},
+		{"outlier", "too few months", steady(11, map[int]float64{5: 20000}), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule+"/"+tt.name, func(t *testing.T) {
+			if got := periods(generationRuleNamed(t, tt.rule).check(tt.rows)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("flagged %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeatherRules(t *testing.T) {
+	day := func(date string, change func(*weatherRow)) weatherRow {
+		row := weatherRow{date: date, sunshine: 30000, daylight: 40000, minTemp: 20, avgTemp: 25, maxTemp: 30,
+			irradiance: 250, humidity: 60, cloudCover: 10, windSpeed: 15, rainfall: 0}
+		if change != nil {
+			change(&row)
+		}
+		return row
+	}
+
+	tests := []struct {
+		rule string
+		name string
+		rows []weatherRow
+		want []string
+	}{
+		{"temperature_order", "valid", []weatherRow{day("2020-01-01", nil)}, nil},
+		{"temperature_order", "max below min", []weatherRow{day("2020-01-01", func(r *weatherRow) { r.maxTemp = 10; r.avgTemp = 15 })}, []string{"2020-01-01"}},
+		{"temperature_order", "average outside", []weatherRow{day("2020-01-01", func(r *weatherRow) { r.avgTemp = 31 })}, []string{"2020-01-01"}},
+		{"temperature_order", "missing average", []weatherRow{day("2020-01-01", func(r *weatherRow) { r.avgTemp = math.NaN() })}, nil},
+		{"physical_bounds", "valid", []weatherRow{day("2020-01-01", nil)}, nil},
+		{"physical_bounds", "humidity above 100", []weatherRow{day("2020-01-01", func(r *weatherRow) { r.humidity = 101 })}, []string{"2020-01-01"}},
+		{"physical_bounds", "negative irradiance", []weatherRow{day("2020-01-01", func(r *weatherRow) { r.irradiance = -1 })}, []string{"2020-01-01"}},
+		{"physical_bounds", "sunshine longer than daylight", []weatherRow{day("2020-01-01", func(r *weatherRow) { r.sunshine = 40001 })}, []string{"2020-01-01"}},
+		{"physical_bounds", "missing values", []weatherRow{day("2020-01-01", func(r *weatherRow) { r.humidity, r.daylight = math.NaN(), math.NaN() })}, nil},
+		{"stuck_value", "irradiance repeats", []weatherRow{day("2020-01-01", nil), day("2020-01-02", nil), day("2020-01-03", nil)}, []string{"2020-01-01", "2020-01-02", "2020-01-03"}},
+		{"stuck_value", "missing irradiance", []weatherRow{
+			day("2020-01-01", func(r *weatherRow) { r.irradiance = math.NaN() }),
+			day("2020-01-02", func(r *weatherRow) { r.irradiance = math.NaN() }),
+			day("2020-01-03", func(r *weatherRow) { r.irradiance = math.NaN() }),
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule+"/"+tt.name, func(t *testing.T) {
+			if got := periods(weatherRuleNamed(t, tt.rule).check(tt.rows)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("flagged %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStuckRuns(t *testing.T) {
+	tests := []struct {
+		values []float64
+		want   [][2]int
+	}{
+		{nil, nil},
+		{[]float64{1, 1, 1}, [][2]int{{0, 3}}},
+		{[]float64{1, 1, 2, 2, 2, 2, 3}, [][2]int{{2, 6}}},
+		{[]float64{0, 0, 0, 4, 4, 4}, [][2]int{{3, 6}}},
+		{[]float64{1, 2, 1, 2, 1}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.values), func(t *testing.T) {
+			if got := stuckRuns(tt.values); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("stuckRuns(%v) = %v, want %v", tt.values, got, tt.want)
+			}
+		})
+	}
+}
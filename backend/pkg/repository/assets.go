@@ -0,0 +1,54 @@
+package repository
+
+import (
+	structure "backend/pkg/struct"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AssetTree nests assets under their parents, given them flat in the order siblings should be
+// listed in
+func AssetTree(assets []structure.Asset) []structure.Asset {
+	children := make(map[int][]structure.Asset)
+	for _, asset := range assets {
+		if asset.ParentID != nil {
+			children[*asset.ParentID] = append(children[*asset.ParentID], asset)
+		}
+	}
+	var build func(asset structure.Asset) structure.Asset
+	build = func(asset structure.Asset) structure.Asset {
+		for _, child := range children[asset.ID] {
+			asset.Children = append(asset.Children, build(child))
+		}
+		return asset
+	}
+
+	tree := []structure.Asset{}
+	for _, asset := range assets {
+		if asset.ParentID == nil {
+			tree = append(tree, build(asset))
+		}
+	}
+	return tree
+}
+
+// ValidateAssetFields checks the fields of input that can be set on create and update, returning
+// an error wrapping ErrInvalidAsset
+func ValidateAssetFields(input structure.AssetInput) error {
+	if input.CapacityKW != nil && *input.CapacityKW <= 0 {
+		return fmt.Errorf("%w: capacityKw must be positive", ErrInvalidAsset)
+	}
+	if input.NumberOfPanels != nil && *input.NumberOfPanels < 0 {
+		return fmt.Errorf("%w: numberOfPanels cannot be negative", ErrInvalidAsset)
+	}
+	if input.CommissionedOn != "" {
+		if _, err := time.Parse("2006-01-02", input.CommissionedOn); err != nil {
+			return fmt.Errorf("%w: commissionedOn must be YYYY-MM-DD", ErrInvalidAsset)
+		}
+	}
+	if len(input.Metadata) > 0 && !json.Valid(input.Metadata) {
+		return fmt.Errorf("%w: metadata must be JSON", ErrInvalidAsset)
+	}
+	return nil
+}
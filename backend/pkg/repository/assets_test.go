@@ -0,0 +1,37 @@
+package repository
+
+import (
+	structure "backend/pkg/struct"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestValidateAssetFields(t *testing.T) {
+	capacity := func(v float64) *float64 { return &v }
+	panels := func(v int) *int { return &v }
+	tests := []struct {
+		name  string
+		input structure.AssetInput
+		valid bool
+	}{
+		{"no optional fields", structure.AssetInput{}, true},
+		{"every field", structure.AssetInput{CapacityKW: capacity(250), NumberOfPanels: panels(600),
+			CommissionedOn: "2019-04-01", Metadata: json.RawMessage(`{"mppt": 2}`)}, true},
+		{"zero capacity", structure.AssetInput{CapacityKW: capacity(0)}, false},
+		{"negative panels", structure.AssetInput{NumberOfPanels: panels(-1)}, false},
+		{"commissioning date format", structure.AssetInput{CommissionedOn: "01/04/2019"}, false},
+		{"metadata not JSON", structure.AssetInput{Metadata: json.RawMessage(`{mppt: 2}`)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAssetFields(tt.input)
+			if (err == nil) != tt.valid {
+				t.Fatalf("ValidateAssetFields() = %v, want valid %v", err, tt.valid)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidAsset) {
+				t.Errorf("ValidateAssetFields() = %v, want an ErrInvalidAsset", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Errors every repository reports in terms of, so handlers can choose a status without knowing
+// which query failed. The entity errors such as ErrAssetNotFound wrap ErrNotFound.
+var (
+	// ErrNotFound means the requested record, or any data for the request, does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrInvalidSite means a site name matches none of the locations
+	ErrInvalidSite = errors.New("invalid site")
+	// ErrStorage means the database failed; StorageError carries the cause
+	ErrStorage = errors.New("storage failure")
+
+	// ErrAssetNotFound means no asset has the requested ID
+	ErrAssetNotFound = fmt.Errorf("asset %w", ErrNotFound)
+	// ErrInvalidAsset wraps every asset validation failure
+	ErrInvalidAsset = errors.New("invalid asset")
+	// ErrImportNotFound means no import has the requested ID
+	ErrImportNotFound = fmt.Errorf("import %w", ErrNotFound)
+	// ErrQuarantineNotFound means no quarantine entry has the requested ID
+	ErrQuarantineNotFound = fmt.Errorf("quarantine entry %w", ErrNotFound)
+	// ErrEmissionFactorNotFound means no emission factor has the requested name
+	ErrEmissionFactorNotFound = fmt.Errorf("emission factor %w", ErrNotFound)
+)
+
+// EmissionFactorNaturalGas is the factor the CO2 offset is calculated with
+const EmissionFactorNaturalGas = "natural_gas"
+
+// StorageError is a database failure behind a query, as opposed to a request for missing or
+// invalid data. It matches ErrStorage.
+type StorageError struct {
+	Op  string
+	Err error
+}
+
+func (e *StorageError) Error() string {
+	return fmt.Sprintf("error %s: %v", e.Op, e.Err)
+}
+
+func (e *StorageError) Unwrap() error {
+	return e.Err
+}
+
+func (e *StorageError) Is(target error) bool {
+	return target == ErrStorage
+}
+
+// Wrap wraps err from the database as a StorageError, reporting sql.ErrNoRows as notFound
+// instead when it is set
+func Wrap(op string, err error, notFound error) error {
+	if notFound != nil && errors.Is(err, sql.ErrNoRows) {
+		return notFound
+	}
+	return &StorageError{Op: op, Err: err}
+}
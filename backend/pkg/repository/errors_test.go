@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	failure := errors.New("database is locked")
+	tests := []struct {
+		name     string
+		err      error
+		notFound error
+		is       []error
+		isNot    []error
+	}{
+		{"no rows as not found", sql.ErrNoRows, ErrAssetNotFound, []error{ErrAssetNotFound, ErrNotFound}, []error{ErrStorage}},
+		{"no rows as invalid site", sql.ErrNoRows, ErrInvalidSite, []error{ErrInvalidSite}, []error{ErrNotFound, ErrStorage}},
+		{"no rows without a not found error", sql.ErrNoRows, nil, []error{ErrStorage, sql.ErrNoRows}, []error{ErrNotFound}},
+		{"database failure", failure, ErrNotFound, []error{ErrStorage, failure}, []error{ErrNotFound}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Wrap("querying assets", tt.err, tt.notFound)
+			for _, target := range tt.is {
+				if !errors.Is(err, target) {
+					t.Errorf("Wrap() = %v, want it to match %v", err, target)
+				}
+			}
+			for _, target := range tt.isNot {
+				if errors.Is(err, target) {
+					t.Errorf("Wrap() = %v, want it not to match %v", err, target)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package memory
+
+import (
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type assetRepo struct {
+	data *Data
+}
+
+func (r assetRepo) Tree(site string) ([]structure.Asset, error) {
+	var assets []structure.Asset
+	for _, asset := range r.data.Assets {
+		if site == "" || asset.Site == site {
+			assets = append(assets, asset)
+		}
+	}
+	sort.SliceStable(assets, func(i, j int) bool {
+		if (assets[i].Kind == "string") != (assets[j].Kind == "string") {
+			return assets[j].Kind == "string"
+		}
+		return assets[i].Code < assets[j].Code
+	})
+	return repository.AssetTree(assets), nil
+}
+
+func (r assetRepo) List() ([]structure.Asset, error) {
+	assets := append([]structure.Asset{}, r.data.Assets...)
+	sort.Slice(assets, func(i, j int) bool { return assets[i].ID < assets[j].ID })
+	return assets, nil
+}
+
+func (r assetRepo) Get(id int) (*structure.Asset, error) {
+	for _, asset := range r.data.Assets {
+		if asset.ID == id {
+			return &asset, nil
+		}
+	}
+	return nil, repository.ErrAssetNotFound
+}
+
+func (r assetRepo) Create(input structure.AssetInput) (int, error) {
+	if input.Kind != "inverter" && input.Kind != "string" {
+		return 0, fmt.Errorf("%w: kind must be inverter or string", repository.ErrInvalidAsset)
+	}
+	input.Code = strings.TrimSpace(input.Code)
+	if input.Code == "" {
+		return 0, fmt.Errorf("%w: code is required", repository.ErrInvalidAsset)
+	}
+	if input.Name == "" {
+		input.Name = input.Code
+	}
+	if err := repository.ValidateAssetFields(input); err != nil {
+		return 0, err
+	}
+
+	site, err := siteRepo{r.data}.Resolve(input.Site)
+	if err != nil || site == "Total System" {
+		return 0, fmt.Errorf("%w: unknown site %q", repository.ErrInvalidAsset, input.Site)
+	}
+	parentCode := input.Parent
+	if parentCode == "" {
+		if input.Kind == "string" {
+			return 0, fmt.Errorf("%w: a string needs a parent inverter", repository.ErrInvalidAsset)
+		}
+		parentCode = site
+	}
+
+	var parent *structure.Asset
+	id := 0
+	for i, asset := range r.data.Assets {
+		if asset.Site == site && asset.Code == parentCode {
+			parent = &r.data.Assets[i]
+		}
+		if asset.Site == site && asset.Code == input.Code {
+			return 0, fmt.Errorf("%w: %s already has an asset %q", repository.ErrInvalidAsset, site, input.Code)
+		}
+		if asset.ID > id {
+			id = asset.ID
+		}
+	}
+	if parent == nil {
+		return 0, fmt.Errorf("%w: unknown parent %q at %s", repository.ErrInvalidAsset, parentCode, site)
+	}
+	if input.Kind == "inverter" && parent.Kind != "site" {
+		return 0, fmt.Errorf("%w: inverters sit directly under the site", repository.ErrInvalidAsset)
+	}
+	if input.Kind == "string" && parent.Kind != "inverter" {
+		return 0, fmt.Errorf("%w: strings sit under an inverter", repository.ErrInvalidAsset)
+	}
+
+	id++
+	parentID := parent.ID
+	r.data.Assets = append(r.data.Assets, structure.Asset{
+		ID: id, Site: site, ParentID: &parentID, Kind: input.Kind, Code: input.Code, Name: input.Name,
+		CapacityKW: input.CapacityKW, NumberOfPanels: input.NumberOfPanels, Manufacturer: input.Manufacturer,
+		Model: input.Model, SerialNumber: input.SerialNumber, CommissionedOn: input.CommissionedOn,
+		Metadata: input.Metadata,
+	})
+	return id, nil
+}
+
+func (r assetRepo) Update(id int, input structure.AssetInput) error {
+	asset, err := r.Get(id)
+	if err != nil {
+		return err
+	}
+	if (input.Site != "" && input.Site != asset.Site) || (input.Kind != "" && input.Kind != asset.Kind) ||
+		(input.Code != "" && input.Code != asset.Code) || input.Parent != "" {
+		return fmt.Errorf("%w: site, kind, code and parent cannot be changed", repository.ErrInvalidAsset)
+	}
+	if err := repository.ValidateAssetFields(input); err != nil {
+		return err
+	}
+	if asset.Kind == "site" && (input.CapacityKW != nil || input.NumberOfPanels != nil) {
+		return fmt.Errorf("%w: site capacity is set on the location", repository.ErrInvalidAsset)
+	}
+
+	for i := range r.data.Assets {
+		stored := &r.data.Assets[i]
+		if stored.ID != id {
+			continue
+		}
+		for _, field := range []struct {
+			value *string
+			given string
+		}{
+			{&stored.Name, input.Name},
+			{&stored.Manufacturer, input.Manufacturer},
+			{&stored.Model, input.Model},
+			{&stored.SerialNumber, input.SerialNumber},
+			{&stored.CommissionedOn, input.CommissionedOn},
+		} {
+			if field.given != "" {
+				*field.value = field.given
+			}
+		}
+		if input.CapacityKW != nil {
+			stored.CapacityKW = input.CapacityKW
+		}
+		if input.NumberOfPanels != nil {
+			stored.NumberOfPanels = input.NumberOfPanels
+		}
+		if len(input.Metadata) > 0 {
+			stored.Metadata = input.Metadata
+		}
+	}
+	return nil
+}
+
+func (r assetRepo) Performance(id int, monthly bool, from, to string) ([]structure.AssetPerformance, error) {
+	rows := r.data.AssetDaily
+	if monthly {
+		rows = r.data.AssetMonthly
+	}
+	performance := []structure.AssetPerformance{}
+	for _, row := range rows {
+		if row.AssetID == id && row.Period >= from && row.Period <= to {
+			performance = append(performance, row.AssetPerformance)
+		}
+	}
+	sort.Slice(performance, func(i, j int) bool { return performance[i].Period < performance[j].Period })
+	return performance, nil
+}
+
+func (r assetRepo) SitePerformance(site string, year, month int) ([]structure.AssetPerformanceRow, error) {
+	assets := make(map[int]structure.Asset, len(r.data.Assets))
+	for _, asset := range r.data.Assets {
+		assets[asset.ID] = asset
+	}
+
+	period := fmt.Sprintf("%04d-%02d", year, month)
+	performance := []structure.AssetPerformanceRow{}
+	for _, row := range r.data.AssetMonthly {
+		asset, ok := assets[row.AssetID]
+		if !ok || asset.Site != site || row.Period != period {
+			continue
+		}
+		row.ParentID, row.Kind, row.Code, row.Name = asset.ParentID, asset.Kind, asset.Code, asset.Name
+		performance = append(performance, row)
+	}
+
+	// Site first, then inverters, then strings, each from the worst performance ratio up
+	rank := map[string]int{"site": 0, "inverter": 1, "string": 2}
+	sort.SliceStable(performance, func(i, j int) bool {
+		a, b := performance[i], performance[j]
+		if rank[a.Kind] != rank[b.Kind] {
+			return rank[a.Kind] < rank[b.Kind]
+		}
+		if a.PerformanceRatio == nil || b.PerformanceRatio == nil {
+			return a.PerformanceRatio == nil && b.PerformanceRatio != nil
+		}
+		return *a.PerformanceRatio < *b.PerformanceRatio
+	})
+	return performance, nil
+}
+
+func (r assetRepo) Generation(monthly bool) ([]structure.AssetGeneration, error) {
+	return r.generation(monthly, func(kind string) bool { return kind != "site" })
+}
+
+func (r assetRepo) SiteGeneration(monthly bool) ([]structure.AssetGeneration, error) {
+	return r.generation(monthly, func(kind string) bool { return kind == "site" })
+}
+
+func (r assetRepo) generation(monthly bool, include func(kind string) bool) ([]structure.AssetGeneration, error) {
+	kinds := make(map[int]string, len(r.data.Assets))
+	for _, asset := range r.data.Assets {
+		kinds[asset.ID] = asset.Kind
+	}
+	generation := []structure.AssetGeneration{}
+	for _, g := range r.data.AssetGeneration {
+		if (len(g.Period) == len("2006-01")) == monthly && include(kinds[g.AssetID]) {
+			generation = append(generation, g)
+		}
+	}
+	return generation, nil
+}
+
+func (r assetRepo) ReplacePerformance(daily, monthly []structure.AssetPerformanceRow) error {
+	r.data.AssetDaily = append([]structure.AssetPerformanceRow{}, daily...)
+	r.data.AssetMonthly = append([]structure.AssetPerformanceRow{}, monthly...)
+	return nil
+}
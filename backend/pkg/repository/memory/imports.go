@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+	"sort"
+)
+
+type importRepo struct {
+	data *Data
+}
+
+func (r importRepo) List(status string, limit int) ([]structure.Import, error) {
+	imports := []structure.Import{}
+	for _, item := range r.data.Imports {
+		if status == "" || item.Status == status {
+			item.Changes, item.Rejections = nil, nil
+			imports = append(imports, item)
+		}
+	}
+	sort.Slice(imports, func(i, j int) bool { return imports[i].ID > imports[j].ID })
+	return limited(imports, limit), nil
+}
+
+func (r importRepo) Get(id int) (*structure.Import, error) {
+	for _, item := range r.data.Imports {
+		if item.ID == id {
+			return &item, nil
+		}
+	}
+	return nil, repository.ErrImportNotFound
+}
+
+// limited returns the first limit items
+func limited[T any](items []T, limit int) []T {
+	if limit >= 0 && len(items) > limit {
+		return items[:limit]
+	}
+	return items
+}
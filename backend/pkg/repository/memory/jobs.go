@@ -0,0 +1,43 @@
+package memory
+
+import (
+	structure "backend/pkg/struct"
+	"sort"
+)
+
+type jobRepo struct {
+	data *Data
+}
+
+func (r jobRepo) Runs(job string, limit int) ([]structure.JobRun, error) {
+	runs := []structure.JobRun{}
+	for _, run := range r.data.JobRuns {
+		if job == "" || run.JobName == job {
+			runs = append(runs, run)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].ID > runs[j].ID })
+	return limited(runs, limit), nil
+}
+
+func (r jobRepo) Last(status string) (*structure.JobRun, error) {
+	var last *structure.JobRun
+	for _, run := range r.data.JobRuns {
+		if (status == "" || run.Status == status) && (last == nil || run.ID > last.ID) {
+			run.Steps = nil
+			last = &run
+		}
+	}
+	return last, nil
+}
+
+func (r modelRepo) Runs(model string, limit int) ([]structure.ModelRun, error) {
+	runs := []structure.ModelRun{}
+	for _, run := range r.data.ModelRuns {
+		if model == "" || run.Model == model {
+			runs = append(runs, run)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].ID > runs[j].ID })
+	return limited(runs, limit), nil
+}
@@ -0,0 +1,447 @@
+// Package memory implements the repositories on data held in memory, for tests of handlers and
+// calculations that should not need a database
+package memory
+
+import (
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Data is everything the repositories answer from and write to. Slices may be in any order.
+// Writes change it in place, so it must not be shared between goroutines.
+type Data struct {
+	Sites []structure.Location
+	// Generation holds each site's months, keyed by location name; Total System has its own
+	Generation  map[string][]structure.ForecastResult
+	Calibration map[string]structure.ForecastCalibration
+	Daily       map[string][]structure.DailyGeneration
+	Intervals   map[string][]structure.IntervalGeneration
+
+	Weather []structure.WeatherImpactData
+	// WeatherDays are the dates (YYYY-MM-DD) of daily weather
+	WeatherDays []string
+
+	MonthlyGeneration  []structure.Generation
+	MonthlyPerformance []structure.Performance
+	YearlyPerformance  []structure.Performance
+	OverallPerformance []structure.Performance
+
+	FeatureImportance     []structure.FeatureImportance
+	SiteFeatureImportance []structure.SiteFeatureImportance
+	Explanations          []structure.ForecastExplanation
+	Models                []structure.ModelVersion
+	ModelRuns             []structure.ModelRun
+
+	// Days are daily generation with its theoretical output; DailyPerformance is what the daily
+	// performance calculation stored
+	Days             []structure.SiteDay
+	DailyPerformance []structure.SiteDay
+	Backtest         []structure.ForecastBacktest
+	WeatherMonths    []structure.WeatherMonth
+	WeatherDaily     []structure.WeatherDay
+
+	// Assets are flat, with parents before their children. AssetGeneration holds both the daily
+	// (YYYY-MM-DD) and monthly (YYYY-MM) periods of every asset, site assets included.
+	Assets          []structure.Asset
+	AssetGeneration []structure.AssetGeneration
+	AssetDaily      []structure.AssetPerformanceRow
+	AssetMonthly    []structure.AssetPerformanceRow
+
+	Imports         []structure.Import
+	Quarantine      []structure.QuarantineEntry
+	Gaps            []structure.DataGap
+	Revisions       []structure.Revision
+	EmissionFactors []structure.EmissionFactor
+	Scenarios       []structure.SavedScenario
+	OutlookIssues   []structure.OutlookIssue
+	// Outlooks holds each site's latest outlook, keyed by location name
+	Outlooks  map[string]structure.SiteOutlook
+	JobRuns   []structure.JobRun
+	Telemetry []structure.TelemetryReading
+}
+
+// New returns repositories that answer from data
+func New(data *Data) repository.Repos {
+	return repository.Repos{
+		Sites:           siteRepo{data},
+		Generation:      generationRepo{data},
+		Weather:         weatherRepo{data},
+		Performance:     performanceRepo{data},
+		Models:          modelRepo{data},
+		Assets:          assetRepo{data},
+		Imports:         importRepo{data},
+		Quality:         qualityRepo{data},
+		Revisions:       revisionRepo{data},
+		EmissionFactors: emissionFactorRepo{data},
+		Scenarios:       scenarioRepo{data},
+		Outlook:         outlookRepo{data},
+		Jobs:            jobRepo{data},
+		Telemetry:       telemetryRepo{data},
+		Status:          statusRepo{data},
+	}
+}
+
+type siteRepo struct {
+	data *Data
+}
+
+func (r siteRepo) Resolve(site string) (string, error) {
+	key := repository.SiteKey(site)
+	for _, location := range r.data.Sites {
+		if strings.ToLower(location.Name) == key {
+			return location.Name, nil
+		}
+	}
+	return "", repository.ErrInvalidSite
+}
+
+func (r siteRepo) List() ([]structure.Location, error) {
+	return append([]structure.Location(nil), r.data.Sites...), nil
+}
+
+func (r siteRepo) exists(site string) bool {
+	for _, location := range r.data.Sites {
+		if location.Name == site {
+			return true
+		}
+	}
+	return false
+}
+
+// name returns the name of the location with id, or "" when there is none
+func (r siteRepo) name(id int) string {
+	for _, location := range r.data.Sites {
+		if location.ID == id {
+			return location.Name
+		}
+	}
+	return ""
+}
+
+type generationRepo struct {
+	data *Data
+}
+
+// months returns a site's months, latest first
+func (r generationRepo) months(site string) ([]structure.ForecastResult, error) {
+	if !(siteRepo{r.data}).exists(site) {
+		return nil, repository.ErrInvalidSite
+	}
+	months := append([]structure.ForecastResult{}, r.data.Generation[site]...)
+	sort.Slice(months, func(i, j int) bool {
+		return months[i].Year*12+months[i].Month > months[j].Year*12+months[j].Month
+	})
+	return months, nil
+}
+
+func (r generationRepo) LastMonth(site string) (float64, error) {
+	months, err := r.months(site)
+	if err != nil {
+		return 0, err
+	}
+	if len(months) == 0 {
+		return 0, fmt.Errorf("no generation for %s: %w", site, repository.ErrNotFound)
+	}
+	return months[0].Actual, nil
+}
+
+func (r generationRepo) LastYear(site string) (float64, error) {
+	months, err := r.months(site)
+	if err != nil {
+		return 0, err
+	}
+	if len(months) == 0 {
+		return 0, fmt.Errorf("no generation for %s: %w", site, repository.ErrNotFound)
+	}
+	total := 0.0
+	for _, month := range months {
+		if month.Year == months[0].Year {
+			total += month.Actual
+		}
+	}
+	return total, nil
+}
+
+func (r generationRepo) Forecast(site string) ([]structure.ForecastResult, error) {
+	return r.months(site)
+}
+
+func (r generationRepo) Calibration(site string) (*structure.ForecastCalibration, error) {
+	calibration, ok := r.data.Calibration[site]
+	if !ok {
+		return nil, nil
+	}
+	return &calibration, nil
+}
+
+func (r generationRepo) Totals() (map[string]float64, error) {
+	totals := map[string]float64{}
+	for _, location := range r.data.Sites {
+		if location.Name == "Total System" {
+			continue
+		}
+		totals[location.Name] = 0
+		for _, month := range r.data.Generation[location.Name] {
+			totals[location.Name] += month.Actual
+		}
+	}
+	return totals, nil
+}
+
+func (r generationRepo) Daily(site, from, to string) ([]structure.DailyGeneration, error) {
+	days := []structure.DailyGeneration{}
+	for _, day := range r.data.Daily[site] {
+		if day.Date >= from && day.Date <= to {
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days, nil
+}
+
+func (r generationRepo) Intervals(site, date string) ([]structure.IntervalGeneration, error) {
+	intervals := []structure.IntervalGeneration{}
+	for _, interval := range r.data.Intervals[site] {
+		if strings.HasPrefix(interval.Start, date) {
+			intervals = append(intervals, interval)
+		}
+	}
+	sort.Slice(intervals, func(i, j int) bool {
+		if intervals[i].Start != intervals[j].Start {
+			return intervals[i].Start < intervals[j].Start
+		}
+		return intervals[i].Inverter < intervals[j].Inverter
+	})
+	return intervals, nil
+}
+
+func (r generationRepo) Latest() ([]structure.SiteFreshness, error) {
+	sites := []structure.SiteFreshness{}
+	for _, location := range r.data.Sites {
+		site := structure.SiteFreshness{Site: location.Name}
+		if months, _ := r.months(location.Name); len(months) > 0 {
+			site.LatestMonth = fmt.Sprintf("%04d-%02d", months[0].Year, months[0].Month)
+		}
+		sites = append(sites, site)
+	}
+	return sites, nil
+}
+
+func (r generationRepo) Actuals() ([]structure.Generation, error) {
+	return append([]structure.Generation{}, r.data.MonthlyGeneration...), nil
+}
+
+// Filled has nothing to impute, since nothing is quarantined in memory
+func (r generationRepo) Filled() ([]structure.Generation, error) {
+	months := []structure.Generation{}
+	for _, month := range r.data.MonthlyGeneration {
+		if month.TheoreticalKWH != 0 {
+			months = append(months, month)
+		}
+	}
+	return months, nil
+}
+
+func (r generationRepo) SetTheoretical(months []structure.Generation) error {
+	for _, month := range months {
+		found := false
+		for i, stored := range r.data.MonthlyGeneration {
+			if stored.Year == month.Year && stored.Month == month.Month && stored.LocationID == month.LocationID {
+				r.data.MonthlyGeneration[i].TheoreticalKWH = month.TheoreticalKWH
+				found = true
+			}
+		}
+		if !found {
+			month.LocationName = siteRepo{r.data}.name(month.LocationID)
+			month.ActualKWH = 0
+			r.data.MonthlyGeneration = append(r.data.MonthlyGeneration, month)
+		}
+	}
+	return nil
+}
+
+func (r generationRepo) FilledDays() ([]structure.SiteDay, error) {
+	days := []structure.SiteDay{}
+	for _, day := range r.data.Days {
+		if day.Theoretical != 0 {
+			days = append(days, day)
+		}
+	}
+	return days, nil
+}
+
+func (r generationRepo) ReplaceDailyTheoretical(days []structure.SiteDay) error {
+	theoretical := make(map[structure.SiteDay]float64, len(days))
+	for _, day := range days {
+		theoretical[structure.SiteDay{Date: day.Date, LocationID: day.LocationID}] = day.Theoretical
+	}
+	for i, day := range r.data.Days {
+		r.data.Days[i].Theoretical = theoretical[structure.SiteDay{Date: day.Date, LocationID: day.LocationID}]
+	}
+	return nil
+}
+
+func (r generationRepo) Backtest() ([]structure.ForecastBacktest, error) {
+	return append([]structure.ForecastBacktest{}, r.data.Backtest...), nil
+}
+
+func (r generationRepo) ReplaceCalibration(calibration map[int]structure.ForecastCalibration) error {
+	r.data.Calibration = make(map[string]structure.ForecastCalibration, len(calibration))
+	for id, c := range calibration {
+		r.data.Calibration[siteRepo{r.data}.name(id)] = c
+	}
+	return nil
+}
+
+type weatherRepo struct {
+	data *Data
+}
+
+func (r weatherRepo) Impact() ([]structure.WeatherImpactData, error) {
+	months := append([]structure.WeatherImpactData(nil), r.data.Weather...)
+	sort.Slice(months, func(i, j int) bool {
+		return months[i].Year*12+months[i].Month < months[j].Year*12+months[j].Month
+	})
+	return months, nil
+}
+
+func (r weatherRepo) Latest() (string, string, error) {
+	day, month := "", ""
+	for _, date := range r.data.WeatherDays {
+		if date > day {
+			day = date
+		}
+	}
+	for _, data := range r.data.Weather {
+		if key := fmt.Sprintf("%04d-%02d", data.Year, data.Month); key > month {
+			month = key
+		}
+	}
+	return day, month, nil
+}
+
+func (r weatherRepo) Months() ([]structure.WeatherMonth, error) {
+	months := append([]structure.WeatherMonth{}, r.data.WeatherMonths...)
+	sort.Slice(months, func(i, j int) bool {
+		return months[i].Year*12+months[i].Month < months[j].Year*12+months[j].Month
+	})
+	return months, nil
+}
+
+func (r weatherRepo) FilledMonths() ([]structure.WeatherMonth, error) {
+	return r.Months()
+}
+
+func (r weatherRepo) FilledDays() ([]structure.WeatherDay, error) {
+	days := append([]structure.WeatherDay{}, r.data.WeatherDaily...)
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days, nil
+}
+
+type performanceRepo struct {
+	data *Data
+}
+
+func (r performanceRepo) MonthlyGeneration() ([]structure.Generation, error) {
+	return append([]structure.Generation(nil), r.data.MonthlyGeneration...), nil
+}
+
+func (r performanceRepo) Monthly() ([]structure.Performance, error) {
+	return append([]structure.Performance(nil), r.data.MonthlyPerformance...), nil
+}
+
+func (r performanceRepo) Yearly() ([]structure.Performance, error) {
+	return append([]structure.Performance(nil), r.data.YearlyPerformance...), nil
+}
+
+func (r performanceRepo) Overall() ([]structure.Performance, error) {
+	return append([]structure.Performance(nil), r.data.OverallPerformance...), nil
+}
+
+func (r performanceRepo) Latest() ([]structure.LatestPerformance, error) {
+	latest := map[int]structure.Performance{}
+	for _, p := range r.data.MonthlyPerformance {
+		if current, ok := latest[p.LocationID]; !ok || p.Year*12+p.Month > current.Year*12+current.Month {
+			latest[p.LocationID] = p
+		}
+	}
+
+	sites := []structure.LatestPerformance{}
+	for _, location := range r.data.Sites {
+		p, ok := latest[location.ID]
+		if !ok {
+			continue
+		}
+		site := structure.LatestPerformance{Site: location.Name, PerformanceRatio: &p.PerformanceRatio, CapacityFactor: &p.CapacityFactor}
+		for _, month := range r.data.MonthlyGeneration {
+			if month.Year == p.Year && month.Month == p.Month && month.LocationID == p.LocationID {
+				site.Generation = month.ActualKWH
+			}
+		}
+		sites = append(sites, site)
+	}
+	return sites, nil
+}
+
+func (r performanceRepo) ReplaceMonthly(performance []structure.Performance) error {
+	r.data.MonthlyPerformance = append([]structure.Performance{}, performance...)
+	return nil
+}
+
+func (r performanceRepo) ReplaceYearly(performance []structure.Performance) error {
+	r.data.YearlyPerformance = append([]structure.Performance{}, performance...)
+	return nil
+}
+
+func (r performanceRepo) ReplaceOverall(performance []structure.Performance) error {
+	r.data.OverallPerformance = append([]structure.Performance{}, performance...)
+	return nil
+}
+
+func (r performanceRepo) ReplaceDaily(days []structure.SiteDay) error {
+	r.data.DailyPerformance = append([]structure.SiteDay{}, days...)
+	return nil
+}
+
+type modelRepo struct {
+	data *Data
+}
+
+func (r modelRepo) FeatureImportance() ([]structure.FeatureImportance, error) {
+	features := append([]structure.FeatureImportance(nil), r.data.FeatureImportance...)
+	sort.SliceStable(features, func(i, j int) bool { return features[i].ImportanceValue > features[j].ImportanceValue })
+	return features, nil
+}
+
+// SiteFeatureImportance takes the last matching entry as the latest run
+func (r modelRepo) SiteFeatureImportance(site, model string, runID int64) (*structure.SiteFeatureImportance, error) {
+	for i := len(r.data.SiteFeatureImportance) - 1; i >= 0; i-- {
+		importance := r.data.SiteFeatureImportance[i]
+		if importance.Site != site || importance.Model != model {
+			continue
+		}
+		if runID != 0 && (importance.ModelRunID == nil || *importance.ModelRunID != runID) {
+			continue
+		}
+		return &importance, nil
+	}
+	return nil, nil
+}
+
+func (r modelRepo) Explanation(site string, year, month int) (*structure.ForecastExplanation, error) {
+	for _, explanation := range r.data.Explanations {
+		if explanation.Site == site && explanation.Year == year && explanation.Month == month {
+			return &explanation, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r modelRepo) Versions() ([]structure.ModelVersion, error) {
+	versions := append([]structure.ModelVersion{}, r.data.Models...)
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Model < versions[j].Model })
+	return versions, nil
+}
@@ -0,0 +1,24 @@
+package memory
+
+import (
+	structure "backend/pkg/struct"
+	"sort"
+)
+
+type outlookRepo struct {
+	data *Data
+}
+
+func (r outlookRepo) Issues(limit int) ([]structure.OutlookIssue, error) {
+	issues := append([]structure.OutlookIssue{}, r.data.OutlookIssues...)
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].IssuedAt > issues[j].IssuedAt })
+	return limited(issues, limit), nil
+}
+
+func (r outlookRepo) Site(site string) (*structure.SiteOutlook, error) {
+	outlook, ok := r.data.Outlooks[site]
+	if !ok {
+		return nil, nil
+	}
+	return &outlook, nil
+}
@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+	"sort"
+)
+
+type qualityRepo struct {
+	data *Data
+}
+
+func (r qualityRepo) Quarantine(dataset, status, site string, limit int) ([]structure.QuarantineEntry, error) {
+	entries := []structure.QuarantineEntry{}
+	for _, entry := range r.data.Quarantine {
+		if (dataset == "" || entry.Dataset == dataset) && (status == "" || entry.Status == status) &&
+			(site == "" || entry.Site == site) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if !a.DetectedAt.Equal(b.DetectedAt) {
+			return a.DetectedAt.After(b.DetectedAt)
+		}
+		if a.Period != b.Period {
+			return a.Period > b.Period
+		}
+		return a.ID > b.ID
+	})
+	return limited(entries, limit), nil
+}
+
+func (r qualityRepo) Entry(id int) (*structure.QuarantineEntry, error) {
+	for _, entry := range r.data.Quarantine {
+		if entry.ID == id {
+			return &entry, nil
+		}
+	}
+	return nil, repository.ErrQuarantineNotFound
+}
+
+func (r qualityRepo) Gaps(dataset, site string, imputed *bool, limit int) ([]structure.DataGap, error) {
+	gaps := []structure.DataGap{}
+	for _, gap := range r.data.Gaps {
+		if (dataset == "" || gap.Dataset == dataset) && (site == "" || gap.Site == site) &&
+			(imputed == nil || (gap.Value != nil) == *imputed) {
+			gaps = append(gaps, gap)
+		}
+	}
+	sort.SliceStable(gaps, func(i, j int) bool {
+		if gaps[i].Dataset != gaps[j].Dataset {
+			return gaps[i].Dataset < gaps[j].Dataset
+		}
+		return gaps[i].Period < gaps[j].Period
+	})
+	return limited(gaps, limit), nil
+}
+
+func (r qualityRepo) Open() (map[string]int, error) {
+	open := map[string]int{}
+	for _, entry := range r.data.Quarantine {
+		if entry.Status == "quarantined" {
+			open[entry.Dataset]++
+		}
+	}
+	return open, nil
+}
@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"backend/pkg/audit"
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+	"sort"
+	"time"
+)
+
+type revisionRepo struct {
+	data *Data
+}
+
+func (r revisionRepo) List(table, key string, limit int) ([]structure.Revision, error) {
+	revisions := []structure.Revision{}
+	for _, revision := range r.data.Revisions {
+		if (table == "" || revision.Table == table) && (key == "" || revision.Key == key) {
+			revisions = append(revisions, revision)
+		}
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].ID > revisions[j].ID })
+	return limited(revisions, limit), nil
+}
+
+func (r revisionRepo) AsOf(table, key string, at time.Time) ([]structure.RevisionValue, error) {
+	type column struct{ table, key, column string }
+	latest := map[column]structure.Revision{}
+	for _, revision := range r.data.Revisions {
+		if (table != "" && revision.Table != table) || (key != "" && revision.Key != key) || revision.ChangedAt.After(at) {
+			continue
+		}
+		c := column{revision.Table, revision.Key, revision.Column}
+		if current, ok := latest[c]; !ok || revision.ID > current.ID {
+			latest[c] = revision
+		}
+	}
+
+	values := []structure.RevisionValue{}
+	for _, revision := range latest {
+		if revision.NewValue != nil {
+			values = append(values, structure.RevisionValue{
+				Table: revision.Table, Key: revision.Key, Column: revision.Column, Value: *revision.NewValue,
+				RevisionID: revision.ID, ChangedAt: revision.ChangedAt,
+			})
+		}
+	}
+	sort.Slice(values, func(i, j int) bool {
+		a, b := values[i], values[j]
+		if a.Table != b.Table {
+			return a.Table < b.Table
+		}
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return a.Column < b.Column
+	})
+	return values, nil
+}
+
+type emissionFactorRepo struct {
+	data *Data
+}
+
+func (r emissionFactorRepo) List() ([]structure.EmissionFactor, error) {
+	factors := append([]structure.EmissionFactor{}, r.data.EmissionFactors...)
+	sort.Slice(factors, func(i, j int) bool { return factors[i].Name < factors[j].Name })
+	return factors, nil
+}
+
+func (r emissionFactorRepo) Get(name string) (*structure.EmissionFactor, error) {
+	for _, factor := range r.data.EmissionFactors {
+		if factor.Name == name {
+			return &factor, nil
+		}
+	}
+	return nil, repository.ErrEmissionFactorNotFound
+}
+
+// Update records the change as a revision of the factor's value
+func (r emissionFactorRepo) Update(name string, value float64, change audit.Change) (*structure.EmissionFactor, error) {
+	for i := range r.data.EmissionFactors {
+		factor := &r.data.EmissionFactors[i]
+		if factor.Name != name {
+			continue
+		}
+		now := time.Now().UTC()
+		old := factor.Value
+		r.data.Revisions = append(r.data.Revisions, structure.Revision{
+			ID: len(r.data.Revisions) + 1, Table: "emission_factors", Key: name, Column: "value",
+			OldValue: &old, NewValue: &value, ChangedBy: change.Author, Reason: change.Reason, ChangedAt: now,
+		})
+		factor.Value, factor.UpdatedAt = value, now
+		updated := *factor
+		return &updated, nil
+	}
+	return nil, repository.ErrEmissionFactorNotFound
+}
@@ -0,0 +1,30 @@
+package memory
+
+import (
+	structure "backend/pkg/struct"
+	"sort"
+	"time"
+)
+
+type scenarioRepo struct {
+	data *Data
+}
+
+func (r scenarioRepo) List() ([]structure.SavedScenario, error) {
+	scenarios := append([]structure.SavedScenario{}, r.data.Scenarios...)
+	sort.Slice(scenarios, func(i, j int) bool {
+		if scenarios[i].CreatedAt != scenarios[j].CreatedAt {
+			return scenarios[i].CreatedAt > scenarios[j].CreatedAt
+		}
+		return scenarios[i].ID > scenarios[j].ID
+	})
+	return scenarios, nil
+}
+
+func (r scenarioRepo) Save(req structure.ScenarioRequest, response structure.ScenarioResponse) (int64, error) {
+	id := int64(len(r.data.Scenarios) + 1)
+	r.data.Scenarios = append(r.data.Scenarios, structure.SavedScenario{
+		ID: id, Name: req.Name, CreatedAt: time.Now().UTC().Format("2006-01-02 15:04:05"),
+	})
+	return id, nil
+}
@@ -0,0 +1,28 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+)
+
+type statusRepo struct {
+	data *Data
+}
+
+func (r statusRepo) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (r statusRepo) Populated(table string) (bool, error) {
+	switch table {
+	case "locations":
+		return len(r.data.Sites) > 0, nil
+	case "monthly_generation":
+		return len(r.data.MonthlyGeneration) > 0 || len(r.data.Generation) > 0, nil
+	case "monthly_performance":
+		return len(r.data.MonthlyPerformance) > 0, nil
+	case "weather_daily":
+		return len(r.data.WeatherDays) > 0, nil
+	}
+	return false, fmt.Errorf("unknown table %q", table)
+}
@@ -0,0 +1,26 @@
+package memory
+
+import (
+	structure "backend/pkg/struct"
+	"sort"
+)
+
+type telemetryRepo struct {
+	data *Data
+}
+
+func (r telemetryRepo) Readings(site, inverter string, limit int) ([]structure.TelemetryReading, error) {
+	readings := []structure.TelemetryReading{}
+	for _, reading := range r.data.Telemetry {
+		if reading.Site == site && (inverter == "" || reading.Inverter == inverter) {
+			readings = append(readings, reading)
+		}
+	}
+	sort.Slice(readings, func(i, j int) bool {
+		if readings[i].ReadAt != readings[j].ReadAt {
+			return readings[i].ReadAt > readings[j].ReadAt
+		}
+		return readings[i].Inverter < readings[j].Inverter
+	})
+	return limited(readings, limit), nil
+}
@@ -0,0 +1,264 @@
+// Package repository defines the storage the dashboard reads from and the calculations write to.
+// Handlers and calculations are given these interfaces through their constructors instead of
+// sharing a database handle, so they run against SQLite or PostgreSQL in the server and against the
+// in-memory implementation in tests.
+package repository
+
+import (
+	"backend/pkg/audit"
+	structure "backend/pkg/struct"
+	"context"
+	"strings"
+	"time"
+)
+
+// SiteRepo reads the sites
+type SiteRepo interface {
+	// Resolve maps a site from a URL path, such as "awali" or "total-system", to its location
+	// name. It returns ErrInvalidSite when no location matches.
+	Resolve(site string) (string, error)
+	// List returns the sites with their capacity, in the order they were created
+	List() ([]structure.Location, error)
+}
+
+// GenerationRepo reads monthly and daily generation and the generation forecast
+type GenerationRepo interface {
+	// LastMonth returns the generation in kWh of the latest month recorded for a site. It
+	// returns ErrNotFound when the site has no generation, and ErrInvalidSite when there is no
+	// such site.
+	LastMonth(site string) (float64, error)
+	// LastYear returns the total generation in kWh of the latest year recorded for a site, with
+	// the same errors as LastMonth
+	LastYear(site string) (float64, error)
+	// Forecast returns actual and predicted generation for a site, latest month first. It
+	// returns ErrInvalidSite when there is no such site.
+	Forecast(site string) ([]structure.ForecastResult, error)
+	// Calibration returns the backtest coverage of the forecast bands for a site, or nil when
+	// the model has not been backtested there
+	Calibration(site string) (*structure.ForecastCalibration, error)
+	// Totals returns the generation in kWh to date of each site, keyed by name, leaving out
+	// Total System
+	Totals() (map[string]float64, error)
+	// Daily returns a site's daily generation between from and to (YYYY-MM-DD, inclusive),
+	// including the missing days that were imputed
+	Daily(site, from, to string) ([]structure.DailyGeneration, error)
+	// Intervals returns a site's interval generation on one day (YYYY-MM-DD), per inverter
+	Intervals(site, date string) ([]structure.IntervalGeneration, error)
+	// Latest returns each site with its latest month of actual generation, which is left
+	// empty for a site without any
+	Latest() ([]structure.SiteFreshness, error)
+
+	// Actuals returns every month with recorded actual generation, as recorded
+	Actuals() ([]structure.Generation, error)
+	// Filled returns every month with both actual and theoretical generation, with quarantined
+	// months left out and gaps imputed
+	Filled() ([]structure.Generation, error)
+	// SetTheoretical stores the theoretical generation of each month and location, adding the
+	// months that have no generation yet
+	SetTheoretical(months []structure.Generation) error
+	// FilledDays returns every day with both actual and theoretical generation, with
+	// quarantined days left out and gaps imputed
+	FilledDays() ([]structure.SiteDay, error)
+	// ReplaceDailyTheoretical stores the theoretical generation of the given days and clears it
+	// on every other day. Days without daily generation are ignored.
+	ReplaceDailyTheoretical(days []structure.SiteDay) error
+	// Backtest returns the actual generation of every month the forecast quantiles cover
+	Backtest() ([]structure.ForecastBacktest, error)
+	// ReplaceCalibration replaces the forecast calibration of every location, keyed by ID
+	ReplaceCalibration(calibration map[int]structure.ForecastCalibration) error
+}
+
+// WeatherRepo reads the weather archive
+type WeatherRepo interface {
+	// Impact returns each month of weather with the generation of all sites that month
+	Impact() ([]structure.WeatherImpactData, error)
+	// Latest returns the latest day of daily weather and month of monthly weather, empty when
+	// there is none
+	Latest() (day string, month string, err error)
+	// Months returns the average daily sunshine and irradiance of each month as recorded,
+	// oldest first, leaving out months without either
+	Months() ([]structure.WeatherMonth, error)
+	// FilledMonths returns the same averages with quarantined days left out and gaps imputed
+	FilledMonths() ([]structure.WeatherMonth, error)
+	// FilledDays returns each day with both sunshine and irradiance, with quarantined days left
+	// out and gaps imputed, oldest first
+	FilledDays() ([]structure.WeatherDay, error)
+}
+
+// PerformanceRepo reads and stores the performance metrics calculated from generation
+type PerformanceRepo interface {
+	// MonthlyGeneration returns actual and theoretical generation of each site and month
+	MonthlyGeneration() ([]structure.Generation, error)
+	// Monthly returns each site's performance ratio, capacity factor and output per panel by month
+	Monthly() ([]structure.Performance, error)
+	// Yearly returns the same metrics by year
+	Yearly() ([]structure.Performance, error)
+	// Overall returns the same metrics across every year
+	Overall() ([]structure.Performance, error)
+	// Latest returns each site's latest month of performance with its generation
+	Latest() ([]structure.LatestPerformance, error)
+
+	// ReplaceMonthly replaces the monthly metrics of every site
+	ReplaceMonthly(performance []structure.Performance) error
+	// ReplaceYearly replaces the yearly metrics of every site
+	ReplaceYearly(performance []structure.Performance) error
+	// ReplaceOverall replaces the metrics across every year of every site
+	ReplaceOverall(performance []structure.Performance) error
+	// ReplaceDaily replaces the daily metrics of every site
+	ReplaceDaily(days []structure.SiteDay) error
+}
+
+// ModelRepo reads what the models stored about themselves and their forecasts
+type ModelRepo interface {
+	// FeatureImportance returns the weather model's feature importance across all sites, most
+	// important first
+	FeatureImportance() ([]structure.FeatureImportance, error)
+	// SiteFeatureImportance returns a site's feature importance from one model run, or from the
+	// latest run when runID is 0. It returns nil when nothing has been stored.
+	SiteFeatureImportance(site, model string, runID int64) (*structure.SiteFeatureImportance, error)
+	// Explanation returns the stored attributions for one site and month, or nil when the month
+	// has no forecast
+	Explanation(site string, year, month int) (*structure.ForecastExplanation, error)
+	// Versions returns the latest successful run of each model, whose output is the one in use
+	Versions() ([]structure.ModelVersion, error)
+	// Runs returns the most recent model runs, newest first, optionally of one model
+	Runs(model string, limit int) ([]structure.ModelRun, error)
+}
+
+// AssetRepo reads and edits the site → inverter → string hierarchy and its performance
+type AssetRepo interface {
+	// Tree returns the asset tree of one site, or of every site when site is empty
+	Tree(site string) ([]structure.Asset, error)
+	// List returns every asset without its children
+	List() ([]structure.Asset, error)
+	// Get returns one asset without its children, or ErrAssetNotFound
+	Get(id int) (*structure.Asset, error)
+	// Create adds an inverter or string and returns its ID. Invalid input returns an error
+	// wrapping ErrInvalidAsset.
+	Create(input structure.AssetInput) (int, error)
+	// Update changes an asset's name, capacity and metadata. Omitted fields keep their value.
+	Update(id int, input structure.AssetInput) error
+	// Performance returns an asset's daily (from and to are YYYY-MM-DD) or monthly (from and
+	// to are YYYY-MM) performance, oldest first
+	Performance(id int, monthly bool, from, to string) ([]structure.AssetPerformance, error)
+	// SitePerformance returns one month of performance for every asset at a site
+	SitePerformance(site string, year, month int) ([]structure.AssetPerformanceRow, error)
+
+	// Generation returns the daily or monthly generation of every inverter and string with
+	// their site's theoretical generation
+	Generation(monthly bool) ([]structure.AssetGeneration, error)
+	// SiteGeneration returns the daily or monthly generation of every site asset, with
+	// quarantined periods left out
+	SiteGeneration(monthly bool) ([]structure.AssetGeneration, error)
+	// ReplacePerformance replaces the daily and monthly performance of every asset. Only the
+	// asset ID and the performance of each row are stored.
+	ReplacePerformance(daily, monthly []structure.AssetPerformanceRow) error
+}
+
+// ImportRepo reads the uploads staged for review
+type ImportRepo interface {
+	// List returns the most recent uploads without their row-level changes, optionally
+	// filtered by status
+	List(status string, limit int) ([]structure.Import, error)
+	// Get returns one upload with its changes, or ErrImportNotFound
+	Get(id int) (*structure.Import, error)
+}
+
+// QualityRepo reads what the data quality checks quarantined and imputed
+type QualityRepo interface {
+	// Quarantine returns the most recent entries, each filter applying when it is not empty
+	Quarantine(dataset, status, site string, limit int) ([]structure.QuarantineEntry, error)
+	// Entry returns one entry, or ErrQuarantineNotFound
+	Entry(id int) (*structure.QuarantineEntry, error)
+	// Gaps returns the most recent gaps, optionally only those that were or were not imputed
+	Gaps(dataset, site string, imputed *bool, limit int) ([]structure.DataGap, error)
+	// Open returns the number of entries awaiting review by dataset
+	Open() (map[string]int, error)
+}
+
+// RevisionRepo reads the history of audited values
+type RevisionRepo interface {
+	// List returns revisions newest first, filtered by table and record key when they are set
+	List(table, key string, limit int) ([]structure.Revision, error)
+	// AsOf returns the value of every audited column of a table at a point in time
+	AsOf(table, key string, at time.Time) ([]structure.RevisionValue, error)
+}
+
+// EmissionFactorRepo reads and edits the emission factors
+type EmissionFactorRepo interface {
+	List() ([]structure.EmissionFactor, error)
+	// Get returns one factor, or ErrEmissionFactorNotFound
+	Get(name string) (*structure.EmissionFactor, error)
+	// Update sets a factor and records change as its revision
+	Update(name string, value float64, change audit.Change) (*structure.EmissionFactor, error)
+}
+
+// ScenarioRepo reads and stores what-if scenarios
+type ScenarioRepo interface {
+	List() ([]structure.SavedScenario, error)
+	// Save stores a scenario run and returns its ID
+	Save(req structure.ScenarioRequest, response structure.ScenarioResponse) (int64, error)
+}
+
+// OutlookRepo reads the weather outlooks and the forecasts made from them
+type OutlookRepo interface {
+	// Issues returns the most recent outlook issues, newest first
+	Issues(limit int) ([]structure.OutlookIssue, error)
+	// Site returns a site's latest forecast outlook, or nil when there is none
+	Site(site string) (*structure.SiteOutlook, error)
+}
+
+// JobRepo reads the history of pipeline runs
+type JobRepo interface {
+	// Runs returns the most recent runs with their steps, optionally of one job
+	Runs(job string, limit int) ([]structure.JobRun, error)
+	// Last returns the most recent run, or the most recent with status if it is set, without
+	// its steps. It returns nil when there is none.
+	Last(status string) (*structure.JobRun, error)
+}
+
+// TelemetryRepo reads live inverter readings
+type TelemetryRepo interface {
+	// Readings returns a site's most recent readings, optionally of one inverter
+	Readings(site, inverter string, limit int) ([]structure.TelemetryReading, error)
+}
+
+// StatusRepo answers whether the storage is usable
+type StatusRepo interface {
+	// Ping checks the storage can be reached
+	Ping(ctx context.Context) error
+	// Populated reports whether table holds any rows. table must be one of ReadyTables.
+	Populated(table string) (bool, error)
+}
+
+// ReadyTables must hold rows before the server can answer the dashboard
+var ReadyTables = []string{"locations", "monthly_generation", "monthly_performance", "weather_daily"}
+
+// Repos is one implementation of every repository
+type Repos struct {
+	Sites           SiteRepo
+	Generation      GenerationRepo
+	Weather         WeatherRepo
+	Performance     PerformanceRepo
+	Models          ModelRepo
+	Assets          AssetRepo
+	Imports         ImportRepo
+	Quality         QualityRepo
+	Revisions       RevisionRepo
+	EmissionFactors EmissionFactorRepo
+	Scenarios       ScenarioRepo
+	Outlook         OutlookRepo
+	Jobs            JobRepo
+	Telemetry       TelemetryRepo
+	Status          StatusRepo
+}
+
+// SiteKey is how Resolve matches a site from a URL path against location names: lowercase, with
+// dashes for spaces, and "total" for Total System
+func SiteKey(site string) string {
+	key := strings.ToLower(strings.ReplaceAll(site, "-", " "))
+	if key == "total" {
+		key = "total system"
+	}
+	return key
+}
@@ -0,0 +1,477 @@
+package sqlstore
+
+import (
+	"backend/pkg/db"
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+type assetRepo struct {
+	db *db.DB
+}
+
+func (r assetRepo) columns() string {
+	return `
+	a.id, l.name, a.parent_id, a.kind, a.code, a.name, a.capacity_kw, a.number_of_panels,
+	COALESCE(a.manufacturer, ''), COALESCE(a.model, ''), COALESCE(a.serial_number, ''),
+	COALESCE(` + r.db.Dialect.Day("a.commissioned_on") + `, ''), COALESCE(a.metadata_json, '')`
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAsset(row rowScanner) (structure.Asset, error) {
+	var asset structure.Asset
+	var parentID, panels sql.NullInt64
+	var capacity sql.NullFloat64
+	var metadata string
+	err := row.Scan(&asset.ID, &asset.Site, &parentID, &asset.Kind, &asset.Code, &asset.Name, &capacity, &panels,
+		&asset.Manufacturer, &asset.Model, &asset.SerialNumber, &asset.CommissionedOn, &metadata)
+	if err != nil {
+		return asset, err
+	}
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		asset.ParentID = &id
+	}
+	asset.CapacityKW = nullFloat(capacity)
+	if panels.Valid {
+		count := int(panels.Int64)
+		asset.NumberOfPanels = &count
+	}
+	if metadata != "" {
+		asset.Metadata = json.RawMessage(metadata)
+	}
+	return asset, nil
+}
+
+func (r assetRepo) Tree(location string) ([]structure.Asset, error) {
+	rows, err := r.db.Query(`
+		SELECT `+r.columns()+`
+		FROM assets a
+		JOIN locations l ON a.location_id = l.id
+		WHERE ? = '' OR l.name = ?
+		ORDER BY l.id, a.kind = 'string', a.code
+	`, location, location)
+	if err != nil {
+		slog.Error("Error querying assets", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assets []structure.Asset
+	for rows.Next() {
+		asset, err := scanAsset(rows)
+		if err != nil {
+			slog.Error("Error scanning asset", "err", err)
+			return nil, err
+		}
+		assets = append(assets, asset)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return repository.AssetTree(assets), nil
+}
+
+func (r assetRepo) Get(id int) (*structure.Asset, error) {
+	asset, err := scanAsset(r.db.QueryRow(`
+		SELECT `+r.columns()+`
+		FROM assets a
+		JOIN locations l ON a.location_id = l.id
+		WHERE a.id = ?
+	`, id))
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrAssetNotFound
+	}
+	if err != nil {
+		slog.Error("Error querying asset", "id", id, "err", err)
+		return nil, err
+	}
+	return &asset, nil
+}
+
+func (r assetRepo) Create(input structure.AssetInput) (int, error) {
+	if input.Kind != "inverter" && input.Kind != "string" {
+		return 0, fmt.Errorf("%w: kind must be inverter or string", repository.ErrInvalidAsset)
+	}
+	input.Code = strings.TrimSpace(input.Code)
+	if input.Code == "" {
+		return 0, fmt.Errorf("%w: code is required", repository.ErrInvalidAsset)
+	}
+	if input.Name == "" {
+		input.Name = input.Code
+	}
+	if err := repository.ValidateAssetFields(input); err != nil {
+		return 0, err
+	}
+
+	site, err := siteRepo{r.db}.Resolve(input.Site)
+	if errors.Is(err, repository.ErrInvalidSite) || site == "Total System" {
+		return 0, fmt.Errorf("%w: unknown site %q", repository.ErrInvalidAsset, input.Site)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var locationID, parentID int
+	var parentKind string
+	parentCode := input.Parent
+	if parentCode == "" {
+		if input.Kind == "string" {
+			return 0, fmt.Errorf("%w: a string needs a parent inverter", repository.ErrInvalidAsset)
+		}
+		parentCode = site
+	}
+	err = r.db.QueryRow(`
+		SELECT a.location_id, a.id, a.kind
+		FROM assets a
+		JOIN locations l ON a.location_id = l.id
+		WHERE l.name = ? AND a.code = ?
+	`, site, parentCode).Scan(&locationID, &parentID, &parentKind)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("%w: unknown parent %q at %s", repository.ErrInvalidAsset, parentCode, site)
+	}
+	if err != nil {
+		slog.Error("Error looking up parent asset", "err", err)
+		return 0, err
+	}
+	if input.Kind == "inverter" && parentKind != "site" {
+		return 0, fmt.Errorf("%w: inverters sit directly under the site", repository.ErrInvalidAsset)
+	}
+	if input.Kind == "string" && parentKind != "inverter" {
+		return 0, fmt.Errorf("%w: strings sit under an inverter", repository.ErrInvalidAsset)
+	}
+
+	var id int
+	err = r.db.QueryRow(`
+		INSERT INTO assets (
+			location_id, parent_id, kind, code, name, capacity_kw, number_of_panels,
+			manufacturer, model, serial_number, commissioned_on, metadata_json
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, locationID, parentID, input.Kind, input.Code, input.Name, input.CapacityKW, input.NumberOfPanels,
+		nullString(input.Manufacturer), nullString(input.Model), nullString(input.SerialNumber),
+		nullString(input.CommissionedOn), nullString(string(input.Metadata))).Scan(&id)
+	if err != nil {
+		if db.IsUniqueViolation(err) {
+			return 0, fmt.Errorf("%w: %s already has an asset %q", repository.ErrInvalidAsset, site, input.Code)
+		}
+		slog.Error("Error inserting asset", "err", err)
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (r assetRepo) Update(id int, input structure.AssetInput) error {
+	asset, err := r.Get(id)
+	if err != nil {
+		return err
+	}
+	if (input.Site != "" && input.Site != asset.Site) || (input.Kind != "" && input.Kind != asset.Kind) ||
+		(input.Code != "" && input.Code != asset.Code) || input.Parent != "" {
+		return fmt.Errorf("%w: site, kind, code and parent cannot be changed", repository.ErrInvalidAsset)
+	}
+	if err := repository.ValidateAssetFields(input); err != nil {
+		return err
+	}
+	if asset.Kind == "site" && (input.CapacityKW != nil || input.NumberOfPanels != nil) {
+		return fmt.Errorf("%w: site capacity is set on the location", repository.ErrInvalidAsset)
+	}
+
+	if input.Name == "" {
+		input.Name = asset.Name
+	}
+	if input.CapacityKW == nil {
+		input.CapacityKW = asset.CapacityKW
+	}
+	if input.NumberOfPanels == nil {
+		input.NumberOfPanels = asset.NumberOfPanels
+	}
+	for _, field := range []struct {
+		value   *string
+		current string
+	}{
+		{&input.Manufacturer, asset.Manufacturer},
+		{&input.Model, asset.Model},
+		{&input.SerialNumber, asset.SerialNumber},
+		{&input.CommissionedOn, asset.CommissionedOn},
+	} {
+		if *field.value == "" {
+			*field.value = field.current
+		}
+	}
+	if len(input.Metadata) == 0 {
+		input.Metadata = asset.Metadata
+	}
+
+	_, err = r.db.Exec(`
+		UPDATE assets SET
+			name = ?,
+			capacity_kw = CASE WHEN kind = 'site' THEN capacity_kw ELSE ? END,
+			number_of_panels = CASE WHEN kind = 'site' THEN number_of_panels ELSE ? END,
+			manufacturer = ?, model = ?, serial_number = ?, commissioned_on = ?, metadata_json = ?,
+			last_updated = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, input.Name, input.CapacityKW, input.NumberOfPanels,
+		nullString(input.Manufacturer), nullString(input.Model), nullString(input.SerialNumber),
+		nullString(input.CommissionedOn), nullString(string(input.Metadata)), id)
+	if err != nil {
+		slog.Error("Error updating asset", "id", id, "err", err)
+	}
+	return err
+}
+
+func (r assetRepo) Performance(id int, monthly bool, from, to string) ([]structure.AssetPerformance, error) {
+	day, period := r.db.Dialect.Day("date"), r.db.Dialect.Period("year", "month")
+	query := `
+		SELECT ` + day + `, actual_kwh, expected_kwh, performance_ratio, capacity_factor, specific_yield, availability
+		FROM asset_daily_performance
+		WHERE asset_id = ? AND ` + day + ` BETWEEN ? AND ?
+		ORDER BY date`
+	if monthly {
+		query = `
+		SELECT ` + period + ` AS period, actual_kwh, expected_kwh,
+			performance_ratio, capacity_factor, specific_yield, availability
+		FROM asset_monthly_performance
+		WHERE asset_id = ? AND ` + period + ` BETWEEN ? AND ?
+		ORDER BY year, month`
+	}
+
+	rows, err := r.db.Query(query, id, from, to)
+	if err != nil {
+		slog.Error("Error querying asset performance", "id", id, "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	performance := []structure.AssetPerformance{}
+	for rows.Next() {
+		p, err := scanAssetPerformance(rows)
+		if err != nil {
+			slog.Error("Error scanning asset performance", "err", err)
+			return nil, err
+		}
+		performance = append(performance, p)
+	}
+	return performance, rows.Err()
+}
+
+func (r assetRepo) SitePerformance(location string, year, month int) ([]structure.AssetPerformanceRow, error) {
+	rows, err := r.db.Query(`
+		SELECT a.id, a.parent_id, a.kind, a.code, a.name,
+			`+r.db.Dialect.Period("p.year", "p.month")+`, p.actual_kwh, p.expected_kwh,
+			p.performance_ratio, p.capacity_factor, p.specific_yield, p.availability
+		FROM asset_monthly_performance p
+		JOIN assets a ON p.asset_id = a.id
+		JOIN locations l ON a.location_id = l.id
+		WHERE l.name = ? AND p.year = ? AND p.month = ?
+		ORDER BY a.kind = 'string', a.kind != 'site', p.performance_ratio
+	`, location, year, month)
+	if err != nil {
+		slog.Error("Error querying asset performance", "site", location, "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []structure.AssetPerformanceRow{}
+	for rows.Next() {
+		var row structure.AssetPerformanceRow
+		var parentID sql.NullInt64
+		var actual, expected, ratio, capacityFactor, yield, availability sql.NullFloat64
+		if err := rows.Scan(&row.AssetID, &parentID, &row.Kind, &row.Code, &row.Name, &row.Period,
+			&actual, &expected, &ratio, &capacityFactor, &yield, &availability); err != nil {
+			slog.Error("Error scanning asset performance", "err", err)
+			return nil, err
+		}
+		if parentID.Valid {
+			id := int(parentID.Int64)
+			row.ParentID = &id
+		}
+		row.Actual = nullFloat(actual)
+		row.Expected = nullFloat(expected)
+		row.PerformanceRatio = nullFloat(ratio)
+		row.CapacityFactor = nullFloat(capacityFactor)
+		row.SpecificYield = nullFloat(yield)
+		row.Availability = nullFloat(availability)
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+func scanAssetPerformance(row rowScanner) (structure.AssetPerformance, error) {
+	var p structure.AssetPerformance
+	var actual, expected, ratio, capacityFactor, yield, availability sql.NullFloat64
+	if err := row.Scan(&p.Period, &actual, &expected, &ratio, &capacityFactor, &yield, &availability); err != nil {
+		return p, err
+	}
+	p.Actual = nullFloat(actual)
+	p.Expected = nullFloat(expected)
+	p.PerformanceRatio = nullFloat(ratio)
+	p.CapacityFactor = nullFloat(capacityFactor)
+	p.SpecificYield = nullFloat(yield)
+	p.Availability = nullFloat(availability)
+	return p, nil
+}
+
+func nullString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+func (r assetRepo) List() ([]structure.Asset, error) {
+	rows, err := r.db.Query(`
+		SELECT ` + r.columns() + `
+		FROM assets a
+		JOIN locations l ON a.location_id = l.id
+		ORDER BY a.id
+	`)
+	if err != nil {
+		return nil, repository.Wrap("querying assets", err, nil)
+	}
+	defer rows.Close()
+
+	assets := []structure.Asset{}
+	for rows.Next() {
+		asset, err := scanAsset(rows)
+		if err != nil {
+			return nil, repository.Wrap("scanning asset", err, nil)
+		}
+		assets = append(assets, asset)
+	}
+	return assets, rows.Err()
+}
+
+func (r assetRepo) Generation(monthly bool) ([]structure.AssetGeneration, error) {
+	dialect := r.db.Dialect
+	query := `
+		SELECT ` + dialect.Day("a.date") + `, a.asset_id, a.actual_kwh, g.theoretical_kwh, a.producing_intervals, a.daylight_intervals
+		FROM asset_daily_generation a
+		JOIN assets s ON s.id = a.asset_id
+		LEFT JOIN daily_generation g ON g.location_id = s.location_id AND ` + dialect.Day("g.date") + ` = ` + dialect.Day("a.date")
+	if monthly {
+		inMonth := dialect.Month("d.date") + ` = ` + dialect.Period("a.year", "a.month")
+		query = `
+		SELECT ` + dialect.Period("a.year", "a.month") + `, a.asset_id, a.actual_kwh, g.theoretical_kwh,
+			(SELECT SUM(d.producing_intervals) FROM asset_daily_generation d
+				WHERE d.asset_id = a.asset_id AND ` + inMonth + `),
+			(SELECT SUM(d.daylight_intervals) FROM asset_daily_generation d
+				WHERE d.asset_id = a.asset_id AND ` + inMonth + `)
+		FROM asset_monthly_generation a
+		JOIN assets s ON s.id = a.asset_id
+		LEFT JOIN monthly_generation g ON g.location_id = s.location_id AND g.year = a.year AND g.month = a.month`
+	}
+	return r.generation(query)
+}
+
+func (r assetRepo) SiteGeneration(monthly bool) ([]structure.AssetGeneration, error) {
+	query := `
+		SELECT ` + r.db.Dialect.Day("g.date") + `, s.id, g.actual_kwh, g.theoretical_kwh, NULL, NULL
+		FROM daily_generation_checked g
+		JOIN assets s ON s.location_id = g.location_id AND s.kind = 'site'
+		WHERE g.actual_kwh IS NOT NULL`
+	if monthly {
+		query = `
+		SELECT ` + r.db.Dialect.Period("g.year", "g.month") + `, s.id, g.actual_kwh, g.theoretical_kwh, NULL, NULL
+		FROM monthly_generation_checked g
+		JOIN assets s ON s.location_id = g.location_id AND s.kind = 'site'
+		WHERE g.actual_kwh IS NOT NULL`
+	}
+	return r.generation(query)
+}
+
+func (r assetRepo) generation(query string) ([]structure.AssetGeneration, error) {
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, repository.Wrap("querying asset generation", err, nil)
+	}
+	defer rows.Close()
+
+	generation := []structure.AssetGeneration{}
+	for rows.Next() {
+		var g structure.AssetGeneration
+		var theoretical sql.NullFloat64
+		var producing, daylight sql.NullInt64
+		if err := rows.Scan(&g.Period, &g.AssetID, &g.Actual, &theoretical, &producing, &daylight); err != nil {
+			return nil, repository.Wrap("scanning asset generation", err, nil)
+		}
+		g.SiteTheoretical = nullFloat(theoretical)
+		g.Producing = nullInt(producing)
+		g.Daylight = nullInt(daylight)
+		generation = append(generation, g)
+	}
+	return generation, rows.Err()
+}
+
+func (r assetRepo) ReplacePerformance(daily, monthly []structure.AssetPerformanceRow) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return repository.Wrap("starting transaction", err, nil)
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"asset_daily_performance", "asset_monthly_performance"} {
+		if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+			return repository.Wrap("clearing "+table, err, nil)
+		}
+	}
+
+	dailyStmt, err := tx.Prepare(`
+		INSERT INTO asset_daily_performance (
+			date, asset_id, actual_kwh, expected_kwh,
+			performance_ratio, capacity_factor, specific_yield, availability
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return repository.Wrap("preparing asset daily performance", err, nil)
+	}
+	defer dailyStmt.Close()
+
+	for _, p := range daily {
+		if _, err := dailyStmt.Exec(p.Period, p.AssetID, p.Actual, p.Expected, p.PerformanceRatio,
+			p.CapacityFactor, p.SpecificYield, p.Availability); err != nil {
+			return repository.Wrap("inserting asset daily performance", err, nil)
+		}
+	}
+
+	monthlyStmt, err := tx.Prepare(`
+		INSERT INTO asset_monthly_performance (
+			year, month, asset_id, actual_kwh, expected_kwh,
+			performance_ratio, capacity_factor, specific_yield, availability
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return repository.Wrap("preparing asset monthly performance", err, nil)
+	}
+	defer monthlyStmt.Close()
+
+	for _, p := range monthly {
+		var year, month int
+		if _, err := fmt.Sscanf(p.Period, "%d-%d", &year, &month); err != nil {
+			return fmt.Errorf("invalid month %q for asset %d: %w", p.Period, p.AssetID, err)
+		}
+		if _, err := monthlyStmt.Exec(year, month, p.AssetID, p.Actual, p.Expected, p.PerformanceRatio,
+			p.CapacityFactor, p.SpecificYield, p.Availability); err != nil {
+			return repository.Wrap("inserting asset monthly performance", err, nil)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return repository.Wrap("committing asset performance", err, nil)
+	}
+	return nil
+}
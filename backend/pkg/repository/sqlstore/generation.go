@@ -0,0 +1,475 @@
+package sqlstore
+
+import (
+	"backend/pkg/db"
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+type generationRepo struct {
+	db *db.DB
+}
+
+func (r generationRepo) LastMonth(site string) (float64, error) {
+	var value sql.NullFloat64
+	err := r.db.QueryRow(`
+		SELECT actual_kwh
+		FROM monthly_generation mg
+		JOIN locations l ON mg.location_id = l.id
+		WHERE l.name = ?
+		ORDER BY year DESC, month DESC
+		LIMIT 1
+	`, site).Scan(&value)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, repository.Wrap("getting last monthly power generation", err, nil)
+	}
+	if !value.Valid {
+		return 0, noGeneration(r.db, site)
+	}
+	return value.Float64, nil
+}
+
+func (r generationRepo) LastYear(site string) (float64, error) {
+	var value sql.NullFloat64
+	err := r.db.QueryRow(`
+		WITH LastYear AS (
+			SELECT year
+			FROM monthly_generation mg
+			JOIN locations l ON mg.location_id = l.id
+			WHERE l.name = ?
+			ORDER BY year DESC
+			LIMIT 1
+		)
+		SELECT SUM(mg.actual_kwh)
+		FROM monthly_generation mg
+		WHERE mg.year = (SELECT year FROM LastYear)
+		AND mg.location_id = (SELECT id FROM locations WHERE name = ?)
+	`, site, site).Scan(&value)
+	if err != nil {
+		return 0, repository.Wrap("getting last yearly power generation", err, nil)
+	}
+	if !value.Valid {
+		return 0, noGeneration(r.db, site)
+	}
+	return value.Float64, nil
+}
+
+func (r generationRepo) Forecast(site string) ([]structure.ForecastResult, error) {
+	var query string
+	var args []interface{}
+	if site == "Total System" {
+		// Quantiles don't add up across sites, so the prediction and the band around it both come
+		// from the Total System model
+		query = `
+			SELECT
+				mg.year,
+				mg.month,
+				SUM(COALESCE(f.actual_kwh, mg.actual_kwh)) as actual_kwh,
+				MAX(t.predicted_kwh) as predicted_kwh,
+				MAX(fq.p10_kwh),
+				MAX(fq.p50_kwh),
+				MAX(fq.p90_kwh),
+				MAX(COALESCE(f.imputed, 0)) as imputed
+			FROM monthly_generation mg
+			JOIN locations l ON mg.location_id = l.id
+			LEFT JOIN monthly_generation_filled f
+				ON f.year = mg.year AND f.month = mg.month AND f.location_id = mg.location_id
+			LEFT JOIN monthly_generation t
+				ON t.year = mg.year AND t.month = mg.month
+				AND t.location_id = (SELECT id FROM locations WHERE name = 'Total System')
+			LEFT JOIN forecast_quantiles fq
+				ON fq.year = mg.year AND fq.month = mg.month
+				AND fq.location_id = (SELECT id FROM locations WHERE name = 'Total System')
+			WHERE l.name != 'Total System'
+			GROUP BY mg.year, mg.month
+			ORDER BY mg.year DESC, mg.month DESC
+		`
+	} else {
+		query = `
+			SELECT
+				mg.year,
+				mg.month,
+				COALESCE(f.actual_kwh, mg.actual_kwh),
+				mg.predicted_kwh,
+				fq.p10_kwh,
+				fq.p50_kwh,
+				fq.p90_kwh,
+				COALESCE(f.imputed, 0)
+			FROM monthly_generation mg
+			JOIN locations l ON mg.location_id = l.id
+			LEFT JOIN monthly_generation_filled f
+				ON f.year = mg.year AND f.month = mg.month AND f.location_id = mg.location_id
+			LEFT JOIN forecast_quantiles fq
+				ON fq.year = mg.year AND fq.month = mg.month AND fq.location_id = mg.location_id
+			WHERE l.name = ?
+			ORDER BY mg.year DESC, mg.month DESC
+		`
+		args = append(args, site)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, repository.Wrap("querying forecast data", err, nil)
+	}
+	defer rows.Close()
+
+	results := []structure.ForecastResult{}
+	for rows.Next() {
+		var result structure.ForecastResult
+		var actual, predicted, p10, p50, p90 sql.NullFloat64
+		if err := rows.Scan(&result.Year, &result.Month, &actual, &predicted, &p10, &p50, &p90, &result.Imputed); err != nil {
+			return nil, repository.Wrap("scanning forecast row", err, nil)
+		}
+		result.Actual = actual.Float64
+		result.Predicted = predicted.Float64
+
+		// Quantile bands only exist for months the model has forecast
+		if p10.Valid && p50.Valid && p90.Valid {
+			result.P10 = nullFloat(p10)
+			result.P50 = nullFloat(p50)
+			result.P90 = nullFloat(p90)
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, repository.Wrap("reading forecast data", err, nil)
+	}
+
+	if len(results) == 0 {
+		if err := noGeneration(r.db, site); !errors.Is(err, repository.ErrNotFound) {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+func (r generationRepo) Calibration(site string) (*structure.ForecastCalibration, error) {
+	var calibration structure.ForecastCalibration
+	err := r.db.QueryRow(`
+		SELECT
+			fc.nominal_coverage,
+			fc.interval_coverage,
+			fc.below_p10,
+			fc.below_p50,
+			fc.below_p90,
+			fc.sample_count
+		FROM forecast_calibration fc
+		JOIN locations l ON fc.location_id = l.id
+		WHERE l.name = ?
+	`, site).Scan(
+		&calibration.NominalCoverage,
+		&calibration.IntervalCoverage,
+		&calibration.BelowP10,
+		&calibration.BelowP50,
+		&calibration.BelowP90,
+		&calibration.SampleCount,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, repository.Wrap("getting forecast calibration", err, nil)
+	}
+	return &calibration, nil
+}
+
+func (r generationRepo) Totals() (map[string]float64, error) {
+	rows, err := r.db.Query(`
+		SELECT
+			l.name,
+			COALESCE(SUM(mg.actual_kwh), 0) as total_generation
+		FROM locations l
+		LEFT JOIN monthly_generation mg ON l.id = mg.location_id
+		WHERE l.name != 'Total System'
+		GROUP BY l.name
+	`)
+	if err != nil {
+		return nil, repository.Wrap("querying total power generation", err, nil)
+	}
+	defer rows.Close()
+
+	totals := map[string]float64{}
+	for rows.Next() {
+		var site string
+		var total float64
+		if err := rows.Scan(&site, &total); err != nil {
+			return nil, repository.Wrap("scanning total power generation row", err, nil)
+		}
+		totals[site] = total
+	}
+	if err := rows.Err(); err != nil {
+		return nil, repository.Wrap("reading total power generation", err, nil)
+	}
+	return totals, nil
+}
+
+func (r generationRepo) Daily(site, from, to string) ([]structure.DailyGeneration, error) {
+	dialect := r.db.Dialect
+	rows, err := r.db.Query(`
+		SELECT k.date, COALESCE(g.source, ''), COALESCE(f.actual_kwh, g.actual_kwh), g.theoretical_kwh, g.predicted_kwh,
+			p.performance_ratio, p.capacity_factor, p.output_per_pv, COALESCE(f.imputed, 0)
+		FROM (
+			SELECT `+dialect.Day("date")+` AS date, location_id FROM daily_generation
+			UNION
+			SELECT period, location_id FROM gaps WHERE dataset = 'daily_generation' AND value IS NOT NULL
+		) k
+		JOIN locations l ON k.location_id = l.id
+		LEFT JOIN daily_generation g ON `+dialect.Day("g.date")+` = k.date AND g.location_id = k.location_id
+		LEFT JOIN daily_generation_filled f ON `+dialect.Day("f.date")+` = k.date AND f.location_id = k.location_id
+		LEFT JOIN daily_performance p ON `+dialect.Day("p.date")+` = k.date AND p.location_id = k.location_id
+		WHERE l.name = ? AND k.date BETWEEN ? AND ?
+		ORDER BY k.date
+	`, site, from, to)
+	if err != nil {
+		return nil, repository.Wrap("querying daily generation", err, nil)
+	}
+	defer rows.Close()
+
+	days := []structure.DailyGeneration{}
+	for rows.Next() {
+		var day structure.DailyGeneration
+		var actual, theoretical, predicted, ratio, capacityFactor, outputPerPV sql.NullFloat64
+		if err := rows.Scan(&day.Date, &day.Source, &actual, &theoretical, &predicted,
+			&ratio, &capacityFactor, &outputPerPV, &day.Imputed); err != nil {
+			return nil, repository.Wrap("scanning daily generation", err, nil)
+		}
+		day.Actual = nullFloat(actual)
+		day.Theoretical = nullFloat(theoretical)
+		day.Predicted = nullFloat(predicted)
+		day.PerformanceRatio = nullFloat(ratio)
+		day.CapacityFactor = nullFloat(capacityFactor)
+		day.OutputPerPV = nullFloat(outputPerPV)
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+func (r generationRepo) Intervals(site, date string) ([]structure.IntervalGeneration, error) {
+	rows, err := r.db.Query(`
+		SELECT g.interval_start, g.interval_minutes, g.inverter, g.energy_kwh, g.source
+		FROM interval_generation g
+		JOIN locations l ON g.location_id = l.id
+		WHERE l.name = ? AND `+r.db.Dialect.Day("g.interval_start")+` = ?
+		ORDER BY g.interval_start, g.inverter
+	`, site, date)
+	if err != nil {
+		return nil, repository.Wrap("querying interval generation", err, nil)
+	}
+	defer rows.Close()
+
+	intervals := []structure.IntervalGeneration{}
+	for rows.Next() {
+		var interval structure.IntervalGeneration
+		var start time.Time
+		if err := rows.Scan(&start, &interval.Minutes, &interval.Inverter, &interval.Energy, &interval.Source); err != nil {
+			return nil, repository.Wrap("scanning interval generation", err, nil)
+		}
+		interval.Start = start.Format("2006-01-02 15:04:05")
+		intervals = append(intervals, interval)
+	}
+	return intervals, rows.Err()
+}
+
+func (r generationRepo) Latest() ([]structure.SiteFreshness, error) {
+	rows, err := r.db.Query(`
+		SELECT l.name, mg.year, mg.month
+		FROM locations l
+		LEFT JOIN monthly_generation mg ON mg.id = (
+			SELECT id FROM monthly_generation
+			WHERE location_id = l.id AND actual_kwh IS NOT NULL
+			ORDER BY year DESC, month DESC
+			LIMIT 1
+		)
+		ORDER BY l.id
+	`)
+	if err != nil {
+		return nil, repository.Wrap("querying latest generation", err, nil)
+	}
+	defer rows.Close()
+
+	sites := []structure.SiteFreshness{}
+	for rows.Next() {
+		var site structure.SiteFreshness
+		var year, month sql.NullInt64
+		if err := rows.Scan(&site.Site, &year, &month); err != nil {
+			return nil, repository.Wrap("scanning latest generation", err, nil)
+		}
+		if year.Valid {
+			site.LatestMonth = fmt.Sprintf("%04d-%02d", year.Int64, month.Int64)
+		}
+		sites = append(sites, site)
+	}
+	return sites, rows.Err()
+}
+
+func (r generationRepo) Actuals() ([]structure.Generation, error) {
+	return r.monthly(`
+		SELECT year, month, location_id, actual_kwh, COALESCE(theoretical_kwh, 0)
+		FROM monthly_generation
+		WHERE actual_kwh IS NOT NULL
+		ORDER BY year, month, location_id
+	`)
+}
+
+func (r generationRepo) Filled() ([]structure.Generation, error) {
+	return r.monthly(`
+		SELECT year, month, location_id, actual_kwh, theoretical_kwh
+		FROM monthly_generation_filled
+		WHERE actual_kwh IS NOT NULL AND theoretical_kwh IS NOT NULL
+		ORDER BY year, month, location_id
+	`)
+}
+
+func (r generationRepo) monthly(query string) ([]structure.Generation, error) {
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, repository.Wrap("querying monthly generation", err, nil)
+	}
+	defer rows.Close()
+
+	months := []structure.Generation{}
+	for rows.Next() {
+		var month structure.Generation
+		if err := rows.Scan(&month.Year, &month.Month, &month.LocationID, &month.ActualKWH, &month.TheoreticalKWH); err != nil {
+			return nil, repository.Wrap("scanning monthly generation", err, nil)
+		}
+		months = append(months, month)
+	}
+	return months, rows.Err()
+}
+
+func (r generationRepo) SetTheoretical(months []structure.Generation) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return repository.Wrap("starting transaction", err, nil)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO monthly_generation (year, month, location_id, theoretical_kwh)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(year, month, location_id)
+		DO UPDATE SET theoretical_kwh = excluded.theoretical_kwh
+	`)
+	if err != nil {
+		return repository.Wrap("preparing theoretical output", err, nil)
+	}
+	defer stmt.Close()
+
+	for _, month := range months {
+		if _, err := stmt.Exec(month.Year, month.Month, month.LocationID, month.TheoreticalKWH); err != nil {
+			return repository.Wrap(fmt.Sprintf("updating theoretical output for location %d", month.LocationID), err, nil)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return repository.Wrap("committing theoretical output", err, nil)
+	}
+	return nil
+}
+
+func (r generationRepo) FilledDays() ([]structure.SiteDay, error) {
+	rows, err := r.db.Query(`
+		SELECT date, location_id, actual_kwh, theoretical_kwh
+		FROM daily_generation_filled
+		WHERE actual_kwh IS NOT NULL AND theoretical_kwh IS NOT NULL
+		ORDER BY date, location_id
+	`)
+	if err != nil {
+		return nil, repository.Wrap("querying daily generation", err, nil)
+	}
+	defer rows.Close()
+
+	days := []structure.SiteDay{}
+	for rows.Next() {
+		var day structure.SiteDay
+		if err := rows.Scan(&day.Date, &day.LocationID, &day.Actual, &day.Theoretical); err != nil {
+			return nil, repository.Wrap("scanning daily generation", err, nil)
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+func (r generationRepo) ReplaceDailyTheoretical(days []structure.SiteDay) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return repository.Wrap("starting transaction", err, nil)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE daily_generation SET theoretical_kwh = NULL`); err != nil {
+		return repository.Wrap("clearing daily theoretical output", err, nil)
+	}
+	stmt, err := tx.Prepare(`
+		UPDATE daily_generation SET theoretical_kwh = ?
+		WHERE date = ? AND location_id = ?
+	`)
+	if err != nil {
+		return repository.Wrap("preparing daily theoretical output", err, nil)
+	}
+	defer stmt.Close()
+
+	for _, day := range days {
+		if _, err := stmt.Exec(day.Theoretical, day.Date, day.LocationID); err != nil {
+			return repository.Wrap(fmt.Sprintf("updating daily theoretical output for location %d", day.LocationID), err, nil)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return repository.Wrap("committing daily theoretical output", err, nil)
+	}
+	return nil
+}
+
+func (r generationRepo) Backtest() ([]structure.ForecastBacktest, error) {
+	rows, err := r.db.Query(`
+		SELECT mg.location_id, mg.actual_kwh, fq.p10_kwh, fq.p50_kwh, fq.p90_kwh
+		FROM monthly_generation mg
+		JOIN forecast_quantiles fq
+			ON fq.year = mg.year AND fq.month = mg.month AND fq.location_id = mg.location_id
+		WHERE mg.actual_kwh IS NOT NULL
+			AND fq.p10_kwh IS NOT NULL
+			AND fq.p50_kwh IS NOT NULL
+			AND fq.p90_kwh IS NOT NULL
+	`)
+	if err != nil {
+		return nil, repository.Wrap("querying forecast quantiles", err, nil)
+	}
+	defer rows.Close()
+
+	backtest := []structure.ForecastBacktest{}
+	for rows.Next() {
+		var month structure.ForecastBacktest
+		if err := rows.Scan(&month.LocationID, &month.Actual, &month.P10, &month.P50, &month.P90); err != nil {
+			return nil, repository.Wrap("scanning forecast quantiles", err, nil)
+		}
+		backtest = append(backtest, month)
+	}
+	return backtest, rows.Err()
+}
+
+func (r generationRepo) ReplaceCalibration(calibration map[int]structure.ForecastCalibration) error {
+	locations := make([]int, 0, len(calibration))
+	for locationID := range calibration {
+		locations = append(locations, locationID)
+	}
+	sort.Ints(locations)
+
+	rows := make([][]interface{}, 0, len(locations))
+	for _, locationID := range locations {
+		c := calibration[locationID]
+		rows = append(rows, []interface{}{locationID, c.NominalCoverage, c.IntervalCoverage,
+			c.BelowP10, c.BelowP50, c.BelowP90, c.SampleCount})
+	}
+	return replaceRows(r.db, "forecast_calibration", `
+		INSERT INTO forecast_calibration (
+			location_id, nominal_coverage, interval_coverage,
+			below_p10, below_p50, below_p90, sample_count
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, rows)
+}
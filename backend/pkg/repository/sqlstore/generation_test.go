@@ -0,0 +1,49 @@
+package sqlstore
+
+import "testing"
+
+func TestForecastBands(t *testing.T) {
+	database := openTestDatabase(t)
+	generation := generationRepo{database}
+	mustExec(t, database, `INSERT INTO monthly_generation (year, month, location_id, actual_kwh, predicted_kwh) VALUES
+		(2024, 1, 1, 105, 100), (2024, 1, 2, 190, 200), (2024, 1, 3, 55, 50), (2024, 1, 4, 350, 360),
+		(2025, 2, 1, NULL, 110), (2025, 2, 2, NULL, 210), (2025, 2, 3, NULL, 60), (2025, 2, 4, NULL, 370)`)
+	mustExec(t, database, `INSERT INTO forecast_quantiles (year, month, location_id, p10_kwh, p50_kwh, p90_kwh) VALUES
+		(2024, 1, 1, 90, 100, 120), (2024, 1, 4, 330, 360, 400), (2025, 2, 4, 320, 370, 410)`)
+
+	tests := []struct {
+		name      string
+		site      string
+		year      int
+		month     int
+		actual    float64
+		predicted float64
+		p50       float64
+	}{
+		{"site", "Awali", 2024, 1, 105, 100, 100},
+		{"total system", "Total System", 2024, 1, 350, 360, 360},
+		{"total system months ahead", "Total System", 2025, 2, 0, 370, 370},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := generation.Forecast(tt.site)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, result := range results {
+				if result.Year != tt.year || result.Month != tt.month {
+					continue
+				}
+				if result.Actual != tt.actual || result.Predicted != tt.predicted {
+					t.Errorf("Actual, Predicted = %v, %v, want %v, %v", result.Actual, result.Predicted, tt.actual, tt.predicted)
+				}
+				// The band is centered on the published prediction
+				if result.P50 == nil || *result.P50 != tt.p50 {
+					t.Errorf("P50 = %v, want %v", result.P50, tt.p50)
+				}
+				return
+			}
+			t.Errorf("no forecast for %d-%02d", tt.year, tt.month)
+		})
+	}
+}
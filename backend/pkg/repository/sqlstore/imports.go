@@ -0,0 +1,68 @@
+package sqlstore
+
+import (
+	"backend/pkg/db"
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+)
+
+type importRepo struct {
+	db *db.DB
+}
+
+func (r importRepo) List(status string, limit int) ([]structure.Import, error) {
+	rows, err := r.db.Query(`
+		SELECT id, status, diff_json
+		FROM imports
+		WHERE ? = '' OR status = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`, status, status, limit)
+	if err != nil {
+		slog.Error("Error querying imports", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	imports := []structure.Import{}
+	for rows.Next() {
+		item, err := scanImport(rows)
+		if err != nil {
+			slog.Error("Error scanning import", "err", err)
+			return nil, err
+		}
+		item.Changes, item.Rejections = nil, nil
+		imports = append(imports, item)
+	}
+	return imports, rows.Err()
+}
+
+func (r importRepo) Get(id int) (*structure.Import, error) {
+	item, err := scanImport(r.db.QueryRow(`SELECT id, status, diff_json FROM imports WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrImportNotFound
+	}
+	if err != nil {
+		slog.Error("Error querying import", "id", id, "err", err)
+		return nil, err
+	}
+	return &item, nil
+}
+
+func scanImport(row rowScanner) (structure.Import, error) {
+	var item structure.Import
+	var id int
+	var status, diff string
+	if err := row.Scan(&id, &status, &diff); err != nil {
+		return item, err
+	}
+	if err := json.Unmarshal([]byte(diff), &item); err != nil {
+		return item, err
+	}
+	// A discarded import keeps the diff it was previewed with
+	item.ID, item.Status = id, status
+	return item, nil
+}
@@ -0,0 +1,127 @@
+package sqlstore
+
+import (
+	"backend/pkg/db"
+	structure "backend/pkg/struct"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+)
+
+type jobRepo struct {
+	db *db.DB
+}
+
+func (r jobRepo) Runs(jobName string, limit int) ([]structure.JobRun, error) {
+	query := `
+		SELECT id, job_name, trigger, status, started_at, finished_at, error_message
+		FROM job_runs
+		WHERE ? = '' OR job_name = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`
+
+	rows, err := r.db.Query(query, jobName, jobName, limit)
+	if err != nil {
+		slog.Error("Error querying job runs", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []structure.JobRun{}
+	index := make(map[int64]int)
+	for rows.Next() {
+		var run structure.JobRun
+		var finishedAt, errorMessage sql.NullString
+		if err := rows.Scan(&run.ID, &run.JobName, &run.Trigger, &run.Status, &run.StartedAt, &finishedAt, &errorMessage); err != nil {
+			slog.Error("Error scanning job run", "err", err)
+			return nil, err
+		}
+		run.FinishedAt = finishedAt.String
+		run.Error = errorMessage.String
+		run.Steps = []structure.JobRunStep{}
+		index[run.ID] = len(runs)
+		runs = append(runs, run)
+	}
+	rows.Close()
+
+	if len(runs) == 0 {
+		return runs, nil
+	}
+
+	stepRows, err := r.db.Query(`
+		SELECT run_id, step_name, status, started_at, finished_at, error_message
+		FROM job_run_steps
+		WHERE run_id >= ?
+		ORDER BY id
+	`, runs[len(runs)-1].ID)
+	if err != nil {
+		slog.Error("Error querying job run steps", "err", err)
+		return nil, err
+	}
+	defer stepRows.Close()
+
+	for stepRows.Next() {
+		var runID int64
+		var step structure.JobRunStep
+		var finishedAt, errorMessage sql.NullString
+		if err := stepRows.Scan(&runID, &step.Name, &step.Status, &step.StartedAt, &finishedAt, &errorMessage); err != nil {
+			slog.Error("Error scanning job run step", "err", err)
+			return nil, err
+		}
+		i, ok := index[runID]
+		if !ok {
+			continue
+		}
+		step.FinishedAt = finishedAt.String
+		step.Error = errorMessage.String
+		runs[i].Steps = append(runs[i].Steps, step)
+	}
+
+	return runs, nil
+}
+
+func (r modelRepo) Runs(model string, limit int) ([]structure.ModelRun, error) {
+	rows, err := r.db.Query(`
+		SELECT id, model, status, started_at, finished_at, duration_ms, exit_code,
+			rows_written, metrics_json, error_message, stderr_tail
+		FROM model_runs
+		WHERE ? = '' OR model = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`, model, model, limit)
+	if err != nil {
+		slog.Error("Error querying model runs", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []structure.ModelRun{}
+	for rows.Next() {
+		var run structure.ModelRun
+		var finishedAt, metrics, errorMessage, stderrTail sql.NullString
+		var durationMs, exitCode, rowsWritten sql.NullInt64
+		if err := rows.Scan(&run.ID, &run.Model, &run.Status, &run.StartedAt, &finishedAt, &durationMs, &exitCode,
+			&rowsWritten, &metrics, &errorMessage, &stderrTail); err != nil {
+			slog.Error("Error scanning model run", "err", err)
+			return nil, err
+		}
+		run.FinishedAt = finishedAt.String
+		run.DurationMs = durationMs.Int64
+		run.RowsWritten = int(rowsWritten.Int64)
+		run.Error = errorMessage.String
+		run.StderrTail = stderrTail.String
+		if exitCode.Valid {
+			code := int(exitCode.Int64)
+			run.ExitCode = &code
+		}
+		if metrics.Valid && metrics.String != "" {
+			if err := json.Unmarshal([]byte(metrics.String), &run.Metrics); err != nil {
+				slog.Error("Error decoding metrics of model run", "run_id", run.ID, "err", err)
+			}
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
@@ -0,0 +1,147 @@
+package sqlstore
+
+import (
+	"backend/pkg/db"
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+	"database/sql"
+)
+
+type modelRepo struct {
+	db *db.DB
+}
+
+func (r modelRepo) FeatureImportance() ([]structure.FeatureImportance, error) {
+	rows, err := r.db.Query(`
+		SELECT
+			feature_name,
+			importance_value as feature_importance
+		FROM feature_importance
+		ORDER BY importance_value DESC
+	`)
+	if err != nil {
+		return nil, repository.Wrap("querying feature importance", err, nil)
+	}
+	defer rows.Close()
+
+	var features []structure.FeatureImportance
+	for rows.Next() {
+		var feature structure.FeatureImportance
+		if err := rows.Scan(&feature.FeatureName, &feature.ImportanceValue); err != nil {
+			return nil, repository.Wrap("scanning feature importance", err, nil)
+		}
+		features = append(features, feature)
+	}
+	return features, rows.Err()
+}
+
+func (r modelRepo) SiteFeatureImportance(site, model string, runID int64) (*structure.SiteFeatureImportance, error) {
+	rows, err := r.db.Query(`
+		SELECT f.model_run_id, f.created_at, f.feature_name, f.importance_value
+		FROM site_feature_importance f
+		JOIN locations l ON f.location_id = l.id
+		WHERE l.name = ? AND f.model = ?
+		AND f.model_run_id IS (
+			SELECT s.model_run_id FROM site_feature_importance s
+			WHERE s.location_id = l.id AND s.model = f.model AND (? = 0 OR s.model_run_id = ?)
+			ORDER BY s.id DESC
+			LIMIT 1
+		)
+		ORDER BY f.importance_value DESC
+	`, site, model, runID, runID)
+	if err != nil {
+		return nil, repository.Wrap("querying site feature importance", err, nil)
+	}
+	defer rows.Close()
+
+	var result *structure.SiteFeatureImportance
+	for rows.Next() {
+		var modelRunID sql.NullInt64
+		var createdAt string
+		var feature structure.FeatureImportance
+		if err := rows.Scan(&modelRunID, &createdAt, &feature.FeatureName, &feature.ImportanceValue); err != nil {
+			return nil, repository.Wrap("scanning site feature importance", err, nil)
+		}
+		if result == nil {
+			result = &structure.SiteFeatureImportance{Site: site, Model: model, CreatedAt: createdAt}
+			if modelRunID.Valid {
+				result.ModelRunID = &modelRunID.Int64
+			}
+		}
+		result.Features = append(result.Features, feature)
+	}
+	return result, rows.Err()
+}
+
+func (r modelRepo) Explanation(site string, year, month int) (*structure.ForecastExplanation, error) {
+	rows, err := r.db.Query(`
+		SELECT e.model_run_id, e.method, e.base_value_kwh, e.feature_name, e.feature_value, e.contribution_kwh,
+			m.actual_kwh, m.predicted_kwh
+		FROM forecast_explanations e
+		JOIN locations l ON e.location_id = l.id
+		LEFT JOIN monthly_generation m
+			ON m.year = e.year AND m.month = e.month AND m.location_id = e.location_id
+		WHERE l.name = ? AND e.year = ? AND e.month = ?
+		ORDER BY ABS(e.contribution_kwh) DESC
+	`, site, year, month)
+	if err != nil {
+		return nil, repository.Wrap("querying forecast explanation", err, nil)
+	}
+	defer rows.Close()
+
+	var explanation *structure.ForecastExplanation
+	for rows.Next() {
+		var modelRunID sql.NullInt64
+		var method string
+		var baseValue float64
+		var contribution structure.FeatureContribution
+		var featureValue, actual, predicted sql.NullFloat64
+		if err := rows.Scan(&modelRunID, &method, &baseValue, &contribution.FeatureName, &featureValue,
+			&contribution.Contribution, &actual, &predicted); err != nil {
+			return nil, repository.Wrap("scanning forecast explanation", err, nil)
+		}
+
+		if explanation == nil {
+			explanation = &structure.ForecastExplanation{
+				Site:      site,
+				Year:      year,
+				Month:     month,
+				Method:    method,
+				BaseValue: baseValue,
+			}
+			if modelRunID.Valid {
+				explanation.ModelRunID = &modelRunID.Int64
+			}
+			explanation.Actual = nullFloat(actual)
+			explanation.Predicted = nullFloat(predicted)
+		}
+		contribution.FeatureValue = nullFloat(featureValue)
+		explanation.Contributions = append(explanation.Contributions, contribution)
+	}
+	return explanation, rows.Err()
+}
+
+func (r modelRepo) Versions() ([]structure.ModelVersion, error) {
+	rows, err := r.db.Query(`
+		SELECT model, id, finished_at
+		FROM model_runs
+		WHERE id IN (SELECT MAX(id) FROM model_runs WHERE status = 'succeeded' GROUP BY model)
+		ORDER BY model
+	`)
+	if err != nil {
+		return nil, repository.Wrap("querying model versions", err, nil)
+	}
+	defer rows.Close()
+
+	versions := []structure.ModelVersion{}
+	for rows.Next() {
+		var version structure.ModelVersion
+		var finishedAt sql.NullString
+		if err := rows.Scan(&version.Model, &version.Version, &finishedAt); err != nil {
+			return nil, repository.Wrap("scanning model version", err, nil)
+		}
+		version.TrainedAt = finishedAt.String
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
@@ -0,0 +1,82 @@
+package sqlstore
+
+import "testing"
+
+func TestSiteFeatureImportance(t *testing.T) {
+	database := openTestDatabase(t)
+	models := modelRepo{database}
+	mustExec(t, database, `INSERT INTO site_feature_importance (model_run_id, model, location_id, feature_name, importance_value) VALUES
+		(1, 'weather_only', 1, 'irradiance', 0.3), (1, 'weather_only', 1, 'sunshine', 0.7),
+		(2, 'weather_only', 1, 'irradiance', 0.6), (2, 'weather_only', 1, 'sunshine', 0.4),
+		(3, 'random_forest', 1, 'irradiance', 0.9)`)
+
+	tests := []struct {
+		name     string
+		location string
+		model    string
+		runID    int64
+		want     int64
+		top      string
+	}{
+		{"latest run", "Awali", "weather_only", 0, 2, "irradiance"},
+		{"earlier run", "Awali", "weather_only", 1, 1, "sunshine"},
+		{"another model", "Awali", "random_forest", 0, 3, "irradiance"},
+		{"unknown run", "Awali", "weather_only", 9, 0, ""},
+		{"site without importance", "UOB", "weather_only", 0, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := models.SiteFeatureImportance(tt.location, tt.model, tt.runID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == 0 {
+				if got != nil {
+					t.Errorf("SiteFeatureImportance() = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil || got.ModelRunID == nil || *got.ModelRunID != tt.want {
+				t.Fatalf("SiteFeatureImportance() = %+v, want run %d", got, tt.want)
+			}
+			// Features are ordered by importance
+			if got.Features[0].FeatureName != tt.top {
+				t.Errorf("top feature = %s, want %s", got.Features[0].FeatureName, tt.top)
+			}
+		})
+	}
+}
+
+func TestExplanation(t *testing.T) {
+	database := openTestDatabase(t)
+	models := modelRepo{database}
+	mustExec(t, database, `INSERT INTO monthly_generation (year, month, location_id, actual_kwh, predicted_kwh) VALUES (2020, 6, 1, 120, 110)`)
+	mustExec(t, database, `INSERT INTO forecast_explanations
+		(model_run_id, year, month, location_id, feature_name, feature_value, contribution_kwh, base_value_kwh, method) VALUES
+		(4, 2020, 6, 1, 'irradiance', 610, 15, 100, 'tree_shap'),
+		(4, 2020, 6, 1, 'temperature', 35, -5, 100, 'tree_shap')`)
+
+	got, err := models.Explanation("Awali", 2020, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil {
+		t.Fatal("Explanation() = nil, want an explanation")
+	}
+	if got.Method != "tree_shap" || got.BaseValue != 100 || *got.Actual != 120 || *got.Predicted != 110 || *got.ModelRunID != 4 {
+		t.Errorf("Explanation() = %+v", got)
+	}
+
+	// Base value plus the contributions, largest first, adds up to the prediction
+	sum := got.BaseValue
+	for _, c := range got.Contributions {
+		sum += c.Contribution
+	}
+	if got.Contributions[0].FeatureName != "irradiance" || sum != *got.Predicted {
+		t.Errorf("contributions = %+v, adding up to %v", got.Contributions, sum)
+	}
+
+	if got, err := models.Explanation("Awali", 2020, 7); err != nil || got != nil {
+		t.Errorf("Explanation() for a month without a forecast = %+v, %v, want nil", got, err)
+	}
+}
@@ -0,0 +1,121 @@
+package sqlstore
+
+import (
+	"backend/pkg/db"
+	structure "backend/pkg/struct"
+	"database/sql"
+	"log/slog"
+	"math"
+	"sort"
+)
+
+type outlookRepo struct {
+	db *db.DB
+}
+
+func (r outlookRepo) Issues(limit int) ([]structure.OutlookIssue, error) {
+	rows, err := r.db.Query(`
+		SELECT source, issued_at, COUNT(DISTINCT member), `+r.db.Dialect.Day("MIN(date)")+`, `+r.db.Dialect.Day("MAX(date)")+`
+		FROM weather_outlook
+		GROUP BY source, issued_at
+		ORDER BY issued_at DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		slog.Error("Error querying weather outlooks", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	issues := []structure.OutlookIssue{}
+	for rows.Next() {
+		var issue structure.OutlookIssue
+		if err := rows.Scan(&issue.Source, &issue.IssuedAt, &issue.Members, &issue.FirstDate, &issue.LastDate); err != nil {
+			slog.Error("Error scanning weather outlook", "err", err)
+			return nil, err
+		}
+		issues = append(issues, issue)
+	}
+
+	return issues, rows.Err()
+}
+
+func (r outlookRepo) Site(location string) (*structure.SiteOutlook, error) {
+	rows, err := r.db.Query(`
+		SELECT f.model_run_id, f.source, f.issued_at, f.year, f.month, f.days_covered, f.predicted_kwh
+		FROM forecast_outlook f
+		JOIN locations l ON f.location_id = l.id
+		WHERE l.name = ?
+		AND f.issued_at = (
+			SELECT MAX(issued_at) FROM forecast_outlook WHERE location_id = l.id
+		)
+		ORDER BY f.year, f.month, f.member
+	`, location)
+	if err != nil {
+		slog.Error("Error querying outlook forecast", "site", location, "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var outlook *structure.SiteOutlook
+	var current *structure.OutlookMonth
+	for rows.Next() {
+		var modelRunID sql.NullInt64
+		var source, issuedAt string
+		var year, month, daysCovered int
+		var predicted float64
+		if err := rows.Scan(&modelRunID, &source, &issuedAt, &year, &month, &daysCovered, &predicted); err != nil {
+			slog.Error("Error scanning outlook forecast", "err", err)
+			return nil, err
+		}
+
+		if outlook == nil {
+			outlook = &structure.SiteOutlook{Site: location, Source: source, IssuedAt: issuedAt}
+			if modelRunID.Valid {
+				outlook.ModelRunID = &modelRunID.Int64
+			}
+		}
+		if current == nil || current.Year != year || current.Month != month {
+			outlook.Months = append(outlook.Months, structure.OutlookMonth{Year: year, Month: month, DaysCovered: daysCovered})
+			current = &outlook.Months[len(outlook.Months)-1]
+		}
+		current.Members = append(current.Members, predicted)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if outlook != nil {
+		for i := range outlook.Months {
+			summariseMembers(&outlook.Months[i])
+		}
+	}
+	return outlook, nil
+}
+
+// summariseMembers fills the mean and the P10/P50/P90 of the member forecasts
+func summariseMembers(month *structure.OutlookMonth) {
+	sorted := append([]float64(nil), month.Members...)
+	sort.Float64s(sorted)
+
+	sum := 0.0
+	for _, value := range sorted {
+		sum += value
+	}
+	month.Mean = math.Round(sum/float64(len(sorted))*100) / 100
+	month.P10 = percentile(sorted, 0.1)
+	month.P50 = percentile(sorted, 0.5)
+	month.P90 = percentile(sorted, 0.9)
+}
+
+// percentile interpolates linearly between the closest ranks of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	value := sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+	return math.Round(value*100) / 100
+}
@@ -0,0 +1,29 @@
+package sqlstore
+
+import (
+	structure "backend/pkg/struct"
+	"testing"
+)
+
+func TestSummariseMembers(t *testing.T) {
+	tests := []struct {
+		name                string
+		members             []float64
+		mean, p10, p50, p90 float64
+	}{
+		{"single member", []float64{120}, 120, 120, 120, 120},
+		{"two members", []float64{200, 100}, 150, 110, 150, 190},
+		{"eleven members", []float64{10, 0, 9, 1, 8, 2, 7, 3, 6, 4, 5}, 5, 1, 5, 9},
+		{"interpolated", []float64{1, 2, 3, 4}, 2.5, 1.3, 2.5, 3.7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			month := structure.OutlookMonth{Members: tt.members}
+			summariseMembers(&month)
+			if month.Mean != tt.mean || month.P10 != tt.p10 || month.P50 != tt.p50 || month.P90 != tt.p90 {
+				t.Errorf("summariseMembers() = mean %v, P10 %v, P50 %v, P90 %v, want %v, %v, %v, %v",
+					month.Mean, month.P10, month.P50, month.P90, tt.mean, tt.p10, tt.p50, tt.p90)
+			}
+		})
+	}
+}
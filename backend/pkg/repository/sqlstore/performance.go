@@ -0,0 +1,256 @@
+package sqlstore
+
+import (
+	"backend/pkg/db"
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+)
+
+const (
+	monthlyGenerationQuery = `
+		SELECT
+			mg.year,
+			mg.month,
+			mg.location_id,
+			l.name as location_name,
+			COALESCE(f.actual_kwh, mg.actual_kwh),
+			mg.theoretical_kwh,
+			COALESCE(f.imputed, 0)
+		FROM monthly_generation mg
+		JOIN locations l ON mg.location_id = l.id
+		LEFT JOIN monthly_generation_filled f
+			ON f.year = mg.year AND f.month = mg.month AND f.location_id = mg.location_id
+		ORDER BY mg.year, mg.month, mg.location_id
+	`
+
+	monthlyPerformanceQuery = `
+		SELECT
+			mp.year,
+			mp.month,
+			mp.location_id,
+			l.name as location_name,
+			mp.performance_ratio,
+			mp.capacity_factor,
+			mp.output_per_pv,
+			COALESCE(f.imputed, 0)
+		FROM monthly_performance mp
+		JOIN locations l ON mp.location_id = l.id
+		LEFT JOIN monthly_generation_filled f
+			ON f.year = mp.year AND f.month = mp.month AND f.location_id = mp.location_id
+		ORDER BY mp.year, mp.month, mp.location_id
+	`
+
+	yearlyPerformanceQuery = `
+		SELECT
+			yp.year,
+			yp.location_id,
+			l.name as location_name,
+			yp.performance_ratio,
+			yp.capacity_factor,
+			yp.output_per_pv,
+			(SELECT COALESCE(SUM(f.imputed), 0) FROM monthly_generation_filled f
+			 WHERE f.year = yp.year AND f.location_id = yp.location_id) AS imputed_months
+		FROM yearly_performance yp
+		JOIN locations l ON yp.location_id = l.id
+		ORDER BY yp.year, yp.location_id
+	`
+
+	overallPerformanceQuery = `
+		SELECT
+			op.start_year,
+			op.end_year,
+			op.location_id,
+			l.name as location_name,
+			op.performance_ratio,
+			op.capacity_factor,
+			op.output_per_pv,
+			(SELECT COALESCE(SUM(f.imputed), 0) FROM monthly_generation_filled f
+			 WHERE f.year BETWEEN op.start_year AND op.end_year AND f.location_id = op.location_id) AS imputed_months
+		FROM overall_performance op
+		JOIN locations l ON op.location_id = l.id
+		ORDER BY op.location_id
+	`
+)
+
+type performanceRepo struct {
+	db *db.DB
+}
+
+func (r performanceRepo) MonthlyGeneration() ([]structure.Generation, error) {
+	rows, err := r.db.Query(monthlyGenerationQuery)
+	if err != nil {
+		return nil, repository.Wrap("querying monthly generation", err, nil)
+	}
+	defer rows.Close()
+
+	var generation []structure.Generation
+	for rows.Next() {
+		var gen structure.Generation
+		err := rows.Scan(
+			&gen.Year, &gen.Month, &gen.LocationID, &gen.LocationName,
+			&gen.ActualKWH, &gen.TheoreticalKWH, &gen.Imputed,
+		)
+		if err != nil {
+			return nil, repository.Wrap("scanning monthly generation", err, nil)
+		}
+		generation = append(generation, gen)
+	}
+	return generation, rows.Err()
+}
+
+func (r performanceRepo) Monthly() ([]structure.Performance, error) {
+	rows, err := r.db.Query(monthlyPerformanceQuery)
+	if err != nil {
+		return nil, repository.Wrap("querying monthly performance", err, nil)
+	}
+	defer rows.Close()
+
+	var performance []structure.Performance
+	for rows.Next() {
+		var perf structure.Performance
+		err := rows.Scan(
+			&perf.Year, &perf.Month, &perf.LocationID, &perf.LocationName,
+			&perf.PerformanceRatio, &perf.CapacityFactor, &perf.OutputPerPV, &perf.Imputed,
+		)
+		if err != nil {
+			return nil, repository.Wrap("scanning monthly performance", err, nil)
+		}
+		performance = append(performance, perf)
+	}
+	return performance, rows.Err()
+}
+
+func (r performanceRepo) Yearly() ([]structure.Performance, error) {
+	rows, err := r.db.Query(yearlyPerformanceQuery)
+	if err != nil {
+		return nil, repository.Wrap("querying yearly performance", err, nil)
+	}
+	defer rows.Close()
+
+	var performance []structure.Performance
+	for rows.Next() {
+		var perf structure.Performance
+		err := rows.Scan(
+			&perf.Year, &perf.LocationID, &perf.LocationName,
+			&perf.PerformanceRatio, &perf.CapacityFactor, &perf.OutputPerPV, &perf.ImputedMonths,
+		)
+		if err != nil {
+			return nil, repository.Wrap("scanning yearly performance", err, nil)
+		}
+		performance = append(performance, perf)
+	}
+	return performance, rows.Err()
+}
+
+func (r performanceRepo) Overall() ([]structure.Performance, error) {
+	rows, err := r.db.Query(overallPerformanceQuery)
+	if err != nil {
+		return nil, repository.Wrap("querying overall performance", err, nil)
+	}
+	defer rows.Close()
+
+	var performance []structure.Performance
+	for rows.Next() {
+		var perf structure.Performance
+		err := rows.Scan(
+			&perf.StartYear, &perf.EndYear, &perf.LocationID, &perf.LocationName,
+			&perf.PerformanceRatio, &perf.CapacityFactor, &perf.OutputPerPV, &perf.ImputedMonths,
+		)
+		if err != nil {
+			return nil, repository.Wrap("scanning overall performance", err, nil)
+		}
+		performance = append(performance, perf)
+	}
+	return performance, rows.Err()
+}
+
+func (r performanceRepo) Latest() ([]structure.LatestPerformance, error) {
+	rows, err := r.db.Query(`
+		SELECT
+			l.name,
+			mp.performance_ratio,
+			mp.capacity_factor,
+			COALESCE(f.actual_kwh, mg.actual_kwh, 0)
+		FROM monthly_performance mp
+		JOIN locations l ON mp.location_id = l.id
+		LEFT JOIN monthly_generation mg
+			ON mg.year = mp.year AND mg.month = mp.month AND mg.location_id = mp.location_id
+		LEFT JOIN monthly_generation_filled f
+			ON f.year = mp.year AND f.month = mp.month AND f.location_id = mp.location_id
+		WHERE mp.year * 12 + mp.month = (
+			SELECT MAX(year * 12 + month) FROM monthly_performance WHERE location_id = mp.location_id
+		)
+		ORDER BY mp.location_id
+	`)
+	if err != nil {
+		return nil, repository.Wrap("querying latest performance", err, nil)
+	}
+	defer rows.Close()
+
+	sites := []structure.LatestPerformance{}
+	for rows.Next() {
+		var site structure.LatestPerformance
+		if err := rows.Scan(&site.Site, &site.PerformanceRatio, &site.CapacityFactor, &site.Generation); err != nil {
+			return nil, repository.Wrap("scanning latest performance", err, nil)
+		}
+		sites = append(sites, site)
+	}
+	return sites, rows.Err()
+}
+
+func (r performanceRepo) ReplaceMonthly(performance []structure.Performance) error {
+	rows := make([][]interface{}, len(performance))
+	for i, p := range performance {
+		rows[i] = []interface{}{p.Year, p.Month, p.LocationID, p.PerformanceRatio, p.CapacityFactor, p.OutputPerPV}
+	}
+	return replaceRows(r.db, "monthly_performance", `
+		INSERT INTO monthly_performance (
+			year, month, location_id,
+			performance_ratio, capacity_factor, output_per_pv
+		)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, rows)
+}
+
+func (r performanceRepo) ReplaceYearly(performance []structure.Performance) error {
+	rows := make([][]interface{}, len(performance))
+	for i, p := range performance {
+		rows[i] = []interface{}{p.Year, p.LocationID, p.PerformanceRatio, p.CapacityFactor, p.OutputPerPV}
+	}
+	return replaceRows(r.db, "yearly_performance", `
+		INSERT INTO yearly_performance (
+			year, location_id,
+			performance_ratio, capacity_factor, output_per_pv
+		)
+		VALUES (?, ?, ?, ?, ?)
+	`, rows)
+}
+
+func (r performanceRepo) ReplaceOverall(performance []structure.Performance) error {
+	rows := make([][]interface{}, len(performance))
+	for i, p := range performance {
+		rows[i] = []interface{}{p.LocationID, p.StartYear, p.EndYear, p.PerformanceRatio, p.CapacityFactor, p.OutputPerPV}
+	}
+	return replaceRows(r.db, "overall_performance", `
+		INSERT INTO overall_performance (
+			location_id,
+			start_year, end_year,
+			performance_ratio, capacity_factor, output_per_pv
+		)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, rows)
+}
+
+func (r performanceRepo) ReplaceDaily(days []structure.SiteDay) error {
+	rows := make([][]interface{}, len(days))
+	for i, d := range days {
+		rows[i] = []interface{}{d.Date, d.LocationID, d.PerformanceRatio, d.CapacityFactor, d.OutputPerPV}
+	}
+	return replaceRows(r.db, "daily_performance", `
+		INSERT INTO daily_performance (
+			date, location_id,
+			performance_ratio, capacity_factor, output_per_pv
+		)
+		VALUES (?, ?, ?, ?, ?)
+	`, rows)
+}
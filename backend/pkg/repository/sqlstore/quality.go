@@ -0,0 +1,134 @@
+package sqlstore
+
+import (
+	"backend/pkg/db"
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+	"database/sql"
+	"log/slog"
+)
+
+type qualityRepo struct {
+	db *db.DB
+}
+
+const quarantineColumns = `
+	q.id, q.dataset, COALESCE(l.name, ''), q.period, q.rule, q.reason, q.value,
+	q.status, q.detected_at, q.reviewed_at, COALESCE(q.review_note, '')`
+
+func scanQuarantineEntry(row rowScanner) (structure.QuarantineEntry, error) {
+	var entry structure.QuarantineEntry
+	var value sql.NullFloat64
+	var reviewedAt sql.NullTime
+	err := row.Scan(&entry.ID, &entry.Dataset, &entry.Site, &entry.Period, &entry.Rule, &entry.Reason, &value,
+		&entry.Status, &entry.DetectedAt, &reviewedAt, &entry.ReviewNote)
+	if err != nil {
+		return entry, err
+	}
+	entry.Value = nullFloat(value)
+	if reviewedAt.Valid {
+		entry.ReviewedAt = &reviewedAt.Time
+	}
+	return entry, nil
+}
+
+func (r qualityRepo) Quarantine(dataset, status, site string, limit int) ([]structure.QuarantineEntry, error) {
+	rows, err := r.db.Query(`
+		SELECT `+quarantineColumns+`
+		FROM quarantine q
+		LEFT JOIN locations l ON l.id = q.location_id
+		WHERE (? = '' OR q.dataset = ?)
+			AND (? = '' OR q.status = ?)
+			AND (? = '' OR l.name = ?)
+		ORDER BY q.detected_at DESC, q.period DESC, q.id DESC
+		LIMIT ?
+	`, dataset, dataset, status, status, site, site, limit)
+	if err != nil {
+		slog.Error("Error querying quarantine", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []structure.QuarantineEntry{}
+	for rows.Next() {
+		entry, err := scanQuarantineEntry(rows)
+		if err != nil {
+			slog.Error("Error scanning quarantine entry", "err", err)
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (r qualityRepo) Entry(id int) (*structure.QuarantineEntry, error) {
+	entry, err := scanQuarantineEntry(r.db.QueryRow(`
+		SELECT `+quarantineColumns+`
+		FROM quarantine q
+		LEFT JOIN locations l ON l.id = q.location_id
+		WHERE q.id = ?
+	`, id))
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrQuarantineNotFound
+	}
+	if err != nil {
+		slog.Error("Error querying quarantine entry", "id", id, "err", err)
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (r qualityRepo) Gaps(dataset, site string, imputed *bool, limit int) ([]structure.DataGap, error) {
+	rows, err := r.db.Query(`
+		SELECT g.dataset, COALESCE(l.name, ''), g.period, g.column_name, g.reason, COALESCE(g.method, ''), g.value
+		FROM gaps g
+		LEFT JOIN locations l ON l.id = g.location_id
+		WHERE (? = '' OR g.dataset = ?)
+			AND (? = '' OR l.name = ?)
+			AND (? IS NULL OR (g.value IS NOT NULL) = ?)
+		ORDER BY g.dataset, g.period, g.location_id, g.column_name
+		LIMIT ?
+	`, dataset, dataset, site, site, imputed, imputed, limit)
+	if err != nil {
+		slog.Error("Error querying gaps", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	gaps := []structure.DataGap{}
+	for rows.Next() {
+		var gap structure.DataGap
+		var value sql.NullFloat64
+		if err := rows.Scan(&gap.Dataset, &gap.Site, &gap.Period, &gap.Column, &gap.Reason, &gap.Method, &value); err != nil {
+			slog.Error("Error scanning gap", "err", err)
+			return nil, err
+		}
+		gap.Value = nullFloat(value)
+		gaps = append(gaps, gap)
+	}
+	return gaps, rows.Err()
+}
+
+func (r qualityRepo) Open() (map[string]int, error) {
+	rows, err := r.db.Query(`
+		SELECT dataset, COUNT(*)
+		FROM quarantine
+		WHERE status = 'quarantined'
+		GROUP BY dataset
+	`)
+	if err != nil {
+		return nil, repository.Wrap("counting open quarantine", err, nil)
+	}
+	defer rows.Close()
+
+	open := map[string]int{}
+	for rows.Next() {
+		var dataset string
+		var count int
+		if err := rows.Scan(&dataset, &count); err != nil {
+			return nil, repository.Wrap("scanning open quarantine", err, nil)
+		}
+		open[dataset] = count
+	}
+	return open, rows.Err()
+}
@@ -0,0 +1,141 @@
+package sqlstore
+
+import (
+	"backend/pkg/audit"
+	"backend/pkg/db"
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+type revisionRepo struct {
+	db *db.DB
+}
+
+type emissionFactorRepo struct {
+	db *db.DB
+}
+
+func (r revisionRepo) List(table, key string, limit int) ([]structure.Revision, error) {
+	rows, err := r.db.Query(`
+		SELECT id, table_name, record_key, column_name, old_value, new_value, changed_by, COALESCE(reason, ''), changed_at
+		FROM revisions
+		WHERE (? = '' OR table_name = ?) AND (? = '' OR record_key = ?)
+		ORDER BY id DESC
+		LIMIT ?
+	`, table, table, key, key, limit)
+	if err != nil {
+		slog.Error("Error querying revisions", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []structure.Revision{}
+	for rows.Next() {
+		var revision structure.Revision
+		var oldValue, newValue sql.NullFloat64
+		if err := rows.Scan(&revision.ID, &revision.Table, &revision.Key, &revision.Column, &oldValue, &newValue,
+			&revision.ChangedBy, &revision.Reason, &revision.ChangedAt); err != nil {
+			slog.Error("Error scanning revision", "err", err)
+			return nil, err
+		}
+		revision.OldValue = nullFloat(oldValue)
+		revision.NewValue = nullFloat(newValue)
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+func (r revisionRepo) AsOf(table, key string, at time.Time) ([]structure.RevisionValue, error) {
+	rows, err := r.db.Query(`
+		SELECT r.table_name, r.record_key, r.column_name, r.new_value, r.id, r.changed_at
+		FROM revisions r
+		JOIN (
+			SELECT MAX(id) AS id
+			FROM revisions
+			WHERE (? = '' OR table_name = ?) AND (? = '' OR record_key = ?) AND changed_at <= ?
+			GROUP BY table_name, record_key, column_name
+		) latest ON latest.id = r.id
+		WHERE r.new_value IS NOT NULL
+		ORDER BY r.table_name, r.record_key, r.column_name
+	`, table, table, key, key, at.UTC())
+	if err != nil {
+		slog.Error("Error querying values as of", "at", at, "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []structure.RevisionValue{}
+	for rows.Next() {
+		var value structure.RevisionValue
+		if err := rows.Scan(&value.Table, &value.Key, &value.Column, &value.Value, &value.RevisionID, &value.ChangedAt); err != nil {
+			slog.Error("Error scanning revision value", "err", err)
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+func (r emissionFactorRepo) List() ([]structure.EmissionFactor, error) {
+	rows, err := r.db.Query(`
+		SELECT name, value, unit, COALESCE(description, ''), updated_at FROM emission_factors ORDER BY name
+	`)
+	if err != nil {
+		slog.Error("Error querying emission factors", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	factors := []structure.EmissionFactor{}
+	for rows.Next() {
+		var factor structure.EmissionFactor
+		if err := rows.Scan(&factor.Name, &factor.Value, &factor.Unit, &factor.Description, &factor.UpdatedAt); err != nil {
+			slog.Error("Error scanning emission factor", "err", err)
+			return nil, err
+		}
+		factors = append(factors, factor)
+	}
+	return factors, rows.Err()
+}
+
+func (r emissionFactorRepo) Get(name string) (*structure.EmissionFactor, error) {
+	var factor structure.EmissionFactor
+	err := r.db.QueryRow(`
+		SELECT name, value, unit, COALESCE(description, ''), updated_at FROM emission_factors WHERE name = ?
+	`, name).Scan(&factor.Name, &factor.Value, &factor.Unit, &factor.Description, &factor.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrEmissionFactorNotFound
+	}
+	if err != nil {
+		slog.Error("Error querying emission factor", "name", name, "err", err)
+		return nil, err
+	}
+	return &factor, nil
+}
+
+func (r emissionFactorRepo) Update(name string, value float64, change audit.Change) (*structure.EmissionFactor, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE emission_factors SET value = ?, updated_at = ? WHERE name = ?`, value, time.Now().UTC(), name)
+	if err != nil {
+		return nil, fmt.Errorf("error updating emission factor %s: %v", name, err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, repository.ErrEmissionFactorNotFound
+	}
+	if _, err := audit.Record(tx, r.db.Dialect, change); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing emission factor %s: %v", name, err)
+	}
+	return r.Get(name)
+}
@@ -0,0 +1,55 @@
+package sqlstore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRevisionAsOf(t *testing.T) {
+	database := openTestDatabase(t)
+	revisions := revisionRepo{database}
+	mustExec(t, database, `INSERT INTO revisions (table_name, record_key, column_name, old_value, new_value, changed_by, changed_at) VALUES
+		('monthly_generation', 'Awali/2020-01', 'actual_kwh', NULL, 100, 'system', '2021-01-01 00:00:00'),
+		('monthly_generation', 'Awali/2020-01', 'actual_kwh', 100, 110, 'tester', '2021-06-01 00:00:00'),
+		('monthly_generation', 'UOB/2020-01', 'actual_kwh', NULL, 50, 'system', '2021-01-01 00:00:00'),
+		('monthly_generation', 'UOB/2020-01', 'actual_kwh', 50, NULL, 'tester', '2021-06-01 00:00:00')`)
+
+	date := func(s string) time.Time {
+		at, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return at
+	}
+	tests := []struct {
+		name string
+		key  string
+		at   time.Time
+		want map[string]float64
+	}{
+		{"before the history", "", date("2020-12-31"), map[string]float64{}},
+		{"first revisions", "", date("2021-03-01"), map[string]float64{"Awali/2020-01": 100, "UOB/2020-01": 50}},
+		{"changed and removed", "", date("2021-07-01"), map[string]float64{"Awali/2020-01": 110}},
+		{"one record", "UOB/2020-01", date("2021-03-01"), map[string]float64{"UOB/2020-01": 50}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := revisions.AsOf("monthly_generation", tt.key, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]float64)
+			for _, v := range values {
+				got[v.Key] = v.Value
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("AsOf() = %v, want %v", got, tt.want)
+			}
+			for key, value := range tt.want {
+				if got[key] != value {
+					t.Errorf("AsOf()[%s] = %v, want %v", key, got[key], value)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package sqlstore
+
+import (
+	"backend/pkg/db"
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+type scenarioRepo struct {
+	db *db.DB
+}
+
+func (r scenarioRepo) List() ([]structure.SavedScenario, error) {
+	query := `
+		SELECT id, COALESCE(name, ''), created_at
+		FROM scenarios
+		ORDER BY created_at DESC, id DESC
+	`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		slog.Error("Error querying saved scenarios", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	scenarios := []structure.SavedScenario{}
+	for rows.Next() {
+		var scenario structure.SavedScenario
+		if err := rows.Scan(&scenario.ID, &scenario.Name, &scenario.CreatedAt); err != nil {
+			slog.Error("Error scanning saved scenario", "err", err)
+			return nil, err
+		}
+		scenarios = append(scenarios, scenario)
+	}
+
+	return scenarios, nil
+}
+
+func (r scenarioRepo) Save(req structure.ScenarioRequest, response structure.ScenarioResponse) (int64, error) {
+	requestJSON, err := json.Marshal(req)
+	if err != nil {
+		return 0, fmt.Errorf("error encoding scenario request: %v", err)
+	}
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return 0, fmt.Errorf("error encoding scenario result: %v", err)
+	}
+
+	var id int64
+	err = r.db.QueryRow(`
+		INSERT INTO scenarios (name, request_json, result_json)
+		VALUES (?, ?, ?)
+		RETURNING id
+	`, req.Name, string(requestJSON), string(responseJSON)).Scan(&id)
+	if err != nil {
+		return 0, repository.Wrap("saving scenario", err, nil)
+	}
+	return id, nil
+}
@@ -0,0 +1,43 @@
+package sqlstore
+
+import (
+	"backend/pkg/db"
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+)
+
+type siteRepo struct {
+	db *db.DB
+}
+
+func (r siteRepo) Resolve(site string) (string, error) {
+	var name string
+	err := r.db.QueryRow(`SELECT name FROM locations WHERE LOWER(name) = ?`, repository.SiteKey(site)).Scan(&name)
+	if err != nil {
+		return "", repository.Wrap("resolving site", err, repository.ErrInvalidSite)
+	}
+	return name, nil
+}
+
+func (r siteRepo) List() ([]structure.Location, error) {
+	rows, err := r.db.Query(`
+		SELECT id, name, installed_capacity_kw, number_of_panels
+		FROM locations
+		WHERE name IN ('Awali', 'Refinery', 'UOB', 'Total System')
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, repository.Wrap("querying location data", err, nil)
+	}
+	defer rows.Close()
+
+	var locations []structure.Location
+	for rows.Next() {
+		var location structure.Location
+		if err := rows.Scan(&location.ID, &location.Name, &location.InstalledCapacity, &location.NumberOfPanels); err != nil {
+			return nil, repository.Wrap("scanning location data", err, nil)
+		}
+		locations = append(locations, location)
+	}
+	return locations, rows.Err()
+}
@@ -0,0 +1,33 @@
+package sqlstore
+
+import (
+	"backend/pkg/repository"
+	"errors"
+	"testing"
+)
+
+func TestSiteResolve(t *testing.T) {
+	sites := siteRepo{openTestDatabase(t)}
+
+	tests := []struct {
+		site string
+		want string
+		err  error
+	}{
+		{"awali", "Awali", nil},
+		{"Refinery", "Refinery", nil},
+		{"uob", "UOB", nil},
+		{"total-system", "Total System", nil},
+		{"total", "Total System", nil},
+		{"sitra", "", repository.ErrInvalidSite},
+		{"", "", repository.ErrInvalidSite},
+	}
+	for _, tt := range tests {
+		t.Run(tt.site, func(t *testing.T) {
+			got, err := sites.Resolve(tt.site)
+			if got != tt.want || !errors.Is(err, tt.err) {
+				t.Errorf("Resolve(%q) = %q, %v, want %q, %v", tt.site, got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// Package sqlstore implements the repositories on the database the server keeps its data in,
+// SQLite or PostgreSQL. The statements are shared; db.Dialect supplies what differs.
+package sqlstore
+
+import (
+	"backend/pkg/db"
+	"backend/pkg/repository"
+	"database/sql"
+	"fmt"
+)
+
+// New returns repositories that query database
+func New(database *db.DB) repository.Repos {
+	return repository.Repos{
+		Sites:       siteRepo{database},
+		Generation:  generationRepo{database},
+		Weather:     weatherRepo{database},
+		Performance: performanceRepo{database},
+		Models:      modelRepo{database},
+
+		Assets:          assetRepo{database},
+		Imports:         importRepo{database},
+		Quality:         qualityRepo{database},
+		Revisions:       revisionRepo{database},
+		EmissionFactors: emissionFactorRepo{database},
+		Scenarios:       scenarioRepo{database},
+		Outlook:         outlookRepo{database},
+		Jobs:            jobRepo{database},
+		Telemetry:       telemetryRepo{database},
+		Status:          statusRepo{database},
+	}
+}
+
+// noGeneration explains why a site has no generation: ErrInvalidSite when there is no such
+// site, ErrNotFound when there is
+func noGeneration(database *db.DB, site string) error {
+	var id int
+	err := database.QueryRow(`SELECT id FROM locations WHERE name = ?`, site).Scan(&id)
+	if err != nil {
+		return repository.Wrap("checking location", err, repository.ErrInvalidSite)
+	}
+	return fmt.Errorf("no generation for %s: %w", site, repository.ErrNotFound)
+}
+
+func nullFloat(value sql.NullFloat64) *float64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Float64
+}
+
+// replaceRows deletes every row of table and inserts rows with insert in one transaction, so a
+// failure leaves the previous rows in place
+func replaceRows(database *db.DB, table, insert string, rows [][]interface{}) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return repository.Wrap("starting transaction", err, nil)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+		return repository.Wrap("clearing "+table, err, nil)
+	}
+	stmt, err := tx.Prepare(insert)
+	if err != nil {
+		return repository.Wrap("preparing insert into "+table, err, nil)
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		if _, err := stmt.Exec(row...); err != nil {
+			return repository.Wrap("inserting into "+table, err, nil)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return repository.Wrap("committing "+table, err, nil)
+	}
+	return nil
+}
+
+func nullInt(value sql.NullInt64) *int {
+	if !value.Valid {
+		return nil
+	}
+	i := int(value.Int64)
+	return &i
+}
@@ -0,0 +1,150 @@
+package sqlstore
+
+import (
+	"backend/pkg/db"
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testDatabases opens the databases the repositories are tested on: a new SQLite file, and the
+// PostgreSQL database named by SOLAR_TEST_POSTGRES_URL when it is set
+func testDatabases() map[db.Dialect]func(t *testing.T) *db.DB {
+	return map[db.Dialect]func(t *testing.T) *db.DB{
+		db.SQLite: func(t *testing.T) *db.DB {
+			database, err := db.Open(filepath.Join(t.TempDir(), "app.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { database.Close() })
+			return database
+		},
+		db.Postgres: func(t *testing.T) *db.DB {
+			url := os.Getenv("SOLAR_TEST_POSTGRES_URL")
+			if url == "" {
+				t.Skip("SOLAR_TEST_POSTGRES_URL is not set")
+			}
+			database, err := db.OpenPostgres(url, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { database.Close() })
+			return database
+		},
+	}
+}
+
+// openTestDatabase opens a new SQLite file with the four locations
+func openTestDatabase(t *testing.T) *db.DB {
+	t.Helper()
+	database := testDatabases()[db.SQLite](t)
+	mustExec(t, database, `INSERT INTO locations (id, name, installed_capacity_kw, number_of_panels) VALUES
+		(1, 'Awali', 1000, 2000), (2, 'Refinery', 2000, 4000), (3, 'UOB', 500, 1000), (4, 'Total System', 3500, 7000)`)
+	return database
+}
+
+func mustExec(t *testing.T, database *db.DB, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := database.Exec(query, args...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// addSite makes sure Awali and its site asset exist
+func addSite(t *testing.T, database *db.DB) {
+	if _, err := database.Exec(`
+		INSERT INTO locations (name, installed_capacity_kw, number_of_panels) VALUES ('Awali', 1000, 4000)
+		ON CONFLICT (name) DO NOTHING
+	`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Exec(`
+		INSERT INTO assets (location_id, kind, code, name)
+		SELECT id, 'site', name, name FROM locations WHERE name = 'Awali'
+		ON CONFLICT (location_id, code) DO NOTHING
+	`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAssetCreate(t *testing.T) {
+	for dialect, open := range testDatabases() {
+		t.Run(string(dialect), func(t *testing.T) {
+			database := open(t)
+			addSite(t, database)
+			repos := New(database)
+
+			// Codes are new on every run, so a PostgreSQL database can be reused
+			code := fmt.Sprintf("INV-%d", time.Now().UnixNano())
+			capacity := 250.0
+			id, err := repos.Assets.Create(structure.AssetInput{
+				Site: "awali", Kind: "inverter", Code: code, CapacityKW: &capacity, CommissionedOn: "2020-01-15",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			asset, err := repos.Assets.Get(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if asset.Site != "Awali" || asset.Code != code || asset.CommissionedOn != "2020-01-15" {
+				t.Errorf("got %s %s commissioned %q, want Awali %s commissioned 2020-01-15", asset.Site, asset.Code, asset.CommissionedOn, code)
+			}
+
+			tests := []struct {
+				name  string
+				input structure.AssetInput
+			}{
+				{"duplicate code", structure.AssetInput{Site: "Awali", Kind: "inverter", Code: code}},
+				{"unknown site", structure.AssetInput{Site: "Nowhere", Kind: "inverter", Code: code + "-x"}},
+				{"string without parent", structure.AssetInput{Site: "Awali", Kind: "string", Code: code + "-s"}},
+				{"inverter under inverter", structure.AssetInput{Site: "Awali", Parent: code, Kind: "inverter", Code: code + "-i"}},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					if _, err := repos.Assets.Create(tt.input); !errors.Is(err, repository.ErrInvalidAsset) {
+						t.Errorf("got %v, want ErrInvalidAsset", err)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestScenarioSave(t *testing.T) {
+	for dialect, open := range testDatabases() {
+		t.Run(string(dialect), func(t *testing.T) {
+			repos := New(open(t))
+
+			first, err := repos.Scenarios.Save(structure.ScenarioRequest{Name: "first"}, structure.ScenarioResponse{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, err := repos.Scenarios.Save(structure.ScenarioRequest{Name: "second"}, structure.ScenarioResponse{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first <= 0 || second <= first {
+				t.Errorf("got ids %d and %d, want increasing ids", first, second)
+			}
+
+			saved, err := repos.Scenarios.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := map[int64]string{}
+			for _, scenario := range saved {
+				names[scenario.ID] = scenario.Name
+			}
+			if names[first] != "first" || names[second] != "second" {
+				t.Errorf("got %v, want %d first and %d second", names, first, second)
+			}
+		})
+	}
+}
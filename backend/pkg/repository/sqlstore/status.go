@@ -0,0 +1,47 @@
+package sqlstore
+
+import (
+	"backend/pkg/db"
+	structure "backend/pkg/struct"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type statusRepo struct {
+	db *db.DB
+}
+
+func (r statusRepo) Populated(table string) (bool, error) {
+	var exists bool
+	if err := r.db.QueryRow(fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s)", table)).Scan(&exists); err != nil {
+		return false, fmt.Errorf("error checking %s: %v", table, err)
+	}
+	return exists, nil
+}
+
+func (r jobRepo) Last(status string) (*structure.JobRun, error) {
+	var run structure.JobRun
+	var finishedAt, errorMessage sql.NullString
+	err := r.db.QueryRow(`
+		SELECT id, job_name, trigger, status, started_at, finished_at, error_message
+		FROM job_runs
+		WHERE ? = '' OR status = ?
+		ORDER BY id DESC
+		LIMIT 1
+	`, status, status).Scan(&run.ID, &run.JobName, &run.Trigger, &run.Status, &run.StartedAt, &finishedAt, &errorMessage)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying last job run: %v", err)
+	}
+	run.FinishedAt = finishedAt.String
+	run.Error = errorMessage.String
+	run.Steps = []structure.JobRunStep{}
+	return &run, nil
+}
+
+func (r statusRepo) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
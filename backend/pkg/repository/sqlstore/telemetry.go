@@ -0,0 +1,52 @@
+package sqlstore
+
+import (
+	"backend/pkg/db"
+	structure "backend/pkg/struct"
+	"database/sql"
+	"log/slog"
+	"time"
+)
+
+type telemetryRepo struct {
+	db *db.DB
+}
+
+func (r telemetryRepo) Readings(location, inverter string, limit int) ([]structure.TelemetryReading, error) {
+	rows, err := r.db.Query(`
+		SELECT l.name, t.inverter, t.read_at, t.power_w, t.dc_power_w, t.lifetime_energy_wh, t.operating_state, t.source
+		FROM telemetry_readings t
+		JOIN locations l ON t.location_id = l.id
+		WHERE l.name = ? AND (? = '' OR t.inverter = ?)
+		ORDER BY t.read_at DESC, t.inverter
+		LIMIT ?
+	`, location, inverter, inverter, limit)
+	if err != nil {
+		slog.Error("Error querying telemetry readings", "site", location, "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	readings := []structure.TelemetryReading{}
+	for rows.Next() {
+		var reading structure.TelemetryReading
+		var readAt time.Time
+		var power, dcPower, energy sql.NullFloat64
+		var state sql.NullInt64
+		if err := rows.Scan(&reading.Site, &reading.Inverter, &readAt, &power, &dcPower, &energy, &state, &reading.Source); err != nil {
+			slog.Error("Error scanning telemetry reading", "err", err)
+			return nil, err
+		}
+		reading.ReadAt = readAt.Format("2006-01-02 15:04:05")
+		reading.PowerW = nullFloat(power)
+		reading.DCPowerW = nullFloat(dcPower)
+		reading.LifetimeEnergyWh = nullFloat(energy)
+		if state.Valid {
+			value := int(state.Int64)
+			reading.OperatingState = &value
+		}
+		readings = append(readings, reading)
+	}
+
+	return readings, rows.Err()
+}
@@ -0,0 +1,150 @@
+package sqlstore
+
+import (
+	"backend/pkg/db"
+	"backend/pkg/repository"
+	structure "backend/pkg/struct"
+	"database/sql"
+	"fmt"
+)
+
+type weatherRepo struct {
+	db *db.DB
+}
+
+func (r weatherRepo) Impact() ([]structure.WeatherImpactData, error) {
+	rows, err := r.db.Query(`
+		SELECT
+			w.year,
+			w.month,
+			w.avg_sunshine_duration_seconds,
+			w.avg_daylight_duration_seconds,
+			w.min_temperature_C,
+			w.avg_temperature_C,
+			w.max_temperature_C,
+			w.avg_solar_irradiance_wm2,
+			w.avg_relative_humidity_percent,
+			w.avg_cloud_cover_percent,
+			w.avg_wind_speed_kmh,
+			w.total_rainfall_mm,
+			SUM(m.actual_kwh) as total_kwh
+		FROM weather_monthly w
+		LEFT JOIN monthly_generation m
+		ON w.year = m.year AND w.month = m.month
+		GROUP BY w.id, w.year, w.month
+		ORDER BY w.year, w.month
+	`)
+	if err != nil {
+		return nil, repository.Wrap("querying weather impact data", err, nil)
+	}
+	defer rows.Close()
+
+	var months []structure.WeatherImpactData
+	for rows.Next() {
+		var data structure.WeatherImpactData
+		err := rows.Scan(
+			&data.Year,
+			&data.Month,
+			&data.AvgSunshineDuration,
+			&data.AvgDaylightDuration,
+			&data.MinTemperature,
+			&data.AvgTemperature,
+			&data.MaxTemperature,
+			&data.AvgSolarIrradiance,
+			&data.AvgRelativeHumidity,
+			&data.AvgCloudCover,
+			&data.AvgWindSpeed,
+			&data.CumulativeRainfall,
+			&data.TotalPowerGeneration,
+		)
+		if err != nil {
+			return nil, repository.Wrap("scanning weather impact data", err, nil)
+		}
+		months = append(months, data)
+	}
+	return months, rows.Err()
+}
+
+func (r weatherRepo) Latest() (string, string, error) {
+	var day sql.NullString
+	var year, month sql.NullInt64
+	err := r.db.QueryRow(`
+		SELECT
+			(SELECT MAX(date) FROM weather_daily),
+			(SELECT MAX(year * 12 + month - 1) / 12 FROM weather_monthly),
+			(SELECT MAX(year * 12 + month - 1) % 12 + 1 FROM weather_monthly)
+	`).Scan(&day, &year, &month)
+	if err != nil {
+		return "", "", repository.Wrap("querying latest weather", err, nil)
+	}
+
+	latestDay, latestMonth := day.String, ""
+	if len(latestDay) > 10 {
+		latestDay = latestDay[:10]
+	}
+	if year.Valid {
+		latestMonth = fmt.Sprintf("%04d-%02d", year.Int64, month.Int64)
+	}
+	return latestDay, latestMonth, nil
+}
+
+func (r weatherRepo) Months() ([]structure.WeatherMonth, error) {
+	return r.months("weather_daily")
+}
+
+func (r weatherRepo) FilledMonths() ([]structure.WeatherMonth, error) {
+	// Missing and quarantined days count towards the month whether or not they were imputed
+	return r.months("weather_daily_filled")
+}
+
+func (r weatherRepo) months(table string) ([]structure.WeatherMonth, error) {
+	rows, err := r.db.Query(`
+		SELECT
+			` + r.db.Dialect.YearOf("date") + ` AS year,
+			` + r.db.Dialect.MonthOf("date") + ` AS month,
+			AVG(sunshine_duration_seconds),
+			AVG(avg_solar_irradiance_wm2),
+			COUNT(*)
+		FROM ` + table + `
+		GROUP BY year, month
+		HAVING AVG(sunshine_duration_seconds) IS NOT NULL AND AVG(avg_solar_irradiance_wm2) IS NOT NULL
+		ORDER BY year, month
+	`)
+	if err != nil {
+		return nil, repository.Wrap("querying monthly weather", err, nil)
+	}
+	defer rows.Close()
+
+	months := []structure.WeatherMonth{}
+	for rows.Next() {
+		var month structure.WeatherMonth
+		if err := rows.Scan(&month.Year, &month.Month, &month.Sunshine, &month.Irradiance, &month.Days); err != nil {
+			return nil, repository.Wrap("scanning monthly weather", err, nil)
+		}
+		months = append(months, month)
+	}
+	return months, rows.Err()
+}
+
+func (r weatherRepo) FilledDays() ([]structure.WeatherDay, error) {
+	rows, err := r.db.Query(`
+		SELECT ` + r.db.Dialect.Day("date") + `, sunshine_duration_seconds, avg_solar_irradiance_wm2
+		FROM weather_daily_filled
+		WHERE sunshine_duration_seconds IS NOT NULL AND avg_solar_irradiance_wm2 IS NOT NULL
+		ORDER BY date
+	`)
+	if err != nil {
+		return nil, repository.Wrap("querying daily weather", err, nil)
+	}
+	defer rows.Close()
+
+	days := []structure.WeatherDay{}
+	for rows.Next() {
+		var day structure.WeatherDay
+		if err := rows.Scan(&day.Date, &day.Sunshine, &day.Irradiance); err != nil {
+			return nil, repository.Wrap("scanning daily weather", err, nil)
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
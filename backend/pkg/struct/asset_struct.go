@@ -0,0 +1,71 @@
+package structure
+
+import "encoding/json"
+
+// Asset is a node of the site → inverter → string hierarchy
+type Asset struct {
+	ID             int             `json:"id"`
+	Site           string          `json:"site"`
+	ParentID       *int            `json:"parentId,omitempty"`
+	Kind           string          `json:"kind"`
+	Code           string          `json:"code"`
+	Name           string          `json:"name"`
+	CapacityKW     *float64        `json:"capacityKw,omitempty"`
+	NumberOfPanels *int            `json:"numberOfPanels,omitempty"`
+	Manufacturer   string          `json:"manufacturer,omitempty"`
+	Model          string          `json:"model,omitempty"`
+	SerialNumber   string          `json:"serialNumber,omitempty"`
+	CommissionedOn string          `json:"commissionedOn,omitempty"`
+	Metadata       json.RawMessage `json:"metadata,omitempty"`
+	Children       []Asset         `json:"children,omitempty"`
+}
+
+// AssetInput creates or updates an asset. Parent is the code of the parent asset at the same
+// site; inverters default to the site asset. Site, kind, code and parent cannot be changed on update.
+type AssetInput struct {
+	Site           string          `json:"site"`
+	Parent         string          `json:"parent"`
+	Kind           string          `json:"kind"`
+	Code           string          `json:"code"`
+	Name           string          `json:"name"`
+	CapacityKW     *float64        `json:"capacityKw"`
+	NumberOfPanels *int            `json:"numberOfPanels"`
+	Manufacturer   string          `json:"manufacturer"`
+	Model          string          `json:"model"`
+	SerialNumber   string          `json:"serialNumber"`
+	CommissionedOn string          `json:"commissionedOn"`
+	Metadata       json.RawMessage `json:"metadata"`
+}
+
+// AssetPerformance is one asset's performance over a day (YYYY-MM-DD) or month (YYYY-MM)
+type AssetPerformance struct {
+	Period           string   `json:"period"`
+	Actual           *float64 `json:"actual,omitempty"`
+	Expected         *float64 `json:"expected,omitempty"`
+	PerformanceRatio *float64 `json:"performanceRatio,omitempty"`
+	CapacityFactor   *float64 `json:"capacityFactor,omitempty"`
+	SpecificYield    *float64 `json:"specificYield,omitempty"`
+	Availability     *float64 `json:"availability,omitempty"`
+}
+
+// AssetPerformanceRow is one asset's performance in a site-wide comparison
+type AssetPerformanceRow struct {
+	AssetID  int    `json:"assetId"`
+	ParentID *int   `json:"parentId,omitempty"`
+	Kind     string `json:"kind"`
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	AssetPerformance
+}
+
+// AssetGeneration is an asset's generation over a day (YYYY-MM-DD) or month (YYYY-MM) with its
+// site's theoretical generation. Producing and Daylight count the asset's intervals, and are nil
+// when there are none.
+type AssetGeneration struct {
+	AssetID         int
+	Period          string
+	Actual          float64
+	SiteTheoretical *float64
+	Producing       *int
+	Daylight        *int
+}
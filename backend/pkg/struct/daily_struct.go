@@ -0,0 +1,36 @@
+package structure
+
+// DailyGeneration is one site-day of generation with its performance metrics
+type DailyGeneration struct {
+	Date             string   `json:"date"`
+	Source           string   `json:"source"`
+	Actual           *float64 `json:"actual,omitempty"`
+	Theoretical      *float64 `json:"theoretical,omitempty"`
+	Predicted        *float64 `json:"predicted,omitempty"`
+	PerformanceRatio *float64 `json:"performanceRatio,omitempty"`
+	CapacityFactor   *float64 `json:"capacityFactor,omitempty"`
+	OutputPerPV      *float64 `json:"outputPerPV,omitempty"`
+	// Imputed means Actual is an estimate filling a missing or quarantined day
+	Imputed bool `json:"imputed,omitempty"`
+}
+
+// IntervalGeneration is one meter or inverter interval. Inverter is empty for site meter readings.
+type IntervalGeneration struct {
+	Start    string  `json:"start"`
+	Minutes  int     `json:"minutes"`
+	Inverter string  `json:"inverter,omitempty"`
+	Energy   float64 `json:"energy"`
+	Source   string  `json:"source"`
+}
+
+// SiteDay is one site-day of generation and its performance metrics, as the daily calculations
+// read and write them
+type SiteDay struct {
+	Date             string
+	LocationID       int
+	Actual           float64
+	Theoretical      float64
+	PerformanceRatio float64
+	CapacityFactor   float64
+	OutputPerPV      float64
+}
@@ -0,0 +1,15 @@
+package structure
+
+// ErrorResponse is the body of every API error
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail describes what went wrong. Code is stable for clients to branch on; Message is for
+// people. RequestID matches the X-Request-ID header and the server's log lines.
+type ErrorDetail struct {
+	Status    int    `json:"status"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"requestId,omitempty"`
+}
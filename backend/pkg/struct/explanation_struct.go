@@ -0,0 +1,37 @@
+package structure
+
+// FeatureImportance is one feature's share of a model's splits
+type FeatureImportance struct {
+	FeatureName     string  `json:"featureName"`
+	ImportanceValue float64 `json:"importanceValue"`
+}
+
+// SiteFeatureImportance is the feature importance of one model run for one site
+type SiteFeatureImportance struct {
+	Site       string              `json:"site"`
+	Model      string              `json:"model"`
+	ModelRunID *int64              `json:"modelRunId,omitempty"`
+	CreatedAt  string              `json:"createdAt"`
+	Features   []FeatureImportance `json:"features"`
+}
+
+// FeatureContribution is how much one feature moved a prediction away from the base value
+type FeatureContribution struct {
+	FeatureName  string   `json:"featureName"`
+	FeatureValue *float64 `json:"featureValue,omitempty"`
+	Contribution float64  `json:"contribution"`
+}
+
+// ForecastExplanation breaks a monthly forecast down into per-feature contributions.
+// BaseValue plus the sum of the contributions equals Predicted, up to rounding.
+type ForecastExplanation struct {
+	Site          string                `json:"site"`
+	Year          int                   `json:"year"`
+	Month         int                   `json:"month"`
+	Method        string                `json:"method"`
+	ModelRunID    *int64                `json:"modelRunId,omitempty"`
+	Actual        *float64              `json:"actual,omitempty"`
+	Predicted     *float64              `json:"predicted,omitempty"`
+	BaseValue     float64               `json:"baseValue"`
+	Contributions []FeatureContribution `json:"contributions"`
+}
@@ -0,0 +1,39 @@
+package structure
+
+import "time"
+
+// ImportRejection is an uploaded row that failed validation. Row is the CSV line or the sheet row.
+type ImportRejection struct {
+	Sheet   string `json:"sheet,omitempty"`
+	Row     int    `json:"row,omitempty"`
+	Cell    string `json:"cell,omitempty"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportChange is one row an import inserts or updates. Old is empty for inserts.
+type ImportChange struct {
+	Action string                 `json:"action"`
+	Key    string                 `json:"key"`
+	Row    int                    `json:"row,omitempty"`
+	Old    map[string]interface{} `json:"old,omitempty"`
+	New    map[string]interface{} `json:"new"`
+}
+
+// Import is an uploaded file and its diff against the stored data. Pending imports are a dry run
+// until they are applied.
+type Import struct {
+	ID             int               `json:"id"`
+	Dataset        string            `json:"dataset"`
+	Filename       string            `json:"filename,omitempty"`
+	Status         string            `json:"status"`
+	Inserted       int               `json:"inserted"`
+	Updated        int               `json:"updated"`
+	Unchanged      int               `json:"unchanged"`
+	Rejected       int               `json:"rejected"`
+	AffectedMonths []string          `json:"affectedMonths"`
+	Changes        []ImportChange    `json:"changes,omitempty"`
+	Rejections     []ImportRejection `json:"rejections,omitempty"`
+	CreatedAt      time.Time         `json:"createdAt"`
+	AppliedAt      *time.Time        `json:"appliedAt,omitempty"`
+}
@@ -0,0 +1,46 @@
+package structure
+
+// JobInfo describes a registered pipeline job and when it will next run
+type JobInfo struct {
+	Name    string   `json:"name"`
+	Spec    string   `json:"spec"`
+	NextRun string   `json:"nextRun,omitempty"`
+	Running bool     `json:"running"`
+	Steps   []string `json:"steps"`
+}
+
+// JobRun is one execution of a pipeline job
+type JobRun struct {
+	ID         int64        `json:"id"`
+	JobName    string       `json:"jobName"`
+	Trigger    string       `json:"trigger"`
+	Status     string       `json:"status"`
+	StartedAt  string       `json:"startedAt"`
+	FinishedAt string       `json:"finishedAt,omitempty"`
+	Error      string       `json:"error,omitempty"`
+	Steps      []JobRunStep `json:"steps"`
+}
+
+// JobRunStep is one step within a job run
+type JobRunStep struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	StartedAt  string `json:"startedAt"`
+	FinishedAt string `json:"finishedAt,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ModelRun is one execution of a Python model script
+type ModelRun struct {
+	ID          int64                         `json:"id"`
+	Model       string                        `json:"model"`
+	Status      string                        `json:"status"`
+	StartedAt   string                        `json:"startedAt"`
+	FinishedAt  string                        `json:"finishedAt,omitempty"`
+	DurationMs  int64                         `json:"durationMs"`
+	ExitCode    *int                          `json:"exitCode,omitempty"`
+	RowsWritten int                           `json:"rowsWritten"`
+	Metrics     map[string]map[string]float64 `json:"metrics,omitempty"`
+	Error       string                        `json:"error,omitempty"`
+	StderrTail  string                        `json:"stderrTail,omitempty"`
+}
@@ -0,0 +1,31 @@
+package structure
+
+// OutlookIssue summarises one stored weather outlook
+type OutlookIssue struct {
+	Source    string `json:"source"`
+	IssuedAt  string `json:"issuedAt"`
+	Members   int    `json:"members"`
+	FirstDate string `json:"firstDate"`
+	LastDate  string `json:"lastDate"`
+}
+
+// OutlookMonth is the ensemble forecast for one month, with the spread across members
+type OutlookMonth struct {
+	Year        int       `json:"year"`
+	Month       int       `json:"month"`
+	DaysCovered int       `json:"daysCovered"`
+	Mean        float64   `json:"mean"`
+	P10         float64   `json:"p10"`
+	P50         float64   `json:"p50"`
+	P90         float64   `json:"p90"`
+	Members     []float64 `json:"members"`
+}
+
+// SiteOutlook is the latest outlook-driven forecast for a site
+type SiteOutlook struct {
+	Site       string         `json:"site"`
+	Source     string         `json:"source"`
+	IssuedAt   string         `json:"issuedAt"`
+	ModelRunID *int64         `json:"modelRunId,omitempty"`
+	Months     []OutlookMonth `json:"months"`
+}
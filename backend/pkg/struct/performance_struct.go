@@ -7,6 +7,8 @@ type Generation struct {
     LocationName string `json:"location_name"`
     ActualKWH   float64 `json:"actual_kwh"`
     TheoreticalKWH float64 `json:"theoretical_kwh"`
+    // Imputed means ActualKWH is an estimate filling a missing or quarantined month
+    Imputed     bool    `json:"imputed,omitempty"`
 }
 
 type Performance struct {
@@ -19,6 +21,10 @@ type Performance struct {
     PerformanceRatio float64 `json:"performance_ratio"`
     CapacityFactor   float64 `json:"capacity_factor"`
     OutputPerPV      float64 `json:"output_per_pv"`
+    // Imputed flags a monthly figure calculated from an estimate; ImputedMonths counts the
+    // estimated months behind a yearly or overall figure
+    Imputed          bool    `json:"imputed,omitempty"`
+    ImputedMonths    int     `json:"imputed_months,omitempty"`
 }
 
 type PerformanceResponse struct {
@@ -27,3 +33,12 @@ type PerformanceResponse struct {
     YearlyPerformance  []Performance `json:"yearly_performance"`
     OverallPerformance []Performance `json:"overall_performance"`
 }
+
+// LatestPerformance is a site's latest month of performance with its generation in kWh; the
+// ratios are nil when the month had no theoretical output to compare against
+type LatestPerformance struct {
+    Site             string
+    PerformanceRatio *float64
+    CapacityFactor   *float64
+    Generation       float64
+}
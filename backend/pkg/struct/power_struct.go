@@ -6,10 +6,34 @@ type ForecastResult struct {
     Month     int
     Actual    float64
     Predicted float64
+    // P10, P50 and P90 are the forecast bands, left out for months the model has not forecast
+    P10       *float64 `json:",omitempty"`
+    P50       *float64 `json:",omitempty"`
+    P90       *float64 `json:",omitempty"`
+    // Imputed means Actual includes an estimate for a missing or quarantined month
+    Imputed   bool
+}
+
+// ForecastCalibration reports how often actuals fell inside the forecast bands during the backtest
+type ForecastCalibration struct {
+	NominalCoverage  float64 `json:"nominalCoverage"`
+	IntervalCoverage float64 `json:"intervalCoverage"`
+	BelowP10         float64 `json:"belowP10"`
+	BelowP50         float64 `json:"belowP50"`
+	BelowP90         float64 `json:"belowP90"`
+	SampleCount      int     `json:"sampleCount"`
 }
 
 type PowerGenerationResponse struct {
-	LastMonth string                 `json:"lastMonth"`
-	LastYear  string                 `json:"lastYear"`
-	Forecast  []ForecastResult       `json:"forecast"`
-}
\ No newline at end of file
+	LastMonth   string                 `json:"lastMonth"`
+	LastYear    string                 `json:"lastYear"`
+	Forecast    []ForecastResult       `json:"forecast"`
+	Calibration *ForecastCalibration   `json:"calibration,omitempty"`
+}
+
+// ForecastBacktest is one location's actual generation in a month the forecast quantiles cover
+type ForecastBacktest struct {
+	LocationID    int
+	Actual        float64
+	P10, P50, P90 float64
+}
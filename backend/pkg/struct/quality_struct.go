@@ -0,0 +1,45 @@
+package structure
+
+import "time"
+
+// QualityRule is a data quality check run over one dataset
+type QualityRule struct {
+	Name        string `json:"name"`
+	Dataset     string `json:"dataset"`
+	Description string `json:"description"`
+}
+
+// QuarantineEntry is a row that failed a quality rule. Period is YYYY-MM for monthly generation and
+// YYYY-MM-DD otherwise; Site is empty for weather. Quarantined and rejected rows are left out of the
+// metrics, accepted rows are used as they are.
+type QuarantineEntry struct {
+	ID         int        `json:"id"`
+	Dataset    string     `json:"dataset"`
+	Site       string     `json:"site,omitempty"`
+	Period     string     `json:"period"`
+	Rule       string     `json:"rule"`
+	Reason     string     `json:"reason"`
+	Value      *float64   `json:"value,omitempty"`
+	Status     string     `json:"status"`
+	DetectedAt time.Time  `json:"detectedAt"`
+	ReviewedAt *time.Time `json:"reviewedAt,omitempty"`
+	ReviewNote string     `json:"reviewNote,omitempty"`
+}
+
+// QuarantineReview accepts or rejects a quarantined row, or puts it back under review
+type QuarantineReview struct {
+	Status string `json:"status"`
+	Note   string `json:"note"`
+}
+
+// DataGap is a missing or quarantined period of a series. Column is actual_kwh for generation and
+// the weather column otherwise. Method and Value are empty when no method could estimate it.
+type DataGap struct {
+	Dataset string   `json:"dataset"`
+	Site    string   `json:"site,omitempty"`
+	Period  string   `json:"period"`
+	Column  string   `json:"column"`
+	Reason  string   `json:"reason"`
+	Method  string   `json:"method,omitempty"`
+	Value   *float64 `json:"value,omitempty"`
+}
@@ -0,0 +1,44 @@
+package structure
+
+import "time"
+
+// Revision is one change to an audited value. Key is "Site/YYYY-MM" for monthly generation, the
+// site name for locations and the factor name for emission factors. OldValue is empty for the
+// first recorded value and NewValue for a removed record.
+type Revision struct {
+	ID        int       `json:"id"`
+	Table     string    `json:"table"`
+	Key       string    `json:"key"`
+	Column    string    `json:"column"`
+	OldValue  *float64  `json:"oldValue"`
+	NewValue  *float64  `json:"newValue"`
+	ChangedBy string    `json:"changedBy"`
+	Reason    string    `json:"reason,omitempty"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+// RevisionValue is an audited value as it stood at a point in time
+type RevisionValue struct {
+	Table      string    `json:"table"`
+	Key        string    `json:"key"`
+	Column     string    `json:"column"`
+	Value      float64   `json:"value"`
+	RevisionID int       `json:"revisionId"`
+	ChangedAt  time.Time `json:"changedAt"`
+}
+
+// EmissionFactor converts generation into avoided emissions
+type EmissionFactor struct {
+	Name        string    `json:"name"`
+	Value       float64   `json:"value"`
+	Unit        string    `json:"unit"`
+	Description string    `json:"description,omitempty"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// EmissionFactorUpdate sets an emission factor's value, saying who changed it and why
+type EmissionFactorUpdate struct {
+	Value  *float64 `json:"value"`
+	Author string   `json:"author"`
+	Reason string   `json:"reason"`
+}
@@ -0,0 +1,68 @@
+package structure
+
+// ScenarioRequest describes a what-if change to one or more sites and/or the weather
+type ScenarioRequest struct {
+	Name      string               `json:"name"`
+	StartYear int                  `json:"startYear,omitempty"`
+	EndYear   int                  `json:"endYear,omitempty"`
+	Sites     []SiteModification   `json:"sites"`
+	Weather   *WeatherPerturbation `json:"weather,omitempty"`
+	Persist   bool                 `json:"persist"`
+}
+
+// SiteModification overrides or adjusts the parameters of a single site.
+// Absolute values take precedence over the Added* deltas. A panel count changes capacity at
+// the site's current capacity per panel, so it can't be combined with a capacity change.
+type SiteModification struct {
+	Location           string   `json:"location"`
+	CapacityKW         *float64 `json:"capacityKw,omitempty"`
+	AddedCapacityKW    float64  `json:"addedCapacityKw,omitempty"`
+	NumberOfPanels     *int     `json:"numberOfPanels,omitempty"`
+	AddedPanels        int      `json:"addedPanels,omitempty"`
+	TiltDegrees        *float64 `json:"tiltDegrees,omitempty"`
+	InverterEfficiency *float64 `json:"inverterEfficiency,omitempty"`
+	AdditionalLosses   float64  `json:"additionalLosses,omitempty"`
+}
+
+// WeatherPerturbation shifts the historical weather. A scale of 0 leaves the value unchanged.
+// Months limits the change to those calendar months, e.g. [6, 7, 8] for a hotter summer.
+type WeatherPerturbation struct {
+	TemperatureDeltaC float64 `json:"temperatureDeltaC,omitempty"`
+	IrradianceScale   float64 `json:"irradianceScale,omitempty"`
+	SunshineScale     float64 `json:"sunshineScale,omitempty"`
+	Months            []int   `json:"months,omitempty"`
+}
+
+// ScenarioFigures holds the projected outcome of either the baseline or the scenario
+type ScenarioFigures struct {
+	GenerationKWH    float64  `json:"generationKwh"`
+	TheoreticalKWH   float64  `json:"theoreticalKwh"`
+	ForecastKWH      *float64 `json:"forecastKwh,omitempty"`
+	PerformanceRatio float64  `json:"performanceRatio"`
+	CO2OffsetKg      float64  `json:"co2OffsetKg"`
+}
+
+type ScenarioPeriod struct {
+	Year     int             `json:"year"`
+	Month    int             `json:"month,omitempty"`
+	Location string          `json:"location"`
+	Baseline ScenarioFigures `json:"baseline"`
+	Scenario ScenarioFigures `json:"scenario"`
+}
+
+type ScenarioResponse struct {
+	ID        int64            `json:"id,omitempty"`
+	Name      string           `json:"name"`
+	Monthly   []ScenarioPeriod `json:"monthly"`
+	Yearly    []ScenarioPeriod `json:"yearly"`
+	Persisted bool             `json:"persisted"`
+	// ForecastError says why the forecast model could not be run; the forecasts are left out
+	ForecastError string `json:"forecastError,omitempty"`
+}
+
+// SavedScenario is a scenario run that was persisted on request
+type SavedScenario struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"createdAt"`
+}
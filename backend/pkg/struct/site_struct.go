@@ -0,0 +1,9 @@
+package structure
+
+// Location is a site with its installed capacity in kW and number of panels
+type Location struct {
+	ID                int `json:"-"`
+	Name              string
+	InstalledCapacity float64
+	NumberOfPanels    int
+}
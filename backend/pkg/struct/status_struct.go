@@ -0,0 +1,52 @@
+package structure
+
+// Status is how current the data behind the dashboard is. Stale is set when any part is older
+// than its freshness threshold.
+type Status struct {
+	CheckedAt string           `json:"checkedAt"`
+	Stale     bool             `json:"stale"`
+	Sites     []SiteFreshness  `json:"sites"`
+	Weather   WeatherFreshness `json:"weather"`
+	Pipeline  PipelineStatus   `json:"pipeline"`
+	Models    []ModelVersion   `json:"models"`
+}
+
+// SiteFreshness is the latest month of generation recorded for a site
+type SiteFreshness struct {
+	Site        string  `json:"site"`
+	LatestMonth string  `json:"latestMonth,omitempty"`
+	AgeDays     float64 `json:"ageDays"`
+	Stale       bool    `json:"stale"`
+}
+
+// WeatherFreshness is the latest daily and monthly weather, which is shared by every site
+type WeatherFreshness struct {
+	LatestDay   string  `json:"latestDay,omitempty"`
+	LatestMonth string  `json:"latestMonth,omitempty"`
+	AgeDays     float64 `json:"ageDays"`
+	Stale       bool    `json:"stale"`
+}
+
+// PipelineStatus is the last pipeline run and the last one that succeeded
+type PipelineStatus struct {
+	Running     string  `json:"running,omitempty"`
+	LastRun     *JobRun `json:"lastRun,omitempty"`
+	LastSuccess *JobRun `json:"lastSuccess,omitempty"`
+	AgeHours    float64 `json:"ageHours"`
+	Stale       bool    `json:"stale"`
+}
+
+// ModelVersion is the model run whose output is in use: the latest successful run of each model
+type ModelVersion struct {
+	Model     string  `json:"model"`
+	Version   int64   `json:"version"`
+	TrainedAt string  `json:"trainedAt"`
+	AgeDays   float64 `json:"ageDays"`
+	Stale     bool    `json:"stale"`
+}
+
+// Readiness is the result of each readiness check, "ok" or what is wrong
+type Readiness struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
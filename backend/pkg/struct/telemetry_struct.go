@@ -0,0 +1,48 @@
+package structure
+
+// TelemetryStatus is the state of the SCADA poller
+type TelemetryStatus struct {
+	Enabled      bool                    `json:"enabled"`
+	PollInterval string                  `json:"pollInterval,omitempty"`
+	Timezone     string                  `json:"timezone,omitempty"`
+	Devices      []TelemetryDeviceStatus `json:"devices"`
+	Loggers      []TelemetryLoggerStatus `json:"loggers"`
+}
+
+// TelemetryDeviceStatus is the last poll of one Modbus inverter
+type TelemetryDeviceStatus struct {
+	Site             string   `json:"site"`
+	Inverter         string   `json:"inverter"`
+	Address          string   `json:"address"`
+	UnitID           int      `json:"unitId"`
+	Manufacturer     string   `json:"manufacturer,omitempty"`
+	Model            string   `json:"model,omitempty"`
+	SerialNumber     string   `json:"serialNumber,omitempty"`
+	LastReadAt       string   `json:"lastReadAt,omitempty"`
+	PowerW           *float64 `json:"powerW,omitempty"`
+	LifetimeEnergyWh *float64 `json:"lifetimeEnergyWh,omitempty"`
+	OperatingState   *int     `json:"operatingState,omitempty"`
+	LastError        string   `json:"lastError,omitempty"`
+	FailedPolls      int      `json:"failedPolls"`
+}
+
+// TelemetryLoggerStatus is the last scan of one site logger export directory
+type TelemetryLoggerStatus struct {
+	Site          string `json:"site"`
+	Dir           string `json:"dir"`
+	LastScanAt    string `json:"lastScanAt,omitempty"`
+	FilesImported int    `json:"filesImported"`
+	LastError     string `json:"lastError,omitempty"`
+}
+
+// TelemetryReading is one stored inverter sample
+type TelemetryReading struct {
+	Site             string   `json:"site"`
+	Inverter         string   `json:"inverter"`
+	ReadAt           string   `json:"readAt"`
+	PowerW           *float64 `json:"powerW,omitempty"`
+	DCPowerW         *float64 `json:"dcPowerW,omitempty"`
+	LifetimeEnergyWh *float64 `json:"lifetimeEnergyWh,omitempty"`
+	OperatingState   *int     `json:"operatingState,omitempty"`
+	Source           string   `json:"source"`
+}
@@ -37,4 +37,21 @@ type WeatherImpactData struct {
 	AvgWindSpeed            float64 `json:"avgWindSpeed"`
 	CumulativeRainfall      float64 `json:"cumulativeRainfall"`
 	TotalPowerGeneration    float64 `json:"totalPowerGeneration"`
-}
\ No newline at end of file
+}
+
+// WeatherMonth is a month's average daily sunshine in seconds and irradiance in W/m², over the
+// days with weather
+type WeatherMonth struct {
+	Year       int
+	Month      int
+	Sunshine   float64
+	Irradiance float64
+	Days       int
+}
+
+// WeatherDay is one day's sunshine in seconds and irradiance in W/m²
+type WeatherDay struct {
+	Date       string
+	Sunshine   float64
+	Irradiance float64
+}
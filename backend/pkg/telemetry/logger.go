@@ -0,0 +1,181 @@
+package telemetry
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// loggerLayouts are the timestamp formats seen in logger exports. Layouts without an offset are
+// plant local time.
+var loggerLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05", "2006-01-02T15:04"}
+
+// ImportLoggerCSV reads a site logger export with timestamp, inverter and a lifetime energy counter
+// in energy_wh or energy_kwh. power_w, dc_power_w and status columns are optional.
+func ImportLoggerCSV(r io.Reader, locationID int, location *time.Location) ([]Reading, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV header: %v", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"timestamp", "inverter"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing %s column", name)
+		}
+	}
+
+	energyIndex, energyScale := -1, 1.0
+	if index, ok := columns["energy_wh"]; ok {
+		energyIndex = index
+	} else if index, ok := columns["energy_kwh"]; ok {
+		energyIndex, energyScale = index, 1000
+	}
+	if energyIndex == -1 {
+		return nil, errors.New("missing energy_wh or energy_kwh column")
+	}
+
+	var readings []Reading
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		readAt, err := parseLoggerTimestamp(record[columns["timestamp"]], location)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		inverter := strings.TrimSpace(record[columns["inverter"]])
+		if inverter == "" {
+			return nil, fmt.Errorf("line %d: missing inverter", line)
+		}
+
+		reading := Reading{LocationID: locationID, Inverter: inverter, ReadAt: readAt, Source: SourceLogger}
+		if reading.LifetimeEnergyWh, err = optionalFloat(record, energyIndex, energyScale); err != nil {
+			return nil, fmt.Errorf("line %d: invalid %s: %v", line, header[energyIndex], err)
+		}
+		if index, ok := columns["power_w"]; ok {
+			if reading.PowerW, err = optionalFloat(record, index, 1); err != nil {
+				return nil, fmt.Errorf("line %d: invalid power_w: %v", line, err)
+			}
+		}
+		if index, ok := columns["dc_power_w"]; ok {
+			if reading.DCPowerW, err = optionalFloat(record, index, 1); err != nil {
+				return nil, fmt.Errorf("line %d: invalid dc_power_w: %v", line, err)
+			}
+		}
+		if index, ok := columns["status"]; ok && strings.TrimSpace(record[index]) != "" {
+			state, err := strconv.Atoi(strings.TrimSpace(record[index]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid status %q", line, record[index])
+			}
+			reading.OperatingState = &state
+		}
+		readings = append(readings, reading)
+	}
+
+	if len(readings) == 0 {
+		return nil, errors.New("CSV has no rows")
+	}
+	return readings, nil
+}
+
+// ScanLoggerDir imports every CSV in dir that has not been imported before. Files are recognised
+// by content hash, so a logger that rewrites the same export is not read twice. A file that fails
+// to parse is logged and retried on the next scan.
+func (p *Poller) ScanLoggerDir(dir string, locationID int, location *time.Location) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.csv"))
+	if err != nil {
+		return 0, err
+	}
+	sort.Strings(paths)
+
+	files := 0
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			slog.Error("Error reading logger export", "path", path, "err", err)
+			continue
+		}
+		sum := sha256.Sum256(content)
+		hash := hex.EncodeToString(sum[:])
+
+		var existing int
+		err = p.db.QueryRow(`SELECT id FROM telemetry_files WHERE sha256 = ?`, hash).Scan(&existing)
+		if err == nil {
+			continue
+		}
+		if err != sql.ErrNoRows {
+			return files, fmt.Errorf("error checking logger export %s: %v", path, err)
+		}
+
+		readings, err := ImportLoggerCSV(bytes.NewReader(content), locationID, location)
+		if err != nil {
+			slog.Warn("Skipping logger export", "path", path, "err", err)
+			continue
+		}
+		inserted, err := p.SaveReadings(readings)
+		if err != nil {
+			return files, fmt.Errorf("error saving logger export %s: %v", path, err)
+		}
+
+		_, err = p.db.Exec(`
+			INSERT INTO telemetry_files (path, sha256, location_id, rows_imported, imported_at)
+			VALUES (?, ?, ?, ?, ?)
+		`, path, hash, locationID, inserted, time.Now().UTC().Format(timestampLayout))
+		if err != nil {
+			return files, fmt.Errorf("error recording logger export %s: %v", path, err)
+		}
+
+		slog.Info("Imported logger export", "path", path, "new_readings", inserted)
+		files++
+	}
+	return files, nil
+}
+
+func parseLoggerTimestamp(value string, location *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return wallClock(t, location), nil
+	}
+	for _, layout := range loggerLayouts[1:] {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+}
+
+func optionalFloat(record []string, index int, scale float64) (*float64, error) {
+	value := strings.TrimSpace(record[index])
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+	parsed *= scale
+	return &parsed, nil
+}
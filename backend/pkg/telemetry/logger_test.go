@@ -0,0 +1,83 @@
+package telemetry
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportLoggerCSV(t *testing.T) {
+	bahrain := time.FixedZone("AST", 3*60*60)
+	tests := []struct {
+		name    string
+		csv     string
+		want    []Reading
+		wantErr string
+	}{
+		{
+			name: "energy in Wh with optional columns",
+			csv:  "Timestamp,Inverter,energy_wh,power_w,dc_power_w,status\n2024-03-01 10:15:00,INV1,1500,400,410,4\n",
+			want: []Reading{{Inverter: "INV1", ReadAt: time.Date(2024, 3, 1, 10, 15, 0, 0, time.UTC), LifetimeEnergyWh: ptr(1500.0), PowerW: ptr(400.0), DCPowerW: ptr(410.0), OperatingState: ptr(4)}},
+		},
+		{
+			name: "energy in kWh",
+			csv:  "timestamp,inverter,energy_kwh\n2024-03-01T10:15,INV1,1.5\n",
+			want: []Reading{{Inverter: "INV1", ReadAt: time.Date(2024, 3, 1, 10, 15, 0, 0, time.UTC), LifetimeEnergyWh: ptr(1500.0)}},
+		},
+		{
+			name: "offset converted to plant time",
+			csv:  "timestamp,inverter,energy_wh\n2024-03-01T07:15:00Z,INV1,\n",
+			want: []Reading{{Inverter: "INV1", ReadAt: time.Date(2024, 3, 1, 10, 15, 0, 0, time.UTC)}},
+		},
+		{
+			name: "blank optional values",
+			csv:  "timestamp,inverter,energy_wh,power_w,status\n2024-03-01 10:15,INV2,, ,\n",
+			want: []Reading{{Inverter: "INV2", ReadAt: time.Date(2024, 3, 1, 10, 15, 0, 0, time.UTC)}},
+		},
+		{name: "missing inverter column", csv: "timestamp,energy_wh\n2024-03-01 10:15,1\n", wantErr: "missing inverter column"},
+		{name: "missing energy column", csv: "timestamp,inverter\n2024-03-01 10:15,INV1\n", wantErr: "missing energy_wh or energy_kwh column"},
+		{name: "no rows", csv: "timestamp,inverter,energy_wh\n", wantErr: "CSV has no rows"},
+		{name: "bad timestamp", csv: "timestamp,inverter,energy_wh\n01/03/2024,INV1,1\n", wantErr: "line 2: invalid timestamp"},
+		{name: "blank inverter", csv: "timestamp,inverter,energy_wh\n2024-03-01 10:15, ,1\n", wantErr: "line 2: missing inverter"},
+		{name: "bad energy", csv: "timestamp,inverter,energy_wh\n2024-03-01 10:15,INV1,lots\n", wantErr: "line 2: invalid energy_wh"},
+		{name: "bad status", csv: "timestamp,inverter,energy_wh,status\n2024-03-01 10:15,INV1,1,on\n", wantErr: "line 2: invalid status"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readings, err := ImportLoggerCSV(strings.NewReader(tt.csv), 7, bahrain)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(readings) != len(tt.want) {
+				t.Fatalf("got %d readings, want %d", len(readings), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := readings[i]
+				if got.LocationID != 7 || got.Source != SourceLogger || got.Inverter != want.Inverter || !got.ReadAt.Equal(want.ReadAt) {
+					t.Errorf("reading %d = %d %s %s at %v, want 7 %s %s at %v", i, got.LocationID, got.Source, got.Inverter, got.ReadAt, SourceLogger, want.Inverter, want.ReadAt)
+				}
+				if !equalPtr(got.LifetimeEnergyWh, want.LifetimeEnergyWh) || !equalPtr(got.PowerW, want.PowerW) ||
+					!equalPtr(got.DCPowerW, want.DCPowerW) || !equalPtr(got.OperatingState, want.OperatingState) {
+					t.Errorf("reading %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,120 @@
+package telemetry
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	functionReadHoldingRegisters = 0x03
+	// maxRegistersPerRead is the Modbus limit for a single read holding registers request
+	maxRegistersPerRead = 125
+	mbapHeaderLength    = 7
+)
+
+// ModbusError is an exception response from the device
+type ModbusError struct {
+	Function  byte
+	Exception byte
+}
+
+func (e *ModbusError) Error() string {
+	return fmt.Sprintf("modbus exception %d on function %d", e.Exception, e.Function)
+}
+
+// Client is a minimal Modbus TCP client for reading holding registers.
+// It is not safe for concurrent use.
+type Client struct {
+	conn          net.Conn
+	unitID        byte
+	timeout       time.Duration
+	transactionID uint16
+}
+
+// Dial connects to a Modbus TCP device. timeout bounds the connect and every request.
+func Dial(address string, unitID byte, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %v", address, err)
+	}
+	return &Client{conn: conn, unitID: unitID, timeout: timeout}, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// ReadHoldingRegisters reads count registers starting at address, splitting the read into
+// requests of at most 125 registers
+func (c *Client) ReadHoldingRegisters(address, count uint16) ([]uint16, error) {
+	registers := make([]uint16, 0, count)
+	for count > 0 {
+		n := count
+		if n > maxRegistersPerRead {
+			n = maxRegistersPerRead
+		}
+		chunk, err := c.readHoldingRegisters(address, n)
+		if err != nil {
+			return nil, err
+		}
+		registers = append(registers, chunk...)
+		address += n
+		count -= n
+	}
+	return registers, nil
+}
+
+func (c *Client) readHoldingRegisters(address, count uint16) ([]uint16, error) {
+	c.transactionID++
+
+	request := make([]byte, 12)
+	binary.BigEndian.PutUint16(request[0:], c.transactionID)
+	binary.BigEndian.PutUint16(request[2:], 0) // protocol identifier
+	binary.BigEndian.PutUint16(request[4:], 6) // unit id + PDU
+	request[6] = c.unitID
+	request[7] = functionReadHoldingRegisters
+	binary.BigEndian.PutUint16(request[8:], address)
+	binary.BigEndian.PutUint16(request[10:], count)
+
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return nil, err
+	}
+	if _, err := c.conn.Write(request); err != nil {
+		return nil, fmt.Errorf("error writing request: %v", err)
+	}
+
+	header := make([]byte, mbapHeaderLength)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return nil, fmt.Errorf("error reading response header: %v", err)
+	}
+	length := binary.BigEndian.Uint16(header[4:])
+	if length < 2 || length > 256 {
+		return nil, fmt.Errorf("invalid response length %d", length)
+	}
+	pdu := make([]byte, length-1)
+	if _, err := io.ReadFull(c.conn, pdu); err != nil {
+		return nil, fmt.Errorf("error reading response: %v", err)
+	}
+
+	if id := binary.BigEndian.Uint16(header[0:]); id != c.transactionID {
+		return nil, fmt.Errorf("response transaction %d does not match request %d", id, c.transactionID)
+	}
+	if pdu[0] == functionReadHoldingRegisters|0x80 {
+		return nil, &ModbusError{Function: functionReadHoldingRegisters, Exception: pdu[1]}
+	}
+	if pdu[0] != functionReadHoldingRegisters {
+		return nil, fmt.Errorf("unexpected function %d in response", pdu[0])
+	}
+	if int(pdu[1]) != int(count)*2 || len(pdu) != 2+int(count)*2 {
+		return nil, fmt.Errorf("expected %d registers, got %d bytes", count, pdu[1])
+	}
+
+	registers := make([]uint16, count)
+	for i := range registers {
+		registers[i] = binary.BigEndian.Uint16(pdu[2+i*2:])
+	}
+	return registers, nil
+}
@@ -0,0 +1,378 @@
+package telemetry
+
+import (
+	"backend/pkg/db"
+	"backend/pkg/metrics"
+	structure "backend/pkg/struct"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	// Embedded so the plant time zone resolves on hosts without tzdata
+	_ "time/tzdata"
+)
+
+const (
+	defaultPollInterval = time.Minute
+	defaultTimeout      = 5 * time.Second
+	defaultTimezone     = "Asia/Bahrain"
+	defaultUnitID       = 1
+)
+
+// Config is the telemetry configuration file. Durations use Go syntax, such as "30s".
+type Config struct {
+	PollInterval string         `json:"pollInterval"`
+	Timeout      string         `json:"timeout"`
+	Timezone     string         `json:"timezone"`
+	Devices      []DeviceConfig `json:"devices"`
+	Loggers      []LoggerConfig `json:"loggers"`
+}
+
+// DeviceConfig is a SunSpec inverter reachable over Modbus TCP
+type DeviceConfig struct {
+	Site     string `json:"site"`
+	Inverter string `json:"inverter"`
+	Address  string `json:"address"`
+	UnitID   int    `json:"unitId"`
+}
+
+// LoggerConfig is a directory the site logger drops its scheduled CSV exports into
+type LoggerConfig struct {
+	Site string `json:"site"`
+	Dir  string `json:"dir"`
+}
+
+// LoadConfig reads a JSON telemetry configuration
+func LoadConfig(path string) (*Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading telemetry config: %v", err)
+	}
+	defer file.Close()
+
+	var config Config
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("error decoding telemetry config %s: %v", path, err)
+	}
+	return &config, nil
+}
+
+type devicePoller struct {
+	config     DeviceConfig
+	locationID int
+	client     *Client
+	device     *Device
+	status     structure.TelemetryDeviceStatus
+}
+
+type loggerScanner struct {
+	config     LoggerConfig
+	locationID int
+	status     structure.TelemetryLoggerStatus
+}
+
+// Poller reads the configured inverters every poll interval and scans the logger directories.
+// pollMu serializes polls and owns the Modbus connections; mu guards configuration and status.
+type Poller struct {
+	db *db.DB
+
+	pollMu   sync.Mutex
+	mu       sync.Mutex
+	enabled  bool
+	interval time.Duration
+	timeout  time.Duration
+	location *time.Location
+	devices  []*devicePoller
+	loggers  []*loggerScanner
+}
+
+// NewPoller returns a poller that stores its readings in database. It stays disabled until
+// configured.
+func NewPoller(database *db.DB) *Poller {
+	return &Poller{db: database}
+}
+
+// Configure validates config and resolves its sites. It must be called before Start.
+func (p *Poller) Configure(config *Config) error {
+	interval, err := durationOrDefault(config.PollInterval, defaultPollInterval)
+	if err != nil {
+		return fmt.Errorf("invalid pollInterval: %v", err)
+	}
+	timeout, err := durationOrDefault(config.Timeout, defaultTimeout)
+	if err != nil {
+		return fmt.Errorf("invalid timeout: %v", err)
+	}
+	if config.Timezone == "" {
+		config.Timezone = defaultTimezone
+	}
+	location, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone: %v", err)
+	}
+
+	var devices []*devicePoller
+	seen := make(map[string]bool)
+	for i, device := range config.Devices {
+		if device.Inverter == "" || device.Address == "" {
+			return fmt.Errorf("device %d: inverter and address are required", i)
+		}
+		if device.UnitID == 0 {
+			device.UnitID = defaultUnitID
+		}
+		if device.UnitID < 0 || device.UnitID > 247 {
+			return fmt.Errorf("device %s: invalid unitId %d", device.Inverter, device.UnitID)
+		}
+		locationID, err := p.siteID(device.Site)
+		if err != nil {
+			return fmt.Errorf("device %s: %v", device.Inverter, err)
+		}
+		key := fmt.Sprintf("%d/%s", locationID, device.Inverter)
+		if seen[key] {
+			return fmt.Errorf("device %s is configured twice for %s", device.Inverter, device.Site)
+		}
+		seen[key] = true
+
+		devices = append(devices, &devicePoller{
+			config:     device,
+			locationID: locationID,
+			status: structure.TelemetryDeviceStatus{
+				Site: device.Site, Inverter: device.Inverter, Address: device.Address, UnitID: device.UnitID,
+			},
+		})
+	}
+
+	var loggers []*loggerScanner
+	for i, logger := range config.Loggers {
+		if logger.Dir == "" {
+			return fmt.Errorf("logger %d: dir is required", i)
+		}
+		locationID, err := p.siteID(logger.Site)
+		if err != nil {
+			return fmt.Errorf("logger %s: %v", logger.Dir, err)
+		}
+		loggers = append(loggers, &loggerScanner{
+			config:     logger,
+			locationID: locationID,
+			status:     structure.TelemetryLoggerStatus{Site: logger.Site, Dir: logger.Dir},
+		})
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.enabled = true
+	p.interval = interval
+	p.timeout = timeout
+	p.location = location
+	p.devices = devices
+	p.loggers = loggers
+	return nil
+}
+
+// Start polls immediately and then every poll interval until ctx is cancelled
+func (p *Poller) Start(ctx context.Context) {
+	p.mu.Lock()
+	enabled, interval := p.enabled, p.interval
+	p.mu.Unlock()
+	if !enabled {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			p.PollOnce(ctx)
+			select {
+			case <-ctx.Done():
+				p.closeClients()
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// PollOnce reads every device, stores the readings and then scans the logger directories
+func (p *Poller) PollOnce(ctx context.Context) {
+	p.pollMu.Lock()
+	defer p.pollMu.Unlock()
+
+	readings := make([]Reading, 0, len(p.devices))
+	var wg sync.WaitGroup
+	for _, device := range p.devices {
+		wg.Add(1)
+		go func(device *devicePoller) {
+			defer wg.Done()
+			reading, err := p.poll(device)
+
+			metrics.RecordIngest("modbus:"+device.config.Inverter, err)
+
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if err != nil {
+				device.status.LastError = err.Error()
+				device.status.FailedPolls++
+				if device.status.FailedPolls == 1 {
+					slog.Error("Error polling inverter", "inverter", device.config.Inverter, "address", device.config.Address, "err", err)
+				}
+				return
+			}
+
+			if device.status.FailedPolls > 0 {
+				slog.Info("Inverter recovered", "inverter", device.config.Inverter, "address", device.config.Address, "failed_polls", device.status.FailedPolls)
+			}
+			device.status.LastError = ""
+			device.status.FailedPolls = 0
+			device.status.Manufacturer = device.device.Manufacturer
+			device.status.Model = device.device.Model
+			device.status.SerialNumber = device.device.SerialNumber
+			device.status.LastReadAt = reading.ReadAt.Format(timestampLayout)
+			device.status.PowerW = reading.PowerW
+			device.status.LifetimeEnergyWh = reading.LifetimeEnergyWh
+			device.status.OperatingState = reading.OperatingState
+			readings = append(readings, *reading)
+		}(device)
+	}
+	wg.Wait()
+
+	if _, err := p.SaveReadings(readings); err != nil {
+		slog.Error("Error saving telemetry readings", "err", err)
+	}
+
+	for _, logger := range p.loggers {
+		if ctx.Err() != nil {
+			return
+		}
+		files, err := p.ScanLoggerDir(logger.config.Dir, logger.locationID, p.location)
+		metrics.RecordIngest("logger:"+logger.config.Site, err)
+		if err != nil {
+			slog.Error("Error scanning logger exports", "dir", logger.config.Dir, "err", err)
+		}
+
+		p.mu.Lock()
+		logger.status.LastScanAt = time.Now().UTC().Format(time.RFC3339)
+		logger.status.FilesImported += files
+		logger.status.LastError = ""
+		if err != nil {
+			logger.status.LastError = err.Error()
+		}
+		p.mu.Unlock()
+	}
+}
+
+// poll reads one inverter, reconnecting and rediscovering its register map after any error
+func (p *Poller) poll(device *devicePoller) (*Reading, error) {
+	if device.client == nil {
+		client, err := Dial(device.config.Address, byte(device.config.UnitID), p.timeout)
+		if err != nil {
+			return nil, err
+		}
+		device.client = client
+	}
+
+	reading, err := p.read(device)
+	if err != nil {
+		device.client.Close()
+		device.client, device.device = nil, nil
+		return nil, err
+	}
+	return reading, nil
+}
+
+func (p *Poller) read(device *devicePoller) (*Reading, error) {
+	if device.device == nil {
+		discovered, err := Discover(device.client)
+		if err != nil {
+			return nil, err
+		}
+		device.device = discovered
+	}
+
+	inverter, err := ReadInverter(device.client, device.device)
+	if err != nil {
+		return nil, err
+	}
+	return &Reading{
+		LocationID: device.locationID,
+		Inverter:   device.config.Inverter,
+		// Readings are stored to the second; one poll per second is more than any inverter updates
+		ReadAt:           wallClock(time.Now(), p.location).Truncate(time.Second),
+		PowerW:           inverter.PowerW,
+		DCPowerW:         inverter.DCPowerW,
+		LifetimeEnergyWh: inverter.LifetimeEnergyWh,
+		OperatingState:   inverter.OperatingState,
+		Source:           SourceModbus,
+	}, nil
+}
+
+func (p *Poller) closeClients() {
+	p.pollMu.Lock()
+	defer p.pollMu.Unlock()
+	for _, device := range p.devices {
+		if device.client != nil {
+			device.client.Close()
+			device.client, device.device = nil, nil
+		}
+	}
+}
+
+// Status returns the last poll of every device and logger directory
+func (p *Poller) Status() structure.TelemetryStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := structure.TelemetryStatus{
+		Enabled: p.enabled,
+		Devices: []structure.TelemetryDeviceStatus{},
+		Loggers: []structure.TelemetryLoggerStatus{},
+	}
+	if !p.enabled {
+		return status
+	}
+	status.PollInterval = p.interval.String()
+	status.Timezone = p.location.String()
+	for _, device := range p.devices {
+		status.Devices = append(status.Devices, device.status)
+	}
+	for _, logger := range p.loggers {
+		status.Loggers = append(status.Loggers, logger.status)
+	}
+	return status
+}
+
+// siteID resolves a configured site name. Total System is derived and has no inverters.
+func (p *Poller) siteID(site string) (int, error) {
+	var id int
+	err := p.db.QueryRow(`
+		SELECT id FROM locations WHERE LOWER(name) = LOWER(?) AND name != 'Total System'
+	`, strings.TrimSpace(site)).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("unknown site %q", site)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error resolving site %q: %v", site, err)
+	}
+	return id, nil
+}
+
+func durationOrDefault(value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("must be positive, got %s", value)
+	}
+	return duration, nil
+}
@@ -0,0 +1,236 @@
+package telemetry
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Reading sources recorded in telemetry_readings.source and interval_generation.source
+const (
+	SourceModbus = "modbus"
+	SourceLogger = "logger"
+)
+
+const (
+	// IntervalMinutes is the resolution telemetry is normalized to, matching the meter exports
+	IntervalMinutes = 15
+	// maxReadingGap is the longest gap between two readings whose energy is still spread over the
+	// intervals in between. Across longer gaps it is unknown when the energy was produced.
+	maxReadingGap   = 2 * time.Hour
+	timestampLayout = "2006-01-02 15:04:05"
+)
+
+// Reading is one inverter sample. ReadAt is plant local wall-clock time, held in UTC the same way
+// the database driver returns TIMESTAMP columns. Values the device does not report are nil.
+type Reading struct {
+	LocationID       int
+	Inverter         string
+	ReadAt           time.Time
+	PowerW           *float64
+	DCPowerW         *float64
+	LifetimeEnergyWh *float64
+	OperatingState   *int
+	Source           string
+}
+
+type inverterKey struct {
+	locationID int
+	inverter   string
+}
+
+// SaveReadings stores readings and rewrites the interval_generation rows they affect. Readings
+// already stored are ignored. It returns the number of new readings.
+func (p *Poller) SaveReadings(readings []Reading) (int, error) {
+	if len(readings) == 0 {
+		return 0, nil
+	}
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO telemetry_readings (location_id, inverter, read_at, power_w, dc_power_w, lifetime_energy_wh, operating_state, source)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (location_id, inverter, read_at) DO NOTHING
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("error preparing statement: %v", err)
+	}
+	defer stmt.Close()
+
+	inserted := 0
+	earliest := make(map[inverterKey]time.Time)
+	sources := make(map[inverterKey]string)
+	for _, reading := range readings {
+		result, err := stmt.Exec(reading.LocationID, reading.Inverter, reading.ReadAt.Format(timestampLayout),
+			reading.PowerW, reading.DCPowerW, reading.LifetimeEnergyWh, reading.OperatingState, reading.Source)
+		if err != nil {
+			return 0, fmt.Errorf("error inserting reading for inverter %s: %v", reading.Inverter, err)
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			continue
+		}
+		inserted++
+		if reading.LifetimeEnergyWh == nil {
+			continue
+		}
+
+		key := inverterKey{reading.LocationID, reading.Inverter}
+		if first, ok := earliest[key]; !ok || reading.ReadAt.Before(first) {
+			earliest[key] = reading.ReadAt
+		}
+		sources[key] = reading.Source
+	}
+
+	for key, since := range earliest {
+		if err := normalizeIntervals(tx, key, since, sources[key]); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing readings: %v", err)
+	}
+	return inserted, nil
+}
+
+// normalizeIntervals turns the lifetime energy counter into interval energy from the interval
+// holding the reading before since onwards. The energy between two readings is split across the
+// intervals they span in proportion to time. Counter resets and gaps longer than maxReadingGap
+// are skipped rather than guessed at. Intervals imported from a meter CSV are left alone.
+func normalizeIntervals(tx *sql.Tx, key inverterKey, since time.Time, source string) error {
+	windowStart := since
+	previous, ok, err := latestReadingBefore(tx, key, since, false)
+	if err != nil {
+		return err
+	}
+	if ok {
+		windowStart = previous
+	}
+	windowStart = windowStart.Truncate(IntervalMinutes * time.Minute)
+
+	// The reading at or before windowStart carries energy into the first interval
+	from := windowStart
+	anchor, ok, err := latestReadingBefore(tx, key, windowStart, true)
+	if err != nil {
+		return err
+	}
+	if ok {
+		from = anchor
+	}
+
+	rows, err := tx.Query(`
+		SELECT read_at, lifetime_energy_wh
+		FROM telemetry_readings
+		WHERE location_id = ? AND inverter = ? AND read_at >= ? AND lifetime_energy_wh IS NOT NULL
+		ORDER BY read_at
+	`, key.locationID, key.inverter, from.Format(timestampLayout))
+	if err != nil {
+		return fmt.Errorf("error querying readings: %v", err)
+	}
+
+	var times []time.Time
+	var energies []float64
+	for rows.Next() {
+		var readAt time.Time
+		var energy float64
+		if err := rows.Scan(&readAt, &energy); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning reading: %v", err)
+		}
+		times = append(times, readAt)
+		energies = append(energies, energy)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	intervals := splitEnergy(times, energies)
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO interval_generation (interval_start, interval_minutes, location_id, inverter, energy_kwh, source)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (interval_start, location_id, inverter)
+		DO UPDATE SET interval_minutes = excluded.interval_minutes, energy_kwh = excluded.energy_kwh, source = excluded.source
+		WHERE interval_generation.source != 'csv'
+	`)
+	if err != nil {
+		return fmt.Errorf("error preparing statement: %v", err)
+	}
+	defer stmt.Close()
+
+	for start, energyWh := range intervals {
+		if start.Before(windowStart) {
+			continue
+		}
+		energyKWh := math.Round(energyWh) / 1000
+		if _, err := stmt.Exec(start.Format(timestampLayout), IntervalMinutes, key.locationID, key.inverter, energyKWh, source); err != nil {
+			return fmt.Errorf("error writing interval generation: %v", err)
+		}
+	}
+	return nil
+}
+
+// latestReadingBefore returns the time of the last reading with an energy value before t,
+// or at t when inclusive is set
+func latestReadingBefore(tx *sql.Tx, key inverterKey, t time.Time, inclusive bool) (time.Time, bool, error) {
+	comparison := "<"
+	if inclusive {
+		comparison = "<="
+	}
+
+	var readAt time.Time
+	err := tx.QueryRow(`
+		SELECT read_at FROM telemetry_readings
+		WHERE location_id = ? AND inverter = ? AND read_at `+comparison+` ? AND lifetime_energy_wh IS NOT NULL
+		ORDER BY read_at DESC
+		LIMIT 1
+	`, key.locationID, key.inverter, t.Format(timestampLayout)).Scan(&readAt)
+	if err == sql.ErrNoRows {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("error querying previous reading: %v", err)
+	}
+	return readAt, true, nil
+}
+
+// splitEnergy spreads the counter increase between consecutive readings over the intervals they
+// span. It returns Wh per interval start; intervals covered only by skipped pairs are absent.
+func splitEnergy(times []time.Time, energies []float64) map[time.Time]float64 {
+	interval := IntervalMinutes * time.Minute
+	intervals := make(map[time.Time]float64)
+
+	for i := 1; i < len(times); i++ {
+		from, to := times[i-1], times[i]
+		span := to.Sub(from)
+		delta := energies[i] - energies[i-1]
+		if span <= 0 || span > maxReadingGap || delta < 0 {
+			continue
+		}
+
+		for start := from.Truncate(interval); start.Before(to); start = start.Add(interval) {
+			overlapStart, overlapEnd := start, start.Add(interval)
+			if from.After(overlapStart) {
+				overlapStart = from
+			}
+			if to.Before(overlapEnd) {
+				overlapEnd = to
+			}
+			intervals[start] += delta * float64(overlapEnd.Sub(overlapStart)) / float64(span)
+		}
+	}
+	return intervals
+}
+
+// wallClock converts t to the plant's local wall-clock time, held in UTC
+func wallClock(t time.Time, location *time.Location) time.Time {
+	local := t.In(location)
+	return time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), 0, time.UTC)
+}
@@ -0,0 +1,192 @@
+package telemetry
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// SunSpec model IDs read by the poller
+const (
+	ModelCommon              = 1
+	ModelInverterSinglePhase = 101
+	ModelInverterSplitPhase  = 102
+	ModelInverterThreePhase  = 103
+	modelEnd                 = 0xFFFF
+)
+
+// sunSpecMarker is "SunS" in two registers
+var sunSpecMarker = [2]uint16{0x5375, 0x6E53}
+
+// baseAddresses are the SunSpec base register addresses, in the order devices most often use them
+var baseAddresses = []uint16{40000, 0, 50000}
+
+// Register offsets from the start of an inverter model (101-103), counting the ID and length registers
+const (
+	inverterW     = 14
+	inverterWSF   = 15
+	inverterWH    = 24
+	inverterWHSF  = 26
+	inverterDCW   = 31
+	inverterDCWSF = 32
+	inverterSt    = 38
+)
+
+// Register offsets in the common model
+const (
+	commonMn = 2
+	commonMd = 18
+	commonSN = 50
+)
+
+// ErrNotSunSpec means no SunSpec marker was found at any base address
+var ErrNotSunSpec = errors.New("device does not expose a SunSpec register map")
+
+// Model is one block in the SunSpec model chain
+type Model struct {
+	ID      uint16
+	Address uint16
+	Length  uint16
+}
+
+// Device is a discovered SunSpec device
+type Device struct {
+	Manufacturer string
+	Model        string
+	SerialNumber string
+	Models       []Model
+}
+
+// InverterReading is one read of an inverter model. Values the device does not implement are nil.
+type InverterReading struct {
+	PowerW           *float64
+	DCPowerW         *float64
+	LifetimeEnergyWh *float64
+	OperatingState   *int
+}
+
+// Discover finds the SunSpec base address and walks the model chain
+func Discover(client *Client) (*Device, error) {
+	var base uint16
+	found := false
+	for _, address := range baseAddresses {
+		marker, err := client.ReadHoldingRegisters(address, 2)
+		if err != nil {
+			var modbusErr *ModbusError
+			if errors.As(err, &modbusErr) {
+				continue
+			}
+			return nil, err
+		}
+		if marker[0] == sunSpecMarker[0] && marker[1] == sunSpecMarker[1] {
+			base, found = address, true
+			break
+		}
+	}
+	if !found {
+		return nil, ErrNotSunSpec
+	}
+
+	device := &Device{}
+	address := base + 2
+	for {
+		header, err := client.ReadHoldingRegisters(address, 2)
+		if err != nil {
+			return nil, fmt.Errorf("error reading model header at %d: %v", address, err)
+		}
+		if header[0] == modelEnd {
+			break
+		}
+		device.Models = append(device.Models, Model{ID: header[0], Address: address, Length: header[1]})
+		if int(address)+2+int(header[1]) > math.MaxUint16 {
+			return nil, fmt.Errorf("model %d at %d runs past the register space", header[0], address)
+		}
+		address += 2 + header[1]
+	}
+
+	if common, ok := device.model(ModelCommon); ok {
+		registers, err := client.ReadHoldingRegisters(common.Address, commonSN+16)
+		if err != nil {
+			return nil, fmt.Errorf("error reading common model: %v", err)
+		}
+		device.Manufacturer = registerString(registers[commonMn : commonMn+16])
+		device.Model = registerString(registers[commonMd : commonMd+16])
+		device.SerialNumber = registerString(registers[commonSN : commonSN+16])
+	}
+	return device, nil
+}
+
+func (d *Device) model(id uint16) (Model, bool) {
+	for _, model := range d.Models {
+		if model.ID == id {
+			return model, true
+		}
+	}
+	return Model{}, false
+}
+
+// inverterModel returns the first single, split or three phase inverter model
+func (d *Device) inverterModel() (Model, bool) {
+	for _, model := range d.Models {
+		switch model.ID {
+		case ModelInverterSinglePhase, ModelInverterSplitPhase, ModelInverterThreePhase:
+			return model, true
+		}
+	}
+	return Model{}, false
+}
+
+// ReadInverter reads AC power, DC power, lifetime energy and operating state
+func ReadInverter(client *Client, device *Device) (*InverterReading, error) {
+	model, ok := device.inverterModel()
+	if !ok {
+		return nil, errors.New("device has no inverter model")
+	}
+	if int(model.Length)+2 <= inverterSt {
+		return nil, fmt.Errorf("inverter model %d is too short (%d registers)", model.ID, model.Length)
+	}
+
+	registers, err := client.ReadHoldingRegisters(model.Address, inverterSt+1)
+	if err != nil {
+		return nil, fmt.Errorf("error reading inverter model: %v", err)
+	}
+
+	reading := &InverterReading{
+		PowerW:           scaledInt16(registers[inverterW], registers[inverterWSF]),
+		DCPowerW:         scaledInt16(registers[inverterDCW], registers[inverterDCWSF]),
+		LifetimeEnergyWh: scaledAcc32(registers[inverterWH], registers[inverterWH+1], registers[inverterWHSF]),
+	}
+	if state := registers[inverterSt]; state != 0xFFFF {
+		value := int(state)
+		reading.OperatingState = &value
+	}
+	return reading, nil
+}
+
+// scaledInt16 applies a sunssf scale factor. 0x8000 marks an unimplemented value or factor.
+func scaledInt16(value, scaleFactor uint16) *float64 {
+	if value == 0x8000 || scaleFactor == 0x8000 {
+		return nil
+	}
+	scaled := float64(int16(value)) * math.Pow10(int(int16(scaleFactor)))
+	return &scaled
+}
+
+// scaledAcc32 applies a scale factor to an accumulator. An accumulator of 0 is unimplemented.
+func scaledAcc32(high, low, scaleFactor uint16) *float64 {
+	value := uint32(high)<<16 | uint32(low)
+	if value == 0 || scaleFactor == 0x8000 {
+		return nil
+	}
+	scaled := float64(value) * math.Pow10(int(int16(scaleFactor)))
+	return &scaled
+}
+
+func registerString(registers []uint16) string {
+	b := make([]byte, 0, len(registers)*2)
+	for _, register := range registers {
+		b = append(b, byte(register>>8), byte(register))
+	}
+	return strings.TrimSpace(strings.TrimRight(string(b), "\x00"))
+}
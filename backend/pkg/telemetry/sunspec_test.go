@@ -0,0 +1,250 @@
+package telemetry
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDevice answers Modbus read holding register requests from a register map. Addresses
+// missing from it get an illegal data address exception, as real devices do.
+func fakeDevice(t *testing.T, registers map[uint16]uint16) *Client {
+	client, server := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+
+	go func() {
+		defer server.Close()
+		request := make([]byte, 12)
+		for {
+			if _, err := io.ReadFull(server, request); err != nil {
+				return
+			}
+			address := binary.BigEndian.Uint16(request[8:])
+			count := binary.BigEndian.Uint16(request[10:])
+
+			pdu := []byte{functionReadHoldingRegisters, byte(count * 2)}
+			for i := uint16(0); i < count; i++ {
+				value, ok := registers[address+i]
+				if !ok {
+					pdu = []byte{functionReadHoldingRegisters | 0x80, 0x02}
+					break
+				}
+				pdu = binary.BigEndian.AppendUint16(pdu, value)
+			}
+
+			reply := append([]byte{}, request[:4]...)
+			reply = binary.BigEndian.AppendUint16(reply, uint16(len(pdu)+1))
+			reply = append(reply, request[6])
+			if _, err := server.Write(append(reply, pdu...)); err != nil {
+				return
+			}
+		}
+	}()
+
+	return &Client{conn: client, unitID: 1, timeout: time.Second}
+}
+
+// sunSpecMap lays out the marker, the given models and the end marker from base
+func sunSpecMap(base uint16, models ...[]uint16) map[uint16]uint16 {
+	registers := map[uint16]uint16{base: sunSpecMarker[0], base + 1: sunSpecMarker[1]}
+	address := base + 2
+	for _, model := range models {
+		for _, value := range model {
+			registers[address] = value
+			address++
+		}
+	}
+	registers[address], registers[address+1] = modelEnd, 0
+	return registers
+}
+
+func stringRegisters(value string, length int) []uint16 {
+	padded := make([]byte, length*2)
+	copy(padded, value)
+	registers := make([]uint16, length)
+	for i := range registers {
+		registers[i] = binary.BigEndian.Uint16(padded[i*2:])
+	}
+	return registers
+}
+
+func commonModel(manufacturer, model, serial string) []uint16 {
+	registers := []uint16{ModelCommon, 66}
+	registers = append(registers, stringRegisters(manufacturer, 16)...)
+	registers = append(registers, stringRegisters(model, 16)...)
+	registers = append(registers, make([]uint16, 16)...)
+	return append(registers, append(stringRegisters(serial, 16), 1, 0x8000)...)
+}
+
+// inverterModelRegisters is a three phase inverter model with every value unimplemented except those set
+func inverterModelRegisters(set map[int]uint16) []uint16 {
+	registers := make([]uint16, 52)
+	registers[0], registers[1] = ModelInverterThreePhase, 50
+	for i := 2; i < len(registers); i++ {
+		registers[i] = 0x8000
+	}
+	registers[inverterWH], registers[inverterWH+1] = 0, 0
+	registers[inverterSt] = 0xFFFF
+	for offset, value := range set {
+		registers[offset] = value
+	}
+	return registers
+}
+
+func TestDiscover(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     uint16
+		models   [][]uint16
+		want     []Model
+		maker    string
+		serial   string
+		notFound bool
+	}{
+		{
+			name:   "common and inverter at 40000",
+			base:   40000,
+			models: [][]uint16{commonModel("Acme Solar", "X-100", "SN12345"), inverterModelRegisters(nil)},
+			want:   []Model{{ModelCommon, 40002, 66}, {ModelInverterThreePhase, 40070, 50}},
+			maker:  "Acme Solar",
+			serial: "SN12345",
+		},
+		{
+			name:   "inverter only at 50000",
+			base:   50000,
+			models: [][]uint16{inverterModelRegisters(nil)},
+			want:   []Model{{ModelInverterThreePhase, 50002, 50}},
+		},
+		{
+			name:   "no models at 0",
+			base:   0,
+			models: nil,
+		},
+		{
+			name:     "no marker",
+			base:     30000,
+			notFound: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device, err := Discover(fakeDevice(t, sunSpecMap(tt.base, tt.models...)))
+			if tt.notFound {
+				if !errors.Is(err, ErrNotSunSpec) {
+					t.Fatalf("got %v, want ErrNotSunSpec", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(device.Models) != len(tt.want) {
+				t.Fatalf("models = %+v, want %+v", device.Models, tt.want)
+			}
+			for i := range tt.want {
+				if device.Models[i] != tt.want[i] {
+					t.Errorf("model %d = %+v, want %+v", i, device.Models[i], tt.want[i])
+				}
+			}
+			if device.Manufacturer != tt.maker || device.SerialNumber != tt.serial {
+				t.Errorf("device = %q %q, want %q %q", device.Manufacturer, device.SerialNumber, tt.maker, tt.serial)
+			}
+		})
+	}
+}
+
+func TestReadInverter(t *testing.T) {
+	tests := []struct {
+		name string
+		set  map[int]uint16
+		want InverterReading
+	}{
+		{
+			name: "scaled values",
+			set: map[int]uint16{
+				inverterW: 12345, inverterWSF: 0xFFFF, // -1
+				inverterDCW: 1280, inverterDCWSF: 1,
+				inverterWH: 0x0001, inverterWH + 1: 0x86A0, inverterWHSF: 0,
+				inverterSt: 4,
+			},
+			want: InverterReading{PowerW: ptr(1234.5), DCPowerW: ptr(12800.0), LifetimeEnergyWh: ptr(100000.0), OperatingState: ptr(4)},
+		},
+		{
+			name: "negative power",
+			set:  map[int]uint16{inverterW: 0xFFF6, inverterWSF: 0},
+			want: InverterReading{PowerW: ptr(-10.0)},
+		},
+		{
+			name: "unimplemented scale factor",
+			set:  map[int]uint16{inverterW: 500, inverterWSF: 0x8000, inverterWH: 0, inverterWH + 1: 10, inverterWHSF: 0x8000},
+			want: InverterReading{},
+		},
+		{
+			name: "nothing implemented",
+			want: InverterReading{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fakeDevice(t, sunSpecMap(40000, inverterModelRegisters(tt.set)))
+			device, err := Discover(client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reading, err := ReadInverter(client, device)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !equalPtr(reading.PowerW, tt.want.PowerW) || !equalPtr(reading.DCPowerW, tt.want.DCPowerW) ||
+				!equalPtr(reading.LifetimeEnergyWh, tt.want.LifetimeEnergyWh) || !equalPtr(reading.OperatingState, tt.want.OperatingState) {
+				t.Errorf("reading = %s, want %s", describe(*reading), describe(tt.want))
+			}
+		})
+	}
+}
+
+func TestReadInverterErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		models [][]uint16
+	}{
+		{"no inverter model", [][]uint16{commonModel("Acme", "X", "1")}},
+		{"short inverter model", [][]uint16{{ModelInverterSinglePhase, 10, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fakeDevice(t, sunSpecMap(40000, tt.models...))
+			device, err := Discover(client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ReadInverter(client, device); err == nil {
+				t.Error("ReadInverter succeeded, want an error")
+			}
+		})
+	}
+}
+
+// describe prints a reading's values rather than its pointers
+func describe(r InverterReading) string {
+	var parts []string
+	for _, v := range []*float64{r.PowerW, r.DCPowerW, r.LifetimeEnergyWh} {
+		if v == nil {
+			parts = append(parts, "nil")
+		} else {
+			parts = append(parts, fmt.Sprint(*v))
+		}
+	}
+	if r.OperatingState == nil {
+		parts = append(parts, "nil")
+	} else {
+		parts = append(parts, fmt.Sprint(*r.OperatingState))
+	}
+	return strings.Join(parts, " ")
+}